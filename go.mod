@@ -2,6 +2,10 @@ module github.com/PhucNguyen204/sigma-engine-golang
 
 go 1.24.5
 
-// require github.com/cespare/xxhash/v2 v2.3.0
-
-require github.com/cespare/xxhash/v2 v2.3.0
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	golang.org/x/net v0.45.0
+	golang.org/x/text v0.29.0
+	google.golang.org/protobuf v1.36.10
+	gopkg.in/yaml.v3 v3.0.1
+)
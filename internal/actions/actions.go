@@ -0,0 +1,297 @@
+// Package actions provides built-in post-match actions for the DAG engine:
+// tagging matches, suppressing repeated matches, counting matches and
+// posting them to webhooks. Actions run in the order they are added to the
+// engine, so a suppression action added before a webhook keeps repeated
+// matches from being posted.
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+)
+
+// SuppressedTag tags matches the suppression action has seen within its
+// window. Webhook actions skip them.
+const SuppressedTag = "suppressed"
+
+var (
+	_ dag.MatchAction = (*TagAction)(nil)
+	_ dag.MatchAction = (*SuppressAction)(nil)
+	_ dag.MatchAction = (*CounterAction)(nil)
+	_ dag.MatchAction = (*WebhookAction)(nil)
+)
+
+// TagAction tags matches, e.g. with the team owning the rule
+type TagAction struct {
+	tags []string
+}
+
+// NewTagAction creates an action adding the given tags
+func NewTagAction(tags ...string) *TagAction {
+	return &TagAction{tags: tags}
+}
+
+// Name implements dag.MatchAction
+func (a *TagAction) Name() string {
+	return "tag"
+}
+
+// Execute implements dag.MatchAction
+func (a *TagAction) Execute(ctx context.Context, match *dag.ActionMatch) error {
+	for _, tag := range a.tags {
+		match.AddTag(tag)
+	}
+	return nil
+}
+
+// minSuppressionSweep is the size below which a suppression list does not
+// sweep out expired keys
+const minSuppressionSweep = 64
+
+// SuppressionList holds keys that expire after a time to live. It is safe
+// for concurrent use.
+type SuppressionList struct {
+	mu      sync.Mutex
+	clock   clock.Clock
+	expires map[string]time.Time
+
+	// Size at which Add sweeps out expired keys: twice the keys left by the
+	// last sweep, so sweeps cost amortised O(1) per Add and the list holds
+	// at most twice its live keys
+	sweepAt int
+}
+
+// NewSuppressionList creates an empty list timed by c (nil = system clock)
+func NewSuppressionList(c clock.Clock) *SuppressionList {
+	return &SuppressionList{clock: clock.Or(c), expires: make(map[string]time.Time), sweepAt: minSuppressionSweep}
+}
+
+// Add suppresses a key for ttl, reporting false when it was already
+// suppressed (the expiry is left unchanged then)
+func (l *SuppressionList) Add(key string, ttl time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	if expires, exists := l.expires[key]; exists && now.Before(expires) {
+		return false
+	}
+	l.expires[key] = now.Add(ttl)
+	if len(l.expires) >= l.sweepAt {
+		l.sweep(now)
+		l.sweepAt = max(2*len(l.expires), minSuppressionSweep)
+	}
+	return true
+}
+
+// sweep drops the keys expired at now
+func (l *SuppressionList) sweep(now time.Time) {
+	for key, expires := range l.expires {
+		if !now.Before(expires) {
+			delete(l.expires, key)
+		}
+	}
+}
+
+// Contains reports whether a key is suppressed
+func (l *SuppressionList) Contains(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	expires, exists := l.expires[key]
+	return exists && l.clock.Now().Before(expires)
+}
+
+// Remove lifts the suppression of a key
+func (l *SuppressionList) Remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.expires, key)
+}
+
+// Len returns the number of suppressed keys, dropping expired ones
+func (l *SuppressionList) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(l.clock.Now())
+	return len(l.expires)
+}
+
+// SuppressAction adds matches to a suppression list, keyed by the rule and
+// the value of an event field (e.g. the host), and tags matches that were
+// already suppressed with SuppressedTag
+type SuppressAction struct {
+	list  *SuppressionList
+	field string
+	ttl   time.Duration
+}
+
+// NewSuppressAction creates an action suppressing matches for ttl. With an
+// empty field every match of a rule shares one key.
+func NewSuppressAction(list *SuppressionList, field string, ttl time.Duration) *SuppressAction {
+	return &SuppressAction{list: list, field: field, ttl: ttl}
+}
+
+// SuppressionKey returns the key of a match of a rule for the given field
+// value
+func SuppressionKey(ruleId ir.RuleID, value string) string {
+	return fmt.Sprintf("%d|%s", ruleId, value)
+}
+
+// Name implements dag.MatchAction
+func (a *SuppressAction) Name() string {
+	return "suppress"
+}
+
+// Execute implements dag.MatchAction
+func (a *SuppressAction) Execute(ctx context.Context, match *dag.ActionMatch) error {
+	value := ""
+	if a.field != "" {
+		fieldValue, err := matcher.DefaultFieldExtractor(match.Event, a.field)
+		if err == nil && fieldValue != nil {
+			value = fmt.Sprint(fieldValue)
+		}
+	}
+	if !a.list.Add(SuppressionKey(match.Rule.ID, value), a.ttl) {
+		match.AddTag(SuppressedTag)
+	}
+	return nil
+}
+
+// CounterAction counts matches per rule. It is safe for concurrent use.
+type CounterAction struct {
+	mu     sync.Mutex
+	counts map[ir.RuleID]uint64
+}
+
+// NewCounterAction creates an action with all counts at zero
+func NewCounterAction() *CounterAction {
+	return &CounterAction{counts: make(map[ir.RuleID]uint64)}
+}
+
+// Name implements dag.MatchAction
+func (a *CounterAction) Name() string {
+	return "counter"
+}
+
+// Execute implements dag.MatchAction
+func (a *CounterAction) Execute(ctx context.Context, match *dag.ActionMatch) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.counts[match.Rule.ID]++
+	return nil
+}
+
+// Count returns the number of matches of a rule
+func (a *CounterAction) Count(ruleId ir.RuleID) uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.counts[ruleId]
+}
+
+// Counts returns the number of matches per rule
+func (a *CounterAction) Counts() map[ir.RuleID]uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	counts := make(map[ir.RuleID]uint64, len(a.counts))
+	for ruleId, count := range a.counts {
+		counts[ruleId] = count
+	}
+	return counts
+}
+
+// WebhookPayload is the JSON body a webhook action posts for a match
+type WebhookPayload struct {
+	RuleID    ir.RuleID              `json:"rule_id"`
+	RuleUUID  string                 `json:"rule_uuid,omitempty"`
+	Title     string                 `json:"title,omitempty"`
+	Level     string                 `json:"level,omitempty"`
+	RuleTags  []string               `json:"rule_tags,omitempty"`
+	Tags      []string               `json:"tags,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+	Event     interface{}            `json:"event"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// WebhookAction posts matches as JSON to a URL. Matches tagged with
+// SuppressedTag are skipped.
+type WebhookAction struct {
+	url    string
+	client *http.Client
+	clock  clock.Clock
+}
+
+// NewWebhookAction creates an action posting to url with client (nil =
+// http.DefaultClient). Bound the time of a post with the engine's action
+// timeout or the client's.
+func NewWebhookAction(url string, client *http.Client) *WebhookAction {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebhookAction{url: url, client: client, clock: clock.System()}
+}
+
+// WithClock sets the time source of payload timestamps
+func (a *WebhookAction) WithClock(c clock.Clock) *WebhookAction {
+	a.clock = clock.Or(c)
+	return a
+}
+
+// Name implements dag.MatchAction
+func (a *WebhookAction) Name() string {
+	return "webhook"
+}
+
+// Execute implements dag.MatchAction
+func (a *WebhookAction) Execute(ctx context.Context, match *dag.ActionMatch) error {
+	for _, tag := range match.Tags {
+		if tag == SuppressedTag {
+			return nil
+		}
+	}
+
+	payload := WebhookPayload{
+		RuleID:    match.Rule.ID,
+		RuleUUID:  match.Rule.SigmaID,
+		Title:     match.Rule.Title,
+		RuleTags:  match.Rule.Tags,
+		Tags:      match.Tags,
+		Event:     match.Event,
+		Timestamp: a.clock.Now().UTC(),
+	}
+	if match.Rule.Level != dag.LevelUnknown {
+		payload.Level = match.Rule.Level.String()
+	}
+	if match.Details != nil && len(match.Details.Fields) > 0 {
+		payload.Fields = match.Details.Fields
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := a.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+)
+
+func TestSuppressionList(t *testing.T) {
+	c := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	list := NewSuppressionList(c)
+
+	if !list.Add("host-a", time.Minute) {
+		t.Error("Expected the first Add to suppress the key")
+	}
+	if list.Add("host-a", time.Hour) || !list.Contains("host-a") {
+		t.Error("Expected the key to stay suppressed")
+	}
+	c.Advance(time.Minute)
+	if list.Contains("host-a") || list.Len() != 0 {
+		t.Error("Expected the suppression to expire after its time to live")
+	}
+	list.Add("host-b", time.Minute)
+	list.Remove("host-b")
+	if list.Contains("host-b") {
+		t.Error("Expected Remove to lift the suppression")
+	}
+}
+
+func TestSuppressionListBounded(t *testing.T) {
+	c := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	list := NewSuppressionList(c)
+
+	// Distinct keys outliving ten steps of the clock leave ten live keys,
+	// and Add sweeps the expired ones without Len being called
+	for i := 0; i < 10000; i++ {
+		c.Advance(time.Second)
+		list.Add(fmt.Sprintf("host-%d", i), 10*time.Second)
+		if size := len(list.expires); size > minSuppressionSweep {
+			t.Fatalf("Expected at most %d keys after %d adds, got %d", minSuppressionSweep, i+1, size)
+		}
+	}
+	if list.Len() != 10 {
+		t.Errorf("Expected 10 live keys, got %d", list.Len())
+	}
+}
+
+func TestSuppressAndTagActions(t *testing.T) {
+	list := NewSuppressionList(clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	suppress := NewSuppressAction(list, "host.name", time.Hour)
+	tag := NewTagAction("soc", "tier1")
+
+	run := func(host string) *dag.ActionMatch {
+		match := &dag.ActionMatch{
+			Event: map[string]interface{}{"host": map[string]interface{}{"name": host}},
+			Rule:  dag.RuleMeta{ID: 7},
+		}
+		for _, action := range []dag.MatchAction{tag, suppress} {
+			if err := action.Execute(context.Background(), match); err != nil {
+				t.Fatalf("%s failed: %v", action.Name(), err)
+			}
+		}
+		return match
+	}
+
+	if match := run("ws-01"); len(match.Tags) != 2 {
+		t.Errorf("Expected only the tag action's tags on the first match, got %v", match.Tags)
+	}
+	if match := run("ws-01"); len(match.Tags) != 3 || match.Tags[2] != SuppressedTag {
+		t.Errorf("Expected the repeated match to be suppressed, got %v", match.Tags)
+	}
+	if match := run("ws-02"); len(match.Tags) != 2 {
+		t.Errorf("Expected matches on other hosts not to be suppressed, got %v", match.Tags)
+	}
+	if !list.Contains(SuppressionKey(7, "ws-01")) {
+		t.Error("Expected the host to be on the suppression list")
+	}
+}
+
+func TestWebhookAction(t *testing.T) {
+	var mu sync.Mutex
+	var payloads []WebhookPayload
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Invalid payload: %v", err)
+		}
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	webhook := NewWebhookAction(server.URL, server.Client())
+	match := &dag.ActionMatch{
+		Event: map[string]interface{}{"Image": "lsass.exe"},
+		Rule:  dag.RuleMeta{ID: 2, SigmaID: "rule-two", Title: "Credential dumping", Level: dag.LevelHigh},
+		Tags:  []string{"soc"},
+	}
+	if err := webhook.Execute(context.Background(), match); err != nil {
+		t.Fatalf("Webhook failed: %v", err)
+	}
+	if len(payloads) != 1 || payloads[0].RuleUUID != "rule-two" || payloads[0].Level != "high" ||
+		payloads[0].Tags[0] != "soc" || payloads[0].Event.(map[string]interface{})["Image"] != "lsass.exe" {
+		t.Errorf("Unexpected payload: %+v", payloads)
+	}
+
+	// Suppressed matches are not posted
+	match.AddTag(SuppressedTag)
+	if err := webhook.Execute(context.Background(), match); err != nil || len(payloads) != 1 {
+		t.Errorf("Expected suppressed matches to be skipped, got %d posts (%v)", len(payloads), err)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := webhook.Execute(context.Background(), &dag.ActionMatch{Rule: dag.RuleMeta{ID: 2}}); err == nil {
+		t.Error("Expected an error status to fail the action")
+	}
+}
+
+func TestEngineActions(t *testing.T) {
+	compiled := dag.NewCompiledDag()
+	primitive := dag.NewDagNode(0, dag.NewPrimitiveNodeType(0))
+	primitive.Dependents = []dag.NodeId{1}
+	result := dag.NewDagNode(1, dag.NewResultNodeType(1))
+	result.Dependencies = []dag.NodeId{0}
+	compiled.Nodes = []dag.DagNode{*primitive, *result}
+	compiled.ExecutionOrder = []dag.NodeId{0, 1}
+	compiled.PrimitiveMap[0] = 0
+	compiled.RuleResults[1] = 1
+	compiled.ResultBufferSize = 2
+	ruleset := &dag.CompiledRuleset{
+		Primitives: []dag.Primitive{{ID: 0, Field: "Image", MatchType: "endswith", Values: []string{`\mimikatz.exe`}}},
+		Dag:        compiled,
+		Rules:      []dag.RuleMeta{{ID: 1, SigmaID: "mimikatz", Title: "Mimikatz"}},
+	}
+
+	posts := 0
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		posts++
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	counter := NewCounterAction()
+	engine, err := dag.NewDagEngineBuilder().
+		WithOptimization(false).
+		WithActionConcurrency(1).
+		WithAction(counter).
+		WithRuleAction("mimikatz", NewSuppressAction(NewSuppressionList(nil), "Computer", time.Hour)).
+		WithRuleAction("mimikatz", NewWebhookAction(server.URL, server.Client())).
+		BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	for _, computer := range []string{"ws-01", "ws-01", "ws-02"} {
+		event := map[string]interface{}{"Image": `C:\Temp\mimikatz.exe`, "Computer": computer}
+		if _, err := engine.Evaluate(event); err != nil {
+			t.Fatalf("Evaluation failed: %v", err)
+		}
+	}
+	engine.FlushActions()
+
+	if counter.Count(1) != 3 {
+		t.Errorf("Expected 3 counted matches, got %d", counter.Count(1))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if posts != 2 {
+		t.Errorf("Expected the repeated match to be suppressed, got %d posts", posts)
+	}
+}
@@ -0,0 +1,276 @@
+// Package attack maps rules to MITRE ATT&CK through their SIGMA tags
+// (attack.t1059.001, attack.execution) and reports which techniques and
+// tactics a rule set covers, against a bundled list of ATT&CK Enterprise
+// techniques. Coverage exports as ATT&CK Navigator layers.
+package attack
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+)
+
+// techniqueTag matches technique and sub-technique tags
+var techniqueTag = regexp.MustCompile(`^attack\.t(\d{4})(\.\d{3})?$`)
+
+// techniquesByID indexes the bundled techniques
+var techniquesByID = func() map[string]Technique {
+	index := make(map[string]Technique, len(techniques))
+	for _, technique := range techniques {
+		index[technique.ID] = technique
+	}
+	return index
+}()
+
+// Techniques returns the bundled ATT&CK Enterprise techniques, without
+// sub-techniques, ordered by ID
+func Techniques() []Technique {
+	return append([]Technique(nil), techniques...)
+}
+
+// LookupTechnique returns a bundled technique by ID. Sub-techniques
+// resolve to their parent technique.
+func LookupTechnique(id string) (Technique, bool) {
+	id = strings.ToUpper(id)
+	if parent, _, found := strings.Cut(id, "."); found {
+		id = parent
+	}
+	technique, ok := techniquesByID[id]
+	return technique, ok
+}
+
+// ParseTags returns the technique IDs (e.g. "T1059.001") and tactics
+// (e.g. "defense-evasion") among a rule's tags. Tags are case-insensitive
+// and tactics may be written with underscores, as in older rules.
+func ParseTags(tags []string) (techniqueIDs, tactics []string) {
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if match := techniqueTag.FindStringSubmatch(tag); match != nil {
+			techniqueIDs = append(techniqueIDs, "T"+match[1]+match[2])
+			continue
+		}
+		if tactic, ok := strings.CutPrefix(tag, "attack."); ok {
+			tactic = strings.ReplaceAll(tactic, "_", "-")
+			for _, known := range Tactics {
+				if tactic == known {
+					tactics = append(tactics, tactic)
+					break
+				}
+			}
+		}
+	}
+	return techniqueIDs, tactics
+}
+
+// TechniqueCoverage is a technique or sub-technique tagged by rules
+type TechniqueCoverage struct {
+	ID string `json:"id"`
+	// Name of the technique, or of the parent of a sub-technique (empty
+	// for techniques missing from the bundled list)
+	Name    string   `json:"name,omitempty"`
+	Tactics []string `json:"tactics,omitempty"`
+
+	// Rules tagged with the technique, and their SIGMA UUIDs
+	Rules     int      `json:"rules"`
+	RuleUUIDs []string `json:"rule_uuids,omitempty"`
+}
+
+// TacticCoverage is the coverage of a tactic
+type TacticCoverage struct {
+	Tactic string `json:"tactic"`
+
+	// Rules tagged with the tactic or with one of its techniques
+	Rules int `json:"rules"`
+
+	// Bundled techniques of the tactic covered by a rule, and in total
+	CoveredTechniques int `json:"covered_techniques"`
+	Techniques        int `json:"techniques"`
+}
+
+// Coverage is the ATT&CK coverage of a rule set
+type Coverage struct {
+	// Rules, and rules with at least one technique or tactic tag
+	Rules       int `json:"rules"`
+	TaggedRules int `json:"tagged_rules"`
+
+	// Tagged techniques and sub-techniques, ordered by ID
+	Techniques []TechniqueCoverage `json:"techniques"`
+
+	// Every tactic, in matrix order
+	Tactics []TacticCoverage `json:"tactics"`
+
+	// Bundled techniques no rule is tagged with, neither directly nor
+	// through a sub-technique, ordered by ID
+	Uncovered []Technique `json:"uncovered"`
+}
+
+// NewCoverage computes the ATT&CK coverage of rules from their tags
+func NewCoverage(rules []dag.RuleMeta) *Coverage {
+	coverage := &Coverage{Rules: len(rules)}
+	byTechnique := make(map[string]*TechniqueCoverage)
+	coveredParents := make(map[string]bool)
+	tacticRules := make(map[string]int)
+
+	for _, rule := range rules {
+		techniqueIDs, tactics := ParseTags(rule.Tags)
+		if len(techniqueIDs) == 0 && len(tactics) == 0 {
+			continue
+		}
+		coverage.TaggedRules++
+
+		ruleTactics := make(map[string]bool)
+		for _, tactic := range tactics {
+			ruleTactics[tactic] = true
+		}
+		seen := make(map[string]bool)
+		for _, id := range techniqueIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			technique := byTechnique[id]
+			if technique == nil {
+				technique = &TechniqueCoverage{ID: id}
+				if known, ok := LookupTechnique(id); ok {
+					technique.Name = known.Name
+					technique.Tactics = known.Tactics
+					coveredParents[known.ID] = true
+				}
+				byTechnique[id] = technique
+			}
+			technique.Rules++
+			if rule.SigmaID != "" {
+				technique.RuleUUIDs = append(technique.RuleUUIDs, rule.SigmaID)
+			}
+			for _, tactic := range technique.Tactics {
+				ruleTactics[tactic] = true
+			}
+		}
+		for tactic := range ruleTactics {
+			tacticRules[tactic]++
+		}
+	}
+
+	for _, technique := range byTechnique {
+		coverage.Techniques = append(coverage.Techniques, *technique)
+	}
+	sort.Slice(coverage.Techniques, func(i, j int) bool {
+		return coverage.Techniques[i].ID < coverage.Techniques[j].ID
+	})
+
+	tactics := make(map[string]*TacticCoverage, len(Tactics))
+	for _, tactic := range Tactics {
+		coverage.Tactics = append(coverage.Tactics, TacticCoverage{Tactic: tactic, Rules: tacticRules[tactic]})
+	}
+	for i := range coverage.Tactics {
+		tactics[coverage.Tactics[i].Tactic] = &coverage.Tactics[i]
+	}
+	for _, technique := range techniques {
+		covered := coveredParents[technique.ID]
+		if !covered {
+			coverage.Uncovered = append(coverage.Uncovered, technique)
+		}
+		for _, tactic := range technique.Tactics {
+			tactics[tactic].Techniques++
+			if covered {
+				tactics[tactic].CoveredTechniques++
+			}
+		}
+	}
+	return coverage
+}
+
+// EngineCoverage computes the ATT&CK coverage of the rules loaded in an
+// engine
+func EngineCoverage(engine *dag.DagEngine) *Coverage {
+	var rules []dag.RuleMeta
+	for _, ruleId := range engine.RuleIDs() {
+		if meta, ok := engine.RuleMeta(uint32(ruleId)); ok {
+			rules = append(rules, meta)
+		}
+	}
+	return NewCoverage(rules)
+}
+
+// Technique returns the coverage of a technique or sub-technique
+func (c *Coverage) Technique(id string) (TechniqueCoverage, bool) {
+	id = strings.ToUpper(id)
+	for _, technique := range c.Techniques {
+		if technique.ID == id {
+			return technique, true
+		}
+	}
+	return TechniqueCoverage{}, false
+}
+
+// WriteJSON writes the coverage as indented JSON
+func (c *Coverage) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
+
+// Navigator layer format, limited to what coverage layers use
+type navigatorLayer struct {
+	Name        string                `json:"name"`
+	Versions    navigatorVersions     `json:"versions"`
+	Domain      string                `json:"domain"`
+	Description string                `json:"description,omitempty"`
+	Techniques  []navigatorTechnique  `json:"techniques"`
+	Gradient    navigatorGradient     `json:"gradient"`
+	Legend      []navigatorLegendItem `json:"legendItems"`
+}
+
+type navigatorVersions struct {
+	Attack    string `json:"attack"`
+	Navigator string `json:"navigator"`
+	Layer     string `json:"layer"`
+}
+
+type navigatorTechnique struct {
+	TechniqueID string `json:"techniqueID"`
+	Score       int    `json:"score"`
+	Comment     string `json:"comment,omitempty"`
+	Enabled     bool   `json:"enabled"`
+}
+
+type navigatorGradient struct {
+	Colors   []string `json:"colors"`
+	MinValue int      `json:"minValue"`
+	MaxValue int      `json:"maxValue"`
+}
+
+type navigatorLegendItem struct {
+	Label string `json:"label"`
+	Color string `json:"color"`
+}
+
+// WriteNavigatorLayer writes the coverage as an ATT&CK Navigator layer,
+// scoring each tagged technique by its number of rules
+func (c *Coverage) WriteNavigatorLayer(w io.Writer, name string) error {
+	layer := navigatorLayer{
+		Name:        name,
+		Versions:    navigatorVersions{Attack: Version, Navigator: "4.9.1", Layer: "4.5"},
+		Domain:      "enterprise-attack",
+		Description: "SIGMA rule coverage",
+		Techniques:  []navigatorTechnique{},
+		Gradient:    navigatorGradient{Colors: []string{"#ffffff", "#66b1ff"}, MinValue: 0, MaxValue: 1},
+		Legend:      []navigatorLegendItem{{Label: "covered by rules", Color: "#66b1ff"}},
+	}
+	for _, technique := range c.Techniques {
+		layer.Techniques = append(layer.Techniques, navigatorTechnique{
+			TechniqueID: technique.ID,
+			Score:       technique.Rules,
+			Comment:     strings.Join(technique.RuleUUIDs, ", "),
+			Enabled:     true,
+		})
+		layer.Gradient.MaxValue = max(layer.Gradient.MaxValue, technique.Rules)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(layer)
+}
@@ -0,0 +1,155 @@
+package attack
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/compiler"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+)
+
+func TestBundledTechniques(t *testing.T) {
+	seen := make(map[string]bool)
+	for i, technique := range Techniques() {
+		if seen[technique.ID] || (i > 0 && techniques[i-1].ID >= technique.ID) {
+			t.Errorf("Technique %s is duplicated or out of order", technique.ID)
+		}
+		seen[technique.ID] = true
+		if len(technique.Tactics) == 0 {
+			t.Errorf("Technique %s has no tactic", technique.ID)
+		}
+		for _, tactic := range technique.Tactics {
+			if _, tactics := ParseTags([]string{"attack." + tactic}); len(tactics) != 1 {
+				t.Errorf("Technique %s has an unknown tactic %q", technique.ID, tactic)
+			}
+		}
+	}
+	if technique, ok := LookupTechnique("t1059.001"); !ok || technique.Name != "Command and Scripting Interpreter" {
+		t.Errorf("Expected sub-techniques to resolve to their parent, got %+v", technique)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	techniqueIDs, tactics := ParseTags([]string{
+		"attack.execution", "attack.T1059.001", "attack.defense_evasion", "attack.t1027",
+		"attack.g0007", "attack.s0002", "car.2013-05-004", "attack.t10",
+	})
+	if !reflect.DeepEqual(techniqueIDs, []string{"T1059.001", "T1027"}) {
+		t.Errorf("Unexpected techniques: %v", techniqueIDs)
+	}
+	if !reflect.DeepEqual(tactics, []string{"execution", "defense-evasion"}) {
+		t.Errorf("Unexpected tactics: %v", tactics)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	coverage := NewCoverage([]dag.RuleMeta{
+		{SigmaID: "rule-1", Tags: []string{"attack.execution", "attack.t1059.001"}},
+		{SigmaID: "rule-2", Tags: []string{"attack.t1059.001", "attack.t1059.003"}},
+		{SigmaID: "rule-3", Tags: []string{"attack.credential_access", "attack.t1003", "attack.t9999"}},
+		{SigmaID: "rule-4", Tags: []string{"attack.impact"}},
+		{SigmaID: "rule-5", Tags: []string{"detection.threat_hunting"}},
+	})
+
+	if coverage.Rules != 5 || coverage.TaggedRules != 4 || len(coverage.Techniques) != 4 {
+		t.Fatalf("Unexpected coverage: %+v", coverage)
+	}
+	powershell, ok := coverage.Technique("t1059.001")
+	if !ok || powershell.Rules != 2 || powershell.Name != "Command and Scripting Interpreter" ||
+		!reflect.DeepEqual(powershell.RuleUUIDs, []string{"rule-1", "rule-2"}) {
+		t.Errorf("Unexpected sub-technique coverage: %+v", powershell)
+	}
+	if unknown, _ := coverage.Technique("T9999"); unknown.Rules != 1 || unknown.Name != "" {
+		t.Errorf("Expected unknown techniques to be reported, got %+v", unknown)
+	}
+
+	// Tactics count rules tagged with them or with one of their techniques
+	rules := make(map[string]TacticCoverage)
+	for _, tactic := range coverage.Tactics {
+		rules[tactic.Tactic] = tactic
+	}
+	if len(coverage.Tactics) != len(Tactics) || rules["execution"].Rules != 2 || rules["credential-access"].Rules != 1 ||
+		rules["impact"].Rules != 1 || rules["discovery"].Rules != 0 {
+		t.Errorf("Unexpected tactic coverage: %+v", coverage.Tactics)
+	}
+	if execution := rules["execution"]; execution.CoveredTechniques != 1 || execution.Techniques < 10 {
+		t.Errorf("Unexpected execution techniques: %+v", execution)
+	}
+
+	// A sub-technique covers its parent
+	if len(coverage.Uncovered) != len(techniques)-2 {
+		t.Errorf("Expected all but 2 techniques uncovered, got %d", len(coverage.Uncovered))
+	}
+	for _, technique := range coverage.Uncovered {
+		if technique.ID == "T1059" || technique.ID == "T1003" {
+			t.Errorf("Technique %s should be covered", technique.ID)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := coverage.WriteJSON(&out); err != nil {
+		t.Fatalf("Failed to write JSON: %v", err)
+	}
+	var decoded Coverage
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || !reflect.DeepEqual(&decoded, coverage) {
+		t.Errorf("Expected the JSON to round-trip, got %v", err)
+	}
+}
+
+func TestNavigatorLayer(t *testing.T) {
+	coverage := NewCoverage([]dag.RuleMeta{
+		{SigmaID: "rule-1", Tags: []string{"attack.t1059.001"}},
+		{SigmaID: "rule-2", Tags: []string{"attack.t1059.001", "attack.t1003"}},
+	})
+
+	var out bytes.Buffer
+	if err := coverage.WriteNavigatorLayer(&out, "Production rules"); err != nil {
+		t.Fatalf("Failed to write layer: %v", err)
+	}
+	var layer struct {
+		Name       string `json:"name"`
+		Domain     string `json:"domain"`
+		Techniques []struct {
+			TechniqueID string `json:"techniqueID"`
+			Score       int    `json:"score"`
+			Comment     string `json:"comment"`
+		} `json:"techniques"`
+		Gradient struct {
+			MaxValue int `json:"maxValue"`
+		} `json:"gradient"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &layer); err != nil {
+		t.Fatalf("Invalid layer JSON: %v", err)
+	}
+	if layer.Name != "Production rules" || layer.Domain != "enterprise-attack" || layer.Gradient.MaxValue != 2 || len(layer.Techniques) != 2 {
+		t.Fatalf("Unexpected layer: %s", out.String())
+	}
+	if technique := layer.Techniques[1]; technique.TechniqueID != "T1059.001" || technique.Score != 2 || technique.Comment != "rule-1, rule-2" {
+		t.Errorf("Unexpected layer technique: %+v", technique)
+	}
+}
+
+func TestEngineCoverage(t *testing.T) {
+	engine, err := dag.NewDagEngineBuilder().WithCompiler(compiler.NewCompiler()).Build([]string{`
+title: Encoded PowerShell
+id: 3b6ab547-8ec2-4991-b9d2-2b06702a48d7
+tags:
+    - attack.execution
+    - attack.t1059.001
+detection:
+    selection:
+        CommandLine|contains: ' -enc '
+    condition: selection
+`})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	coverage := EngineCoverage(engine)
+	if technique, ok := coverage.Technique("T1059.001"); !ok || !reflect.DeepEqual(technique.RuleUUIDs, []string{"3b6ab547-8ec2-4991-b9d2-2b06702a48d7"}) {
+		t.Errorf("Unexpected engine coverage: %+v", coverage.Techniques)
+	}
+}
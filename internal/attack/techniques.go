@@ -0,0 +1,273 @@
+package attack
+
+// Version of the ATT&CK Enterprise matrix the bundled techniques are from
+const Version = "14"
+
+// ATT&CK Enterprise tactics, by the short names used in SIGMA tags (with
+// hyphens, e.g. attack.defense-evasion) and in Navigator layers
+const (
+	TacticReconnaissance      = "reconnaissance"
+	TacticResourceDevelopment = "resource-development"
+	TacticInitialAccess       = "initial-access"
+	TacticExecution           = "execution"
+	TacticPersistence         = "persistence"
+	TacticPrivilegeEscalation = "privilege-escalation"
+	TacticDefenseEvasion      = "defense-evasion"
+	TacticCredentialAccess    = "credential-access"
+	TacticDiscovery           = "discovery"
+	TacticLateralMovement     = "lateral-movement"
+	TacticCollection          = "collection"
+	TacticCommandAndControl   = "command-and-control"
+	TacticExfiltration        = "exfiltration"
+	TacticImpact              = "impact"
+)
+
+// Tactics lists the tactics in the order of the ATT&CK matrix
+var Tactics = []string{
+	TacticReconnaissance,
+	TacticResourceDevelopment,
+	TacticInitialAccess,
+	TacticExecution,
+	TacticPersistence,
+	TacticPrivilegeEscalation,
+	TacticDefenseEvasion,
+	TacticCredentialAccess,
+	TacticDiscovery,
+	TacticLateralMovement,
+	TacticCollection,
+	TacticCommandAndControl,
+	TacticExfiltration,
+	TacticImpact,
+}
+
+// Technique is an ATT&CK Enterprise technique
+type Technique struct {
+	// Technique ID, e.g. "T1059", or "T1059.001" for a sub-technique
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Tactics []string `json:"tactics"`
+}
+
+// shorthands for the technique table
+const (
+	recon   = TacticReconnaissance
+	resdev  = TacticResourceDevelopment
+	initial = TacticInitialAccess
+	exec    = TacticExecution
+	persist = TacticPersistence
+	privesc = TacticPrivilegeEscalation
+	evasion = TacticDefenseEvasion
+	creds   = TacticCredentialAccess
+	disc    = TacticDiscovery
+	lateral = TacticLateralMovement
+	collect = TacticCollection
+	c2      = TacticCommandAndControl
+	exfil   = TacticExfiltration
+	impact  = TacticImpact
+)
+
+// techniques lists the Enterprise techniques, without sub-techniques,
+// ordered by ID
+var techniques = []Technique{
+	{"T1001", "Data Obfuscation", []string{c2}},
+	{"T1003", "OS Credential Dumping", []string{creds}},
+	{"T1005", "Data from Local System", []string{collect}},
+	{"T1006", "Direct Volume Access", []string{evasion}},
+	{"T1007", "System Service Discovery", []string{disc}},
+	{"T1008", "Fallback Channels", []string{c2}},
+	{"T1010", "Application Window Discovery", []string{disc}},
+	{"T1011", "Exfiltration Over Other Network Medium", []string{exfil}},
+	{"T1012", "Query Registry", []string{disc}},
+	{"T1014", "Rootkit", []string{evasion}},
+	{"T1016", "System Network Configuration Discovery", []string{disc}},
+	{"T1018", "Remote System Discovery", []string{disc}},
+	{"T1020", "Automated Exfiltration", []string{exfil}},
+	{"T1021", "Remote Services", []string{lateral}},
+	{"T1025", "Data from Removable Media", []string{collect}},
+	{"T1027", "Obfuscated Files or Information", []string{evasion}},
+	{"T1029", "Scheduled Transfer", []string{exfil}},
+	{"T1030", "Data Transfer Size Limits", []string{exfil}},
+	{"T1033", "System Owner/User Discovery", []string{disc}},
+	{"T1036", "Masquerading", []string{evasion}},
+	{"T1037", "Boot or Logon Initialization Scripts", []string{persist, privesc}},
+	{"T1039", "Data from Network Shared Drive", []string{collect}},
+	{"T1040", "Network Sniffing", []string{creds, disc}},
+	{"T1041", "Exfiltration Over C2 Channel", []string{exfil}},
+	{"T1046", "Network Service Discovery", []string{disc}},
+	{"T1047", "Windows Management Instrumentation", []string{exec}},
+	{"T1048", "Exfiltration Over Alternative Protocol", []string{exfil}},
+	{"T1049", "System Network Connections Discovery", []string{disc}},
+	{"T1052", "Exfiltration Over Physical Medium", []string{exfil}},
+	{"T1053", "Scheduled Task/Job", []string{exec, persist, privesc}},
+	{"T1055", "Process Injection", []string{evasion, privesc}},
+	{"T1056", "Input Capture", []string{collect, creds}},
+	{"T1057", "Process Discovery", []string{disc}},
+	{"T1059", "Command and Scripting Interpreter", []string{exec}},
+	{"T1068", "Exploitation for Privilege Escalation", []string{privesc}},
+	{"T1069", "Permission Groups Discovery", []string{disc}},
+	{"T1070", "Indicator Removal", []string{evasion}},
+	{"T1071", "Application Layer Protocol", []string{c2}},
+	{"T1072", "Software Deployment Tools", []string{exec, lateral}},
+	{"T1074", "Data Staged", []string{collect}},
+	{"T1078", "Valid Accounts", []string{evasion, persist, privesc, initial}},
+	{"T1080", "Taint Shared Content", []string{lateral}},
+	{"T1082", "System Information Discovery", []string{disc}},
+	{"T1083", "File and Directory Discovery", []string{disc}},
+	{"T1087", "Account Discovery", []string{disc}},
+	{"T1090", "Proxy", []string{c2}},
+	{"T1091", "Replication Through Removable Media", []string{lateral, initial}},
+	{"T1092", "Communication Through Removable Media", []string{c2}},
+	{"T1095", "Non-Application Layer Protocol", []string{c2}},
+	{"T1098", "Account Manipulation", []string{persist, privesc}},
+	{"T1102", "Web Service", []string{c2}},
+	{"T1104", "Multi-Stage Channels", []string{c2}},
+	{"T1105", "Ingress Tool Transfer", []string{c2}},
+	{"T1106", "Native API", []string{exec}},
+	{"T1110", "Brute Force", []string{creds}},
+	{"T1111", "Multi-Factor Authentication Interception", []string{creds}},
+	{"T1112", "Modify Registry", []string{evasion}},
+	{"T1113", "Screen Capture", []string{collect}},
+	{"T1114", "Email Collection", []string{collect}},
+	{"T1115", "Clipboard Data", []string{collect}},
+	{"T1119", "Automated Collection", []string{collect}},
+	{"T1120", "Peripheral Device Discovery", []string{disc}},
+	{"T1123", "Audio Capture", []string{collect}},
+	{"T1124", "System Time Discovery", []string{disc}},
+	{"T1125", "Video Capture", []string{collect}},
+	{"T1127", "Trusted Developer Utilities Proxy Execution", []string{evasion}},
+	{"T1129", "Shared Modules", []string{exec}},
+	{"T1132", "Data Encoding", []string{c2}},
+	{"T1133", "External Remote Services", []string{persist, initial}},
+	{"T1134", "Access Token Manipulation", []string{evasion, privesc}},
+	{"T1135", "Network Share Discovery", []string{disc}},
+	{"T1136", "Create Account", []string{persist}},
+	{"T1137", "Office Application Startup", []string{persist}},
+	{"T1140", "Deobfuscate/Decode Files or Information", []string{evasion}},
+	{"T1176", "Browser Extensions", []string{persist}},
+	{"T1185", "Browser Session Hijacking", []string{collect}},
+	{"T1187", "Forced Authentication", []string{creds}},
+	{"T1189", "Drive-by Compromise", []string{initial}},
+	{"T1190", "Exploit Public-Facing Application", []string{initial}},
+	{"T1195", "Supply Chain Compromise", []string{initial}},
+	{"T1197", "BITS Jobs", []string{evasion, persist}},
+	{"T1199", "Trusted Relationship", []string{initial}},
+	{"T1200", "Hardware Additions", []string{initial}},
+	{"T1201", "Password Policy Discovery", []string{disc}},
+	{"T1202", "Indirect Command Execution", []string{evasion}},
+	{"T1203", "Exploitation for Client Execution", []string{exec}},
+	{"T1204", "User Execution", []string{exec}},
+	{"T1205", "Traffic Signaling", []string{evasion, persist, c2}},
+	{"T1207", "Rogue Domain Controller", []string{evasion}},
+	{"T1210", "Exploitation of Remote Services", []string{lateral}},
+	{"T1211", "Exploitation for Defense Evasion", []string{evasion}},
+	{"T1212", "Exploitation for Credential Access", []string{creds}},
+	{"T1213", "Data from Information Repositories", []string{collect}},
+	{"T1216", "System Script Proxy Execution", []string{evasion}},
+	{"T1217", "Browser Information Discovery", []string{disc}},
+	{"T1218", "System Binary Proxy Execution", []string{evasion}},
+	{"T1219", "Remote Access Software", []string{c2}},
+	{"T1220", "XSL Script Processing", []string{evasion}},
+	{"T1221", "Template Injection", []string{evasion}},
+	{"T1222", "File and Directory Permissions Modification", []string{evasion}},
+	{"T1480", "Execution Guardrails", []string{evasion}},
+	{"T1482", "Domain Trust Discovery", []string{disc}},
+	{"T1484", "Domain Policy Modification", []string{evasion, privesc}},
+	{"T1485", "Data Destruction", []string{impact}},
+	{"T1486", "Data Encrypted for Impact", []string{impact}},
+	{"T1489", "Service Stop", []string{impact}},
+	{"T1490", "Inhibit System Recovery", []string{impact}},
+	{"T1491", "Defacement", []string{impact}},
+	{"T1495", "Firmware Corruption", []string{impact}},
+	{"T1496", "Resource Hijacking", []string{impact}},
+	{"T1497", "Virtualization/Sandbox Evasion", []string{evasion, disc}},
+	{"T1498", "Network Denial of Service", []string{impact}},
+	{"T1499", "Endpoint Denial of Service", []string{impact}},
+	{"T1505", "Server Software Component", []string{persist}},
+	{"T1518", "Software Discovery", []string{disc}},
+	{"T1525", "Implant Internal Image", []string{persist}},
+	{"T1526", "Cloud Service Discovery", []string{disc}},
+	{"T1528", "Steal Application Access Token", []string{creds}},
+	{"T1529", "System Shutdown/Reboot", []string{impact}},
+	{"T1530", "Data from Cloud Storage", []string{collect}},
+	{"T1531", "Account Access Removal", []string{impact}},
+	{"T1534", "Internal Spearphishing", []string{lateral}},
+	{"T1535", "Unused/Unsupported Cloud Regions", []string{evasion}},
+	{"T1537", "Transfer Data to Cloud Account", []string{exfil}},
+	{"T1538", "Cloud Service Dashboard", []string{disc}},
+	{"T1539", "Steal Web Session Cookie", []string{creds}},
+	{"T1542", "Pre-OS Boot", []string{evasion, persist}},
+	{"T1543", "Create or Modify System Process", []string{persist, privesc}},
+	{"T1546", "Event Triggered Execution", []string{persist, privesc}},
+	{"T1547", "Boot or Logon Autostart Execution", []string{persist, privesc}},
+	{"T1548", "Abuse Elevation Control Mechanism", []string{privesc, evasion}},
+	{"T1550", "Use Alternate Authentication Material", []string{evasion, lateral}},
+	{"T1552", "Unsecured Credentials", []string{creds}},
+	{"T1553", "Subvert Trust Controls", []string{evasion}},
+	{"T1554", "Compromise Client Software Binary", []string{persist}},
+	{"T1555", "Credentials from Password Stores", []string{creds}},
+	{"T1556", "Modify Authentication Process", []string{creds, evasion, persist}},
+	{"T1557", "Adversary-in-the-Middle", []string{creds, collect}},
+	{"T1558", "Steal or Forge Kerberos Tickets", []string{creds}},
+	{"T1559", "Inter-Process Communication", []string{exec}},
+	{"T1560", "Archive Collected Data", []string{collect}},
+	{"T1561", "Disk Wipe", []string{impact}},
+	{"T1562", "Impair Defenses", []string{evasion}},
+	{"T1563", "Remote Service Session Hijacking", []string{lateral}},
+	{"T1564", "Hide Artifacts", []string{evasion}},
+	{"T1565", "Data Manipulation", []string{impact}},
+	{"T1566", "Phishing", []string{initial}},
+	{"T1567", "Exfiltration Over Web Service", []string{exfil}},
+	{"T1568", "Dynamic Resolution", []string{c2}},
+	{"T1569", "System Services", []string{exec}},
+	{"T1570", "Lateral Tool Transfer", []string{lateral}},
+	{"T1571", "Non-Standard Port", []string{c2}},
+	{"T1572", "Protocol Tunneling", []string{c2}},
+	{"T1573", "Encrypted Channel", []string{c2}},
+	{"T1574", "Hijack Execution Flow", []string{persist, privesc, evasion}},
+	{"T1578", "Modify Cloud Compute Infrastructure", []string{evasion}},
+	{"T1580", "Cloud Infrastructure Discovery", []string{disc}},
+	{"T1583", "Acquire Infrastructure", []string{resdev}},
+	{"T1584", "Compromise Infrastructure", []string{resdev}},
+	{"T1585", "Establish Accounts", []string{resdev}},
+	{"T1586", "Compromise Accounts", []string{resdev}},
+	{"T1587", "Develop Capabilities", []string{resdev}},
+	{"T1588", "Obtain Capabilities", []string{resdev}},
+	{"T1589", "Gather Victim Identity Information", []string{recon}},
+	{"T1590", "Gather Victim Network Information", []string{recon}},
+	{"T1591", "Gather Victim Org Information", []string{recon}},
+	{"T1592", "Gather Victim Host Information", []string{recon}},
+	{"T1593", "Search Open Websites/Domains", []string{recon}},
+	{"T1594", "Search Victim-Owned Websites", []string{recon}},
+	{"T1595", "Active Scanning", []string{recon}},
+	{"T1596", "Search Open Technical Databases", []string{recon}},
+	{"T1597", "Search Closed Sources", []string{recon}},
+	{"T1598", "Phishing for Information", []string{recon}},
+	{"T1599", "Network Boundary Bridging", []string{evasion}},
+	{"T1600", "Weaken Encryption", []string{evasion}},
+	{"T1601", "Modify System Image", []string{evasion}},
+	{"T1602", "Data from Configuration Repository", []string{collect}},
+	{"T1606", "Forge Web Credentials", []string{creds}},
+	{"T1608", "Stage Capabilities", []string{resdev}},
+	{"T1609", "Container Administration Command", []string{exec}},
+	{"T1610", "Deploy Container", []string{evasion, exec}},
+	{"T1611", "Escape to Host", []string{privesc}},
+	{"T1612", "Build Image on Host", []string{evasion}},
+	{"T1613", "Container and Resource Discovery", []string{disc}},
+	{"T1614", "System Location Discovery", []string{disc}},
+	{"T1615", "Group Policy Discovery", []string{disc}},
+	{"T1619", "Cloud Storage Object Discovery", []string{disc}},
+	{"T1620", "Reflective Code Loading", []string{evasion}},
+	{"T1621", "Multi-Factor Authentication Request Generation", []string{creds}},
+	{"T1622", "Debugger Evasion", []string{evasion, disc}},
+	{"T1647", "Plist File Modification", []string{evasion}},
+	{"T1648", "Serverless Execution", []string{exec}},
+	{"T1649", "Steal or Forge Authentication Certificates", []string{creds}},
+	{"T1650", "Acquire Access", []string{resdev}},
+	{"T1651", "Cloud Administration Command", []string{exec}},
+	{"T1652", "Device Driver Discovery", []string{disc}},
+	{"T1653", "Power Settings", []string{persist}},
+	{"T1654", "Log Enumeration", []string{disc}},
+	{"T1656", "Impersonation", []string{evasion}},
+	{"T1657", "Financial Theft", []string{impact}},
+	{"T1659", "Content Injection", []string{initial, c2}},
+}
@@ -0,0 +1,384 @@
+// Package backtest replays archived events through a rule set to validate
+// rules before they are deployed: how often each rule fires, over which
+// period, and on which events.
+package backtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/compiler"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// Defaults of the backtest options
+const (
+	defaultMaxExamples      = 3
+	defaultParquetBatchSize = 256
+
+	// maxLineSize bounds the length of an NDJSON line
+	maxLineSize = 16 * 1024 * 1024
+)
+
+// ErrMalformedEvent is wrapped by readers for archived events that cannot
+// be decoded. Backtests count and skip them.
+var ErrMalformedEvent = stderrors.New("malformed event")
+
+// EventReader reads archived events one at a time, returning io.EOF after
+// the last one
+type EventReader interface {
+	Next() (map[string]interface{}, error)
+}
+
+// Options configures a backtest
+type Options struct {
+	// Compiler of the rules (nil = default compiler)
+	Compiler *compiler.Compiler
+
+	// Engine configuration (nil = DefaultDagEngineConfig). The clock is
+	// replaced by the backtest's virtual clock.
+	Config *dag.DagEngineConfig
+
+	// Field holding each event's timestamp (e.g. "@timestamp" or
+	// "UtcTime"). The virtual clock is set to it before the event is
+	// evaluated, so time-based features see archive time rather than wall
+	// time. Events without a readable timestamp keep the previous time.
+	TimestampField string
+
+	// Example matches kept per rule (0 = 3, negative = none)
+	MaxExamples int
+
+	// Events evaluated together with the batch evaluator (0 = one at a
+	// time, or 256 for Parquet files). The virtual clock is set to the
+	// latest timestamp of each batch before it is evaluated.
+	BatchSize int
+}
+
+// Example is an archived event a rule matched
+type Example struct {
+	// Position of the event in the archive, from 0
+	Index int
+	Time  time.Time
+	Event map[string]interface{}
+}
+
+// RuleHits reports the matches of a rule
+type RuleHits struct {
+	RuleID ir.RuleID
+	UUID   string
+	Title  string
+	Level  dag.RuleLevel
+
+	Hits uint64
+
+	// Event times of the first and last match (zero without timestamps),
+	// and the positions of their events
+	FirstSeen  time.Time
+	LastSeen   time.Time
+	FirstIndex int
+	LastIndex  int
+
+	Examples []Example
+}
+
+// Report is the outcome of a backtest
+type Report struct {
+	// Events read, events matching any rule, and events skipped because
+	// they could not be decoded
+	Events          uint64
+	MatchedEvents   uint64
+	MalformedEvents uint64
+
+	// Time span of the archive's timestamps (zero without timestamps)
+	Start time.Time
+	End   time.Time
+
+	// Rules from the most to the least hits, then by rule ID. Rules
+	// without hits are listed too, so rules that never fire stand out.
+	Rules []RuleHits
+
+	// Wall time of the replay
+	Elapsed time.Duration
+}
+
+// Rule returns the hits of the rule with a SIGMA UUID
+func (r *Report) Rule(uuid string) (RuleHits, bool) {
+	for _, rule := range r.Rules {
+		if rule.UUID == uuid {
+			return rule, true
+		}
+	}
+	return RuleHits{}, false
+}
+
+// Backtest compiles rules and replays the events of an archive through
+// them
+func Backtest(rules []string, events EventReader, opts Options) (*Report, error) {
+	engine, virtual, err := newEngine(rules, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer engine.Close()
+	return replay(engine, virtual, events, opts)
+}
+
+// BacktestParquet compiles rules and replays the rows of a Parquet file
+// through them. Only the columns of the fields the rules reference, and of
+// the timestamp field, are decoded, and rows are evaluated in batches.
+func BacktestParquet(rules []string, file *ParquetFile, opts Options) (*Report, error) {
+	engine, virtual, err := newEngine(rules, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer engine.Close()
+
+	fields := engine.ReferencedFields()
+	if opts.TimestampField != "" {
+		fields = append(fields, opts.TimestampField)
+	}
+	events, err := file.Reader(fields)
+	if err != nil {
+		return nil, err
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultParquetBatchSize
+	}
+	return replay(engine, virtual, events, opts)
+}
+
+// newEngine compiles the rules of a backtest into an engine running on a
+// virtual clock
+func newEngine(rules []string, opts Options) (*dag.DagEngine, *clock.Manual, error) {
+	config := dag.DefaultDagEngineConfig()
+	if opts.Config != nil {
+		config = *opts.Config
+	}
+	virtual := clock.NewManual(time.Time{})
+	config.Clock = virtual
+	ruleCompiler := opts.Compiler
+	if ruleCompiler == nil {
+		ruleCompiler = compiler.NewCompiler()
+	}
+
+	engine, err := dag.NewDagEngineBuilder().
+		WithConfig(config).
+		WithCompiler(ruleCompiler).
+		Build(rules)
+	if err != nil {
+		return nil, nil, err
+	}
+	return engine, virtual, nil
+}
+
+// replay evaluates the events of an archive, setting the virtual clock to
+// each event's time, or to the latest time of each batch
+func replay(engine *dag.DagEngine, virtual *clock.Manual, events EventReader, opts Options) (*Report, error) {
+	maxExamples := opts.MaxExamples
+	if maxExamples == 0 {
+		maxExamples = defaultMaxExamples
+	}
+	batchSize := max(opts.BatchSize, 1)
+
+	started := time.Now()
+	report := &Report{}
+	hits := make(map[ir.RuleID]*RuleHits)
+	result := &dag.DagEvaluationResult{}
+
+	// The current batch, with the index and time of each event. Events
+	// without a timestamp take the time of the event before them.
+	batch := make([]interface{}, 0, batchSize)
+	indexes := make([]int, 0, batchSize)
+	times := make([]time.Time, 0, batchSize)
+	var last time.Time
+
+	record := func(i int, matched []ir.RuleID) {
+		if len(matched) == 0 {
+			return
+		}
+		report.MatchedEvents++
+		index, now := indexes[i], times[i]
+		event, _ := batch[i].(map[string]interface{})
+		for _, ruleId := range matched {
+			rule := hits[ruleId]
+			if rule == nil {
+				rule = &RuleHits{RuleID: ruleId, FirstSeen: now, FirstIndex: index}
+				hits[ruleId] = rule
+			}
+			rule.Hits++
+			rule.LastSeen = now
+			rule.LastIndex = index
+			if len(rule.Examples) < maxExamples {
+				rule.Examples = append(rule.Examples, Example{Index: index, Time: now, Event: event})
+			}
+		}
+	}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		defer func() {
+			batch, indexes, times = batch[:0], indexes[:0], times[:0]
+		}()
+		if batchSize == 1 {
+			virtual.Set(times[0])
+			if err := engine.EvaluateInto(batch[0], result); err != nil {
+				return fmt.Errorf("event at index %d: %w", indexes[0], err)
+			}
+			record(0, result.MatchedRules)
+			return nil
+		}
+
+		latest := times[0]
+		for _, timestamp := range times[1:] {
+			if timestamp.After(latest) {
+				latest = timestamp
+			}
+		}
+		virtual.Set(latest)
+		results, err := engine.EvaluateBatch(batch)
+		if err != nil {
+			return fmt.Errorf("batch at index %d: %w", indexes[0], err)
+		}
+		for i, batchResult := range results {
+			if batchResult != nil {
+				record(i, batchResult.MatchedRules)
+			}
+		}
+		return nil
+	}
+
+	for index := 0; ; index++ {
+		event, err := events.Next()
+		if err == io.EOF {
+			break
+		}
+		if stderrors.Is(err, ErrMalformedEvent) {
+			report.Events++
+			report.MalformedEvents++
+			continue
+		}
+		if err != nil {
+			return nil, errors.WrapIOError(err)
+		}
+		report.Events++
+
+		if timestamp, ok := eventTime(event, opts.TimestampField); ok {
+			last = timestamp
+			if report.Start.IsZero() || timestamp.Before(report.Start) {
+				report.Start = timestamp
+			}
+			if timestamp.After(report.End) {
+				report.End = timestamp
+			}
+		}
+		times = append(times, last)
+		batch = append(batch, event)
+		indexes = append(indexes, index)
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	engine.FlushActions()
+
+	report.Rules = listRules(engine, hits)
+	report.Elapsed = time.Since(started)
+	return report, nil
+}
+
+// listRules lists the hits of every rule of the engine, rules without
+// hits included, from the most to the least hits
+func listRules(engine *dag.DagEngine, hits map[ir.RuleID]*RuleHits) []RuleHits {
+	var rules []RuleHits
+	for _, ruleId := range engine.RuleIDs() {
+		rule := RuleHits{RuleID: ruleId}
+		if recorded := hits[ruleId]; recorded != nil {
+			rule = *recorded
+		}
+		if meta, ok := engine.RuleMeta(uint32(ruleId)); ok {
+			rule.UUID = meta.SigmaID
+			rule.Title = meta.Title
+			rule.Level = meta.Level
+		}
+		rules = append(rules, rule)
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Hits != rules[j].Hits {
+			return rules[i].Hits > rules[j].Hits
+		}
+		return rules[i].RuleID < rules[j].RuleID
+	})
+	return rules
+}
+
+// eventTime reads an event's timestamp
+func eventTime(event map[string]interface{}, field string) (time.Time, bool) {
+	if field == "" {
+		return time.Time{}, false
+	}
+	value, err := matcher.DefaultFieldExtractor(event, field)
+	if err != nil || value == nil {
+		return time.Time{}, false
+	}
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case float64:
+		text = fmt.Sprintf("%f", v)
+	default:
+		text = fmt.Sprint(v)
+	}
+	timestamp, err := matcher.ParseTimestamp(text)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return timestamp.UTC(), true
+}
+
+// ndjsonReader reads newline-delimited JSON objects
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewNDJSONReader creates a reader of newline-delimited JSON events. Blank
+// lines are skipped; lines that are not JSON objects are malformed events.
+func NewNDJSONReader(r io.Reader) EventReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &ndjsonReader{scanner: scanner}
+}
+
+func (r *ndjsonReader) Next() (map[string]interface{}, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var event map[string]interface{}
+		if err := json.Unmarshal(line, &event); err != nil || event == nil {
+			return nil, fmt.Errorf("line %d: %w", r.line, ErrMalformedEvent)
+		}
+		return event, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,739 @@
+package backtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+const encodedPowerShellRule = `
+title: Encoded PowerShell
+id: 3b6ab547-8ec2-4991-b9d2-2b06702a48d7
+logsource:
+    category: process_creation
+    product: windows
+detection:
+    selection:
+        CommandLine|contains: ' -enc '
+    condition: selection
+level: high
+`
+
+const lsassAccessRule = `
+title: LSASS Access
+id: 0d894093-71bc-43c3-8c4d-ecfa28dcf5e6
+logsource:
+    category: process_access
+    product: windows
+detection:
+    selection:
+        TargetImage|endswith: '\lsass.exe'
+    condition: selection
+level: critical
+`
+
+const archive = `{"@timestamp": "2024-03-01T10:00:00Z", "CommandLine": "powershell -enc SQBFAFgA"}
+{"@timestamp": "2024-03-01T10:05:00Z", "CommandLine": "notepad.exe"}
+
+not json
+{"@timestamp": 1709287800, "CommandLine": "pwsh -enc ZQBjAGgAbwA="}
+{"@timestamp": "2024-03-01T11:00:00Z", "CommandLine": "cmd.exe /c -enc x"}
+`
+
+func TestBacktest(t *testing.T) {
+	report, err := Backtest([]string{encodedPowerShellRule, lsassAccessRule}, NewNDJSONReader(strings.NewReader(archive)), Options{
+		TimestampField: "@timestamp",
+		MaxExamples:    2,
+	})
+	if err != nil {
+		t.Fatalf("Backtest failed: %v", err)
+	}
+
+	if report.Events != 5 || report.MatchedEvents != 3 || report.MalformedEvents != 1 {
+		t.Errorf("Unexpected event counts: %+v", report)
+	}
+	if !report.Start.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) || !report.End.Equal(time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected archive span: %v - %v", report.Start, report.End)
+	}
+	if len(report.Rules) != 2 || report.Rules[0].Title != "Encoded PowerShell" {
+		t.Fatalf("Expected the most matching rule first, got %+v", report.Rules)
+	}
+
+	encoded, _ := report.Rule("3b6ab547-8ec2-4991-b9d2-2b06702a48d7")
+	if encoded.Hits != 3 || encoded.FirstIndex != 0 || encoded.LastIndex != 4 {
+		t.Errorf("Unexpected hits: %+v", encoded)
+	}
+	// Epoch timestamps are read too
+	if !encoded.FirstSeen.Equal(report.Start) || !encoded.LastSeen.Equal(report.End) {
+		t.Errorf("Unexpected first and last seen: %v - %v", encoded.FirstSeen, encoded.LastSeen)
+	}
+	if len(encoded.Examples) != 2 || encoded.Examples[1].Index != 3 ||
+		!encoded.Examples[1].Time.Equal(time.Date(2024, 3, 1, 10, 10, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected examples: %+v", encoded.Examples)
+	}
+
+	// Rules that never fire are reported with no hits
+	lsass, ok := report.Rule("0d894093-71bc-43c3-8c4d-ecfa28dcf5e6")
+	if !ok || lsass.Hits != 0 || !lsass.FirstSeen.IsZero() {
+		t.Errorf("Expected the LSASS rule without hits, got %+v", lsass)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	report, err := Backtest([]string{encodedPowerShellRule, lsassAccessRule}, NewNDJSONReader(strings.NewReader(archive)), Options{})
+	if err != nil {
+		t.Fatalf("Backtest failed: %v", err)
+	}
+
+	// 3 of the 4 decoded events match the encoded PowerShell rule
+	coverage := report.Coverage(0)
+	if coverage.Events != 4 || coverage.NoisyRatio != 0.1 || len(coverage.Rules) != 2 {
+		t.Fatalf("Unexpected coverage: %+v", coverage)
+	}
+	if noisy := coverage.Noisy(); len(noisy) != 1 || noisy[0].Title != "Encoded PowerShell" || noisy[0].Selectivity != 0.75 {
+		t.Errorf("Unexpected noisy rules: %+v", noisy)
+	}
+	if never := coverage.NeverFired(); len(never) != 1 || never[0].UUID != "0d894093-71bc-43c3-8c4d-ecfa28dcf5e6" {
+		t.Errorf("Unexpected rules that never fired: %+v", never)
+	}
+	if rule := report.Coverage(0.9).Rules[0]; rule.Status != CoverageOK || rule.Level != "high" {
+		t.Errorf("Expected the rule to be ok under a 90%% ratio, got %+v", rule)
+	}
+
+	var csvOut bytes.Buffer
+	if err := coverage.WriteCSV(&csvOut); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	expected := "rule_id,uuid,title,level,hits,selectivity,status\n" +
+		"0,3b6ab547-8ec2-4991-b9d2-2b06702a48d7,Encoded PowerShell,high,3,0.75,noisy\n" +
+		"1,0d894093-71bc-43c3-8c4d-ecfa28dcf5e6,LSASS Access,critical,0,0,never_fired\n"
+	if csvOut.String() != expected {
+		t.Errorf("Unexpected CSV:\n%s", csvOut.String())
+	}
+
+	var jsonOut bytes.Buffer
+	if err := coverage.WriteJSON(&jsonOut); err != nil {
+		t.Fatalf("Failed to write JSON: %v", err)
+	}
+	var decoded CoverageReport
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil || !reflect.DeepEqual(&decoded, coverage) {
+		t.Errorf("Expected the JSON to round-trip, got %+v, %v", decoded, err)
+	}
+}
+
+func TestCoverageRecorder(t *testing.T) {
+	engine, _, err := newEngine([]string{encodedPowerShellRule, lsassAccessRule}, Options{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	recorder := NewCoverageRecorder(engine)
+	for _, command := range []string{"powershell -enc SQBFAFgA", "notepad.exe", "cmd.exe"} {
+		result, err := engine.Evaluate(map[string]interface{}{"CommandLine": command})
+		if err != nil {
+			t.Fatalf("Evaluation failed: %v", err)
+		}
+		recorder.Record(result)
+	}
+	recorder.Record(nil)
+
+	report := recorder.Report()
+	if report.Events != 3 || report.MatchedEvents != 1 || len(report.Rules) != 2 || report.Rules[0].Hits != 1 {
+		t.Fatalf("Unexpected recorded report: %+v", report)
+	}
+	if never := report.Coverage(0.5).NeverFired(); len(never) != 1 || never[0].Title != "LSASS Access" {
+		t.Errorf("Unexpected rules that never fired: %+v", never)
+	}
+
+	recorder.Reset()
+	if report := recorder.Report(); report.Events != 0 || report.Rules[0].Hits != 0 {
+		t.Errorf("Expected an empty period after reset, got %+v", report)
+	}
+}
+
+const mimikatzRule = `
+title: Mimikatz Process
+id: 06d71506-7beb-4f22-8888-e2e5e2ca7fd8
+logsource:
+    category: process_creation
+    product: windows
+detection:
+    selection:
+        process.name|endswith: 'mimikatz.exe'
+    condition: selection
+level: critical
+`
+
+func TestRunRuleTests(t *testing.T) {
+	report, err := RunRuleTests(encodedPowerShellRule+`x-tests:
+    - name: encoded command
+      match: true
+      event:
+          CommandLine: powershell -enc SQBFAFgA
+    - name: plain command
+      match: false
+      event:
+          CommandLine: powershell -File build.ps1
+    - name: missed
+      match: true
+      event:
+          CommandLine: powershell -EncodedCommand SQBFAFgA
+`, Options{})
+	if err != nil {
+		t.Fatalf("Rule tests failed to run: %v", err)
+	}
+
+	if report.Title != "Encoded PowerShell" || len(report.Cases) != 3 || report.Passed() {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+	failures := report.Failures()
+	if len(failures) != 1 || failures[0].Index != 2 || !failures[0].Expected || failures[0].Matched {
+		t.Errorf("Expected the third case to fail, got %+v", failures)
+	}
+	if failures[0].String() != "case 2 (missed): expected a match, got none" {
+		t.Errorf("Unexpected failure message: %s", failures[0])
+	}
+
+	// Rules without test cases pass, rules that do not compile fail
+	if report, err := RunRuleTests(lsassAccessRule, Options{}); err != nil || !report.Passed() || len(report.Cases) != 0 {
+		t.Errorf("Expected an empty passing report, got %+v, %v", report, err)
+	}
+	if _, err := RunRuleTests("title: Broken\ndetection:\n    condition: selection\n", Options{}); err == nil {
+		t.Error("Expected an error for a rule that does not compile")
+	}
+}
+
+// thriftField is a field of a Thrift struct to encode
+type thriftField struct {
+	id    int16
+	kind  byte
+	value interface{}
+}
+
+// thriftListValue is a Thrift list to encode
+type thriftListValue struct {
+	kind  byte
+	items []interface{}
+}
+
+// encodeThrift encodes a Thrift struct in the compact protocol
+func encodeThrift(buffer *bytes.Buffer, fields []thriftField) {
+	for _, field := range fields {
+		buffer.WriteByte(field.kind)
+		buffer.Write(binary.AppendUvarint(nil, uint64(field.id)<<1))
+		if field.kind != thriftTrue && field.kind != thriftFalse {
+			encodeThriftValue(buffer, field.kind, field.value)
+		}
+	}
+	buffer.WriteByte(thriftStop)
+}
+
+func encodeThriftValue(buffer *bytes.Buffer, kind byte, value interface{}) {
+	switch kind {
+	case thriftI32, thriftI64:
+		v := value.(int64)
+		buffer.Write(binary.AppendUvarint(nil, uint64(v<<1^v>>63)))
+	case thriftBinary:
+		v := value.(string)
+		buffer.Write(binary.AppendUvarint(nil, uint64(len(v))))
+		buffer.WriteString(v)
+	case thriftList:
+		list := value.(thriftListValue)
+		buffer.WriteByte(0xF0 | list.kind)
+		buffer.Write(binary.AppendUvarint(nil, uint64(len(list.items))))
+		for _, item := range list.items {
+			encodeThriftValue(buffer, list.kind, item)
+		}
+	case thriftStruct:
+		encodeThrift(buffer, value.([]thriftField))
+	}
+}
+
+func thriftBytes(fields []thriftField) []byte {
+	var buffer bytes.Buffer
+	encodeThrift(&buffer, fields)
+	return buffer.Bytes()
+}
+
+// testColumn describes a leaf column of a test Parquet file
+type testColumn struct {
+	name          string
+	physicalType  int64
+	repetition    int64
+	convertedType int64
+	codec         int64
+	dictionary    bool
+	v2            bool
+
+	// Values of each row group, nil for nulls
+	values [][]interface{}
+
+	// Column chunk metadata overriding the written one
+	chunkMeta []thriftField
+}
+
+// bitPack encodes values as one bit-packed run of the RLE hybrid encoding
+func bitPack(values []int, bitWidth int) []byte {
+	groups := (len(values) + 7) / 8
+	packed := make([]byte, groups*bitWidth)
+	for i, value := range values {
+		for bit := 0; bit < bitWidth; bit++ {
+			if value>>bit&1 == 1 {
+				position := i*bitWidth + bit
+				packed[position/8] |= 1 << (position % 8)
+			}
+		}
+	}
+	return append(binary.AppendUvarint(nil, uint64(groups<<1|1)), packed...)
+}
+
+// plainValues PLAIN-encodes values of a physical type
+func plainValues(physicalType int64, values []interface{}) []byte {
+	var buffer bytes.Buffer
+	for _, value := range values {
+		switch physicalType {
+		case parquetInt32:
+			buffer.Write(binary.LittleEndian.AppendUint32(nil, uint32(value.(int64))))
+		case parquetInt64:
+			buffer.Write(binary.LittleEndian.AppendUint64(nil, uint64(value.(int64))))
+		case parquetByteArray:
+			buffer.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(value.(string)))))
+			buffer.WriteString(value.(string))
+		}
+	}
+	return buffer.Bytes()
+}
+
+// compressTest compresses page data; Snappy blocks use literals only
+func compressTest(t testing.TB, codec int64, data []byte) []byte {
+	switch codec {
+	case parquetSnappy:
+		block := binary.AppendUvarint(nil, uint64(len(data)))
+		for len(data) > 0 {
+			n := min(len(data), 256)
+			block = append(block, 60<<2, byte(n-1))
+			block = append(block, data[:n]...)
+			data = data[n:]
+		}
+		return block
+	case parquetGzip:
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		writer.Write(data)
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buffer.Bytes()
+	}
+	return data
+}
+
+// writeTestParquet writes a Parquet file of a flat root holding the
+// columns, where names with a dot are columns of an optional group
+func writeTestParquet(t testing.TB, columns []testColumn) []byte {
+	var file bytes.Buffer
+	file.Write(parquetMagic)
+
+	var rowGroups []interface{}
+	var totalRows int64
+	for group := range columns[0].values {
+		var chunks []interface{}
+		for _, column := range columns {
+			values := column.values[group]
+			maxDefinition := 0
+			if column.repetition != parquetRequired {
+				maxDefinition++
+			}
+			if strings.Contains(column.name, ".") {
+				maxDefinition++
+			}
+
+			var present []interface{}
+			definitions := make([]int, len(values))
+			for i, value := range values {
+				if value != nil {
+					present = append(present, value)
+					definitions[i] = maxDefinition
+				}
+			}
+			var levels []byte
+			if maxDefinition > 0 {
+				levels = bitPack(definitions, 2)
+			}
+
+			offset := int64(file.Len())
+			dictionaryOffset := int64(0)
+			encoding := int64(parquetPlain)
+			data := plainValues(column.physicalType, present)
+			if column.dictionary {
+				var dictionary []interface{}
+				indices := make([]int, len(present))
+				for i, value := range present {
+					indices[i] = -1
+					for j, entry := range dictionary {
+						if entry == value {
+							indices[i] = j
+						}
+					}
+					if indices[i] < 0 {
+						indices[i] = len(dictionary)
+						dictionary = append(dictionary, value)
+					}
+				}
+				page := plainValues(column.physicalType, dictionary)
+				compressed := compressTest(t, column.codec, page)
+				file.Write(thriftBytes([]thriftField{
+					{1, thriftI32, int64(parquetDictionaryPage)},
+					{2, thriftI32, int64(len(page))},
+					{3, thriftI32, int64(len(compressed))},
+					{7, thriftStruct, []thriftField{
+						{1, thriftI32, int64(len(dictionary))},
+						{2, thriftI32, int64(parquetPlain)},
+					}},
+				}))
+				file.Write(compressed)
+				dictionaryOffset = offset
+				encoding = parquetRLEDictionary
+				data = append([]byte{4}, bitPack(indices, 4)...)
+			}
+
+			dataOffset := int64(file.Len())
+			if column.v2 {
+				compressed := compressTest(t, column.codec, data)
+				file.Write(thriftBytes([]thriftField{
+					{1, thriftI32, int64(parquetDataPageV2)},
+					{2, thriftI32, int64(len(levels) + len(data))},
+					{3, thriftI32, int64(len(levels) + len(compressed))},
+					{8, thriftStruct, []thriftField{
+						{1, thriftI32, int64(len(values))},
+						{2, thriftI32, int64(len(values) - len(present))},
+						{3, thriftI32, int64(len(values))},
+						{4, thriftI32, encoding},
+						{5, thriftI32, int64(len(levels))},
+						{6, thriftI32, int64(0)},
+					}},
+				}))
+				file.Write(levels)
+				file.Write(compressed)
+			} else {
+				var page []byte
+				if levels != nil {
+					page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+					page = append(page, levels...)
+				}
+				page = append(page, data...)
+				compressed := compressTest(t, column.codec, page)
+				file.Write(thriftBytes([]thriftField{
+					{1, thriftI32, int64(parquetDataPage)},
+					{2, thriftI32, int64(len(page))},
+					{3, thriftI32, int64(len(compressed))},
+					{5, thriftStruct, []thriftField{
+						{1, thriftI32, int64(len(values))},
+						{2, thriftI32, encoding},
+						{3, thriftI32, int64(3)},
+						{4, thriftI32, int64(3)},
+					}},
+				}))
+				file.Write(compressed)
+			}
+
+			meta := []thriftField{
+				{1, thriftI32, column.physicalType},
+				{3, thriftList, thriftListValue{thriftBinary, []interface{}{column.name}}},
+				{4, thriftI32, column.codec},
+				{5, thriftI64, int64(len(values))},
+				{7, thriftI64, int64(file.Len()) - offset},
+				{9, thriftI64, dataOffset},
+			}
+			if column.dictionary {
+				meta = append(meta, thriftField{11, thriftI64, dictionaryOffset})
+			}
+			meta = append(meta, column.chunkMeta...)
+			chunks = append(chunks, []thriftField{
+				{2, thriftI64, offset},
+				{3, thriftStruct, meta},
+			})
+		}
+		rows := int64(len(columns[0].values[group]))
+		totalRows += rows
+		rowGroups = append(rowGroups, []thriftField{
+			{1, thriftList, thriftListValue{thriftStruct, chunks}},
+			{2, thriftI64, int64(0)},
+			{3, thriftI64, rows},
+		})
+	}
+
+	// Columns with a dot follow their group, which precedes them
+	schema := []interface{}{nil}
+	children := 0
+	groups := make(map[string]*[]thriftField)
+	for _, column := range columns {
+		name := column.name
+		if parent, child, nested := strings.Cut(column.name, "."); nested {
+			if fields, ok := groups[parent]; ok {
+				(*fields)[2].value = (*fields)[2].value.(int64) + 1
+			} else {
+				group := []thriftField{
+					{3, thriftI32, int64(parquetOptional)},
+					{4, thriftBinary, parent},
+					{5, thriftI32, int64(1)},
+				}
+				groups[parent] = &group
+				schema = append(schema, &group)
+				children++
+			}
+			name = child
+		} else {
+			children++
+		}
+		element := []thriftField{
+			{1, thriftI32, column.physicalType},
+			{3, thriftI32, column.repetition},
+			{4, thriftBinary, name},
+		}
+		if column.convertedType != 0 {
+			element = append(element, thriftField{6, thriftI32, column.convertedType})
+		}
+		schema = append(schema, element)
+	}
+	schema[0] = []thriftField{
+		{4, thriftBinary, "schema"},
+		{5, thriftI32, int64(children)},
+	}
+	for i, element := range schema {
+		if group, ok := element.(*[]thriftField); ok {
+			schema[i] = *group
+		}
+	}
+
+	metadata := thriftBytes([]thriftField{
+		{1, thriftI32, int64(1)},
+		{2, thriftList, thriftListValue{thriftStruct, schema}},
+		{3, thriftI64, totalRows},
+		{4, thriftList, thriftListValue{thriftStruct, rowGroups}},
+	})
+	file.Write(metadata)
+	file.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(metadata))))
+	file.Write(parquetMagic)
+	return file.Bytes()
+}
+
+// testParquetColumns are the columns of the test Parquet file, in two row
+// groups of two and three rows
+var testParquetColumns = []testColumn{
+	{name: "EventID", physicalType: parquetInt64, values: [][]interface{}{
+		{int64(1), int64(1)}, {int64(1), int64(4688), int64(1)},
+	}},
+	{name: "CommandLine", physicalType: parquetByteArray, repetition: parquetOptional, codec: parquetSnappy, values: [][]interface{}{
+		{"powershell -enc SQBFAFgA", nil}, {"notepad.exe", nil, "pwsh -enc ZQBjAGgAbwA="},
+	}},
+	{name: "process.name", physicalType: parquetByteArray, repetition: parquetOptional, dictionary: true, codec: parquetGzip, values: [][]interface{}{
+		{"powershell.exe", "mimikatz.exe"}, {"notepad.exe", nil, "mimikatz.exe"},
+	}},
+	{name: "process.pid", physicalType: parquetInt32, repetition: parquetOptional, v2: true, codec: parquetSnappy, values: [][]interface{}{
+		{int64(10), int64(-20)}, {int64(30), nil, int64(50)},
+	}},
+	{name: "ts", physicalType: parquetInt64, convertedType: parquetTimestampMillis, v2: true, values: [][]interface{}{
+		{int64(1709287200000), int64(1709287500000)}, {int64(1709287800000), int64(1709288100000), int64(1709290800000)},
+	}},
+	{name: "tags", physicalType: parquetByteArray, repetition: parquetRepeated, values: [][]interface{}{
+		{nil, nil}, {nil, nil, nil},
+	}},
+}
+
+func TestParquetReader(t *testing.T) {
+	data := writeTestParquet(t, testParquetColumns)
+	file, err := NewParquetFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewParquetFile failed: %v", err)
+	}
+	if file.NumRows() != 5 {
+		t.Errorf("Expected 5 rows, got %d", file.NumRows())
+	}
+	expectedColumns := []string{"EventID", "CommandLine", "process.name", "process.pid", "ts", "tags"}
+	if columns := file.Columns(); !reflect.DeepEqual(columns, expectedColumns) {
+		t.Errorf("Expected columns %v, got %v", expectedColumns, columns)
+	}
+
+	// Every column but the repeated one, with nulls left out
+	reader, err := file.Reader(nil)
+	if err != nil {
+		t.Fatalf("Reader failed: %v", err)
+	}
+	var events []map[string]interface{}
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			break
+		}
+		events = append(events, event)
+	}
+	if len(events) != 5 {
+		t.Fatalf("Expected 5 events, got %d", len(events))
+	}
+	first := map[string]interface{}{
+		"EventID":     int64(1),
+		"CommandLine": "powershell -enc SQBFAFgA",
+		"process":     map[string]interface{}{"name": "powershell.exe", "pid": int64(10)},
+		"ts":          "2024-03-01T10:00:00Z",
+	}
+	if !reflect.DeepEqual(events[0], first) {
+		t.Errorf("Unexpected first event: %v", events[0])
+	}
+	if _, ok := events[1]["CommandLine"]; ok || events[1]["process"].(map[string]interface{})["pid"] != int64(-20) {
+		t.Errorf("Unexpected second event: %v", events[1])
+	}
+	if _, ok := events[3]["process"]; ok || events[3]["EventID"] != int64(4688) {
+		t.Errorf("Unexpected fourth event: %v", events[3])
+	}
+
+	// Projection onto a group
+	reader, _ = file.Reader([]string{"process"})
+	event, err := reader.Next()
+	if err != nil || !reflect.DeepEqual(event, map[string]interface{}{"process": first["process"]}) {
+		t.Errorf("Unexpected projected event: %v, %v", event, err)
+	}
+
+	if _, err := file.Reader([]string{"tags"}); err == nil {
+		t.Error("Expected an error projecting a repeated column")
+	}
+	if _, err := NewParquetFile(bytes.NewReader(data[:len(data)-1]), int64(len(data)-1)); err == nil {
+		t.Error("Expected an error for a truncated file")
+	}
+}
+
+func TestParquetCorruptMetadata(t *testing.T) {
+	for name, meta := range map[string][]thriftField{
+		"negative value count": {{5, thriftI64, int64(-1)}},
+		"negative offset":      {{9, thriftI64, int64(-4)}},
+		"chunk past the file":  {{7, thriftI64, int64(1) << 40}},
+	} {
+		columns := []testColumn{{name: "EventID", physicalType: parquetInt64, chunkMeta: meta, values: [][]interface{}{{int64(1)}}}}
+		data := writeTestParquet(t, columns)
+		if _, err := NewParquetFile(bytes.NewReader(data), int64(len(data))); !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeConfig) {
+			t.Errorf("%s: expected a config error, got %v", name, err)
+		}
+	}
+
+	page := compressTest(t, parquetGzip, bytes.Repeat([]byte{'a'}, 4096))
+	if _, err := decompress(parquetGzip, page, 1<<40); err == nil {
+		t.Error("Expected an oversized page to be rejected")
+	}
+	if _, err := decompress(parquetGzip, page, 1024); err == nil {
+		t.Error("Expected gzip output beyond the page size to be rejected")
+	}
+	if data, err := decompress(parquetGzip, page, 4096); err != nil || len(data) != 4096 {
+		t.Errorf("Expected the page to decompress, got %d bytes, %v", len(data), err)
+	}
+}
+
+func TestParquetCorruptPages(t *testing.T) {
+	for name, v2 := range map[string]bool{"data page": false, "data page v2": true} {
+		columns := []testColumn{{name: "CommandLine", physicalType: parquetByteArray, repetition: parquetOptional, v2: v2, values: [][]interface{}{{"a", nil, "b"}}}}
+		data := writeTestParquet(t, columns)
+		// Overwrite the page's value count of 3, the first field of its
+		// data page header (field 5, or 8 for v2), with -8
+		headerID := byte(5)
+		if v2 {
+			headerID = 8
+		}
+		index := bytes.Index(data, []byte{thriftStruct, headerID << 1, thriftI32, 1 << 1, 3 << 1})
+		if index < 0 {
+			t.Fatalf("%s: value count not found", name)
+		}
+		data[index+4] = 15
+		file, err := NewParquetFile(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("%s: NewParquetFile failed: %v", name, err)
+		}
+		reader, _ := file.Reader(nil)
+		if _, err := reader.Next(); err == nil {
+			t.Errorf("%s: expected an error for a negative value count", name)
+		}
+	}
+
+	if _, err := decodeRLEHybrid([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 0}, 3, 8); err == nil {
+		t.Error("Expected an error for a bit-packed run past the data")
+	}
+	if _, err := decodeRLEHybrid(nil, 1, -1); err == nil {
+		t.Error("Expected an error for a negative level count")
+	}
+	if _, _, err := decodePlain(parquetColumn{physicalType: parquetInt32}, nil, -1); err == nil {
+		t.Error("Expected an error for a negative value count")
+	}
+}
+
+func FuzzReadParquet(f *testing.F) {
+	f.Add(writeTestParquet(f, testParquetColumns))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		file, err := NewParquetFile(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return
+		}
+		reader, err := file.Reader(nil)
+		if err != nil {
+			return
+		}
+		for {
+			if _, err := reader.Next(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func TestBacktestParquet(t *testing.T) {
+	data := writeTestParquet(t, testParquetColumns)
+	file, err := NewParquetFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewParquetFile failed: %v", err)
+	}
+
+	report, err := BacktestParquet([]string{encodedPowerShellRule, mimikatzRule}, file, Options{
+		TimestampField: "ts",
+		BatchSize:      2,
+	})
+	if err != nil {
+		t.Fatalf("BacktestParquet failed: %v", err)
+	}
+	if report.Events != 5 || report.MatchedEvents != 3 {
+		t.Errorf("Unexpected event counts: %+v", report)
+	}
+	if !report.Start.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) || !report.End.Equal(time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected archive span: %v - %v", report.Start, report.End)
+	}
+
+	mimikatz, _ := report.Rule("06d71506-7beb-4f22-8888-e2e5e2ca7fd8")
+	if mimikatz.Hits != 2 || mimikatz.FirstIndex != 1 || mimikatz.LastIndex != 4 ||
+		!mimikatz.LastSeen.Equal(report.End) {
+		t.Errorf("Unexpected hits: %+v", mimikatz)
+	}
+	// Only referenced columns are decoded; the event has no CommandLine
+	if example := mimikatz.Examples[0].Event; len(example) != 2 || example["EventID"] != nil {
+		t.Errorf("Expected a projected event, got %v", example)
+	}
+	encoded, _ := report.Rule("3b6ab547-8ec2-4991-b9d2-2b06702a48d7")
+	if encoded.Hits != 1 || encoded.FirstIndex != 0 {
+		t.Errorf("Unexpected hits: %+v", encoded)
+	}
+}
+
+func TestDecodeSnappy(t *testing.T) {
+	// Literal "abc", then an overlapping copy of 7 bytes at offset 3
+	block := []byte{10, 2 << 2, 'a', 'b', 'c', (7-4)<<2 | 1, 3}
+	decoded, err := decodeSnappy(block)
+	if err != nil || string(decoded) != "abcabcabca" {
+		t.Errorf("Unexpected decoding: %q, %v", decoded, err)
+	}
+	if _, err := decodeSnappy([]byte{10, 2 << 2, 'a', 'b', 'c'}); err == nil {
+		t.Error("Expected an error for a short block")
+	}
+}
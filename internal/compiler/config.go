@@ -0,0 +1,133 @@
+package compiler
+
+import (
+	"log/slog"
+	"runtime"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/logging"
+)
+
+// CompilerConfig controls compiler behavior.
+type CompilerConfig struct {
+	// Emit debug-level compilation logs (parse trees, generated nodes)
+	Debug bool
+
+	// Skip value modifiers unknown to the matcher registry instead of
+	// failing the rule (strict by default, since skipping changes semantics)
+	LenientModifiers bool
+
+	// Exclude rules that fail to compile instead of failing the whole
+	// ruleset; failures are recorded in CompilationResult.Errors
+	TolerateRuleErrors bool
+
+	// Reject rules that deviate from the SIGMA rule schema (unknown keys,
+	// wrongly shaped values) instead of compiling them with warnings
+	StrictSchema bool
+
+	// Compile rules with status deprecated or unsupported. They are
+	// skipped by default and recorded in CompilationResult.Skipped.
+	IncludeRetiredRules bool
+
+	// Limits on a single condition expression, so hostile rules cannot
+	// exhaust the stack: maximum parenthesis nesting and maximum token
+	// count (0 = DefaultMaxConditionDepth / DefaultMaxConditionTokens)
+	MaxConditionDepth  int
+	MaxConditionTokens int
+
+	// Keep a single copy of each field name, match type, value and modifier
+	// string across the compiled primitives, shrinking large rulesets that
+	// repeat them across many rules (on by default)
+	InternStrings bool
+
+	// What to do with rule fields the field mapping does not map, when it
+	// has mappings or transformations (allowed through unchanged by default)
+	UnmappedFieldPolicy UnmappedFieldPolicy
+
+	// Expand detection field names with * and ? wildcards, e.g. Hash*, to
+	// the matching fields of the field mapping, matching any of them
+	ExpandFieldPatterns bool
+
+	// Goroutines parsing and compiling rules in CompileRules,
+	// CompileRulesResult and CompileRulesWithFilter (0 = GOMAXPROCS). The
+	// compiled ruleset does not depend on the number of goroutines.
+	NumThreads int
+
+	// Time source of the compilation statistics (nil = system clock)
+	Clock clock.Clock `json:"-"`
+
+	// Logger receives structured compiler logs (nil = discard)
+	Logger *slog.Logger `json:"-"`
+}
+
+// UnmappedFieldPolicy is what the compiler does with a rule field that the
+// field mapping neither maps nor rewrites, see FieldMapping.IsMapped
+type UnmappedFieldPolicy int
+
+const (
+	// UnmappedFieldAllow matches the field under its name in the rule
+	UnmappedFieldAllow UnmappedFieldPolicy = iota
+	// UnmappedFieldWarn compiles the rule with a warning per unmapped field
+	UnmappedFieldWarn
+	// UnmappedFieldFail fails the rule on its first unmapped field
+	UnmappedFieldFail
+)
+
+func (p UnmappedFieldPolicy) String() string {
+	switch p {
+	case UnmappedFieldAllow:
+		return "allow"
+	case UnmappedFieldWarn:
+		return "warn"
+	case UnmappedFieldFail:
+		return "fail"
+	default:
+		return "unknown"
+	}
+}
+
+// Default condition limits, far above what real SIGMA rules use
+const (
+	DefaultMaxConditionDepth  = 64
+	DefaultMaxConditionTokens = 4096
+)
+
+// DefaultCompilerConfig returns the default compiler configuration.
+func DefaultCompilerConfig() CompilerConfig {
+	return CompilerConfig{
+		Debug:         false,
+		InternStrings: true,
+	}
+}
+
+// logger returns the compiler-scoped logger for this configuration.
+//
+// Debug records are only forwarded when Debug is enabled, so hosts can keep a
+// verbose handler without flooding it during normal compilation.
+func (c CompilerConfig) logger() *slog.Logger {
+	logger := logging.For(c.Logger, logging.ComponentCompiler)
+	if !c.Debug {
+		return logging.WithMinLevel(logger, slog.LevelInfo)
+	}
+	return logger
+}
+
+// workerCount returns the number of goroutines compiling rules
+func (c CompilerConfig) workerCount() int {
+	if c.NumThreads > 0 {
+		return c.NumThreads
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// conditionLimits returns the effective condition depth and token limits.
+func (c CompilerConfig) conditionLimits() (maxDepth, maxTokens int) {
+	maxDepth, maxTokens = c.MaxConditionDepth, c.MaxConditionTokens
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxConditionDepth
+	}
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxConditionTokens
+	}
+	return maxDepth, maxTokens
+}
@@ -1,11 +1,13 @@
 package compiler
 
 import (
-	"fmt"
+	"log/slog"
+	"sort"
 	"strings"
 
 	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
 	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
 )
 
 // DagCodegenContext represents the context for DAG generation from AST
@@ -94,11 +96,11 @@ func (ctx *DagCodegenContext) generateDagRecursive(
 		// Look up the selection in the selection map
 		primitiveIDs, exists := selectionMap[node.Name]
 		if !exists {
-			return 0, fmt.Errorf("unknown selection: %s", node.Name)
+			return 0, errors.Errorf(errors.ErrorTypeCompilation, "unknown selection: %s", node.Name)
 		}
 
 		if len(primitiveIDs) == 0 {
-			return 0, fmt.Errorf("empty selection: %s", node.Name)
+			return 0, errors.Errorf(errors.ErrorTypeCompilation, "empty selection: %s", node.Name)
 		}
 
 		if len(primitiveIDs) == 1 {
@@ -157,8 +159,8 @@ func (ctx *DagCodegenContext) generateDagRecursive(
 		orNode := ctx.createLogicalNode(dag.LogicalOr)
 		hasPrimitives := false
 
-		for _, primitiveIDs := range selectionMap {
-			for _, primitiveID := range primitiveIDs {
+		for _, name := range sortedSelectionNames(selectionMap) {
+			for _, primitiveID := range selectionMap[name] {
 				primitiveNode := ctx.getOrCreatePrimitiveNode(primitiveID)
 				ctx.addDependency(orNode, primitiveNode)
 				hasPrimitives = true
@@ -166,7 +168,7 @@ func (ctx *DagCodegenContext) generateDagRecursive(
 		}
 
 		if !hasPrimitives {
-			return 0, fmt.Errorf("no primitives found for 'one of them'")
+			return 0, errors.Errorf(errors.ErrorTypeCompilation, "no primitives found for 'one of them'")
 		}
 
 		return orNode, nil
@@ -176,8 +178,8 @@ func (ctx *DagCodegenContext) generateDagRecursive(
 		andNode := ctx.createLogicalNode(dag.LogicalAnd)
 		hasPrimitives := false
 
-		for _, primitiveIDs := range selectionMap {
-			for _, primitiveID := range primitiveIDs {
+		for _, name := range sortedSelectionNames(selectionMap) {
+			for _, primitiveID := range selectionMap[name] {
 				primitiveNode := ctx.getOrCreatePrimitiveNode(primitiveID)
 				ctx.addDependency(andNode, primitiveNode)
 				hasPrimitives = true
@@ -185,7 +187,7 @@ func (ctx *DagCodegenContext) generateDagRecursive(
 		}
 
 		if !hasPrimitives {
-			return 0, fmt.Errorf("no primitives found for 'all of them'")
+			return 0, errors.Errorf(errors.ErrorTypeCompilation, "no primitives found for 'all of them'")
 		}
 
 		return andNode, nil
@@ -195,9 +197,9 @@ func (ctx *DagCodegenContext) generateDagRecursive(
 		orNode := ctx.createLogicalNode(dag.LogicalOr)
 		hasMatches := false
 
-		for selectionName, primitiveIDs := range selectionMap {
+		for _, selectionName := range sortedSelectionNames(selectionMap) {
 			if strings.Contains(selectionName, node.Pattern) {
-				for _, primitiveID := range primitiveIDs {
+				for _, primitiveID := range selectionMap[selectionName] {
 					primitiveNode := ctx.getOrCreatePrimitiveNode(primitiveID)
 					ctx.addDependency(orNode, primitiveNode)
 					hasMatches = true
@@ -206,7 +208,7 @@ func (ctx *DagCodegenContext) generateDagRecursive(
 		}
 
 		if !hasMatches {
-			return 0, fmt.Errorf("no selections found matching pattern: %s", node.Pattern)
+			return 0, errors.Errorf(errors.ErrorTypeCompilation, "no selections found matching pattern: %s", node.Pattern)
 		}
 
 		return orNode, nil
@@ -216,9 +218,9 @@ func (ctx *DagCodegenContext) generateDagRecursive(
 		andNode := ctx.createLogicalNode(dag.LogicalAnd)
 		hasMatches := false
 
-		for selectionName, primitiveIDs := range selectionMap {
+		for _, selectionName := range sortedSelectionNames(selectionMap) {
 			if strings.Contains(selectionName, node.Pattern) {
-				for _, primitiveID := range primitiveIDs {
+				for _, primitiveID := range selectionMap[selectionName] {
 					primitiveNode := ctx.getOrCreatePrimitiveNode(primitiveID)
 					ctx.addDependency(andNode, primitiveNode)
 					hasMatches = true
@@ -227,7 +229,7 @@ func (ctx *DagCodegenContext) generateDagRecursive(
 		}
 
 		if !hasMatches {
-			return 0, fmt.Errorf("no selections found matching pattern: %s", node.Pattern)
+			return 0, errors.Errorf(errors.ErrorTypeCompilation, "no selections found matching pattern: %s", node.Pattern)
 		}
 
 		return andNode, nil
@@ -238,9 +240,9 @@ func (ctx *DagCodegenContext) generateDagRecursive(
 		orNode := ctx.createLogicalNode(dag.LogicalOr)
 		hasMatches := false
 
-		for selectionName, primitiveIDs := range selectionMap {
+		for _, selectionName := range sortedSelectionNames(selectionMap) {
 			if strings.Contains(selectionName, node.Pattern) {
-				for _, primitiveID := range primitiveIDs {
+				for _, primitiveID := range selectionMap[selectionName] {
 					primitiveNode := ctx.getOrCreatePrimitiveNode(primitiveID)
 					ctx.addDependency(orNode, primitiveNode)
 					hasMatches = true
@@ -249,14 +251,25 @@ func (ctx *DagCodegenContext) generateDagRecursive(
 		}
 
 		if !hasMatches {
-			return 0, fmt.Errorf("no selections found matching pattern: %s", node.Pattern)
+			return 0, errors.Errorf(errors.ErrorTypeCompilation, "no selections found matching pattern: %s", node.Pattern)
 		}
 
 		return orNode, nil
 
 	default:
-		return 0, fmt.Errorf("unknown AST node type: %T", node)
+		return 0, errors.Errorf(errors.ErrorTypeCompilation, "unknown AST node type: %T", node)
+	}
+}
+
+// sortedSelectionNames returns the selection names in sorted order, so the
+// generated nodes do not depend on map iteration order
+func sortedSelectionNames(selectionMap map[string][]ir.PrimitiveID) []string {
+	names := make([]string, 0, len(selectionMap))
+	for name := range selectionMap {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
 }
 
 // finalize finalizes DAG generation by creating result node
@@ -283,6 +296,8 @@ type DagGenerationResult struct {
 	ResultNodeID dag.NodeId
 	// Rule ID
 	RuleID ir.RuleID
+	// Selections referenced by the condition (selection name -> primitive IDs)
+	Selections map[string][]ir.PrimitiveID
 }
 
 // GenerateDagFromAst generates DAG nodes from a SIGMA condition AST
@@ -291,10 +306,40 @@ func GenerateDagFromAst(
 	selectionMap map[string][]ir.PrimitiveID,
 	ruleID ir.RuleID,
 ) (*DagGenerationResult, error) {
+	return GenerateDagFromAstWithConfig(ast, selectionMap, ruleID, DefaultCompilerConfig())
+}
+
+// GenerateDagFromAstWithConfig generates DAG nodes from a SIGMA condition AST,
+// logging through the compiler configuration
+func GenerateDagFromAstWithConfig(
+	ast ConditionAst,
+	selectionMap map[string][]ir.PrimitiveID,
+	ruleID ir.RuleID,
+	config CompilerConfig,
+) (*DagGenerationResult, error) {
+	logger := config.logger()
+
 	ctx := NewDagCodegenContext(ruleID)
 	conditionRoot, err := ctx.generateDagRecursive(ast, selectionMap)
 	if err != nil {
+		logger.Warn("DAG generation failed",
+			slog.Uint64("rule_id", uint64(ruleID)),
+			slog.String("condition", ast.String()),
+			slog.Any("error", err))
 		return nil, err
 	}
-	return ctx.finalize(conditionRoot), nil
+
+	result := ctx.finalize(conditionRoot)
+	result.Selections = make(map[string][]ir.PrimitiveID, len(selectionMap))
+	for name, primitiveIDs := range selectionMap {
+		result.Selections[name] = append([]ir.PrimitiveID(nil), primitiveIDs...)
+	}
+
+	logger.Debug("generated rule DAG",
+		slog.Uint64("rule_id", uint64(ruleID)),
+		slog.String("condition", ast.String()),
+		slog.Int("nodes", len(result.Nodes)),
+		slog.Int("primitives", len(result.PrimitiveNodes)))
+
+	return result, nil
 }
@@ -1,7 +1,14 @@
 package compiler
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
 	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
 )
 
 // TestDagCodegenContextCreation matches Rust test_dag_codegen_context_creation
@@ -93,3 +100,71 @@ func TestGenerateDagUnknownSelectionError(t *testing.T) {
 		t.Errorf("Expected 'unknown selection' error, got: %v", err)
 	}
 }
+
+// TestGenerateDagDebugLogging checks that codegen logs only when Debug is enabled
+func TestGenerateDagDebugLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ast := &Identifier{Name: "selection1"}
+
+	config := DefaultCompilerConfig()
+	config.Logger = logger
+	if _, err := GenerateDagFromAstWithConfig(ast, createTestSelectionMap(), 1, config); err != nil {
+		t.Fatalf("Failed to generate DAG: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected no debug output with Debug disabled, got: %s", buf.String())
+	}
+
+	config.Debug = true
+	if _, err := GenerateDagFromAstWithConfig(ast, createTestSelectionMap(), 1, config); err != nil {
+		t.Fatalf("Failed to generate DAG: %v", err)
+	}
+	if !strings.Contains(buf.String(), "generated rule DAG") || !strings.Contains(buf.String(), "component=compiler") {
+		t.Errorf("Expected compiler debug output, got: %s", buf.String())
+	}
+}
+
+// TestGenerateDagRecordsSelections checks that selection metadata is carried on the result
+func TestGenerateDagRecordsSelections(t *testing.T) {
+	selectionMap := createTestSelectionMap()
+	result, err := GenerateDagFromAst(&Identifier{Name: "selection1"}, selectionMap, 1)
+	if err != nil {
+		t.Fatalf("Failed to generate DAG: %v", err)
+	}
+	if len(result.Selections) != len(selectionMap) {
+		t.Errorf("Expected %d selections, got %d", len(selectionMap), len(result.Selections))
+	}
+	if ids := result.Selections["selection1"]; len(ids) != len(selectionMap["selection1"]) {
+		t.Errorf("Expected selection1 primitives %v, got %v", selectionMap["selection1"], ids)
+	}
+}
+
+// TestGenerateDagDeterministic checks that "them" and pattern conditions
+// generate the same nodes regardless of selection map iteration order
+func TestGenerateDagDeterministic(t *testing.T) {
+	selectionMap := map[string][]ir.PrimitiveID{}
+	for i := 0; i < 16; i++ {
+		selectionMap[fmt.Sprintf("selection%d", i)] = []ir.PrimitiveID{ir.PrimitiveID(i)}
+	}
+
+	for _, ast := range []ConditionAst{&OneOfThem{}, &AllOfThem{}, &OneOfPattern{Pattern: "selection1"}} {
+		var first string
+		for run := 0; run < 10; run++ {
+			result, err := GenerateDagFromAst(ast, selectionMap, 1)
+			if err != nil {
+				t.Fatalf("Failed to generate DAG for %s: %v", ast, err)
+			}
+			nodes, err := json.Marshal(result.Nodes)
+			if err != nil {
+				t.Fatalf("Failed to serialize nodes: %v", err)
+			}
+			encoded := string(nodes)
+			if run == 0 {
+				first = encoded
+			} else if encoded != first {
+				t.Fatalf("Nodes for %s differ between runs:\n%s\n%s", ast, first, encoded)
+			}
+		}
+	}
+}
@@ -0,0 +1,664 @@
+package compiler
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// maxCountCombinations bounds the expansion of "N of pattern" conditions
+const maxCountCombinations = 256
+
+// maxFieldPatternAlternatives bounds the alternatives a field map with
+// field name patterns expands into
+const maxFieldPatternAlternatives = 256
+
+// matchTypeModifiers maps SIGMA field modifiers to matcher match types.
+var matchTypeModifiers = map[string]string{
+	"contains":   "contains",
+	"startswith": "startswith",
+	"endswith":   "endswith",
+	"re":         "regex",
+	"cidr":       "cidr",
+}
+
+// caseInsensitiveMatchTypes are the match types SIGMA compares ignoring
+// case, unless the "cased" modifier is set. Regular expressions are case
+// sensitive; base64_scan compares its decoded content.
+var caseInsensitiveMatchTypes = map[string]bool{
+	"equals":      true,
+	"contains":    true,
+	"startswith":  true,
+	"endswith":    true,
+	"wildcard":    true,
+	"base64_scan": true,
+}
+
+// escapedMatchTypes are the match types whose values are unescaped (see
+// unescapeValue). Wildcard values keep their escapes for the wildcard
+// matcher.
+var escapedMatchTypes = map[string]bool{
+	"equals":     true,
+	"contains":   true,
+	"startswith": true,
+	"endswith":   true,
+}
+
+// compiledSelection is a detection selection lowered to primitives.
+//
+// Each alternative is a list of primitives combined with AND; alternatives
+// (from a list of maps in the rule) are combined with OR.
+type compiledSelection struct {
+	name         string
+	alternatives [][]ir.PrimitiveID
+}
+
+// key returns the codegen selection name for an alternative
+func (s *compiledSelection) key(index int) string {
+	if len(s.alternatives) == 1 {
+		return s.name
+	}
+	return fmt.Sprintf("%s[%d]", s.name, index)
+}
+
+// compileSelections lowers every detection selection of a rule into
+// primitives registered in the shared primitive table. Selections are
+// returned sorted by name. With expandPatterns, field name patterns expand
+// to the matching fields of the field mapping.
+func compileSelections(
+	detection map[string]interface{},
+	fieldMapping *FieldMapping,
+	expandPatterns bool,
+	registry *matcher.MatcherRegistry,
+	primitives *ir.CompiledRuleset,
+) ([]*compiledSelection, error) {
+	names := make([]string, 0, len(detection))
+	for name := range detection {
+		if name == "condition" || name == "timeframe" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	selections := make([]*compiledSelection, 0, len(names))
+	for _, name := range names {
+		selection, err := compileSelection(name, detection[name], fieldMapping, expandPatterns, registry, primitives)
+		if err != nil {
+			return nil, fmt.Errorf("selection %s: %w", name, withSourcePath(err, name))
+		}
+		selections = append(selections, selection)
+	}
+
+	return selections, nil
+}
+
+// compileSelection lowers a single detection selection
+func compileSelection(
+	name string,
+	definition interface{},
+	fieldMapping *FieldMapping,
+	expandPatterns bool,
+	registry *matcher.MatcherRegistry,
+	primitives *ir.CompiledRuleset,
+) (*compiledSelection, error) {
+	selection := &compiledSelection{name: name}
+
+	switch def := definition.(type) {
+	case map[string]interface{}:
+		alternatives, err := compileFieldMap(def, fieldMapping, expandPatterns, registry, primitives)
+		if err != nil {
+			return nil, err
+		}
+		selection.alternatives = append(selection.alternatives, alternatives...)
+
+	case []interface{}:
+		for i, item := range def {
+			fieldMap, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, withSourcePath(errors.Errorf(errors.ErrorTypeCompilation, "keyword selections are not supported"), strconv.Itoa(i))
+			}
+			alternatives, err := compileFieldMap(fieldMap, fieldMapping, expandPatterns, registry, primitives)
+			if err != nil {
+				return nil, withSourcePath(err, strconv.Itoa(i))
+			}
+			selection.alternatives = append(selection.alternatives, alternatives...)
+		}
+
+	default:
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "unsupported selection type %T", definition)
+	}
+
+	if len(selection.alternatives) == 0 {
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "empty selection")
+	}
+	return selection, nil
+}
+
+// compileFieldMap lowers a field map (implicit AND of its entries) into
+// alternatives combined with OR. A field map has a single alternative,
+// unless a field name pattern expands to several fields: the entry then
+// matches any of them, giving one alternative per field.
+func compileFieldMap(
+	fieldMap map[string]interface{},
+	fieldMapping *FieldMapping,
+	expandPatterns bool,
+	registry *matcher.MatcherRegistry,
+	primitives *ir.CompiledRuleset,
+) ([][]ir.PrimitiveID, error) {
+	keys := make([]string, 0, len(fieldMap))
+	for key := range fieldMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	alternatives := [][]ir.PrimitiveID{nil}
+	for _, key := range keys {
+		field, modifiers, _ := strings.Cut(key, "|")
+		fields := []string{field}
+		if expandPatterns && isFieldPattern(field) {
+			if expanded := fieldMapping.ExpandFieldPattern(field); len(expanded) > 0 {
+				fields = expanded
+			}
+		}
+		if len(fields)*len(alternatives) > maxFieldPatternAlternatives {
+			return nil, withSourcePath(errors.Errorf(errors.ErrorTypeCompilation,
+				"field pattern %s expands to more than %d alternatives", field, maxFieldPatternAlternatives), key)
+		}
+
+		expanded := make([][]ir.PrimitiveID, 0, len(fields)*len(alternatives))
+		for _, name := range fields {
+			entryKey := name
+			if modifiers != "" {
+				entryKey += "|" + modifiers
+			}
+			fieldPrimitives, err := buildFieldPrimitives(entryKey, fieldMap[key], fieldMapping, registry)
+			if err != nil {
+				return nil, withSourcePath(errors.WithField(err, errors.ErrorTypeCompilation, field), key)
+			}
+			ids := make([]ir.PrimitiveID, 0, len(fieldPrimitives))
+			for _, primitive := range fieldPrimitives {
+				ids = append(ids, primitives.AddPrimitive(primitive))
+			}
+			for _, alternative := range alternatives {
+				expanded = append(expanded, append(slices.Clip(alternative), ids...))
+			}
+		}
+		alternatives = expanded
+	}
+
+	if len(alternatives[0]) == 0 {
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "empty field map")
+	}
+	return alternatives, nil
+}
+
+// isFieldPattern reports whether a rule field name is a pattern of field
+// names, with * and ? wildcards
+func isFieldPattern(field string) bool {
+	return strings.ContainsAny(field, "*?")
+}
+
+// buildFieldPrimitives builds the primitives for a single "Field|modifiers: values"
+// entry. Values are combined with OR, or with AND when the "all" modifier is set.
+// Strings are compared ignoring case unless the "cased" modifier is set.
+// Modifiers naming a custom matcher in the registry (nil = none) select it
+// as the match type.
+func buildFieldPrimitives(key string, value interface{}, fieldMapping *FieldMapping, registry *matcher.MatcherRegistry) ([]ir.Primitive, error) {
+	parts := strings.Split(key, "|")
+	field := fieldMapping.NormalizeField(parts[0])
+	if field == "" {
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "empty field name in %q", key)
+	}
+
+	matchType := ""
+	matchAll := false
+	cased := false
+	var modifiers []string
+	for _, modifier := range parts[1:] {
+		mapped, isMatchType := matchTypeModifiers[modifier]
+		if !isMatchType && isCustomMatchType(registry, modifier) {
+			mapped, isMatchType = modifier, true
+		}
+		if isMatchType {
+			if matchType != "" {
+				return nil, errors.Errorf(errors.ErrorTypeCompilation, "conflicting match modifiers in %q", key)
+			}
+			matchType = mapped
+			continue
+		}
+		if modifier == "all" {
+			matchAll = true
+			continue
+		}
+		if modifier == "cased" {
+			cased = true
+			continue
+		}
+		modifiers = append(modifiers, modifier)
+	}
+
+	values, kinds, err := selectionValues(value)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", parts[0], err)
+	}
+	if len(values) == 0 {
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "field %s has no values", parts[0])
+	}
+
+	if matchType == "" {
+		matchType = "equals"
+		if hasWildcard(values) {
+			matchType = "wildcard"
+		}
+	}
+	if escapedMatchTypes[matchType] {
+		for i, v := range values {
+			if kinds[i] == ir.ValueKindString {
+				values[i] = unescapeValue(v)
+			}
+		}
+	}
+
+	var result []ir.Primitive
+	if !matchAll {
+		result = []ir.Primitive{*ir.NewTypedPrimitive(field, matchType, values, kinds, modifiers)}
+	} else {
+		result = make([]ir.Primitive, 0, len(values))
+		for i, v := range values {
+			result = append(result, *ir.NewTypedPrimitive(field, matchType, []string{v}, kinds[i:i+1], modifiers))
+		}
+	}
+	ignoreCase := !cased && caseInsensitiveMatchTypes[matchType]
+	aliases := fieldMapping.FieldAliases(field)
+	for i := range result {
+		result[i].IgnoreCase = ignoreCase
+		if len(aliases) > 0 {
+			result[i].FieldAliases = aliases
+		}
+	}
+	return result, nil
+}
+
+// isCustomMatchType reports whether a modifier names a matcher in the
+// registry. Registered value modifiers take precedence.
+func isCustomMatchType(registry *matcher.MatcherRegistry, modifier string) bool {
+	if registry == nil {
+		return false
+	}
+	if _, isModifier := registry.GetModifier(modifier); isModifier {
+		return false
+	}
+	_, isMatcher := registry.GetMatcher(modifier)
+	return isMatcher
+}
+
+// checkDetectionModifiers returns an error for the first value modifier in
+// the detection section that is not known to the matcher registry or has
+// invalid arguments. Match type modifiers, "all" and "cased" are handled by the
+// compiler itself.
+func checkDetectionModifiers(detection map[string]interface{}, known func(string) (bool, error)) error {
+	if errs := detectionModifierErrors(detection, known); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// detectionModifierErrors returns an error for every unknown or invalid
+// value modifier in the detection section, in selection and key order
+func detectionModifierErrors(detection map[string]interface{}, known func(string) (bool, error)) []error {
+	var errs []error
+	walkDetectionKeys(detection, func(path []string, key string) {
+		parts := strings.Split(key, "|")
+		for _, modifier := range parts[1:] {
+			if _, isMatchType := matchTypeModifiers[modifier]; isMatchType || modifier == "all" || modifier == "cased" {
+				continue
+			}
+			exists, err := known(modifier)
+			if err != nil {
+				errs = append(errs, withSourcePath(matcher.NewInvalidModifierError(modifier, parts[0], err), path...))
+			} else if !exists && !matcher.IsModifierParameter(modifier) {
+				errs = append(errs, withSourcePath(matcher.NewUnknownModifierError(modifier, parts[0]), path...))
+			}
+		}
+	})
+	return errs
+}
+
+// unmappedFieldErrors returns an error for every detection field the field
+// mapping does not map, in selection and key order. With expandPatterns,
+// field name patterns are left to fieldPatternWarnings.
+func unmappedFieldErrors(detection map[string]interface{}, fieldMapping *FieldMapping, expandPatterns bool) []error {
+	var errs []error
+	walkDetectionKeys(detection, func(path []string, key string) {
+		field := strings.Split(key, "|")[0]
+		if (expandPatterns && isFieldPattern(field)) || fieldMapping.IsMapped(field) {
+			return
+		}
+		errs = append(errs, withSourcePath(errors.Errorf(errors.ErrorTypeCompilation, "field %s has no field mapping", field), path...))
+	})
+	return errs
+}
+
+// fieldPatternWarnings returns a warning for every detection field name
+// pattern that matches no field of the field mapping, in selection and key
+// order. Such entries match the pattern as a literal field name.
+func fieldPatternWarnings(detection map[string]interface{}, fieldMapping *FieldMapping) []string {
+	var warnings []string
+	walkDetectionKeys(detection, func(_ []string, key string) {
+		field := strings.Split(key, "|")[0]
+		if isFieldPattern(field) && len(fieldMapping.ExpandFieldPattern(field)) == 0 {
+			warnings = append(warnings, fmt.Sprintf("field pattern %s matches no field of the %s taxonomy", field, fieldMapping.Taxonomy()))
+		}
+	})
+	return warnings
+}
+
+// detectionFields returns the field names of the detection section's field
+// maps, before field mapping
+func detectionFields(detection map[string]interface{}) []string {
+	var fields []string
+	walkDetectionKeys(detection, func(_ []string, key string) {
+		fields = append(fields, strings.Split(key, "|")[0])
+	})
+	return fields
+}
+
+// walkDetectionKeys calls fn with every key of the detection section's
+// field maps and its detection path, in selection and key order
+func walkDetectionKeys(detection map[string]interface{}, fn func(path []string, key string)) {
+	names := make([]string, 0, len(detection))
+	for name := range detection {
+		if name != "condition" && name != "timeframe" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		// Field maps with their detection path, for locating errors
+		var fieldMaps []map[string]interface{}
+		var paths [][]string
+		switch def := detection[name].(type) {
+		case map[string]interface{}:
+			fieldMaps = append(fieldMaps, def)
+			paths = append(paths, []string{name})
+		case []interface{}:
+			for i, item := range def {
+				if fieldMap, ok := item.(map[string]interface{}); ok {
+					fieldMaps = append(fieldMaps, fieldMap)
+					paths = append(paths, []string{name, strconv.Itoa(i)})
+				}
+			}
+		}
+
+		for m, fieldMap := range fieldMaps {
+			keys := make([]string, 0, len(fieldMap))
+			for key := range fieldMap {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				fn(append(append([]string(nil), paths[m]...), key), key)
+			}
+		}
+	}
+}
+
+// selectionValues converts a YAML scalar or list into primitive values and
+// the kind of each value, which tells the matcher how to compare it
+func selectionValues(value interface{}) ([]string, []ir.ValueKind, error) {
+	list, isList := value.([]interface{})
+	if !isList {
+		list = []interface{}{value}
+	}
+
+	values := make([]string, 0, len(list))
+	kinds := make([]ir.ValueKind, 0, len(list))
+	for _, item := range list {
+		switch v := item.(type) {
+		case nil:
+			values = append(values, "")
+			kinds = append(kinds, ir.ValueKindNull)
+		case string:
+			values = append(values, v)
+			kinds = append(kinds, ir.ValueKindString)
+		case bool:
+			values = append(values, strconv.FormatBool(v))
+			kinds = append(kinds, ir.ValueKindBool)
+		case int, int64, uint64:
+			values = append(values, fmt.Sprintf("%v", v))
+			kinds = append(kinds, ir.ValueKindInt)
+		case float64:
+			values = append(values, strconv.FormatFloat(v, 'g', -1, 64))
+			kinds = append(kinds, ir.ValueKindFloat)
+		case map[string]interface{}, []interface{}:
+			return nil, nil, errors.Errorf(errors.ErrorTypeCompilation, "nested values are not supported")
+		default:
+			values = append(values, fmt.Sprintf("%v", v))
+			kinds = append(kinds, ir.ValueKindString)
+		}
+	}
+	return values, kinds, nil
+}
+
+// hasWildcard reports whether any value uses SIGMA wildcards: * or ? not
+// escaped with a backslash
+func hasWildcard(values []string) bool {
+	for _, v := range values {
+		for i := 0; i < len(v); i++ {
+			switch {
+			case isEscape(v, i):
+				i++
+			case v[i] == '*' || v[i] == '?':
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isEscape reports whether the backslash at value[i] starts a SIGMA escape
+// sequence: \*, \? or \\. Other backslashes are literal.
+func isEscape(value string, i int) bool {
+	return value[i] == '\\' && i+1 < len(value) && strings.IndexByte(`*?\`, value[i+1]) >= 0
+}
+
+// unescapeValue resolves the SIGMA escape sequences of a value without
+// wildcards, e.g. C:\\x\\report\*.txt to C:\x\report*.txt
+func unescapeValue(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var unescaped strings.Builder
+	unescaped.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if isEscape(value, i) {
+			i++
+		}
+		unescaped.WriteByte(value[i])
+	}
+	return unescaped.String()
+}
+
+// escapeValue escapes a literal value for SIGMA, the inverse of
+// unescapeValue
+func escapeValue(value string) string {
+	if !strings.ContainsAny(value, `*?\`) {
+		return value
+	}
+	var escaped strings.Builder
+	escaped.Grow(len(value) + 2)
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == '*' || c == '?' || isEscape(value, i) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteByte(c)
+	}
+	return escaped.String()
+}
+
+// expandCondition rewrites a parsed condition so that it only references
+// concrete selection alternatives, resolving "them" and wildcard patterns
+// against the rule's selections.
+func expandCondition(ast ConditionAst, selections []*compiledSelection) (ConditionAst, error) {
+	switch node := ast.(type) {
+	case *Identifier:
+		for _, selection := range selections {
+			if selection.name == node.Name {
+				return selectionAst(selection), nil
+			}
+		}
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "unknown selection: %s", node.Name)
+
+	case *And:
+		left, err := expandCondition(node.Left, selections)
+		if err != nil {
+			return nil, err
+		}
+		right, err := expandCondition(node.Right, selections)
+		if err != nil {
+			return nil, err
+		}
+		return &And{Left: left, Right: right}, nil
+
+	case *Or:
+		left, err := expandCondition(node.Left, selections)
+		if err != nil {
+			return nil, err
+		}
+		right, err := expandCondition(node.Right, selections)
+		if err != nil {
+			return nil, err
+		}
+		return &Or{Left: left, Right: right}, nil
+
+	case *Not:
+		operand, err := expandCondition(node.Operand, selections)
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Operand: operand}, nil
+
+	case *OneOfThem:
+		return countOf(1, selections, "them")
+
+	case *AllOfThem:
+		return countOf(uint32(len(selections)), selections, "them")
+
+	case *OneOfPattern:
+		return countOf(1, matchingSelections(node.Pattern, selections), node.Pattern)
+
+	case *AllOfPattern:
+		matched := matchingSelections(node.Pattern, selections)
+		return countOf(uint32(len(matched)), matched, node.Pattern)
+
+	case *CountOfPattern:
+		return countOf(node.Count, matchingSelections(node.Pattern, selections), node.Pattern)
+
+	default:
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "unknown AST node type: %T", node)
+	}
+}
+
+// selectionAst returns the OR of a selection's alternatives
+func selectionAst(selection *compiledSelection) ConditionAst {
+	var result ConditionAst
+	for i := range selection.alternatives {
+		ident := &Identifier{Name: selection.key(i)}
+		if result == nil {
+			result = ident
+		} else {
+			result = &Or{Left: result, Right: ident}
+		}
+	}
+	return result
+}
+
+// matchingSelections returns the selections whose name matches a wildcard pattern
+func matchingSelections(pattern string, selections []*compiledSelection) []*compiledSelection {
+	var matched []*compiledSelection
+	for _, selection := range selections {
+		if ok, err := path.Match(pattern, selection.name); err == nil && ok {
+			matched = append(matched, selection)
+		}
+	}
+	return matched
+}
+
+// countOf builds "at least count of selections" as an OR of ANDs over every
+// combination of count selections
+func countOf(count uint32, selections []*compiledSelection, pattern string) (ConditionAst, error) {
+	n := len(selections)
+	if n == 0 {
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "no selections found matching pattern: %s", pattern)
+	}
+	if count == 0 || int(count) > n {
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "cannot match %d of %d selections for pattern: %s", count, n, pattern)
+	}
+
+	k := int(count)
+	if binomial(n, k) > maxCountCombinations {
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "'%d of %s' expands to too many combinations", count, pattern)
+	}
+
+	var result ConditionAst
+	indices := make([]int, k)
+	for i := range indices {
+		indices[i] = i
+	}
+	for {
+		var term ConditionAst
+		for _, idx := range indices {
+			sel := selectionAst(selections[idx])
+			if term == nil {
+				term = sel
+			} else {
+				term = &And{Left: term, Right: sel}
+			}
+		}
+		if result == nil {
+			result = term
+		} else {
+			result = &Or{Left: result, Right: term}
+		}
+
+		// Advance to the next combination in lexicographic order
+		i := k - 1
+		for i >= 0 && indices[i] == n-k+i {
+			i--
+		}
+		if i < 0 {
+			break
+		}
+		indices[i]++
+		for j := i + 1; j < k; j++ {
+			indices[j] = indices[j-1] + 1
+		}
+	}
+	return result, nil
+}
+
+// binomial returns n choose k, saturating above maxCountCombinations
+func binomial(n, k int) int {
+	if k > n-k {
+		k = n - k
+	}
+	result := 1
+	for i := 1; i <= k; i++ {
+		result = result * (n - k + i) / i
+		if result > maxCountCombinations {
+			return maxCountCombinations + 1
+		}
+	}
+	return result
+}
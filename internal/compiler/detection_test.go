@@ -0,0 +1,65 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+func TestBuildFieldPrimitivesModifiers(t *testing.T) {
+	fieldMapping := NewFieldMapping()
+	fieldMapping.AddMapping("Image", "process.executable")
+
+	primitives, err := buildFieldPrimitives("Image|endswith", []interface{}{`\cmd.exe`, `\powershell.exe`}, fieldMapping, nil)
+	if err != nil {
+		t.Fatalf("Failed to build primitives: %v", err)
+	}
+	if len(primitives) != 1 {
+		t.Fatalf("Expected 1 primitive, got %d", len(primitives))
+	}
+	if primitives[0].Field != "process.executable" || primitives[0].MatchType != "endswith" || len(primitives[0].Values) != 2 {
+		t.Errorf("Unexpected primitive: %s", primitives[0].String())
+	}
+
+	primitives, err = buildFieldPrimitives("CommandLine|contains|all", []interface{}{"-enc", "-nop"}, fieldMapping, nil)
+	if err != nil {
+		t.Fatalf("Failed to build primitives: %v", err)
+	}
+	if len(primitives) != 2 {
+		t.Errorf("Expected one primitive per value with |all, got %d", len(primitives))
+	}
+
+	primitives, err = buildFieldPrimitives("Image", `C:\\*\cmd.exe`, fieldMapping, nil)
+	if err != nil {
+		t.Fatalf("Failed to build primitives: %v", err)
+	}
+	if primitives[0].MatchType != "wildcard" {
+		t.Errorf("Expected wildcard match type, got %s", primitives[0].MatchType)
+	}
+
+	if _, err := buildFieldPrimitives("Image|contains|startswith", "x", fieldMapping, nil); err == nil {
+		t.Error("Expected error for conflicting match modifiers")
+	}
+}
+
+func TestExpandConditionCountOf(t *testing.T) {
+	selections := []*compiledSelection{
+		{name: "sel_a", alternatives: [][]ir.PrimitiveID{{0}}},
+		{name: "sel_b", alternatives: [][]ir.PrimitiveID{{1}}},
+		{name: "sel_c", alternatives: [][]ir.PrimitiveID{{2}, {3}}},
+		{name: "filter", alternatives: [][]ir.PrimitiveID{{4}}},
+	}
+
+	ast, err := expandCondition(&CountOfPattern{Count: 2, Pattern: "sel_*"}, selections)
+	if err != nil {
+		t.Fatalf("Failed to expand condition: %v", err)
+	}
+	expected := "(((sel_a and sel_b) or (sel_a and (sel_c[0] or sel_c[1]))) or (sel_b and (sel_c[0] or sel_c[1])))"
+	if ast.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, ast.String())
+	}
+
+	if _, err := expandCondition(&AllOfPattern{Pattern: "missing_*"}, selections); err == nil {
+		t.Error("Expected error for pattern without matching selections")
+	}
+}
@@ -0,0 +1,208 @@
+package compiler
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"io"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// DiagnosticSeverity is the severity of a compilation diagnostic
+type DiagnosticSeverity string
+
+const (
+	SeverityError   DiagnosticSeverity = "error"
+	SeverityWarning DiagnosticSeverity = "warning"
+)
+
+// warningCode is the code of compilation warnings
+const warningCode = "WARNING"
+
+// Diagnostic is a compilation error or warning in a machine-readable form,
+// e.g. for CI pipelines annotating rule changes.
+type Diagnostic struct {
+	Severity DiagnosticSeverity `json:"severity"`
+
+	// Error type of errors (see errors.ErrorType), "WARNING" for warnings
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// SIGMA rule UUID and title (empty when the rule could not be parsed)
+	RuleUUID  string `json:"rule_uuid,omitempty"`
+	RuleTitle string `json:"rule_title,omitempty"`
+
+	// Position of a failed rule in the compiled input; -1 for warnings
+	Index int `json:"index"`
+
+	// Position in the rule source (1-based), when known
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+
+	// Location of the rule file. The compiler only sees rule text, so
+	// callers compiling files fill it in; SARIF results without a URI
+	// have no location.
+	URI string `json:"uri,omitempty"`
+}
+
+// Diagnostics returns the failed rules as errors followed by the warnings
+// of every compiled rule, in rule ID order.
+func (r *CompilationResult) Diagnostics() []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, failure := range r.Errors {
+		diagnostics = append(diagnostics, newErrorDiagnostic(failure))
+	}
+	for _, info := range r.PerRule {
+		for _, warning := range info.Warnings {
+			diagnostics = append(diagnostics, Diagnostic{
+				Severity:  SeverityWarning,
+				Code:      warningCode,
+				Message:   warning,
+				RuleUUID:  info.UUID,
+				RuleTitle: info.Title,
+				Index:     -1,
+			})
+		}
+	}
+	return diagnostics
+}
+
+// newErrorDiagnostic describes a failed rule, with the error's type as
+// code and its source position when located
+func newErrorDiagnostic(failure RuleCompileError) Diagnostic {
+	diagnostic := Diagnostic{
+		Severity:  SeverityError,
+		Code:      errors.ErrorTypeCompilation.String(),
+		Message:   failure.Err.Error(),
+		RuleUUID:  failure.UUID,
+		RuleTitle: failure.Title,
+		Index:     failure.Index,
+	}
+	var sigmaErr *errors.SigmaError
+	if stderrors.As(failure.Err, &sigmaErr) {
+		diagnostic.Code = sigmaErr.Type.String()
+	}
+	var sourceErr *SourceError
+	if stderrors.As(failure.Err, &sourceErr) {
+		diagnostic.Line = sourceErr.Line
+		diagnostic.Column = sourceErr.Column
+		diagnostic.Snippet = sourceErr.Snippet
+	}
+	return diagnostic
+}
+
+// WriteDiagnosticsJSON writes diagnostics as an indented JSON array
+func WriteDiagnosticsJSON(w io.Writer, diagnostics []Diagnostic) error {
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(diagnostics)
+}
+
+// SARIF 2.1.0 log, limited to what diagnostics use
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+const (
+	sarifSchema   = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion  = "2.1.0"
+	sarifToolName = "sigma-engine"
+)
+
+// WriteDiagnosticsSARIF writes diagnostics as a SARIF 2.1.0 log with a
+// single run. Diagnostic codes become the run's rules; rule UUIDs and
+// titles are result properties.
+func WriteDiagnosticsSARIF(w io.Writer, diagnostics []Diagnostic) error {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: sarifToolName}},
+		Results: make([]sarifResult, 0, len(diagnostics)),
+	}
+	seen := make(map[string]bool)
+	for _, diagnostic := range diagnostics {
+		if !seen[diagnostic.Code] {
+			seen[diagnostic.Code] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: diagnostic.Code})
+		}
+
+		result := sarifResult{
+			RuleID:  diagnostic.Code,
+			Level:   string(diagnostic.Severity),
+			Message: sarifMessage{Text: diagnostic.Message},
+		}
+		if diagnostic.URI != "" {
+			location := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: diagnostic.URI}}
+			if diagnostic.Line > 0 {
+				location.Region = &sarifRegion{StartLine: diagnostic.Line, StartColumn: diagnostic.Column}
+			}
+			result.Locations = []sarifLocation{{PhysicalLocation: location}}
+		}
+		if diagnostic.RuleUUID != "" || diagnostic.RuleTitle != "" {
+			result.Properties = map[string]string{}
+			if diagnostic.RuleUUID != "" {
+				result.Properties["ruleUuid"] = diagnostic.RuleUUID
+			}
+			if diagnostic.RuleTitle != "" {
+				result.Properties["ruleTitle"] = diagnostic.RuleTitle
+			}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
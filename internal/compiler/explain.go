@@ -0,0 +1,158 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// matchTypeNames maps matcher match types back to SIGMA modifiers
+var matchTypeNames = map[string]string{
+	"regex": "re",
+}
+
+// ExplainRule renders what the compiler made of the rule with the given
+// SIGMA UUID as SIGMA-like YAML: selections list the compiled primitives
+// with mapped field names and their resolved match type and modifiers
+// (plain equality has none, wildcard values show the wildcard match type),
+// and the condition has every "of" expression expanded to the selections
+// it matched. Keys that field mapping maps onto the same field appear once
+// per source key.
+func (c *Compiler) ExplainRule(uuid string) (string, error) {
+	var rule *compiledRule
+	for _, candidate := range c.rules {
+		if candidate.rule.ID == uuid {
+			rule = candidate
+			break
+		}
+	}
+	if rule == nil {
+		return "", errors.Errorf(errors.ErrorTypeCompilation, "no compiled rule with id %q", uuid)
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	addEntry(root, "title", scalarNode(rule.rule.Title))
+	addEntry(root, "id", scalarNode(rule.rule.ID))
+	addEntry(root, "rule_id", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprintf("%d", rule.id)})
+
+	detection := &yaml.Node{Kind: yaml.MappingNode}
+	for _, selection := range rule.selections {
+		alternatives := make([]*yaml.Node, 0, len(selection.alternatives))
+		for _, alternative := range selection.alternatives {
+			alternatives = append(alternatives, c.explainPrimitives(alternative))
+		}
+		if len(alternatives) == 1 {
+			addEntry(detection, selection.name, alternatives[0])
+		} else {
+			addEntry(detection, selection.name, &yaml.Node{Kind: yaml.SequenceNode, Content: alternatives})
+		}
+	}
+	condition, err := expandCondition(rule.parsed, wholeSelections(rule.selections))
+	if err != nil {
+		return "", ruleError(rule.rule.Title, err)
+	}
+	addEntry(detection, "condition", scalarNode(condition.String()))
+	addEntry(root, "detection", detection)
+
+	if len(rule.fields) > 0 {
+		fields := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, field := range rule.fields {
+			fields.Content = append(fields.Content, scalarNode(field.EventField))
+		}
+		addEntry(root, "fields", fields)
+	}
+	addEntry(root, "level", scalarNode(rule.rule.Meta(rule.id).Level.String()))
+	normalizeStyle(root)
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(formatIndent)
+	if err := encoder.Encode(root); err != nil {
+		return "", errors.Errorf(errors.ErrorTypeYAML, "failed to explain rule: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", errors.Errorf(errors.ErrorTypeYAML, "failed to explain rule: %w", err)
+	}
+	return buffer.String(), nil
+}
+
+// explainPrimitives renders the primitives of a selection alternative as a
+// field map. Runs of single-value primitives on the same key come from the
+// "all" modifier and are folded back into it.
+func (c *Compiler) explainPrimitives(ids []ir.PrimitiveID) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i < len(ids); {
+		primitive := c.primitives.Primitives[ids[i]]
+		key := primitiveKey(primitive)
+
+		j := i + 1
+		for j < len(ids) && len(primitive.Values) == 1 && primitiveKey(c.primitives.Primitives[ids[j]]) == key &&
+			len(c.primitives.Primitives[ids[j]].Values) == 1 {
+			j++
+		}
+
+		values := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, id := range ids[i:j] {
+			valuePrimitive := c.primitives.Primitives[id]
+			for k, value := range valuePrimitive.Values {
+				if escapedMatchTypes[valuePrimitive.MatchType] && valuePrimitive.ValueKind(k) == ir.ValueKindString {
+					value = escapeValue(value)
+				}
+				values.Content = append(values.Content, scalarNode(value))
+			}
+		}
+		if j-i > 1 {
+			key += "|all"
+		}
+		if len(values.Content) == 1 {
+			addEntry(node, key, values.Content[0])
+		} else {
+			addEntry(node, key, values)
+		}
+		i = j
+	}
+	return node
+}
+
+// wholeSelections returns stand-ins for selections with a single
+// alternative each, so conditions expand to selection names rather than
+// to their alternatives
+func wholeSelections(selections []*compiledSelection) []*compiledSelection {
+	whole := make([]*compiledSelection, 0, len(selections))
+	for _, selection := range selections {
+		whole = append(whole, &compiledSelection{name: selection.name, alternatives: [][]ir.PrimitiveID{nil}})
+	}
+	return whole
+}
+
+// primitiveKey renders a primitive's field, match type and modifiers as a
+// SIGMA detection key, with "cased" for case-sensitive string comparisons
+func primitiveKey(primitive ir.Primitive) string {
+	parts := []string{primitive.Field}
+	if primitive.MatchType != "equals" {
+		name, renamed := matchTypeNames[primitive.MatchType]
+		if !renamed {
+			name = primitive.MatchType
+		}
+		parts = append(parts, name)
+	}
+	parts = append(parts, primitive.Modifiers...)
+	if caseInsensitiveMatchTypes[primitive.MatchType] && !primitive.IgnoreCase {
+		parts = append(parts, "cased")
+	}
+	return strings.Join(parts, "|")
+}
+
+// scalarNode returns a string node
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// addEntry appends a key/value pair to a mapping node
+func addEntry(mapping *yaml.Node, key string, value *yaml.Node) {
+	mapping.Content = append(mapping.Content, scalarNode(key), value)
+}
@@ -9,6 +9,18 @@
 // - dag_codegen - DAG generation from parsed ASTs
 package compiler
 
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+	"github.com/cespare/xxhash/v2"
+)
+
 // FieldMapping provides field name normalization and taxonomy support.
 // This supports the SIGMA taxonomy and custom field mappings.
 //
@@ -16,9 +28,66 @@ package compiler
 // - Rule-driven: The SIGMA rule itself defines what fields it uses
 // - Taxonomy-based: Field mappings come from the taxonomy system
 // - Configurable: Field mappings should be configurable per deployment
+//
+// Field names go through an explicit pipeline: normalize transformations
+// rewrite the name in order, the normalized name is looked up in the
+// mappings, and names without a mapping are rewritten by the first
+// fallback transformation matching them.
+//
+// Aliases are resolved at evaluation time instead: a primitive on a field
+// with aliases reads the first alias present in an event lacking the field.
 type FieldMapping struct {
-	fieldMap map[string]string
-	taxonomy string
+	fieldMap        map[string]string
+	taxonomy        string
+	transformations []FieldTransformation
+	aliases         map[string][]string
+}
+
+// FieldTransformStage is where a transformation runs in the field mapping
+// pipeline
+type FieldTransformStage int
+
+const (
+	// FieldTransformNormalize rewrites field names before mappings are
+	// looked up. Every matching normalize transformation applies, in order.
+	FieldTransformNormalize FieldTransformStage = iota
+	// FieldTransformFallback rewrites field names no mapping exists for.
+	// The first matching fallback transformation applies.
+	FieldTransformFallback
+)
+
+func (s FieldTransformStage) String() string {
+	switch s {
+	case FieldTransformNormalize:
+		return "normalize"
+	case FieldTransformFallback:
+		return "fallback"
+	default:
+		return fmt.Sprintf("FieldTransformStage(%d)", int(s))
+	}
+}
+
+// FieldTransformation rewrites field names matching a regular expression
+type FieldTransformation struct {
+	Stage FieldTransformStage
+
+	// Pattern as configured; it must match the whole field name
+	Pattern string
+
+	// Replacement of the field name, which may refer to the pattern's
+	// submatches as $1 or ${name}
+	Replacement string
+
+	regex *regexp.Regexp
+}
+
+// apply rewrites a field name the transformation matches
+func (t FieldTransformation) apply(fieldName string) (string, bool) {
+	match := t.regex.FindStringSubmatchIndex(fieldName)
+	if match == nil {
+		return fieldName, false
+	}
+	return string(t.regex.ExpandString(nil, t.Replacement, fieldName, match)), true
 }
 
 // NewFieldMapping creates a new empty field mapping using the default SIGMA taxonomy.
@@ -65,19 +134,121 @@ func (fm *FieldMapping) SetTaxonomy(taxonomy string) {
 	fm.taxonomy = taxonomy
 }
 
+// AddTransformation appends a transformation to a stage of the pipeline.
+// The pattern is a regular expression that must match the whole field
+// name.
+func (fm *FieldMapping) AddTransformation(stage FieldTransformStage, pattern, replacement string) error {
+	if stage != FieldTransformNormalize && stage != FieldTransformFallback {
+		return errors.NewConfigError(fmt.Sprintf("unknown field transformation stage %v", stage))
+	}
+	regex, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return errors.Wrap(errors.ErrorTypeConfig, fmt.Sprintf("invalid field transformation pattern %q: %v", pattern, err), err)
+	}
+	fm.transformations = append(fm.transformations, FieldTransformation{
+		Stage:       stage,
+		Pattern:     pattern,
+		Replacement: replacement,
+		regex:       regex,
+	})
+	return nil
+}
+
+// Transformations returns the transformations, in the order they were
+// added.
+func (fm *FieldMapping) Transformations() []FieldTransformation {
+	return append([]FieldTransformation(nil), fm.transformations...)
+}
+
 // NormalizeField normalizes a field name according to the mapping.
 //
-// Returns the normalized field name, or the original if no mapping exists.
+// Returns the mapped name of the normalized field name, or the fallback
+// rewrite of it if no mapping exists, or the normalized name itself.
 //
 // According to SIGMA spec, if no mapping exists, the field name should be used as-is
 // from the rule, following the principle that rules define their own field usage.
 func (fm *FieldMapping) NormalizeField(fieldName string) string {
+	for _, transformation := range fm.transformations {
+		if transformation.Stage == FieldTransformNormalize {
+			fieldName, _ = transformation.apply(fieldName)
+		}
+	}
 	if mapped, exists := fm.fieldMap[fieldName]; exists {
 		return mapped
 	}
+	for _, transformation := range fm.transformations {
+		if transformation.Stage != FieldTransformFallback {
+			continue
+		}
+		if rewritten, ok := transformation.apply(fieldName); ok {
+			return rewritten
+		}
+	}
 	return fieldName
 }
 
+// AddFieldAliases appends event field paths read, in order, from events
+// that lack the (mapped) field, e.g. winlog.event_data.CommandLine for
+// process.command_line.
+func (fm *FieldMapping) AddFieldAliases(field string, aliases ...string) {
+	if fm.aliases == nil {
+		fm.aliases = make(map[string][]string)
+	}
+	for _, alias := range aliases {
+		if alias != field && !slices.Contains(fm.aliases[field], alias) {
+			fm.aliases[field] = append(fm.aliases[field], alias)
+		}
+	}
+}
+
+// FieldAliases returns the aliases of a (mapped) field, in lookup order.
+func (fm *FieldMapping) FieldAliases(field string) []string {
+	return fm.aliases[field]
+}
+
+// ExpandFieldPattern returns the sorted rule field names with a mapping
+// that match a field name pattern with * and ? wildcards, e.g. Hash* for
+// Hashes and Hash. A malformed pattern matches nothing.
+func (fm *FieldMapping) ExpandFieldPattern(pattern string) []string {
+	var fields []string
+	for field := range fm.fieldMap {
+		if matched, err := path.Match(pattern, field); err == nil && matched {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// IsMapped reports whether the mapping pipeline maps a field name: a
+// mapping of its normalized name exists (an identity mapping allows the
+// name through unchanged) or a fallback transformation rewrites it.
+func (fm *FieldMapping) IsMapped(fieldName string) bool {
+	for _, transformation := range fm.transformations {
+		if transformation.Stage == FieldTransformNormalize {
+			fieldName, _ = transformation.apply(fieldName)
+		}
+	}
+	if _, exists := fm.fieldMap[fieldName]; exists {
+		return true
+	}
+	for _, transformation := range fm.transformations {
+		if transformation.Stage != FieldTransformFallback {
+			continue
+		}
+		if _, ok := transformation.apply(fieldName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEmpty reports whether the mapping has neither mappings nor
+// transformations, passing every field name through unchanged.
+func (fm *FieldMapping) IsEmpty() bool {
+	return len(fm.fieldMap) == 0 && len(fm.transformations) == 0
+}
+
 // HasMapping checks if a field mapping exists for the given field name.
 func (fm *FieldMapping) HasMapping(fieldName string) bool {
 	_, exists := fm.fieldMap[fieldName]
@@ -88,3 +259,24 @@ func (fm *FieldMapping) HasMapping(fieldName string) bool {
 func (fm *FieldMapping) Mappings() map[string]string {
 	return fm.fieldMap
 }
+
+// Fingerprint identifies the taxonomy, its field mappings, transformations
+// and aliases, so artifacts compiled with one field mapping can tell whether
+// another maps fields the same way.
+func (fm *FieldMapping) Fingerprint() string {
+	entries := make([]string, 0, len(fm.fieldMap))
+	for source, target := range fm.fieldMap {
+		entries = append(entries, source+"\x1f"+target)
+	}
+	sort.Strings(entries)
+	for _, transformation := range fm.transformations {
+		entries = append(entries, fmt.Sprintf("%s\x1f%s\x1f%s", transformation.Stage, transformation.Pattern, transformation.Replacement))
+	}
+	aliases := make([]string, 0, len(fm.aliases))
+	for field, fieldAliases := range fm.aliases {
+		aliases = append(aliases, "alias\x1f"+field+"\x1f"+strings.Join(fieldAliases, "\x1f"))
+	}
+	sort.Strings(aliases)
+	entries = append(entries, aliases...)
+	return fmt.Sprintf("%016x", xxhash.Sum64String(fm.taxonomy+"\x1e"+strings.Join(entries, "\x1e")))
+}
@@ -1,7 +1,10 @@
 package compiler
 
 import (
+	"reflect"
 	"testing"
+
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
 )
 
 // TestFieldMappingCreation matches Rust test_field_mapping_creation
@@ -84,3 +87,97 @@ func TestDefaultImplementation(t *testing.T) {
 		t.Errorf("Expected 0 mappings, got %d", len(mapping.Mappings()))
 	}
 }
+
+func TestFieldTransformationPipeline(t *testing.T) {
+	mapping := WithTaxonomy("ecs")
+	mapping.AddMapping("CommandLine", "process.command_line")
+	for _, transformation := range []struct {
+		stage                FieldTransformStage
+		pattern, replacement string
+	}{
+		{FieldTransformNormalize, `EventData\.(.+)`, "$1"},
+		{FieldTransformFallback, `Parent(.+)`, "process.parent.$1"},
+		{FieldTransformFallback, `.+`, "winlog.event_data.$0"},
+	} {
+		if err := mapping.AddTransformation(transformation.stage, transformation.pattern, transformation.replacement); err != nil {
+			t.Fatalf("Failed to add transformation: %v", err)
+		}
+	}
+
+	tests := map[string]string{
+		// Normalized before the lookup
+		"EventData.CommandLine": "process.command_line",
+		"CommandLine":           "process.command_line",
+		// First matching fallback only
+		"ParentImage": "process.parent.Image",
+		"Image":       "winlog.event_data.Image",
+		// Patterns match whole names, not substrings
+		"GrandParentImage": "winlog.event_data.GrandParentImage",
+	}
+	for field, expected := range tests {
+		if mapped := mapping.NormalizeField(field); mapped != expected {
+			t.Errorf("Expected %s to map to %s, got %s", field, expected, mapped)
+		}
+	}
+
+	if err := mapping.AddTransformation(FieldTransformFallback, "(", ""); err == nil {
+		t.Error("Expected an invalid pattern to be rejected")
+	}
+	if len(mapping.Transformations()) != 3 {
+		t.Errorf("Expected 3 transformations, got %d", len(mapping.Transformations()))
+	}
+}
+
+func TestFieldMappingValidateAgainst(t *testing.T) {
+	mapping := WithTaxonomy("ecs")
+	mapping.AddMapping("CommandLine", "process.command_line")
+	mapping.AddMapping("Image", "process.executable")
+	mapping.AddFieldAliases("process.executable", "winlog.event_data.Image")
+
+	ruleFields, err := NewCompiler().DetectionFields([]string{testProcessRule})
+	if err != nil {
+		t.Fatalf("Failed to collect rule fields: %v", err)
+	}
+	if !reflect.DeepEqual(ruleFields, []string{"CommandLine", "Image", "User"}) {
+		t.Fatalf("Unexpected rule fields %v", ruleFields)
+	}
+
+	sample := map[string]interface{}{
+		"process": map[string]interface{}{"command_line": "whoami"},
+		"winlog":  map[string]interface{}{"event_data": map[string]interface{}{"Image": "cmd.exe"}},
+	}
+	report := mapping.ValidateAgainst(sample, ruleFields)
+	expected := []FieldCheck{
+		{RuleField: "CommandLine", EventField: "process.command_line", Resolved: "process.command_line"},
+		{RuleField: "Image", EventField: "process.executable", Resolved: "winlog.event_data.Image"},
+		{RuleField: "User", EventField: "User"},
+	}
+	if !reflect.DeepEqual(report.Fields, expected) {
+		t.Errorf("Unexpected report %+v", report.Fields)
+	}
+	if unmapped := report.Unmapped(); len(unmapped) != 1 || unmapped[0].RuleField != "User" {
+		t.Errorf("Expected User to be reported unmapped, got %+v", unmapped)
+	}
+}
+
+func TestParseFieldSchema(t *testing.T) {
+	expected := []string{"process", "process.command_line", "user", "user.name"}
+	for name, document := range map[string]string{
+		"field list":  `["process.command_line", "user.name"]`,
+		"json schema": `{"type": "object", "properties": {"process": {"properties": {"command_line": {"type": "string"}}}, "user": {"properties": {"name": {}}}}}`,
+	} {
+		schema, err := ParseFieldSchema([]byte(document))
+		if err != nil {
+			t.Fatalf("%s: failed to parse schema: %v", name, err)
+		}
+		if fields := schema.Fields(); !reflect.DeepEqual(fields, expected) {
+			t.Errorf("%s: expected fields %v, got %v", name, expected, fields)
+		}
+	}
+
+	for _, document := range []string{`{"type": "object"}`, `"process"`, `{`} {
+		if _, err := ParseFieldSchema([]byte(document)); !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeConfig) {
+			t.Errorf("Expected a config error for %s, got %v", document, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package compiler
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// FieldSchema is the set of event field paths a log source produces, for
+// checking a field mapping against it before deployment. Paths are dotted,
+// e.g. "process.command_line"; the parents of nested paths are included.
+type FieldSchema struct {
+	fields map[string]bool
+}
+
+// NewFieldSchema creates a schema of the given field paths
+func NewFieldSchema(fields ...string) *FieldSchema {
+	schema := &FieldSchema{fields: make(map[string]bool, len(fields))}
+	for _, field := range fields {
+		schema.add(field)
+	}
+	return schema
+}
+
+// FieldSchemaFromEvent creates the schema of a sample event: every key path
+// of its nested objects. Lists are leaves.
+func FieldSchemaFromEvent(event interface{}) *FieldSchema {
+	schema := NewFieldSchema()
+	schema.addEvent("", event)
+	return schema
+}
+
+// ParseFieldSchema parses a JSON field schema: either an array of field
+// paths, or a JSON Schema document whose (nested) object properties are
+// the fields.
+func ParseFieldSchema(data []byte) (*FieldSchema, error) {
+	var fields []string
+	if err := json.Unmarshal(data, &fields); err == nil {
+		return NewFieldSchema(fields...), nil
+	}
+
+	var document jsonSchemaObject
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, errors.Errorf(errors.ErrorTypeConfig, "invalid field schema: %v", err)
+	}
+	if document.Properties == nil {
+		return nil, errors.New(errors.ErrorTypeConfig, "invalid field schema: expected an array of field paths or a JSON Schema with properties")
+	}
+	schema := NewFieldSchema()
+	schema.addProperties("", document.Properties)
+	return schema, nil
+}
+
+// jsonSchemaObject is the part of a JSON Schema document listing fields
+type jsonSchemaObject struct {
+	Properties map[string]jsonSchemaObject `json:"properties"`
+}
+
+// Has reports whether events carry a field path
+func (s *FieldSchema) Has(path string) bool {
+	return s.fields[path]
+}
+
+// Fields returns the sorted field paths of the schema
+func (s *FieldSchema) Fields() []string {
+	fields := make([]string, 0, len(s.fields))
+	for field := range s.fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// add adds a field path and its parents
+func (s *FieldSchema) add(path string) {
+	for path != "" && !s.fields[path] {
+		s.fields[path] = true
+		if dot := strings.LastIndexByte(path, '.'); dot >= 0 {
+			path = path[:dot]
+		} else {
+			path = ""
+		}
+	}
+}
+
+func (s *FieldSchema) addEvent(prefix string, value interface{}) {
+	s.add(prefix)
+	if object, ok := value.(map[string]interface{}); ok {
+		for key, child := range object {
+			s.addEvent(joinFieldPath(prefix, key), child)
+		}
+	}
+}
+
+func (s *FieldSchema) addProperties(prefix string, properties map[string]jsonSchemaObject) {
+	for name, property := range properties {
+		path := joinFieldPath(prefix, name)
+		s.add(path)
+		s.addProperties(path, property.Properties)
+	}
+}
+
+func joinFieldPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// FieldCheck is the outcome of checking one rule field against a schema
+type FieldCheck struct {
+	// Field name as written in the rules
+	RuleField string
+
+	// Event field the rule field maps to
+	EventField string
+
+	// Path events are read from: EventField or the first of its aliases
+	// in the schema ("" when the schema has neither)
+	Resolved string
+}
+
+// FieldMappingReport lists the rule fields checked by ValidateAgainst,
+// sorted by rule field.
+type FieldMappingReport struct {
+	Fields []FieldCheck
+}
+
+// Unmapped returns the rule fields that map to nothing in the schema.
+// Primitives on them never match events of the schema.
+func (r *FieldMappingReport) Unmapped() []FieldCheck {
+	var unmapped []FieldCheck
+	for _, check := range r.Fields {
+		if check.Resolved == "" {
+			unmapped = append(unmapped, check)
+		}
+	}
+	return unmapped
+}
+
+// ValidateAgainst checks which event fields rule fields map to in a sample
+// event of the target log source, see ValidateAgainstSchema.
+func (fm *FieldMapping) ValidateAgainst(sampleEvent interface{}, ruleFields []string) *FieldMappingReport {
+	return fm.ValidateAgainstSchema(FieldSchemaFromEvent(sampleEvent), ruleFields)
+}
+
+// ValidateAgainstSchema maps rule fields (see Compiler.DetectionFields) and
+// reports whether the schema has the mapped field or one of its aliases,
+// catching taxonomy gaps that would otherwise silently never match.
+func (fm *FieldMapping) ValidateAgainstSchema(schema *FieldSchema, ruleFields []string) *FieldMappingReport {
+	seen := make(map[string]bool, len(ruleFields))
+	report := &FieldMappingReport{Fields: make([]FieldCheck, 0, len(ruleFields))}
+	for _, ruleField := range ruleFields {
+		if seen[ruleField] {
+			continue
+		}
+		seen[ruleField] = true
+
+		check := FieldCheck{RuleField: ruleField, EventField: fm.NormalizeField(ruleField)}
+		for _, path := range append([]string{check.EventField}, fm.FieldAliases(check.EventField)...) {
+			if schema.Has(path) {
+				check.Resolved = path
+				break
+			}
+		}
+		report.Fields = append(report.Fields, check)
+	}
+	sort.Slice(report.Fields, func(i, j int) bool { return report.Fields[i].RuleField < report.Fields[j].RuleField })
+	return report
+}
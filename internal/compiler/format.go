@@ -0,0 +1,176 @@
+package compiler
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// formatIndent is the indentation of formatted rules, as used by the
+// SIGMA rule repositories
+const formatIndent = 4
+
+// ruleKeyOrder is the canonical order of a rule's top-level keys; unknown
+// keys follow in their original order
+var ruleKeyOrder = []string{
+	"title", "id", "related", "name", "taxonomy", "status", "description",
+	"license", "references", "author", "date", "modified", "tags", "scope",
+	"logsource", "detection", "fields", "falsepositives", "level",
+}
+
+// logSourceKeyOrder is the canonical order of the `logsource:` keys
+var logSourceKeyOrder = []string{"category", "product", "service", "definition"}
+
+// FormatRule formats a SIGMA rule canonically: top-level and logsource keys
+// in the conventional order, the detection condition after the selections,
+// sorted tags, block-style lists and mappings indented by four spaces, and
+// strings quoted only when they must be. Comments are kept. The rule must
+// parse like it would for compilation; formatting an already formatted
+// rule returns it unchanged.
+func FormatRule(ruleYaml string) (string, error) {
+	if _, err := ParseRule(ruleYaml); err != nil {
+		return "", err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(ruleYaml), &document); err != nil {
+		return "", errors.Errorf(errors.ErrorTypeYAML, "invalid rule YAML: %w", err)
+	}
+	root := document.Content[0]
+
+	// A comment heading the rule stays on top whichever key moves there
+	if len(root.Content) > 0 && root.Content[0].HeadComment != "" {
+		document.HeadComment = strings.TrimSpace(document.HeadComment + "\n" + root.Content[0].HeadComment)
+		root.Content[0].HeadComment = ""
+	}
+
+	orderKeys(root, ruleKeyOrder)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "logsource":
+			orderKeys(value, logSourceKeyOrder)
+		case "detection":
+			// Selections keep their order, the condition closes the section
+			orderKeysLast(value, "condition", "timeframe")
+		case "tags":
+			sortScalars(value)
+		}
+	}
+	normalizeStyle(root)
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(formatIndent)
+	if err := encoder.Encode(&document); err != nil {
+		return "", errors.Errorf(errors.ErrorTypeYAML, "failed to format rule: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", errors.Errorf(errors.ErrorTypeYAML, "failed to format rule: %w", err)
+	}
+	return buffer.String(), nil
+}
+
+// orderKeys reorders a mapping's entries by the given key order. Keys not
+// in the order follow in their original order.
+func orderKeys(node *yaml.Node, order []string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	rank := make(map[string]int, len(order))
+	for i, key := range order {
+		rank[key] = i
+	}
+	sortEntries(node, func(key string) int {
+		if r, known := rank[key]; known {
+			return r
+		}
+		return len(order)
+	})
+}
+
+// orderKeysLast moves the given keys, in that order, to the end of a
+// mapping
+func orderKeysLast(node *yaml.Node, last ...string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	sortEntries(node, func(key string) int {
+		for i, name := range last {
+			if key == name {
+				return i + 1
+			}
+		}
+		return 0
+	})
+}
+
+// sortEntries stably sorts a mapping's key/value pairs by the rank of
+// their keys
+func sortEntries(node *yaml.Node, rank func(key string) int) {
+	entries := make([][2]*yaml.Node, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		entries = append(entries, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return rank(entries[i][0].Value) < rank(entries[j][0].Value)
+	})
+	node.Content = node.Content[:0]
+	for _, entry := range entries {
+		node.Content = append(node.Content, entry[0], entry[1])
+	}
+}
+
+// sortScalars sorts the scalar items of a sequence
+func sortScalars(node *yaml.Node) {
+	if node.Kind != yaml.SequenceNode {
+		return
+	}
+	sort.SliceStable(node.Content, func(i, j int) bool {
+		return node.Content[i].Value < node.Content[j].Value
+	})
+}
+
+// normalizeStyle switches every collection to block style and every string
+// to the plainest quoting that keeps its value. Multi-line strings keep
+// their literal or folded style.
+func normalizeStyle(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode, yaml.MappingNode:
+		node.Style &^= yaml.FlowStyle
+		for _, child := range node.Content {
+			normalizeStyle(child)
+		}
+	case yaml.ScalarNode:
+		switch {
+		case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		case node.ShortTag() != "!!str" || plainString(node.Value):
+			node.Style = 0
+		case strings.ContainsAny(node.Value, "\n\t\r"):
+			node.Style = yaml.DoubleQuotedStyle
+		default:
+			node.Style = yaml.SingleQuotedStyle
+		}
+	}
+}
+
+// plainString reports whether a string reads back as the same string when
+// written without quotes
+func plainString(value string) bool {
+	if value == "" || value != strings.TrimSpace(value) || strings.ContainsAny(value[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return false
+	}
+	if strings.Contains(value, ": ") || strings.Contains(value, " #") || strings.HasSuffix(value, ":") {
+		return false
+	}
+	var decoded interface{}
+	if err := yaml.Unmarshal([]byte(value), &decoded); err != nil {
+		return false
+	}
+	str, isString := decoded.(string)
+	return isString && str == value
+}
@@ -0,0 +1,97 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatRule(t *testing.T) {
+	rule := `# Header comment
+level: high
+detection:
+  condition: selection and not filter
+  selection:
+    Image|endswith: ["\\cmd.exe", '-enc']
+    CommandLine: "whoami"
+  filter:
+    User: 'SYSTEM'
+    Count: '12'
+tags: [attack.t1059, attack.execution]
+logsource: {product: windows, category: process_creation}
+id: '1234'
+title: 'Unordered Rule'
+custom: kept
+`
+	expected := `# Header comment
+
+title: Unordered Rule
+id: '1234'
+tags:
+    - attack.execution
+    - attack.t1059
+logsource:
+    category: process_creation
+    product: windows
+detection:
+    selection:
+        Image|endswith:
+            - \cmd.exe
+            - '-enc'
+        CommandLine: whoami
+    filter:
+        User: SYSTEM
+        Count: '12'
+    condition: selection and not filter
+level: high
+custom: kept
+`
+	formatted, err := FormatRule(rule)
+	if err != nil {
+		t.Fatalf("Failed to format rule: %v", err)
+	}
+	if formatted != expected {
+		t.Errorf("Unexpected formatting:\n%s\nexpected:\n%s", formatted, expected)
+	}
+
+	if _, err := FormatRule("title: No Detection\n"); err == nil {
+		t.Error("Expected an error for a rule the compiler rejects")
+	}
+}
+
+func TestFormatRuleIdempotent(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "..", "test-rules", "*.yml"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("Failed to list test rules: %v", err)
+	}
+	for _, path := range paths {
+		if filepath.Base(path) == "malformed_rule.yml" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+
+		formatted, err := FormatRule(string(data))
+		if err != nil {
+			t.Fatalf("%s: failed to format: %v", filepath.Base(path), err)
+		}
+		if again, err := FormatRule(formatted); err != nil || again != formatted {
+			t.Errorf("%s: formatting is not idempotent:\n%s\nthen:\n%s", filepath.Base(path), formatted, again)
+		}
+
+		// Formatting keeps the rule's compiled meaning
+		original, err := NewCompiler().CompileRulesResult([]string{string(data)})
+		if err != nil {
+			t.Fatalf("%s: failed to compile: %v", filepath.Base(path), err)
+		}
+		reformatted, err := NewCompiler().CompileRulesResult([]string{formatted})
+		if err != nil {
+			t.Fatalf("%s: failed to compile formatted rule: %v", filepath.Base(path), err)
+		}
+		if original.PerRule[0].Condition != reformatted.PerRule[0].Condition || original.PrimitiveCount != reformatted.PrimitiveCount {
+			t.Errorf("%s: formatting changed the compiled rule", filepath.Base(path))
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"unicode"
 
 	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
 )
 
 // Token represents tokens in a SIGMA condition expression.
@@ -127,6 +128,10 @@ type ConditionParser struct {
 	tokens       []TokenValue
 	position     int
 	selectionMap map[string][]ir.PrimitiveID
+
+	// Current and maximum parenthesis nesting
+	depth    int
+	maxDepth int
 }
 
 // NewConditionParser creates a new condition parser.
@@ -135,6 +140,7 @@ func NewConditionParser(tokens []TokenValue, selectionMap map[string][]ir.Primit
 		tokens:       tokens,
 		position:     0,
 		selectionMap: selectionMap,
+		maxDepth:     DefaultMaxConditionDepth,
 	}
 }
 
@@ -208,18 +214,23 @@ func (p *ConditionParser) parseNotExpression() (ConditionAst, error) {
 func (p *ConditionParser) parsePrimary() (ConditionAst, error) {
 	token := p.currentToken()
 	if token == nil {
-		return nil, fmt.Errorf("unexpected end of tokens")
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "unexpected end of tokens")
 	}
 
 	switch token.Type {
 	case TokenLeftParen:
 		p.advance()
+		if p.depth >= p.maxDepth {
+			return nil, errors.NewCompilationError(fmt.Sprintf("condition nesting exceeds %d levels", p.maxDepth))
+		}
+		p.depth++
 		expr, err := p.ParseOrExpression()
+		p.depth--
 		if err != nil {
 			return nil, err
 		}
 		if p.currentToken() == nil || p.currentToken().Type != TokenRightParen {
-			return nil, fmt.Errorf("expected closing parenthesis")
+			return nil, errors.Errorf(errors.ErrorTypeCompilation, "expected closing parenthesis")
 		}
 		p.advance()
 		return expr, nil
@@ -231,20 +242,20 @@ func (p *ConditionParser) parsePrimary() (ConditionAst, error) {
 		if _, exists := p.selectionMap[name]; exists {
 			return &Identifier{Name: name}, nil
 		}
-		return nil, fmt.Errorf("unknown selection identifier: %s", name)
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "unknown selection identifier: %s", name)
 
 	case TokenNumber:
 		count := token.Number
 		p.advance()
 
 		if p.currentToken() == nil || p.currentToken().Type != TokenOf {
-			return nil, fmt.Errorf("expected 'of' after number")
+			return nil, errors.Errorf(errors.ErrorTypeCompilation, "expected 'of' after number")
 		}
 		p.advance()
 
 		nextToken := p.currentToken()
 		if nextToken == nil {
-			return nil, fmt.Errorf("expected 'them' or pattern after 'of'")
+			return nil, errors.Errorf(errors.ErrorTypeCompilation, "expected 'them' or pattern after 'of'")
 		}
 
 		switch nextToken.Type {
@@ -253,7 +264,7 @@ func (p *ConditionParser) parsePrimary() (ConditionAst, error) {
 			if count == 1 {
 				return &OneOfThem{}, nil
 			}
-			return nil, fmt.Errorf("only '1 of them' is supported")
+			return nil, errors.Errorf(errors.ErrorTypeCompilation, "only '1 of them' is supported")
 
 		case TokenWildcard:
 			pattern := nextToken.Value
@@ -261,20 +272,20 @@ func (p *ConditionParser) parsePrimary() (ConditionAst, error) {
 			return &CountOfPattern{Count: count, Pattern: pattern}, nil
 
 		default:
-			return nil, fmt.Errorf("expected 'them' or pattern after 'of'")
+			return nil, errors.Errorf(errors.ErrorTypeCompilation, "expected 'them' or pattern after 'of'")
 		}
 
 	case TokenAll:
 		p.advance()
 
 		if p.currentToken() == nil || p.currentToken().Type != TokenOf {
-			return nil, fmt.Errorf("expected 'of' after 'all'")
+			return nil, errors.Errorf(errors.ErrorTypeCompilation, "expected 'of' after 'all'")
 		}
 		p.advance()
 
 		nextToken := p.currentToken()
 		if nextToken == nil {
-			return nil, fmt.Errorf("expected 'them' or pattern after 'of'")
+			return nil, errors.Errorf(errors.ErrorTypeCompilation, "expected 'them' or pattern after 'of'")
 		}
 
 		switch nextToken.Type {
@@ -288,11 +299,11 @@ func (p *ConditionParser) parsePrimary() (ConditionAst, error) {
 			return &AllOfPattern{Pattern: pattern}, nil
 
 		default:
-			return nil, fmt.Errorf("expected 'them' or pattern after 'of'")
+			return nil, errors.Errorf(errors.ErrorTypeCompilation, "expected 'them' or pattern after 'of'")
 		}
 
 	default:
-		return nil, fmt.Errorf("unexpected token in condition")
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "unexpected token in condition")
 	}
 }
 
@@ -328,9 +339,11 @@ func TokenizeCondition(condition string) ([]TokenValue, error) {
 					i++
 				}
 				numberStr := string(runes[start:i])
-				if num, err := strconv.ParseUint(numberStr, 10, 32); err == nil {
-					tokens = append(tokens, TokenValue{Type: TokenNumber, Number: uint32(num)})
+				num, err := strconv.ParseUint(numberStr, 10, 32)
+				if err != nil {
+					return nil, errors.Errorf(errors.ErrorTypeCompilation, "number out of range in condition: %s", numberStr)
 				}
+				tokens = append(tokens, TokenValue{Type: TokenNumber, Number: uint32(num)})
 
 			} else if unicode.IsLetter(ch) || ch == '_' {
 				// Parse identifier/keyword
@@ -362,7 +375,7 @@ func TokenizeCondition(condition string) ([]TokenValue, error) {
 				}
 
 			} else {
-				return nil, fmt.Errorf("unexpected character in condition: '%c'", ch)
+				return nil, errors.Errorf(errors.ErrorTypeCompilation, "unexpected character in condition: '%c'", ch)
 			}
 		}
 	}
@@ -372,10 +385,22 @@ func TokenizeCondition(condition string) ([]TokenValue, error) {
 
 // ParseTokens parses tokens into an AST.
 func ParseTokens(tokens []TokenValue, selectionMap map[string][]ir.PrimitiveID) (ConditionAst, error) {
+	return ParseTokensWithConfig(tokens, selectionMap, DefaultCompilerConfig())
+}
+
+// ParseTokensWithConfig parses tokens into an AST, enforcing the
+// configured condition depth and token limits.
+func ParseTokensWithConfig(tokens []TokenValue, selectionMap map[string][]ir.PrimitiveID, config CompilerConfig) (ConditionAst, error) {
 	if len(tokens) == 0 {
-		return nil, fmt.Errorf("empty condition")
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "empty condition")
+	}
+
+	maxDepth, maxTokens := config.conditionLimits()
+	if len(tokens) > maxTokens {
+		return nil, errors.NewCompilationError(fmt.Sprintf("condition has %d tokens (max %d)", len(tokens), maxTokens))
 	}
 
 	parser := NewConditionParser(tokens, selectionMap)
+	parser.maxDepth = maxDepth
 	return parser.ParseOrExpression()
 }
@@ -1,9 +1,12 @@
 package compiler
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
 )
 
 func createTestSelectionMap() map[string][]ir.PrimitiveID {
@@ -187,6 +190,13 @@ func TestTokenizeInvalidCharacter(t *testing.T) {
 	}
 }
 
+func TestTokenizeNumberOutOfRange(t *testing.T) {
+	_, err := TokenizeCondition("4294967296 of them")
+	if err == nil || !contains(err.Error(), "number out of range") {
+		t.Errorf("Expected 'number out of range' error, got: %v", err)
+	}
+}
+
 // TestTokenizeWhitespaceHandling matches Rust test_tokenize_whitespace_handling
 func TestTokenizeWhitespaceHandling(t *testing.T) {
 	tokens, err := TokenizeCondition("  selection1   and   selection2  ")
@@ -411,3 +421,67 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestParseConditionLimits(t *testing.T) {
+	nested := strings.Repeat("(", 10) + "selection1" + strings.Repeat(")", 10)
+	tokens, err := TokenizeCondition(nested)
+	if err != nil {
+		t.Fatalf("Failed to tokenize: %v", err)
+	}
+	if _, err := ParseTokensWithConfig(tokens, createTestSelectionMap(), CompilerConfig{MaxConditionDepth: 10}); err != nil {
+		t.Errorf("Expected 10 levels of nesting to parse, got %v", err)
+	}
+	_, err = ParseTokensWithConfig(tokens, createTestSelectionMap(), CompilerConfig{MaxConditionDepth: 9})
+	var sigmaErr *sigmaerrors.SigmaError
+	if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeCompilation {
+		t.Errorf("Expected a compilation error for 10 levels with a limit of 9, got %v", err)
+	}
+
+	// Deep nesting is rejected by the default depth limit without
+	// exhausting the stack, even when the token limit is raised
+	tokens, err = TokenizeCondition(strings.Repeat("(", 100000) + "selection1" + strings.Repeat(")", 100000))
+	if err != nil {
+		t.Fatalf("Failed to tokenize: %v", err)
+	}
+	if _, err := ParseTokensWithConfig(tokens, createTestSelectionMap(), CompilerConfig{MaxConditionTokens: len(tokens)}); err == nil || !contains(err.Error(), "nesting exceeds 64") {
+		t.Errorf("Expected a nesting error, got %v", err)
+	}
+
+	long := strings.TrimSuffix(strings.Repeat("selection1 or ", 10), " or ")
+	tokens, err = TokenizeCondition(long)
+	if err != nil {
+		t.Fatalf("Failed to tokenize: %v", err)
+	}
+	if _, err := ParseTokensWithConfig(tokens, createTestSelectionMap(), CompilerConfig{MaxConditionTokens: 18}); err == nil || !contains(err.Error(), "19 tokens") {
+		t.Errorf("Expected a token count error, got %v", err)
+	}
+}
+
+func FuzzTokenizeAndParseCondition(f *testing.F) {
+	for _, seed := range []string{
+		"selection1",
+		"selection1 and not selection2",
+		"(selection1 or selection2) and selection3",
+		"1 of selection*",
+		"all of them",
+		"4294967296 of them",
+		"((((selection1))))",
+		"not not selection1 or",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, condition string) {
+		tokens, err := TokenizeCondition(condition)
+		if err != nil {
+			return
+		}
+		ast, err := ParseTokens(tokens, createTestSelectionMap())
+		if err != nil {
+			return
+		}
+		if ast == nil {
+			t.Fatalf("%q parsed without error to a nil AST", condition)
+		}
+		_ = ast.String()
+	})
+}
@@ -0,0 +1,130 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SourceError is a rule compilation error located in the rule's YAML source.
+type SourceError struct {
+	// Position of the offending key or value (1-based)
+	Line   int
+	Column int
+
+	// The source line at Line, without surrounding whitespace
+	Snippet string
+
+	Err error
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %v (at %q)", e.Line, e.Column, e.Err, e.Snippet)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// ruleSource is the YAML node tree and text of a parsed rule, kept to locate
+// compilation errors
+type ruleSource struct {
+	root  *yaml.Node
+	lines []string
+}
+
+// newRuleSource parses the node tree of a rule; nil when the YAML is not a
+// mapping
+func newRuleSource(ruleYaml string) *ruleSource {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(ruleYaml), &document); err != nil || len(document.Content) == 0 {
+		return nil
+	}
+	if document.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	return &ruleSource{root: document.Content[0], lines: strings.Split(ruleYaml, "\n")}
+}
+
+// lookup returns the deepest node along a path of mapping keys and sequence
+// indexes. Mapping entries resolve to their key node, so errors point at
+// the key that introduces the offending value.
+func (s *ruleSource) lookup(path []string) *yaml.Node {
+	var found *yaml.Node
+	node := s.root
+	for _, step := range path {
+		var next, located *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == step {
+					located, next = node.Content[i], node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if index, err := strconv.Atoi(step); err == nil && index >= 0 && index < len(node.Content) {
+				located, next = node.Content[index], node.Content[index]
+			}
+		}
+		if next == nil {
+			break
+		}
+		found, node = located, next
+	}
+	return found
+}
+
+// sourcePathError marks the detection entry an error comes from, as a path
+// of keys and list indexes below `detection:`. It reads like the error it
+// wraps.
+type sourcePathError struct {
+	path []string
+	err  error
+}
+
+func (e *sourcePathError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sourcePathError) Unwrap() error {
+	return e.err
+}
+
+// withSourcePath prefixes the detection path of err with the given steps,
+// marking err with them when it has no path yet
+func withSourcePath(err error, steps ...string) error {
+	var pathErr *sourcePathError
+	if errors.As(err, &pathErr) {
+		pathErr.path = append(append([]string(nil), steps...), pathErr.path...)
+		return err
+	}
+	return &sourcePathError{path: steps, err: err}
+}
+
+// locate attaches the source position of a detection entry to a compilation
+// error. The entry is the error's own detection path when it has one, and
+// fallback otherwise. Errors of rules without source are returned as is.
+func (r *SigmaRule) locate(err error, fallback ...string) error {
+	if r.source == nil {
+		return err
+	}
+	path := fallback
+	var pathErr *sourcePathError
+	if errors.As(err, &pathErr) {
+		path = pathErr.path
+	}
+
+	node := r.source.lookup(append([]string{"detection"}, path...))
+	if node == nil {
+		return err
+	}
+	sourceErr := &SourceError{Line: node.Line, Column: node.Column, Err: err}
+	if node.Line > 0 && node.Line <= len(r.source.lines) {
+		sourceErr.Snippet = strings.TrimSpace(r.source.lines[node.Line-1])
+	}
+	return sourceErr
+}
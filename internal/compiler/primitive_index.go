@@ -0,0 +1,112 @@
+package compiler
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+// PrimitiveIndex carries the primitive table of a compilation over to the
+// next compilation of the same, slightly edited rules. A compiler seeded
+// with the index gives unchanged primitives the IDs they had before, so
+// engines and caches keyed by primitive ID (compiled regexes, literal sets)
+// do not need rebuilding; only new primitives get new IDs.
+//
+// Primitives of the previous compilation that no rule uses any more keep
+// their IDs reserved: they stay in the index but are left out of the
+// ruleset, whose primitive IDs then have gaps. Once more than half of the
+// table is unused, the index drops them and the next compilation renumbers
+// the primitives.
+//
+// An index can be persisted as JSON between runs. It is safe for
+// concurrent use, but compilations sharing an index should not overlap.
+type PrimitiveIndex struct {
+	mu         sync.Mutex
+	primitives []ir.Primitive
+	ids        map[uint64][]ir.PrimitiveID
+}
+
+// NewPrimitiveIndex creates an empty primitive index
+func NewPrimitiveIndex() *PrimitiveIndex {
+	return &PrimitiveIndex{ids: make(map[uint64][]ir.PrimitiveID)}
+}
+
+// Len returns the number of indexed primitives
+func (i *PrimitiveIndex) Len() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return len(i.primitives)
+}
+
+// Lookup returns the ID of a primitive in the index, by its canonical hash
+func (i *PrimitiveIndex) Lookup(primitive ir.Primitive) (ir.PrimitiveID, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, id := range i.ids[primitive.Hash()] {
+		if i.primitives[id].Equal(&primitive) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// Primitives returns the indexed primitives, by ID
+func (i *PrimitiveIndex) Primitives() []ir.Primitive {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]ir.Primitive(nil), i.primitives...)
+}
+
+// update replaces the index with a compiled primitive table, keeping only
+// the used primitives when more than half of the table is unused. It
+// returns the number of primitives dropped.
+func (i *PrimitiveIndex) update(primitives []ir.Primitive, used map[ir.PrimitiveID]bool) int {
+	if len(used)*2 < len(primitives) {
+		kept := make([]ir.Primitive, 0, len(used))
+		for id, primitive := range primitives {
+			if used[ir.PrimitiveID(id)] {
+				kept = append(kept, primitive)
+			}
+		}
+		i.set(kept)
+		return len(primitives) - len(kept)
+	}
+	i.set(primitives)
+	return 0
+}
+
+// set replaces the indexed primitives, numbering them in order
+func (i *PrimitiveIndex) set(primitives []ir.Primitive) {
+	ids := make(map[uint64][]ir.PrimitiveID, len(primitives))
+	for id := range primitives {
+		hash := primitives[id].Hash()
+		ids[hash] = append(ids[hash], ir.PrimitiveID(id))
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.primitives = append([]ir.Primitive(nil), primitives...)
+	i.ids = ids
+}
+
+// primitiveIndexJSON is the persisted form of a primitive index. Hashes
+// are recomputed on load, so they never go stale.
+type primitiveIndexJSON struct {
+	Primitives []ir.Primitive `json:"primitives"`
+}
+
+// MarshalJSON encodes the indexed primitives, by ID
+func (i *PrimitiveIndex) MarshalJSON() ([]byte, error) {
+	return json.Marshal(primitiveIndexJSON{Primitives: i.Primitives()})
+}
+
+// UnmarshalJSON restores an index encoded by MarshalJSON
+func (i *PrimitiveIndex) UnmarshalJSON(data []byte) error {
+	var decoded primitiveIndexJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	i.set(decoded.Primitives)
+	return nil
+}
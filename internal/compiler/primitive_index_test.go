@@ -0,0 +1,168 @@
+package compiler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+const indexedNetworkRule = `
+title: Suspicious Port
+detection:
+    selection:
+        DestinationPort: 4444
+    condition: selection
+`
+
+func TestPrimitiveIndexKeepsIDsAcrossCompiles(t *testing.T) {
+	index := NewPrimitiveIndex()
+	first, err := NewCompiler().WithPrimitiveIndex(index).CompileRules([]string{testProcessRule, indexedNetworkRule})
+	if err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+	if index.Len() != len(first.Primitives) {
+		t.Fatalf("Expected the index to hold %d primitives, got %d", len(first.Primitives), index.Len())
+	}
+
+	// Edit one value of the process rule and recompile with a new compiler
+	edited := strings.Replace(testProcessRule, "'IEX'", "'Invoke-Expression'", 1)
+	second, err := NewCompiler().WithPrimitiveIndex(index).CompileRules([]string{edited, indexedNetworkRule})
+	if err != nil {
+		t.Fatalf("Failed to recompile rules: %v", err)
+	}
+	if index.Len() != len(first.Primitives)+1 {
+		t.Fatalf("Expected one new primitive in the index, got %d after %d", index.Len(), len(first.Primitives))
+	}
+	secondByID := primitivesByID(second)
+	if len(secondByID) != len(first.Primitives) {
+		t.Fatalf("Expected the replaced primitive to be left out, got %d primitives", len(secondByID))
+	}
+	var replaced dag.Primitive
+	for _, primitive := range first.Primitives {
+		if kept, exists := secondByID[primitive.ID]; !exists {
+			replaced = primitive
+		} else if !primitiveEqual(kept, primitive) {
+			t.Errorf("Expected primitive %d to keep its ID, got %+v instead of %+v", primitive.ID, kept, primitive)
+		}
+	}
+	if len(replaced.Values) != 2 || replaced.Values[0] != "IEX" {
+		t.Errorf("Expected the edited primitive to be left out, got %+v", replaced)
+	}
+	added, exists := secondByID[uint32(len(first.Primitives))]
+	if !exists || len(added.Values) != 2 || added.Values[0] != "Invoke-Expression" {
+		t.Errorf("Expected the edited value to be appended, got %+v", added)
+	}
+	if id, ok := index.Lookup(ir.Primitive{Field: added.Field, MatchType: added.MatchType, Values: added.Values, Modifiers: added.Modifiers, IgnoreCase: added.IgnoreCase}); !ok || id != ir.PrimitiveID(len(first.Primitives)) {
+		t.Errorf("Expected the index to hold the new primitive, got %d, %v", id, ok)
+	}
+
+	engine, err := dag.NewDagEngineBuilder().BuildFromRuleset(second)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+	if report := engine.Verify(); !report.OK() {
+		t.Errorf("Expected a consistent engine, got %v", report.Err())
+	}
+	processEvent := map[string]interface{}{
+		"Image":       `C:\Windows\System32\powershell.exe`,
+		"CommandLine": "Invoke-Expression $payload",
+		"User":        "alice",
+	}
+	result, err := engine.Evaluate(processEvent)
+	if err != nil || len(result.MatchedRules) != 1 || result.MatchedRules[0] != 0 {
+		t.Errorf("Expected the edited rule to match, got %v, %v", result, err)
+	}
+
+	// Removing a rule leaves its primitive in the index only
+	third, err := NewCompiler().WithPrimitiveIndex(index).CompileRules([]string{edited})
+	if err != nil {
+		t.Fatalf("Failed to recompile rules: %v", err)
+	}
+	if index.Len() != len(first.Primitives)+1 {
+		t.Fatalf("Expected the index to keep the removed rule's primitive, got %d primitives", index.Len())
+	}
+	for _, primitive := range third.Primitives {
+		if primitive.Field == "DestinationPort" {
+			t.Errorf("Expected the removed rule's primitive to be left out, got %+v", primitive)
+		}
+	}
+	engine, err = dag.NewDagEngineBuilder().BuildFromRuleset(third)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+	if report := engine.Verify(); !report.OK() {
+		t.Errorf("Expected a consistent engine after removing a rule, got %v", report.Err())
+	}
+	result, err = engine.Evaluate(processEvent)
+	if err != nil || len(result.MatchedRules) != 1 || result.MatchedRules[0] != 0 {
+		t.Errorf("Expected the remaining rule to match, got %v, %v", result, err)
+	}
+}
+
+func TestPrimitiveIndexDropsUnusedPrimitives(t *testing.T) {
+	index := NewPrimitiveIndex()
+	if _, err := NewCompiler().WithPrimitiveIndex(index).CompileRules([]string{testProcessRule, indexedNetworkRule}); err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+
+	// Most primitives are unused once the process rule is removed
+	if _, err := NewCompiler().WithPrimitiveIndex(index).CompileRules([]string{indexedNetworkRule}); err != nil {
+		t.Fatalf("Failed to recompile rules: %v", err)
+	}
+	if index.Len() != 1 {
+		t.Fatalf("Expected the index to keep only the used primitive, got %d", index.Len())
+	}
+	ruleset, err := NewCompiler().WithPrimitiveIndex(index).CompileRules([]string{indexedNetworkRule})
+	if err != nil {
+		t.Fatalf("Failed to recompile rules: %v", err)
+	}
+	if len(ruleset.Primitives) != 1 || ruleset.Primitives[0].Field != "DestinationPort" {
+		t.Errorf("Expected the primitives to be renumbered, got %+v", ruleset.Primitives)
+	}
+}
+
+func TestPrimitiveIndexJSON(t *testing.T) {
+	index := NewPrimitiveIndex()
+	first, err := NewCompiler().WithPrimitiveIndex(index).CompileRules([]string{testProcessRule, indexedNetworkRule})
+	if err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		t.Fatalf("Failed to encode index: %v", err)
+	}
+	restored := NewPrimitiveIndex()
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Failed to decode index: %v", err)
+	}
+
+	second, err := NewCompiler().WithPrimitiveIndex(restored).CompileRules([]string{indexedNetworkRule, testProcessRule})
+	if err != nil {
+		t.Fatalf("Failed to recompile rules: %v", err)
+	}
+	for id, primitive := range first.Primitives {
+		if !primitiveEqual(second.Primitives[id], primitive) {
+			t.Errorf("Expected primitive %d to keep its ID after reordering rules, got %+v", id, second.Primitives[id])
+		}
+	}
+}
+
+// primitivesByID returns the primitives of a ruleset by ID
+func primitivesByID(ruleset *dag.CompiledRuleset) map[uint32]dag.Primitive {
+	primitives := make(map[uint32]dag.Primitive, len(ruleset.Primitives))
+	for _, primitive := range ruleset.Primitives {
+		primitives[primitive.ID] = primitive
+	}
+	return primitives
+}
+
+// primitiveEqual compares compiled primitives, ignoring string identity
+func primitiveEqual(a, b dag.Primitive) bool {
+	return a.ID == b.ID && a.Field == b.Field && a.MatchType == b.MatchType &&
+		strings.Join(a.Values, "\x1f") == strings.Join(b.Values, "\x1f") &&
+		strings.Join(a.Modifiers, "\x1f") == strings.Join(b.Modifiers, "\x1f")
+}
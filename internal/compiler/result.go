@@ -0,0 +1,315 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+// CompilationResult is a compiled ruleset together with compilation
+// statistics and the artifacts of every compiled rule.
+type CompilationResult struct {
+	// Ruleset ready for the DAG engine
+	Ruleset *dag.CompiledRuleset
+
+	// Aggregate statistics
+	RuleCount      int
+	PrimitiveCount int
+	NodeCount      int
+
+	// Compiled artifacts per rule, in rule ID order
+	PerRule []RuleCompileInfo
+
+	// Rules excluded because they failed to compile (tolerant mode only)
+	Errors RuleCompileErrors
+
+	// Rules left out by policy, e.g. deprecated rules
+	Skipped []SkippedRule
+
+	// Time spent compiling, in total and per phase
+	Statistics CompilationStatistics
+}
+
+// CompilationStatistics records the time a compiler spent per compilation
+// phase, summed over every rule it compiled (failed rules included).
+type CompilationStatistics struct {
+	// Total time of all phases, in milliseconds
+	CompilationTimeMs float64
+
+	// Parsing rule YAML and checking it against the rule schema
+	Parse time.Duration
+
+	// Compiling detection selections into primitives
+	SelectionProcessing time.Duration
+
+	// Tokenizing, parsing and expanding conditions
+	ConditionParse time.Duration
+
+	// Generating the rule DAGs
+	Codegen time.Duration
+
+	// Merging the rule DAGs into the ruleset. The DAG optimizer runs when
+	// the engine is built and reports its own pass statistics.
+	Build time.Duration
+}
+
+// Total returns the time of all phases
+func (s CompilationStatistics) Total() time.Duration {
+	return s.Parse + s.SelectionProcessing + s.ConditionParse + s.Codegen + s.Build
+}
+
+// add adds the phase times of other to s
+func (s *CompilationStatistics) add(other CompilationStatistics) {
+	s.Parse += other.Parse
+	s.SelectionProcessing += other.SelectionProcessing
+	s.ConditionParse += other.ConditionParse
+	s.Codegen += other.Codegen
+	s.Build += other.Build
+}
+
+// RuleCompileInfo maps a rule's compiled structures back to its source.
+type RuleCompileInfo struct {
+	// SIGMA rule UUID (the rule's `id:`) and title
+	UUID  string
+	Title string
+
+	// Rule ID assigned by the compiler
+	RuleID ir.RuleID
+
+	// Primitive IDs of each detection selection
+	Selections map[string][]ir.PrimitiveID
+
+	// Sorted primitive IDs referenced by the rule's DAG
+	PrimitiveIDs []ir.PrimitiveID
+
+	// Parsed condition, with multiple conditions combined with OR
+	Condition string
+
+	// Parsed status, and creation and modification dates (zero when absent
+	// or invalid)
+	Status   RuleStatus
+	Date     time.Time
+	Modified time.Time
+
+	// The rule's `related:` links to other rules
+	Related []RelatedRule
+
+	// Non-fatal compilation issues, e.g. skipped modifiers
+	Warnings []string
+
+	// Time spent compiling the parsed rule, from its selections to its DAG
+	CompileTime time.Duration
+}
+
+// SkippedRule records a rule left out of compilation by policy.
+type SkippedRule struct {
+	// Position of the rule in the compiled input
+	Index int
+
+	// SIGMA rule UUID and title
+	UUID  string
+	Title string
+
+	// Why the rule was skipped, e.g. "status deprecated"
+	Reason string
+}
+
+// RuleCompileError records a rule excluded from a tolerant compilation.
+type RuleCompileError struct {
+	// Position of the rule in the compiled input
+	Index int
+
+	// SIGMA rule UUID and title (empty when the rule could not be parsed)
+	UUID  string
+	Title string
+
+	Err error
+}
+
+func (e RuleCompileError) Error() string {
+	if e.Title == "" {
+		return fmt.Sprintf("rule %d: %v", e.Index, e.Err)
+	}
+	return fmt.Sprintf("rule %d (%s): %v", e.Index, e.Title, e.Err)
+}
+
+func (e RuleCompileError) Unwrap() error {
+	return e.Err
+}
+
+// RuleCompileErrors is the failures of a tolerant compilation. It
+// implements error like the result of errors.Join: errors.Is and errors.As
+// see every rule's failure.
+type RuleCompileErrors []RuleCompileError
+
+// Error lists the failures one per line
+func (e RuleCompileErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, failure := range e {
+		messages[i] = failure.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (e RuleCompileErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, failure := range e {
+		errs[i] = failure
+	}
+	return errs
+}
+
+// Err returns the rules that failed to compile as a single error, or nil
+// when every rule compiled.
+func (r *CompilationResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return r.Errors
+}
+
+// newRuleCompileInfo collects the compiled artifacts of a rule
+func newRuleCompileInfo(
+	ruleID ir.RuleID,
+	rule *SigmaRule,
+	selections []*compiledSelection,
+	condition ConditionAst,
+	result *DagGenerationResult,
+	warnings []string,
+) RuleCompileInfo {
+	info := RuleCompileInfo{
+		UUID:         rule.ID,
+		Title:        rule.Title,
+		RuleID:       ruleID,
+		Selections:   make(map[string][]ir.PrimitiveID, len(selections)),
+		PrimitiveIDs: make([]ir.PrimitiveID, 0, len(result.PrimitiveNodes)),
+		Condition:    condition.String(),
+		Related:      append([]RelatedRule(nil), rule.Related...),
+		Status:       rule.RuleStatus(),
+		Warnings:     warnings,
+	}
+	info.Date, _ = rule.CreatedAt()
+	info.Modified, _ = rule.ModifiedAt()
+	for _, selection := range selections {
+		var ids []ir.PrimitiveID
+		for _, alternative := range selection.alternatives {
+			ids = append(ids, alternative...)
+		}
+		info.Selections[selection.name] = ids
+	}
+	for primitiveID := range result.PrimitiveNodes {
+		info.PrimitiveIDs = append(info.PrimitiveIDs, primitiveID)
+	}
+	sort.Slice(info.PrimitiveIDs, func(i, j int) bool { return info.PrimitiveIDs[i] < info.PrimitiveIDs[j] })
+	return info
+}
+
+// ruleWarnings returns the non-fatal issues of a rule: modifiers skipped in
+// lenient mode, an ignored timeframe and selections the condition never uses
+func (c *Compiler) ruleWarnings(rule *SigmaRule, selections []*compiledSelection, expanded ConditionAst) []string {
+	warnings := statusWarnings(rule)
+	if c.config.LenientModifiers {
+		for _, err := range detectionModifierErrors(rule.Detection, c.knownModifier) {
+			warnings = append(warnings, fmt.Sprintf("skipped: %v", err))
+		}
+	}
+	if c.config.UnmappedFieldPolicy == UnmappedFieldWarn && !c.fieldMapping.IsEmpty() {
+		for _, err := range unmappedFieldErrors(rule.Detection, c.fieldMapping, c.config.ExpandFieldPatterns) {
+			warnings = append(warnings, err.Error())
+		}
+	}
+	if c.config.ExpandFieldPatterns {
+		warnings = append(warnings, fieldPatternWarnings(rule.Detection, c.fieldMapping)...)
+	}
+	if _, exists := rule.Detection["timeframe"]; exists {
+		warnings = append(warnings, "timeframe is not supported and was ignored")
+	}
+	for _, related := range rule.Related {
+		if !related.Type.Known() {
+			warnings = append(warnings, fmt.Sprintf("related rule %s has unknown type %q", related.ID, related.Type))
+		}
+	}
+
+	used := make(map[string]bool)
+	collectIdentifiers(expanded, used)
+	for _, selection := range selections {
+		referenced := false
+		for i := range selection.alternatives {
+			referenced = referenced || used[selection.key(i)]
+		}
+		if !referenced {
+			warnings = append(warnings, fmt.Sprintf("selection %s is not used by the condition", selection.name))
+		}
+	}
+	return warnings
+}
+
+// collectIdentifiers adds every identifier of an expanded condition to names
+func collectIdentifiers(ast ConditionAst, names map[string]bool) {
+	switch node := ast.(type) {
+	case *Identifier:
+		names[node.Name] = true
+	case *And:
+		collectIdentifiers(node.Left, names)
+		collectIdentifiers(node.Right, names)
+	case *Or:
+		collectIdentifiers(node.Left, names)
+		collectIdentifiers(node.Right, names)
+	case *Not:
+		collectIdentifiers(node.Operand, names)
+	}
+}
+
+// SupersededRules returns the sorted UUIDs of compiled rules that another
+// compiled rule obsoletes, merges or renames, i.e. rules loaded twice under
+// an old and a new identity. Rule management can drop them to avoid
+// duplicate detections.
+func (r *CompilationResult) SupersededRules() []string {
+	compiled := make(map[string]bool, len(r.PerRule))
+	for _, info := range r.PerRule {
+		if info.UUID != "" {
+			compiled[info.UUID] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	var superseded []string
+	for _, info := range r.PerRule {
+		for _, related := range info.Related {
+			if related.Type.Supersedes() && compiled[related.ID] && related.ID != info.UUID && !seen[related.ID] {
+				seen[related.ID] = true
+				superseded = append(superseded, related.ID)
+			}
+		}
+	}
+	sort.Strings(superseded)
+	return superseded
+}
+
+// BuildResult builds the ruleset like Build and reports the compiled
+// artifacts of every rule.
+func (c *Compiler) BuildResult() (*CompilationResult, error) {
+	ruleset, err := c.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CompilationResult{
+		Ruleset:        ruleset,
+		RuleCount:      len(c.rules),
+		PrimitiveCount: len(ruleset.Primitives),
+		NodeCount:      len(ruleset.Dag.Nodes),
+		PerRule:        make([]RuleCompileInfo, 0, len(c.rules)),
+		Errors:         append(RuleCompileErrors(nil), c.errors...),
+		Skipped:        append([]SkippedRule(nil), c.skipped...),
+		Statistics:     c.Statistics(),
+	}
+	for _, rule := range c.rules {
+		result.PerRule = append(result.PerRule, rule.info)
+	}
+	return result, nil
+}
@@ -0,0 +1,177 @@
+package compiler
+
+import (
+	"io"
+	"strings"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// SigmaRule represents a parsed SIGMA rule document.
+type SigmaRule struct {
+	Title          string                 `yaml:"title"`
+	ID             string                 `yaml:"id"`
+	Status         string                 `yaml:"status"`
+	Description    string                 `yaml:"description"`
+	Author         string                 `yaml:"author"`
+	Date           string                 `yaml:"date"`
+	Modified       string                 `yaml:"modified"`
+	References     []string               `yaml:"references"`
+	Tags           []string               `yaml:"tags"`
+	LogSource      LogSource              `yaml:"logsource"`
+	Detection      map[string]interface{} `yaml:"detection"`
+	Fields         []string               `yaml:"fields"`
+	FalsePositives []string               `yaml:"falsepositives"`
+	Level          string                 `yaml:"level"`
+	Related        []RelatedRule          `yaml:"related"`
+	Name           string                 `yaml:"name"`
+	Taxonomy       string                 `yaml:"taxonomy"`
+	License        string                 `yaml:"license"`
+	Scope          []string               `yaml:"scope"`
+
+	// Sample events the rule must or must not match, from the `x-tests:`
+	// extension
+	Tests []RuleTestCase `yaml:"x-tests"`
+
+	// YAML source the rule was parsed from, to locate compilation errors
+	source *ruleSource
+}
+
+// RelatedRule is an entry of a rule's `related:` list, linking it to
+// another rule by UUID.
+type RelatedRule struct {
+	ID   string      `yaml:"id"`
+	Type RelatedType `yaml:"type"`
+}
+
+// RelatedType is how a rule relates to the rule it references.
+type RelatedType string
+
+const (
+	// RelatedDerived: the rule is derived from the referenced rule, which
+	// remains valid
+	RelatedDerived RelatedType = "derived"
+	// RelatedObsoletes: the rule replaces the referenced rule
+	RelatedObsoletes RelatedType = "obsoletes"
+	// RelatedMerged: the rule was merged from the referenced rule, among
+	// others, and replaces it
+	RelatedMerged RelatedType = "merged"
+	// RelatedRenamed: the rule was previously known under the referenced ID
+	RelatedRenamed RelatedType = "renamed"
+	// RelatedSimilar: the rule detects something similar to the referenced
+	// rule, both remain valid
+	RelatedSimilar RelatedType = "similar"
+)
+
+// Known reports whether the type is one defined by the SIGMA specification.
+func (t RelatedType) Known() bool {
+	switch t {
+	case RelatedDerived, RelatedObsoletes, RelatedMerged, RelatedRenamed, RelatedSimilar:
+		return true
+	}
+	return false
+}
+
+// Supersedes reports whether the referencing rule replaces the referenced
+// one, so loading both would detect the same activity twice.
+func (t RelatedType) Supersedes() bool {
+	return t == RelatedObsoletes || t == RelatedMerged || t == RelatedRenamed
+}
+
+// RuleTestCase is an entry of a rule's `x-tests:` list: a sample event and
+// whether the rule matches it.
+type RuleTestCase struct {
+	Name  string                 `yaml:"name"`
+	Match bool                   `yaml:"match"`
+	Event map[string]interface{} `yaml:"event"`
+}
+
+// LogSource describes the log source a SIGMA rule applies to.
+type LogSource struct {
+	Category   string `yaml:"category"`
+	Product    string `yaml:"product"`
+	Service    string `yaml:"service"`
+	Definition string `yaml:"definition"`
+}
+
+// ParseRule parses a SIGMA rule from its YAML source. Unknown keys are
+// ignored, see ParseRuleStrict and ValidateRuleSchema.
+func ParseRule(ruleYaml string) (*SigmaRule, error) {
+	var rule SigmaRule
+	if err := yaml.Unmarshal([]byte(ruleYaml), &rule); err != nil {
+		return nil, errors.Errorf(errors.ErrorTypeYAML, "invalid rule YAML: %w", err)
+	}
+	rule.source = newRuleSource(ruleYaml)
+	return validateParsedRule(&rule)
+}
+
+// ParseRuleStrict parses a SIGMA rule like ParseRule, but rejects keys
+// that are not part of the rule schema instead of dropping them.
+func ParseRuleStrict(ruleYaml string) (*SigmaRule, error) {
+	var rule SigmaRule
+	decoder := yaml.NewDecoder(strings.NewReader(ruleYaml))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&rule); err != nil && err != io.EOF {
+		return nil, errors.Errorf(errors.ErrorTypeYAML, "invalid rule YAML: %w", err)
+	}
+	rule.source = newRuleSource(ruleYaml)
+	return validateParsedRule(&rule)
+}
+
+// validateParsedRule checks that a decoded rule has a detection condition
+func validateParsedRule(rule *SigmaRule) (*SigmaRule, error) {
+	if len(rule.Detection) == 0 {
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "rule %q has no detection section", rule.Title)
+	}
+	if _, exists := rule.Detection["condition"]; !exists {
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "rule %q has no detection condition", rule.Title)
+	}
+
+	return rule, nil
+}
+
+// Conditions returns the rule's detection conditions.
+//
+// SIGMA allows the condition to be a single expression or a list of
+// expressions that are combined with OR.
+func (r *SigmaRule) Conditions() ([]string, error) {
+	switch condition := r.Detection["condition"].(type) {
+	case string:
+		return []string{condition}, nil
+	case []interface{}:
+		conditions := make([]string, 0, len(condition))
+		for _, item := range condition {
+			str, ok := item.(string)
+			if !ok {
+				return nil, errors.Errorf(errors.ErrorTypeCompilation, "condition list entries must be strings, got %T", item)
+			}
+			conditions = append(conditions, str)
+		}
+		if len(conditions) == 0 {
+			return nil, errors.Errorf(errors.ErrorTypeCompilation, "empty condition list")
+		}
+		return conditions, nil
+	default:
+		return nil, errors.Errorf(errors.ErrorTypeCompilation, "condition must be a string or list of strings, got %T", condition)
+	}
+}
+
+// Meta returns the engine metadata for the rule compiled under the given ID.
+func (r *SigmaRule) Meta(ruleID ir.RuleID) dag.RuleMeta {
+	return dag.RuleMeta{
+		ID:      ruleID,
+		SigmaID: r.ID,
+		Title:   r.Title,
+		Level:   dag.ParseRuleLevel(r.Level),
+		Status:  r.Status,
+		Tags:    append([]string(nil), r.Tags...),
+		LogSource: dag.LogSource{
+			Category: r.LogSource.Category,
+			Product:  r.LogSource.Product,
+			Service:  r.LogSource.Service,
+		},
+	}
+}
@@ -0,0 +1,167 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRuleConditionList(t *testing.T) {
+	rule, err := ParseRule(`
+title: Multiple Conditions
+detection:
+    selection1:
+        EventID: 1
+    selection2:
+        EventID: 2
+    condition:
+        - selection1
+        - selection2
+fields:
+    - EventID
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse rule: %v", err)
+	}
+
+	conditions, err := rule.Conditions()
+	if err != nil {
+		t.Fatalf("Failed to read conditions: %v", err)
+	}
+	if len(conditions) != 2 || conditions[0] != "selection1" {
+		t.Errorf("Expected 2 conditions, got %v", conditions)
+	}
+	if len(rule.Fields) != 1 || rule.Fields[0] != "EventID" {
+		t.Errorf("Expected fields [EventID], got %v", rule.Fields)
+	}
+}
+
+func TestParseRuleMissingDetection(t *testing.T) {
+	if _, err := ParseRule("title: No Detection\n"); err == nil {
+		t.Error("Expected error for rule without detection")
+	}
+	if _, err := ParseRule("title: [unterminated\n"); err == nil {
+		t.Error("Expected error for invalid YAML")
+	}
+}
+
+func TestParseRuleRelated(t *testing.T) {
+	rule, err := ParseRule(`
+title: Related Rule
+related:
+    - id: 11111111-1111-1111-1111-111111111111
+      type: obsoletes
+    - id: 22222222-2222-2222-2222-222222222222
+      type: similar
+detection:
+    selection:
+        EventID: 1
+    condition: selection
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse rule: %v", err)
+	}
+
+	expected := []RelatedRule{
+		{ID: "11111111-1111-1111-1111-111111111111", Type: RelatedObsoletes},
+		{ID: "22222222-2222-2222-2222-222222222222", Type: RelatedSimilar},
+	}
+	if !reflect.DeepEqual(rule.Related, expected) {
+		t.Errorf("Expected related %v, got %v", expected, rule.Related)
+	}
+	if !RelatedObsoletes.Supersedes() || RelatedSimilar.Supersedes() || RelatedDerived.Supersedes() {
+		t.Error("Only obsoletes, merged and renamed should supersede")
+	}
+	if RelatedType("copied").Known() || !RelatedRenamed.Known() {
+		t.Error("Unexpected known related types")
+	}
+}
+
+func TestParseRuleTests(t *testing.T) {
+	rule, err := ParseRuleStrict(`
+title: Tested Rule
+detection:
+    selection:
+        EventID: 1
+    condition: selection
+x-tests:
+    - name: process creation
+      match: true
+      event:
+          EventID: 1
+    - match: false
+      event:
+          EventID: 4688
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse rule: %v", err)
+	}
+
+	expected := []RuleTestCase{
+		{Name: "process creation", Match: true, Event: map[string]interface{}{"EventID": 1}},
+		{Match: false, Event: map[string]interface{}{"EventID": 4688}},
+	}
+	if !reflect.DeepEqual(rule.Tests, expected) {
+		t.Errorf("Expected tests %v, got %v", expected, rule.Tests)
+	}
+}
+
+func TestParseRuleStatusAndDates(t *testing.T) {
+	rule, err := ParseRule(`
+title: Dated Rule
+status: Experimental
+date: 2024/01/05
+modified: 2024-3-7
+detection:
+    selection:
+        EventID: 1
+    condition: selection
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse rule: %v", err)
+	}
+
+	if rule.RuleStatus() != StatusExperimental {
+		t.Errorf("Expected experimental status, got %s", rule.RuleStatus())
+	}
+	if date, err := rule.CreatedAt(); err != nil || !date.Equal(time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected date %v: %v", date, err)
+	}
+	if modified, err := rule.ModifiedAt(); err != nil || !modified.Equal(time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected modified date %v: %v", modified, err)
+	}
+
+	if ParseRuleStatus("unknown") != StatusUnknown || ParseRuleStatus(" Deprecated ") != StatusDeprecated {
+		t.Error("Unexpected parsed statuses")
+	}
+	if !StatusUnsupported.Retired() || StatusTest.Retired() {
+		t.Error("Only deprecated and unsupported rules should be retired")
+	}
+	if _, err := ParseRuleDate("05.01.2024"); err == nil {
+		t.Error("Expected an error for an unsupported date format")
+	}
+}
+
+func FuzzParseRule(f *testing.F) {
+	seeds, _ := filepath.Glob(filepath.Join("..", "..", "test-rules", "*.yml"))
+	for _, path := range seeds {
+		if data, err := os.ReadFile(path); err == nil {
+			f.Add(string(data))
+		}
+	}
+	f.Add("detection:\n  condition: [selection, 1]\n")
+	f.Add("detection:\n  sel:\n    a|re: '('\n  condition: sel\n")
+	f.Fuzz(func(t *testing.T, ruleYaml string) {
+		rule, err := ParseRule(ruleYaml)
+		if err != nil {
+			return
+		}
+		_, _ = rule.Conditions()
+		_ = rule.Meta(1)
+
+		// Compilation may reject the rule but must not panic
+		_, _ = NewCompiler().CompileRule(ruleYaml)
+	})
+}
@@ -0,0 +1,286 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// SchemaIssue is a deviation of a rule from the SIGMA rule schema, located
+// in the rule source.
+type SchemaIssue struct {
+	// Source position of the offending key or value (1-based)
+	Line   int
+	Column int
+
+	// Dotted path of the field, e.g. "logsource.categroy"
+	Field string
+
+	Message string
+}
+
+func (issue SchemaIssue) String() string {
+	return fmt.Sprintf("line %d: %s: %s", issue.Line, issue.Field, issue.Message)
+}
+
+// fieldKind is the YAML shape a rule field must have
+type fieldKind int
+
+const (
+	kindScalar fieldKind = iota
+	kindScalarList
+	kindMapping
+	kindAny
+)
+
+var fieldKindNames = map[fieldKind]string{
+	kindScalar:     "a single value",
+	kindScalarList: "a list of values",
+	kindMapping:    "a mapping",
+}
+
+// ruleSchema lists the top-level fields of the SIGMA rule specification
+var ruleSchema = map[string]fieldKind{
+	"title":          kindScalar,
+	"id":             kindScalar,
+	"name":           kindScalar,
+	"taxonomy":       kindScalar,
+	"status":         kindScalar,
+	"description":    kindScalar,
+	"license":        kindScalar,
+	"author":         kindScalar,
+	"date":           kindScalar,
+	"modified":       kindScalar,
+	"level":          kindScalar,
+	"references":     kindScalarList,
+	"tags":           kindScalarList,
+	"fields":         kindScalarList,
+	"falsepositives": kindScalarList,
+	"scope":          kindScalarList,
+	"related":        kindAny,
+	"logsource":      kindMapping,
+	"detection":      kindMapping,
+	"x-tests":        kindAny,
+}
+
+// logSourceSchema lists the fields of a rule's `logsource:`
+var logSourceSchema = map[string]fieldKind{
+	"category":   kindScalar,
+	"product":    kindScalar,
+	"service":    kindScalar,
+	"definition": kindScalar,
+}
+
+// ruleTestSchema lists the fields of an `x-tests:` entry
+var ruleTestSchema = map[string]fieldKind{
+	"name":  kindScalar,
+	"match": kindScalar,
+	"event": kindMapping,
+}
+
+// relatedSchema lists the fields of a `related:` entry
+var relatedSchema = map[string]fieldKind{
+	"id":   kindScalar,
+	"type": kindScalar,
+}
+
+// ValidateRuleSchema checks a rule's YAML against the SIGMA rule schema:
+// unknown keys (with a suggestion when a known key is close, e.g.
+// `detections:` for `detection:`) and values of the wrong shape. Issues are
+// ordered by source position. It fails only when the YAML cannot be parsed.
+func ValidateRuleSchema(ruleYaml string) ([]SchemaIssue, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(ruleYaml), &document); err != nil {
+		return nil, errors.Errorf(errors.ErrorTypeYAML, "invalid rule YAML: %w", err)
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return []SchemaIssue{{Line: root.Line, Column: root.Column, Field: "rule", Message: "expected a mapping"}}, nil
+	}
+
+	var issues []SchemaIssue
+	validateMapping(root, "", ruleSchema, &issues)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "logsource":
+			if value.Kind == yaml.MappingNode {
+				validateMapping(value, "logsource.", logSourceSchema, &issues)
+			}
+		case "related":
+			validateRelated(value, &issues)
+		case "detection":
+			validateDetection(value, &issues)
+		case "x-tests":
+			validateRuleTests(value, &issues)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+	return issues, nil
+}
+
+// validateMapping reports unknown keys and wrongly shaped values of a
+// mapping with a fixed set of keys
+func validateMapping(node *yaml.Node, prefix string, schema map[string]fieldKind, issues *[]SchemaIssue) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		kind, known := schema[key.Value]
+		if !known {
+			message := "unknown field"
+			if suggestion := closestKey(key.Value, schema); suggestion != "" {
+				message = fmt.Sprintf("unknown field, did you mean %q?", suggestion)
+			}
+			*issues = append(*issues, SchemaIssue{Line: key.Line, Column: key.Column, Field: prefix + key.Value, Message: message})
+			continue
+		}
+		if !hasKind(value, kind) {
+			*issues = append(*issues, SchemaIssue{
+				Line:    value.Line,
+				Column:  value.Column,
+				Field:   prefix + key.Value,
+				Message: "expected " + fieldKindNames[kind],
+			})
+		}
+	}
+}
+
+// validateRelated checks the `related:` list entries
+func validateRelated(node *yaml.Node, issues *[]SchemaIssue) {
+	if node.Kind != yaml.SequenceNode {
+		*issues = append(*issues, SchemaIssue{Line: node.Line, Column: node.Column, Field: "related", Message: "expected a list of mappings"})
+		return
+	}
+	for i, entry := range node.Content {
+		prefix := fmt.Sprintf("related.%d.", i)
+		if entry.Kind != yaml.MappingNode {
+			*issues = append(*issues, SchemaIssue{Line: entry.Line, Column: entry.Column, Field: prefix[:len(prefix)-1], Message: "expected a mapping"})
+			continue
+		}
+		validateMapping(entry, prefix, relatedSchema, issues)
+	}
+}
+
+// validateRuleTests checks the `x-tests:` entries, which need an event and
+// whether it matches
+func validateRuleTests(node *yaml.Node, issues *[]SchemaIssue) {
+	if node.Kind != yaml.SequenceNode {
+		*issues = append(*issues, SchemaIssue{Line: node.Line, Column: node.Column, Field: "x-tests", Message: "expected a list of mappings"})
+		return
+	}
+	for i, entry := range node.Content {
+		prefix := fmt.Sprintf("x-tests.%d.", i)
+		if entry.Kind != yaml.MappingNode {
+			*issues = append(*issues, SchemaIssue{Line: entry.Line, Column: entry.Column, Field: prefix[:len(prefix)-1], Message: "expected a mapping"})
+			continue
+		}
+		validateMapping(entry, prefix, ruleTestSchema, issues)
+		for _, required := range []string{"event", "match"} {
+			if !hasKey(entry, required) {
+				*issues = append(*issues, SchemaIssue{Line: entry.Line, Column: entry.Column, Field: prefix + required, Message: "missing field"})
+			}
+		}
+	}
+}
+
+// hasKey reports whether a mapping has a key
+func hasKey(node *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+// validateDetection checks that the condition is a value or list of values
+// and every selection is a mapping or list
+func validateDetection(node *yaml.Node, issues *[]SchemaIssue) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		field := "detection." + key.Value
+		switch key.Value {
+		case "condition":
+			if !hasKind(value, kindScalar) && !hasKind(value, kindScalarList) {
+				*issues = append(*issues, SchemaIssue{Line: value.Line, Column: value.Column, Field: field, Message: "expected a condition or list of conditions"})
+			}
+		case "timeframe":
+			if !hasKind(value, kindScalar) {
+				*issues = append(*issues, SchemaIssue{Line: value.Line, Column: value.Column, Field: field, Message: "expected " + fieldKindNames[kindScalar]})
+			}
+		default:
+			if value.Kind != yaml.MappingNode && value.Kind != yaml.SequenceNode {
+				*issues = append(*issues, SchemaIssue{Line: value.Line, Column: value.Column, Field: field, Message: "expected a selection mapping or list"})
+			}
+		}
+	}
+}
+
+// hasKind reports whether a value has the given shape
+func hasKind(node *yaml.Node, kind fieldKind) bool {
+	switch kind {
+	case kindScalar:
+		return node.Kind == yaml.ScalarNode
+	case kindScalarList:
+		if node.Kind != yaml.SequenceNode {
+			return false
+		}
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return false
+			}
+		}
+		return true
+	case kindMapping:
+		return node.Kind == yaml.MappingNode
+	default:
+		return true
+	}
+}
+
+// closestKey returns the schema key within two edits of name, if any
+func closestKey(name string, schema map[string]fieldKind) string {
+	best, bestDistance := "", 3
+	for key := range schema {
+		if distance := editDistance(name, key); distance < bestDistance || (distance == bestDistance && key < best) {
+			best, bestDistance = key, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,122 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const schemaTestRule = `title: Typos
+tags: attack.execution
+logsource:
+    categroy: process_creation
+related:
+    - id: 11111111-1111-1111-1111-111111111111
+      relation: derived
+detection:
+    selection:
+        EventID: 1
+    keyword: powershell
+    condition: selection
+detections:
+    selection:
+        EventID: 2
+`
+
+func TestValidateRuleSchema(t *testing.T) {
+	issues, err := ValidateRuleSchema(schemaTestRule)
+	if err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+
+	expected := []SchemaIssue{
+		{Line: 2, Column: 7, Field: "tags", Message: "expected a list of values"},
+		{Line: 4, Column: 5, Field: "logsource.categroy", Message: `unknown field, did you mean "category"?`},
+		{Line: 7, Column: 7, Field: "related.0.relation", Message: "unknown field"},
+		{Line: 11, Column: 14, Field: "detection.keyword", Message: "expected a selection mapping or list"},
+		{Line: 13, Column: 1, Field: "detections", Message: `unknown field, did you mean "detection"?`},
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Unexpected issues:\n%v\nexpected:\n%v", issues, expected)
+	}
+	if issues[0].String() != "line 2: tags: expected a list of values" {
+		t.Errorf("Unexpected issue string: %s", issues[0])
+	}
+}
+
+func TestValidateRuleSchemaTests(t *testing.T) {
+	issues, err := ValidateRuleSchema(`title: Tested
+detection:
+    selection:
+        EventID: 1
+    condition: selection
+x-tests:
+    - name: login
+      expected: true
+      event:
+          EventID: 1
+    - EventID: 1
+`)
+	if err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+
+	expected := []SchemaIssue{
+		{Line: 7, Column: 7, Field: "x-tests.0.match", Message: "missing field"},
+		{Line: 8, Column: 7, Field: "x-tests.0.expected", Message: "unknown field"},
+		{Line: 11, Column: 7, Field: "x-tests.1.EventID", Message: "unknown field"},
+		{Line: 11, Column: 7, Field: "x-tests.1.event", Message: "missing field"},
+		{Line: 11, Column: 7, Field: "x-tests.1.match", Message: "missing field"},
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Unexpected issues:\n%v\nexpected:\n%v", issues, expected)
+	}
+}
+
+func TestValidateRuleSchemaTestRules(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "..", "test-rules", "*.yml"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("Failed to list test rules: %v", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		if issues, err := ValidateRuleSchema(string(data)); err != nil || len(issues) != 0 {
+			t.Errorf("%s: expected a valid schema, got %v, %v", filepath.Base(path), issues, err)
+		}
+	}
+}
+
+func TestCompileRuleSchemaModes(t *testing.T) {
+	// Lists in the wrong shape fail either way; unknown keys only in strict mode
+	ruleYaml := strings.Replace(schemaTestRule, "tags: attack.execution", "tags: [attack.execution]", 1)
+	if _, err := ParseRule(ruleYaml); err != nil {
+		t.Fatalf("Expected lenient parsing to ignore unknown keys: %v", err)
+	}
+	if _, err := ParseRuleStrict(ruleYaml); err == nil || !strings.Contains(err.Error(), "detections") {
+		t.Errorf("Expected strict parsing to reject unknown keys, got %v", err)
+	}
+
+	// Schema issues are compile warnings by default
+	compiler := NewCompiler()
+	if _, err := compiler.CompileRule(strings.Replace(ruleYaml, "    keyword: powershell\n", "", 1)); err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+	result, err := compiler.BuildResult()
+	if err != nil {
+		t.Fatalf("Failed to build result: %v", err)
+	}
+	if warnings := result.PerRule[0].Warnings; len(warnings) != 4 || warnings[2] != `line 12: detections: unknown field, did you mean "detection"?` {
+		t.Errorf("Expected schema warnings, got %v", warnings)
+	}
+
+	config := DefaultCompilerConfig()
+	config.StrictSchema = true
+	if _, err := NewCompilerWithConfig(config).CompileRule(ruleYaml); err == nil || !strings.Contains(err.Error(), "categroy") {
+		t.Errorf("Expected strict mode to reject schema issues, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// RuleStatus is the maturity of a SIGMA rule, from its `status:` field.
+type RuleStatus int
+
+const (
+	// StatusUnknown is a missing or unrecognized status
+	StatusUnknown RuleStatus = iota
+	StatusStable
+	StatusTest
+	StatusExperimental
+	StatusDeprecated
+	StatusUnsupported
+)
+
+var ruleStatusNames = map[RuleStatus]string{
+	StatusUnknown:      "unknown",
+	StatusStable:       "stable",
+	StatusTest:         "test",
+	StatusExperimental: "experimental",
+	StatusDeprecated:   "deprecated",
+	StatusUnsupported:  "unsupported",
+}
+
+func (status RuleStatus) String() string {
+	if name, exists := ruleStatusNames[status]; exists {
+		return name
+	}
+	return "unknown"
+}
+
+// Retired reports whether rules with this status should no longer be
+// loaded: deprecated rules are replaced or obsolete, unsupported rules
+// cannot work with common backends.
+func (status RuleStatus) Retired() bool {
+	return status == StatusDeprecated || status == StatusUnsupported
+}
+
+// ParseRuleStatus parses a SIGMA status name (case-insensitive);
+// unrecognized names map to StatusUnknown.
+func ParseRuleStatus(name string) RuleStatus {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for status, statusName := range ruleStatusNames {
+		if statusName == name && status != StatusUnknown {
+			return status
+		}
+	}
+	return StatusUnknown
+}
+
+// ruleDateFormats are the date layouts accepted in `date:` and `modified:`.
+// The SIGMA specification uses YYYY-MM-DD; older rules use YYYY/MM/DD, and
+// some omit leading zeros.
+var ruleDateFormats = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"2006-1-2",
+	"2006/1/2",
+	"2006.01.02",
+	time.RFC3339,
+}
+
+// ParseRuleDate parses a rule date in any of the accepted formats.
+func ParseRuleDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range ruleDateFormats {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, errors.Errorf(errors.ErrorTypeCompilation, "invalid date %q", value)
+}
+
+// RuleStatus returns the rule's parsed status.
+func (r *SigmaRule) RuleStatus() RuleStatus {
+	return ParseRuleStatus(r.Status)
+}
+
+// CreatedAt returns the rule's parsed `date:`, or the zero time when the
+// rule has none.
+func (r *SigmaRule) CreatedAt() (time.Time, error) {
+	if strings.TrimSpace(r.Date) == "" {
+		return time.Time{}, nil
+	}
+	return ParseRuleDate(r.Date)
+}
+
+// ModifiedAt returns the rule's parsed `modified:`, or the zero time when
+// the rule has none.
+func (r *SigmaRule) ModifiedAt() (time.Time, error) {
+	if strings.TrimSpace(r.Modified) == "" {
+		return time.Time{}, nil
+	}
+	return ParseRuleDate(r.Modified)
+}
+
+// statusWarnings returns warnings for an unrecognized status and for dates
+// that cannot be parsed
+func statusWarnings(rule *SigmaRule) []string {
+	var warnings []string
+	if strings.TrimSpace(rule.Status) != "" && rule.RuleStatus() == StatusUnknown {
+		warnings = append(warnings, fmt.Sprintf("unknown status %q", rule.Status))
+	}
+	if _, err := rule.CreatedAt(); err != nil {
+		warnings = append(warnings, fmt.Sprintf("date: %v", err))
+	}
+	if _, err := rule.ModifiedAt(); err != nil {
+		warnings = append(warnings, fmt.Sprintf("modified: %v", err))
+	}
+	return warnings
+}
@@ -0,0 +1,444 @@
+// Package config loads engine and compiler options from YAML or JSON
+// files, so deployments can be configured without code changes. Files are
+// validated in full when loaded: unknown keys, out-of-range values and
+// unreadable field mapping files fail LoadConfig rather than the engine
+// build.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/compiler"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// Config is a loaded configuration, with every option the file leaves out
+// at its default.
+type Config struct {
+	Engine   dag.DagEngineConfig
+	Compiler compiler.CompilerConfig
+
+	// Field mapping from the file's inline mappings and mapping files
+	FieldMapping *compiler.FieldMapping
+}
+
+// Default returns the configuration of an empty file
+func Default() *Config {
+	return &Config{
+		Engine:       dag.DefaultDagEngineConfig(),
+		Compiler:     compiler.DefaultCompilerConfig(),
+		FieldMapping: compiler.NewFieldMapping(),
+	}
+}
+
+// NewCompiler creates a compiler with the configured options and field
+// mapping
+func (c *Config) NewCompiler() *compiler.Compiler {
+	return compiler.NewCompilerWithConfig(c.Compiler).WithFieldMapping(c.FieldMapping)
+}
+
+// fileConfig is the layout of a configuration file. Options are pointers
+// so options left out keep their defaults.
+type fileConfig struct {
+	Engine       *engineFile       `yaml:"engine" json:"engine"`
+	Parallel     *parallelFile     `yaml:"parallel" json:"parallel"`
+	Compiler     *compilerFile     `yaml:"compiler" json:"compiler"`
+	FieldMapping *fieldMappingFile `yaml:"field_mapping" json:"field_mapping"`
+}
+
+type engineFile struct {
+	Optimization      *bool    `yaml:"optimization" json:"optimization"`
+	OptimizationLevel *int     `yaml:"optimization_level" json:"optimization_level"`
+	Prefilter         *bool    `yaml:"prefilter" json:"prefilter"`
+	PrefilterStrategy *string  `yaml:"prefilter_strategy" json:"prefilter_strategy"`
+	RawPrefilter      *bool    `yaml:"raw_prefilter" json:"raw_prefilter"`
+	RuleGrouping      *bool    `yaml:"rule_grouping" json:"rule_grouping"`
+	MatchDetails      *bool    `yaml:"match_details" json:"match_details"`
+	CaptureFields     *bool    `yaml:"capture_fields" json:"capture_fields"`
+	MinLevel          *string  `yaml:"min_level" json:"min_level"`
+	Levels            []string `yaml:"levels" json:"levels"`
+	MemoryBudgetBytes *int     `yaml:"memory_budget_bytes" json:"memory_budget_bytes"`
+	MaxRuleComplexity *int     `yaml:"max_rule_complexity" json:"max_rule_complexity"`
+	ComplexityPolicy  *string  `yaml:"complexity_policy" json:"complexity_policy"`
+	LenientModifiers  *bool    `yaml:"lenient_modifiers" json:"lenient_modifiers"`
+	EventFlattening   *string  `yaml:"event_flattening" json:"event_flattening"`
+	Backend           *string  `yaml:"backend" json:"backend"`
+	EventTimeout      *string  `yaml:"event_timeout" json:"event_timeout"`
+	ErrorPolicy       *string  `yaml:"error_policy" json:"error_policy"`
+	ActionConcurrency *int     `yaml:"action_concurrency" json:"action_concurrency"`
+	ActionQueueSize   *int     `yaml:"action_queue_size" json:"action_queue_size"`
+	ActionTimeout     *string  `yaml:"action_timeout" json:"action_timeout"`
+
+	SampleRate  *int           `yaml:"sample_rate" json:"sample_rate"`
+	SampleField *string        `yaml:"sample_field" json:"sample_field"`
+	SampleRates map[string]int `yaml:"sample_rates" json:"sample_rates"`
+	RateLimit   *float64       `yaml:"rate_limit" json:"rate_limit"`
+	RateBurst   *int           `yaml:"rate_burst" json:"rate_burst"`
+
+	DeadLetterThreshold *int `yaml:"dead_letter_threshold" json:"dead_letter_threshold"`
+
+	CaseInsensitiveFields *bool `yaml:"case_insensitive_fields" json:"case_insensitive_fields"`
+}
+
+type parallelFile struct {
+	Enabled           *bool `yaml:"enabled" json:"enabled"`
+	NumThreads        *int  `yaml:"num_threads" json:"num_threads"`
+	MinRulesPerThread *int  `yaml:"min_rules_per_thread" json:"min_rules_per_thread"`
+	EventParallelism  *bool `yaml:"event_parallelism" json:"event_parallelism"`
+	MinBatchSize      *int  `yaml:"min_batch_size" json:"min_batch_size"`
+	AutoTune          *bool `yaml:"auto_tune" json:"auto_tune"`
+}
+
+type compilerFile struct {
+	Debug               *bool `yaml:"debug" json:"debug"`
+	LenientModifiers    *bool `yaml:"lenient_modifiers" json:"lenient_modifiers"`
+	TolerateRuleErrors  *bool `yaml:"tolerate_rule_errors" json:"tolerate_rule_errors"`
+	StrictSchema        *bool `yaml:"strict_schema" json:"strict_schema"`
+	IncludeRetiredRules *bool `yaml:"include_retired_rules" json:"include_retired_rules"`
+	MaxConditionDepth   *int  `yaml:"max_condition_depth" json:"max_condition_depth"`
+	MaxConditionTokens  *int  `yaml:"max_condition_tokens" json:"max_condition_tokens"`
+	InternStrings       *bool `yaml:"intern_strings" json:"intern_strings"`
+	ExpandFieldPatterns *bool `yaml:"expand_field_patterns" json:"expand_field_patterns"`
+
+	// allow, warn or fail
+	UnmappedFieldPolicy *string `yaml:"unmapped_field_policy" json:"unmapped_field_policy"`
+}
+
+type fieldMappingFile struct {
+	Taxonomy *string `yaml:"taxonomy" json:"taxonomy"`
+
+	// Mapping files (YAML or JSON objects of source to target field),
+	// relative to the configuration file. Inline mappings are applied
+	// after them and take precedence.
+	Files    []string          `yaml:"files" json:"files"`
+	Mappings map[string]string `yaml:"mappings" json:"mappings"`
+
+	// Field name transformations of the taxonomy, in pipeline order
+	Transformations []fieldTransformationFile `yaml:"transformations" json:"transformations"`
+
+	// Event field paths tried in order at evaluation time when an event
+	// lacks the (mapped) field
+	Aliases map[string][]string `yaml:"aliases" json:"aliases"`
+}
+
+type fieldTransformationFile struct {
+	Stage       string `yaml:"stage" json:"stage"`
+	Pattern     string `yaml:"pattern" json:"pattern"`
+	Replacement string `yaml:"replacement" json:"replacement"`
+}
+
+// LoadConfig loads a configuration file. Files ending in .json are read as
+// JSON, others as YAML.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WrapIOError(err)
+	}
+
+	var file fileConfig
+	if err := decodeStrict(path, data, &file); err != nil {
+		return nil, errors.Wrap(errors.ErrorTypeConfig, fmt.Sprintf("%s: %v", path, err), err)
+	}
+	config, err := file.resolve(filepath.Dir(path))
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrorTypeConfig, fmt.Sprintf("%s: %v", path, err), err)
+	}
+	return config, nil
+}
+
+// decodeStrict decodes JSON or YAML by file extension, rejecting unknown
+// keys
+func decodeStrict(path string, data []byte, target interface{}) error {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(target)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(target); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// resolve applies the file's options over the defaults. Field mapping
+// files are relative to dir.
+func (file *fileConfig) resolve(dir string) (*Config, error) {
+	config := Default()
+	if err := file.Engine.apply(&config.Engine); err != nil {
+		return nil, fmt.Errorf("engine: %w", err)
+	}
+	if err := file.Parallel.apply(&config.Engine); err != nil {
+		return nil, fmt.Errorf("parallel: %w", err)
+	}
+	if err := file.Compiler.apply(&config.Compiler); err != nil {
+		return nil, fmt.Errorf("compiler: %w", err)
+	}
+	if err := file.FieldMapping.apply(config.FieldMapping, dir); err != nil {
+		return nil, fmt.Errorf("field_mapping: %w", err)
+	}
+	return config, nil
+}
+
+func (file *engineFile) apply(config *dag.DagEngineConfig) error {
+	if file == nil {
+		return nil
+	}
+	setBool(&config.EnableOptimization, file.Optimization)
+	if file.OptimizationLevel != nil {
+		if *file.OptimizationLevel < 0 || *file.OptimizationLevel > 3 {
+			return fmt.Errorf("optimization_level %d is not between 0 and 3", *file.OptimizationLevel)
+		}
+		config.OptimizationLevel = uint8(*file.OptimizationLevel)
+	}
+	setBool(&config.EnablePrefilter, file.Prefilter)
+	if file.PrefilterStrategy != nil {
+		strategy, err := parseName("prefilter_strategy", *file.PrefilterStrategy,
+			dag.PrefilterAuto, dag.PrefilterNone, dag.PrefilterHashSet, dag.PrefilterAhoCorasick, dag.PrefilterBloom)
+		if err != nil {
+			return err
+		}
+		config.PrefilterStrategy = strategy
+	}
+	setBool(&config.EnableRawPrefilter, file.RawPrefilter)
+	setBool(&config.EnableRuleGrouping, file.RuleGrouping)
+	setBool(&config.CollectMatchDetails, file.MatchDetails)
+	setBool(&config.CaptureRuleFields, file.CaptureFields)
+	setBool(&config.CaseInsensitiveFields, file.CaseInsensitiveFields)
+	if file.MinLevel != nil {
+		level, err := parseLevel(*file.MinLevel)
+		if err != nil {
+			return fmt.Errorf("min_level: %w", err)
+		}
+		config.MinRuleLevel = level
+	}
+	for _, name := range file.Levels {
+		level, err := parseLevel(name)
+		if err != nil {
+			return fmt.Errorf("levels: %w", err)
+		}
+		config.RuleLevels = append(config.RuleLevels, level)
+	}
+	if err := setNonNegative(&config.MemoryBudgetBytes, file.MemoryBudgetBytes, "memory_budget_bytes"); err != nil {
+		return err
+	}
+	if err := setNonNegative(&config.MaxRuleComplexity, file.MaxRuleComplexity, "max_rule_complexity"); err != nil {
+		return err
+	}
+	if file.ComplexityPolicy != nil {
+		policy, err := parseName("complexity_policy", *file.ComplexityPolicy,
+			dag.ComplexityWarn, dag.ComplexityReject, dag.ComplexityDisable)
+		if err != nil {
+			return err
+		}
+		config.ComplexityPolicy = policy
+	}
+	setBool(&config.LenientModifiers, file.LenientModifiers)
+	if file.EventFlattening != nil {
+		mode, err := parseName("event_flattening", *file.EventFlattening,
+			dag.FlattenOff, dag.FlattenAlways, dag.FlattenAuto)
+		if err != nil {
+			return err
+		}
+		config.EventFlattening = mode
+	}
+	if file.Backend != nil {
+		backend, err := parseName("backend", *file.Backend,
+			dag.BackendDAG, dag.BackendVM, dag.BackendInterpreter)
+		if err != nil {
+			return err
+		}
+		config.Backend = backend
+	}
+	if file.ErrorPolicy != nil {
+		policy, err := parseName("error_policy", *file.ErrorPolicy, dag.ErrorFailFast, dag.ErrorNoMatch)
+		if err != nil {
+			return err
+		}
+		config.PrimitiveErrorPolicy = policy
+	}
+	if err := setNonNegative(&config.DeadLetterThreshold, file.DeadLetterThreshold, "dead_letter_threshold"); err != nil {
+		return err
+	}
+	if file.EventTimeout != nil {
+		timeout, err := time.ParseDuration(*file.EventTimeout)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("event_timeout %q is not a non-negative duration", *file.EventTimeout)
+		}
+		config.EventTimeout = timeout
+	}
+	if err := setNonNegative(&config.ActionConcurrency, file.ActionConcurrency, "action_concurrency"); err != nil {
+		return err
+	}
+	if err := setNonNegative(&config.ActionQueueSize, file.ActionQueueSize, "action_queue_size"); err != nil {
+		return err
+	}
+	if file.ActionTimeout != nil {
+		timeout, err := time.ParseDuration(*file.ActionTimeout)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("action_timeout %q is not a non-negative duration", *file.ActionTimeout)
+		}
+		config.ActionTimeout = timeout
+	}
+	if err := setNonNegative(&config.SampleRate, file.SampleRate, "sample_rate"); err != nil {
+		return err
+	}
+	if file.SampleField != nil {
+		config.SampleField = *file.SampleField
+	}
+	for value, rate := range file.SampleRates {
+		if rate < 0 {
+			return fmt.Errorf("sample_rates: rate %d of %q is negative", rate, value)
+		}
+	}
+	if file.SampleRates != nil {
+		config.SampleRates = file.SampleRates
+	}
+	if file.RateLimit != nil {
+		if *file.RateLimit < 0 {
+			return fmt.Errorf("rate_limit %g is negative", *file.RateLimit)
+		}
+		config.RateLimit = *file.RateLimit
+	}
+	if err := setNonNegative(&config.RateBurst, file.RateBurst, "rate_burst"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (file *parallelFile) apply(config *dag.DagEngineConfig) error {
+	if file == nil {
+		return nil
+	}
+	setBool(&config.EnableParallelProcessing, file.Enabled)
+	parallel := &config.ParallelConfig
+	if err := setNonNegative(&parallel.NumThreads, file.NumThreads, "num_threads"); err != nil {
+		return err
+	}
+	if err := setNonNegative(&parallel.MinRulesPerThread, file.MinRulesPerThread, "min_rules_per_thread"); err != nil {
+		return err
+	}
+	setBool(&parallel.EnableEventParallelism, file.EventParallelism)
+	if err := setNonNegative(&parallel.MinBatchSizeForParallelism, file.MinBatchSize, "min_batch_size"); err != nil {
+		return err
+	}
+	setBool(&parallel.AutoTune, file.AutoTune)
+	return nil
+}
+
+func (file *compilerFile) apply(config *compiler.CompilerConfig) error {
+	if file == nil {
+		return nil
+	}
+	setBool(&config.Debug, file.Debug)
+	setBool(&config.LenientModifiers, file.LenientModifiers)
+	setBool(&config.TolerateRuleErrors, file.TolerateRuleErrors)
+	setBool(&config.StrictSchema, file.StrictSchema)
+	setBool(&config.IncludeRetiredRules, file.IncludeRetiredRules)
+	if err := setNonNegative(&config.MaxConditionDepth, file.MaxConditionDepth, "max_condition_depth"); err != nil {
+		return err
+	}
+	if err := setNonNegative(&config.MaxConditionTokens, file.MaxConditionTokens, "max_condition_tokens"); err != nil {
+		return err
+	}
+	setBool(&config.InternStrings, file.InternStrings)
+	setBool(&config.ExpandFieldPatterns, file.ExpandFieldPatterns)
+	if file.UnmappedFieldPolicy != nil {
+		policy, err := parseName("unmapped_field_policy", *file.UnmappedFieldPolicy,
+			compiler.UnmappedFieldAllow, compiler.UnmappedFieldWarn, compiler.UnmappedFieldFail)
+		if err != nil {
+			return err
+		}
+		config.UnmappedFieldPolicy = policy
+	}
+	return nil
+}
+
+func (file *fieldMappingFile) apply(mapping *compiler.FieldMapping, dir string) error {
+	if file == nil {
+		return nil
+	}
+	if file.Taxonomy != nil {
+		mapping.SetTaxonomy(*file.Taxonomy)
+	}
+	for _, path := range file.Files {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var mappings map[string]string
+		if err := decodeStrict(path, data, &mappings); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		mapping.LoadTaxonomyMappings(mappings)
+	}
+	mapping.LoadTaxonomyMappings(file.Mappings)
+
+	for i, transformation := range file.Transformations {
+		stage, err := parseName("field transformation stage", transformation.Stage,
+			compiler.FieldTransformNormalize, compiler.FieldTransformFallback)
+		if err != nil {
+			return fmt.Errorf("transformation %d: %w", i, err)
+		}
+		if err := mapping.AddTransformation(stage, transformation.Pattern, transformation.Replacement); err != nil {
+			return fmt.Errorf("transformation %d: %w", i, err)
+		}
+	}
+	for field, aliases := range file.Aliases {
+		mapping.AddFieldAliases(field, aliases...)
+	}
+	return nil
+}
+
+// parseLevel parses a rule level name, rejecting unknown names
+func parseLevel(name string) (dag.RuleLevel, error) {
+	level := dag.ParseRuleLevel(name)
+	if level == dag.LevelUnknown {
+		return level, fmt.Errorf("unknown rule level %q", name)
+	}
+	return level, nil
+}
+
+// parseName returns the value whose name is name (case-insensitive)
+func parseName[T fmt.Stringer](option, name string, values ...T) (T, error) {
+	names := make([]string, len(values))
+	for i, value := range values {
+		if strings.EqualFold(value.String(), name) {
+			return value, nil
+		}
+		names[i] = value.String()
+	}
+	var zero T
+	return zero, fmt.Errorf("%s %q is not one of %s", option, name, strings.Join(names, ", "))
+}
+
+func setBool(target *bool, value *bool) {
+	if value != nil {
+		*target = *value
+	}
+}
+
+func setNonNegative(target *int, value *int, option string) error {
+	if value == nil {
+		return nil
+	}
+	if *value < 0 {
+		return fmt.Errorf("%s %d is negative", option, *value)
+	}
+	*target = *value
+	return nil
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/compiler"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "mapping.yml", "Image: process.executable\nUser: user.name\n")
+	path := writeFile(t, dir, "engine.yml", `
+engine:
+  optimization_level: 3
+  prefilter: true
+  prefilter_strategy: aho_corasick
+  raw_prefilter: true
+  rule_grouping: true
+  min_level: high
+  complexity_policy: disable
+  event_flattening: auto
+  backend: vm
+  event_timeout: 50ms
+  case_insensitive_fields: true
+  error_policy: no_match
+  dead_letter_threshold: 3
+  action_concurrency: 2
+  action_timeout: 5s
+  sample_field: Channel
+  sample_rates:
+    Security: 10
+  rate_limit: 5000
+parallel:
+  enabled: true
+  num_threads: 4
+compiler:
+  tolerate_rule_errors: true
+  intern_strings: false
+  unmapped_field_policy: warn
+  expand_field_patterns: true
+field_mapping:
+  taxonomy: ecs
+  files: [mapping.yml]
+  mappings:
+    User: winlog.user.name
+  transformations:
+    - stage: fallback
+      pattern: '(.+)'
+      replacement: winlog.event_data.$1
+  aliases:
+    process.executable: [winlog.event_data.Image, Image]
+`)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	engine := config.Engine
+	if engine.OptimizationLevel != 3 || !engine.EnablePrefilter || engine.PrefilterStrategy != dag.PrefilterAhoCorasick || !engine.EnableRawPrefilter || !engine.EnableRuleGrouping ||
+		engine.MinRuleLevel != dag.LevelHigh ||
+		engine.ComplexityPolicy != dag.ComplexityDisable || engine.EventFlattening != dag.FlattenAuto ||
+		engine.Backend != dag.BackendVM || engine.EventTimeout != 50*time.Millisecond || !engine.CaseInsensitiveFields || engine.PrimitiveErrorPolicy != dag.ErrorNoMatch || engine.DeadLetterThreshold != 3 ||
+		engine.ActionConcurrency != 2 || engine.ActionTimeout != 5*time.Second ||
+		engine.SampleField != "Channel" || engine.SampleRates["Security"] != 10 || engine.RateLimit != 5000 {
+		t.Errorf("Unexpected engine config: %+v", engine)
+	}
+	// Options left out keep their defaults
+	if !engine.EnableOptimization || engine.CollectMatchDetails {
+		t.Errorf("Expected default engine options to be kept: %+v", engine)
+	}
+	if !engine.EnableParallelProcessing || engine.ParallelConfig.NumThreads != 4 ||
+		engine.ParallelConfig.MinRulesPerThread != dag.DefaultParallelConfig().MinRulesPerThread {
+		t.Errorf("Unexpected parallel config: %+v", engine.ParallelConfig)
+	}
+	if !config.Compiler.TolerateRuleErrors || config.Compiler.InternStrings || config.Compiler.UnmappedFieldPolicy != compiler.UnmappedFieldWarn || !config.Compiler.ExpandFieldPatterns {
+		t.Errorf("Unexpected compiler config: %+v", config.Compiler)
+	}
+
+	expected := map[string]string{"Image": "process.executable", "User": "winlog.user.name"}
+	if mapping := config.FieldMapping; mapping.Taxonomy() != "ecs" || !reflect.DeepEqual(mapping.Mappings(), expected) {
+		t.Errorf("Unexpected field mapping %s: %v", mapping.Taxonomy(), mapping.Mappings())
+	}
+	if mapped := config.FieldMapping.NormalizeField("LogonType"); mapped != "winlog.event_data.LogonType" {
+		t.Errorf("Expected the fallback transformation to apply, got %s", mapped)
+	}
+	if aliases := config.FieldMapping.FieldAliases("process.executable"); !reflect.DeepEqual(aliases, []string{"winlog.event_data.Image", "Image"}) {
+		t.Errorf("Expected the field aliases in order, got %v", aliases)
+	}
+	if config.NewCompiler().FieldMapping() != config.FieldMapping {
+		t.Error("Expected the compiler to use the configured field mapping")
+	}
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "engine.json", `{"engine": {"levels": ["high", "critical"]}, "compiler": {"strict_schema": true}}`)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !reflect.DeepEqual(config.Engine.RuleLevels, []dag.RuleLevel{dag.LevelHigh, dag.LevelCritical}) || !config.Compiler.StrictSchema {
+		t.Errorf("Unexpected config: %+v %+v", config.Engine, config.Compiler)
+	}
+}
+
+func TestLoadConfigEmpty(t *testing.T) {
+	config, err := LoadConfig(writeFile(t, t.TempDir(), "empty.yml", ""))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if defaults := Default(); !reflect.DeepEqual(config, defaults) {
+		t.Errorf("Expected the default config, got %+v", config)
+	}
+}
+
+func TestLoadConfigRejectsInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		message string
+	}{
+		{"unknown key", "a.yml", "engine:\n  prefilterr: true\n", "prefilterr"},
+		{"unknown JSON key", "a.json", `{"compiler": {"strict": true}}`, "strict"},
+		{"level out of range", "a.yml", "engine:\n  optimization_level: 5\n", "optimization_level 5"},
+		{"unknown rule level", "a.yml", "engine:\n  min_level: severe\n", "severe"},
+		{"unknown backend", "a.yml", "engine:\n  backend: gpu\n", "dag, vm, interpreter"},
+		{"unknown error policy", "a.yml", "engine:\n  error_policy: ignore\n", "fail_fast, no_match"},
+		{"invalid timeout", "a.yml", "engine:\n  event_timeout: soon\n", "event_timeout"},
+		{"negative rate limit", "a.yml", "engine:\n  rate_limit: -1\n", "rate_limit -1"},
+		{"negative action timeout", "a.yml", "engine:\n  action_timeout: -1s\n", "action_timeout"},
+		{"negative threads", "a.yml", "parallel:\n  num_threads: -1\n", "num_threads -1"},
+		{"missing mapping file", "a.yml", "field_mapping:\n  files: [missing.yml]\n", "missing.yml"},
+		{"unknown transformation stage", "a.yml", "field_mapping:\n  transformations:\n    - {stage: before, pattern: x}\n", "normalize, fallback"},
+		{"unknown unmapped field policy", "a.yml", "compiler:\n  unmapped_field_policy: drop\n", "allow, warn, fail"},
+		{"invalid transformation pattern", "a.yml", "field_mapping:\n  transformations:\n    - {stage: normalize, pattern: '('}\n", "transformation 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeFile(t, t.TempDir(), tt.file, tt.content))
+			if !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeConfig) || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected a config error mentioning %q, got %v", tt.message, err)
+			}
+		})
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "absent.yml")); !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeIO) {
+		t.Errorf("Expected an IO error for a missing file, got %v", err)
+	}
+}
@@ -0,0 +1,349 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+// Defaults of the action workers
+const (
+	defaultActionConcurrency = 4
+	defaultActionQueueSize   = 1024
+)
+
+// MatchAction runs after an event matches a rule, e.g. to tag the match,
+// suppress repeated alerts, count matches or notify a webhook. Actions run
+// on the engine's action workers once the evaluation that produced the
+// match has returned its result, so a slow or failing action never delays,
+// fails or drops a match.
+type MatchAction interface {
+	// Name identifies the action in statistics and logs
+	Name() string
+
+	// Execute runs the action for a match. Errors are logged and counted;
+	// the match's later actions still run.
+	Execute(ctx context.Context, match *ActionMatch) error
+}
+
+// NewMatchAction creates an action from a function
+func NewMatchAction(name string, execute func(ctx context.Context, match *ActionMatch) error) MatchAction {
+	return &funcAction{name: name, execute: execute}
+}
+
+type funcAction struct {
+	name    string
+	execute func(ctx context.Context, match *ActionMatch) error
+}
+
+func (a *funcAction) Name() string {
+	return a.name
+}
+
+func (a *funcAction) Execute(ctx context.Context, match *ActionMatch) error {
+	return a.execute(ctx, match)
+}
+
+// ActionMatch is a rule match passed through the actions of the rule. The
+// actions of a match run in order on one worker, so an action sees the tags
+// added by the actions before it.
+type ActionMatch struct {
+	// Event as passed to the engine. Actions of other matches of the event
+	// run concurrently, so actions must not modify it.
+	Event interface{}
+
+	// Metadata of the matched rule
+	Rule RuleMeta
+
+	// Match details of the rule (nil unless match details or field
+	// capture are enabled)
+	Details *RuleMatch
+
+	// Tags added by the match's actions so far
+	Tags []string
+}
+
+// AddTag tags the match, once per tag
+func (m *ActionMatch) AddTag(tag string) {
+	for _, existing := range m.Tags {
+		if existing == tag {
+			return
+		}
+	}
+	m.Tags = append(m.Tags, tag)
+}
+
+// ActionStats reports post-match action activity
+type ActionStats struct {
+	// Matches handed to the action workers, and matches whose actions
+	// have not all run yet
+	Queued  uint64
+	Pending int
+
+	// Action runs and failed runs (errors, panics and timeouts) per action
+	// name
+	Executed map[string]uint64
+	Failed   map[string]uint64
+}
+
+// WithAction adds an action run for every match, before the actions of the
+// matched rule
+func (b *DagEngineBuilder) WithAction(action MatchAction) *DagEngineBuilder {
+	b.config.Actions = append(b.config.Actions, action)
+	return b
+}
+
+// WithRuleAction adds an action run for the matches of the rule with the
+// given SIGMA UUID
+func (b *DagEngineBuilder) WithRuleAction(ruleUUID string, action MatchAction) *DagEngineBuilder {
+	if b.config.RuleActions == nil {
+		b.config.RuleActions = make(map[string][]MatchAction)
+	}
+	b.config.RuleActions[ruleUUID] = append(b.config.RuleActions[ruleUUID], action)
+	return b
+}
+
+// WithActionConcurrency sets the number of matches whose actions run at
+// once
+func (b *DagEngineBuilder) WithActionConcurrency(workers int) *DagEngineBuilder {
+	b.config.ActionConcurrency = workers
+	return b
+}
+
+// WithActionTimeout bounds each action run
+func (b *DagEngineBuilder) WithActionTimeout(timeout time.Duration) *DagEngineBuilder {
+	b.config.ActionTimeout = timeout
+	return b
+}
+
+// actionJob is a match with the actions to run for it
+type actionJob struct {
+	match   *ActionMatch
+	actions []MatchAction
+}
+
+// actionDispatcher runs the actions of matches on a fixed pool of workers
+// fed by a bounded queue. Jobs are queued under the engine lock, which also
+// guards closed.
+type actionDispatcher struct {
+	global  []MatchAction
+	byRule  map[ir.RuleID][]MatchAction
+	timeout time.Duration
+	logger  *slog.Logger
+
+	queue   chan actionJob
+	workers sync.WaitGroup
+	closed  bool
+
+	// Counters and the number of queued jobs not done yet, signalled on
+	// idle when it drops to zero
+	mu       sync.Mutex
+	idle     *sync.Cond
+	pending  int
+	queued   uint64
+	executed map[string]uint64
+	failed   map[string]uint64
+}
+
+// newActionDispatcher starts the action workers, or returns nil when no
+// action is configured. Rule actions are keyed by SIGMA UUID; actions of
+// rules missing from the ruleset (e.g. removed by a rule filter) are
+// logged and never run.
+func newActionDispatcher(config DagEngineConfig, ruleUUIDs map[string]ir.RuleID, logger *slog.Logger) *actionDispatcher {
+	if len(config.Actions) == 0 && len(config.RuleActions) == 0 {
+		return nil
+	}
+
+	d := &actionDispatcher{
+		global:   config.Actions,
+		byRule:   make(map[ir.RuleID][]MatchAction, len(config.RuleActions)),
+		timeout:  config.ActionTimeout,
+		logger:   logger,
+		executed: make(map[string]uint64),
+		failed:   make(map[string]uint64),
+	}
+	d.idle = sync.NewCond(&d.mu)
+	for uuid, actions := range config.RuleActions {
+		ruleId, exists := ruleUUIDs[uuid]
+		if !exists {
+			logger.Warn("actions configured for an unknown rule", slog.String("rule_uuid", uuid))
+			continue
+		}
+		d.byRule[ruleId] = append(d.byRule[ruleId], actions...)
+	}
+
+	workers := config.ActionConcurrency
+	if workers <= 0 {
+		workers = defaultActionConcurrency
+	}
+	queueSize := config.ActionQueueSize
+	if queueSize <= 0 {
+		queueSize = defaultActionQueueSize
+	}
+	d.queue = make(chan actionJob, queueSize)
+	d.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// dispatch queues the actions of the matches in evaluation results. Queuing
+// blocks while the queue is full, so evaluation slows to the pace of the
+// actions rather than dropping them.
+func (d *actionDispatcher) dispatch(rules map[ir.RuleID]RuleMeta, events []interface{}, results []*DagEvaluationResult) {
+	if d.closed {
+		return
+	}
+	for i, result := range results {
+		if result == nil {
+			continue
+		}
+		for _, ruleId := range result.MatchedRules {
+			ruleActions := d.byRule[ruleId]
+			if len(d.global) == 0 && len(ruleActions) == 0 {
+				continue
+			}
+			actions := d.global
+			if len(ruleActions) > 0 {
+				actions = append(append(make([]MatchAction, 0, len(d.global)+len(ruleActions)), d.global...), ruleActions...)
+			}
+
+			match := &ActionMatch{Event: events[i], Rule: rules[ruleId]}
+			// Rulesets built without metadata still identify the rule
+			match.Rule.ID = ruleId
+			for j := range result.RuleMatches {
+				if result.RuleMatches[j].RuleID == ruleId {
+					// Copied, since results may be reused for the next event
+					details := result.RuleMatches[j]
+					match.Details = &details
+					break
+				}
+			}
+
+			d.mu.Lock()
+			d.pending++
+			d.queued++
+			d.mu.Unlock()
+			d.queue <- actionJob{match: match, actions: actions}
+		}
+	}
+}
+
+// work runs queued jobs until the queue is closed
+func (d *actionDispatcher) work() {
+	defer d.workers.Done()
+	for job := range d.queue {
+		for _, action := range job.actions {
+			err := d.run(action, job.match)
+			d.mu.Lock()
+			d.executed[action.Name()]++
+			if err != nil {
+				d.failed[action.Name()]++
+			}
+			d.mu.Unlock()
+			if err != nil {
+				d.logger.Warn("match action failed",
+					slog.String("action", action.Name()),
+					slog.Any("rule_id", job.match.Rule.ID),
+					slog.Any("error", err))
+			}
+		}
+
+		d.mu.Lock()
+		d.pending--
+		if d.pending == 0 {
+			d.idle.Broadcast()
+		}
+		d.mu.Unlock()
+	}
+}
+
+// run executes an action, turning panics into errors
+func (d *actionDispatcher) run(action MatchAction, match *ActionMatch) (err error) {
+	ctx := context.Background()
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("action %s panicked: %v", action.Name(), r)
+		}
+	}()
+	return action.Execute(ctx, match)
+}
+
+// wait blocks until every queued job has run
+func (d *actionDispatcher) wait() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for d.pending > 0 {
+		d.idle.Wait()
+	}
+}
+
+// stats returns a snapshot of the counters
+func (d *actionDispatcher) stats() ActionStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats := ActionStats{
+		Queued:   d.queued,
+		Pending:  d.pending,
+		Executed: make(map[string]uint64, len(d.executed)),
+		Failed:   make(map[string]uint64, len(d.failed)),
+	}
+	for name, count := range d.executed {
+		stats.Executed[name] = count
+	}
+	for name, count := range d.failed {
+		stats.Failed[name] = count
+	}
+	return stats
+}
+
+// dispatchActions queues the actions of matched rules. Callers hold the
+// engine lock.
+func (e *DagEngine) dispatchActions(events []interface{}, results []*DagEvaluationResult) {
+	if e.actions != nil {
+		e.actions.dispatch(e.rules, events, results)
+	}
+}
+
+// FlushActions blocks until the actions of every match so far have run
+func (e *DagEngine) FlushActions() {
+	if e.actions != nil {
+		e.actions.wait()
+	}
+}
+
+// Close waits for the queued actions to run and stops the action workers.
+// Matches of later evaluations run no actions. Engines without actions
+// need no Close.
+func (e *DagEngine) Close() {
+	if e.actions == nil {
+		return
+	}
+	e.mu.Lock()
+	if !e.actions.closed {
+		e.actions.closed = true
+		close(e.actions.queue)
+	}
+	e.mu.Unlock()
+	e.actions.workers.Wait()
+}
+
+// ActionStats returns post-match action statistics, or nil when the engine
+// has no actions
+func (e *DagEngine) ActionStats() *ActionStats {
+	if e.actions == nil {
+		return nil
+	}
+	stats := e.actions.stats()
+	return &stats
+}
@@ -0,0 +1,114 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+func TestDagEngineActions(t *testing.T) {
+	ruleset := createRawPrefilterRuleset()
+	ruleset.Rules = []RuleMeta{
+		{ID: 1, SigmaID: "rule-one", Title: "Encoded PowerShell"},
+		{ID: 2, SigmaID: "rule-two", Title: "Credential dumping"},
+	}
+
+	var mu sync.Mutex
+	counts := make(map[ir.RuleID]int)
+	var tagged []string
+	count := NewMatchAction("count", func(ctx context.Context, match *ActionMatch) error {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[match.Rule.ID]++
+		return nil
+	})
+	tag := NewMatchAction("tag", func(ctx context.Context, match *ActionMatch) error {
+		match.AddTag("credential-access")
+		return nil
+	})
+	failing := NewMatchAction("failing", func(ctx context.Context, match *ActionMatch) error {
+		return errors.New("webhook unavailable")
+	})
+	panicking := NewMatchAction("panicking", func(ctx context.Context, match *ActionMatch) error {
+		panic("broken action")
+	})
+	record := NewMatchAction("record", func(ctx context.Context, match *ActionMatch) error {
+		mu.Lock()
+		defer mu.Unlock()
+		tagged = append(tagged, match.Tags...)
+		return nil
+	})
+
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithActionConcurrency(2).
+		WithAction(count).
+		WithAction(failing).
+		WithRuleAction("rule-two", tag).
+		WithRuleAction("rule-two", panicking).
+		WithRuleAction("rule-two", record).
+		WithRuleAction("missing-rule", record).
+		BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	result, err := engine.Evaluate(map[string]interface{}{"EventID": 4624, "CommandLine": "powershell -enc SQBFAFgA", "Image": "lsass.exe"})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	// Failing actions do not drop matches
+	if len(result.MatchedRules) != 2 {
+		t.Errorf("Expected both rules to match, got %v", result.MatchedRules)
+	}
+	results, err := engine.EvaluateBatch([]interface{}{
+		map[string]interface{}{"Image": "lsass.exe"},
+		map[string]interface{}{"Image": "notepad.exe"},
+	})
+	if err != nil || len(results[0].MatchedRules) != 1 {
+		t.Fatalf("Batch evaluation failed: %v %v", results, err)
+	}
+	engine.FlushActions()
+
+	mu.Lock()
+	if counts[1] != 1 || counts[2] != 2 {
+		t.Errorf("Expected rule 1 to run actions once and rule 2 twice, got %v", counts)
+	}
+	if len(tagged) != 2 || tagged[0] != "credential-access" {
+		t.Errorf("Expected later actions to see the tags of earlier ones, got %v", tagged)
+	}
+	mu.Unlock()
+
+	stats := engine.ActionStats()
+	if stats.Queued != 3 || stats.Pending != 0 {
+		t.Errorf("Expected 3 queued matches and none pending, got %+v", stats)
+	}
+	if stats.Executed["count"] != 3 || stats.Failed["failing"] != 3 || stats.Failed["panicking"] != 2 || stats.Failed["count"] != 0 {
+		t.Errorf("Unexpected action counts: %+v", stats)
+	}
+
+	// Closed engines keep evaluating without running actions
+	engine.Close()
+	if _, err := engine.Evaluate(map[string]interface{}{"Image": "lsass.exe"}); err != nil {
+		t.Fatalf("Evaluation after Close failed: %v", err)
+	}
+	if stats := engine.ActionStats(); stats.Queued != 3 {
+		t.Errorf("Expected no actions after Close, got %+v", stats)
+	}
+	engine.Close()
+}
+
+func TestDagEngineWithoutActions(t *testing.T) {
+	engine, err := NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(createRawPrefilterRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if engine.ActionStats() != nil {
+		t.Error("Expected no action statistics without actions")
+	}
+	engine.FlushActions()
+	engine.Close()
+}
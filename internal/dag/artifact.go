@@ -0,0 +1,172 @@
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// RulesetArtifactVersion is the version of the serialized ruleset format.
+// It is raised whenever the encoding of compiled rulesets changes in a way
+// older artifacts cannot be read with.
+const RulesetArtifactVersion = 1
+
+// ArtifactHeader records what a serialized ruleset was compiled against.
+// Loading it into an engine that resolves match types, modifiers or field
+// names differently would silently change which events match.
+type ArtifactHeader struct {
+	// Version of the artifact format (RulesetArtifactVersion when written)
+	SchemaVersion int `json:"schema_version"`
+
+	// Fingerprint of the matcher registry the primitives were compiled
+	// against, see matcher.MatcherRegistry.Fingerprint
+	MatcherRegistry string `json:"matcher_registry,omitempty"`
+
+	// Fingerprint of the taxonomy and field mappings the rule fields were
+	// mapped with
+	Taxonomy string `json:"taxonomy,omitempty"`
+}
+
+// rulesetArtifact is the serialized form of a compiled ruleset. Primitive
+// matchers are not serialized; engines compile them from the primitives.
+type rulesetArtifact struct {
+	Header  ArtifactHeader  `json:"header"`
+	Ruleset json.RawMessage `json:"ruleset"`
+}
+
+// rulesetArtifactBody is the ruleset of a version 1 artifact
+type rulesetArtifactBody struct {
+	Primitives []Primitive  `json:"primitives"`
+	Dag        *CompiledDag `json:"dag,omitempty"`
+	Rules      []RuleMeta   `json:"rules"`
+}
+
+// WriteRulesetArtifact serializes a compiled ruleset with a header of what
+// it was compiled against, for ReadRulesetArtifact. The header's schema
+// version is set to RulesetArtifactVersion.
+func WriteRulesetArtifact(w io.Writer, ruleset *CompiledRuleset, header ArtifactHeader) error {
+	body, err := json.Marshal(rulesetArtifactBody{
+		Primitives: ruleset.Primitives,
+		Dag:        ruleset.Dag,
+		Rules:      ruleset.Rules,
+	})
+	if err != nil {
+		return errors.WrapIOError(err)
+	}
+
+	header.SchemaVersion = RulesetArtifactVersion
+	if err := json.NewEncoder(w).Encode(rulesetArtifact{Header: header, Ruleset: body}); err != nil {
+		return errors.WrapIOError(err)
+	}
+	return nil
+}
+
+// ReadRulesetArtifact loads a ruleset written by WriteRulesetArtifact. It
+// fails with an ErrorTypeIncompatibleVersion error when the artifact's
+// schema version is not RulesetArtifactVersion, or when it was compiled
+// against a matcher registry or taxonomy other than the expected ones.
+// Empty fingerprints in expected are not checked. A DAG that is not
+// structurally valid fails with an ErrorTypeCompilation error.
+func ReadRulesetArtifact(r io.Reader, expected ArtifactHeader) (*CompiledRuleset, error) {
+	var artifact rulesetArtifact
+	if err := json.NewDecoder(r).Decode(&artifact); err != nil {
+		return nil, errors.WrapIOError(err)
+	}
+	if err := checkArtifactHeader(artifact.Header, expected); err != nil {
+		return nil, err
+	}
+
+	var body rulesetArtifactBody
+	if err := json.Unmarshal(artifact.Ruleset, &body); err != nil {
+		return nil, errors.WrapIOError(err)
+	}
+	if body.Dag != nil {
+		if err := validateArtifactDag(body.Dag); err != nil {
+			return nil, err
+		}
+	}
+	return &CompiledRuleset{
+		Primitives:   body.Primitives,
+		PrimitiveMap: make(map[uint32]*CompiledPrimitive),
+		Dag:          body.Dag,
+		Rules:        body.Rules,
+	}, nil
+}
+
+// validateArtifactDag checks the structure of a loaded DAG, which engines
+// trust: edges point at existing nodes in both directions, nodes sit at the
+// position of their ID and the execution order lists every node once,
+// after its dependencies. The flat edge arrays are dropped, so engines
+// compact the DAG again from the checked node edges.
+func validateArtifactDag(dag *CompiledDag) error {
+	dag.DependencyOffsets, dag.DependencyIndex = nil, nil
+	dag.DependentOffsets, dag.DependentIndex = nil, nil
+
+	for _, check := range []func(*CompiledDag) error{(*CompiledDag).Validate, checkEdges, checkNodeOrder} {
+		if err := check(dag); err != nil {
+			message := err.Error()
+			if sigmaErr, ok := err.(*errors.SigmaError); ok {
+				message = sigmaErr.Message
+			}
+			return errors.Wrap(errors.ErrorTypeCompilation, fmt.Sprintf(
+				"ruleset artifact has an invalid DAG: %s; recompile the rules", message), err)
+		}
+	}
+	return nil
+}
+
+// checkNodeOrder verifies that nodes are stored at the position of their
+// ID and evaluated once each, after their dependencies
+func checkNodeOrder(dag *CompiledDag) error {
+	for i := range dag.Nodes {
+		if int(dag.Nodes[i].ID) != i {
+			return errors.NewCompilationError(fmt.Sprintf("Node %d stored at position %d", dag.Nodes[i].ID, i))
+		}
+	}
+	position := make([]int, len(dag.Nodes))
+	for i, nodeId := range dag.ExecutionOrder {
+		if int(nodeId) >= len(dag.Nodes) || position[nodeId] > 0 {
+			return errors.NewCompilationError(fmt.Sprintf("Invalid execution order entry: %d", nodeId))
+		}
+		position[nodeId] = i + 1
+	}
+	for _, node := range dag.Nodes {
+		for _, depId := range node.Dependencies {
+			if position[depId] > position[node.ID] {
+				return errors.NewCompilationError(fmt.Sprintf("Node %d evaluated before its dependency %d", node.ID, depId))
+			}
+		}
+	}
+	return nil
+}
+
+// checkArtifactHeader checks that an artifact was compiled the way the
+// loading engine would compile its rules
+func checkArtifactHeader(header, expected ArtifactHeader) error {
+	switch {
+	case header.SchemaVersion != RulesetArtifactVersion:
+		return errors.New(errors.ErrorTypeIncompatibleVersion, fmt.Sprintf(
+			"ruleset artifact has schema version %d, this engine reads version %d; recompile the rules with this engine",
+			header.SchemaVersion, RulesetArtifactVersion))
+	case expected.MatcherRegistry != "" && header.MatcherRegistry != expected.MatcherRegistry:
+		return errors.New(errors.ErrorTypeIncompatibleVersion, fmt.Sprintf(
+			"ruleset artifact was compiled against matcher registry %s, this engine uses %s; load the same matcher extensions or recompile the rules",
+			displayFingerprint(header.MatcherRegistry), expected.MatcherRegistry))
+	case expected.Taxonomy != "" && header.Taxonomy != expected.Taxonomy:
+		return errors.New(errors.ErrorTypeIncompatibleVersion, fmt.Sprintf(
+			"ruleset artifact was compiled with field mapping %s, this engine uses %s; load the same taxonomy or recompile the rules",
+			displayFingerprint(header.Taxonomy), expected.Taxonomy))
+	}
+	return nil
+}
+
+// displayFingerprint names a fingerprint in messages, which artifacts
+// written without one lack
+func displayFingerprint(fingerprint string) string {
+	if fingerprint == "" {
+		return "(unknown)"
+	}
+	return fingerprint
+}
@@ -0,0 +1,112 @@
+package dag
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+func TestRulesetArtifactRoundTrip(t *testing.T) {
+	ruleset := createTestRuleset()
+	ruleset.Dag = createTestDag()
+	ruleset.Rules = []RuleMeta{{ID: 1, Title: "Logon", Level: LevelHigh}}
+	header := ArtifactHeader{MatcherRegistry: "registry", Taxonomy: "taxonomy"}
+
+	var buf bytes.Buffer
+	if err := WriteRulesetArtifact(&buf, ruleset, header); err != nil {
+		t.Fatalf("Failed to write artifact: %v", err)
+	}
+	loaded, err := ReadRulesetArtifact(bytes.NewReader(buf.Bytes()), header)
+	if err != nil {
+		t.Fatalf("Failed to read artifact: %v", err)
+	}
+	if len(loaded.Primitives) != len(ruleset.Primitives) || len(loaded.Dag.Nodes) != len(ruleset.Dag.Nodes) || loaded.Rules[0].Level != LevelHigh {
+		t.Fatalf("Expected the ruleset to round trip, got %+v", loaded)
+	}
+
+	original, err := NewDagEngineFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+	restored, err := NewDagEngineFromRuleset(loaded)
+	if err != nil {
+		t.Fatalf("Failed to build engine from artifact: %v", err)
+	}
+	event := map[string]interface{}{"EventID": "4624", "ProcessName": "powershell"}
+	expected, _ := original.Evaluate(event)
+	result, err := restored.Evaluate(event)
+	if err != nil || len(expected.MatchedRules) == 0 || len(result.MatchedRules) != len(expected.MatchedRules) {
+		t.Errorf("Expected the loaded ruleset to match like the original, got %v, %v", result, err)
+	}
+}
+
+func TestRulesetArtifactCompatibility(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteRulesetArtifact(&buf, createTestRuleset(), ArtifactHeader{MatcherRegistry: "old-registry", Taxonomy: "sigma"}); err != nil {
+		t.Fatalf("Failed to write artifact: %v", err)
+	}
+	artifact := buf.String()
+
+	tests := []struct {
+		name     string
+		artifact string
+		expected ArtifactHeader
+		message  string
+	}{
+		{"schema version", strings.Replace(artifact, `"schema_version":1`, `"schema_version":0`, 1), ArtifactHeader{}, "schema version 0"},
+		{"matcher registry", artifact, ArtifactHeader{MatcherRegistry: "new-registry"}, "matcher extensions"},
+		{"taxonomy", artifact, ArtifactHeader{MatcherRegistry: "old-registry", Taxonomy: "ecs"}, "same taxonomy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadRulesetArtifact(strings.NewReader(tt.artifact), tt.expected)
+			var sigmaErr *sigmaerrors.SigmaError
+			if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeIncompatibleVersion {
+				t.Fatalf("Expected an incompatible version error, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.message) || !strings.Contains(err.Error(), "recompile") {
+				t.Errorf("Expected a remediation message mentioning %q, got %v", tt.message, err)
+			}
+		})
+	}
+
+	// Fingerprints left empty are not checked
+	if _, err := ReadRulesetArtifact(strings.NewReader(artifact), ArtifactHeader{}); err != nil {
+		t.Errorf("Expected unchecked fingerprints to load, got %v", err)
+	}
+}
+
+func TestRulesetArtifactInvalidDag(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(dag *CompiledDag)
+		message string
+	}{
+		{"unknown dependency", func(dag *CompiledDag) { dag.Nodes[3].Dependencies[0] = 9999 }, "Invalid dependency: 3 -> 9999"},
+		{"missing dependent", func(dag *CompiledDag) { dag.Nodes[2].Dependents = nil }, "Missing dependent: 2 -> 3"},
+		{"unknown result node", func(dag *CompiledDag) { dag.RuleResults[1] = 9999 }, "Invalid result node"},
+		{"execution order", func(dag *CompiledDag) { dag.ExecutionOrder = []NodeId{0, 1, 3, 2} }, "Node 3 evaluated before its dependency 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleset := createTestRuleset()
+			ruleset.Dag = createTestDag()
+			tt.corrupt(ruleset.Dag)
+
+			var buf bytes.Buffer
+			if err := WriteRulesetArtifact(&buf, ruleset, ArtifactHeader{}); err != nil {
+				t.Fatalf("Failed to write artifact: %v", err)
+			}
+			_, err := ReadRulesetArtifact(&buf, ArtifactHeader{})
+			if !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeCompilation) {
+				t.Fatalf("Expected a compilation error, got %v", err)
+			}
+			if !strings.Contains(err.Error(), "invalid DAG: "+tt.message) {
+				t.Errorf("Expected an error mentioning %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
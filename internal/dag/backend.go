@@ -0,0 +1,100 @@
+package dag
+
+import (
+	"fmt"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// Backend selects how the engine evaluates rule conditions
+type Backend int
+
+const (
+	// BackendDAG evaluates the shared rule DAG node by node
+	BackendDAG Backend = iota
+	// BackendVM runs one compact stack bytecode program per rule. It skips
+	// the DAG's per-node bookkeeping, which pays off for small rulesets.
+	BackendVM
+	// BackendInterpreter walks each rule's condition tree recursively with
+	// no sharing between rules. It is the slowest backend and serves as a
+	// simple reference to cross-check the others against.
+	BackendInterpreter
+)
+
+var backendNames = map[Backend]string{
+	BackendDAG:         "dag",
+	BackendVM:          "vm",
+	BackendInterpreter: "interpreter",
+}
+
+func (backend Backend) String() string {
+	if name, exists := backendNames[backend]; exists {
+		return name
+	}
+	return "dag"
+}
+
+// EvaluatorBackend evaluates events against a compiled ruleset. The DAG
+// engine, the bytecode VM and the tree-walking interpreter all implement
+// it, so their results can be compared event by event.
+type EvaluatorBackend interface {
+	// Evaluate matches a single event against every active rule
+	Evaluate(event interface{}) (*DagEvaluationResult, error)
+
+	// EvaluateAnyMatch reports whether any active rule matches an event,
+	// stopping at the first match
+	EvaluateAnyMatch(event interface{}) (bool, error)
+
+	// Backend identifies the evaluation strategy
+	Backend() Backend
+}
+
+var (
+	_ EvaluatorBackend = (*DagEngine)(nil)
+	_ EvaluatorBackend = (*BytecodeVM)(nil)
+	_ EvaluatorBackend = (*TreeInterpreter)(nil)
+)
+
+// newEvaluatorBackend creates the alternate backend selected by the config.
+// It returns nil for the DAG backend, which the engine evaluates with its
+// own single, batch and parallel evaluators.
+func newEvaluatorBackend(
+	config DagEngineConfig,
+	dag *CompiledDag,
+	primitives map[uint32]*CompiledPrimitive,
+	options evaluatorOptions,
+) (EvaluatorBackend, error) {
+	if config.Backend == BackendDAG {
+		return nil, nil
+	}
+	if _, exists := backendNames[config.Backend]; !exists {
+		return nil, errors.Errorf(errors.ErrorTypeConfig, "unknown backend: %d", config.Backend)
+	}
+	if config.CollectMatchDetails {
+		return nil, errors.Errorf(errors.ErrorTypeConfig, "match details are not supported by the %s backend", config.Backend)
+	}
+
+	switch config.Backend {
+	case BackendVM:
+		vm, err := NewBytecodeVM(dag, primitives)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile bytecode: %w", err)
+		}
+		return vm.withOptions(options, dag.RuleFields), nil
+	default:
+		return NewTreeInterpreter(dag, primitives).withOptions(options, dag.RuleFields), nil
+	}
+}
+
+// inactiveRuleSet indexes the rules excluded by the engine's rule filters
+func inactiveRuleSet(rules []ir.RuleID) map[ir.RuleID]bool {
+	if len(rules) == 0 {
+		return nil
+	}
+	inactive := make(map[ir.RuleID]bool, len(rules))
+	for _, ruleId := range rules {
+		inactive[ruleId] = true
+	}
+	return inactive
+}
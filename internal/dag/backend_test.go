@@ -0,0 +1,64 @@
+package dag
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+func TestEvaluatorBackendsAgree(t *testing.T) {
+	backends := []Backend{BackendDAG, BackendVM, BackendInterpreter}
+	engines := make([]EvaluatorBackend, 0, len(backends))
+	for _, backend := range backends {
+		engine, err := NewDagEngineBuilder().WithPrefilter(false).WithBackend(backend).BuildFromRuleset(createBatchTestRuleset())
+		if err != nil {
+			t.Fatalf("Failed to create %s engine: %v", backend, err)
+		}
+		if engine.Backend() != backend || engine.Stats().Backend != backend {
+			t.Errorf("Expected %s backend, got %s", backend, engine.Backend())
+		}
+		engines = append(engines, engine)
+	}
+
+	for i := 0; i < 15; i++ {
+		event := map[string]interface{}{"EventID": fmt.Sprint(4620 + i%5)}
+		if i%3 == 0 {
+			event["ProcessName"] = "powershell.exe"
+		}
+
+		var expected []ir.RuleID
+		for j, engine := range engines {
+			result, err := engine.Evaluate(event)
+			if err != nil {
+				t.Fatalf("%s evaluation failed: %v", engine.Backend(), err)
+			}
+			matched := append([]ir.RuleID{}, result.MatchedRules...)
+			sort.Slice(matched, func(a, b int) bool { return matched[a] < matched[b] })
+			if j == 0 {
+				expected = matched
+			} else if !reflect.DeepEqual(matched, expected) {
+				t.Errorf("event %d: %s matched %v, %s matched %v", i, engine.Backend(), matched, engines[0].Backend(), expected)
+			}
+		}
+	}
+}
+
+func TestEvaluatorBackendConfig(t *testing.T) {
+	if BackendInterpreter.String() != "interpreter" || Backend(42).String() != "dag" {
+		t.Errorf("Unexpected backend names %s, %s", BackendInterpreter, Backend(42))
+	}
+	cache := NewPrimitiveCache()
+	if _, err := NewDagEngineBuilder().WithPrimitiveCache(cache).WithBackend(Backend(42)).BuildFromRuleset(createBatchTestRuleset()); !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeConfig) {
+		t.Errorf("Expected an unknown backend to be rejected as a config error, got %v", err)
+	}
+	if _, err := NewDagEngineBuilder().WithPrimitiveCache(cache).WithBackend(BackendInterpreter).WithMatchDetails(true).BuildFromRuleset(createBatchTestRuleset()); !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeConfig) {
+		t.Errorf("Expected the interpreter backend to reject match details as a config error, got %v", err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected rejected engines to release their primitives, got %d cached", cache.Len())
+	}
+}
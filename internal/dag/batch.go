@@ -0,0 +1,234 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+)
+
+// ruleResultNode pairs a rule with its result node
+type ruleResultNode struct {
+	ruleId ir.RuleID
+	nodeId NodeId
+}
+
+// EvaluateBatch evaluates multiple events using batch processing.
+//
+// Primitives are matched column-wise into a [primitive][event] bit matrix,
+// then every logical node resolves the whole batch with word-wide AND/OR/NOT
+// over its dependencies' event-vectors instead of looping per event. Match
+// details and field capture need per-event state, so batches requesting them
+// are evaluated event by event.
+func (b *BatchDagEvaluator) EvaluateBatch(events []interface{}) ([]*DagEvaluationResult, error) {
+	for i, event := range events {
+		if !matcher.IsSupportedEvent(event) {
+			return nil, fmt.Errorf("event at index %d: %w", i, matcher.ErrUnsupportedEvent)
+		}
+	}
+
+	return b.evaluateEvents(events)
+}
+
+// evaluateEvents evaluates already validated events
+func (b *BatchDagEvaluator) evaluateEvents(events []interface{}) ([]*DagEvaluationResult, error) {
+	if b.options.collectDetails || b.options.captureFields {
+		return b.evaluateSequential(events)
+	}
+	return b.evaluateMatrix(events)
+}
+
+// evaluateSequential evaluates each event with a regular evaluator
+func (b *BatchDagEvaluator) evaluateSequential(events []interface{}) ([]*DagEvaluationResult, error) {
+	results := make([]*DagEvaluationResult, len(events))
+
+	evaluator := NewDagEvaluatorWithCompiledPrimitives(b.dag, b.primitives).withOptions(b.options)
+	defer evaluator.Release()
+	for i, event := range events {
+		result, err := evaluator.Evaluate(event)
+		if err != nil {
+			return nil, err
+		}
+		b.totalNodesEvaluated += result.NodesEvaluated
+		b.totalPrimitiveEvaluations += result.PrimitiveEvaluations
+		results[i] = result
+	}
+
+	return results, nil
+}
+
+// evaluateMatrix evaluates the batch over node x event bit matrices
+func (b *BatchDagEvaluator) evaluateMatrix(events []interface{}) ([]*DagEvaluationResult, error) {
+	batchSize := len(events)
+	rows := b.memoryPool.prepare(len(b.dag.Nodes), batchSize)
+
+	inactive := make(map[NodeId]bool, len(b.options.inactiveRules))
+	for _, ruleId := range b.options.inactiveRules {
+		if nodeId, exists := b.dag.RuleResults[ruleId]; exists {
+			inactive[nodeId] = true
+		}
+	}
+
+	// Events are matched primitive by primitive across the batch, so the
+	// per-event timeout is pooled into one deadline for the whole batch
+	contexts := make([]*matcher.EventContext, batchSize)
+	batchTimeout := b.options.eventTimeout * time.Duration(batchSize)
+	var deadline time.Time
+	if batchTimeout > 0 {
+		deadline = clock.Or(b.options.clock).Now().Add(batchTimeout)
+	}
+	primitiveEvaluations := 0
+
+	for _, nodeId := range b.dag.ExecutionOrder {
+		node := b.dag.GetNode(nodeId)
+		if node == nil {
+			return nil, fmt.Errorf("node not found: %d", nodeId)
+		}
+		row := rows[nodeId]
+
+		switch node.NodeType.Type {
+		case "Primitive":
+			if node.NodeType.PrimitiveId == nil {
+				continue
+			}
+			primitiveId := *node.NodeType.PrimitiveId
+			if cached := b.memoryPool.PrimitiveRow(primitiveId); cached != nil {
+				// Duplicate primitive node: reuse the already matched event-vector
+				copy(row, cached)
+				continue
+			}
+			primitiveEvaluations++
+			primitive := b.primitives[uint32(primitiveId)]
+			if primitive == nil {
+				continue
+			}
+			for i, event := range events {
+				if contexts[i] == nil {
+					contexts[i] = matcher.NewEventContext(event)
+					contexts[i].SetCaseInsensitiveFields(b.options.foldFieldCase)
+					if batchTimeout > 0 {
+						contexts[i].SetDeadline(deadline, b.options.clock)
+					}
+				}
+				matched, err := matchPrimitive(primitiveId, primitive, contexts[i], event, b.options.primitiveErrors)
+				if err != nil {
+					return nil, err
+				}
+				if matched {
+					row.Set(uint32(i))
+				}
+			}
+			b.memoryPool.setPrimitiveRow(primitiveId, row)
+
+		case "Logical":
+			if node.NodeType.Operation == nil {
+				continue
+			}
+			deps := node.Dependencies
+			switch *node.NodeType.Operation {
+			case LogicalAnd:
+				if len(deps) == 0 {
+					continue
+				}
+				copy(row, rows[deps[0]])
+				for _, depId := range deps[1:] {
+					row.And(rows[depId])
+				}
+			case LogicalOr:
+				for _, depId := range deps {
+					row.Or(rows[depId])
+				}
+			case LogicalNot:
+				if len(deps) == 1 {
+					copy(row, rows[deps[0]])
+					row.Not(batchSize)
+				}
+			}
+
+		case "Result":
+			if !inactive[nodeId] && len(node.Dependencies) == 1 {
+				copy(row, rows[node.Dependencies[0]])
+			}
+
+		case "Prefilter":
+			row.Not(batchSize)
+		}
+	}
+
+	// Rules in a stable order so every event lists its matches consistently
+	resultNodes := make([]ruleResultNode, 0, len(b.dag.RuleResults))
+	for ruleId, nodeId := range b.dag.RuleResults {
+		resultNodes = append(resultNodes, ruleResultNode{ruleId: ruleId, nodeId: nodeId})
+	}
+	sort.Slice(resultNodes, func(i, j int) bool { return resultNodes[i].ruleId < resultNodes[j].ruleId })
+
+	nodesEvaluated := len(b.dag.ExecutionOrder)
+	results := make([]*DagEvaluationResult, batchSize)
+	for i := range results {
+		var matchedRules []ir.RuleID
+		for _, result := range resultNodes {
+			if rows[result.nodeId].Test(uint32(i)) {
+				matchedRules = append(matchedRules, result.ruleId)
+			}
+		}
+		results[i] = &DagEvaluationResult{
+			MatchedRules:         matchedRules,
+			NodesEvaluated:       nodesEvaluated,
+			PrimitiveEvaluations: primitiveEvaluations,
+			PrimitiveErrors:      contexts[i].RecoveredErrors(),
+		}
+	}
+
+	b.totalNodesEvaluated += nodesEvaluated * batchSize
+	b.totalPrimitiveEvaluations += primitiveEvaluations * batchSize
+
+	return results, nil
+}
+
+// prepare sizes the node matrix for a batch and clears it. All rows share a
+// single contiguous backing array that is reused across batches.
+func (p *BatchMemoryPool) prepare(nodeCount, batchSize int) []Bitset {
+	words := bitsetWords(batchSize)
+	total := nodeCount * words
+	if cap(p.backing) < total {
+		p.backing = make([]uint64, total)
+	}
+	p.backing = p.backing[:total]
+	clear(p.backing)
+
+	if cap(p.nodeResults) < nodeCount {
+		p.nodeResults = make([]Bitset, nodeCount)
+	}
+	p.nodeResults = p.nodeResults[:nodeCount]
+	for i := range p.nodeResults {
+		p.nodeResults[i] = Bitset(p.backing[i*words : (i+1)*words : (i+1)*words])
+	}
+
+	clear(p.primitiveResults)
+	p.primitiveResults = p.primitiveResults[:0]
+	p.primitiveCount = 0
+
+	p.nodeCount = nodeCount
+	p.batchSize = batchSize
+	return p.nodeResults
+}
+
+// setPrimitiveRow records the event-vector of a primitive
+func (p *BatchMemoryPool) setPrimitiveRow(primitiveId ir.PrimitiveID, row Bitset) {
+	if int(primitiveId) >= len(p.primitiveResults) {
+		p.primitiveResults = append(p.primitiveResults, make([]Bitset, int(primitiveId)+1-len(p.primitiveResults))...)
+	}
+	p.primitiveResults[primitiveId] = row
+	p.primitiveCount++
+}
+
+// PrimitiveRow returns the event-vector of a primitive from the last batch
+func (p *BatchMemoryPool) PrimitiveRow(primitiveId ir.PrimitiveID) Bitset {
+	if int(primitiveId) < len(p.primitiveResults) {
+		return p.primitiveResults[primitiveId]
+	}
+	return nil
+}
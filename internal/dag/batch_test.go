@@ -0,0 +1,165 @@
+package dag
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+)
+
+// createBatchTestRuleset extends the test ruleset with a second rule
+// matching events whose process is not powershell
+func createBatchTestRuleset() *CompiledRuleset {
+	ruleset := createTestRuleset()
+	dag := createTestDag()
+
+	not := NewDagNode(4, NewLogicalNodeType(LogicalNot))
+	not.Dependencies = []NodeId{1}
+	not.Dependents = []NodeId{5}
+	result := NewDagNode(5, NewResultNodeType(2))
+	result.Dependencies = []NodeId{4}
+
+	dag.Nodes[1].Dependents = append(dag.Nodes[1].Dependents, 4)
+	dag.Nodes = append(dag.Nodes, *not, *result)
+	dag.RuleResults[2] = 5
+	dag.ExecutionOrder = append(dag.ExecutionOrder, 4, 5)
+
+	ruleset.Dag = dag
+	return ruleset
+}
+
+func TestBatchMatrixMatchesSingleEvaluation(t *testing.T) {
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithPrefilter(false).
+		BuildFromRuleset(createBatchTestRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// More than one word of events
+	events := make([]interface{}, 70)
+	for i := range events {
+		event := map[string]interface{}{"EventID": fmt.Sprint(4620 + i%5)}
+		if i%3 == 0 {
+			event["ProcessName"] = "powershell.exe"
+		}
+		events[i] = event
+	}
+
+	results, err := engine.EvaluateBatch(events)
+	if err != nil {
+		t.Fatalf("Batch evaluation failed: %v", err)
+	}
+	if len(results) != len(events) {
+		t.Fatalf("Expected %d results, got %d", len(events), len(results))
+	}
+
+	for i, event := range events {
+		single, err := engine.Evaluate(event)
+		if err != nil {
+			t.Fatalf("Evaluation failed: %v", err)
+		}
+		expected := append([]ir.RuleID(nil), single.MatchedRules...)
+		sort.Slice(expected, func(a, b int) bool { return expected[a] < expected[b] })
+
+		if !reflect.DeepEqual(results[i].MatchedRules, expected) {
+			t.Errorf("event %d: batch matched %v, single matched %v", i, results[i].MatchedRules, expected)
+		}
+	}
+
+	row := engine.batchEvaluator.memoryPool.PrimitiveRow(1)
+	if row.Count() != 24 {
+		t.Errorf("Expected 24 powershell events in primitive row, got %d", row.Count())
+	}
+}
+
+func TestBatchMatrixInactiveRules(t *testing.T) {
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithPrefilter(false).
+		BuildFromRuleset(createBatchTestRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	evaluator := NewBatchDagEvaluator(engine.dag, engine.primitives)
+	evaluator.options = evaluatorOptions{inactiveRules: []ir.RuleID{2}}
+
+	results, err := evaluator.EvaluateBatch([]interface{}{
+		map[string]interface{}{"EventID": "1"},
+		map[string]interface{}{"EventID": "4624", "ProcessName": "powershell"},
+	})
+	if err != nil {
+		t.Fatalf("Batch evaluation failed: %v", err)
+	}
+	if len(results[0].MatchedRules) != 0 {
+		t.Errorf("Expected inactive rule 2 not to match, got %v", results[0].MatchedRules)
+	}
+	if !reflect.DeepEqual(results[1].MatchedRules, []ir.RuleID{1}) {
+		t.Errorf("Expected rule 1 to match, got %v", results[1].MatchedRules)
+	}
+}
+
+func TestEvaluateBatchFunc(t *testing.T) {
+	engine, err := NewDagEngineBuilder().BuildFromRuleset(createBatchTestRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// Spans several chunks
+	events := make([]interface{}, 2*streamChunkSize+10)
+	for i := range events {
+		event := map[string]interface{}{"EventID": fmt.Sprint(4620 + i%5)}
+		if i%3 == 0 {
+			event["ProcessName"] = "powershell.exe"
+		}
+		events[i] = event
+	}
+	expected, err := engine.EvaluateBatch(events)
+	if err != nil {
+		t.Fatalf("Batch evaluation failed: %v", err)
+	}
+
+	next := 0
+	err = engine.EvaluateBatchFunc(events, func(i int, result *DagEvaluationResult) error {
+		if i != next {
+			t.Fatalf("Expected result %d, got %d", next, i)
+		}
+		if !reflect.DeepEqual(result.MatchedRules, expected[i].MatchedRules) {
+			t.Errorf("event %d: streamed %v, batch %v", i, result.MatchedRules, expected[i].MatchedRules)
+		}
+		next++
+		return nil
+	})
+	if err != nil || next != len(events) {
+		t.Fatalf("Expected %d results, got %d (err=%v)", len(events), next, err)
+	}
+
+	// Returning an error stops the evaluation
+	stop := errors.New("stop")
+	delivered := 0
+	err = engine.EvaluateBatchFunc(events, func(i int, result *DagEvaluationResult) error {
+		delivered++
+		if i == 5 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || delivered != 6 {
+		t.Errorf("Expected the evaluation to stop after 6 results, got %d (err=%v)", delivered, err)
+	}
+
+	// Unsupported events fail before any result is delivered
+	err = engine.EvaluateBatchFunc(append(events[:3:3], 42), func(int, *DagEvaluationResult) error {
+		t.Fatal("Unexpected result")
+		return nil
+	})
+	if !errors.Is(err, matcher.ErrUnsupportedEvent) {
+		t.Errorf("Expected an unsupported event error, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package dag
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// Bitset is a fixed-size set of bits backed by 64-bit words. Evaluators use
+// it for node and primitive results so whole words can be combined at once.
+type Bitset []uint64
+
+// bitsetWords returns the number of words needed to hold size bits
+func bitsetWords(size int) int {
+	return (size + 63) >> 6
+}
+
+// NewBitset creates a cleared bitset holding at least size bits
+func NewBitset(size int) Bitset {
+	return make(Bitset, bitsetWords(size))
+}
+
+// Len returns the number of bits the bitset can hold
+func (b Bitset) Len() int {
+	return len(b) << 6
+}
+
+// Test reports whether bit i is set; out-of-range bits read as unset
+func (b Bitset) Test(i uint32) bool {
+	word := int(i >> 6)
+	return word < len(b) && b[word]&(1<<(i&63)) != 0
+}
+
+// Set sets bit i
+func (b Bitset) Set(i uint32) {
+	b[i>>6] |= 1 << (i & 63)
+}
+
+// Clear clears bit i
+func (b Bitset) Clear(i uint32) {
+	b[i>>6] &^= 1 << (i & 63)
+}
+
+// SetTo sets or clears bit i; out-of-range bits are ignored
+func (b Bitset) SetTo(i uint32, value bool) {
+	if int(i>>6) >= len(b) {
+		return
+	}
+	if value {
+		b.Set(i)
+	} else {
+		b.Clear(i)
+	}
+}
+
+// Reset clears every bit
+func (b Bitset) Reset() {
+	clear(b)
+}
+
+// Count returns the number of set bits
+func (b Bitset) Count() int {
+	count := 0
+	for _, word := range b {
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+// Any reports whether any bit is set
+func (b Bitset) Any() bool {
+	for _, word := range b {
+		if word != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// And intersects b with other in place
+func (b Bitset) And(other Bitset) {
+	n := min(len(b), len(other))
+	for i := 0; i < n; i++ {
+		b[i] &= other[i]
+	}
+	clear(b[n:])
+}
+
+// Or merges other into b in place
+func (b Bitset) Or(other Bitset) {
+	n := min(len(b), len(other))
+	for i := 0; i < n; i++ {
+		b[i] |= other[i]
+	}
+}
+
+// AndNot clears the bits of b that are set in other
+func (b Bitset) AndNot(other Bitset) {
+	n := min(len(b), len(other))
+	for i := 0; i < n; i++ {
+		b[i] &^= other[i]
+	}
+}
+
+// Not complements the first size bits and clears the rest
+func (b Bitset) Not(size int) {
+	for i := range b {
+		b[i] = ^b[i]
+	}
+	b.truncate(size)
+}
+
+// truncate clears every bit at or above size
+func (b Bitset) truncate(size int) {
+	words := bitsetWords(size)
+	if words > len(b) {
+		return
+	}
+	clear(b[words:])
+	if rem := size & 63; rem != 0 && words > 0 {
+		b[words-1] &= (1 << rem) - 1
+	}
+}
+
+// bitsetPool recycles bitset buffers between short-lived evaluators
+var bitsetPool = sync.Pool{
+	New: func() interface{} { return new(Bitset) },
+}
+
+// acquireBitset returns a cleared bitset of at least size bits, reusing a
+// pooled buffer when one is large enough
+func acquireBitset(size int) Bitset {
+	words := bitsetWords(size)
+	buf := bitsetPool.Get().(*Bitset)
+	if cap(*buf) < words {
+		*buf = make(Bitset, words)
+	}
+	b := (*buf)[:words]
+	b.Reset()
+	return b
+}
+
+// releaseBitset returns a bitset buffer to the pool
+func releaseBitset(b Bitset) {
+	if cap(b) == 0 {
+		return
+	}
+	buf := b[:0]
+	bitsetPool.Put(&buf)
+}
@@ -0,0 +1,89 @@
+package dag
+
+import "testing"
+
+func TestBitset(t *testing.T) {
+	b := NewBitset(130)
+	if b.Len() != 192 {
+		t.Errorf("Expected capacity 192, got %d", b.Len())
+	}
+
+	b.Set(0)
+	b.Set(64)
+	b.SetTo(129, true)
+	b.SetTo(1000, true) // out of range, ignored
+	if !b.Test(0) || !b.Test(64) || !b.Test(129) || b.Test(1) || b.Test(1000) {
+		t.Errorf("Unexpected bits: %b", b)
+	}
+	if b.Count() != 3 {
+		t.Errorf("Expected 3 set bits, got %d", b.Count())
+	}
+
+	b.Clear(64)
+	if b.Test(64) {
+		t.Error("Expected bit 64 to be cleared")
+	}
+
+	other := NewBitset(130)
+	other.Set(0)
+	other.Set(5)
+
+	union := NewBitset(130)
+	union.Or(b)
+	union.Or(other)
+	if union.Count() != 3 || !union.Test(5) {
+		t.Errorf("Unexpected union: %b", union)
+	}
+
+	b.And(other)
+	if b.Count() != 1 || !b.Test(0) {
+		t.Errorf("Unexpected intersection: %b", b)
+	}
+
+	union.AndNot(other)
+	if union.Count() != 1 || !union.Test(129) {
+		t.Errorf("Unexpected difference: %b", union)
+	}
+
+	union.Reset()
+	if union.Any() {
+		t.Error("Expected bitset to be empty after reset")
+	}
+}
+
+func TestBitsetNot(t *testing.T) {
+	b := NewBitset(70)
+	b.Set(3)
+	b.Not(70)
+	if b.Count() != 69 || b.Test(3) || b.Test(70) {
+		t.Errorf("Unexpected complement: count=%d", b.Count())
+	}
+}
+
+func TestBitsetPool(t *testing.T) {
+	b := acquireBitset(100)
+	b.Set(99)
+	releaseBitset(b)
+
+	reused := acquireBitset(64)
+	if reused.Any() {
+		t.Error("Expected pooled bitset to be cleared")
+	}
+	if reused.Len() < 64 {
+		t.Errorf("Expected at least 64 bits, got %d", reused.Len())
+	}
+}
+
+func TestDagEvaluatorRelease(t *testing.T) {
+	dag := createTestDag()
+	evaluator := NewDagEvaluatorWithPrimitives(dag)
+	evaluator.Release()
+
+	result, err := evaluator.Evaluate(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Evaluation after release failed: %v", err)
+	}
+	if len(result.MatchedRules) != 0 {
+		t.Errorf("Expected no matches, got %v", result.MatchedRules)
+	}
+}
@@ -38,8 +38,9 @@ func (builder *DagBuilder) WithPrefilter(enable bool) *DagBuilder {
 }
 
 func (builder *DagBuilder) FromRuleset(ruleset *ir.CompiledRuleset) *DagBuilder {
-	// First pass: Create primitive nodes (shared across rules)
-	for _, primitiveId := range ruleset.PrimitiveMap {
+	// First pass: Create primitive nodes (shared across rules) in ID order
+	for i := range ruleset.Primitives {
+		primitiveId := ir.PrimitiveID(i)
 		nodeId := builder.createPrimitiveNode(primitiveId)
 		builder.primitiveNodes[primitiveId] = nodeId
 	}
@@ -105,6 +106,62 @@ func (builder *DagBuilder) createPrefilterNode(patternCount int) NodeId {
 	return nodeId
 }
 
+// AddRuleDag merges the nodes generated for a single rule into the builder.
+// Primitive nodes are shared with rules added earlier; logical and result
+// nodes are renumbered into the builder's node space.
+func (builder *DagBuilder) AddRuleDag(ruleId ir.RuleID, nodes []DagNode) error {
+	if _, exists := builder.ruleResultNodes[ruleId]; exists {
+		return errors.NewCompilationError("Duplicate rule ID: " + strconv.Itoa(int(ruleId)))
+	}
+
+	remap := make(map[NodeId]NodeId, len(nodes))
+	for _, node := range nodes {
+		switch node.NodeType.Type {
+		case "Primitive":
+			if node.NodeType.PrimitiveId == nil {
+				return errors.NewCompilationError("Primitive node without primitive ID")
+			}
+			primitiveId := *node.NodeType.PrimitiveId
+			nodeId, exists := builder.primitiveNodes[primitiveId]
+			if !exists {
+				nodeId = builder.createPrimitiveNode(primitiveId)
+				builder.primitiveNodes[primitiveId] = nodeId
+			}
+			remap[node.ID] = nodeId
+		case "Logical":
+			if node.NodeType.Operation == nil {
+				return errors.NewCompilationError("Logical node without operation")
+			}
+			remap[node.ID] = builder.createLogicalNode(*node.NodeType.Operation)
+		case "Result":
+			remap[node.ID] = builder.createResultNode(ruleId)
+		default:
+			return errors.NewCompilationError("Unsupported node type in rule DAG: " + node.NodeType.Type)
+		}
+	}
+
+	for _, node := range nodes {
+		for _, depId := range node.Dependencies {
+			mappedDep, exists := remap[depId]
+			if !exists {
+				return errors.NewCompilationError("Invalid dependency")
+			}
+			builder.addEdge(remap[node.ID], mappedDep)
+		}
+	}
+
+	if _, exists := builder.ruleResultNodes[ruleId]; !exists {
+		return errors.NewCompilationError("Missing result node for rule: " + strconv.Itoa(int(ruleId)))
+	}
+	return nil
+}
+
+// addEdge records that dependent depends on dependency
+func (builder *DagBuilder) addEdge(dependent, dependency NodeId) {
+	builder.nodes[dependent].AddDependency(dependency)
+	builder.nodes[dependency].AddDependent(dependent)
+}
+
 // Optimize - Enable optimization passes
 func (builder *DagBuilder) Optimize() *DagBuilder {
 	if builder.enableOptimization {
@@ -132,6 +189,8 @@ func (builder *DagBuilder) Build() (*CompiledDag, error) {
 		PrimitiveMap:     builder.primitiveNodes,
 		RuleResults:      builder.ruleResultNodes,
 		ResultBufferSize: int(builder.nextNodeId),
+		RuleSelections:   make(map[ir.RuleID]map[string][]ir.PrimitiveID),
+		RuleFields:       make(map[ir.RuleID][]RuleField),
 	}
 
 	// Final validation
@@ -208,14 +267,8 @@ func (builder *DagBuilder) updateFromOptimizedDag(optimizedDag *CompiledDag) {
 	builder.primitiveNodes = optimizedDag.PrimitiveMap
 	builder.ruleResultNodes = optimizedDag.RuleResults
 
-	// Update nextNodeId to be safe
-	maxId := NodeId(0)
-	for _, node := range builder.nodes {
-		if node.ID > maxId {
-			maxId = node.ID
-		}
-	}
-	builder.nextNodeId = maxId + 1
+	// Continue numbering above every remaining node (0 for an empty DAG)
+	builder.nextNodeId = nextNodeId(optimizedDag)
 }
 
 func (builder *DagBuilder) topologicalSort() ([]NodeId, error) {
@@ -0,0 +1,101 @@
+package dag
+
+import (
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+// Compact moves the DAG's edges and node payloads into a few contiguous
+// arrays (structure-of-arrays layout).
+//
+// After compaction, the dependencies of node i are
+// DependencyIndex[DependencyOffsets[i]:DependencyOffsets[i+1]] (dependents
+// likewise), and each node's Dependencies/Dependents slices alias those
+// arrays. Node type payloads (primitive IDs, operations, rule IDs) point
+// into shared slabs instead of individual allocations. This replaces
+// several small allocations per node with a handful per DAG, which cuts GC
+// scanning cost and pointer chasing for large rulesets.
+//
+// Node slices are capacity-limited, so appending to them afterwards copies
+// instead of overwriting a neighbour's edges.
+func (dag *CompiledDag) Compact() {
+	nodeCount := len(dag.Nodes)
+
+	totalDependencies, totalDependents := 0, 0
+	primitiveCount, logicalCount, resultCount := 0, 0, 0
+	for i := range dag.Nodes {
+		node := &dag.Nodes[i]
+		totalDependencies += len(node.Dependencies)
+		totalDependents += len(node.Dependents)
+		if node.NodeType.PrimitiveId != nil {
+			primitiveCount++
+		}
+		if node.NodeType.Operation != nil {
+			logicalCount++
+		}
+		if node.NodeType.RuleId != nil {
+			resultCount++
+		}
+	}
+
+	dag.DependencyOffsets = make([]uint32, nodeCount+1)
+	dag.DependencyIndex = make([]NodeId, 0, totalDependencies)
+	dag.DependentOffsets = make([]uint32, nodeCount+1)
+	dag.DependentIndex = make([]NodeId, 0, totalDependents)
+
+	primitiveIds := make([]ir.PrimitiveID, 0, primitiveCount)
+	operations := make([]LogicalOp, 0, logicalCount)
+	ruleIds := make([]ir.RuleID, 0, resultCount)
+
+	for i := range dag.Nodes {
+		node := &dag.Nodes[i]
+
+		start := len(dag.DependencyIndex)
+		dag.DependencyIndex = append(dag.DependencyIndex, node.Dependencies...)
+		dag.DependencyOffsets[i] = uint32(start)
+
+		start = len(dag.DependentIndex)
+		dag.DependentIndex = append(dag.DependentIndex, node.Dependents...)
+		dag.DependentOffsets[i] = uint32(start)
+
+		if node.NodeType.PrimitiveId != nil {
+			primitiveIds = append(primitiveIds, *node.NodeType.PrimitiveId)
+			node.NodeType.PrimitiveId = &primitiveIds[len(primitiveIds)-1]
+		}
+		if node.NodeType.Operation != nil {
+			operations = append(operations, *node.NodeType.Operation)
+			node.NodeType.Operation = &operations[len(operations)-1]
+		}
+		if node.NodeType.RuleId != nil {
+			ruleIds = append(ruleIds, *node.NodeType.RuleId)
+			node.NodeType.RuleId = &ruleIds[len(ruleIds)-1]
+		}
+	}
+	dag.DependencyOffsets[nodeCount] = uint32(len(dag.DependencyIndex))
+	dag.DependentOffsets[nodeCount] = uint32(len(dag.DependentIndex))
+
+	// Point node slices into the flat arrays
+	for i := range dag.Nodes {
+		node := &dag.Nodes[i]
+		start, end := dag.DependencyOffsets[i], dag.DependencyOffsets[i+1]
+		node.Dependencies = dag.DependencyIndex[start:end:end]
+		start, end = dag.DependentOffsets[i], dag.DependentOffsets[i+1]
+		node.Dependents = dag.DependentIndex[start:end:end]
+	}
+}
+
+// IsCompact reports whether the flat edge arrays are in sync with the nodes
+func (dag *CompiledDag) IsCompact() bool {
+	return len(dag.DependencyOffsets) == len(dag.Nodes)+1 && len(dag.DependentOffsets) == len(dag.Nodes)+1
+}
+
+// DependenciesOf returns the dependencies of a node, reading the flat edge
+// arrays when the DAG is compact
+func (dag *CompiledDag) DependenciesOf(nodeId NodeId) []NodeId {
+	if dag.IsCompact() && int(nodeId) < len(dag.Nodes) {
+		return dag.DependencyIndex[dag.DependencyOffsets[nodeId]:dag.DependencyOffsets[nodeId+1]]
+	}
+	if node := dag.GetNode(nodeId); node != nil {
+		return node.Dependencies
+	}
+	return nil
+}
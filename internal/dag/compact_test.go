@@ -0,0 +1,76 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompactDag(t *testing.T) {
+	dag := createTestDag()
+	if dag.IsCompact() {
+		t.Fatal("Expected a freshly built DAG not to be compact")
+	}
+
+	dag.Compact()
+	if !dag.IsCompact() {
+		t.Fatal("Expected DAG to be compact after Compact")
+	}
+
+	if !reflect.DeepEqual(dag.DependencyOffsets, []uint32{0, 0, 0, 2, 3}) {
+		t.Errorf("Unexpected dependency offsets: %v", dag.DependencyOffsets)
+	}
+	if !reflect.DeepEqual(dag.DependencyIndex, []NodeId{0, 1, 2}) {
+		t.Errorf("Unexpected dependency index: %v", dag.DependencyIndex)
+	}
+	if !reflect.DeepEqual(dag.DependentIndex, []NodeId{2, 2, 3}) {
+		t.Errorf("Unexpected dependent index: %v", dag.DependentIndex)
+	}
+
+	// Node slices alias the flat arrays
+	logical := dag.GetNode(2)
+	if &logical.Dependencies[0] != &dag.DependencyIndex[0] {
+		t.Error("Expected node dependencies to alias the flat index")
+	}
+	if !reflect.DeepEqual(dag.DependenciesOf(3), []NodeId{2}) {
+		t.Errorf("Unexpected dependencies of result node: %v", dag.DependenciesOf(3))
+	}
+	if *dag.GetNode(3).NodeType.RuleId != 1 || *logical.NodeType.Operation != LogicalAnd {
+		t.Error("Expected node payloads to survive compaction")
+	}
+
+	// Appending to a node slice must not clobber the next node's edges
+	logical.Dependencies = append(logical.Dependencies, 9)
+	if dag.DependencyIndex[2] != 2 {
+		t.Errorf("Append overwrote neighbouring edges: %v", dag.DependencyIndex)
+	}
+
+	// Adding nodes invalidates the flat arrays
+	dag.AddNode(*NewDagNode(4, NewPrimitiveNodeType(2)))
+	if dag.IsCompact() {
+		t.Error("Expected DAG with new nodes not to be compact")
+	}
+}
+
+func TestEngineCompactsDag(t *testing.T) {
+	ruleset := createTestRuleset()
+	ruleset.Dag = createTestDag()
+
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithPrefilter(false).
+		BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if !engine.dag.IsCompact() {
+		t.Error("Expected engine DAG to be compact")
+	}
+
+	result, err := engine.Evaluate(map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 || result.MatchedRules[0] != 1 {
+		t.Errorf("Expected rule 1 to match on compact DAG, got %v", result.MatchedRules)
+	}
+}
@@ -0,0 +1,138 @@
+package dag
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// ComplexityPolicy selects what happens to rules whose complexity exceeds
+// DagEngineConfig.MaxRuleComplexity
+type ComplexityPolicy int
+
+const (
+	// ComplexityWarn logs a warning and keeps the rule active
+	ComplexityWarn ComplexityPolicy = iota
+	// ComplexityReject fails the engine build
+	ComplexityReject
+	// ComplexityDisable keeps the rule compiled but inactive, like rules
+	// excluded by the rule filters
+	ComplexityDisable
+)
+
+var complexityPolicyNames = map[ComplexityPolicy]string{
+	ComplexityWarn:    "warn",
+	ComplexityReject:  "reject",
+	ComplexityDisable: "disable",
+}
+
+func (policy ComplexityPolicy) String() string {
+	if name, exists := complexityPolicyNames[policy]; exists {
+		return name
+	}
+	return "warn"
+}
+
+// RuleComplexity estimates the per-event evaluation cost of a rule: one per
+// logical node of its condition plus one per value of each primitive it
+// matches. Nodes shared within the rule are counted once. Returns 0 for
+// unknown rules.
+func RuleComplexity(dag *CompiledDag, ruleId ir.RuleID, primitives []Primitive) int {
+	resultNodeId, exists := dag.RuleResults[ruleId]
+	if !exists {
+		return 0
+	}
+
+	valueCounts := make(map[ir.PrimitiveID]int, len(primitives))
+	for _, primitive := range primitives {
+		valueCounts[ir.PrimitiveID(primitive.ID)] = max(len(primitive.Values), 1)
+	}
+
+	complexity := 0
+	visited := make(map[NodeId]bool)
+	stack := []NodeId{resultNodeId}
+	for len(stack) > 0 {
+		nodeId := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		node := dag.GetNode(nodeId)
+		if visited[nodeId] || node == nil {
+			continue
+		}
+		visited[nodeId] = true
+
+		switch node.NodeType.Type {
+		case "Primitive":
+			if node.NodeType.PrimitiveId != nil {
+				complexity += valueCounts[*node.NodeType.PrimitiveId]
+			}
+		case "Logical":
+			complexity++
+		}
+		stack = append(stack, dag.DependenciesOf(nodeId)...)
+	}
+	return complexity
+}
+
+// applyComplexityPolicy checks every rule against the configured maximum
+// complexity. It returns the rules to deactivate under ComplexityDisable and
+// fails under ComplexityReject.
+func applyComplexityPolicy(
+	config DagEngineConfig,
+	dag *CompiledDag,
+	primitives []Primitive,
+	rules map[ir.RuleID]RuleMeta,
+	logger *slog.Logger,
+) ([]ir.RuleID, error) {
+	if config.MaxRuleComplexity <= 0 {
+		return nil, nil
+	}
+
+	ruleIds := make([]ir.RuleID, 0, len(dag.RuleResults))
+	for ruleId := range dag.RuleResults {
+		ruleIds = append(ruleIds, ruleId)
+	}
+	sort.Slice(ruleIds, func(i, j int) bool { return ruleIds[i] < ruleIds[j] })
+
+	var disabled []ir.RuleID
+	for _, ruleId := range ruleIds {
+		complexity := RuleComplexity(dag, ruleId, primitives)
+		if complexity <= config.MaxRuleComplexity {
+			continue
+		}
+
+		switch config.ComplexityPolicy {
+		case ComplexityReject:
+			return nil, errors.New(errors.ErrorTypeTooManyOperations,
+				fmt.Sprintf("rule %d (%s) has complexity %d (max %d)", ruleId, rules[ruleId].Title, complexity, config.MaxRuleComplexity))
+		case ComplexityDisable:
+			disabled = append(disabled, ruleId)
+		}
+		logger.Warn("rule exceeds maximum complexity",
+			slog.Uint64("rule_id", uint64(ruleId)),
+			slog.String("title", rules[ruleId].Title),
+			slog.Int("complexity", complexity),
+			slog.Int("max_complexity", config.MaxRuleComplexity),
+			slog.String("policy", config.ComplexityPolicy.String()))
+	}
+	return disabled, nil
+}
+
+// mergeRuleIDs returns the sorted union of two rule ID lists
+func mergeRuleIDs(a, b []ir.RuleID) []ir.RuleID {
+	if len(b) == 0 {
+		return a
+	}
+	seen := make(map[ir.RuleID]bool, len(a)+len(b))
+	merged := make([]ir.RuleID, 0, len(a)+len(b))
+	for _, ruleId := range append(append([]ir.RuleID(nil), a...), b...) {
+		if !seen[ruleId] {
+			seen[ruleId] = true
+			merged = append(merged, ruleId)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i] < merged[j] })
+	return merged
+}
@@ -0,0 +1,70 @@
+package dag
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+func TestRuleComplexity(t *testing.T) {
+	ruleset := createBatchTestRuleset()
+	ruleset.Primitives[1].Values = []string{"powershell", "pwsh"}
+
+	// Rule 1: AND(P0, P1); rule 2: NOT(P1)
+	if complexity := RuleComplexity(ruleset.Dag, 1, ruleset.Primitives); complexity != 4 {
+		t.Errorf("Expected rule 1 complexity 4, got %d", complexity)
+	}
+	if complexity := RuleComplexity(ruleset.Dag, 2, ruleset.Primitives); complexity != 3 {
+		t.Errorf("Expected rule 2 complexity 3, got %d", complexity)
+	}
+	if complexity := RuleComplexity(ruleset.Dag, 99, ruleset.Primitives); complexity != 0 {
+		t.Errorf("Expected unknown rule complexity 0, got %d", complexity)
+	}
+}
+
+func TestComplexityPolicy(t *testing.T) {
+	event := map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	engine, err := NewDagEngineBuilder().WithLogger(logger).WithMaxRuleComplexity(2, ComplexityWarn).BuildFromRuleset(createBatchTestRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if result, _ := engine.Evaluate(event); !reflect.DeepEqual(result.MatchedRules, []ir.RuleID{1}) {
+		t.Errorf("Expected warn policy to keep rule 1 active, got %v", result.MatchedRules)
+	}
+	if !strings.Contains(buf.String(), "rule exceeds maximum complexity") || !strings.Contains(buf.String(), "rule_id=1") {
+		t.Errorf("Expected a complexity warning for rule 1, got: %s", buf.String())
+	}
+
+	engine, err = NewDagEngineBuilder().WithMaxRuleComplexity(2, ComplexityDisable).BuildFromRuleset(createBatchTestRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if result, _ := engine.Evaluate(event); len(result.MatchedRules) != 0 {
+		t.Errorf("Expected disable policy to deactivate rule 1, got %v", result.MatchedRules)
+	}
+	if engine.ActiveRuleCount() != 1 {
+		t.Errorf("Expected 1 active rule, got %d", engine.ActiveRuleCount())
+	}
+
+	_, err = NewDagEngineBuilder().WithMaxRuleComplexity(2, ComplexityReject).BuildFromRuleset(createBatchTestRuleset())
+	var sigmaErr *sigmaerrors.SigmaError
+	if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeTooManyOperations {
+		t.Fatalf("Expected too many operations error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "rule 1") {
+		t.Errorf("Expected error to name rule 1, got %v", err)
+	}
+
+	if _, err := NewDagEngineBuilder().WithMaxRuleComplexity(3, ComplexityReject).BuildFromRuleset(createBatchTestRuleset()); err != nil {
+		t.Errorf("Expected rules within the limit to build, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package dag
+
+import (
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Reasons an event is dead-lettered
+const (
+	// The raw event is not valid JSON
+	DeadLetterParse = "parse"
+	// Evaluating the event failed, e.g. a primitive error under
+	// ErrorFailFast, an enricher error or an unsupported event type
+	DeadLetterEvaluation = "evaluation"
+	// The event was evaluated, but with at least DeadLetterThreshold
+	// primitive errors treated as no match, so its result is unreliable
+	DeadLetterPrimitiveErrors = "primitive_errors"
+)
+
+// DeadLetter is an event that could not be evaluated reliably, with the
+// context of its failure
+type DeadLetter struct {
+	// Event as received: the JSON string for EvaluateRaw, otherwise the
+	// event passed to evaluation
+	Event interface{}
+
+	// One of the DeadLetter reasons, and the error behind it (nil for
+	// DeadLetterPrimitiveErrors)
+	Reason string
+	Err    error
+
+	// Primitive errors treated as no match while evaluating the event
+	PrimitiveErrors int
+
+	// When the event was dead-lettered, on the engine clock
+	Time time.Time
+}
+
+// DeadLetterSink receives dead-lettered events. Sinks are called on the
+// evaluating goroutine, with the engine lock held except for parse
+// failures, so they should hand letters off (e.g. to a queue) rather than
+// block.
+type DeadLetterSink interface {
+	DeadLetter(letter DeadLetter)
+}
+
+// DeadLetterFunc adapts a function to a DeadLetterSink
+type DeadLetterFunc func(letter DeadLetter)
+
+func (f DeadLetterFunc) DeadLetter(letter DeadLetter) {
+	f(letter)
+}
+
+// WithDeadLetterSink routes events that cannot be evaluated to a sink
+func (b *DagEngineBuilder) WithDeadLetterSink(sink DeadLetterSink) *DagEngineBuilder {
+	b.config.DeadLetterSink = sink
+	return b
+}
+
+// WithDeadLetterThreshold also dead-letters evaluated events with at
+// least threshold primitive errors treated as no match (0 = never)
+func (b *DagEngineBuilder) WithDeadLetterThreshold(threshold int) *DagEngineBuilder {
+	b.config.DeadLetterThreshold = threshold
+	return b
+}
+
+// deadLetter routes an event to the dead-letter sink, if one is set
+func (e *DagEngine) deadLetter(event interface{}, reason string, err error, primitiveErrors int) {
+	if e.config.DeadLetterSink == nil {
+		return
+	}
+	atomic.AddUint64(&e.deadLetters, 1)
+	e.config.DeadLetterSink.DeadLetter(DeadLetter{
+		Event:           event,
+		Reason:          reason,
+		Err:             err,
+		PrimitiveErrors: primitiveErrors,
+		Time:            e.clock.Now(),
+	})
+}
+
+// deadLetterUnreliable dead-letters the evaluated events whose primitive
+// errors reach the threshold. Callers hold the engine lock.
+func (e *DagEngine) deadLetterUnreliable(events []interface{}, results []*DagEvaluationResult) {
+	threshold := e.config.DeadLetterThreshold
+	if threshold <= 0 || e.config.DeadLetterSink == nil {
+		return
+	}
+	for i, result := range results {
+		if result != nil && result.PrimitiveErrors >= threshold {
+			e.log().Debug("event dead-lettered",
+				slog.String("reason", DeadLetterPrimitiveErrors),
+				slog.Int("primitive_errors", result.PrimitiveErrors))
+			e.deadLetter(events[i], DeadLetterPrimitiveErrors, nil, result.PrimitiveErrors)
+		}
+	}
+}
+
+// validateDeadLetterConfig checks the dead-letter settings
+func validateDeadLetterConfig(config DagEngineConfig) error {
+	if config.DeadLetterThreshold < 0 {
+		return fmt.Errorf("dead-letter threshold %d is negative", config.DeadLetterThreshold)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package dag
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+func TestDeadLetterSink(t *testing.T) {
+	var letters []DeadLetter
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	ruleset, strict := createFailingRuleset()
+	engine, err := NewDagEngineBuilder().
+		WithPrefilter(false).
+		WithCustomModifier("strict", strict).
+		WithClock(clock.NewManual(now)).
+		WithDeadLetterSink(DeadLetterFunc(func(letter DeadLetter) {
+			letters = append(letters, letter)
+		})).
+		BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	if _, err := engine.EvaluateRaw(`{"EventID": `); err == nil {
+		t.Error("Expected a parse error")
+	}
+	bad := map[string]interface{}{"EventID": "4624", "ProcessName": "bad.exe"}
+	if _, err := engine.Evaluate(bad); err == nil {
+		t.Error("Expected an evaluation error")
+	}
+	good := map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"}
+	engine.EvaluateBatchPartial([]interface{}{good, "EventID=4624"})
+
+	if len(letters) != 3 {
+		t.Fatalf("Expected 3 dead letters, got %+v", letters)
+	}
+	if letters[0].Reason != DeadLetterParse || letters[0].Event != `{"EventID": ` || letters[0].Err == nil || !letters[0].Time.Equal(now) {
+		t.Errorf("Unexpected parse dead letter: %+v", letters[0])
+	}
+	if letters[1].Reason != DeadLetterEvaluation || !sigmaerrors.IsType(letters[1].Err, sigmaerrors.ErrorTypeExecution) {
+		t.Errorf("Unexpected evaluation dead letter: %+v", letters[1])
+	}
+	if letters[2].Event != "EventID=4624" || !errors.Is(letters[2].Err, matcher.ErrUnsupportedEvent) {
+		t.Errorf("Unexpected batch dead letter: %+v", letters[2])
+	}
+	if stats := engine.Stats(); stats.DeadLetters != 3 {
+		t.Errorf("Expected 3 dead letters in the stats, got %d", stats.DeadLetters)
+	}
+}
+
+func TestDeadLetterThreshold(t *testing.T) {
+	var letters []DeadLetter
+	ruleset, strict := createFailingRuleset()
+	engine, err := NewDagEngineBuilder().
+		WithPrefilter(false).
+		WithCustomModifier("strict", strict).
+		WithPrimitiveErrorPolicy(ErrorNoMatch).
+		WithDeadLetterThreshold(1).
+		WithDeadLetterSink(DeadLetterFunc(func(letter DeadLetter) {
+			letters = append(letters, letter)
+		})).
+		BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	good := map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"}
+	bad := map[string]interface{}{"EventID": "4624", "ProcessName": "bad.exe"}
+	results, err := engine.EvaluateBatch([]interface{}{good, bad})
+	if err != nil {
+		t.Fatalf("Batch evaluation failed: %v", err)
+	}
+	if results[0].PrimitiveErrors != 0 || results[1].PrimitiveErrors != 1 {
+		t.Errorf("Unexpected primitive errors: %d and %d", results[0].PrimitiveErrors, results[1].PrimitiveErrors)
+	}
+	if result, err := engine.Evaluate(bad); err != nil || result.PrimitiveErrors != 1 {
+		t.Errorf("Expected the event evaluated with 1 primitive error, got %v, %v", result, err)
+	}
+
+	// The unreliable events are evaluated and dead-lettered
+	if len(letters) != 2 || letters[0].Reason != DeadLetterPrimitiveErrors || letters[0].PrimitiveErrors != 1 {
+		t.Fatalf("Unexpected dead letters: %+v", letters)
+	}
+	if event, _ := letters[1].Event.(map[string]interface{}); event["ProcessName"] != "bad.exe" {
+		t.Errorf("Expected the failing event, got %v", letters[1].Event)
+	}
+
+	if _, err := NewDagEngineBuilder().WithDeadLetterThreshold(-1).BuildFromRuleset(createBatchTestRuleset()); !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeConfig) {
+		t.Errorf("Expected a config error for a negative threshold, got %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package dag
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+)
+
+// Vocabulary shared by generated primitives and events, kept small so
+// random events match random rules often enough to be interesting
+var (
+	differentialFields     = []string{"EventID", "Image", "User", "CommandLine"}
+	differentialMatchTypes = []string{"equals", "contains", "startswith", "endswith"}
+	differentialValues     = []string{"4624", "cmd", "powershell", "admin", "system", "-enc"}
+)
+
+// randomRuleset generates a ruleset of random rules over a shared primitive
+// pool. Primitive nodes are shared between rules like the compiler's CSE;
+// logical nodes are created per rule. The same seed always produces the
+// same ruleset.
+func randomRuleset(seed int64, ruleCount int) *CompiledRuleset {
+	rng := rand.New(rand.NewSource(seed))
+
+	primitives := make([]Primitive, 1+rng.Intn(6))
+	for i := range primitives {
+		values := make([]string, 1+rng.Intn(2))
+		for j := range values {
+			values[j] = differentialValues[rng.Intn(len(differentialValues))]
+		}
+		primitives[i] = Primitive{
+			ID:        uint32(i),
+			Field:     differentialFields[rng.Intn(len(differentialFields))],
+			MatchType: differentialMatchTypes[rng.Intn(len(differentialMatchTypes))],
+			Values:    values,
+			Modifiers: []string{},
+		}
+	}
+
+	dag := NewCompiledDag()
+	addNode := func(nodeType NodeType, dependencies ...NodeId) NodeId {
+		nodeId := NodeId(len(dag.Nodes))
+		node := NewDagNode(nodeId, nodeType)
+		node.Dependencies = dependencies
+		dag.Nodes = append(dag.Nodes, *node)
+		for _, depId := range dependencies {
+			dag.Nodes[depId].Dependents = append(dag.Nodes[depId].Dependents, nodeId)
+		}
+		// Nodes are created after their dependencies, so creation order is
+		// a topological order
+		dag.ExecutionOrder = append(dag.ExecutionOrder, nodeId)
+		return nodeId
+	}
+
+	var condition func(depth int) NodeId
+	condition = func(depth int) NodeId {
+		if depth == 0 || rng.Intn(3) == 0 {
+			primitiveId := ir.PrimitiveID(rng.Intn(len(primitives)))
+			if nodeId, exists := dag.PrimitiveMap[primitiveId]; exists {
+				return nodeId
+			}
+			nodeId := addNode(NewPrimitiveNodeType(primitiveId))
+			dag.PrimitiveMap[primitiveId] = nodeId
+			return nodeId
+		}
+
+		switch rng.Intn(3) {
+		case 0:
+			return addNode(NewLogicalNodeType(LogicalNot), condition(depth-1))
+		default:
+			operation := LogicalAnd
+			if rng.Intn(2) == 0 {
+				operation = LogicalOr
+			}
+			dependencies := make([]NodeId, 2+rng.Intn(2))
+			for i := range dependencies {
+				dependencies[i] = condition(depth - 1)
+			}
+			return addNode(NewLogicalNodeType(operation), dependencies...)
+		}
+	}
+
+	for i := 0; i < ruleCount; i++ {
+		ruleId := ir.RuleID(i + 1)
+		dag.RuleResults[ruleId] = addNode(NewResultNodeType(ruleId), condition(3))
+	}
+	dag.ResultBufferSize = len(dag.Nodes)
+
+	return &CompiledRuleset{
+		Primitives:   primitives,
+		PrimitiveMap: map[uint32]*CompiledPrimitive{},
+		Dag:          dag,
+	}
+}
+
+// randomEvent generates an event over the differential vocabulary
+func randomEvent(rng *rand.Rand) map[string]interface{} {
+	event := make(map[string]interface{})
+	for _, field := range differentialFields {
+		if rng.Intn(4) == 0 {
+			continue
+		}
+		value := differentialValues[rng.Intn(len(differentialValues))]
+		if rng.Intn(2) == 0 {
+			value = fmt.Sprintf("%s %s", value, differentialValues[rng.Intn(len(differentialValues))])
+		}
+		event[field] = value
+	}
+	return event
+}
+
+// sortedRules returns a sorted copy of matched rules, never nil
+func sortedRules(rules []ir.RuleID) []ir.RuleID {
+	sorted := append([]ir.RuleID{}, rules...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// checkBackendsAgree evaluates events on every backend and evaluation path
+// of the ruleset generated from seed, reporting any disagreement with the
+// tree-walking interpreter
+func checkBackendsAgree(t *testing.T, seed int64, ruleCount int, events []map[string]interface{}) {
+	t.Helper()
+
+	reference := randomRuleset(seed, ruleCount)
+	primitives, err := buildPrimitiveMap(reference)
+	if err != nil {
+		t.Fatalf("seed %d: failed to build primitives: %v", seed, err)
+	}
+	interp := NewTreeInterpreter(reference.Dag, primitives)
+	evaluator := NewDagEvaluatorWithCompiledPrimitives(reference.Dag, primitives)
+	fastPath := len(reference.Dag.RuleResults) == 1 && len(reference.Dag.Nodes) <= 3
+
+	// Engines optimize and compact their DAG, so each gets its own copy
+	engines := make(map[string]*DagEngine)
+	for _, backend := range []Backend{BackendDAG, BackendVM} {
+		for _, optimize := range []bool{false, true} {
+			engine, err := NewDagEngineBuilder().
+				WithOptimization(optimize).
+				WithPrefilter(false).
+				WithBackend(backend).
+				BuildFromRuleset(randomRuleset(seed, ruleCount))
+			if err != nil {
+				t.Fatalf("seed %d: failed to create %s engine: %v", seed, backend, err)
+			}
+			engines[fmt.Sprintf("%s engine (optimize=%t)", backend, optimize)] = engine
+		}
+	}
+	factoring, err := NewDagEngineBuilder().
+		WithOptimizationLevel(3).
+		WithPrefilter(false).
+		BuildFromRuleset(randomRuleset(seed, ruleCount))
+	if err != nil {
+		t.Fatalf("seed %d: failed to create factoring engine: %v", seed, err)
+	}
+	engines["dag engine (level 3)"] = factoring
+
+	batch := make([]interface{}, len(events))
+	for i, event := range events {
+		batch[i] = event
+	}
+	batchResults, err := engines["dag engine (optimize=true)"].EvaluateBatch(batch)
+	if err != nil {
+		t.Fatalf("seed %d: batch evaluation failed: %v", seed, err)
+	}
+
+	for i, event := range events {
+		expected, err := interp.Evaluate(event)
+		if err != nil {
+			t.Fatalf("seed %d: interpreter failed: %v", seed, err)
+		}
+
+		if anyMatch, err := interp.EvaluateAnyMatch(event); err != nil || anyMatch != (len(expected.MatchedRules) > 0) {
+			t.Errorf("seed %d, event %v: interpreter any-match returned %t, %v for matches %v",
+				seed, event, anyMatch, err, sortedRules(expected.MatchedRules))
+		}
+
+		actual := map[string][]ir.RuleID{"dag batch": batchResults[i].MatchedRules}
+		evaluator.eventCtx = matcher.NewEventContext(event)
+		standard := &DagEvaluationResult{}
+		if err := evaluator.evaluateStandardPath(event, standard); err != nil {
+			t.Fatalf("seed %d: standard path failed: %v", seed, err)
+		}
+		actual["dag standard path"] = standard.MatchedRules
+		if fastPath {
+			fast := &DagEvaluationResult{}
+			if err := evaluator.evaluateSinglePrimitiveFast(event, fast); err != nil {
+				t.Fatalf("seed %d: fast path failed: %v", seed, err)
+			}
+			actual["dag fast path"] = fast.MatchedRules
+		}
+		for name, engine := range engines {
+			result, err := engine.Evaluate(event)
+			if err != nil {
+				t.Fatalf("seed %d: %s failed: %v", seed, name, err)
+			}
+			actual[name] = result.MatchedRules
+
+			anyMatch, err := engine.EvaluateAnyMatch(event)
+			if err != nil {
+				t.Fatalf("seed %d: %s any-match failed: %v", seed, name, err)
+			}
+			if anyMatch != (len(expected.MatchedRules) > 0) {
+				t.Errorf("seed %d, event %v: %s any-match returned %t, interpreter matched %v",
+					seed, event, name, anyMatch, sortedRules(expected.MatchedRules))
+			}
+		}
+
+		for name, matched := range actual {
+			if !reflect.DeepEqual(sortedRules(matched), sortedRules(expected.MatchedRules)) {
+				t.Errorf("seed %d, event %v: %s matched %v, interpreter matched %v",
+					seed, event, name, sortedRules(matched), sortedRules(expected.MatchedRules))
+			}
+		}
+	}
+}
+
+func TestBackendsAgreeOnRandomRulesets(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for seed := int64(0); seed < 200; seed++ {
+		events := make([]map[string]interface{}, 20)
+		for i := range events {
+			events[i] = randomEvent(rng)
+		}
+		// Single-rule rulesets exercise the DAG fast path
+		checkBackendsAgree(t, seed, 1+int(seed%4), events)
+	}
+}
+
+func FuzzBackendsAgree(f *testing.F) {
+	f.Add(int64(0), uint8(1), int64(0))
+	f.Add(int64(7), uint8(3), int64(42))
+	f.Fuzz(func(t *testing.T, rulesetSeed int64, ruleCount uint8, eventSeed int64) {
+		rng := rand.New(rand.NewSource(eventSeed))
+		events := make([]map[string]interface{}, 8)
+		for i := range events {
+			events[i] = randomEvent(rng)
+		}
+		checkBackendsAgree(t, rulesetSeed, 1+int(ruleCount%8), events)
+	})
+}
@@ -2,12 +2,20 @@
 package dag
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
 	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/logging"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
 )
 
 // DagEngineConfig controls DAG engine behavior and optimization
@@ -19,9 +27,14 @@ type DagEngineConfig struct {
 	// 0: No optimization (fastest compilation)
 	// 1: Basic optimizations (DCE, constant folding)
 	// 2: Standard optimizations (CSE, reordering) - Default
-	// 3: Aggressive optimizations (all techniques)
+	// 3: Aggressive optimizations (all techniques, including factoring
+	//    operands shared across rules)
 	OptimizationLevel uint8
 
+	// Custom optimization passes, run after the built-in rewrites and
+	// before dead code elimination
+	OptimizerPasses []Pass `json:"-"`
+
 	// Enable parallel processing for rule evaluation
 	EnableParallelProcessing bool
 
@@ -30,11 +43,142 @@ type DagEngineConfig struct {
 
 	// Enable literal prefiltering for fast event elimination
 	EnablePrefilter bool
+
+	// How the prefilter looks up event values (PrefilterAuto = chosen by
+	// the number of literal patterns)
+	PrefilterStrategy PrefilterStrategy
+
+	// Reject raw JSON events before parsing them when they contain none of
+	// the literal words the rules require (see RawPrefilter). Ignored when
+	// a rule requires no literal word, or with enrichers, whose fields the
+	// raw event does not contain yet.
+	EnableRawPrefilter bool
+
+	// Group rules by the literal words they require and evaluate only the
+	// groups of the words in each event (see RuleGroupIndex). Only the DAG
+	// backend groups rules.
+	EnableRuleGrouping bool
+
+	// Collect per-rule match details (matched fields, values, selections)
+	CollectMatchDetails bool
+
+	// Attach the values of each matched rule's `fields:` list to its RuleMatch
+	CaptureRuleFields bool
+
+	// Minimum rule level to evaluate (LevelUnknown = no minimum). Rules below
+	// the minimum, including rules without a level, are inactive.
+	MinRuleLevel RuleLevel
+
+	// Rule levels to evaluate (empty = all levels)
+	RuleLevels []RuleLevel
+
+	// Restricts which rules are included (nil = all rules). Compilers that
+	// implement FilteringCompiler skip rejected rules before compiling them;
+	// otherwise rejected rules are compiled but inactive.
+	RuleFilter RuleFilter `json:"-"`
+
+	// Shares compiled primitive matchers with other engines (nil = private)
+	PrimitiveCache *PrimitiveCache `json:"-"`
+
+	// Maximum estimated memory of the compiled engine in bytes (0 = unlimited).
+	// Building an engine that exceeds the budget fails.
+	MemoryBudgetBytes int
+
+	// Maximum estimated per-event cost of a single rule (0 = unlimited),
+	// see RuleComplexity. ComplexityPolicy decides whether rules over the
+	// limit are logged, fail the build or are deactivated.
+	MaxRuleComplexity int
+	ComplexityPolicy  ComplexityPolicy
+
+	// Skip modifiers missing from the matcher registry instead of failing
+	// the build (strict by default, since skipping changes rule semantics)
+	LenientModifiers bool
+
+	// Matchers and modifiers available to rules (nil = built-ins only).
+	// Compilers implementing MatcherRegistryCompiler resolve custom match
+	// types from YAML modifiers against it. Ignored for primitive matchers
+	// when PrimitiveCache is set, which compiles with its own registry.
+	MatcherRegistry *matcher.MatcherRegistry `json:"-"`
+
+	// How nested event fields are resolved: walked per primitive (default),
+	// flattened to dotted keys once per event, or chosen from the ruleset's
+	// field depth statistics
+	EventFlattening EventFlattening
+
+	// How rule conditions are evaluated: the shared DAG (default), one
+	// bytecode program per rule, or a reference tree-walking interpreter.
+	// Only the DAG backend collects match details.
+	Backend Backend
+
+	// Maximum matching time per event (0 = unlimited). Evaluation fails
+	// with an ErrorTypeExecutionTimeout error once an event runs over, so a
+	// pathological event cannot stall the pipeline. Batches evaluated
+	// together share a budget of EventTimeout per event.
+	EventTimeout time.Duration
+
+	// Resolve event fields missing under a rule's field name to a field
+	// whose name differs only in case (CommandLine vs commandline), for
+	// producers that disagree on casing. Each event's keys are indexed on
+	// its first missing field.
+	CaseInsensitiveFields bool
+
+	// Enrichers run in order on every map event before it is evaluated,
+	// adding fields rules can match on (see Enricher)
+	Enrichers []Enricher `json:"-"`
+
+	// Actions run after every match, followed by the actions of the
+	// matched rule keyed by SIGMA UUID (see MatchAction)
+	Actions     []MatchAction            `json:"-"`
+	RuleActions map[string][]MatchAction `json:"-"`
+
+	// Number of matches whose actions run at once (0 = 4), and number of
+	// matches waiting for a worker (0 = 1024) before evaluation blocks
+	ActionConcurrency int
+	ActionQueueSize   int
+
+	// Maximum time of a single action run (0 = unlimited)
+	ActionTimeout time.Duration
+
+	// Evaluate one of every SampleRate events (0 or 1 = every event). With
+	// SampleField set, events are sampled per value of the field (e.g. the
+	// log channel), at the value's rate in SampleRates or else SampleRate.
+	// Skipped events yield no matches and are counted in EngineStats.
+	SampleRate  int
+	SampleField string
+	SampleRates map[string]int
+
+	// Maximum evaluated events per second (0 = unlimited), with bursts of
+	// up to RateBurst events (0 = one second of events). Events over the
+	// limit are skipped after sampling.
+	RateLimit float64
+	RateBurst int
+
+	// What happens when a primitive fails at runtime: fail the event (and
+	// its batch), or treat the primitive as not matching and count the
+	// error (see ErrorPolicy)
+	PrimitiveErrorPolicy ErrorPolicy
+
+	// Receives events that cannot be parsed or evaluated, with their error
+	// (nil = errors are only returned). With DeadLetterThreshold set,
+	// evaluated events with at least that many primitive errors treated as
+	// no match are dead-lettered too. Evaluate, EvaluateInto, EvaluateRaw
+	// and EvaluateBatchPartial dead-letter failed events; the other paths
+	// cannot tell which event failed and only return the error.
+	DeadLetterSink      DeadLetterSink `json:"-"`
+	DeadLetterThreshold int
+
+	// Time source of time-based features such as evaluation timing,
+	// auto-tuning measurements and event timeouts (nil = system clock)
+	Clock clock.Clock `json:"-"`
+
+	// Logger receives structured engine logs (nil = discard)
+	Logger *slog.Logger `json:"-"`
 }
 
 // ParallelConfig contains parallel processing settings
 type ParallelConfig struct {
-	// Number of threads to use (0 = auto-detect)
+	// Number of threads to use (0 = auto-detect from GOMAXPROCS, capped
+	// by the container's cgroup CPU quota)
 	NumThreads int
 
 	// Minimum number of rules per thread
@@ -45,6 +189,10 @@ type ParallelConfig struct {
 
 	// Minimum batch size to enable parallelism
 	MinBatchSizeForParallelism int
+
+	// Measure batch throughput at different worker counts and chunk sizes
+	// and converge on the fastest (NumThreads caps the worker count)
+	AutoTune bool
 }
 
 // DefaultDagEngineConfig returns a default configuration
@@ -61,7 +209,7 @@ func DefaultDagEngineConfig() DagEngineConfig {
 // DefaultParallelConfig returns default parallel configuration
 func DefaultParallelConfig() ParallelConfig {
 	return ParallelConfig{
-		NumThreads:                 4, // Default to 4 threads
+		NumThreads:                 0, // Auto-detect
 		MinRulesPerThread:          10,
 		EnableEventParallelism:     true,
 		MinBatchSizeForParallelism: 100,
@@ -116,9 +264,59 @@ type DagEngine struct {
 	batchEvaluator    *BatchDagEvaluator
 	parallelEvaluator *ParallelDagEvaluator
 
+	// Alternate backend used instead of the DAG evaluators (nil for the
+	// DAG backend)
+	backend EvaluatorBackend
+
 	// Optional prefilter for literal pattern matching
 	prefilter *LiteralPrefilter
 
+	// Optional prefilter over raw event words, checked before parsing
+	rawPrefilter *RawPrefilter
+
+	// Rules grouped by anchor word (nil when rule grouping is disabled)
+	ruleGroups *RuleGroupIndex
+
+	// Workers running post-match actions (nil without actions)
+	actions *actionDispatcher
+
+	// Sampling and rate limiting (nil when every event is evaluated)
+	sampler *eventSampler
+
+	// Primitive errors absorbed under ErrorNoMatch (nil = fail fast)
+	primitiveErrors *primitiveErrors
+
+	// Events routed to the dead-letter sink
+	deadLetters uint64
+
+	// Source metadata of the compiled rules, and rule IDs by SIGMA UUID
+	rules     map[ir.RuleID]RuleMeta
+	ruleUUIDs map[string]ir.RuleID
+
+	// Rules excluded from matching by the level and rule filters
+	inactiveRules []ir.RuleID
+
+	// Rules from the most to the least severe level, built on first use by
+	// EvaluatePriority
+	priorityOrder []ruleResultNode
+
+	// Estimated memory of the compiled state, computed at build time
+	memoryUsage MemoryUsage
+
+	// Statistics of the optimization passes run at build time
+	passStatistics []PassStatistics
+
+	// Field depth statistics of the ruleset and whether events are
+	// flattened at ingest
+	fieldDepth    FieldDepthStats
+	flattenEvents bool
+
+	// Component-scoped logger
+	logger *slog.Logger
+
+	// Time source
+	clock clock.Clock
+
 	// Mutex for thread safety
 	mu sync.Mutex
 }
@@ -131,13 +329,26 @@ type CompiledPrimitive struct {
 	Values      []string
 	Modifiers   []string
 	MatcherFunc func(interface{}) bool
+
+	// Event field paths read, in order, when an event lacks Field
+	FieldAliases []string
+
+	// Registry-backed matcher (nil when the match type is not registered)
+	Matcher *matcher.CompiledPrimitive
 }
 
 // LiteralPrefilter provides fast literal pattern matching
 type LiteralPrefilter struct {
+	// Literal patterns (nil for the Bloom filter, which does not keep them)
 	patterns   map[string]bool
+	index      prefilterIndex
 	fieldCount int
 	stats      *PrefilterStats
+
+	// Outcomes observed on evaluated events
+	observed      atomic.Uint64
+	hits          atomic.Uint64
+	missedMatches atomic.Uint64
 }
 
 // PrefilterStats contains prefilter performance statistics
@@ -146,21 +357,41 @@ type PrefilterStats struct {
 	FieldCount           int
 	EstimatedSelectivity float64
 	StrategyName         string
+
+	// Events evaluated since the engine was built, and how many of them
+	// the prefilter passed (Hits) or would have dropped (Misses)
+	EventsObserved uint64
+	Hits           uint64
+	Misses         uint64
+
+	// Dropped events that matched a rule anyway. Skipping them would lose
+	// detections, so prefiltering does not suit a ruleset with missed
+	// matches.
+	MissedMatches uint64
+
+	// Fraction of observed events the prefilter passed (0 before any event
+	// was observed). The lower it is, the more evaluation prefiltering
+	// would save on the traffic seen so far.
+	ObservedSelectivity float64
 }
 
 // BatchDagEvaluator provides high-performance batch evaluation
 type BatchDagEvaluator struct {
 	dag                       *CompiledDag
 	primitives                map[uint32]*CompiledPrimitive
+	options                   evaluatorOptions
 	memoryPool                *BatchMemoryPool
 	totalNodesEvaluated       int
 	totalPrimitiveEvaluations int
 }
 
-// BatchMemoryPool manages memory allocation for batch processing
+// BatchMemoryPool manages memory allocation for batch processing.
+// Results are stored as bit matrices: one event-vector per node (and per
+// primitive), with bit i set when event i of the batch matched.
 type BatchMemoryPool struct {
-	nodeResults      [][]bool
-	primitiveResults [][]bool
+	nodeResults      []Bitset
+	primitiveResults []Bitset
+	backing          []uint64
 	batchSize        int
 	nodeCount        int
 	primitiveCount   int
@@ -170,8 +401,11 @@ type BatchMemoryPool struct {
 type ParallelDagEvaluator struct {
 	dag                       *CompiledDag
 	primitives                map[uint32]*CompiledPrimitive
+	options                   evaluatorOptions
 	config                    ParallelConfig
 	rulePartitions            []RulePartition
+	tuner                     *ParallelTuner
+	clock                     clock.Clock
 	totalNodesEvaluated       int
 	totalPrimitiveEvaluations int
 }
@@ -185,8 +419,9 @@ type RulePartition struct {
 
 // DagEngineBuilder provides a builder pattern for creating DagEngine
 type DagEngineBuilder struct {
-	compiler Compiler
-	config   DagEngineConfig
+	compiler       Compiler
+	config         DagEngineConfig
+	extensionPaths []string
 }
 
 // Compiler interface for rule compilation
@@ -194,10 +429,29 @@ type Compiler interface {
 	CompileRules(rules []string) (*CompiledRuleset, error)
 }
 
+// MatcherRegistryCompiler is a Compiler that resolves match types and
+// modifiers against a matcher registry
+type MatcherRegistryCompiler interface {
+	Compiler
+	SetMatcherRegistry(registry *matcher.MatcherRegistry)
+}
+
+// FilteringCompiler is a Compiler that can skip rules before compiling them
+type FilteringCompiler interface {
+	Compiler
+	CompileRulesWithFilter(rules []string, filter RuleFilter) (*CompiledRuleset, error)
+}
+
 // CompiledRuleset represents a compiled set of rules
 type CompiledRuleset struct {
 	Primitives   []Primitive
 	PrimitiveMap map[uint32]*CompiledPrimitive
+
+	// Compiled rule DAG (nil = no rule logic, primitives only)
+	Dag *CompiledDag
+
+	// Source metadata of the compiled rules
+	Rules []RuleMeta
 }
 
 // Primitive represents a basic matching primitive
@@ -207,6 +461,15 @@ type Primitive struct {
 	MatchType string
 	Values    []string
 	Modifiers []string
+
+	// Kind of each value (nil = all strings)
+	ValueKinds []ir.ValueKind
+
+	// Event field paths read, in order, when an event lacks Field
+	FieldAliases []string `json:",omitempty"`
+
+	// Compare strings ignoring case, the SIGMA default
+	IgnoreCase bool `json:",omitempty"`
 }
 
 // NewDagEngineBuilder creates a new DAG engine builder
@@ -240,26 +503,238 @@ func (b *DagEngineBuilder) WithOptimizationLevel(level uint8) *DagEngineBuilder
 	return b
 }
 
+// WithOptimizerPass adds a custom optimization pass
+func (b *DagEngineBuilder) WithOptimizerPass(pass Pass) *DagEngineBuilder {
+	b.config.OptimizerPasses = append(b.config.OptimizerPasses, pass)
+	return b
+}
+
 // WithParallelProcessing enables or disables parallel processing
 func (b *DagEngineBuilder) WithParallelProcessing(enable bool) *DagEngineBuilder {
 	b.config.EnableParallelProcessing = enable
 	return b
 }
 
+// WithAutoTuning enables runtime tuning of the parallel batch worker pool
+func (b *DagEngineBuilder) WithAutoTuning(enable bool) *DagEngineBuilder {
+	b.config.ParallelConfig.AutoTune = enable
+	return b
+}
+
 // WithPrefilter enables or disables prefiltering
 func (b *DagEngineBuilder) WithPrefilter(enable bool) *DagEngineBuilder {
 	b.config.EnablePrefilter = enable
 	return b
 }
 
+// WithPrefilterStrategy selects how the prefilter looks up event values
+func (b *DagEngineBuilder) WithPrefilterStrategy(strategy PrefilterStrategy) *DagEngineBuilder {
+	b.config.PrefilterStrategy = strategy
+	return b
+}
+
+// WithRawPrefilter enables or disables rejecting raw events before parsing
+func (b *DagEngineBuilder) WithRawPrefilter(enable bool) *DagEngineBuilder {
+	b.config.EnableRawPrefilter = enable
+	return b
+}
+
+// WithRuleGrouping enables or disables evaluating rules by anchor group
+func (b *DagEngineBuilder) WithRuleGrouping(enable bool) *DagEngineBuilder {
+	b.config.EnableRuleGrouping = enable
+	return b
+}
+
+// WithMatchDetails enables or disables per-rule match details in results
+func (b *DagEngineBuilder) WithMatchDetails(enable bool) *DagEngineBuilder {
+	b.config.CollectMatchDetails = enable
+	return b
+}
+
+// WithMinLevel only evaluates rules at or above the given level
+func (b *DagEngineBuilder) WithMinLevel(level RuleLevel) *DagEngineBuilder {
+	b.config.MinRuleLevel = level
+	return b
+}
+
+// WithLevels only evaluates rules with one of the given levels
+func (b *DagEngineBuilder) WithLevels(levels ...RuleLevel) *DagEngineBuilder {
+	b.config.RuleLevels = append([]RuleLevel(nil), levels...)
+	return b
+}
+
+// WithRuleFilter restricts the engine to rules accepted by the filter.
+// Multiple filters are combined so that a rule must pass all of them.
+func (b *DagEngineBuilder) WithRuleFilter(filter RuleFilter) *DagEngineBuilder {
+	if b.config.RuleFilter == nil {
+		b.config.RuleFilter = filter
+	} else {
+		b.config.RuleFilter = AllOf(b.config.RuleFilter, filter)
+	}
+	return b
+}
+
+// WithTagFilter restricts the engine to rules with a tag matching one of the patterns
+func (b *DagEngineBuilder) WithTagFilter(patterns ...string) *DagEngineBuilder {
+	return b.WithRuleFilter(FilterByTags(patterns...))
+}
+
+// WithLogSourceFilter restricts the engine to rules for the given log source
+func (b *DagEngineBuilder) WithLogSourceFilter(category, product, service string) *DagEngineBuilder {
+	return b.WithRuleFilter(FilterByLogSource(category, product, service))
+}
+
+// WithStatusFilter restricts the engine to rules with one of the given statuses
+func (b *DagEngineBuilder) WithStatusFilter(statuses ...string) *DagEngineBuilder {
+	return b.WithRuleFilter(FilterByStatus(statuses...))
+}
+
+// WithPrimitiveCache shares compiled primitive matchers with other engines
+func (b *DagEngineBuilder) WithPrimitiveCache(cache *PrimitiveCache) *DagEngineBuilder {
+	b.config.PrimitiveCache = cache
+	return b
+}
+
+// WithMemoryBudget sets the maximum estimated memory of the compiled engine
+func (b *DagEngineBuilder) WithMemoryBudget(bytes int) *DagEngineBuilder {
+	b.config.MemoryBudgetBytes = bytes
+	return b
+}
+
+// WithLenientModifiers skips unknown modifiers instead of rejecting the ruleset
+func (b *DagEngineBuilder) WithLenientModifiers(enable bool) *DagEngineBuilder {
+	b.config.LenientModifiers = enable
+	return b
+}
+
+// WithMatcherRegistry sets the registry used to compile and evaluate rule
+// matchers, so custom match types and modifiers registered on it can be
+// used from rule YAML
+func (b *DagEngineBuilder) WithMatcherRegistry(registry *matcher.MatcherRegistry) *DagEngineBuilder {
+	b.config.MatcherRegistry = registry
+	return b
+}
+
+// WithCustomMatcher registers a custom match type, usable as a field
+// modifier in rules (e.g. `Field|name: value`). Without a registry set by
+// WithMatcherRegistry, one with all built-in matchers is created first.
+func (b *DagEngineBuilder) WithCustomMatcher(name string, fn matcher.MatchFn) *DagEngineBuilder {
+	b.matcherRegistry().RegisterMatcher(name, fn)
+	return b
+}
+
+// WithCustomModifier registers a custom value modifier, usable as a field
+// modifier in rules
+func (b *DagEngineBuilder) WithCustomModifier(name string, fn matcher.ModifierFn) *DagEngineBuilder {
+	b.matcherRegistry().RegisterModifier(name, fn)
+	return b
+}
+
+// WithExtensions loads matcher extensions (Go plugins, or any file type with
+// a registered matcher.ExtensionLoader) into the matcher registry when the
+// engine is built
+func (b *DagEngineBuilder) WithExtensions(paths ...string) *DagEngineBuilder {
+	b.extensionPaths = append(b.extensionPaths, paths...)
+	return b
+}
+
+// loadExtensions registers the configured extensions, once per builder
+func (b *DagEngineBuilder) loadExtensions() error {
+	if len(b.extensionPaths) == 0 {
+		return nil
+	}
+	if err := b.matcherRegistry().LoadExtensions(b.extensionPaths...); err != nil {
+		return err
+	}
+	b.extensionPaths = nil
+	return nil
+}
+
+// matcherRegistry returns the configured registry, creating one with the
+// built-in matchers when none is set
+func (b *DagEngineBuilder) matcherRegistry() *matcher.MatcherRegistry {
+	if b.config.MatcherRegistry == nil {
+		b.config.MatcherRegistry = matcher.NewComprehensiveMatcherBuilder().GetRegistry()
+	}
+	return b.config.MatcherRegistry
+}
+
+// WithFieldCapture enables capturing each matched rule's `fields:` values
+func (b *DagEngineBuilder) WithFieldCapture(enable bool) *DagEngineBuilder {
+	b.config.CaptureRuleFields = enable
+	return b
+}
+
+// WithEventFlattening sets how nested event fields are resolved
+func (b *DagEngineBuilder) WithEventFlattening(mode EventFlattening) *DagEngineBuilder {
+	b.config.EventFlattening = mode
+	return b
+}
+
+// WithMaxRuleComplexity limits the estimated per-event cost of each rule,
+// applying the policy to rules over the limit
+func (b *DagEngineBuilder) WithMaxRuleComplexity(limit int, policy ComplexityPolicy) *DagEngineBuilder {
+	b.config.MaxRuleComplexity = limit
+	b.config.ComplexityPolicy = policy
+	return b
+}
+
+// WithBackend sets how rule conditions are evaluated
+func (b *DagEngineBuilder) WithBackend(backend Backend) *DagEngineBuilder {
+	b.config.Backend = backend
+	return b
+}
+
+// WithEventTimeout limits the matching time of each event
+func (b *DagEngineBuilder) WithEventTimeout(timeout time.Duration) *DagEngineBuilder {
+	b.config.EventTimeout = timeout
+	return b
+}
+
+// WithCaseInsensitiveFields resolves event fields regardless of case
+func (b *DagEngineBuilder) WithCaseInsensitiveFields(enabled bool) *DagEngineBuilder {
+	b.config.CaseInsensitiveFields = enabled
+	return b
+}
+
+// WithPrimitiveErrorPolicy sets what happens when a primitive fails at
+// runtime
+func (b *DagEngineBuilder) WithPrimitiveErrorPolicy(policy ErrorPolicy) *DagEngineBuilder {
+	b.config.PrimitiveErrorPolicy = policy
+	return b
+}
+
+// WithClock sets the engine's time source
+func (b *DagEngineBuilder) WithClock(c clock.Clock) *DagEngineBuilder {
+	b.config.Clock = c
+	return b
+}
+
+// WithLogger sets the logger used by the engine and its optimizer
+func (b *DagEngineBuilder) WithLogger(logger *slog.Logger) *DagEngineBuilder {
+	b.config.Logger = logger
+	return b
+}
+
 // Build creates the engine from SIGMA rule YAML strings
 func (b *DagEngineBuilder) Build(ruleYamls []string) (*DagEngine, error) {
+	if err := b.loadExtensions(); err != nil {
+		return nil, err
+	}
 	if b.compiler != nil {
 		return NewDagEngineFromRulesWithCompiler(ruleYamls, b.compiler, b.config)
 	}
 	return NewDagEngineFromRulesWithConfig(ruleYamls, b.config)
 }
 
+// BuildFromRuleset creates the engine from an already compiled ruleset
+func (b *DagEngineBuilder) BuildFromRuleset(ruleset *CompiledRuleset) (*DagEngine, error) {
+	if err := b.loadExtensions(); err != nil {
+		return nil, err
+	}
+	return NewDagEngineFromRulesetWithConfig(ruleset, b.config)
+}
+
 // NewDagEngineFromRuleset creates a DAG engine from a compiled ruleset
 func NewDagEngineFromRuleset(ruleset *CompiledRuleset) (*DagEngine, error) {
 	return NewDagEngineFromRulesetWithConfig(ruleset, DefaultDagEngineConfig())
@@ -267,46 +742,180 @@ func NewDagEngineFromRuleset(ruleset *CompiledRuleset) (*DagEngine, error) {
 
 // NewDagEngineFromRulesetWithConfig creates a DAG engine from a compiled ruleset with config
 func NewDagEngineFromRulesetWithConfig(ruleset *CompiledRuleset, config DagEngineConfig) (*DagEngine, error) {
-	// Build DAG from ruleset (simplified implementation)
-	// In a real implementation, this would properly construct the DAG from the ruleset
-	dag := &CompiledDag{
-		Nodes:            make([]DagNode, 0),
-		ExecutionOrder:   make([]NodeId, 0),
-		PrimitiveMap:     make(map[ir.PrimitiveID]NodeId),
-		RuleResults:      make(map[ir.RuleID]NodeId),
-		ResultBufferSize: 0,
+	logger := logging.For(config.Logger, logging.ComponentEngine)
+
+	// Use the compiled rule DAG when the compiler produced one
+	dag := ruleset.Dag
+	if dag == nil {
+		dag = NewCompiledDag()
 	}
 
 	// Apply optimization if enabled
+	var passStatistics []PassStatistics
 	if config.EnableOptimization {
-		optimizer := NewDagOptimizer()
+		optimizer := NewDagOptimizer().
+			WithLogger(config.Logger).
+			WithClock(config.Clock).
+			WithFactoring(config.OptimizationLevel >= 3).
+			WithImpossiblePrimitives(impossiblePrimitives(ruleset.Primitives)...)
+		for _, pass := range config.OptimizerPasses {
+			optimizer.WithPass(pass)
+		}
 		optimizedDag, err := optimizer.Optimize(dag)
-		if err == nil && optimizedDag != nil {
+		if err != nil {
+			logger.Warn("DAG optimization failed, using unoptimized DAG", slog.Any("error", err))
+		} else if optimizedDag != nil {
 			dag = optimizedDag
+			passStatistics = optimizer.PassStatistics()
+		}
+	}
+
+	// Pack nodes into flat storage before the evaluators start reading them
+	if !dag.IsCompact() {
+		dag.Compact()
+	}
+
+	cache := config.PrimitiveCache
+	if cache == nil {
+		cache = NewPrimitiveCacheWithRegistry(config.MatcherRegistry)
+	}
+	if !config.LenientModifiers {
+		if err := validateModifiers(ruleset.Primitives, cache.builder.GetRegistry()); err != nil {
+			return nil, err
 		}
 	}
 
 	// Build primitive map
-	primitives, err := buildPrimitiveMap(ruleset)
+	primitives, err := buildPrimitiveMapWithCache(ruleset, cache)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build primitive map: %w", err)
 	}
+	// Drop the references taken on a shared cache unless the engine is built
+	built := false
+	defer func() {
+		if !built && config.PrimitiveCache != nil {
+			config.PrimitiveCache.release(ruleset.Primitives)
+		}
+	}()
 
 	// Create prefilter if enabled
 	var prefilter *LiteralPrefilter
 	if config.EnablePrefilter {
-		prefilter, err = NewLiteralPrefilterFromPrimitives(ruleset.Primitives)
+		prefilter, err = NewLiteralPrefilterWithStrategy(ruleset.Primitives, config.PrefilterStrategy)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create prefilter: %w", err)
 		}
 	}
 
-	return &DagEngine{
-		dag:        dag,
-		primitives: primitives,
-		config:     config,
-		prefilter:  prefilter,
-	}, nil
+	var rawPrefilter *RawPrefilter
+	if config.EnableRawPrefilter && len(config.Enrichers) > 0 {
+		logger.Debug("raw prefilter disabled, enrichers add fields after raw events are checked",
+			slog.Int("enrichers", len(config.Enrichers)))
+	} else if config.EnableRawPrefilter {
+		filter, ruleId, ok := newRawPrefilter(dag, ruleset.Primitives)
+		if ok {
+			rawPrefilter = filter
+		} else {
+			logger.Debug("raw prefilter disabled, a rule requires no literal word", slog.Any("rule_id", ruleId))
+		}
+	}
+
+	var ruleGroups *RuleGroupIndex
+	if config.EnableRuleGrouping {
+		ruleGroups = newRuleGroupIndex(dag, ruleset.Primitives)
+	}
+
+	memoryUsage := engineMemoryUsage(dag, primitives, prefilter)
+	if rawPrefilter != nil {
+		memoryUsage.PrefilterBytes += rawPrefilter.memoryBytes()
+	}
+	if ruleGroups != nil {
+		memoryUsage.IndexBytes += ruleGroups.memoryBytes()
+	}
+	memoryUsage = memoryUsage.total()
+	if config.MemoryBudgetBytes > 0 && memoryUsage.TotalBytes > config.MemoryBudgetBytes {
+		return nil, errors.NewMemoryBudgetExceeded(uint64(memoryUsage.TotalBytes), uint64(config.MemoryBudgetBytes))
+	}
+
+	logger.Debug("DAG engine built",
+		slog.Int("nodes", len(dag.Nodes)),
+		slog.Int("rules", len(dag.RuleResults)),
+		slog.Int("primitives", len(primitives)),
+		slog.Bool("prefilter", prefilter != nil),
+		slog.Int("memory_bytes", memoryUsage.TotalBytes))
+
+	rules := make(map[ir.RuleID]RuleMeta, len(ruleset.Rules))
+	ruleUUIDs := make(map[string]ir.RuleID, len(ruleset.Rules))
+	for _, meta := range ruleset.Rules {
+		rules[meta.ID] = meta
+		// Duplicate UUIDs resolve to the first rule compiled with them
+		if _, exists := ruleUUIDs[meta.SigmaID]; meta.SigmaID != "" && !exists {
+			ruleUUIDs[meta.SigmaID] = meta.ID
+		}
+	}
+	inactiveRules := inactiveRulesForConfig(rules, config)
+	if len(inactiveRules) > 0 {
+		logger.Debug("rules deactivated by rule filters",
+			slog.Int("inactive", len(inactiveRules)),
+			slog.String("min_level", config.MinRuleLevel.String()))
+	}
+	tooComplex, err := applyComplexityPolicy(config, dag, ruleset.Primitives, rules, logger)
+	if err != nil {
+		return nil, err
+	}
+	inactiveRules = mergeRuleIDs(inactiveRules, tooComplex)
+
+	sampler, err := newEventSampler(config, clock.Or(config.Clock))
+	if err != nil {
+		return nil, errors.NewConfigError(err.Error())
+	}
+	if err := validateDeadLetterConfig(config); err != nil {
+		return nil, errors.NewConfigError(err.Error())
+	}
+
+	fieldDepth := ComputeFieldDepthStats(ruleset.Primitives)
+	flattenEvents := resolveFlattening(config.EventFlattening, fieldDepth)
+	if config.EventFlattening != FlattenOff {
+		logger.Debug("event flattening resolved",
+			slog.String("mode", config.EventFlattening.String()),
+			slog.Bool("flatten", flattenEvents),
+			slog.Int("nested_fields", fieldDepth.NestedFields),
+			slog.Int("max_depth", fieldDepth.MaxDepth))
+	}
+
+	engine := &DagEngine{
+		dag:            dag,
+		primitives:     primitives,
+		config:         config,
+		prefilter:      prefilter,
+		rawPrefilter:   rawPrefilter,
+		ruleGroups:     ruleGroups,
+		sampler:        sampler,
+		rules:          rules,
+		ruleUUIDs:      ruleUUIDs,
+		inactiveRules:  inactiveRules,
+		memoryUsage:    memoryUsage,
+		passStatistics: passStatistics,
+		fieldDepth:     fieldDepth,
+		flattenEvents:  flattenEvents,
+		logger:         logger,
+		clock:          clock.Or(config.Clock),
+
+		primitiveErrors: newPrimitiveErrors(config.PrimitiveErrorPolicy, logger),
+	}
+
+	backend, err := newEvaluatorBackend(config, dag, primitives, engine.evaluatorOptions())
+	if err != nil {
+		return nil, err
+	}
+	if backend != nil {
+		engine.backend = backend
+		logger.Debug("evaluator backend selected", slog.String("backend", backend.Backend().String()))
+	}
+	engine.actions = newActionDispatcher(config, ruleUUIDs, logger)
+
+	built = true
+	return engine, nil
 }
 
 // NewDagEngineFromRulesWithConfig creates a DAG engine from rule YAML strings with config
@@ -319,7 +928,7 @@ func NewDagEngineFromRulesWithConfig(ruleYamls []string, config DagEngineConfig)
 // NewDagEngineFromRulesWithCompiler creates a DAG engine from rules with a custom compiler
 func NewDagEngineFromRulesWithCompiler(ruleYamls []string, compiler Compiler, config DagEngineConfig) (*DagEngine, error) {
 	// Compile rules using the provided compiler
-	ruleset, err := compiler.CompileRules(ruleYamls)
+	ruleset, err := compileRules(compiler, ruleYamls, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compile rules: %w", err)
 	}
@@ -327,25 +936,136 @@ func NewDagEngineFromRulesWithCompiler(ruleYamls []string, compiler Compiler, co
 	return NewDagEngineFromRulesetWithConfig(ruleset, config)
 }
 
+// compileRules compiles rules, skipping filtered rules when the compiler
+// supports it and handing it the configured matcher registry
+func compileRules(compiler Compiler, ruleYamls []string, config DagEngineConfig) (*CompiledRuleset, error) {
+	if registryCompiler, ok := compiler.(MatcherRegistryCompiler); ok && config.MatcherRegistry != nil {
+		registryCompiler.SetMatcherRegistry(config.MatcherRegistry)
+	}
+	if filtering, ok := compiler.(FilteringCompiler); ok && config.RuleFilter != nil {
+		return filtering.CompileRulesWithFilter(ruleYamls, config.RuleFilter)
+	}
+	return compiler.CompileRules(ruleYamls)
+}
+
 // buildPrimitiveMap builds the primitive matcher map from compiled ruleset
 func buildPrimitiveMap(ruleset *CompiledRuleset) (map[uint32]*CompiledPrimitive, error) {
-	primitives := make(map[uint32]*CompiledPrimitive)
+	return buildPrimitiveMapWithCache(ruleset, nil)
+}
 
+// buildPrimitiveMapWithCache builds the primitive matcher map, reusing
+// matchers from the cache (nil = compile every primitive privately)
+func buildPrimitiveMapWithCache(ruleset *CompiledRuleset, cache *PrimitiveCache) (map[uint32]*CompiledPrimitive, error) {
+	if cache == nil {
+		cache = NewPrimitiveCache()
+	}
+
+	primitives := make(map[uint32]*CompiledPrimitive, len(ruleset.Primitives))
 	for _, primitive := range ruleset.Primitives {
-		// Create a basic matcher function (simplified)
-		matcherFunc := createMatcherFunc(primitive.Field, primitive.MatchType, primitive.Values)
+		primitives[primitive.ID] = cache.acquire(primitive)
+	}
 
-		primitives[primitive.ID] = &CompiledPrimitive{
-			ID:          primitive.ID,
-			Field:       primitive.Field,
-			MatchType:   primitive.MatchType,
-			Values:      primitive.Values,
-			Modifiers:   primitive.Modifiers,
-			MatcherFunc: matcherFunc,
+	return primitives, nil
+}
+
+// valueMatchTypes are the built-in match types that match an event value
+// against one of the primitive's values
+var valueMatchTypes = map[string]bool{
+	"equals": true, "contains": true, "startswith": true, "endswith": true,
+	"wildcard": true, "regex": true, "cidr": true,
+}
+
+// impossiblePrimitives returns the primitives that can never match: value
+// matchers without values. Custom matchers may match without values.
+func impossiblePrimitives(primitives []Primitive) []ir.PrimitiveID {
+	var impossible []ir.PrimitiveID
+	for _, primitive := range primitives {
+		if len(primitive.Values) == 0 && valueMatchTypes[primitive.MatchType] {
+			impossible = append(impossible, ir.PrimitiveID(primitive.ID))
 		}
 	}
+	return impossible
+}
 
-	return primitives, nil
+// newPrimitiveMatcherBuilder creates a matcher builder over the registry
+// (nil = every built-in matcher and modifier). Unknown modifiers are checked
+// by the engine before primitives reach the builder, so the builder itself
+// skips them.
+func newPrimitiveMatcherBuilder(registry *matcher.MatcherRegistry) *matcher.MatcherBuilder {
+	builder := matcher.NewComprehensiveMatcherBuilder()
+	if registry != nil {
+		builder = matcher.NewMatcherBuilderWithRegistry(registry)
+	}
+	return builder.WithUnknownModifierMode(matcher.UnknownModifierSkip)
+}
+
+// validateModifiers rejects primitives whose modifiers are not registered
+// or have invalid arguments
+func validateModifiers(primitives []Primitive, registry *matcher.MatcherRegistry) error {
+	for _, primitive := range primitives {
+		for _, modifier := range primitive.Modifiers {
+			_, exists, err := registry.ResolveModifier(modifier)
+			if err != nil {
+				return matcher.NewInvalidModifierError(modifier, primitive.Field, err)
+			}
+			if !exists && !matcher.IsModifierParameter(modifier) {
+				return matcher.NewUnknownModifierError(modifier, primitive.Field)
+			}
+		}
+	}
+	return nil
+}
+
+// newEvaluator creates a single-event evaluator bound to the engine primitives
+func (e *DagEngine) newEvaluator() *DagEvaluator {
+	return NewDagEvaluatorWithCompiledPrimitives(e.dag, e.primitives).withOptions(e.evaluatorOptions())
+}
+
+// evaluatorOptions returns the evaluation settings derived from the engine config
+func (e *DagEngine) evaluatorOptions() evaluatorOptions {
+	return evaluatorOptions{
+		collectDetails: e.config.CollectMatchDetails,
+		captureFields:  e.config.CaptureRuleFields,
+		inactiveRules:  e.inactiveRules,
+		eventTimeout:   e.config.EventTimeout,
+		clock:          e.clock,
+		foldFieldCase:  e.config.CaseInsensitiveFields,
+
+		primitiveErrors: e.primitiveErrors,
+	}
+}
+
+// inactiveRulesForConfig returns the rules excluded by the configured level
+// and rule filters
+func inactiveRulesForConfig(rules map[ir.RuleID]RuleMeta, config DagEngineConfig) []ir.RuleID {
+	if config.MinRuleLevel == LevelUnknown && len(config.RuleLevels) == 0 && config.RuleFilter == nil {
+		return nil
+	}
+
+	var inactive []ir.RuleID
+	for ruleId, meta := range rules {
+		if !config.ruleLevelActive(meta.Level) || (config.RuleFilter != nil && !config.RuleFilter(meta)) {
+			inactive = append(inactive, ruleId)
+		}
+	}
+	sort.Slice(inactive, func(i, j int) bool { return inactive[i] < inactive[j] })
+	return inactive
+}
+
+// ruleLevelActive reports whether rules of the given level pass the level filters
+func (c DagEngineConfig) ruleLevelActive(level RuleLevel) bool {
+	if c.MinRuleLevel != LevelUnknown && level < c.MinRuleLevel {
+		return false
+	}
+	if len(c.RuleLevels) == 0 {
+		return true
+	}
+	for _, allowed := range c.RuleLevels {
+		if level == allowed {
+			return true
+		}
+	}
+	return false
 }
 
 // createMatcherFunc creates a basic matcher function for a primitive
@@ -363,7 +1083,7 @@ func createMatcherFunc(field, matchType string, values []string) func(interface{
 			return false
 		}
 
-		fieldStr := fmt.Sprintf("%v", fieldValue)
+		fieldStr := matcher.ValueString(fieldValue)
 
 		// Simple equality check for demonstration
 		for _, value := range values {
@@ -378,41 +1098,128 @@ func createMatcherFunc(field, matchType string, values []string) func(interface{
 
 // Evaluate evaluates the DAG against an event and returns matches
 func (e *DagEngine) Evaluate(event interface{}) (*DagEvaluationResult, error) {
+	result := &DagEvaluationResult{}
+	if err := e.EvaluateInto(event, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// EvaluateInto evaluates an event into a caller-provided result, like
+// Evaluate. The result's previous contents are discarded and its slices are
+// reset and reused, so a caller evaluating events one after another with
+// the same result avoids allocating a result and its matched rule slices
+// per event. Results returned by alternate backends are copied into it.
+func (e *DagEngine) EvaluateInto(event interface{}, result *DagEvaluationResult) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	startTime := time.Now()
+	startTime := e.clock.Now()
 
-	// Get or create evaluator
-	if e.evaluator == nil {
-		e.evaluator = NewDagEvaluatorWithPrimitivesAndPrefilter(e.dag)
-	} else {
-		e.evaluator.reset()
+	if !matcher.IsSupportedEvent(event) {
+		e.deadLetter(event, DeadLetterEvaluation, matcher.ErrUnsupportedEvent, 0)
+		return matcher.ErrUnsupportedEvent
 	}
-
-	// Convert event to map[string]interface{}
-	eventMap, ok := event.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("event must be a map[string]interface{}")
+	if !e.admitEvent(event) {
+		*result = DagEvaluationResult{MatchedRules: result.MatchedRules[:0]}
+		return nil
 	}
 
 	// Perform evaluation
-	result, err := e.evaluator.Evaluate(eventMap)
+	var err error
+	if e.backend != nil {
+		var backendResult *DagEvaluationResult
+		var prepared interface{}
+		if prepared, err = e.prepareEvent(event); err == nil {
+			backendResult, err = e.backend.Evaluate(prepared)
+		}
+		if err == nil {
+			*result = *backendResult
+		}
+	} else {
+		// Get or create evaluator
+		if e.evaluator == nil {
+			e.evaluator = e.newEvaluator()
+		} else {
+			e.evaluator.reset()
+		}
+		var prepared interface{}
+		if prepared, err = e.prepareEvent(event); err == nil {
+			err = e.evaluateGrouped(prepared, result)
+		}
+	}
 	if err != nil {
-		return nil, err
+		e.log().Debug("event evaluation failed", slog.Any("error", err))
+		e.deadLetter(event, DeadLetterEvaluation, err, 0)
+		return err
 	}
 
-	// Add timing information
-	_ = time.Since(startTime)
+	if e.log().Enabled(context.Background(), slog.LevelDebug) {
+		e.log().Debug("event evaluated",
+			slog.Int("matched_rules", len(result.MatchedRules)),
+			slog.Int("nodes_evaluated", result.NodesEvaluated),
+			slog.Duration("elapsed", clock.Since(e.clock, startTime)))
+	}
 
-	return result, nil
+	if e.prefilter != nil {
+		e.prefilter.observe(event, len(result.MatchedRules) > 0)
+	}
+	e.attachRuleUUIDs(result)
+	e.dispatchActions([]interface{}{event}, []*DagEvaluationResult{result})
+	e.deadLetterUnreliable([]interface{}{event}, []*DagEvaluationResult{result})
+	return nil
 }
 
-// EvaluateRaw evaluates the DAG against a raw JSON string
+// EvaluateAnyMatch reports whether any active rule matches an event. It
+// stops at the first matching rule without collecting results, for callers
+// that only need a yes/no decision per event.
+func (e *DagEngine) EvaluateAnyMatch(event interface{}) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !matcher.IsSupportedEvent(event) {
+		return false, matcher.ErrUnsupportedEvent
+	}
+	if !e.admitEvent(event) {
+		return false, nil
+	}
+
+	prepared, err := e.prepareEvent(event)
+	if err != nil {
+		return false, err
+	}
+	var matched bool
+	if e.backend != nil {
+		matched, err = e.backend.EvaluateAnyMatch(prepared)
+	} else {
+		if e.evaluator == nil {
+			e.evaluator = e.newEvaluator()
+		}
+		matched, err = e.evaluator.EvaluateAnyMatch(prepared)
+	}
+	if err != nil {
+		e.log().Debug("event evaluation failed", slog.Any("error", err))
+		return false, err
+	}
+	if e.prefilter != nil {
+		e.prefilter.observe(event, matched)
+	}
+	return matched, nil
+}
+
+// EvaluateRaw evaluates the DAG against a raw JSON string. With a raw
+// prefilter, events it rejects are not parsed, so they yield no matches
+// even when they are not valid JSON.
 func (e *DagEngine) EvaluateRaw(jsonStr string) (*DagEvaluationResult, error) {
+	if e.rawPrefilter != nil && !e.rawPrefilter.MatchesString(jsonStr) {
+		return NewDagEvaluationResult(), nil
+	}
+
 	var event map[string]interface{}
 	if err := json.Unmarshal([]byte(jsonStr), &event); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		err = fmt.Errorf("failed to parse JSON: %w", err)
+		e.deadLetter(jsonStr, DeadLetterParse, err, 0)
+		return nil, err
 	}
 
 	return e.Evaluate(event)
@@ -420,22 +1227,40 @@ func (e *DagEngine) EvaluateRaw(jsonStr string) (*DagEvaluationResult, error) {
 
 // EvaluateParallel evaluates the DAG using parallel processing
 func (e *DagEngine) EvaluateParallel(event interface{}) (*DagEvaluationResult, error) {
-	if !e.config.EnableParallelProcessing {
+	if !e.config.EnableParallelProcessing || e.backend != nil {
 		return e.Evaluate(event)
 	}
 
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	if !e.admitEvent(event) {
+		return NewDagEvaluationResult(), nil
+	}
+
 	// Get or create parallel evaluator
 	if e.parallelEvaluator == nil {
 		e.parallelEvaluator = NewParallelDagEvaluator(e.dag, e.primitives, e.config.ParallelConfig)
+		e.parallelEvaluator.options = e.evaluatorOptions()
+		e.parallelEvaluator.clock = e.clock
 	} else {
 		e.parallelEvaluator.Reset()
 	}
 
 	// Perform parallel evaluation
-	return e.parallelEvaluator.Evaluate(event)
+	prepared, err := e.prepareEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	result, err := e.parallelEvaluator.Evaluate(prepared)
+	if err != nil {
+		return nil, err
+	}
+	e.observePrefilter([]interface{}{event}, []*DagEvaluationResult{result})
+	e.attachRuleUUIDs(result)
+	e.dispatchActions([]interface{}{event}, []*DagEvaluationResult{result})
+	e.deadLetterUnreliable([]interface{}{event}, []*DagEvaluationResult{result})
+	return result, nil
 }
 
 // EvaluateBatch evaluates multiple events using batch processing
@@ -447,20 +1272,29 @@ func (e *DagEngine) EvaluateBatch(events []interface{}) ([]*DagEvaluationResult,
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	// Get or create batch evaluator
-	if e.batchEvaluator == nil {
-		e.batchEvaluator = NewBatchDagEvaluator(e.dag, e.primitives)
-	} else {
-		e.batchEvaluator.Reset()
+	batch, positions := e.sampleBatch(events)
+	if len(batch) == 0 {
+		return expandSampled(nil, positions, len(events)), nil
 	}
 
-	// Perform batch evaluation
-	return e.batchEvaluator.EvaluateBatch(events)
+	var results []*DagEvaluationResult
+	prepared, err := e.prepareEvents(batch)
+	if err == nil {
+		results, err = e.evaluatePrepared(prepared)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.observePrefilter(batch, results)
+	e.attachRuleUUIDs(results...)
+	e.dispatchActions(batch, results)
+	e.deadLetterUnreliable(batch, results)
+	return expandSampled(results, positions, len(events)), nil
 }
 
 // EvaluateBatchParallel evaluates multiple events using parallel batch processing
 func (e *DagEngine) EvaluateBatchParallel(events []interface{}) ([]*DagEvaluationResult, error) {
-	if !e.config.EnableParallelProcessing {
+	if !e.config.EnableParallelProcessing || e.backend != nil {
 		return e.EvaluateBatch(events)
 	}
 
@@ -471,15 +1305,98 @@ func (e *DagEngine) EvaluateBatchParallel(events []interface{}) ([]*DagEvaluatio
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	batch, positions := e.sampleBatch(events)
+	if len(batch) == 0 {
+		return expandSampled(nil, positions, len(events)), nil
+	}
+
 	// Get or create parallel evaluator
 	if e.parallelEvaluator == nil {
 		e.parallelEvaluator = NewParallelDagEvaluator(e.dag, e.primitives, e.config.ParallelConfig)
+		e.parallelEvaluator.options = e.evaluatorOptions()
+		e.parallelEvaluator.clock = e.clock
 	} else {
 		e.parallelEvaluator.Reset()
 	}
 
 	// Perform parallel batch evaluation
-	return e.parallelEvaluator.EvaluateBatch(events)
+	prepared, err := e.prepareEvents(batch)
+	if err != nil {
+		return nil, err
+	}
+	results, err := e.parallelEvaluator.EvaluateBatch(prepared)
+	if err != nil {
+		return nil, err
+	}
+	e.observePrefilter(batch, results)
+	e.attachRuleUUIDs(results...)
+	e.dispatchActions(batch, results)
+	e.deadLetterUnreliable(batch, results)
+	return expandSampled(results, positions, len(events)), nil
+}
+
+// streamChunkSize is the number of events EvaluateBatchFunc evaluates
+// together; only one chunk of results is held at a time
+const streamChunkSize = 256
+
+// EvaluateBatchFunc evaluates events like EvaluateBatch but passes each
+// result to fn, in event order with the event's index, instead of returning
+// them in one slice. Events are evaluated in chunks, so memory is bounded by
+// the chunk size rather than the number of events. Evaluation stops at the
+// first error fn returns, which EvaluateBatchFunc returns as is; later
+// events are not evaluated. fn runs without the engine lock held and may
+// keep the results.
+func (e *DagEngine) EvaluateBatchFunc(events []interface{}, fn func(i int, result *DagEvaluationResult) error) error {
+	// Reject unsupported events before any result is delivered
+	for i, event := range events {
+		if !matcher.IsSupportedEvent(event) {
+			return fmt.Errorf("event at index %d: %w", i, matcher.ErrUnsupportedEvent)
+		}
+	}
+
+	for start := 0; start < len(events); start += streamChunkSize {
+		results, err := e.EvaluateBatch(events[start:min(start+streamChunkSize, len(events))])
+		if err != nil {
+			return err
+		}
+		for i, result := range results {
+			if err := fn(start+i, result); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// evaluatePrepared evaluates a batch of prepared events with the batch
+// evaluator, or event by event on the alternate backend. Callers hold the
+// engine lock.
+func (e *DagEngine) evaluatePrepared(prepared []interface{}) ([]*DagEvaluationResult, error) {
+	if e.backend == nil {
+		// Get or create batch evaluator
+		if e.batchEvaluator == nil {
+			e.batchEvaluator = NewBatchDagEvaluator(e.dag, e.primitives)
+			e.batchEvaluator.options = e.evaluatorOptions()
+		} else {
+			e.batchEvaluator.Reset()
+		}
+		return e.batchEvaluator.EvaluateBatch(prepared)
+	}
+
+	for i, event := range prepared {
+		if !matcher.IsSupportedEvent(event) {
+			return nil, fmt.Errorf("event at index %d: %w", i, matcher.ErrUnsupportedEvent)
+		}
+	}
+	results := make([]*DagEvaluationResult, len(prepared))
+	for i, event := range prepared {
+		result, err := e.backend.Evaluate(event)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
 }
 
 // EvaluateWithPrimitiveResults evaluates using pre-computed primitive results
@@ -489,7 +1406,7 @@ func (e *DagEngine) EvaluateWithPrimitiveResults(primitiveResults []bool) (*DagE
 
 	// Get or create evaluator
 	if e.evaluator == nil {
-		e.evaluator = NewDagEvaluatorWithPrimitivesAndPrefilter(e.dag)
+		e.evaluator = e.newEvaluator()
 	} else {
 		e.evaluator.reset()
 	}
@@ -499,9 +1416,25 @@ func (e *DagEngine) EvaluateWithPrimitiveResults(primitiveResults []bool) (*DagE
 	return nil, fmt.Errorf("EvaluateWithPrimitiveResults not implemented yet")
 }
 
-// GetStatistics returns DAG statistics
+// GetStatistics returns DAG statistics, including the number of
+// primitives per event field and the rule grouping
 func (e *DagEngine) GetStatistics() *DagStatistics {
-	return e.dag.Statistics()
+	stats := e.dag.Statistics()
+	stats.PrimitivesPerField = make(map[string]int)
+	for _, primitive := range e.primitives {
+		stats.PrimitivesPerField[primitive.Field]++
+	}
+	if e.ruleGroups != nil {
+		stats.RuleGroups = e.ruleGroups.Stats()
+	}
+	return stats
+}
+
+// OptimizerStatistics returns the statistics of the optimization passes
+// run when the engine was built (empty when optimization was disabled or
+// failed)
+func (e *DagEngine) OptimizerStatistics() []PassStatistics {
+	return append([]PassStatistics(nil), e.passStatistics...)
 }
 
 // RuleCount returns the number of rules in the DAG
@@ -509,6 +1442,62 @@ func (e *DagEngine) RuleCount() int {
 	return len(e.dag.RuleResults)
 }
 
+// RuleIDs returns the IDs of the compiled rules in order
+func (e *DagEngine) RuleIDs() []ir.RuleID {
+	return sortedRuleIDs(e.dag.RuleResults)
+}
+
+// ActiveRuleCount returns the number of rules not excluded by the level and rule filters
+func (e *DagEngine) ActiveRuleCount() int {
+	return len(e.dag.RuleResults) - len(e.inactiveRules)
+}
+
+// RuleMeta returns the source metadata of a compiled rule
+func (e *DagEngine) RuleMeta(ruleID uint32) (RuleMeta, bool) {
+	meta, exists := e.rules[ir.RuleID(ruleID)]
+	return meta, exists
+}
+
+// Stats returns the runtime decisions made by the engine, such as the
+// parallel batch setting chosen by auto-tuning
+func (e *DagEngine) Stats() EngineStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	stats := EngineStats{
+		ParallelWorkers: e.config.ParallelConfig.workerCount(),
+		NumThreads:      e.config.ParallelConfig.workerCount(),
+		CPUQuota:        cgroupQuota(),
+		ChunksPerWorker: chunksPerWorker,
+		AutoTuning:      e.config.ParallelConfig.AutoTune,
+		EventFlattening: e.flattenEvents,
+		FieldDepth:      e.fieldDepth,
+		Backend:         e.config.Backend,
+	}
+	if e.sampler != nil {
+		stats.Sampling = e.sampler.snapshot()
+	}
+	stats.PrimitiveErrors = e.primitiveErrors.snapshot()
+	stats.DeadLetters = atomic.LoadUint64(&e.deadLetters)
+	if e.parallelEvaluator == nil || e.parallelEvaluator.tuner == nil {
+		return stats
+	}
+
+	tuner := e.parallelEvaluator.tuner
+	setting := tuner.next()
+	stats.ParallelWorkers = setting.workers
+	stats.ChunksPerWorker = setting.chunksPerWorker
+	stats.AutoTuned = tuner.Converged()
+	stats.TuningBatches = tuner.tuningBatches()
+	stats.BestEventsPerSecond = tuner.bestRate
+	return stats
+}
+
+// MemoryUsage returns the estimated memory held by the compiled engine
+func (e *DagEngine) MemoryUsage() MemoryUsage {
+	return e.memoryUsage
+}
+
 // NodeCount returns the number of nodes in the DAG
 func (e *DagEngine) NodeCount() int {
 	return len(e.dag.Nodes)
@@ -525,6 +1514,40 @@ func (e *DagEngine) PrimitiveCount() int {
 	return count
 }
 
+// ReferencedFields returns the sorted event field paths, after field
+// mapping, that evaluation can read: the fields and field aliases of every
+// primitive in the DAG, plus the rules' `fields:` entries when rule field capture is
+// enabled. Ingestion can project events down to these paths (keeping the
+// parents of nested paths) before evaluation without changing any result.
+func (e *DagEngine) ReferencedFields() []string {
+	seen := make(map[string]bool)
+	for _, node := range e.dag.Nodes {
+		if node.NodeType.Type != "Primitive" || node.NodeType.PrimitiveId == nil {
+			continue
+		}
+		if primitive := e.primitives[uint32(*node.NodeType.PrimitiveId)]; primitive != nil {
+			seen[primitive.Field] = true
+			for _, alias := range primitive.FieldAliases {
+				seen[alias] = true
+			}
+		}
+	}
+	if e.config.CaptureRuleFields {
+		for _, ruleFields := range e.dag.RuleFields {
+			for _, field := range ruleFields {
+				seen[field.EventField] = true
+			}
+		}
+	}
+
+	fields := make([]string, 0, len(seen))
+	for field := range seen {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
 // ContainsRule checks if the DAG contains a specific rule
 func (e *DagEngine) ContainsRule(ruleID uint32) bool {
 	ruleIDConverted := ir.RuleID(ruleID)
@@ -532,11 +1555,72 @@ func (e *DagEngine) ContainsRule(ruleID uint32) bool {
 	return exists
 }
 
+// ContainsRuleUUID checks if the DAG contains the rule with the given
+// SIGMA rule UUID (the rule's `id:`)
+func (e *DagEngine) ContainsRuleUUID(uuid string) bool {
+	ruleID, exists := e.ruleUUIDs[uuid]
+	return exists && e.ContainsRule(uint32(ruleID))
+}
+
+// RuleMetaByUUID returns the source metadata of the rule with the given
+// SIGMA rule UUID
+func (e *DagEngine) RuleMetaByUUID(uuid string) (RuleMeta, bool) {
+	ruleID, exists := e.ruleUUIDs[uuid]
+	if !exists {
+		return RuleMeta{}, false
+	}
+	return e.RuleMeta(uint32(ruleID))
+}
+
+// attachRuleUUIDs fills in the SIGMA UUIDs of the matched rules
+func (e *DagEngine) attachRuleUUIDs(results ...*DagEvaluationResult) {
+	if len(e.ruleUUIDs) == 0 {
+		return
+	}
+	for _, result := range results {
+		if result == nil || len(result.MatchedRules) == 0 {
+			continue
+		}
+		// Reuse the UUID slice of a reused result
+		if cap(result.MatchedRuleUUIDs) >= len(result.MatchedRules) {
+			result.MatchedRuleUUIDs = result.MatchedRuleUUIDs[:len(result.MatchedRules)]
+		} else {
+			result.MatchedRuleUUIDs = make([]string, len(result.MatchedRules))
+		}
+		for i, ruleID := range result.MatchedRules {
+			result.MatchedRuleUUIDs[i] = e.rules[ruleID].SigmaID
+		}
+		for i := range result.RuleMatches {
+			result.RuleMatches[i].RuleUUID = e.rules[result.RuleMatches[i].RuleID].SigmaID
+		}
+	}
+}
+
+// Backend returns the backend evaluating rule conditions
+func (e *DagEngine) Backend() Backend {
+	return e.config.Backend
+}
+
 // Config returns the engine configuration
 func (e *DagEngine) Config() DagEngineConfig {
 	return e.config
 }
 
+// log returns the engine logger resolved when the engine was built
+func (e *DagEngine) log() *slog.Logger {
+	return e.logger
+}
+
+// observePrefilter records the prefilter outcome of evaluated events
+func (e *DagEngine) observePrefilter(events []interface{}, results []*DagEvaluationResult) {
+	if e.prefilter == nil {
+		return
+	}
+	for i, event := range events {
+		e.prefilter.observe(event, len(results[i].MatchedRules) > 0)
+	}
+}
+
 // PrefilterStats returns prefilter statistics if prefilter is enabled
 func (e *DagEngine) PrefilterStats() *PrefilterStats {
 	if e.prefilter != nil {
@@ -545,10 +1629,34 @@ func (e *DagEngine) PrefilterStats() *PrefilterStats {
 	return nil
 }
 
+// RawPrefilterStats returns raw prefilter statistics, or nil when the
+// engine has no raw prefilter
+func (e *DagEngine) RawPrefilterStats() *RawPrefilterStats {
+	if e.rawPrefilter != nil {
+		return e.rawPrefilter.Stats()
+	}
+	return nil
+}
+
 // Placeholder implementations for additional components
 
-// NewLiteralPrefilterFromPrimitives creates a prefilter from primitives
+// NewLiteralPrefilterFromPrimitives creates a prefilter from primitives,
+// choosing its strategy by the number of patterns
 func NewLiteralPrefilterFromPrimitives(primitives []Primitive) (*LiteralPrefilter, error) {
+	return NewLiteralPrefilterWithStrategy(primitives, PrefilterAuto)
+}
+
+// NewLiteralPrefilterWithStrategy creates a prefilter from primitives with
+// the given lookup strategy. PrefilterNone creates no prefilter and
+// returns nil.
+func NewLiteralPrefilterWithStrategy(primitives []Primitive, strategy PrefilterStrategy) (*LiteralPrefilter, error) {
+	if _, known := prefilterStrategyNames[strategy]; !known {
+		return nil, errors.Errorf(errors.ErrorTypeConfig, "unknown prefilter strategy %d", strategy)
+	}
+	if strategy == PrefilterNone {
+		return nil, nil
+	}
+
 	patterns := make(map[string]bool)
 	fieldCount := 0
 
@@ -562,15 +1670,20 @@ func NewLiteralPrefilterFromPrimitives(primitives []Primitive) (*LiteralPrefilte
 		}
 	}
 
+	index := newPrefilterIndex(resolvePrefilterStrategy(strategy, len(patterns)), patterns)
 	stats := &PrefilterStats{
 		PatternCount:         len(patterns),
 		FieldCount:           fieldCount,
 		EstimatedSelectivity: calculateSelectivity(len(patterns)),
-		StrategyName:         getStrategyName(len(patterns)),
+		StrategyName:         index.strategy().String(),
+	}
+	if index.strategy() == PrefilterBloom {
+		patterns = nil
 	}
 
 	return &LiteralPrefilter{
 		patterns:   patterns,
+		index:      index,
 		fieldCount: fieldCount,
 		stats:      stats,
 	}, nil
@@ -595,14 +1708,6 @@ func calculateSelectivity(patternCount int) float64 {
 	return 1.0 / float64(patternCount)
 }
 
-// getStrategyName returns the strategy name based on pattern count
-func getStrategyName(patternCount int) string {
-	if patternCount > 100 {
-		return "AhoCorasick"
-	}
-	return "Simple"
-}
-
 // Matches checks if an event matches any prefilter patterns
 func (p *LiteralPrefilter) Matches(event interface{}) (bool, error) {
 	eventMap, ok := event.(map[string]interface{})
@@ -612,8 +1717,7 @@ func (p *LiteralPrefilter) Matches(event interface{}) (bool, error) {
 
 	// Check if any field value matches our patterns
 	for _, value := range eventMap {
-		valueStr := fmt.Sprintf("%v", value)
-		if p.patterns[valueStr] {
+		if p.index.matches(matcher.ValueString(value)) {
 			return true, nil
 		}
 	}
@@ -621,9 +1725,30 @@ func (p *LiteralPrefilter) Matches(event interface{}) (bool, error) {
 	return false, nil
 }
 
-// Stats returns prefilter statistics
+// Stats returns prefilter statistics, including the outcomes observed on
+// the events evaluated so far
 func (p *LiteralPrefilter) Stats() *PrefilterStats {
-	return p.stats
+	stats := *p.stats
+	stats.EventsObserved = p.observed.Load()
+	stats.Hits = p.hits.Load()
+	stats.Misses = stats.EventsObserved - stats.Hits
+	stats.MissedMatches = p.missedMatches.Load()
+	if stats.EventsObserved > 0 {
+		stats.ObservedSelectivity = float64(stats.Hits) / float64(stats.EventsObserved)
+	}
+	return &stats
+}
+
+// observe records whether the prefilter passes an evaluated event and
+// whether the event matched a rule. Safe for concurrent use.
+func (p *LiteralPrefilter) observe(event interface{}, matched bool) {
+	hit, _ := p.Matches(event)
+	p.observed.Add(1)
+	if hit {
+		p.hits.Add(1)
+	} else if matched {
+		p.missedMatches.Add(1)
+	}
 }
 
 // NewBatchDagEvaluator creates a new batch evaluator
@@ -640,27 +1765,6 @@ func NewBatchMemoryPool() *BatchMemoryPool {
 	return &BatchMemoryPool{}
 }
 
-// EvaluateBatch evaluates multiple events using batch processing
-func (b *BatchDagEvaluator) EvaluateBatch(events []interface{}) ([]*DagEvaluationResult, error) {
-	results := make([]*DagEvaluationResult, len(events))
-
-	// Simplified batch evaluation - in practice this would be optimized
-	for i, event := range events {
-		evaluator := NewDagEvaluatorWithPrimitivesAndPrefilter(b.dag)
-		eventMap, ok := event.(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("event at index %d must be a map[string]interface{}", i)
-		}
-		result, err := evaluator.Evaluate(eventMap)
-		if err != nil {
-			return nil, err
-		}
-		results[i] = result
-	}
-
-	return results, nil
-}
-
 // Reset resets the batch evaluator state
 func (b *BatchDagEvaluator) Reset() {
 	b.totalNodesEvaluated = 0
@@ -674,7 +1778,17 @@ func NewParallelDagEvaluator(dag *CompiledDag, primitives map[uint32]*CompiledPr
 		primitives:     primitives,
 		config:         config,
 		rulePartitions: partitionRules(dag, config),
+		tuner:          newParallelTunerForConfig(config),
+		clock:          clock.System(),
+	}
+}
+
+// newParallelTunerForConfig returns a tuner when auto-tuning is enabled
+func newParallelTunerForConfig(config ParallelConfig) *ParallelTuner {
+	if !config.AutoTune {
+		return nil
 	}
+	return NewParallelTuner(config.workerCount())
 }
 
 // partitionRules partitions rules for parallel processing
@@ -700,28 +1814,12 @@ func partitionRules(dag *CompiledDag, config ParallelConfig) []RulePartition {
 // Evaluate evaluates using parallel processing
 func (p *ParallelDagEvaluator) Evaluate(event interface{}) (*DagEvaluationResult, error) {
 	// Simplified parallel evaluation - fallback to sequential for now
-	evaluator := NewDagEvaluatorWithPrimitivesAndPrefilter(p.dag)
-	eventMap, ok := event.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("event must be a map[string]interface{}")
-	}
-	return evaluator.Evaluate(eventMap)
-}
-
-// EvaluateBatch evaluates multiple events using parallel batch processing
-func (p *ParallelDagEvaluator) EvaluateBatch(events []interface{}) ([]*DagEvaluationResult, error) {
-	results := make([]*DagEvaluationResult, len(events))
-
-	// Simplified parallel batch evaluation
-	for i, event := range events {
-		result, err := p.Evaluate(event)
-		if err != nil {
-			return nil, err
-		}
-		results[i] = result
+	evaluator := NewDagEvaluatorWithCompiledPrimitives(p.dag, p.primitives).withOptions(p.options)
+	defer evaluator.Release()
+	if !matcher.IsSupportedEvent(event) {
+		return nil, matcher.ErrUnsupportedEvent
 	}
-
-	return results, nil
+	return evaluator.Evaluate(event)
 }
 
 // Reset resets the parallel evaluator state
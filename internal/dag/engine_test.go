@@ -1,10 +1,20 @@
 package dag
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
 	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func TestDefaultDagEngineConfig(t *testing.T) {
@@ -91,6 +101,23 @@ func TestDagEngineBuilder(t *testing.T) {
 	}
 }
 
+func TestDagEngineBuilderWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	builder := NewDagEngineBuilder().WithLogger(logger)
+	if builder.config.Logger != logger {
+		t.Fatal("Expected builder to carry the injected logger")
+	}
+
+	if _, err := NewDagEngineFromRulesetWithConfig(createTestRuleset(), builder.config); err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if !strings.Contains(buf.String(), "component=engine") {
+		t.Errorf("Expected engine log output, got: %s", buf.String())
+	}
+}
+
 func createTestRuleset() *CompiledRuleset {
 	primitive1 := Primitive{
 		ID:        0,
@@ -157,6 +184,64 @@ func TestBuildPrimitiveMap(t *testing.T) {
 	}
 }
 
+func TestDagEngineMatchDetails(t *testing.T) {
+	ruleset := createTestRuleset()
+	ruleset.Dag = createTestDag()
+	ruleset.Dag.RuleSelections[1] = map[string][]ir.PrimitiveID{
+		"selection_logon":   {0},
+		"selection_process": {1},
+	}
+
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithPrefilter(false).
+		WithMatchDetails(true).
+		BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	result, err := engine.Evaluate(map[string]interface{}{
+		"EventID":     "4624",
+		"ProcessName": "C:\\tools\\powershell.exe",
+	})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 || result.MatchedRules[0] != 1 {
+		t.Fatalf("Expected rule 1 to match, got %v", result.MatchedRules)
+	}
+	if len(result.RuleMatches) != 1 {
+		t.Fatalf("Expected 1 rule match detail, got %d", len(result.RuleMatches))
+	}
+
+	detail := result.RuleMatches[0]
+	if len(detail.Selections) != 2 || detail.Selections[0] != "selection_logon" || detail.Selections[1] != "selection_process" {
+		t.Errorf("Unexpected contributing selections: %v", detail.Selections)
+	}
+	if len(detail.Primitives) != 2 {
+		t.Fatalf("Expected 2 matched primitives, got %d", len(detail.Primitives))
+	}
+	process := detail.Primitives[1]
+	if process.Field != "ProcessName" || process.MatchedValue != "powershell" || process.ValueIndex != 0 {
+		t.Errorf("Unexpected primitive match detail: %+v", process)
+	}
+	if process.FieldValue != "C:\\tools\\powershell.exe" {
+		t.Errorf("Expected event value to be reported, got '%s'", process.FieldValue)
+	}
+
+	// Details are opt-in
+	engine.config.CollectMatchDetails = false
+	engine.evaluator = nil
+	result, err = engine.Evaluate(map[string]interface{}{"EventID": "4624", "ProcessName": "powershell"})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 || result.RuleMatches != nil {
+		t.Errorf("Expected match without details, got %+v", result)
+	}
+}
+
 func TestCreateMatcherFunc(t *testing.T) {
 	// Test equals matcher
 	matcher := createMatcherFunc("EventID", "equals", []string{"4624", "4625"})
@@ -306,24 +391,26 @@ func TestCalculateSelectivity(t *testing.T) {
 	}
 }
 
-func TestGetStrategyName(t *testing.T) {
+func TestResolvePrefilterStrategy(t *testing.T) {
 	testCases := []struct {
 		patternCount int
-		expected     string
+		expected     PrefilterStrategy
 	}{
-		{0, "Simple"},
-		{50, "Simple"},
-		{100, "Simple"},
-		{101, "AhoCorasick"},
-		{1000, "AhoCorasick"},
+		{0, PrefilterHashSet},
+		{100, PrefilterHashSet},
+		{101, PrefilterAhoCorasick},
+		{100000, PrefilterAhoCorasick},
+		{100001, PrefilterBloom},
 	}
 
 	for _, tc := range testCases {
-		result := getStrategyName(tc.patternCount)
-		if result != tc.expected {
-			t.Errorf("Expected getStrategyName(%d) to be %s, got %s", tc.patternCount, tc.expected, result)
+		if result := resolvePrefilterStrategy(PrefilterAuto, tc.patternCount); result != tc.expected {
+			t.Errorf("Expected %d patterns to resolve to %s, got %s", tc.patternCount, tc.expected, result)
 		}
 	}
+	if result := resolvePrefilterStrategy(PrefilterBloom, 1); result != PrefilterBloom {
+		t.Errorf("Expected an explicit strategy to be kept, got %s", result)
+	}
 }
 
 func TestDagEngineFromRulesetWithoutCompiler(t *testing.T) {
@@ -347,6 +434,64 @@ detection:
 	}
 }
 
+func TestDagEngineStatisticsFields(t *testing.T) {
+	ruleset := createTestRuleset()
+	ruleset.Dag = createTestDag()
+	engine, err := NewDagEngineFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	stats := engine.GetStatistics()
+	if !reflect.DeepEqual(stats.PrimitivesPerField, map[string]int{"EventID": 1, "ProcessName": 1}) {
+		t.Errorf("Unexpected primitives per field: %v", stats.PrimitivesPerField)
+	}
+	if stats.CriticalPath != 3 || stats.NodesPerRule[1] != 4 {
+		t.Errorf("Unexpected rule statistics: %+v", stats)
+	}
+}
+
+func TestDagEnginePrefilterObservedStats(t *testing.T) {
+	// Rule 1: EventID contains 4624 and ProcessName contains powershell
+	ruleset := createTestRuleset()
+	ruleset.Primitives[0].MatchType = "contains"
+	ruleset.Dag = createTestDag()
+	engine, err := NewDagEngineFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if stats := engine.PrefilterStats(); stats.EventsObserved != 0 || stats.ObservedSelectivity != 0 {
+		t.Errorf("Expected no observations before evaluation, got %+v", stats)
+	}
+
+	matching := map[string]interface{}{"EventID": 4624, "ProcessName": "powershell"}
+	if _, err := engine.Evaluate(matching); err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	// The second event matches without any exact pattern, so the prefilter
+	// would have dropped a detection
+	if _, err := engine.EvaluateBatch([]interface{}{
+		map[string]interface{}{"EventID": "1"},
+		map[string]interface{}{"EventID": "44624", "ProcessName": "powershell.exe"},
+	}); err != nil {
+		t.Fatalf("Batch evaluation failed: %v", err)
+	}
+	if _, err := engine.EvaluateAnyMatch(matching); err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+
+	stats := engine.PrefilterStats()
+	if stats.EventsObserved != 4 || stats.Hits != 2 || stats.Misses != 2 || stats.MissedMatches != 1 {
+		t.Errorf("Unexpected observed outcomes: %+v", stats)
+	}
+	if stats.ObservedSelectivity != 0.5 {
+		t.Errorf("Expected observed selectivity 0.5, got %v", stats.ObservedSelectivity)
+	}
+	if stats.PatternCount != 2 {
+		t.Errorf("Expected static statistics to be kept, got %+v", stats)
+	}
+}
+
 func TestBatchMemoryPool(t *testing.T) {
 	pool := NewBatchMemoryPool()
 	if pool == nil {
@@ -442,3 +587,265 @@ func TestDagEngineConfigSerialization(t *testing.T) {
 		t.Error("EnablePrefilter not preserved in JSON round-trip")
 	}
 }
+
+func TestDagEngineLevelFilter(t *testing.T) {
+	event := map[string]interface{}{"EventID": "4624", "ProcessName": "powershell"}
+	newEngine := func(builder *DagEngineBuilder) *DagEngine {
+		ruleset := createTestRuleset()
+		ruleset.Dag = createTestDag()
+		ruleset.Rules = []RuleMeta{{ID: 1, Title: "Logon", Level: LevelMedium}}
+		engine, err := builder.WithOptimization(false).WithPrefilter(false).BuildFromRuleset(ruleset)
+		if err != nil {
+			t.Fatalf("Failed to create engine: %v", err)
+		}
+		return engine
+	}
+
+	tests := []struct {
+		name    string
+		builder *DagEngineBuilder
+		matches bool
+	}{
+		{"no filter", NewDagEngineBuilder(), true},
+		{"min level below", NewDagEngineBuilder().WithMinLevel(LevelLow), true},
+		{"min level above", NewDagEngineBuilder().WithMinLevel(LevelHigh), false},
+		{"allowlist hit", NewDagEngineBuilder().WithLevels(LevelMedium, LevelCritical), true},
+		{"allowlist miss", NewDagEngineBuilder().WithLevels(LevelInformational), false},
+	}
+	for _, tt := range tests {
+		engine := newEngine(tt.builder)
+		result, err := engine.Evaluate(event)
+		if err != nil {
+			t.Fatalf("%s: evaluation failed: %v", tt.name, err)
+		}
+		if (len(result.MatchedRules) == 1) != tt.matches {
+			t.Errorf("%s: expected match=%v, got %v", tt.name, tt.matches, result.MatchedRules)
+		}
+		batch, err := engine.EvaluateBatch([]interface{}{event})
+		if err != nil {
+			t.Fatalf("%s: batch evaluation failed: %v", tt.name, err)
+		}
+		if (len(batch[0].MatchedRules) == 1) != tt.matches {
+			t.Errorf("%s: batch expected match=%v, got %v", tt.name, tt.matches, batch[0].MatchedRules)
+		}
+	}
+
+	engine := newEngine(NewDagEngineBuilder().WithMinLevel(LevelHigh))
+	if engine.ActiveRuleCount() != 0 || engine.RuleCount() != 1 {
+		t.Errorf("Expected 0 of 1 rules active, got %d of %d", engine.ActiveRuleCount(), engine.RuleCount())
+	}
+}
+
+func TestDagEngineUnknownModifiers(t *testing.T) {
+	ruleset := createTestRuleset()
+	ruleset.Dag = createTestDag()
+	ruleset.Primitives[1].Modifiers = []string{"windash"}
+
+	_, err := NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(ruleset)
+	var sigmaErr *sigmaerrors.SigmaError
+	if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeModifier {
+		t.Fatalf("Expected unknown modifier error, got %v", err)
+	}
+
+	engine, err := NewDagEngineBuilder().WithOptimization(false).WithLenientModifiers(true).BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Expected lenient engine to build, got %v", err)
+	}
+	result, err := engine.Evaluate(map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 {
+		t.Errorf("Expected rule to match with the modifier skipped, got %v", result.MatchedRules)
+	}
+}
+
+func TestDagEngineCustomModifier(t *testing.T) {
+	ruleset := createTestRuleset()
+	ruleset.Dag = createTestDag()
+	ruleset.Primitives[1].MatchType = "equals"
+	ruleset.Primitives[1].Modifiers = []string{"stripexe"}
+
+	stripExe := func(input string) (string, error) {
+		return strings.TrimSuffix(input, ".exe"), nil
+	}
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithCustomModifier("stripexe", stripExe).
+		BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Expected custom modifier to be accepted, got %v", err)
+	}
+	result, err := engine.Evaluate(map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 {
+		t.Errorf("Expected custom modifier to apply before matching, got %v", result.MatchedRules)
+	}
+}
+
+func TestDagEngineStructpbEvent(t *testing.T) {
+	ruleset := createTestRuleset()
+	ruleset.Dag = createTestDag()
+
+	engine, err := NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build engine: %v", err)
+	}
+	event, err := structpb.NewStruct(map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"})
+	if err != nil {
+		t.Fatalf("Failed to build event: %v", err)
+	}
+	result, err := engine.Evaluate(event)
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 {
+		t.Errorf("Expected structpb event to match, got %v", result.MatchedRules)
+	}
+
+	if _, err := engine.Evaluate("EventID=4624"); !errors.Is(err, matcher.ErrUnsupportedEvent) {
+		t.Errorf("Expected unsupported event error, got %v", err)
+	}
+}
+
+func TestEngineEventTimeout(t *testing.T) {
+	event := map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"}
+
+	for _, backend := range []Backend{BackendDAG, BackendVM, BackendInterpreter} {
+		// Every read of the clock moves it past the event timeout
+		engine, err := NewDagEngineBuilder().
+			WithPrefilter(false).
+			WithBackend(backend).
+			WithEventTimeout(time.Millisecond).
+			WithClock(clock.NewStepping(time.Time{}, time.Second)).
+			BuildFromRuleset(createBatchTestRuleset())
+		if err != nil {
+			t.Fatalf("%s: failed to create engine: %v", backend, err)
+		}
+		if _, err := engine.Evaluate(event); !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeExecutionTimeout) {
+			t.Errorf("%s: expected an execution timeout, got %v", backend, err)
+		}
+		if _, err := engine.EvaluateBatch([]interface{}{event, event}); !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeExecutionTimeout) {
+			t.Errorf("%s: expected a batch execution timeout, got %v", backend, err)
+		}
+	}
+
+	// Within the timeout, evaluation is unaffected
+	engine, err := NewDagEngineBuilder().
+		WithPrefilter(false).
+		WithEventTimeout(time.Millisecond).
+		WithClock(clock.NewManual(time.Time{})).
+		BuildFromRuleset(createBatchTestRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	result, err := engine.Evaluate(event)
+	if err != nil || len(result.MatchedRules) != 1 {
+		t.Errorf("Expected rule 1 to match within the timeout, got %v, %v", result, err)
+	}
+}
+
+func TestEngineCaseInsensitiveFields(t *testing.T) {
+	event := map[string]interface{}{"eventid": "4624", "PROCESSNAME": "powershell.exe"}
+
+	for _, backend := range []Backend{BackendDAG, BackendVM, BackendInterpreter} {
+		for _, fold := range []bool{false, true} {
+			engine, err := NewDagEngineBuilder().
+				WithPrefilter(false).
+				WithBackend(backend).
+				WithCaseInsensitiveFields(fold).
+				BuildFromRuleset(createBatchTestRuleset())
+			if err != nil {
+				t.Fatalf("%s: failed to create engine: %v", backend, err)
+			}
+			result, err := engine.Evaluate(event)
+			if err != nil {
+				t.Fatalf("%s: evaluation failed: %v", backend, err)
+			}
+			// Rule 1 needs EventID and ProcessName to match, rule 2 ProcessName not to
+			expected := []ir.RuleID{2}
+			if fold {
+				expected = []ir.RuleID{1}
+			}
+			if !reflect.DeepEqual(result.MatchedRules, expected) {
+				t.Errorf("%s: expected rules %v to match (case-insensitive: %v), got %v", backend, expected, fold, result.MatchedRules)
+			}
+			results, err := engine.EvaluateBatch([]interface{}{event, event})
+			if err != nil || len(results[1].MatchedRules) != len(result.MatchedRules) {
+				t.Errorf("%s: expected batches to resolve fields like single events, got %v, %v", backend, results, err)
+			}
+		}
+	}
+}
+
+func TestDagEngineEvaluateInto(t *testing.T) {
+	engine, err := NewDagEngineBuilder().
+		WithPrefilter(false).
+		BuildFromRuleset(createBatchTestRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	both := map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"}
+	other := map[string]interface{}{"EventID": "4624", "ProcessName": "cmd.exe"}
+
+	result := &DagEvaluationResult{}
+	if err := engine.EvaluateInto(both, result); err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 || result.MatchedRules[0] != 1 {
+		t.Fatalf("Expected rule 1 to match, got %v", result.MatchedRules)
+	}
+	matched := &result.MatchedRules[0]
+
+	// The previous matches are replaced in the same backing array
+	if err := engine.EvaluateInto(other, result); err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 || result.MatchedRules[0] != 2 || &result.MatchedRules[0] != matched {
+		t.Errorf("Expected rule 2 to match in the reused slice, got %v", result.MatchedRules)
+	}
+
+	into := testing.AllocsPerRun(100, func() {
+		engine.EvaluateInto(both, result)
+	})
+	fresh := testing.AllocsPerRun(100, func() {
+		engine.Evaluate(both)
+	})
+	// The result and its matched rule slice are reused
+	if into > fresh-2 {
+		t.Errorf("Expected EvaluateInto to save at least 2 allocations, got %v vs %v", into, fresh)
+	}
+}
+
+func benchmarkEngineEvaluation(b *testing.B, evaluate func(engine *DagEngine, event interface{}) error) {
+	engine, err := NewDagEngineBuilder().
+		WithPrefilter(false).
+		BuildFromRuleset(createBatchTestRuleset())
+	if err != nil {
+		b.Fatalf("Failed to create engine: %v", err)
+	}
+	event := map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := evaluate(engine, event); err != nil {
+			b.Fatalf("Evaluation failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkDagEngineEvaluate(b *testing.B) {
+	benchmarkEngineEvaluation(b, func(engine *DagEngine, event interface{}) error {
+		_, err := engine.Evaluate(event)
+		return err
+	})
+}
+
+func BenchmarkDagEngineEvaluateInto(b *testing.B) {
+	result := &DagEvaluationResult{}
+	benchmarkEngineEvaluation(b, func(engine *DagEngine, event interface{}) error {
+		return engine.EvaluateInto(event, result)
+	})
+}
@@ -0,0 +1,46 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+)
+
+// Enricher adds fields to events before they are evaluated, so rules can
+// match on data the event source does not carry, such as the country of an
+// IP address or the owner of a host. Enrichers only see map events; other
+// events are evaluated as is.
+type Enricher interface {
+	// Enrich adds fields to the event. An error fails the evaluation of
+	// the event.
+	Enrich(ctx context.Context, event map[string]interface{}) error
+}
+
+// EnricherFunc adapts a function to the Enricher interface
+type EnricherFunc func(ctx context.Context, event map[string]interface{}) error
+
+// Enrich calls f(ctx, event)
+func (f EnricherFunc) Enrich(ctx context.Context, event map[string]interface{}) error {
+	return f(ctx, event)
+}
+
+// WithEnricher adds an enricher, run after the enrichers added before it
+func (b *DagEngineBuilder) WithEnricher(enricher Enricher) *DagEngineBuilder {
+	b.config.Enrichers = append(b.config.Enrichers, enricher)
+	return b
+}
+
+// enrichEvent runs the enrichers on a copy of the event's top-level fields,
+// leaving the caller's event unchanged. Enrichers replacing nested values
+// must copy them too.
+func (e *DagEngine) enrichEvent(event map[string]interface{}) (map[string]interface{}, error) {
+	enriched := make(map[string]interface{}, len(event)+len(e.config.Enrichers))
+	for field, value := range event {
+		enriched[field] = value
+	}
+	for _, enricher := range e.config.Enrichers {
+		if err := enricher.Enrich(context.Background(), enriched); err != nil {
+			return nil, fmt.Errorf("event enrichment failed: %w", err)
+		}
+	}
+	return enriched, nil
+}
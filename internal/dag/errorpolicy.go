@@ -0,0 +1,105 @@
+package dag
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// ErrorPolicy selects what happens when a primitive fails to match an event
+// at runtime, e.g. a matcher rejecting a malformed field value
+type ErrorPolicy int
+
+const (
+	// ErrorFailFast fails the evaluation of the event, and of the whole
+	// batch it belongs to
+	ErrorFailFast ErrorPolicy = iota
+	// ErrorNoMatch treats the failing primitive as not matching, so the
+	// other rules still evaluate the event. Errors are counted in
+	// EngineStats and a sample of them is logged.
+	ErrorNoMatch
+)
+
+var errorPolicyNames = map[ErrorPolicy]string{
+	ErrorFailFast: "fail_fast",
+	ErrorNoMatch:  "no_match",
+}
+
+func (policy ErrorPolicy) String() string {
+	if name, exists := errorPolicyNames[policy]; exists {
+		return name
+	}
+	return "fail_fast"
+}
+
+// primitiveErrorLogInterval logs the first primitive error and then one of
+// every primitiveErrorLogInterval errors, so a field that is malformed in
+// every event cannot flood the logs
+const primitiveErrorLogInterval = 1000
+
+// PrimitiveErrorStats counts the failed primitive evaluations treated as no
+// match
+type PrimitiveErrorStats struct {
+	Total uint64
+
+	// Errors by the event field of the failing primitive
+	ByField map[string]uint64
+}
+
+// primitiveErrors absorbs primitive errors under ErrorNoMatch. It is shared
+// by every evaluator of an engine, including parallel workers.
+type primitiveErrors struct {
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	total   uint64
+	byField map[string]uint64
+}
+
+// newPrimitiveErrors returns the error tracker of a policy (nil = fail fast)
+func newPrimitiveErrors(policy ErrorPolicy, logger *slog.Logger) *primitiveErrors {
+	if policy != ErrorNoMatch {
+		return nil
+	}
+	return &primitiveErrors{logger: logger, byField: make(map[string]uint64)}
+}
+
+// absorb counts a primitive error and reports whether evaluation goes on
+// with the primitive as not matching. Timeouts always fail the event.
+func (t *primitiveErrors) absorb(primitiveId ir.PrimitiveID, primitive *CompiledPrimitive, err error) bool {
+	if t == nil || errors.IsType(err, errors.ErrorTypeExecutionTimeout) {
+		return false
+	}
+
+	t.mu.Lock()
+	t.total++
+	t.byField[primitive.Field]++
+	total := t.total
+	t.mu.Unlock()
+
+	if (total-1)%primitiveErrorLogInterval == 0 && t.logger != nil {
+		t.logger.Warn("primitive evaluation failed, treating it as no match",
+			slog.Any("primitive_id", primitiveId),
+			slog.String("field", primitive.Field),
+			slog.Uint64("errors", total),
+			slog.Any("error", err))
+	}
+	return true
+}
+
+// snapshot returns the error counts (nil = fail fast)
+func (t *primitiveErrors) snapshot() *PrimitiveErrorStats {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := &PrimitiveErrorStats{Total: t.total, ByField: make(map[string]uint64, len(t.byField))}
+	for field, count := range t.byField {
+		stats.ByField[field] = count
+	}
+	return stats
+}
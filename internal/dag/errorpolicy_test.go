@@ -0,0 +1,101 @@
+package dag
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// createFailingRuleset returns the batch test ruleset with a ProcessName
+// modifier that fails on values starting with "bad"
+func createFailingRuleset() (*CompiledRuleset, func(string) (string, error)) {
+	ruleset := createBatchTestRuleset()
+	ruleset.Primitives[1].Modifiers = []string{"strict"}
+	strict := func(input string) (string, error) {
+		if strings.HasPrefix(input, "bad") {
+			return "", fmt.Errorf("malformed value %q", input)
+		}
+		return input, nil
+	}
+	return ruleset, strict
+}
+
+func TestPrimitiveErrorPolicyFailFast(t *testing.T) {
+	ruleset, strict := createFailingRuleset()
+	engine, err := NewDagEngineBuilder().
+		WithPrefilter(false).
+		WithCustomModifier("strict", strict).
+		BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	bad := map[string]interface{}{"EventID": "4624", "ProcessName": "bad.exe"}
+	if _, err := engine.Evaluate(bad); !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeExecution) {
+		t.Errorf("Expected an execution error, got %v", err)
+	}
+	if _, err := engine.EvaluateBatch([]interface{}{bad, bad}); err == nil {
+		t.Error("Expected the batch to fail")
+	}
+	if stats := engine.Stats(); stats.PrimitiveErrors != nil {
+		t.Errorf("Expected no primitive error stats, got %+v", stats.PrimitiveErrors)
+	}
+}
+
+func TestPrimitiveErrorPolicyNoMatch(t *testing.T) {
+	good := map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"}
+	bad := map[string]interface{}{"EventID": "4624", "ProcessName": "bad.exe"}
+
+	for _, backend := range []Backend{BackendDAG, BackendVM, BackendInterpreter} {
+		var buf bytes.Buffer
+		ruleset, strict := createFailingRuleset()
+		engine, err := NewDagEngineBuilder().
+			WithPrefilter(false).
+			WithBackend(backend).
+			WithCustomModifier("strict", strict).
+			WithPrimitiveErrorPolicy(ErrorNoMatch).
+			WithLogger(slog.New(slog.NewTextHandler(&buf, nil))).
+			BuildFromRuleset(ruleset)
+		if err != nil {
+			t.Fatalf("%s: failed to create engine: %v", backend, err)
+		}
+
+		result, err := engine.Evaluate(bad)
+		if err != nil {
+			t.Fatalf("%s: expected the error to be absorbed, got %v", backend, err)
+		}
+		// The failing primitive is false, so its negation matches
+		if len(result.MatchedRules) != 1 || result.MatchedRules[0] != 2 {
+			t.Errorf("%s: expected only rule 2 to match, got %v", backend, result.MatchedRules)
+		}
+
+		// One malformed event no longer drops the rest of the batch
+		results, err := engine.EvaluateBatch([]interface{}{good, bad, good})
+		if err != nil {
+			t.Fatalf("%s: batch failed: %v", backend, err)
+		}
+		if len(results[0].MatchedRules) != 1 || results[0].MatchedRules[0] != 1 ||
+			len(results[2].MatchedRules) != 1 || results[2].MatchedRules[0] != 1 {
+			t.Errorf("%s: expected the valid events to match rule 1, got %v and %v",
+				backend, results[0].MatchedRules, results[2].MatchedRules)
+		}
+
+		// The interpreter matches the shared primitive once per rule
+		failures := uint64(2)
+		if backend == BackendInterpreter {
+			failures = 4
+		}
+		stats := engine.Stats().PrimitiveErrors
+		if stats == nil || stats.Total != failures || stats.ByField["ProcessName"] != failures {
+			t.Errorf("%s: unexpected primitive error stats: %+v", backend, stats)
+		}
+		// Only the first of the errors is logged
+		if logged := strings.Count(buf.String(), "primitive evaluation failed"); logged != 1 {
+			t.Errorf("%s: expected 1 logged error, got %d: %s", backend, logged, buf.String())
+		}
+	}
+}
@@ -2,15 +2,63 @@ package dag
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
 	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
 	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
 )
 
 type DagEvaluationResult struct {
-	MatchedRules         []ir.RuleID
+	MatchedRules []ir.RuleID
+
+	// SIGMA UUIDs of MatchedRules, index-aligned (empty for rules without
+	// an id; only set by DagEngine)
+	MatchedRuleUUIDs []string
+
 	NodesEvaluated       int
 	PrimitiveEvaluations int
+
+	// Per-rule match details (only populated when match details are enabled)
+	RuleMatches []RuleMatch
+
+	// Primitive errors treated as no match while evaluating the event
+	// (see ErrorNoMatch)
+	PrimitiveErrors int
+}
+
+// RuleMatch describes why a rule fired
+type RuleMatch struct {
+	RuleID ir.RuleID
+
+	// SIGMA UUID of the rule (only set by DagEngine)
+	RuleUUID string
+
+	// Selections whose primitives all matched, sorted by name
+	Selections []string
+
+	// Primitives that matched on the positive (non-negated) path to the result node
+	Primitives []PrimitiveMatch
+
+	// Event values of the rule's `fields:` list, keyed by rule field name
+	// (only populated when field capture is enabled)
+	Fields map[string]interface{}
+}
+
+// PrimitiveMatch describes a single matched primitive
+type PrimitiveMatch struct {
+	PrimitiveID ir.PrimitiveID
+	Field       string
+	MatchType   string
+
+	// Event value of the field (before modifiers)
+	FieldValue string
+
+	// Primitive value that matched and its index in the value list (-1 = unknown)
+	MatchedValue string
+	ValueIndex   int
 }
 
 func NewDagEvaluationResult() *DagEvaluationResult {
@@ -23,8 +71,20 @@ func NewDagEvaluationResult() *DagEvaluationResult {
 
 type DagEvaluator struct {
 	dag                  *CompiledDag
-	nodeResults          map[uint32]bool
-	fastResults          []bool
+	primitives           map[uint32]*CompiledPrimitive
+	nodeResults          Bitset
+	primitiveResults     Bitset
+	primitiveEvaluated   Bitset
+	primitiveCapacity    int
+	eventCtx             *matcher.EventContext
+	collectDetails       bool
+	captureFields        bool
+	inactiveResults      map[NodeId]bool
+	ruleOrder            []ruleResultNode
+	eventTimeout         time.Duration
+	clock                clock.Clock
+	foldFieldCase        bool
+	primitiveErrors      *primitiveErrors
 	nodesEvaluated       int
 	primitiveEvaluations int
 	prefilterHits        int
@@ -32,10 +92,21 @@ type DagEvaluator struct {
 }
 
 func NewDagEvaluatorWithPrimitives(dag *CompiledDag) *DagEvaluator {
+	// Size primitive bitsets from the nodes so duplicate (non-CSE'd)
+	// primitive nodes share a result slot
+	primitiveCapacity := 0
+	for i := range dag.Nodes {
+		if primitiveId := dag.Nodes[i].NodeType.PrimitiveId; primitiveId != nil {
+			primitiveCapacity = max(primitiveCapacity, int(*primitiveId)+1)
+		}
+	}
+
 	return &DagEvaluator{
 		dag:                  dag,
-		nodeResults:          make(map[uint32]bool),
-		fastResults:          make([]bool, len(dag.Nodes)),
+		nodeResults:          acquireBitset(len(dag.Nodes)),
+		primitiveResults:     acquireBitset(primitiveCapacity),
+		primitiveEvaluated:   acquireBitset(primitiveCapacity),
+		primitiveCapacity:    primitiveCapacity,
 		nodesEvaluated:       0,
 		primitiveEvaluations: 0,
 		prefilterHits:        0,
@@ -48,7 +119,277 @@ func NewDagEvaluatorWithPrimitivesAndPrefilter(dag *CompiledDag) *DagEvaluator {
 	return NewDagEvaluatorWithPrimitives(dag)
 }
 
-func (eval *DagEvaluator) Evaluate(event map[string]interface{}) (*DagEvaluationResult, error) {
+// NewDagEvaluatorWithCompiledPrimitives creates an evaluator that matches
+// primitive nodes against events using the given compiled primitives
+func NewDagEvaluatorWithCompiledPrimitives(dag *CompiledDag, primitives map[uint32]*CompiledPrimitive) *DagEvaluator {
+	eval := NewDagEvaluatorWithPrimitives(dag)
+	eval.primitives = primitives
+	return eval
+}
+
+// WithMatchDetails enables per-rule match details in evaluation results
+func (eval *DagEvaluator) WithMatchDetails(enable bool) *DagEvaluator {
+	eval.collectDetails = enable
+	return eval
+}
+
+// WithFieldCapture enables extraction of each matched rule's `fields:` values
+func (eval *DagEvaluator) WithFieldCapture(enable bool) *DagEvaluator {
+	eval.captureFields = enable
+	return eval
+}
+
+// WithInactiveRules marks the result nodes of the given rules inactive so
+// they never report a match
+func (eval *DagEvaluator) WithInactiveRules(rules []ir.RuleID) *DagEvaluator {
+	eval.inactiveResults = nil
+	for _, ruleId := range rules {
+		if resultNodeId, exists := eval.dag.RuleResults[ruleId]; exists {
+			if eval.inactiveResults == nil {
+				eval.inactiveResults = make(map[NodeId]bool, len(rules))
+			}
+			eval.inactiveResults[resultNodeId] = true
+		}
+	}
+	return eval
+}
+
+// evaluatorOptions holds the evaluation settings an engine applies to every
+// evaluator it creates (single, batch and parallel)
+type evaluatorOptions struct {
+	collectDetails bool
+	captureFields  bool
+	inactiveRules  []ir.RuleID
+	eventTimeout   time.Duration
+	clock          clock.Clock
+
+	// Resolve event fields case-insensitively
+	foldFieldCase bool
+
+	// Absorbs primitive errors under ErrorNoMatch (nil = fail fast)
+	primitiveErrors *primitiveErrors
+}
+
+// withOptions applies engine evaluation settings to the evaluator
+func (eval *DagEvaluator) withOptions(options evaluatorOptions) *DagEvaluator {
+	eval.primitiveErrors = options.primitiveErrors
+	eval.foldFieldCase = options.foldFieldCase
+	return eval.WithMatchDetails(options.collectDetails).
+		WithFieldCapture(options.captureFields).
+		WithInactiveRules(options.inactiveRules).
+		WithEventTimeout(options.eventTimeout, options.clock)
+}
+
+// WithEventTimeout limits the matching time of each event (0 = unlimited),
+// measured on source (nil = system clock). Evaluation of an event fails
+// with an ErrorTypeExecutionTimeout error once the limit has passed.
+func (eval *DagEvaluator) WithEventTimeout(timeout time.Duration, source clock.Clock) *DagEvaluator {
+	eval.eventTimeout = timeout
+	eval.clock = source
+	return eval
+}
+
+// newEventContext creates the context of one event, with a deadline when an
+// event timeout is set
+func newEventContext(event interface{}, timeout time.Duration, source clock.Clock, foldFieldCase bool) *matcher.EventContext {
+	eventCtx := matcher.NewEventContext(event)
+	eventCtx.SetCaseInsensitiveFields(foldFieldCase)
+	if timeout > 0 {
+		source = clock.Or(source)
+		eventCtx.SetDeadline(source.Now().Add(timeout), source)
+	}
+	return eventCtx
+}
+
+func (eval *DagEvaluator) Evaluate(event interface{}) (*DagEvaluationResult, error) {
+	result := &DagEvaluationResult{}
+	if err := eval.EvaluateInto(event, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// EvaluateInto evaluates an event into a caller-provided result, reusing
+// the capacity of its slices instead of allocating a new result. The
+// result's previous contents are discarded; its slices are only valid until
+// the result is reused.
+func (eval *DagEvaluator) EvaluateInto(event interface{}, result *DagEvaluationResult) error {
+	eval.eventCtx = newEventContext(event, eval.eventTimeout, eval.clock, eval.foldFieldCase)
+	defer func() { eval.eventCtx = nil }()
+
+	result.reset()
+	if err := eval.evaluate(event, result); err != nil {
+		return err
+	}
+	result.PrimitiveErrors = eval.eventCtx.RecoveredErrors()
+
+	if (eval.collectDetails || eval.captureFields) && len(result.MatchedRules) > 0 {
+		result.RuleMatches = eval.collectRuleMatches(result.MatchedRules)
+	}
+
+	return nil
+}
+
+// reset empties the result for reuse, keeping the capacity of its slices
+func (result *DagEvaluationResult) reset() {
+	result.MatchedRules = result.MatchedRules[:0]
+	result.MatchedRuleUUIDs = result.MatchedRuleUUIDs[:0]
+	result.NodesEvaluated = 0
+	result.PrimitiveEvaluations = 0
+	result.RuleMatches = result.RuleMatches[:0]
+	result.PrimitiveErrors = 0
+}
+
+// EvaluateAnyMatch reports whether any active rule matches an event. Rules
+// are evaluated one at a time in rule ID order, each evaluating only the
+// nodes its result depends on with short-circuit AND/OR, and evaluation
+// stops at the first rule that matches. Nodes shared between rules are
+// evaluated at most once.
+func (eval *DagEvaluator) EvaluateAnyMatch(event interface{}) (bool, error) {
+	if eval.ruleOrder == nil {
+		eval.ruleOrder = make([]ruleResultNode, 0, len(eval.dag.RuleResults))
+		for ruleId, nodeId := range eval.dag.RuleResults {
+			eval.ruleOrder = append(eval.ruleOrder, ruleResultNode{ruleId: ruleId, nodeId: nodeId})
+		}
+		sort.Slice(eval.ruleOrder, func(i, j int) bool { return eval.ruleOrder[i].ruleId < eval.ruleOrder[j].ruleId })
+	}
+
+	eval.eventCtx = newEventContext(event, eval.eventTimeout, eval.clock, eval.foldFieldCase)
+	defer func() { eval.eventCtx = nil }()
+	eval.reset()
+	result := &DagEvaluationResult{}
+	err := eval.evaluateRulesOnDemand(event, eval.ruleOrder, func(matched []ir.RuleID) bool {
+		return true
+	}, false, result)
+	if err != nil {
+		return false, err
+	}
+	return len(result.MatchedRules) > 0, nil
+}
+
+// collectsMatches reports whether match details or captured fields are
+// attached to results, which need every node of the matched rules
+func (eval *DagEvaluator) collectsMatches() bool {
+	return eval.collectDetails || eval.captureFields
+}
+
+// evaluateRulesOnDemand evaluates active rules in the given order into
+// result, each evaluating only the nodes its result depends on, with
+// short-circuit AND/OR unless complete is set. After every match, stop
+// decides from the rules matched so far whether to skip the remaining
+// rules. Matched rules are appended in evaluation order. The caller sets up
+// the event context and resets the evaluator and result, so primitive
+// results already cached for the event are reused.
+func (eval *DagEvaluator) evaluateRulesOnDemand(
+	event interface{},
+	rules []ruleResultNode,
+	stop func(matched []ir.RuleID) bool,
+	complete bool,
+	result *DagEvaluationResult,
+) error {
+	evaluated := acquireBitset(len(eval.dag.Nodes))
+	defer releaseBitset(evaluated)
+	for _, rule := range rules {
+		if eval.inactiveResults[rule.nodeId] {
+			continue
+		}
+		matched, err := eval.evaluateNodeOnDemand(rule.nodeId, event, evaluated, complete)
+		if err != nil {
+			return err
+		}
+		if matched {
+			result.MatchedRules = append(result.MatchedRules, rule.ruleId)
+			if stop(result.MatchedRules) {
+				break
+			}
+		}
+	}
+
+	result.NodesEvaluated = eval.nodesEvaluated
+	result.PrimitiveEvaluations = eval.primitiveEvaluations
+	result.PrimitiveErrors = eval.eventCtx.RecoveredErrors()
+	return nil
+}
+
+// evaluateNodeOnDemand evaluates a node after the dependencies its result
+// needs, with the same semantics as evaluateNode. Results are memoized in
+// nodeResults, with evaluated marking the nodes already resolved. With
+// complete set, AND and OR nodes evaluate every dependency instead of
+// stopping at the first that decides them.
+func (eval *DagEvaluator) evaluateNodeOnDemand(nodeId NodeId, event interface{}, evaluated Bitset, complete bool) (bool, error) {
+	if evaluated.Test(uint32(nodeId)) {
+		return eval.nodeResults.Test(uint32(nodeId)), nil
+	}
+	node := eval.dag.GetNode(nodeId)
+	if node == nil {
+		return false, errors.NewExecutionError(fmt.Sprintf("Node not found: %d", nodeId))
+	}
+
+	var result bool
+	var err error
+	switch node.NodeType.Type {
+	case "Primitive":
+		if node.NodeType.PrimitiveId != nil {
+			result, err = eval.evaluatePrimitiveCached(*node.NodeType.PrimitiveId, event)
+		}
+
+	case "Logical":
+		if node.NodeType.Operation == nil {
+			break
+		}
+		switch *node.NodeType.Operation {
+		case LogicalAnd:
+			result = len(node.Dependencies) > 0
+			for _, depId := range node.Dependencies {
+				matched, depErr := eval.evaluateNodeOnDemand(depId, event, evaluated, complete)
+				if depErr != nil {
+					result, err = false, depErr
+					break
+				}
+				result = result && matched
+				if !result && !complete {
+					break
+				}
+			}
+		case LogicalOr:
+			for _, depId := range node.Dependencies {
+				matched, depErr := eval.evaluateNodeOnDemand(depId, event, evaluated, complete)
+				if depErr != nil {
+					result, err = false, depErr
+					break
+				}
+				result = result || matched
+				if result && !complete {
+					break
+				}
+			}
+		case LogicalNot:
+			if len(node.Dependencies) == 1 {
+				var matched bool
+				matched, err = eval.evaluateNodeOnDemand(node.Dependencies[0], event, evaluated, complete)
+				result = !matched
+			}
+		}
+
+	case "Result":
+		if !eval.inactiveResults[nodeId] && len(node.Dependencies) == 1 {
+			result, err = eval.evaluateNodeOnDemand(node.Dependencies[0], event, evaluated, complete)
+		}
+
+	case "Prefilter":
+		result = true
+	}
+	if err != nil {
+		return false, err
+	}
+
+	evaluated.Set(uint32(nodeId))
+	eval.nodeResults.SetTo(uint32(nodeId), result)
+	eval.nodesEvaluated++
+	return result, nil
+}
+
+func (eval *DagEvaluator) evaluate(event interface{}, result *DagEvaluationResult) error {
 	// Early termination with prefilter if available (TODO: implement later)
 	// if eval.prefilter != nil {
 	//     if !eval.prefilter.Matches(event) {
@@ -64,29 +405,37 @@ func (eval *DagEvaluator) Evaluate(event map[string]interface{}) (*DagEvaluation
 
 	// Ultra-fast path for single primitive rules (most common case)
 	if len(eval.dag.RuleResults) == 1 && len(eval.dag.Nodes) <= 3 {
-		return eval.evaluateSinglePrimitiveFast(event)
+		return eval.evaluateSinglePrimitiveFast(event, result)
 	}
 
-	// Use fast-path for small DAGs to avoid HashMap overhead
-	if len(eval.dag.Nodes) <= 32 {
-		return eval.evaluateFastPath(event)
-	} else {
-		return eval.evaluateStandardPath(event)
-	}
+	return eval.evaluateStandardPath(event, result)
 }
 
 func (eval *DagEvaluator) reset() {
 	eval.nodesEvaluated = 0
 	eval.primitiveEvaluations = 0
 
-	// Clear maps/slices
-	for k := range eval.nodeResults {
-		delete(eval.nodeResults, k)
+	// Re-acquire result buffers after Release, otherwise clear them
+	if eval.nodeResults == nil {
+		eval.nodeResults = acquireBitset(len(eval.dag.Nodes))
+		eval.primitiveResults = acquireBitset(eval.primitiveCapacity)
+		eval.primitiveEvaluated = acquireBitset(eval.primitiveCapacity)
+		return
 	}
+	eval.nodeResults.Reset()
+	eval.primitiveResults.Reset()
+	eval.primitiveEvaluated.Reset()
+}
 
-	for i := range eval.fastResults {
-		eval.fastResults[i] = false
-	}
+// Release returns the evaluator's result buffers to the shared pool. The
+// evaluator stays usable and re-acquires buffers on its next evaluation.
+func (eval *DagEvaluator) Release() {
+	releaseBitset(eval.nodeResults)
+	releaseBitset(eval.primitiveResults)
+	releaseBitset(eval.primitiveEvaluated)
+	eval.nodeResults = nil
+	eval.primitiveResults = nil
+	eval.primitiveEvaluated = nil
 }
 
 func (eval *DagEvaluator) evaluateLogicalOperation(operation LogicalOp, dependencies []NodeId) bool {
@@ -94,7 +443,7 @@ func (eval *DagEvaluator) evaluateLogicalOperation(operation LogicalOp, dependen
 	case LogicalAnd:
 		// AND: tất cả dependencies phải true
 		for _, depId := range dependencies {
-			if result, exists := eval.nodeResults[uint32(depId)]; !exists || !result {
+			if !eval.nodeResults.Test(uint32(depId)) {
 				return false
 			}
 		}
@@ -103,7 +452,7 @@ func (eval *DagEvaluator) evaluateLogicalOperation(operation LogicalOp, dependen
 	case LogicalOr:
 		// OR: ít nhất một dependency phải true
 		for _, depId := range dependencies {
-			if result, exists := eval.nodeResults[uint32(depId)]; exists && result {
+			if eval.nodeResults.Test(uint32(depId)) {
 				return true
 			}
 		}
@@ -112,9 +461,7 @@ func (eval *DagEvaluator) evaluateLogicalOperation(operation LogicalOp, dependen
 	case LogicalNot:
 		// NOT: chỉ có 1 dependency, đảo ngược kết quả
 		if len(dependencies) == 1 {
-			if result, exists := eval.nodeResults[uint32(dependencies[0])]; exists {
-				return !result
-			}
+			return !eval.nodeResults.Test(uint32(dependencies[0]))
 		}
 		return false
 
@@ -122,91 +469,213 @@ func (eval *DagEvaluator) evaluateLogicalOperation(operation LogicalOp, dependen
 		return false
 	}
 }
-func (eval *DagEvaluator) evaluateLogicalOperationFast(operation LogicalOp, dependencies []NodeId) bool {
-	switch operation {
-	case LogicalAnd:
-		// AND: tất cả dependencies phải true
-		for _, depId := range dependencies {
-			if int(depId) >= len(eval.fastResults) || !eval.fastResults[depId] {
-				return false
-			}
+
+func (eval *DagEvaluator) evaluatePrimitive(primitiveId ir.PrimitiveID, event interface{}) (bool, error) {
+	eval.primitiveEvaluations++
+
+	primitive, exists := eval.primitives[uint32(primitiveId)]
+	if !exists || primitive == nil {
+		// Không có matcher cho primitive này => không match
+		return false, nil
+	}
+	return matchPrimitive(primitiveId, primitive, eval.context(event), event, eval.primitiveErrors)
+}
+
+// evaluatePrimitiveCached evaluates a primitive at most once per event, so
+// primitives shared by several rules cost one match even when the DAG has
+// duplicate primitive nodes
+func (eval *DagEvaluator) evaluatePrimitiveCached(primitiveId ir.PrimitiveID, event interface{}) (bool, error) {
+	if eval.primitiveEvaluated.Test(uint32(primitiveId)) {
+		return eval.primitiveResults.Test(uint32(primitiveId)), nil
+	}
+
+	result, err := eval.evaluatePrimitive(primitiveId, event)
+	if err != nil {
+		return false, err
+	}
+	eval.primitiveEvaluated.SetTo(uint32(primitiveId), true)
+	eval.primitiveResults.SetTo(uint32(primitiveId), result)
+	return result, nil
+}
+
+// matchPrimitive matches a compiled primitive against an event. Errors are
+// returned unless the error tracker absorbs them (nil = fail fast).
+func matchPrimitive(primitiveId ir.PrimitiveID, primitive *CompiledPrimitive, eventCtx *matcher.EventContext, event interface{}, errs *primitiveErrors) (bool, error) {
+	if primitive.Matcher != nil {
+		matched, err := primitive.Matcher.Matches(eventCtx)
+		if err != nil && errs.absorb(primitiveId, primitive, err) {
+			eventCtx.RecordError()
+			return false, nil
 		}
-		return len(dependencies) > 0
+		if errors.IsType(err, errors.ErrorTypeExecutionTimeout) {
+			return false, err
+		}
+		if err != nil {
+			return false, errors.Wrap(errors.ErrorTypeExecution,
+				fmt.Sprintf("primitive %d (%s) evaluation failed", primitiveId, primitive.Field), err)
+		}
+		return matched, nil
+	}
 
-	case LogicalOr:
-		// OR: ít nhất một dependency phải true
-		for _, depId := range dependencies {
-			if int(depId) < len(eval.fastResults) && eval.fastResults[depId] {
-				return true
-			}
+	if primitive.MatcherFunc != nil {
+		return primitive.MatcherFunc(event), nil
+	}
+
+	return false, nil
+}
+
+// context returns the event context for the current evaluation, creating one
+// when the evaluator is driven directly (e.g. from tests)
+func (eval *DagEvaluator) context(event interface{}) *matcher.EventContext {
+	if eval.eventCtx == nil {
+		eval.eventCtx = matcher.NewEventContext(event)
+	}
+	return eval.eventCtx
+}
+
+// nodeResult returns the result of an already evaluated node
+func (eval *DagEvaluator) nodeResult(nodeId NodeId) bool {
+	return eval.nodeResults.Test(uint32(nodeId))
+}
+
+// collectRuleMatches builds match details for the matched rules
+func (eval *DagEvaluator) collectRuleMatches(matchedRules []ir.RuleID) []RuleMatch {
+	details := make(map[ir.PrimitiveID]*matcher.MatchResult)
+	ruleMatches := make([]RuleMatch, 0, len(matchedRules))
+
+	for _, ruleId := range matchedRules {
+		resultNodeId, exists := eval.dag.RuleResults[ruleId]
+		if !exists {
+			continue
 		}
-		return false
 
-	case LogicalNot:
-		// NOT: chỉ có 1 dependency, đảo ngược kết quả
-		if len(dependencies) == 1 && int(dependencies[0]) < len(eval.fastResults) {
-			return !eval.fastResults[dependencies[0]]
+		ruleMatch := RuleMatch{RuleID: ruleId}
+		if eval.captureFields {
+			ruleMatch.Fields = eval.captureRuleFields(ruleId)
+		}
+		if !eval.collectDetails {
+			ruleMatches = append(ruleMatches, ruleMatch)
+			continue
 		}
-		return false
 
-	default:
-		return false
+		// Primitives reachable from the result node without crossing a NOT node
+		visited := make(map[NodeId]bool)
+		var primitiveIds []ir.PrimitiveID
+		eval.collectPositivePrimitives(resultNodeId, visited, &primitiveIds)
+		sort.Slice(primitiveIds, func(i, j int) bool { return primitiveIds[i] < primitiveIds[j] })
+
+		for _, primitiveId := range primitiveIds {
+			ruleMatch.Primitives = append(ruleMatch.Primitives, eval.primitiveMatch(primitiveId, details))
+		}
+
+		for name, selectionPrimitives := range eval.dag.RuleSelections[ruleId] {
+			if eval.selectionMatched(selectionPrimitives) {
+				ruleMatch.Selections = append(ruleMatch.Selections, name)
+			}
+		}
+		sort.Strings(ruleMatch.Selections)
+
+		ruleMatches = append(ruleMatches, ruleMatch)
 	}
+
+	return ruleMatches
 }
 
-func (eval *DagEvaluator) evaluatePrimitive(primitiveId ir.PrimitiveID, event map[string]interface{}) (bool, error) {
-	eval.primitiveEvaluations++
+// captureRuleFields extracts the values of a rule's `fields:` list from the
+// current event; fields missing from the event are omitted
+func (eval *DagEvaluator) captureRuleFields(ruleId ir.RuleID) map[string]interface{} {
+	return extractRuleFields(eval.eventCtx, eval.dag.RuleFields[ruleId])
+}
 
-	// TODO: Implement actual primitive matching when CompiledPrimitive is ready
-	// For now, return false as placeholder
-	//
-	// Logic sẽ là:
-	// 1. Lấy CompiledPrimitive từ eval.primitives[primitiveId]
-	// 2. Áp dụng field matching logic
-	// 3. Trả về true/false
+// extractRuleFields extracts rule field values from an event context
+func extractRuleFields(eventCtx *matcher.EventContext, ruleFields []RuleField) map[string]interface{} {
+	if len(ruleFields) == 0 || eventCtx == nil {
+		return nil
+	}
 
-	return false, nil
+	fields := make(map[string]interface{}, len(ruleFields))
+	for _, field := range ruleFields {
+		value, exists, err := eventCtx.GetField(field.EventField)
+		if err != nil || !exists {
+			continue
+		}
+		fields[field.Name] = value
+	}
+	return fields
 }
 
-func (eval *DagEvaluator) evaluateNode(nodeId uint32, event map[string]interface{}) (bool, error) {
-	node := eval.dag.GetNode(NodeId(nodeId))
-	if node == nil {
-		return false, errors.NewExecutionError(fmt.Sprintf("Node not found: %d", nodeId))
+// collectPositivePrimitives walks dependencies collecting matched primitive IDs
+func (eval *DagEvaluator) collectPositivePrimitives(nodeId NodeId, visited map[NodeId]bool, out *[]ir.PrimitiveID) {
+	if visited[nodeId] {
+		return
+	}
+	visited[nodeId] = true
+
+	node := eval.dag.GetNode(nodeId)
+	if node == nil || !eval.nodeResult(nodeId) {
+		return
 	}
 
 	switch node.NodeType.Type {
 	case "Primitive":
 		if node.NodeType.PrimitiveId != nil {
-			return eval.evaluatePrimitive(*node.NodeType.PrimitiveId, event)
+			*out = append(*out, *node.NodeType.PrimitiveId)
 		}
-		return false, nil
-
 	case "Logical":
-		if node.NodeType.Operation != nil {
-			return eval.evaluateLogicalOperation(*node.NodeType.Operation, node.Dependencies), nil
+		if node.NodeType.Operation != nil && *node.NodeType.Operation == LogicalNot {
+			return
 		}
-		return false, nil
+		for _, depId := range node.Dependencies {
+			eval.collectPositivePrimitives(depId, visited, out)
+		}
+	default:
+		for _, depId := range node.Dependencies {
+			eval.collectPositivePrimitives(depId, visited, out)
+		}
+	}
+}
 
-	case "Result":
-		// Result node: trả về kết quả của dependency đầu tiên
-		if len(node.Dependencies) == 1 {
-			if result, exists := eval.nodeResults[uint32(node.Dependencies[0])]; exists {
-				return result, nil
-			}
+// selectionMatched reports whether every primitive of a selection matched
+func (eval *DagEvaluator) selectionMatched(primitiveIds []ir.PrimitiveID) bool {
+	if len(primitiveIds) == 0 {
+		return false
+	}
+	for _, primitiveId := range primitiveIds {
+		if !eval.primitiveResults.Test(uint32(primitiveId)) {
+			return false
 		}
-		return false, nil
+	}
+	return true
+}
 
-	case "Prefilter":
-		// Prefilter nodes đã được handle ở đầu evaluation
-		// Nếu đến đây thì prefilter đã pass
-		return true, nil
+// primitiveMatch builds the match detail for a matched primitive
+func (eval *DagEvaluator) primitiveMatch(primitiveId ir.PrimitiveID, cache map[ir.PrimitiveID]*matcher.MatchResult) PrimitiveMatch {
+	match := PrimitiveMatch{PrimitiveID: primitiveId, ValueIndex: -1}
 
-	default:
-		return false, nil
+	primitive, exists := eval.primitives[uint32(primitiveId)]
+	if !exists || primitive == nil {
+		return match
+	}
+	match.Field = primitive.Field
+	match.MatchType = primitive.MatchType
+
+	if primitive.Matcher == nil || eval.eventCtx == nil {
+		return match
+	}
+
+	detail, cached := cache[primitiveId]
+	if !cached {
+		detail = primitive.Matcher.MatchesWithResult(eval.eventCtx)
+		cache[primitiveId] = detail
 	}
+
+	match.FieldValue = detail.MatchedValue
+	match.MatchedValue = detail.MatchedPattern
+	match.ValueIndex = detail.PatternIndex
+	return match
 }
 
-func (eval *DagEvaluator) evaluateNodeFast(nodeId uint32, event map[string]interface{}) (bool, error) {
+func (eval *DagEvaluator) evaluateNode(nodeId uint32, event interface{}) (bool, error) {
 	node := eval.dag.GetNode(NodeId(nodeId))
 	if node == nil {
 		return false, errors.NewExecutionError(fmt.Sprintf("Node not found: %d", nodeId))
@@ -215,27 +684,29 @@ func (eval *DagEvaluator) evaluateNodeFast(nodeId uint32, event map[string]inter
 	switch node.NodeType.Type {
 	case "Primitive":
 		if node.NodeType.PrimitiveId != nil {
-			return eval.evaluatePrimitive(*node.NodeType.PrimitiveId, event)
+			return eval.evaluatePrimitiveCached(*node.NodeType.PrimitiveId, event)
 		}
 		return false, nil
 
 	case "Logical":
 		if node.NodeType.Operation != nil {
-			return eval.evaluateLogicalOperationFast(*node.NodeType.Operation, node.Dependencies), nil
+			return eval.evaluateLogicalOperation(*node.NodeType.Operation, node.Dependencies), nil
 		}
 		return false, nil
 
 	case "Result":
-		// Result node: trả về kết quả từ fastResults
+		if eval.inactiveResults[NodeId(nodeId)] {
+			return false, nil
+		}
+		// Result node: trả về kết quả của dependency đầu tiên
 		if len(node.Dependencies) == 1 {
-			depId := int(node.Dependencies[0])
-			if depId < len(eval.fastResults) {
-				return eval.fastResults[depId], nil
-			}
+			return eval.nodeResults.Test(uint32(node.Dependencies[0])), nil
 		}
 		return false, nil
 
 	case "Prefilter":
+		// Prefilter nodes đã được handle ở đầu evaluation
+		// Nếu đến đây thì prefilter đã pass
 		return true, nil
 
 	default:
@@ -243,67 +714,40 @@ func (eval *DagEvaluator) evaluateNodeFast(nodeId uint32, event map[string]inter
 	}
 }
 
-func (eval *DagEvaluator) evaluateStandardPath(event map[string]interface{}) (*DagEvaluationResult, error) {
+func (eval *DagEvaluator) evaluateStandardPath(event interface{}, result *DagEvaluationResult) error {
 	eval.reset()
-
-	// Evaluate nodes in topological order
-	for _, nodeId := range eval.dag.ExecutionOrder {
-		result, err := eval.evaluateNode(uint32(nodeId), event)
-		if err != nil {
-			return nil, err
-		}
-		eval.nodeResults[uint32(nodeId)] = result
-		eval.nodesEvaluated++
-	}
-
-	// Collect matched rules
-	var matchedRules []ir.RuleID
-	for ruleId, resultNodeId := range eval.dag.RuleResults {
-		if result, exists := eval.nodeResults[uint32(resultNodeId)]; exists && result {
-			matchedRules = append(matchedRules, ruleId)
-		}
-	}
-
-	return &DagEvaluationResult{
-		MatchedRules:         matchedRules,
-		NodesEvaluated:       eval.nodesEvaluated,
-		PrimitiveEvaluations: eval.primitiveEvaluations,
-	}, nil
+	return eval.evaluateAllNodes(event, result)
 }
 
-// evaluateFastPath - Fast-path evaluation for small DAGs using slice
-func (eval *DagEvaluator) evaluateFastPath(event map[string]interface{}) (*DagEvaluationResult, error) {
-	eval.reset()
-
+// evaluateAllNodes evaluates every node without resetting the evaluator,
+// reusing the primitive results already cached for the event
+func (eval *DagEvaluator) evaluateAllNodes(event interface{}, result *DagEvaluationResult) error {
 	// Evaluate nodes in topological order
 	for _, nodeId := range eval.dag.ExecutionOrder {
-		result, err := eval.evaluateNodeFast(uint32(nodeId), event)
+		matched, err := eval.evaluateNode(uint32(nodeId), event)
 		if err != nil {
-			return nil, err
-		}
-		if int(nodeId) < len(eval.fastResults) {
-			eval.fastResults[nodeId] = result
+			return err
 		}
+		eval.nodeResults.SetTo(uint32(nodeId), matched)
 		eval.nodesEvaluated++
 	}
 
 	// Collect matched rules
-	var matchedRules []ir.RuleID
+	matchedRules := result.MatchedRules[:0]
 	for ruleId, resultNodeId := range eval.dag.RuleResults {
-		if int(resultNodeId) < len(eval.fastResults) && eval.fastResults[resultNodeId] {
+		if eval.nodeResults.Test(uint32(resultNodeId)) {
 			matchedRules = append(matchedRules, ruleId)
 		}
 	}
 
-	return &DagEvaluationResult{
-		MatchedRules:         matchedRules,
-		NodesEvaluated:       eval.nodesEvaluated,
-		PrimitiveEvaluations: eval.primitiveEvaluations,
-	}, nil
+	result.MatchedRules = matchedRules
+	result.NodesEvaluated = eval.nodesEvaluated
+	result.PrimitiveEvaluations = eval.primitiveEvaluations
+	return nil
 }
 
 // evaluateSinglePrimitiveFast - Ultra-fast evaluation for single primitive rules
-func (eval *DagEvaluator) evaluateSinglePrimitiveFast(event map[string]interface{}) (*DagEvaluationResult, error) {
+func (eval *DagEvaluator) evaluateSinglePrimitiveFast(event interface{}, result *DagEvaluationResult) error {
 	eval.reset()
 
 	// Lấy rule duy nhất
@@ -316,8 +760,8 @@ func (eval *DagEvaluator) evaluateSinglePrimitiveFast(event map[string]interface
 	}
 
 	resultNode := eval.dag.GetNode(resultNodeId)
-	if resultNode == nil || resultNode.NodeType.Type != "Result" {
-		return eval.evaluateStandardPath(event) // fallback
+	if resultNode == nil || resultNode.NodeType.Type != "Result" || eval.inactiveResults[resultNodeId] {
+		return eval.evaluateStandardPath(event, result) // fallback
 	}
 
 	if len(resultNode.Dependencies) == 1 {
@@ -326,24 +770,28 @@ func (eval *DagEvaluator) evaluateSinglePrimitiveFast(event map[string]interface
 
 		if primitiveNode != nil && primitiveNode.NodeType.Type == "Primitive" && primitiveNode.NodeType.PrimitiveId != nil {
 			eval.nodesEvaluated = 2
-			result, err := eval.evaluatePrimitive(*primitiveNode.NodeType.PrimitiveId, event)
+			matched, err := eval.evaluatePrimitive(*primitiveNode.NodeType.PrimitiveId, event)
 			if err != nil {
-				return nil, err
+				return err
 			}
 
-			var matchedRules []ir.RuleID
-			if result {
-				matchedRules = append(matchedRules, ruleId)
+			eval.primitiveEvaluated.SetTo(uint32(*primitiveNode.NodeType.PrimitiveId), true)
+			if matched {
+				eval.nodeResults.SetTo(uint32(primitiveNodeId), true)
+				eval.nodeResults.SetTo(uint32(resultNodeId), true)
+				eval.primitiveResults.SetTo(uint32(*primitiveNode.NodeType.PrimitiveId), true)
 			}
 
-			return &DagEvaluationResult{
-				MatchedRules:         matchedRules,
-				NodesEvaluated:       eval.nodesEvaluated,
-				PrimitiveEvaluations: eval.primitiveEvaluations,
-			}, nil
+			result.MatchedRules = result.MatchedRules[:0]
+			if matched {
+				result.MatchedRules = append(result.MatchedRules, ruleId)
+			}
+			result.NodesEvaluated = eval.nodesEvaluated
+			result.PrimitiveEvaluations = eval.primitiveEvaluations
+			return nil
 		}
 	}
 
 	// Fallback to standard evaluation
-	return eval.evaluateStandardPath(event)
+	return eval.evaluateStandardPath(event, result)
 }
@@ -44,8 +44,8 @@ func TestDagEvaluatorCreation(t *testing.T) {
 	dag := createTestDagForEvaluator()
 	evaluator := NewDagEvaluatorWithPrimitives(dag)
 
-	if len(evaluator.fastResults) != len(dag.Nodes) {
-		t.Errorf("Expected fast results length %d, got %d", len(dag.Nodes), len(evaluator.fastResults))
+	if evaluator.nodeResults.Len() < len(dag.Nodes) {
+		t.Errorf("Expected node results to hold %d nodes, got %d", len(dag.Nodes), evaluator.nodeResults.Len())
 	}
 	if evaluator.nodesEvaluated != 0 {
 		t.Errorf("Expected 0 nodes evaluated, got %d", evaluator.nodesEvaluated)
@@ -60,19 +60,19 @@ func TestDagEvaluatorReset(t *testing.T) {
 	evaluator := NewDagEvaluatorWithPrimitives(dag)
 
 	// Simulate some state
-	evaluator.nodeResults[0] = true
-	evaluator.fastResults[0] = true
+	evaluator.nodeResults.SetTo(0, true)
+	evaluator.primitiveResults.SetTo(0, true)
 	evaluator.nodesEvaluated = 5
 	evaluator.primitiveEvaluations = 3
 
 	// Reset and verify
 	evaluator.reset()
 
-	if len(evaluator.nodeResults) != 0 {
-		t.Errorf("Expected empty node results after reset, got %d", len(evaluator.nodeResults))
+	if evaluator.nodeResults.Any() {
+		t.Errorf("Expected empty node results after reset, got %d", evaluator.nodeResults.Count())
 	}
-	if evaluator.fastResults[0] {
-		t.Error("Expected fast results to be reset to false")
+	if evaluator.primitiveResults.Test(0) {
+		t.Error("Expected primitive results to be reset to false")
 	}
 	if evaluator.nodesEvaluated != 0 {
 		t.Errorf("Expected 0 nodes evaluated after reset, got %d", evaluator.nodesEvaluated)
@@ -105,8 +105,8 @@ func TestEvaluateLogicalOperationAndSuccess(t *testing.T) {
 	evaluator := NewDagEvaluatorWithPrimitives(dag)
 
 	// Set up dependencies
-	evaluator.nodeResults[0] = true
-	evaluator.nodeResults[1] = true
+	evaluator.nodeResults.SetTo(0, true)
+	evaluator.nodeResults.SetTo(1, true)
 
 	result := evaluator.evaluateLogicalOperation(LogicalAnd, []NodeId{0, 1})
 	if !result {
@@ -119,8 +119,8 @@ func TestEvaluateLogicalOperationAndFailure(t *testing.T) {
 	evaluator := NewDagEvaluatorWithPrimitives(dag)
 
 	// Set up dependencies with one false
-	evaluator.nodeResults[0] = true
-	evaluator.nodeResults[1] = false
+	evaluator.nodeResults.SetTo(0, true)
+	evaluator.nodeResults.SetTo(1, false)
 
 	result := evaluator.evaluateLogicalOperation(LogicalAnd, []NodeId{0, 1})
 	if result {
@@ -133,8 +133,8 @@ func TestEvaluateLogicalOperationOrSuccess(t *testing.T) {
 	evaluator := NewDagEvaluatorWithPrimitives(dag)
 
 	// Set up dependencies with one true
-	evaluator.nodeResults[0] = false
-	evaluator.nodeResults[1] = true
+	evaluator.nodeResults.SetTo(0, false)
+	evaluator.nodeResults.SetTo(1, true)
 
 	result := evaluator.evaluateLogicalOperation(LogicalOr, []NodeId{0, 1})
 	if !result {
@@ -147,8 +147,8 @@ func TestEvaluateLogicalOperationOrFailure(t *testing.T) {
 	evaluator := NewDagEvaluatorWithPrimitives(dag)
 
 	// Set up dependencies with both false
-	evaluator.nodeResults[0] = false
-	evaluator.nodeResults[1] = false
+	evaluator.nodeResults.SetTo(0, false)
+	evaluator.nodeResults.SetTo(1, false)
 
 	result := evaluator.evaluateLogicalOperation(LogicalOr, []NodeId{0, 1})
 	if result {
@@ -161,7 +161,7 @@ func TestEvaluateLogicalOperationNotSuccess(t *testing.T) {
 	evaluator := NewDagEvaluatorWithPrimitives(dag)
 
 	// Set up dependency
-	evaluator.nodeResults[0] = false
+	evaluator.nodeResults.SetTo(0, false)
 
 	result := evaluator.evaluateLogicalOperation(LogicalNot, []NodeId{0})
 	if !result {
@@ -174,7 +174,7 @@ func TestEvaluateLogicalOperationNotFailure(t *testing.T) {
 	evaluator := NewDagEvaluatorWithPrimitives(dag)
 
 	// Set up dependency
-	evaluator.nodeResults[0] = true
+	evaluator.nodeResults.SetTo(0, true)
 
 	result := evaluator.evaluateLogicalOperation(LogicalNot, []NodeId{0})
 	if result {
@@ -182,88 +182,6 @@ func TestEvaluateLogicalOperationNotFailure(t *testing.T) {
 	}
 }
 
-func TestEvaluateLogicalOperationFastAndSuccess(t *testing.T) {
-	dag := createTestDagForEvaluator()
-	evaluator := NewDagEvaluatorWithPrimitives(dag)
-
-	// Set up fast results
-	evaluator.fastResults[0] = true
-	evaluator.fastResults[1] = true
-
-	result := evaluator.evaluateLogicalOperationFast(LogicalAnd, []NodeId{0, 1})
-	if !result {
-		t.Error("Expected fast AND operation with all true to return true")
-	}
-}
-
-func TestEvaluateLogicalOperationFastAndFailure(t *testing.T) {
-	dag := createTestDagForEvaluator()
-	evaluator := NewDagEvaluatorWithPrimitives(dag)
-
-	// Set up fast results with one false
-	evaluator.fastResults[0] = true
-	evaluator.fastResults[1] = false
-
-	result := evaluator.evaluateLogicalOperationFast(LogicalAnd, []NodeId{0, 1})
-	if result {
-		t.Error("Expected fast AND operation with one false to return false")
-	}
-}
-
-func TestEvaluateLogicalOperationFastOrSuccess(t *testing.T) {
-	dag := createTestDagForEvaluator()
-	evaluator := NewDagEvaluatorWithPrimitives(dag)
-
-	// Set up fast results with one true
-	evaluator.fastResults[0] = false
-	evaluator.fastResults[1] = true
-
-	result := evaluator.evaluateLogicalOperationFast(LogicalOr, []NodeId{0, 1})
-	if !result {
-		t.Error("Expected fast OR operation with one true to return true")
-	}
-}
-
-func TestEvaluateLogicalOperationFastOrFailure(t *testing.T) {
-	dag := createTestDagForEvaluator()
-	evaluator := NewDagEvaluatorWithPrimitives(dag)
-
-	// Set up fast results with both false
-	evaluator.fastResults[0] = false
-	evaluator.fastResults[1] = false
-
-	result := evaluator.evaluateLogicalOperationFast(LogicalOr, []NodeId{0, 1})
-	if result {
-		t.Error("Expected fast OR operation with all false to return false")
-	}
-}
-
-func TestEvaluateLogicalOperationFastNotSuccess(t *testing.T) {
-	dag := createTestDagForEvaluator()
-	evaluator := NewDagEvaluatorWithPrimitives(dag)
-
-	// Set up fast results
-	evaluator.fastResults[0] = false
-
-	result := evaluator.evaluateLogicalOperationFast(LogicalNot, []NodeId{0})
-	if !result {
-		t.Error("Expected fast NOT operation with false to return true")
-	}
-}
-
-func TestEvaluateLogicalOperationFastNotFailure(t *testing.T) {
-	dag := createTestDagForEvaluator()
-	evaluator := NewDagEvaluatorWithPrimitives(dag)
-
-	// Set up fast results
-	evaluator.fastResults[0] = true
-
-	result := evaluator.evaluateLogicalOperationFast(LogicalNot, []NodeId{0})
-	if result {
-		t.Error("Expected fast NOT operation with true to return false")
-	}
-}
-
 func TestEvaluateEmptyEvent(t *testing.T) {
 	dag := createTestDagForEvaluator()
 	evaluator := NewDagEvaluatorWithPrimitives(dag)
@@ -303,3 +221,82 @@ func TestEvaluateSimpleEvent(t *testing.T) {
 		t.Errorf("Expected no matched rules with placeholder implementation, got %d", len(result.MatchedRules))
 	}
 }
+
+func TestEvaluatePrimitiveOncePerEvent(t *testing.T) {
+	// Two rules with their own (non-deduplicated) node for primitive 0
+	dag := NewCompiledDag()
+	dag.Nodes = []DagNode{
+		*NewDagNode(0, NewPrimitiveNodeType(0)),
+		*NewDagNode(1, NewResultNodeType(1)),
+		*NewDagNode(2, NewPrimitiveNodeType(0)),
+		*NewDagNode(3, NewResultNodeType(2)),
+	}
+	dag.Nodes[1].Dependencies = []NodeId{0}
+	dag.Nodes[3].Dependencies = []NodeId{2}
+	dag.PrimitiveMap[0] = 0
+	dag.RuleResults[1] = 1
+	dag.RuleResults[2] = 3
+	dag.ExecutionOrder = []NodeId{0, 1, 2, 3}
+
+	calls := 0
+	primitives := map[uint32]*CompiledPrimitive{
+		0: {ID: 0, MatcherFunc: func(event interface{}) bool {
+			calls++
+			return event.(map[string]interface{})["EventID"] == "4624"
+		}},
+	}
+
+	evaluator := NewDagEvaluatorWithCompiledPrimitives(dag, primitives)
+	result, err := evaluator.Evaluate(map[string]interface{}{"EventID": "4624"})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 2 {
+		t.Errorf("Expected both rules to match, got %v", result.MatchedRules)
+	}
+	if calls != 1 || result.PrimitiveEvaluations != 1 {
+		t.Errorf("Expected primitive to be evaluated once, got %d calls / %d evaluations", calls, result.PrimitiveEvaluations)
+	}
+
+	// The cache is per event
+	if _, err := evaluator.Evaluate(map[string]interface{}{"EventID": "1"}); err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected primitive to be re-evaluated for a new event, got %d calls", calls)
+	}
+}
+
+func TestEvaluateAnyMatchStopsAtFirstMatch(t *testing.T) {
+	ruleset := createBatchTestRuleset()
+	primitives, err := buildPrimitiveMap(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build primitives: %v", err)
+	}
+	evaluator := NewDagEvaluatorWithCompiledPrimitives(ruleset.Dag, primitives)
+
+	// Rule 1 matches, so rule 2's NOT node is never reached
+	matched, err := evaluator.EvaluateAnyMatch(map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"})
+	if err != nil || !matched {
+		t.Fatalf("Expected a match, got %v, %v", matched, err)
+	}
+	if evaluator.nodesEvaluated != 4 || evaluator.primitiveEvaluations != 2 {
+		t.Errorf("Expected 4 nodes and 2 primitives evaluated, got %d and %d", evaluator.nodesEvaluated, evaluator.primitiveEvaluations)
+	}
+
+	// Rule 1's AND short-circuits on EventID, rule 2 matches
+	matched, err = evaluator.EvaluateAnyMatch(map[string]interface{}{"EventID": "1"})
+	if err != nil || !matched {
+		t.Fatalf("Expected a match, got %v, %v", matched, err)
+	}
+	if evaluator.primitiveEvaluations != 2 {
+		t.Errorf("Expected each primitive to be evaluated once, got %d evaluations", evaluator.primitiveEvaluations)
+	}
+
+	// Inactive rules never match
+	evaluator.WithInactiveRules([]ir.RuleID{2})
+	matched, err = evaluator.EvaluateAnyMatch(map[string]interface{}{"EventID": "1"})
+	if err != nil || matched {
+		t.Errorf("Expected no match with rule 2 inactive, got %v, %v", matched, err)
+	}
+}
@@ -0,0 +1,335 @@
+package dag
+
+import (
+	"log/slog"
+	"sort"
+)
+
+const (
+	// maxFactorRounds bounds the rounds of the factoring pass
+	maxFactorRounds = 10
+	// maxFactorOperands skips AND/OR nodes wider than this when counting
+	// shared operand pairs, which is quadratic in the width
+	maxFactorOperands = 64
+)
+
+// operandPair is two operands of an AND or OR node, first < second
+type operandPair struct {
+	op            LogicalOp
+	first, second NodeId
+}
+
+// factorSharedOperands factors operands that several AND (or OR) nodes
+// have in common into a single shared node. When many rules AND the same
+// log source filter with their own selection, the filter's conjunction is
+// then evaluated once instead of once per rule. Same-operation chains used
+// by a single parent are flattened first, so nesting from the condition
+// (`a and b and c`) does not hide shared operands.
+//
+// A set of operands is factored when it is shared by at least two nodes
+// and factoring reduces the number of operand evaluations. Operands a node
+// no longer uses are left for dead code elimination.
+func (opt *DagOptimizer) factorSharedOperands(dag *CompiledDag) (*CompiledDag, bool, error) {
+	rebuildDependents(dag)
+	changed := opt.flattenChains(dag)
+
+	for round := 0; round < maxFactorRounds; round++ {
+		if !opt.factorRound(dag) {
+			break
+		}
+		changed = true
+	}
+
+	rebuildDependents(dag)
+	return dag, changed, nil
+}
+
+// flattenChains merges AND/OR operands with the same operation into their
+// parent when the parent is their only dependent. Reports whether anything
+// was merged.
+func (opt *DagOptimizer) flattenChains(dag *CompiledDag) bool {
+	flattenedAny := false
+	index := nodeIndex(dag)
+	for i := range dag.Nodes {
+		node := &dag.Nodes[i]
+		op, factorable := factorableOp(node)
+		if !factorable {
+			continue
+		}
+
+		changed := true
+		for changed {
+			changed = false
+			var flattened []NodeId
+			for _, depId := range node.Dependencies {
+				position, exists := index[depId]
+				if !exists {
+					flattened = append(flattened, depId)
+					continue
+				}
+				dep := &dag.Nodes[position]
+				if depOp, ok := factorableOp(dep); ok && depOp == op && len(dep.Dependents) == 1 && dep.Dependents[0] == node.ID {
+					flattened = appendUnique(flattened, dep.Dependencies...)
+					// The operands now feed the parent, which may flatten
+					// them in turn
+					for _, operandId := range dep.Dependencies {
+						if operand, exists := index[operandId]; exists {
+							dependents := dag.Nodes[operand].Dependents[:0]
+							for _, dependentId := range dag.Nodes[operand].Dependents {
+								if dependentId != dep.ID {
+									dependents = append(dependents, dependentId)
+								}
+							}
+							dag.Nodes[operand].Dependents = appendUnique(dependents, node.ID)
+						}
+					}
+					dep.Dependents = nil
+					changed = true
+					flattenedAny = true
+					continue
+				}
+				flattened = appendUnique(flattened, depId)
+			}
+			node.Dependencies = flattened
+		}
+	}
+	rebuildDependents(dag)
+	return flattenedAny
+}
+
+// factorRound factors the most shared operand pairs, extended to all the
+// operands their nodes have in common. Each node is rewritten at most once
+// per round. Reports whether anything was factored.
+func (opt *DagOptimizer) factorRound(dag *CompiledDag) bool {
+	counts := make(map[operandPair]int)
+	for i := range dag.Nodes {
+		node := &dag.Nodes[i]
+		op, factorable := factorableOp(node)
+		if !factorable || !factorCandidate(node) {
+			continue
+		}
+		for a := 0; a < len(node.Dependencies); a++ {
+			for b := a + 1; b < len(node.Dependencies); b++ {
+				counts[newOperandPair(op, node.Dependencies[a], node.Dependencies[b])]++
+			}
+		}
+	}
+
+	pairs := make([]operandPair, 0, len(counts))
+	for pair, count := range counts {
+		if count >= 2 {
+			pairs = append(pairs, pair)
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i], pairs[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		if a.op != b.op {
+			return a.op < b.op
+		}
+		if a.first != b.first {
+			return a.first < b.first
+		}
+		return a.second < b.second
+	})
+
+	touched := make(map[NodeId]bool)
+	factored := false
+	for _, pair := range pairs {
+		var sharing []int
+		for i := range dag.Nodes {
+			node := &dag.Nodes[i]
+			if op, ok := factorableOp(node); ok && op == pair.op && !touched[node.ID] && factorCandidate(node) &&
+				containsNode(node.Dependencies, pair.first) && containsNode(node.Dependencies, pair.second) {
+				sharing = append(sharing, i)
+			}
+		}
+		if len(sharing) < 2 {
+			continue
+		}
+
+		common := commonOperands(dag, sharing)
+		if !opt.factorOperands(dag, pair.op, sharing, common) {
+			continue
+		}
+		for _, i := range sharing {
+			touched[dag.Nodes[i].ID] = true
+		}
+		factored = true
+	}
+	if factored {
+		rebuildDependents(dag)
+	}
+	return factored
+}
+
+// factorOperands replaces the common operands of the sharing nodes with a
+// single node computing them, reusing a sharing node whose operands are
+// exactly the common ones. Reports whether this saves operand evaluations.
+func (opt *DagOptimizer) factorOperands(dag *CompiledDag, op LogicalOp, sharing []int, common []NodeId) bool {
+	shared := -1
+	for _, i := range sharing {
+		if len(dag.Nodes[i].Dependencies) == len(common) {
+			shared = i
+			break
+		}
+	}
+
+	// Every sharing node evaluates the common operands; afterwards they are
+	// evaluated once and each node evaluates the shared node instead
+	before := len(sharing) * len(common)
+	after := len(common) + len(sharing)
+	if shared >= 0 {
+		after--
+	}
+	if after >= before {
+		return false
+	}
+
+	var sharedId NodeId
+	if shared >= 0 {
+		sharedId = dag.Nodes[shared].ID
+	} else {
+		sharedId = nextNodeId(dag)
+		node := NewDagNode(sharedId, NewLogicalNodeType(op))
+		node.Dependencies = append([]NodeId(nil), common...)
+		// The sharing nodes are addressed by position, which appending
+		// leaves intact
+		dag.Nodes = append(dag.Nodes, *node)
+	}
+
+	inCommon := make(map[NodeId]bool, len(common))
+	for _, id := range common {
+		inCommon[id] = true
+	}
+	for _, i := range sharing {
+		if i == shared {
+			continue
+		}
+		node := &dag.Nodes[i]
+		var dependencies []NodeId
+		replaced := false
+		for _, depId := range node.Dependencies {
+			if !inCommon[depId] {
+				dependencies = append(dependencies, depId)
+			} else if !replaced {
+				dependencies = append(dependencies, sharedId)
+				replaced = true
+			}
+		}
+		node.Dependencies = dependencies
+	}
+
+	opt.log().Debug("factored shared operands",
+		slog.String("operation", op.String()),
+		slog.Int("operands", len(common)),
+		slog.Int("nodes", len(sharing)))
+	return true
+}
+
+// commonOperands returns the operands all the given nodes share, in the
+// order of the first node
+func commonOperands(dag *CompiledDag, positions []int) []NodeId {
+	common := append([]NodeId(nil), dag.Nodes[positions[0]].Dependencies...)
+	for _, i := range positions[1:] {
+		var kept []NodeId
+		for _, id := range common {
+			if containsNode(dag.Nodes[i].Dependencies, id) {
+				kept = append(kept, id)
+			}
+		}
+		common = kept
+	}
+	return common
+}
+
+// factorableOp returns the operation of an AND or OR node
+func factorableOp(node *DagNode) (LogicalOp, bool) {
+	if node.NodeType.Type != "Logical" || node.NodeType.Operation == nil || node.CachedResult != nil {
+		return 0, false
+	}
+	op := *node.NodeType.Operation
+	return op, op == LogicalAnd || op == LogicalOr
+}
+
+// factorCandidate reports whether an AND/OR node takes part in factoring:
+// it is in use (nodes flattened into their parent are not) and neither
+// trivial nor too wide
+func factorCandidate(node *DagNode) bool {
+	return len(node.Dependents) > 0 && len(node.Dependencies) >= 2 && len(node.Dependencies) <= maxFactorOperands
+}
+
+func newOperandPair(op LogicalOp, a, b NodeId) operandPair {
+	if b < a {
+		a, b = b, a
+	}
+	return operandPair{op: op, first: a, second: b}
+}
+
+// rebuildDependents recomputes every node's dependents from the
+// dependencies, in node order
+func rebuildDependents(dag *CompiledDag) {
+	index := nodeIndex(dag)
+	for i := range dag.Nodes {
+		dag.Nodes[i].Dependents = nil
+	}
+	for i := range dag.Nodes {
+		for _, depId := range dag.Nodes[i].Dependencies {
+			if position, exists := index[depId]; exists {
+				dag.Nodes[position].Dependents = appendUnique(dag.Nodes[position].Dependents, dag.Nodes[i].ID)
+			}
+		}
+	}
+}
+
+// nodeIndex maps node IDs to positions in dag.Nodes
+func nodeIndex(dag *CompiledDag) map[NodeId]int {
+	index := make(map[NodeId]int, len(dag.Nodes))
+	for i := range dag.Nodes {
+		index[dag.Nodes[i].ID] = i
+	}
+	return index
+}
+
+// nextNodeId returns an ID above every node's
+func nextNodeId(dag *CompiledDag) NodeId {
+	var next NodeId
+	for i := range dag.Nodes {
+		if dag.Nodes[i].ID >= next {
+			next = dag.Nodes[i].ID + 1
+		}
+	}
+	return next
+}
+
+func containsNode(ids []NodeId, id NodeId) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+// appendUnique appends the IDs not yet in ids
+func appendUnique(ids []NodeId, more ...NodeId) []NodeId {
+	for _, id := range more {
+		if !containsNode(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// removeNode returns ids without id
+func removeNode(ids []NodeId, id NodeId) []NodeId {
+	kept := ids[:0]
+	for _, existing := range ids {
+		if existing != id {
+			kept = append(kept, existing)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,115 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+// createSharedFilterDag creates rules 1-3 as AND(AND(P0, P1), P2, P(2+rule)):
+// a selection chain every rule shares with its own selection
+func createSharedFilterDag() *CompiledDag {
+	dag := NewCompiledDag()
+	addNode := func(nodeType NodeType, dependencies ...NodeId) NodeId {
+		nodeId := NodeId(len(dag.Nodes))
+		node := NewDagNode(nodeId, nodeType)
+		node.Dependencies = dependencies
+		dag.Nodes = append(dag.Nodes, *node)
+		for _, depId := range dependencies {
+			dag.Nodes[depId].Dependents = append(dag.Nodes[depId].Dependents, nodeId)
+		}
+		dag.ExecutionOrder = append(dag.ExecutionOrder, nodeId)
+		return nodeId
+	}
+
+	for primitiveId := ir.PrimitiveID(0); primitiveId < 6; primitiveId++ {
+		dag.PrimitiveMap[primitiveId] = addNode(NewPrimitiveNodeType(primitiveId))
+	}
+	for ruleId := ir.RuleID(1); ruleId <= 3; ruleId++ {
+		chain := addNode(NewLogicalNodeType(LogicalAnd), 0, 1)
+		condition := addNode(NewLogicalNodeType(LogicalAnd), chain, 2, NodeId(2+ruleId))
+		dag.RuleResults[ruleId] = addNode(NewResultNodeType(ruleId), condition)
+	}
+	dag.ResultBufferSize = len(dag.Nodes)
+	return dag
+}
+
+func TestOptimizeFactorsSharedOperands(t *testing.T) {
+	// Without CSE the per-rule chains are only merged by factoring
+	optimizer := NewDagOptimizer().WithCSE(false).WithFactoring(true)
+	optimized, err := optimizer.Optimize(createSharedFilterDag())
+	if err != nil {
+		t.Fatalf("Optimization failed: %v", err)
+	}
+	if err := optimized.Validate(); err != nil {
+		t.Fatalf("Optimized DAG is invalid: %v", err)
+	}
+
+	// 6 primitives, one shared AND(P0, P1, P2), and per rule an AND and a
+	// result
+	if len(optimized.Nodes) != 13 {
+		t.Errorf("Expected 13 nodes, got %d", len(optimized.Nodes))
+	}
+	var shared *DagNode
+	for i := range optimized.Nodes {
+		node := &optimized.Nodes[i]
+		if node.NodeType.Type == "Logical" && len(node.Dependents) == 3 {
+			shared = node
+		}
+	}
+	if shared == nil || len(shared.Dependencies) != 3 {
+		t.Fatalf("Expected a node with three operands shared by every rule, got %+v", shared)
+	}
+	for ruleId, resultId := range optimized.RuleResults {
+		condition := optimized.GetNode(optimized.GetNode(resultId).Dependencies[0])
+		if len(condition.Dependencies) != 2 || condition.Dependencies[0] != shared.ID {
+			t.Errorf("Rule %d: expected AND(shared, own primitive), got %v", ruleId, condition.Dependencies)
+		}
+	}
+
+	// Factoring is off by default
+	unfactored, err := NewDagOptimizer().WithCSE(false).Optimize(createSharedFilterDag())
+	if err != nil {
+		t.Fatalf("Optimization failed: %v", err)
+	}
+	if len(unfactored.Nodes) != 15 {
+		t.Errorf("Expected 15 nodes without factoring, got %d", len(unfactored.Nodes))
+	}
+}
+
+func TestFactoringReusesNodesWhenProfitable(t *testing.T) {
+	dag := NewCompiledDag()
+	for i, spec := range []struct {
+		nodeType     NodeType
+		dependencies []NodeId
+	}{
+		{NewPrimitiveNodeType(0), nil},
+		{NewPrimitiveNodeType(1), nil},
+		{NewPrimitiveNodeType(2), nil},
+		{NewPrimitiveNodeType(3), nil},
+		// OR(P0, P1, P2) can reuse OR(P0, P1)
+		{NewLogicalNodeType(LogicalOr), []NodeId{0, 1, 2}},
+		{NewLogicalNodeType(LogicalOr), []NodeId{0, 1}},
+		// A new AND(P0, P1) would not save operand evaluations
+		{NewLogicalNodeType(LogicalAnd), []NodeId{0, 1, 2}},
+		{NewLogicalNodeType(LogicalAnd), []NodeId{0, 1, 3}},
+		{NewResultNodeType(1), []NodeId{4}},
+		{NewResultNodeType(2), []NodeId{5}},
+		{NewResultNodeType(3), []NodeId{6}},
+		{NewResultNodeType(4), []NodeId{7}},
+	} {
+		node := NewDagNode(NodeId(i), spec.nodeType)
+		node.Dependencies = spec.dependencies
+		dag.Nodes = append(dag.Nodes, *node)
+	}
+
+	NewDagOptimizer().WithFactoring(true).factorSharedOperands(dag)
+
+	if deps := dag.Nodes[4].Dependencies; !reflect.DeepEqual(deps, []NodeId{5, 2}) {
+		t.Errorf("Expected OR(P0, P1, P2) to reuse OR(P0, P1), got %v", deps)
+	}
+	if len(dag.Nodes) != 12 || !reflect.DeepEqual(dag.Nodes[6].Dependencies, []NodeId{0, 1, 2}) {
+		t.Errorf("Expected the AND nodes to be left alone, got %d nodes", len(dag.Nodes))
+	}
+}
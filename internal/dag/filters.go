@@ -0,0 +1,66 @@
+package dag
+
+import (
+	"path"
+	"strings"
+)
+
+// RuleFilter decides whether a rule is included in the engine
+type RuleFilter func(meta RuleMeta) bool
+
+// AllOf combines filters so that a rule must pass every filter
+func AllOf(filters ...RuleFilter) RuleFilter {
+	return func(meta RuleMeta) bool {
+		for _, filter := range filters {
+			if filter != nil && !filter(meta) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// FilterByTags accepts rules with at least one tag matching one of the
+// patterns. Patterns are case-insensitive and may use wildcards
+// (e.g. "attack.t1059.*").
+func FilterByTags(patterns ...string) RuleFilter {
+	return func(meta RuleMeta) bool {
+		for _, tag := range meta.Tags {
+			tag = strings.ToLower(tag)
+			for _, pattern := range patterns {
+				if matched, err := path.Match(strings.ToLower(pattern), tag); err == nil && matched {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// FilterByLogSource accepts rules whose log source matches the given
+// category, product and service (empty = any)
+func FilterByLogSource(category, product, service string) RuleFilter {
+	return func(meta RuleMeta) bool {
+		return logSourceFieldMatches(category, meta.LogSource.Category) &&
+			logSourceFieldMatches(product, meta.LogSource.Product) &&
+			logSourceFieldMatches(service, meta.LogSource.Service)
+	}
+}
+
+// FilterByStatus accepts rules with one of the given statuses
+// (e.g. "stable", "experimental")
+func FilterByStatus(statuses ...string) RuleFilter {
+	return func(meta RuleMeta) bool {
+		for _, status := range statuses {
+			if strings.EqualFold(status, meta.Status) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// logSourceFieldMatches compares a log source field against a filter value
+func logSourceFieldMatches(want, got string) bool {
+	return want == "" || strings.EqualFold(want, got)
+}
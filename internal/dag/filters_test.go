@@ -0,0 +1,56 @@
+package dag
+
+import "testing"
+
+func TestRuleFilters(t *testing.T) {
+	meta := RuleMeta{
+		Status:    "stable",
+		Tags:      []string{"attack.execution", "attack.T1059.001"},
+		LogSource: LogSource{Category: "process_creation", Product: "windows"},
+	}
+
+	tests := []struct {
+		name   string
+		filter RuleFilter
+		want   bool
+	}{
+		{"tag wildcard", FilterByTags("attack.t1059.*"), true},
+		{"tag exact", FilterByTags("attack.execution"), true},
+		{"tag miss", FilterByTags("attack.t1003*"), false},
+		{"logsource match", FilterByLogSource("process_creation", "Windows", ""), true},
+		{"logsource miss", FilterByLogSource("", "linux", ""), false},
+		{"status match", FilterByStatus("test", "stable"), true},
+		{"status miss", FilterByStatus("experimental"), false},
+		{"all of", AllOf(FilterByStatus("stable"), FilterByTags("attack.execution")), true},
+		{"all of miss", AllOf(FilterByStatus("stable"), FilterByTags("attack.t1003")), false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter(meta); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestDagEngineRuleFilterDeactivatesRules(t *testing.T) {
+	ruleset := createTestRuleset()
+	ruleset.Dag = createTestDag()
+	ruleset.Rules = []RuleMeta{{ID: 1, Status: "experimental", Tags: []string{"attack.t1078"}}}
+
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithPrefilter(false).
+		WithTagFilter("attack.t1078").
+		WithStatusFilter("stable").
+		BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	result, err := engine.Evaluate(map[string]interface{}{"EventID": "4624", "ProcessName": "powershell"})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 0 {
+		t.Errorf("Expected filtered rule to be inactive, got %v", result.MatchedRules)
+	}
+}
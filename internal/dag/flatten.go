@@ -0,0 +1,144 @@
+package dag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+)
+
+// EventFlattening selects how nested event fields are resolved
+type EventFlattening int
+
+const (
+	// FlattenOff resolves nested fields per primitive by walking the event
+	FlattenOff EventFlattening = iota
+	// FlattenAlways flattens every map event to dotted keys once at ingest
+	FlattenAlways
+	// FlattenAuto flattens when the ruleset field statistics favor it
+	FlattenAuto
+)
+
+var eventFlatteningNames = map[EventFlattening]string{
+	FlattenOff:    "off",
+	FlattenAlways: "always",
+	FlattenAuto:   "auto",
+}
+
+func (mode EventFlattening) String() string {
+	if name, exists := eventFlatteningNames[mode]; exists {
+		return name
+	}
+	return "off"
+}
+
+// FieldDepthStats describes the field paths read by a ruleset
+type FieldDepthStats struct {
+	// Distinct field paths, and those with more than one segment
+	Fields       int
+	NestedFields int
+
+	// Field paths with a list index segment, e.g. "process.args.0"
+	IndexedFields int
+
+	// Deepest and mean number of path segments
+	MaxDepth  int
+	MeanDepth float64
+}
+
+// ComputeFieldDepthStats computes the field depth statistics of primitives
+func ComputeFieldDepthStats(primitives []Primitive) FieldDepthStats {
+	var stats FieldDepthStats
+	seen := make(map[string]bool, len(primitives))
+	totalDepth := 0
+	for _, primitive := range primitives {
+		if seen[primitive.Field] {
+			continue
+		}
+		seen[primitive.Field] = true
+
+		parts := strings.Split(primitive.Field, ".")
+		depth := len(parts)
+		stats.Fields++
+		if depth > 1 {
+			stats.NestedFields++
+		}
+		if hasIndexSegment(parts[1:]) {
+			stats.IndexedFields++
+		}
+		stats.MaxDepth = max(stats.MaxDepth, depth)
+		totalDepth += depth
+	}
+	if stats.Fields > 0 {
+		stats.MeanDepth = float64(totalDepth) / float64(stats.Fields)
+	}
+	return stats
+}
+
+// hasIndexSegment reports whether a path segment is a list index
+func hasIndexSegment(parts []string) bool {
+	for _, part := range parts {
+		if _, err := strconv.Atoi(part); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// favorsFlattening reports whether a ruleset with these statistics should
+// flatten events at ingest. BenchmarkEventFlattening shows flattening is
+// slower than nested extraction at every measured field count and depth
+// (building the flat map costs more than the walks it saves), so auto mode
+// only flattens when rules address list elements, which nested extraction
+// cannot resolve.
+func (stats FieldDepthStats) favorsFlattening() bool {
+	return stats.IndexedFields > 0
+}
+
+// resolveFlattening decides whether the engine flattens events
+func resolveFlattening(mode EventFlattening, stats FieldDepthStats) bool {
+	switch mode {
+	case FlattenAlways:
+		return true
+	case FlattenAuto:
+		return stats.favorsFlattening()
+	default:
+		return false
+	}
+}
+
+// prepareEvent enriches map events with the configured enrichers and
+// flattens them when the engine flattens at ingest
+func (e *DagEngine) prepareEvent(event interface{}) (interface{}, error) {
+	eventMap, ok := event.(map[string]interface{})
+	if !ok {
+		return event, nil
+	}
+	if len(e.config.Enrichers) > 0 {
+		enriched, err := e.enrichEvent(eventMap)
+		if err != nil {
+			return nil, err
+		}
+		eventMap = enriched
+	}
+	if e.flattenEvents {
+		return matcher.FlattenEvent(eventMap), nil
+	}
+	return eventMap, nil
+}
+
+// prepareEvents prepares a batch of events like prepareEvent
+func (e *DagEngine) prepareEvents(events []interface{}) ([]interface{}, error) {
+	if !e.flattenEvents && len(e.config.Enrichers) == 0 {
+		return events, nil
+	}
+	prepared := make([]interface{}, len(events))
+	for i, event := range events {
+		var err error
+		if prepared[i], err = e.prepareEvent(event); err != nil {
+			return nil, fmt.Errorf("event at index %d: %w", i, err)
+		}
+	}
+	return prepared, nil
+}
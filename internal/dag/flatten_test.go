@@ -0,0 +1,207 @@
+package dag
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+// createFieldRuleset creates one single-primitive rule per field, each
+// matching the value "match"
+func createFieldRuleset(fields []string) *CompiledRuleset {
+	dag := NewCompiledDag()
+	primitives := make([]Primitive, len(fields))
+	for i, field := range fields {
+		primitives[i] = Primitive{ID: uint32(i), Field: field, MatchType: "equals", Values: []string{"match"}}
+
+		primitiveNode := NewDagNode(NodeId(2*i), NewPrimitiveNodeType(ir.PrimitiveID(i)))
+		primitiveNode.Dependents = []NodeId{NodeId(2*i + 1)}
+		resultNode := NewDagNode(NodeId(2*i+1), NewResultNodeType(ir.RuleID(i)))
+		resultNode.Dependencies = []NodeId{NodeId(2 * i)}
+		dag.Nodes = append(dag.Nodes, *primitiveNode, *resultNode)
+
+		dag.PrimitiveMap[ir.PrimitiveID(i)] = NodeId(2 * i)
+		dag.RuleResults[ir.RuleID(i)] = NodeId(2*i + 1)
+		dag.ExecutionOrder = append(dag.ExecutionOrder, NodeId(2*i), NodeId(2*i+1))
+	}
+	return &CompiledRuleset{Primitives: primitives, Dag: dag}
+}
+
+// nestedFields returns count field paths of the given depth, e.g.
+// "f0.level1.level2" for depth 3
+func nestedFields(count, depth int) []string {
+	fields := make([]string, count)
+	for i := range fields {
+		parts := []string{fmt.Sprintf("f%d", i)}
+		for level := 1; level < depth; level++ {
+			parts = append(parts, fmt.Sprintf("level%d", level))
+		}
+		fields[i] = strings.Join(parts, ".")
+	}
+	return fields
+}
+
+// nestedEvent builds an event holding value at every field path, plus
+// unrelated fields
+func nestedEvent(fields []string, value string) map[string]interface{} {
+	event := map[string]interface{}{}
+	for i := 0; i < 20; i++ {
+		event[fmt.Sprintf("other%d", i)] = "noise"
+	}
+	for _, field := range fields {
+		parts := strings.Split(field, ".")
+		current := event
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := current[part].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				current[part] = next
+			}
+			current = next
+		}
+		current[parts[len(parts)-1]] = value
+	}
+	return event
+}
+
+func TestComputeFieldDepthStats(t *testing.T) {
+	stats := ComputeFieldDepthStats([]Primitive{
+		{Field: "EventID"},
+		{Field: "process.name"},
+		{Field: "process.name"},
+		{Field: "a.b.c.d"},
+		{Field: "process.args.0"},
+	})
+	if stats.Fields != 4 || stats.NestedFields != 3 || stats.IndexedFields != 1 || stats.MaxDepth != 4 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if stats.MeanDepth != 2.5 {
+		t.Errorf("Expected mean depth 2.5, got %f", stats.MeanDepth)
+	}
+}
+
+func TestResolveFlattening(t *testing.T) {
+	flat := FieldDepthStats{Fields: 2, NestedFields: 0, MaxDepth: 1}
+	shallow := FieldDepthStats{Fields: 3, NestedFields: 2, MaxDepth: 2}
+	deep := FieldDepthStats{Fields: 64, NestedFields: 64, MaxDepth: 6}
+	indexed := FieldDepthStats{Fields: 1, NestedFields: 1, IndexedFields: 1, MaxDepth: 3}
+
+	tests := []struct {
+		mode     EventFlattening
+		stats    FieldDepthStats
+		expected bool
+	}{
+		{FlattenOff, indexed, false},
+		{FlattenAlways, flat, true},
+		{FlattenAuto, flat, false},
+		{FlattenAuto, shallow, false},
+		{FlattenAuto, deep, false},
+		{FlattenAuto, indexed, true},
+	}
+	for _, tt := range tests {
+		if flatten := resolveFlattening(tt.mode, tt.stats); flatten != tt.expected {
+			t.Errorf("%s with %+v: expected %v, got %v", tt.mode, tt.stats, tt.expected, flatten)
+		}
+	}
+}
+
+func TestDagEngineEventFlattening(t *testing.T) {
+	fields := []string{"EventID", "process.name", "a.b.c.d"}
+	indexedFields := append(fields[:len(fields):len(fields)], "process.args.1")
+	event := map[string]interface{}{
+		"EventID": "match",
+		"process": map[string]interface{}{
+			"name": "match",
+			"args": []interface{}{"x", "match"},
+		},
+		"a": map[string]interface{}{"b": map[string]interface{}{"c": map[string]interface{}{"d": "match"}}},
+	}
+
+	tests := []struct {
+		mode    EventFlattening
+		fields  []string
+		flatten bool
+	}{
+		{FlattenOff, fields, false},
+		{FlattenAlways, fields, true},
+		{FlattenAlways, indexedFields, true},
+		{FlattenAuto, fields, false},
+		{FlattenAuto, indexedFields, true},
+	}
+	for _, tt := range tests {
+		mode := tt.mode
+		engine, err := NewDagEngineBuilder().
+			WithOptimization(false).
+			WithPrefilter(false).
+			WithParallelProcessing(true).
+			WithEventFlattening(mode).
+			BuildFromRuleset(createFieldRuleset(tt.fields))
+		if err != nil {
+			t.Fatalf("%s: failed to build engine: %v", mode, err)
+		}
+		if engine.Stats().EventFlattening != tt.flatten {
+			t.Errorf("%s with %v: expected flattening %v", mode, tt.fields, tt.flatten)
+		}
+
+		expected := len(tt.fields)
+		result, err := engine.Evaluate(event)
+		if err != nil {
+			t.Fatalf("%s: evaluation failed: %v", mode, err)
+		}
+		if len(result.MatchedRules) != expected {
+			t.Errorf("%s: expected %d matches, got %v", mode, expected, result.MatchedRules)
+		}
+
+		parallel, err := engine.EvaluateParallel(event)
+		if err != nil {
+			t.Fatalf("%s: parallel evaluation failed: %v", mode, err)
+		}
+		if len(parallel.MatchedRules) != expected {
+			t.Errorf("%s: expected %d parallel matches, got %v", mode, expected, parallel.MatchedRules)
+		}
+
+		results, err := engine.EvaluateBatch([]interface{}{event, event})
+		if err != nil {
+			t.Fatalf("%s: batch evaluation failed: %v", mode, err)
+		}
+		for _, batchResult := range results {
+			if len(batchResult.MatchedRules) != expected {
+				t.Errorf("%s: expected %d batch matches, got %v", mode, expected, batchResult.MatchedRules)
+			}
+		}
+	}
+}
+
+// BenchmarkEventFlattening compares nested extraction with flattening at
+// ingest across ruleset shapes; FlattenAuto's policy comes from it
+func BenchmarkEventFlattening(b *testing.B) {
+	shapes := []struct{ fields, depth int }{
+		{16, 2}, {64, 2}, {256, 2}, {16, 4}, {64, 4}, {256, 4}, {64, 6},
+	}
+	for _, shape := range shapes {
+		fields := nestedFields(shape.fields, shape.depth)
+		event := nestedEvent(fields, "match")
+		for _, mode := range []EventFlattening{FlattenOff, FlattenAlways} {
+			name := fmt.Sprintf("fields=%d/depth=%d/%s", shape.fields, shape.depth, mode)
+			b.Run(name, func(b *testing.B) {
+				engine, err := NewDagEngineBuilder().
+					WithOptimization(false).
+					WithPrefilter(false).
+					WithEventFlattening(mode).
+					BuildFromRuleset(createFieldRuleset(fields))
+				if err != nil {
+					b.Fatalf("Failed to build engine: %v", err)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := engine.Evaluate(event); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
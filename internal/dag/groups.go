@@ -0,0 +1,217 @@
+package dag
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+)
+
+// RuleGroupIndex groups rules by the literal anchor words they require (see
+// RawPrefilter). A rule whose strongest requirement is one of a few words
+// joins the group of each word; the rules of a group are only evaluated
+// for events with a value containing the word. Rules that require no word
+// are evaluated for every event.
+//
+// The index keeps per-event scratch space, so it is only used under the
+// engine lock.
+type RuleGroupIndex struct {
+	// Rules in rule ID order, and the positions of each anchor's rules in it
+	rules     []ruleResultNode
+	groups    map[string][]int
+	ungrouped []int
+
+	// Per-event scratch: rules selected so far and the candidate list
+	selected   []bool
+	candidates []ruleResultNode
+	word       []byte
+
+	eventsGrouped atomic.Uint64
+	rulesSkipped  atomic.Uint64
+}
+
+// RuleGroupStats describes the rule grouping of an engine
+type RuleGroupStats struct {
+	// Rules of each anchor word, by rule ID
+	Groups map[string][]ir.RuleID
+
+	// Rules without an anchor, evaluated for every event
+	Ungrouped []ir.RuleID
+
+	// Most rules in a single group
+	LargestGroup int
+
+	// Events evaluated by group, and the rule evaluations skipped for them
+	EventsGrouped uint64
+	RulesSkipped  uint64
+}
+
+// newRuleGroupIndex groups the rules of a DAG by their anchor words
+func newRuleGroupIndex(dag *CompiledDag, primitives []Primitive) *RuleGroupIndex {
+	builder := newAnchorBuilder(dag, primitives)
+	index := &RuleGroupIndex{groups: make(map[string][]int)}
+	for _, ruleId := range sortedRuleIDs(dag.RuleResults) {
+		position := len(index.rules)
+		index.rules = append(index.rules, ruleResultNode{ruleId: ruleId, nodeId: dag.RuleResults[ruleId]})
+
+		anchors := builder.nodeAnchors(dag.RuleResults[ruleId])
+		if anchors.unconstrained {
+			index.ungrouped = append(index.ungrouped, position)
+			continue
+		}
+		for _, word := range anchors.words {
+			group := index.groups[word]
+			// A rule may require the same word through several operands
+			if len(group) == 0 || group[len(group)-1] != position {
+				index.groups[word] = append(group, position)
+			}
+		}
+	}
+	index.selected = make([]bool, len(index.rules))
+	return index
+}
+
+// candidatesFor returns the rules an event may match in rule ID order: the
+// ungrouped rules and the groups of the words in the event's values.
+// Returns false when the event's words cannot be read (non-map events and
+// values with non-ASCII characters), and all rules must be evaluated.
+func (index *RuleGroupIndex) candidatesFor(event interface{}) ([]ruleResultNode, bool) {
+	var eventMap map[string]interface{}
+	switch e := event.(type) {
+	case map[string]interface{}:
+		eventMap = e
+	case matcher.FlatEvent:
+		// Flattened events hold the same leaf values under dotted keys
+		eventMap = e
+	default:
+		return nil, false
+	}
+
+	clear(index.selected)
+	for _, position := range index.ungrouped {
+		index.selected[position] = true
+	}
+	if !index.selectValue(eventMap) {
+		return nil, false
+	}
+
+	index.candidates = index.candidates[:0]
+	for position, selected := range index.selected {
+		if selected {
+			index.candidates = append(index.candidates, index.rules[position])
+		}
+	}
+	index.eventsGrouped.Add(1)
+	index.rulesSkipped.Add(uint64(len(index.rules) - len(index.candidates)))
+	return index.candidates, true
+}
+
+// selectValue selects the groups of the words of an event value and the
+// values nested in it
+func (index *RuleGroupIndex) selectValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return index.selectWords(v)
+	case map[string]interface{}:
+		for _, nested := range v {
+			if !index.selectValue(nested) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		for _, nested := range v {
+			if !index.selectValue(nested) {
+				return false
+			}
+		}
+		return true
+	case []string:
+		for _, nested := range v {
+			if !index.selectWords(nested) {
+				return false
+			}
+		}
+		return true
+	case bool:
+		return index.selectWords(strconv.FormatBool(v))
+	default:
+		return index.selectWords(fmt.Sprint(v))
+	}
+}
+
+// selectWords selects the groups of the words of a string, splitting and
+// lowercasing like the raw prefilter
+func (index *RuleGroupIndex) selectWords(s string) bool {
+	index.word = index.word[:0]
+	for i := 0; i <= len(s); i++ {
+		c := byte(' ')
+		if i < len(s) {
+			c = s[i]
+		}
+		if c >= 0x80 {
+			return false
+		}
+		if isWordByte(c) {
+			index.word = append(index.word, lowerASCII(c))
+			continue
+		}
+		if len(index.word) > 0 {
+			for _, position := range index.groups[string(index.word)] {
+				index.selected[position] = true
+			}
+			index.word = index.word[:0]
+		}
+	}
+	return true
+}
+
+// Stats returns the grouping and how many rule evaluations it skipped
+func (index *RuleGroupIndex) Stats() *RuleGroupStats {
+	stats := &RuleGroupStats{
+		Groups:        make(map[string][]ir.RuleID, len(index.groups)),
+		Ungrouped:     make([]ir.RuleID, 0, len(index.ungrouped)),
+		EventsGrouped: index.eventsGrouped.Load(),
+		RulesSkipped:  index.rulesSkipped.Load(),
+	}
+	for word, positions := range index.groups {
+		rules := make([]ir.RuleID, len(positions))
+		for i, position := range positions {
+			rules[i] = index.rules[position].ruleId
+		}
+		stats.Groups[word] = rules
+		stats.LargestGroup = max(stats.LargestGroup, len(rules))
+	}
+	for _, position := range index.ungrouped {
+		stats.Ungrouped = append(stats.Ungrouped, index.rules[position].ruleId)
+	}
+	return stats
+}
+
+func (index *RuleGroupIndex) memoryBytes() int {
+	size := len(index.rules)*(int(unsafe.Sizeof(ruleResultNode{}))+1) + len(index.ungrouped)*8
+	for word, positions := range index.groups {
+		size += len(word) + len(positions)*8 + mapEntryOverhead
+	}
+	return size
+}
+
+// evaluateGrouped evaluates an event with the DAG evaluator, only
+// evaluating the candidate rules of the event's groups when the engine
+// groups rules
+func (e *DagEngine) evaluateGrouped(event interface{}, result *DagEvaluationResult) error {
+	if e.ruleGroups == nil {
+		return e.evaluator.EvaluateInto(event, result)
+	}
+	candidates, ok := e.ruleGroups.candidatesFor(event)
+	if !ok {
+		return e.evaluator.EvaluateInto(event, result)
+	}
+
+	return e.evaluator.evaluateInOrderInto(event, candidates, func([]ir.RuleID) bool { return false }, result)
+}
@@ -0,0 +1,155 @@
+package dag
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+func TestRuleGroupIndex(t *testing.T) {
+	ruleset := createRawPrefilterRuleset()
+	stats := newRuleGroupIndex(ruleset.Dag, ruleset.Primitives).Stats()
+
+	expected := map[string][]ir.RuleID{"enc": {1}, "mimikatz": {2}, "lsass": {2}}
+	if !reflect.DeepEqual(stats.Groups, expected) {
+		t.Errorf("Expected groups %v, got %v", expected, stats.Groups)
+	}
+	if len(stats.Ungrouped) != 0 || stats.LargestGroup != 1 {
+		t.Errorf("Unexpected grouping: %+v", stats)
+	}
+
+	// A regular expression anchors nothing, so rule 2 is evaluated for
+	// every event
+	ruleset.Primitives[3].MatchType = "regex"
+	stats = newRuleGroupIndex(ruleset.Dag, ruleset.Primitives).Stats()
+	if len(stats.Groups) != 1 || !reflect.DeepEqual(stats.Ungrouped, []ir.RuleID{2}) {
+		t.Errorf("Expected rule 2 to be ungrouped, got %+v", stats)
+	}
+}
+
+func TestDagEngineRuleGrouping(t *testing.T) {
+	grouped, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithRuleGrouping(true).
+		BuildFromRuleset(createRawPrefilterRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	ungrouped, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		BuildFromRuleset(createRawPrefilterRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	events := []map[string]interface{}{
+		{"EventID": 4624, "CommandLine": "powershell -enc SQBFAFgA"},
+		{"EventID": "4624", "CommandLine": "powershell -ENC SQBFAFgA", "Image": "lsass.exe"},
+		{"Image": `C:\Tools\mimikatz.exe`, "Details": map[string]interface{}{"Tags": []interface{}{"x", 1}}},
+		{"EventID": 4624, "CommandLine": "notepad.exe"},
+		// Non-ASCII values are evaluated against every rule
+		{"Image": "lsass.exe", "User": "Jürgen"},
+	}
+	for _, event := range events {
+		expected, err := ungrouped.Evaluate(event)
+		if err != nil {
+			t.Fatalf("Evaluation failed: %v", err)
+		}
+		result, err := grouped.Evaluate(event)
+		if err != nil {
+			t.Fatalf("Grouped evaluation failed: %v", err)
+		}
+		sort.Slice(expected.MatchedRules, func(i, j int) bool { return expected.MatchedRules[i] < expected.MatchedRules[j] })
+		if len(result.MatchedRules) != len(expected.MatchedRules) ||
+			(len(result.MatchedRules) > 0 && !reflect.DeepEqual(result.MatchedRules, expected.MatchedRules)) {
+			t.Errorf("Event %v: expected %v, grouped evaluation matched %v", event, expected.MatchedRules, result.MatchedRules)
+		}
+	}
+
+	stats := grouped.GetStatistics().RuleGroups
+	if stats == nil {
+		t.Fatal("Expected rule group statistics")
+	}
+	// The first four events skip 1, 0, 1 and 2 rules
+	if stats.EventsGrouped != 4 || stats.RulesSkipped != 4 {
+		t.Errorf("Expected 4 grouped events skipping 4 rules, got %d and %d", stats.EventsGrouped, stats.RulesSkipped)
+	}
+	if ungrouped.GetStatistics().RuleGroups != nil {
+		t.Error("Expected no rule groups by default")
+	}
+}
+
+func TestDagEngineRuleGroupingFlattened(t *testing.T) {
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithRuleGrouping(true).
+		WithEventFlattening(FlattenAlways).
+		BuildFromRuleset(createRawPrefilterRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	result, err := engine.Evaluate(map[string]interface{}{"EventID": 4624, "CommandLine": "powershell -enc SQBFAFgA"})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.MatchedRules, []ir.RuleID{1}) {
+		t.Errorf("Expected rule 1 to match, got %v", result.MatchedRules)
+	}
+	// Flattened events are grouped like map events
+	if stats := engine.GetStatistics().RuleGroups; stats == nil || stats.EventsGrouped != 1 || stats.RulesSkipped != 1 {
+		t.Errorf("Expected the flattened event to be grouped, got %+v", stats)
+	}
+}
+
+func TestDagEngineRuleGroupingMatchDetails(t *testing.T) {
+	// Rule 2 is "Image|endswith: \mimikatz.exe or Image|contains: mimikatz"
+	newRuleset := func() *CompiledRuleset {
+		ruleset := createRawPrefilterRuleset()
+		ruleset.Primitives[3] = Primitive{ID: 3, Field: "Image", MatchType: "contains", Values: []string{"mimikatz"}}
+		ruleset.Dag.RuleSelections[2] = map[string][]ir.PrimitiveID{"sel1": {2}, "sel2": {3}}
+		return ruleset
+	}
+	grouped, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithRuleGrouping(true).
+		WithMatchDetails(true).
+		BuildFromRuleset(newRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	ungrouped, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithMatchDetails(true).
+		BuildFromRuleset(newRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	event := map[string]interface{}{"Image": `C:\Tools\mimikatz.exe`}
+	expected, err := ungrouped.Evaluate(event)
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	var result DagEvaluationResult
+	if err := grouped.EvaluateInto(event, &result); err != nil {
+		t.Fatalf("Grouped evaluation failed: %v", err)
+	}
+	if len(result.RuleMatches) != 1 || !reflect.DeepEqual(result.RuleMatches[0].Selections, []string{"sel1", "sel2"}) {
+		t.Fatalf("Expected both selections of rule 2, got %+v", result.RuleMatches)
+	}
+	if !reflect.DeepEqual(result.RuleMatches, expected.RuleMatches) {
+		t.Errorf("Expected grouped details %+v, got %+v", expected.RuleMatches, result.RuleMatches)
+	}
+
+	// The caller's result is reused
+	matched := &result.MatchedRules[0]
+	if err := grouped.EvaluateInto(event, &result); err != nil {
+		t.Fatalf("Grouped evaluation failed: %v", err)
+	}
+	if &result.MatchedRules[0] != matched {
+		t.Error("Expected the matched rules to reuse the result's slice")
+	}
+}
@@ -0,0 +1,199 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+)
+
+// TreeInterpreter evaluates each rule by walking its condition tree from the
+// rule's result node. Nothing is cached or shared between rules: a node
+// reachable from several rules or branches is evaluated every time it is
+// reached. It trades speed for obviousness and is meant as a reference for
+// the optimized backends.
+type TreeInterpreter struct {
+	dag        *CompiledDag
+	primitives map[uint32]*CompiledPrimitive
+	ruleIds    []ir.RuleID
+	inactive   map[ir.RuleID]bool
+
+	captureFields bool
+	ruleFields    map[ir.RuleID][]RuleField
+	eventTimeout  time.Duration
+	clock         clock.Clock
+	foldFieldCase bool
+
+	primitiveErrors *primitiveErrors
+}
+
+// NewTreeInterpreter creates an interpreter over the DAG's rules
+func NewTreeInterpreter(dag *CompiledDag, primitives map[uint32]*CompiledPrimitive) *TreeInterpreter {
+	ruleIds := make([]ir.RuleID, 0, len(dag.RuleResults))
+	for ruleId := range dag.RuleResults {
+		ruleIds = append(ruleIds, ruleId)
+	}
+	sort.Slice(ruleIds, func(i, j int) bool { return ruleIds[i] < ruleIds[j] })
+
+	return &TreeInterpreter{
+		dag:        dag,
+		primitives: primitives,
+		ruleIds:    ruleIds,
+	}
+}
+
+// withOptions applies engine evaluation settings to the interpreter. Match
+// details are not supported; rule field capture is.
+func (interp *TreeInterpreter) withOptions(options evaluatorOptions, ruleFields map[ir.RuleID][]RuleField) *TreeInterpreter {
+	interp.inactive = inactiveRuleSet(options.inactiveRules)
+	interp.captureFields = options.captureFields
+	interp.ruleFields = ruleFields
+	interp.eventTimeout = options.eventTimeout
+	interp.clock = options.clock
+	interp.foldFieldCase = options.foldFieldCase
+	interp.primitiveErrors = options.primitiveErrors
+	return interp
+}
+
+// Backend identifies the interpreter backend
+func (interp *TreeInterpreter) Backend() Backend {
+	return BackendInterpreter
+}
+
+// Evaluate walks every active rule's condition against an event. Matched
+// rules are reported in rule ID order.
+func (interp *TreeInterpreter) Evaluate(event interface{}) (*DagEvaluationResult, error) {
+	if !matcher.IsSupportedEvent(event) {
+		return nil, matcher.ErrUnsupportedEvent
+	}
+
+	eventCtx := newEventContext(event, interp.eventTimeout, interp.clock, interp.foldFieldCase)
+	result := NewDagEvaluationResult()
+	for _, ruleId := range interp.ruleIds {
+		if interp.inactive[ruleId] {
+			continue
+		}
+		matched, err := interp.evaluateNode(interp.dag.RuleResults[ruleId], eventCtx, event, result, make(map[NodeId]bool))
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			result.MatchedRules = append(result.MatchedRules, ruleId)
+		}
+	}
+
+	if interp.captureFields && len(result.MatchedRules) > 0 {
+		result.RuleMatches = make([]RuleMatch, 0, len(result.MatchedRules))
+		for _, ruleId := range result.MatchedRules {
+			result.RuleMatches = append(result.RuleMatches, RuleMatch{RuleID: ruleId, Fields: extractRuleFields(eventCtx, interp.ruleFields[ruleId])})
+		}
+	}
+	result.PrimitiveErrors = eventCtx.RecoveredErrors()
+	return result, nil
+}
+
+// EvaluateAnyMatch reports whether any active rule matches an event,
+// stopping at the first rule that matches
+func (interp *TreeInterpreter) EvaluateAnyMatch(event interface{}) (bool, error) {
+	if !matcher.IsSupportedEvent(event) {
+		return false, matcher.ErrUnsupportedEvent
+	}
+
+	eventCtx := newEventContext(event, interp.eventTimeout, interp.clock, interp.foldFieldCase)
+	result := NewDagEvaluationResult()
+	for _, ruleId := range interp.ruleIds {
+		if interp.inactive[ruleId] {
+			continue
+		}
+		matched, err := interp.evaluateNode(interp.dag.RuleResults[ruleId], eventCtx, event, result, make(map[NodeId]bool))
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+// evaluateNode evaluates a node and its dependencies recursively, with the
+// DAG evaluator's semantics for each node type
+func (interp *TreeInterpreter) evaluateNode(
+	nodeId NodeId,
+	eventCtx *matcher.EventContext,
+	event interface{},
+	result *DagEvaluationResult,
+	visiting map[NodeId]bool,
+) (bool, error) {
+	node := interp.dag.GetNode(nodeId)
+	if node == nil {
+		return false, fmt.Errorf("node not found: %d", nodeId)
+	}
+	if visiting[nodeId] {
+		return false, fmt.Errorf("cycle at node %d", nodeId)
+	}
+	visiting[nodeId] = true
+	defer delete(visiting, nodeId)
+	result.NodesEvaluated++
+
+	dependencies := interp.dag.DependenciesOf(nodeId)
+	switch node.NodeType.Type {
+	case "Primitive":
+		if node.NodeType.PrimitiveId == nil {
+			return false, nil
+		}
+		primitiveId := *node.NodeType.PrimitiveId
+		primitive, exists := interp.primitives[uint32(primitiveId)]
+		if !exists || primitive == nil {
+			return false, nil
+		}
+		result.PrimitiveEvaluations++
+		return matchPrimitive(primitiveId, primitive, eventCtx, event, interp.primitiveErrors)
+
+	case "Logical":
+		if node.NodeType.Operation == nil {
+			return false, nil
+		}
+		switch *node.NodeType.Operation {
+		case LogicalAnd:
+			if len(dependencies) == 0 {
+				return false, nil
+			}
+			for _, depId := range dependencies {
+				matched, err := interp.evaluateNode(depId, eventCtx, event, result, visiting)
+				if err != nil || !matched {
+					return false, err
+				}
+			}
+			return true, nil
+		case LogicalOr:
+			for _, depId := range dependencies {
+				matched, err := interp.evaluateNode(depId, eventCtx, event, result, visiting)
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+			return false, nil
+		case LogicalNot:
+			if len(dependencies) != 1 {
+				return false, nil
+			}
+			matched, err := interp.evaluateNode(dependencies[0], eventCtx, event, result, visiting)
+			return !matched && err == nil, err
+		default:
+			return false, nil
+		}
+
+	case "Result":
+		if len(dependencies) != 1 {
+			return false, nil
+		}
+		return interp.evaluateNode(dependencies[0], eventCtx, event, result, visiting)
+
+	case "Prefilter":
+		return true, nil
+
+	default:
+		return false, nil
+	}
+}
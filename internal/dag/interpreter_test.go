@@ -0,0 +1,49 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+func TestTreeInterpreterEvaluate(t *testing.T) {
+	ruleset := createBatchTestRuleset()
+	primitives, err := buildPrimitiveMap(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build primitives: %v", err)
+	}
+	interp := NewTreeInterpreter(ruleset.Dag, primitives)
+	if interp.Backend() != BackendInterpreter {
+		t.Errorf("Expected interpreter backend, got %s", interp.Backend())
+	}
+
+	tests := []struct {
+		name     string
+		event    map[string]interface{}
+		expected []ir.RuleID
+	}{
+		{"both primitives", map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"}, []ir.RuleID{1}},
+		{"event id only", map[string]interface{}{"EventID": "4624"}, []ir.RuleID{2}},
+		{"neither", map[string]interface{}{"EventID": "1"}, []ir.RuleID{2}},
+	}
+	for _, tt := range tests {
+		result, err := interp.Evaluate(tt.event)
+		if err != nil {
+			t.Fatalf("%s: evaluation failed: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(result.MatchedRules, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, result.MatchedRules)
+		}
+	}
+
+	interp.withOptions(evaluatorOptions{inactiveRules: []ir.RuleID{2}}, nil)
+	result, err := interp.Evaluate(map[string]interface{}{"EventID": "1"})
+	if err != nil || len(result.MatchedRules) != 0 {
+		t.Errorf("Expected inactive rule 2 not to match, got %v (%v)", result, err)
+	}
+
+	if _, err := interp.Evaluate("not an event"); err == nil {
+		t.Error("Expected an unsupported event to be rejected")
+	}
+}
@@ -0,0 +1,279 @@
+package dag
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/logging"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// IncrementalCompiler is a Compiler that can also parse a rule's metadata
+// without compiling it, and compile rules one at a time into a growing
+// ruleset, as lazy engines need
+type IncrementalCompiler interface {
+	Compiler
+	ParseRuleMeta(ruleYaml string) (RuleMeta, error)
+	CompileRule(ruleYaml string) (ir.RuleID, error)
+	Build() (*CompiledRuleset, error)
+}
+
+// RuleCompileState is where a rule of a lazy engine is in its compilation
+type RuleCompileState int
+
+const (
+	// RulePending: the rule's log source has not been seen yet
+	RulePending RuleCompileState = iota
+	// RuleCompiled: the rule is compiled and evaluated
+	RuleCompiled
+	// RuleFailed: the rule could not be parsed or compiled, or the engine
+	// could not be rebuilt with it. Rebuilding is retried when a log source
+	// is next seen.
+	RuleFailed
+	// RuleSkipped: the rule is excluded by the configured rule filter and
+	// is never compiled
+	RuleSkipped
+)
+
+var ruleCompileStateNames = map[RuleCompileState]string{
+	RulePending:  "pending",
+	RuleCompiled: "compiled",
+	RuleFailed:   "failed",
+	RuleSkipped:  "skipped",
+}
+
+func (s RuleCompileState) String() string {
+	if name, ok := ruleCompileStateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("RuleCompileState(%d)", int(s))
+}
+
+// LazyRule is a rule of a lazy engine and its compile state
+type LazyRule struct {
+	// Position of the rule in the loaded rules
+	Index int
+
+	// Metadata parsed when the rule was loaded. Meta.ID is the rule's ID
+	// once it is compiled.
+	Meta  RuleMeta
+	State RuleCompileState
+
+	// Parse or compile error of failed rules
+	Err error
+}
+
+// LazyEngine compiles rules only once events of their log source are seen,
+// for tools loading thousands of rules but evaluating a few log sources.
+// Rule metadata is parsed when the rules are loaded; when Evaluate first
+// sees a log source, the pending rules of that log source are compiled and
+// the engine is rebuilt with every rule compiled so far.
+//
+// Events are evaluated against all compiled rules, like an eager engine
+// evaluates all rules, so rules of a log source seen earlier can match
+// events of another. Rules of log sources never seen do not match.
+type LazyEngine struct {
+	mu       sync.Mutex
+	compiler IncrementalCompiler
+	config   DagEngineConfig
+	cache    *PrimitiveCache
+	logger   *slog.Logger
+
+	ruleYamls []string
+	rules     []LazyRule
+	seen      map[LogSource]bool
+
+	// Engine of the compiled rules (nil before any rule is compiled) and
+	// the primitives it holds in the cache
+	engine     *DagEngine
+	primitives []Primitive
+
+	// Indexes of the rules compiled but not yet in the engine, after a
+	// failed rebuild
+	unbuilt []int
+}
+
+// NewLazyEngine parses the metadata of rules for lazy compilation with the
+// compiler. Rules that cannot be parsed are marked failed rather than
+// failing the engine.
+func NewLazyEngine(compiler IncrementalCompiler, ruleYamls []string, config DagEngineConfig) *LazyEngine {
+	if registryCompiler, ok := compiler.(MatcherRegistryCompiler); ok && config.MatcherRegistry != nil {
+		registryCompiler.SetMatcherRegistry(config.MatcherRegistry)
+	}
+	cache := config.PrimitiveCache
+	if cache == nil {
+		cache = NewPrimitiveCacheWithRegistry(config.MatcherRegistry)
+	}
+	config.PrimitiveCache = cache
+
+	lazy := &LazyEngine{
+		compiler:  compiler,
+		config:    config,
+		cache:     cache,
+		logger:    logging.For(config.Logger, logging.ComponentEngine),
+		ruleYamls: append([]string(nil), ruleYamls...),
+		rules:     make([]LazyRule, len(ruleYamls)),
+		seen:      make(map[LogSource]bool),
+	}
+	for i, ruleYaml := range ruleYamls {
+		meta, err := compiler.ParseRuleMeta(ruleYaml)
+		lazy.rules[i] = LazyRule{Index: i, Meta: meta}
+		switch {
+		case err != nil:
+			lazy.rules[i].State = RuleFailed
+			lazy.rules[i].Err = err
+		case config.RuleFilter != nil && !config.RuleFilter(meta):
+			lazy.rules[i].State = RuleSkipped
+		}
+	}
+	return lazy
+}
+
+// BuildLazy creates a lazy engine of SIGMA rule YAML strings, see
+// LazyEngine. The builder's compiler must be an IncrementalCompiler.
+func (b *DagEngineBuilder) BuildLazy(ruleYamls []string) (*LazyEngine, error) {
+	if err := b.loadExtensions(); err != nil {
+		return nil, err
+	}
+	compiler, ok := b.compiler.(IncrementalCompiler)
+	if !ok {
+		return nil, errors.NewConfigError("lazy compilation requires an incremental compiler")
+	}
+	return NewLazyEngine(compiler, ruleYamls, b.config), nil
+}
+
+// Evaluate evaluates an event of a log source, first compiling the pending
+// rules of the log source if it is seen for the first time. Fields left
+// empty in source match rules with any value for them.
+func (l *LazyEngine) Evaluate(source LogSource, event interface{}) (*DagEvaluationResult, error) {
+	engine, err := l.engineFor(source)
+	if err != nil {
+		return nil, err
+	}
+	if engine == nil {
+		return NewDagEvaluationResult(), nil
+	}
+	return engine.Evaluate(event)
+}
+
+// Compile compiles the pending rules of a log source ahead of its first
+// event, e.g. for log sources known to be ingested
+func (l *LazyEngine) Compile(source LogSource) error {
+	_, err := l.engineFor(source)
+	return err
+}
+
+// engineFor returns the engine to evaluate events of a log source with,
+// compiling the log source's rules when it is first seen. The engine is
+// rebuilt until it holds every compiled rule, so a failed rebuild is
+// retried rather than leaving the rules out silently.
+func (l *LazyEngine) engineFor(source LogSource) (*DagEngine, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.seen[source] {
+		return l.engine, nil
+	}
+
+	compiled := 0
+	for i := range l.rules {
+		rule := &l.rules[i]
+		if rule.State != RulePending || !logSourceCovers(rule.Meta.LogSource, source) {
+			continue
+		}
+		ruleId, err := l.compiler.CompileRule(l.ruleYamls[i])
+		if err != nil {
+			rule.State = RuleFailed
+			rule.Err = err
+			l.logger.Debug("lazy rule compilation failed",
+				slog.Int("index", i),
+				slog.String("title", rule.Meta.Title),
+				slog.Any("error", err))
+			continue
+		}
+		rule.Meta.ID = ruleId
+		l.unbuilt = append(l.unbuilt, i)
+		compiled++
+	}
+	if len(l.unbuilt) > 0 {
+		if err := l.rebuild(); err != nil {
+			for _, i := range l.unbuilt {
+				l.rules[i].State = RuleFailed
+				l.rules[i].Err = err
+			}
+			l.logger.Debug("lazy engine rebuild failed",
+				slog.Int("rules", len(l.unbuilt)),
+				slog.Any("error", err))
+			return nil, err
+		}
+		for _, i := range l.unbuilt {
+			l.rules[i].State = RuleCompiled
+			l.rules[i].Err = nil
+		}
+		l.unbuilt = nil
+	}
+	l.seen[source] = true
+
+	l.logger.Debug("log source seen",
+		slog.String("category", source.Category),
+		slog.String("product", source.Product),
+		slog.String("service", source.Service),
+		slog.Int("compiled_rules", compiled))
+	return l.engine, nil
+}
+
+// rebuild replaces the engine with one of every rule compiled so far.
+// Callers hold the lock.
+func (l *LazyEngine) rebuild() error {
+	ruleset, err := l.compiler.Build()
+	if err != nil {
+		return err
+	}
+	engine, err := NewDagEngineFromRulesetWithConfig(ruleset, l.config)
+	if err != nil {
+		return err
+	}
+
+	previous, previousPrimitives := l.engine, l.primitives
+	l.engine, l.primitives = engine, ruleset.Primitives
+	if previous != nil {
+		previous.Close()
+		l.cache.release(previousPrimitives)
+	}
+	return nil
+}
+
+// logSourceCovers reports whether a rule's log source applies to events of
+// a log source: every field is empty on either side or equal
+func logSourceCovers(rule, source LogSource) bool {
+	covers := func(ruleField, sourceField string) bool {
+		return sourceField == "" || logSourceFieldMatches(ruleField, sourceField)
+	}
+	return covers(rule.Category, source.Category) &&
+		covers(rule.Product, source.Product) &&
+		covers(rule.Service, source.Service)
+}
+
+// Rules returns the loaded rules with their compile state, in load order
+func (l *LazyEngine) Rules() []LazyRule {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LazyRule(nil), l.rules...)
+}
+
+// Engine returns the engine of the rules compiled so far, or nil before
+// any rule is compiled
+func (l *LazyEngine) Engine() *DagEngine {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.engine
+}
+
+// Close closes the current engine
+func (l *LazyEngine) Close() {
+	if engine := l.Engine(); engine != nil {
+		engine.Close()
+	}
+}
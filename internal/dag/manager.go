@@ -0,0 +1,277 @@
+package dag
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/logging"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+)
+
+// PrimitiveCache shares compiled primitive matchers between engines so that
+// identical primitives (same field, match type, values and modifiers) are
+// compiled once, however many rulesets use them.
+type PrimitiveCache struct {
+	mu      sync.Mutex
+	builder *matcher.MatcherBuilder
+	entries map[string]*primitiveCacheEntry
+	hits    uint64
+	misses  uint64
+}
+
+// primitiveCacheEntry is a shared compiled matcher with its reference count
+type primitiveCacheEntry struct {
+	matcher     *matcher.CompiledPrimitive
+	matcherFunc func(interface{}) bool
+	refs        int
+}
+
+// PrimitiveCacheStats reports primitive cache usage
+type PrimitiveCacheStats struct {
+	Entries int
+	Hits    uint64
+	Misses  uint64
+}
+
+// NewPrimitiveCache creates an empty primitive cache
+func NewPrimitiveCache() *PrimitiveCache {
+	return NewPrimitiveCacheWithRegistry(nil)
+}
+
+// NewPrimitiveCacheWithRegistry creates an empty primitive cache compiling
+// matchers from the registry (nil = built-in matchers and modifiers)
+func NewPrimitiveCacheWithRegistry(registry *matcher.MatcherRegistry) *PrimitiveCache {
+	return &PrimitiveCache{
+		builder: newPrimitiveMatcherBuilder(registry),
+		entries: make(map[string]*primitiveCacheEntry),
+	}
+}
+
+// acquire returns the compiled primitive for an engine, compiling the
+// matcher on first use and taking a reference on the shared entry
+func (c *PrimitiveCache) acquire(primitive Primitive) *CompiledPrimitive {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := primitiveCacheKey(primitive)
+	entry, exists := c.entries[key]
+	if exists {
+		c.hits++
+	} else {
+		c.misses++
+		entry = &primitiveCacheEntry{}
+		irPrimitive := ir.NewTypedPrimitive(primitive.Field, primitive.MatchType, primitive.Values, primitive.ValueKinds, primitive.Modifiers)
+		irPrimitive.FieldAliases = primitive.FieldAliases
+		irPrimitive.IgnoreCase = primitive.IgnoreCase
+		if m, err := c.builder.CompilePrimitive(*irPrimitive); err == nil {
+			entry.matcher = m
+			entry.matcherFunc = func(event interface{}) bool {
+				matched, err := m.Matches(matcher.NewEventContext(event))
+				return err == nil && matched
+			}
+		} else {
+			// Unregistered match type: fall back to the basic equality matcher
+			entry.matcherFunc = createMatcherFunc(primitive.Field, primitive.MatchType, primitive.Values)
+		}
+		c.entries[key] = entry
+	}
+	entry.refs++
+
+	return &CompiledPrimitive{
+		ID:          primitive.ID,
+		Field:       primitive.Field,
+		MatchType:   primitive.MatchType,
+		Values:      primitive.Values,
+		Modifiers:   primitive.Modifiers,
+		MatcherFunc: entry.matcherFunc,
+		Matcher:     entry.matcher,
+
+		FieldAliases: primitive.FieldAliases,
+	}
+}
+
+// release drops references taken by acquire, evicting unused entries
+func (c *PrimitiveCache) release(primitives []Primitive) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, primitive := range primitives {
+		key := primitiveCacheKey(primitive)
+		entry, exists := c.entries[key]
+		if !exists {
+			continue
+		}
+		entry.refs--
+		if entry.refs <= 0 {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Len returns the number of unique compiled primitives in the cache
+func (c *PrimitiveCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Stats returns cache usage statistics
+func (c *PrimitiveCache) Stats() PrimitiveCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return PrimitiveCacheStats{
+		Entries: len(c.entries),
+		Hits:    c.hits,
+		Misses:  c.misses,
+	}
+}
+
+// primitiveCacheKey builds the identity key of a primitive (ignoring its ID)
+func primitiveCacheKey(primitive Primitive) string {
+	kinds := make([]string, len(primitive.ValueKinds))
+	for i, kind := range primitive.ValueKinds {
+		kinds[i] = kind.String()
+	}
+	return strings.Join([]string{
+		primitive.Field,
+		primitive.MatchType,
+		strings.Join(primitive.Values, "\x1f"),
+		strings.Join(primitive.Modifiers, "\x1f"),
+		strings.Join(kinds, "\x1f"),
+		strings.Join(primitive.FieldAliases, "\x1f"),
+		strconv.FormatBool(primitive.IgnoreCase),
+	}, "\x1e")
+}
+
+// EngineManager maintains one engine per tenant. All tenant engines share a
+// PrimitiveCache, so memory grows with the number of unique primitives
+// rather than with tenants × rules.
+type EngineManager struct {
+	mu              sync.RWMutex
+	config          DagEngineConfig
+	compilerFactory func() Compiler
+	cache           *PrimitiveCache
+	tenants         map[string]*tenantEngine
+	logger          *slog.Logger
+}
+
+// tenantEngine is a tenant's engine and the primitives it holds in the cache
+type tenantEngine struct {
+	engine     *DagEngine
+	primitives []Primitive
+}
+
+// NewEngineManager creates a manager that builds tenant engines with the
+// given config. compilerFactory creates a compiler for each tenant rule load
+// (nil = only LoadTenantRuleset is available).
+func NewEngineManager(config DagEngineConfig, compilerFactory func() Compiler) *EngineManager {
+	cache := config.PrimitiveCache
+	if cache == nil {
+		cache = NewPrimitiveCacheWithRegistry(config.MatcherRegistry)
+	}
+	config.PrimitiveCache = cache
+
+	return &EngineManager{
+		config:          config,
+		compilerFactory: compilerFactory,
+		cache:           cache,
+		tenants:         make(map[string]*tenantEngine),
+		logger:          logging.For(config.Logger, logging.ComponentEngine),
+	}
+}
+
+// LoadTenant compiles the tenant's rules and replaces its engine
+func (m *EngineManager) LoadTenant(tenant string, ruleYamls []string) error {
+	if m.compilerFactory == nil {
+		return fmt.Errorf("engine manager has no compiler")
+	}
+
+	ruleset, err := compileRules(m.compilerFactory(), ruleYamls, m.config)
+	if err != nil {
+		return fmt.Errorf("failed to compile rules for tenant %s: %w", tenant, err)
+	}
+
+	return m.LoadTenantRuleset(tenant, ruleset)
+}
+
+// LoadTenantRuleset builds an engine from a compiled ruleset and replaces
+// the tenant's engine
+func (m *EngineManager) LoadTenantRuleset(tenant string, ruleset *CompiledRuleset) error {
+	engine, err := NewDagEngineFromRulesetWithConfig(ruleset, m.config)
+	if err != nil {
+		return fmt.Errorf("failed to build engine for tenant %s: %w", tenant, err)
+	}
+
+	m.mu.Lock()
+	previous := m.tenants[tenant]
+	m.tenants[tenant] = &tenantEngine{engine: engine, primitives: ruleset.Primitives}
+	m.mu.Unlock()
+
+	if previous != nil {
+		m.cache.release(previous.primitives)
+	}
+
+	m.logger.Debug("tenant engine loaded",
+		slog.String("tenant", tenant),
+		slog.Int("rules", engine.RuleCount()),
+		slog.Int("shared_primitives", m.cache.Len()))
+
+	return nil
+}
+
+// RemoveTenant drops a tenant's engine and releases its primitives
+func (m *EngineManager) RemoveTenant(tenant string) bool {
+	m.mu.Lock()
+	previous, exists := m.tenants[tenant]
+	delete(m.tenants, tenant)
+	m.mu.Unlock()
+
+	if exists {
+		m.cache.release(previous.primitives)
+	}
+	return exists
+}
+
+// Engine returns the engine of a tenant
+func (m *EngineManager) Engine(tenant string) (*DagEngine, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, exists := m.tenants[tenant]
+	if !exists {
+		return nil, false
+	}
+	return entry.engine, true
+}
+
+// Tenants returns the loaded tenants, sorted by name
+func (m *EngineManager) Tenants() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tenants := make([]string, 0, len(m.tenants))
+	for tenant := range m.tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// Evaluate evaluates an event against a tenant's engine
+func (m *EngineManager) Evaluate(tenant string, event interface{}) (*DagEvaluationResult, error) {
+	engine, exists := m.Engine(tenant)
+	if !exists {
+		return nil, fmt.Errorf("unknown tenant: %s", tenant)
+	}
+	return engine.Evaluate(event)
+}
+
+// PrimitiveCache returns the primitive cache shared by the tenant engines
+func (m *EngineManager) PrimitiveCache() *PrimitiveCache {
+	return m.cache
+}
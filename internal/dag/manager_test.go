@@ -0,0 +1,55 @@
+package dag
+
+import "testing"
+
+func TestEngineManagerSharesPrimitives(t *testing.T) {
+	config := DefaultDagEngineConfig()
+	config.EnableOptimization = false
+	config.EnablePrefilter = false
+	manager := NewEngineManager(config, nil)
+
+	for _, tenant := range []string{"tenant-b", "tenant-a"} {
+		ruleset := createTestRuleset()
+		ruleset.Dag = createTestDag()
+		if err := manager.LoadTenantRuleset(tenant, ruleset); err != nil {
+			t.Fatalf("Failed to load %s: %v", tenant, err)
+		}
+	}
+
+	if tenants := manager.Tenants(); len(tenants) != 2 || tenants[0] != "tenant-a" {
+		t.Errorf("Unexpected tenants: %v", tenants)
+	}
+	stats := manager.PrimitiveCache().Stats()
+	if stats.Entries != 2 || stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Expected 2 shared primitives with 2 hits, got %+v", stats)
+	}
+
+	engineA, _ := manager.Engine("tenant-a")
+	engineB, _ := manager.Engine("tenant-b")
+	if engineA.primitives[0].Matcher != engineB.primitives[0].Matcher {
+		t.Error("Expected tenants to share compiled matchers")
+	}
+
+	result, err := manager.Evaluate("tenant-a", map[string]interface{}{"EventID": "4624", "ProcessName": "powershell"})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 {
+		t.Errorf("Expected 1 matched rule, got %v", result.MatchedRules)
+	}
+	if _, err := manager.Evaluate("unknown", map[string]interface{}{}); err == nil {
+		t.Error("Expected error for unknown tenant")
+	}
+
+	manager.RemoveTenant("tenant-a")
+	if manager.PrimitiveCache().Len() != 2 {
+		t.Error("Expected primitives still used by tenant-b to stay cached")
+	}
+	manager.RemoveTenant("tenant-b")
+	if manager.PrimitiveCache().Len() != 0 {
+		t.Errorf("Expected unused primitives to be evicted, got %d", manager.PrimitiveCache().Len())
+	}
+	if err := manager.LoadTenant("tenant-c", nil); err == nil {
+		t.Error("Expected error loading rules without a compiler")
+	}
+}
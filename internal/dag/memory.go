@@ -0,0 +1,188 @@
+package dag
+
+import (
+	"regexp/syntax"
+	"unsafe"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+// Per-item size estimates used for memory accounting
+const (
+	stringHeaderSize = int(unsafe.Sizeof(""))
+	sliceHeaderSize  = int(unsafe.Sizeof([]NodeId(nil)))
+	pointerSize      = int(unsafe.Sizeof(uintptr(0)))
+
+	// Approximate per-entry overhead of a Go map (bucket slot, tophash, overflow share)
+	mapEntryOverhead = 16
+)
+
+// MemoryUsage is an itemized estimate of the memory held by a compiled DAG
+// and the matchers evaluating it
+type MemoryUsage struct {
+	// DagNode structs, node type payloads and dependency/dependent slices
+	NodeBytes int
+
+	// Execution order, primitive map, rule results and rule metadata
+	IndexBytes int
+
+	// Compiled primitives (field paths, values, modifiers, matcher structs)
+	PrimitiveBytes int
+
+	// Compiled regular expression programs used by regex primitives
+	RegexBytes int
+
+	// Literal pattern set and lookup index of the prefilter
+	PrefilterBytes int
+
+	TotalBytes int
+}
+
+// total recomputes TotalBytes from the itemized counts
+func (m MemoryUsage) total() MemoryUsage {
+	m.TotalBytes = m.NodeBytes + m.IndexBytes + m.PrimitiveBytes + m.RegexBytes + m.PrefilterBytes
+	return m
+}
+
+// MemoryUsage estimates the memory held by the DAG structure itself
+func (dag *CompiledDag) MemoryUsage() MemoryUsage {
+	usage := MemoryUsage{
+		NodeBytes:  dagNodesMemory(dag.Nodes),
+		IndexBytes: dagIndexMemory(dag),
+	}
+	return usage.total()
+}
+
+// dagNodesMemory estimates the memory of DAG nodes and their edges
+func dagNodesMemory(nodes []DagNode) int {
+	nodeSize := int(unsafe.Sizeof(DagNode{}))
+	size := len(nodes) * nodeSize
+	for i := range nodes {
+		node := &nodes[i]
+		size += (cap(node.Dependencies) + cap(node.Dependents)) * int(unsafe.Sizeof(NodeId(0)))
+
+		// Node type payloads (individually allocated, or slab entries once compacted)
+		nodeType := node.NodeType
+		size += len(nodeType.Type)
+		if nodeType.PrimitiveId != nil {
+			size += int(unsafe.Sizeof(ir.PrimitiveID(0)))
+		}
+		if nodeType.Operation != nil {
+			size += int(unsafe.Sizeof(LogicalOp(0)))
+		}
+		if nodeType.RuleId != nil {
+			size += int(unsafe.Sizeof(ir.RuleID(0)))
+		}
+		if nodeType.PrefilterID != nil {
+			size += int(unsafe.Sizeof(uint32(0)))
+		}
+		if nodeType.PatternCount != nil {
+			size += int(unsafe.Sizeof(0))
+		}
+	}
+	return size
+}
+
+// dagIndexMemory estimates the memory of the DAG lookup tables and rule metadata
+func dagIndexMemory(dag *CompiledDag) int {
+	idPairSize := int(unsafe.Sizeof(ir.PrimitiveID(0))) + int(unsafe.Sizeof(NodeId(0))) + mapEntryOverhead
+
+	size := cap(dag.ExecutionOrder) * int(unsafe.Sizeof(NodeId(0)))
+	size += len(dag.PrimitiveMap) * idPairSize
+	size += len(dag.RuleResults) * idPairSize
+
+	for _, selections := range dag.RuleSelections {
+		size += pointerSize + mapEntryOverhead
+		for name, primitiveIds := range selections {
+			size += stringHeaderSize + len(name) + sliceHeaderSize + mapEntryOverhead
+			size += cap(primitiveIds) * int(unsafe.Sizeof(ir.PrimitiveID(0)))
+		}
+	}
+	for _, fields := range dag.RuleFields {
+		size += sliceHeaderSize + mapEntryOverhead
+		for _, field := range fields {
+			size += 2*stringHeaderSize + len(field.Name) + len(field.EventField)
+		}
+	}
+	return size
+}
+
+// primitivesMemory estimates the memory of compiled primitives, returning
+// the primitive bytes and the regex program bytes separately
+func primitivesMemory(primitives map[uint32]*CompiledPrimitive) (int, int) {
+	primitiveSize := int(unsafe.Sizeof(CompiledPrimitive{}))
+	primitiveBytes := 0
+	regexBytes := 0
+
+	// Strings shared between primitives, e.g. interned by the compiler, are
+	// counted once
+	shared := make(map[*byte]bool)
+	for _, primitive := range primitives {
+		primitiveBytes += primitiveSize + pointerSize + mapEntryOverhead
+		primitiveBytes += sharedStringsMemory([]string{primitive.Field, primitive.MatchType}, shared)
+		primitiveBytes += sharedStringsMemory(primitive.Values, shared) + sharedStringsMemory(primitive.Modifiers, shared)
+		if primitive.Matcher != nil {
+			primitiveBytes += primitive.Matcher.MemoryUsage()
+		}
+
+		if primitive.MatchType == "regex" || primitive.MatchType == "re" {
+			for _, pattern := range primitive.Values {
+				regexBytes += regexMemory(pattern)
+			}
+		}
+	}
+	return primitiveBytes, regexBytes
+}
+
+// regexMemory estimates the size of a compiled regular expression program
+func regexMemory(pattern string) int {
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return 0
+	}
+	prog, err := syntax.Compile(parsed.Simplify())
+	if err != nil {
+		return 0
+	}
+
+	size := len(pattern) + int(unsafe.Sizeof(syntax.Prog{}))
+	for _, inst := range prog.Inst {
+		size += int(unsafe.Sizeof(inst)) + len(inst.Rune)*int(unsafe.Sizeof(rune(0)))
+	}
+	return size
+}
+
+// prefilterMemory estimates the memory of the prefilter literal set and
+// lookup index
+func prefilterMemory(prefilter *LiteralPrefilter) int {
+	if prefilter == nil {
+		return 0
+	}
+	size := int(unsafe.Sizeof(LiteralPrefilter{})) + int(unsafe.Sizeof(PrefilterStats{}))
+	for pattern := range prefilter.patterns {
+		size += stringHeaderSize + len(pattern) + 1 + mapEntryOverhead
+	}
+	return size + prefilter.index.memoryBytes()
+}
+
+// sharedStringsMemory estimates the memory of a string slice, leaving out
+// the bytes of strings whose data was already counted
+func sharedStringsMemory(values []string, counted map[*byte]bool) int {
+	size := sliceHeaderSize
+	for _, value := range values {
+		size += stringHeaderSize
+		if data := unsafe.StringData(value); !counted[data] {
+			counted[data] = true
+			size += len(value)
+		}
+	}
+	return size
+}
+
+// engineMemoryUsage estimates the memory held by an engine's compiled state
+func engineMemoryUsage(dag *CompiledDag, primitives map[uint32]*CompiledPrimitive, prefilter *LiteralPrefilter) MemoryUsage {
+	usage := dag.MemoryUsage()
+	usage.PrimitiveBytes, usage.RegexBytes = primitivesMemory(primitives)
+	usage.PrefilterBytes = prefilterMemory(prefilter)
+	return usage.total()
+}
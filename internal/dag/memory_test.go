@@ -0,0 +1,63 @@
+package dag
+
+import (
+	"errors"
+	"testing"
+
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+func TestDagMemoryUsage(t *testing.T) {
+	dag := createTestDag()
+	usage := dag.MemoryUsage()
+
+	if usage.NodeBytes <= 0 || usage.IndexBytes <= 0 {
+		t.Errorf("Expected node and index memory to be accounted, got %+v", usage)
+	}
+	if usage.TotalBytes != usage.NodeBytes+usage.IndexBytes {
+		t.Errorf("Expected total to be the sum of its parts, got %+v", usage)
+	}
+	if dag.Statistics().EstimatedMemoryBytes != usage.TotalBytes {
+		t.Error("Expected statistics to report the DAG memory usage")
+	}
+}
+
+func TestEngineMemoryUsageIncludesRegexes(t *testing.T) {
+	ruleset := createTestRuleset()
+	ruleset.Dag = createTestDag()
+	engine, err := NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	usage := engine.MemoryUsage()
+	if usage.PrimitiveBytes <= 0 || usage.PrefilterBytes <= 0 || usage.RegexBytes != 0 {
+		t.Errorf("Unexpected engine memory usage: %+v", usage)
+	}
+
+	ruleset = createTestRuleset()
+	ruleset.Dag = createTestDag()
+	ruleset.Primitives[1].MatchType = "regex"
+	ruleset.Primitives[1].Values = []string{`(?i)power(shell|sploit)\.exe$`}
+	engine, err = NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if engine.MemoryUsage().RegexBytes <= 0 {
+		t.Error("Expected regex programs to be accounted")
+	}
+}
+
+func TestEngineMemoryBudget(t *testing.T) {
+	ruleset := createTestRuleset()
+	ruleset.Dag = createTestDag()
+
+	_, err := NewDagEngineBuilder().WithOptimization(false).WithMemoryBudget(64).BuildFromRuleset(ruleset)
+	var sigmaErr *sigmaerrors.SigmaError
+	if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeMemoryBudgetExceeded {
+		t.Fatalf("Expected memory budget error, got %v", err)
+	}
+
+	if _, err := NewDagEngineBuilder().WithOptimization(false).WithMemoryBudget(1 << 20).BuildFromRuleset(ruleset); err != nil {
+		t.Errorf("Expected engine within budget to build, got %v", err)
+	}
+}
@@ -2,10 +2,13 @@ package dag
 
 import (
 	"fmt"
+	"log/slog"
 	"sort"
 	"strings"
 
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
 	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/logging"
 	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
 )
 
@@ -13,6 +16,20 @@ type DagOptimizer struct {
 	enableCSE             bool
 	enableDCE             bool
 	enableConstantFolding bool
+	enableFactoring       bool
+	logger                *slog.Logger
+	clock                 clock.Clock
+
+	// Primitives that can never match, folded to false
+	impossible map[ir.PrimitiveID]bool
+
+	// Custom passes run before dead code elimination, and the pipeline
+	// replacing the built-in passes (nil = built-in passes)
+	custom   []Pass
+	pipeline []Pass
+
+	// Statistics of the passes of the last run
+	statistics []PassStatistics
 }
 
 func NewDagOptimizer() *DagOptimizer {
@@ -20,9 +37,16 @@ func NewDagOptimizer() *DagOptimizer {
 		enableCSE:             true,
 		enableDCE:             true,
 		enableConstantFolding: true,
+		logger:                logging.Discard(),
 	}
 }
 
+// WithLogger sets the logger used to report per-pass results
+func (opt *DagOptimizer) WithLogger(logger *slog.Logger) *DagOptimizer {
+	opt.logger = logging.For(logger, logging.ComponentOptimizer)
+	return opt
+}
+
 func (opt *DagOptimizer) WithCSE(enable bool) *DagOptimizer {
 	opt.enableCSE = enable
 	return opt
@@ -38,42 +62,196 @@ func (opt *DagOptimizer) WithConstantFolding(enable bool) *DagOptimizer {
 	return opt
 }
 
+// WithImpossiblePrimitives marks primitives that can never match, e.g.
+// value matchers without values. Constant folding propagates them through
+// their dependents.
+func (opt *DagOptimizer) WithImpossiblePrimitives(ids ...ir.PrimitiveID) *DagOptimizer {
+	opt.impossible = make(map[ir.PrimitiveID]bool, len(ids))
+	for _, id := range ids {
+		opt.impossible[id] = true
+	}
+	return opt
+}
 
-func (opt *DagOptimizer) Optimize(dag *CompiledDag) (*CompiledDag, error) {
- 	optimizedDag := opt.copyDag(dag)
+// WithFactoring enables factoring operands shared by several AND/OR nodes
+// into a single node (off by default)
+func (opt *DagOptimizer) WithFactoring(enable bool) *DagOptimizer {
+	opt.enableFactoring = enable
+	return opt
+}
 
-	// Perform optimization passes in order
-	var err error
+// WithClock sets the time source of pass timing (nil = system clock)
+func (opt *DagOptimizer) WithClock(c clock.Clock) *DagOptimizer {
+	opt.clock = c
+	return opt
+}
 
-	if opt.enableConstantFolding {
-		optimizedDag, err = opt.constantFolding(optimizedDag)
-		if err != nil {
-			return nil, err
-		}
+// WithPass adds a custom pass to the built-in pipeline. Custom passes run in
+// the order they were added, after the built-in rewrites and before dead
+// code elimination, which removes the nodes they leave unused.
+func (opt *DagOptimizer) WithPass(pass Pass) *DagOptimizer {
+	opt.custom = append(opt.custom, pass)
+	return opt
+}
+
+// WithPasses replaces the whole pipeline, built-in passes included, with
+// the given passes. Passes returns the built-in pipeline to start from.
+func (opt *DagOptimizer) WithPasses(passes ...Pass) *DagOptimizer {
+	opt.pipeline = append([]Pass(nil), passes...)
+	return opt
+}
+
+// Passes returns the passes Optimize runs, in order
+func (opt *DagOptimizer) Passes() []Pass {
+	if opt.pipeline != nil {
+		return append([]Pass(nil), opt.pipeline...)
 	}
 
+	var passes []Pass
+	if opt.enableConstantFolding {
+		passes = append(passes, NewPass(PassConstantFolding, opt.constantFolding))
+	}
 	if opt.enableCSE {
-		optimizedDag, err = opt.commonSubexpressionElimination(optimizedDag)
-		if err != nil {
-			return nil, err
-		}
+		passes = append(passes, NewPass(PassCSE, opt.commonSubexpressionElimination))
 	}
-
+	if opt.enableFactoring {
+		passes = append(passes, NewPass(PassFactoring, opt.factorSharedOperands))
+	}
+	passes = append(passes, opt.custom...)
 	if opt.enableDCE {
-		optimizedDag, err = opt.deadCodeElimination(optimizedDag)
+		passes = append(passes, NewPass(PassDCE, opt.deadCodeElimination))
+	}
+	return passes
+}
+
+// PassStatistics returns the statistics of the passes the last Optimize
+// call ran, in order
+func (opt *DagOptimizer) PassStatistics() []PassStatistics {
+	return append([]PassStatistics(nil), opt.statistics...)
+}
+
+func (opt *DagOptimizer) Optimize(dag *CompiledDag) (*CompiledDag, error) {
+	optimizedDag := opt.copyDag(dag)
+	initialNodes := len(optimizedDag.Nodes)
+
+	// Dependents mirror dependencies; derive them so every pass starts from
+	// consistent edges
+	rebuildDependents(optimizedDag)
+	if err := checkEdges(optimizedDag); err != nil {
+		opt.log().Error("invalid DAG", slog.Any("error", err))
+		return nil, err
+	}
+
+	// Perform optimization passes in order
+	opt.statistics = nil
+	now := clock.Or(opt.clock)
+	for _, pass := range opt.Passes() {
+		before := len(optimizedDag.Nodes)
+		start := now.Now()
+		result, changed, err := pass.Run(optimizedDag)
+		if err == nil && result == nil {
+			err = errors.NewCompilationError(fmt.Sprintf("Pass %s returned no DAG", pass.Name()))
+		}
+		if err == nil {
+			err = checkEdges(result)
+		}
 		if err != nil {
+			opt.log().Error("optimization pass failed", slog.String("pass", pass.Name()), slog.Any("error", err))
 			return nil, err
 		}
+		optimizedDag = result
+
+		stats := PassStatistics{
+			Name:        pass.Name(),
+			Duration:    clock.Since(now, start),
+			NodesBefore: before,
+			NodesAfter:  len(optimizedDag.Nodes),
+			Changed:     changed,
+		}
+		opt.statistics = append(opt.statistics, stats)
+		opt.logPass(stats)
 	}
 
-	optimizedDag, err = opt.rebuildExecutionOrderOptimized(optimizedDag)
+	// Evaluators index nodes by ID, so close the gaps left by removed nodes
+	optimizedDag = opt.renumberNodes(optimizedDag)
+
+	optimizedDag, err := opt.rebuildExecutionOrderOptimized(optimizedDag)
+	if err == nil {
+		err = optimizedDag.Validate()
+	}
 	if err != nil {
+		opt.log().Error("execution order rebuild failed", slog.Any("error", err))
 		return nil, err
 	}
 
+	opt.log().Debug("optimization complete",
+		slog.Int("nodes_before", initialNodes),
+		slog.Int("nodes_after", len(optimizedDag.Nodes)))
+
 	return optimizedDag, nil
 }
 
+// checkEdges verifies that node IDs are unique, every edge exists in both
+// directions and the primitive and rule result maps point at nodes. Passes
+// run before renumbering, so IDs need not match positions.
+func checkEdges(dag *CompiledDag) error {
+	index := make(map[NodeId]int, len(dag.Nodes))
+	for i := range dag.Nodes {
+		if _, duplicate := index[dag.Nodes[i].ID]; duplicate {
+			return errors.NewCompilationError(fmt.Sprintf("Duplicate node ID: %d", dag.Nodes[i].ID))
+		}
+		index[dag.Nodes[i].ID] = i
+	}
+
+	for _, node := range dag.Nodes {
+		for _, depId := range node.Dependencies {
+			position, exists := index[depId]
+			if !exists {
+				return errors.NewCompilationError(fmt.Sprintf("Invalid dependency: %d -> %d", node.ID, depId))
+			}
+			if !containsNode(dag.Nodes[position].Dependents, node.ID) {
+				return errors.NewCompilationError(fmt.Sprintf("Missing dependent: %d -> %d", depId, node.ID))
+			}
+		}
+		for _, dependentId := range node.Dependents {
+			position, exists := index[dependentId]
+			if !exists {
+				return errors.NewCompilationError(fmt.Sprintf("Invalid dependent: %d -> %d", node.ID, dependentId))
+			}
+			if !containsNode(dag.Nodes[position].Dependencies, node.ID) {
+				return errors.NewCompilationError(fmt.Sprintf("Stale dependent: %d -> %d", node.ID, dependentId))
+			}
+		}
+	}
+
+	for primitiveId, nodeId := range dag.PrimitiveMap {
+		if _, exists := index[nodeId]; !exists {
+			return errors.NewCompilationError(fmt.Sprintf("Invalid primitive node: %d -> %d", primitiveId, nodeId))
+		}
+	}
+	for ruleId, nodeId := range dag.RuleResults {
+		if _, exists := index[nodeId]; !exists {
+			return errors.NewCompilationError(fmt.Sprintf("Invalid result node: %d -> %d", ruleId, nodeId))
+		}
+	}
+	return nil
+}
+
+// log returns the optimizer logger (discarding when none was configured)
+func (opt *DagOptimizer) log() *slog.Logger {
+	return opt.logger
+}
+
+// logPass reports the node counts, duration and outcome of a single pass
+func (opt *DagOptimizer) logPass(stats PassStatistics) {
+	opt.log().Debug("optimization pass finished",
+		slog.String("pass", stats.Name),
+		slog.Int("nodes_before", stats.NodesBefore),
+		slog.Int("nodes_after", stats.NodesAfter),
+		slog.Duration("duration", stats.Duration),
+		slog.Bool("changed", stats.Changed))
+}
+
 func (opt *DagOptimizer) copyDag(dag *CompiledDag) *CompiledDag {
 	// Copy nodes
 	nodesCopy := make([]DagNode, len(dag.Nodes))
@@ -111,6 +289,8 @@ func (opt *DagOptimizer) copyDag(dag *CompiledDag) *CompiledDag {
 		PrimitiveMap:     primitiveMapCopy,
 		RuleResults:      ruleResultsCopy,
 		ResultBufferSize: dag.ResultBufferSize,
+		RuleSelections:   dag.RuleSelections, // Read-only metadata, shared
+		RuleFields:       dag.RuleFields,     // Read-only metadata, shared
 	}
 }
 
@@ -132,10 +312,10 @@ func (opt *DagOptimizer) topologicalSort(dag *CompiledDag) ([]NodeId, error) {
 		}
 	}
 
-	// Find nodes with no dependencies
-	for nodeId, degree := range inDegree {
-		if degree == 0 {
-			queue = append(queue, nodeId)
+	// Find nodes with no dependencies, in node order so the result is stable
+	for _, node := range dag.Nodes {
+		if inDegree[node.ID] == 0 {
+			queue = append(queue, node.ID)
 		}
 	}
 
@@ -192,7 +372,7 @@ func (opt *DagOptimizer) markReachable(nodeId NodeId, dag *CompiledDag, reachabl
 }
 
 // deadCodeElimination - Remove nodes that don't contribute to any rule result
-func (opt *DagOptimizer) deadCodeElimination(dag *CompiledDag) (*CompiledDag, error) {
+func (opt *DagOptimizer) deadCodeElimination(dag *CompiledDag) (*CompiledDag, bool, error) {
 	reachable := make(map[NodeId]bool)
 
 	// Mark all result nodes as reachable
@@ -200,14 +380,23 @@ func (opt *DagOptimizer) deadCodeElimination(dag *CompiledDag) (*CompiledDag, er
 		opt.markReachable(resultNodeId, dag, reachable)
 	}
 
-	// Remove unreachable nodes
+	// Remove unreachable nodes and their edges from the nodes that remain
+	removed := len(dag.Nodes)
 	var newNodes []DagNode
 	for _, node := range dag.Nodes {
 		if reachable[node.ID] {
+			var dependents []NodeId
+			for _, dependentId := range node.Dependents {
+				if reachable[dependentId] {
+					dependents = append(dependents, dependentId)
+				}
+			}
+			node.Dependents = dependents
 			newNodes = append(newNodes, node)
 		}
 	}
 	dag.Nodes = newNodes
+	removed -= len(newNodes)
 
 	newPrimitiveMap := make(map[ir.PrimitiveID]NodeId)
 	for k, v := range dag.PrimitiveMap {
@@ -226,7 +415,40 @@ func (opt *DagOptimizer) deadCodeElimination(dag *CompiledDag) (*CompiledDag, er
 	}
 	dag.RuleResults = newRuleResults
 
-	return dag, nil
+	return dag, removed > 0, nil
+}
+
+// renumberNodes reassigns node IDs to match node positions after passes
+// removed nodes, rewriting every reference to the old IDs
+func (opt *DagOptimizer) renumberNodes(dag *CompiledDag) *CompiledDag {
+	newIds := make(map[NodeId]NodeId, len(dag.Nodes))
+	for i := range dag.Nodes {
+		newIds[dag.Nodes[i].ID] = NodeId(i)
+	}
+	remap := func(ids []NodeId) []NodeId {
+		remapped := ids[:0]
+		for _, id := range ids {
+			if newId, exists := newIds[id]; exists {
+				remapped = append(remapped, newId)
+			}
+		}
+		return remapped
+	}
+
+	for i := range dag.Nodes {
+		node := &dag.Nodes[i]
+		node.ID = NodeId(i)
+		node.Dependencies = remap(node.Dependencies)
+		node.Dependents = remap(node.Dependents)
+	}
+	for primitiveId, nodeId := range dag.PrimitiveMap {
+		dag.PrimitiveMap[primitiveId] = newIds[nodeId]
+	}
+	for ruleId, nodeId := range dag.RuleResults {
+		dag.RuleResults[ruleId] = newIds[nodeId]
+	}
+	dag.ResultBufferSize = len(dag.Nodes)
+	return dag
 }
 
 // buildExpressionSignature - Build signature string for CSE
@@ -288,7 +510,8 @@ func (opt *DagOptimizer) buildExpressionSignature(node *DagNode, dag *CompiledDa
 }
 
 // commonSubexpressionElimination - Perform CSE optimization
-func (opt *DagOptimizer) commonSubexpressionElimination(dag *CompiledDag) (*CompiledDag, error) {
+func (opt *DagOptimizer) commonSubexpressionElimination(dag *CompiledDag) (*CompiledDag, bool, error) {
+	merged := false
 	changed := true
 	iterations := 0
 	const maxIterations = 5
@@ -326,12 +549,13 @@ func (opt *DagOptimizer) commonSubexpressionElimination(dag *CompiledDag) (*Comp
 			var err error
 			dag, err = opt.applyNodeMapping(dag, nodeMapping)
 			if err != nil {
-				return nil, err
+				return nil, false, err
 			}
+			merged = true
 		}
 	}
 
-	return dag, nil
+	return dag, merged, nil
 }
 
 func (opt *DagOptimizer) applyNodeMapping(dag *CompiledDag, nodeMapping map[NodeId]NodeId) (*CompiledDag, error) {
@@ -340,10 +564,14 @@ func (opt *DagOptimizer) applyNodeMapping(dag *CompiledDag, nodeMapping map[Node
 		nodesToRemove[nodeId] = true
 	}
 
+	// Nodes that replace a removed node inherit its dependents
+	inheritedDependents := make(map[NodeId][]NodeId)
 	var newNodes []DagNode
 	for _, node := range dag.Nodes {
 		if !nodesToRemove[node.ID] {
 			newNodes = append(newNodes, node)
+		} else {
+			inheritedDependents[nodeMapping[node.ID]] = append(inheritedDependents[nodeMapping[node.ID]], node.Dependents...)
 		}
 	}
 
@@ -351,9 +579,9 @@ func (opt *DagOptimizer) applyNodeMapping(dag *CompiledDag, nodeMapping map[Node
 		node := &newNodes[i]
 		var newDependencies []NodeId
 		for _, depId := range node.Dependencies {
-			mappedId := nodeMapping[depId]
-			if mappedId == 0 {
-				mappedId = depId 
+			mappedId, exists := nodeMapping[depId]
+			if !exists {
+				mappedId = depId
 			}
 			found := false
 			for _, existingDep := range newDependencies {
@@ -369,9 +597,9 @@ func (opt *DagOptimizer) applyNodeMapping(dag *CompiledDag, nodeMapping map[Node
 		node.Dependencies = newDependencies
 
 		var newDependents []NodeId
-		for _, depId := range node.Dependents {
-			mappedId := nodeMapping[depId]
-			if mappedId == 0 {
+		for _, depId := range append(node.Dependents, inheritedDependents[node.ID]...) {
+			mappedId, exists := nodeMapping[depId]
+			if !exists {
 				mappedId = depId // Use original if no mapping
 			}
 			// Remove duplicates
@@ -501,11 +729,15 @@ func (opt *DagOptimizer) optimizeExecutionOrder(dag *CompiledDag, basicOrder []N
 			break
 		}
 
-		// Sort ready nodes by estimated selectivity (most selective first)
+		// Sort ready nodes by estimated selectivity (most selective first),
+		// breaking ties by node ID so the order does not depend on map iteration
 		sort.Slice(readyNodes, func(i, j int) bool {
 			selectivityA := opt.estimateNodeSelectivity(dag, readyNodes[i])
 			selectivityB := opt.estimateNodeSelectivity(dag, readyNodes[j])
-			return selectivityA < selectivityB // Lower selectivity = higher priority
+			if selectivityA != selectivityB {
+				return selectivityA < selectivityB // Lower selectivity = higher priority
+			}
+			return readyNodes[i] < readyNodes[j]
 		})
 
 		// Add ready nodes to execution order
@@ -520,7 +752,23 @@ func (opt *DagOptimizer) optimizeExecutionOrder(dag *CompiledDag, basicOrder []N
 }
 
 // constantFolding - Perform constant folding optimization
-func (opt *DagOptimizer) constantFolding(dag *CompiledDag) (*CompiledDag, error) {
+//
+// Impossible primitives are false. Constants propagate through AND, OR and
+// NOT; operands that cannot change a non-constant node's result are dropped
+// (true in AND, false in OR). Constant nodes are then rewritten so every
+// evaluator computes their value, and rules that became constant are
+// reported: a rule that can never match would otherwise fail silently.
+func (opt *DagOptimizer) constantFolding(dag *CompiledDag) (*CompiledDag, bool, error) {
+	folded := false
+	for i := range dag.Nodes {
+		node := &dag.Nodes[i]
+		if node.NodeType.Type == "Primitive" && node.NodeType.PrimitiveId != nil && opt.impossible[*node.NodeType.PrimitiveId] {
+			impossible := false
+			node.CachedResult = &impossible
+			folded = true
+		}
+	}
+
 	changed := true
 	iterations := 0
 	const maxIterations = 10 // Prevent infinite loops
@@ -537,7 +785,7 @@ func (opt *DagOptimizer) constantFolding(dag *CompiledDag) (*CompiledDag, error)
 		}
 
 		for _, node := range dag.Nodes {
-			if node.NodeType.Type == "Logical" {
+			if node.NodeType.Type == "Logical" && node.CachedResult == nil {
 				if constantResult := opt.evaluateConstantExpression(&node, dag); constantResult != nil {
 					nodesToFold = append(nodesToFold, struct {
 						nodeId        NodeId
@@ -551,11 +799,115 @@ func (opt *DagOptimizer) constantFolding(dag *CompiledDag) (*CompiledDag, error)
 		for _, fold := range nodesToFold {
 			if opt.foldNodeToConstant(dag, fold.nodeId, fold.constantValue) {
 				changed = true
+				folded = true
 			}
 		}
 	}
 
-	return dag, nil
+	if folded {
+		opt.dropNeutralOperands(dag)
+		opt.materializeConstants(dag)
+		rebuildDependents(dag)
+	}
+	return dag, folded, nil
+}
+
+// dropNeutralOperands removes constant operands that do not decide a
+// non-constant AND (true) or OR (false)
+func (opt *DagOptimizer) dropNeutralOperands(dag *CompiledDag) {
+	constants := make(map[NodeId]bool)
+	for _, node := range dag.Nodes {
+		if node.CachedResult != nil {
+			constants[node.ID] = *node.CachedResult
+		}
+	}
+
+	for i := range dag.Nodes {
+		node := &dag.Nodes[i]
+		op, factorable := factorableOp(node)
+		if !factorable {
+			continue
+		}
+		neutral := op == LogicalAnd
+		var dependencies []NodeId
+		for _, depId := range node.Dependencies {
+			if value, constant := constants[depId]; !constant || value != neutral {
+				dependencies = append(dependencies, depId)
+			}
+		}
+		node.Dependencies = dependencies
+	}
+}
+
+// materializeConstants rewrites constant logical nodes into nodes every
+// evaluator computes to their value, false as an OR without operands and
+// true as its negation, and points results of constant rules at them
+func (opt *DagOptimizer) materializeConstants(dag *CompiledDag) {
+	falseId, hasFalse := NodeId(0), false
+	falseNode := func() NodeId {
+		if !hasFalse {
+			falseId, hasFalse = nextNodeId(dag), true
+			node := NewDagNode(falseId, NewLogicalNodeType(LogicalOr))
+			value := false
+			node.CachedResult = &value
+			dag.Nodes = append(dag.Nodes, *node)
+		}
+		return falseId
+	}
+
+	constants := make(map[NodeId]bool)
+	for i := range dag.Nodes {
+		if dag.Nodes[i].CachedResult != nil {
+			constants[dag.Nodes[i].ID] = *dag.Nodes[i].CachedResult
+		}
+	}
+	for i := range dag.Nodes {
+		node := &dag.Nodes[i]
+		value, constant := constants[node.ID]
+		if !constant || node.NodeType.Type != "Logical" {
+			continue
+		}
+		if value {
+			// falseNode may grow the slice
+			operand := falseNode()
+			node = &dag.Nodes[i]
+			node.NodeType = NewLogicalNodeType(LogicalNot)
+			node.Dependencies = []NodeId{operand}
+		} else {
+			node.NodeType = NewLogicalNodeType(LogicalOr)
+			node.Dependencies = nil
+		}
+	}
+
+	ruleIds := make([]ir.RuleID, 0, len(dag.RuleResults))
+	for ruleId := range dag.RuleResults {
+		ruleIds = append(ruleIds, ruleId)
+	}
+	sort.Slice(ruleIds, func(i, j int) bool { return ruleIds[i] < ruleIds[j] })
+
+	index := nodeIndex(dag)
+	for _, ruleId := range ruleIds {
+		position, exists := index[dag.RuleResults[ruleId]]
+		if !exists || len(dag.Nodes[position].Dependencies) != 1 {
+			continue
+		}
+		depId := dag.Nodes[position].Dependencies[0]
+		value, constant := constants[depId]
+		if !constant {
+			continue
+		}
+
+		// Primitives keep their type for the primitive map; results of
+		// impossible primitives use the shared false node instead
+		if depPosition, exists := index[depId]; exists && dag.Nodes[depPosition].NodeType.Type == "Primitive" {
+			dag.Nodes[position].Dependencies = []NodeId{falseNode()}
+		}
+		if value {
+			opt.log().Warn("rule matches every event", slog.Uint64("rule_id", uint64(ruleId)))
+		} else {
+			opt.log().Warn("rule can never match", slog.Uint64("rule_id", uint64(ruleId)))
+		}
+	}
 }
 
 // evaluateConstantExpression - Evaluate a logical expression if all operands are constants
@@ -565,39 +917,56 @@ func (opt *DagOptimizer) evaluateConstantExpression(node *DagNode, dag *Compiled
 	}
 
 	var operandValues []bool
+	allConstant := true
 
-	// Check if all dependencies are constant
+	// Collect the constant operands
 	for _, depId := range node.Dependencies {
 		for _, depNode := range dag.Nodes {
 			if depNode.ID == depId {
 				if depNode.CachedResult != nil {
 					operandValues = append(operandValues, *depNode.CachedResult)
 				} else {
-					// Not all operands are constant
-					return nil
+					allConstant = false
 				}
 				break
 			}
 		}
 	}
 
-	// Evaluate the logical operation
+	// Evaluate the logical operation. A false operand decides AND and a true
+	// one decides OR even when other operands are not constant; evaluators
+	// treat AND and OR without operands as false.
 	switch *node.NodeType.Operation {
 	case LogicalAnd:
-		result := true
+		result := len(node.Dependencies) > 0
 		for _, val := range operandValues {
-			result = result && val
+			if !val {
+				result = false
+				return &result
+			}
+		}
+		if !allConstant {
+			return nil
 		}
 		return &result
 
 	case LogicalOr:
 		result := false
 		for _, val := range operandValues {
-			result = result || val
+			if val {
+				result = true
+				return &result
+			}
+		}
+		if !allConstant {
+			return nil
 		}
 		return &result
 
 	case LogicalNot:
+		if !allConstant {
+			return nil
+		}
 		if len(operandValues) == 1 {
 			result := !operandValues[0]
 			return &result
@@ -617,7 +986,13 @@ func (opt *DagOptimizer) foldNodeToConstant(dag *CompiledDag, nodeId NodeId, con
 			// Cache the constant result
 			dag.Nodes[i].CachedResult = &constantValue
 
-			// Clear dependencies since this is now a constant
+			// Clear dependencies since this is now a constant, along with
+			// the reverse edges
+			for j := range dag.Nodes {
+				if containsNode(dag.Nodes[i].Dependencies, dag.Nodes[j].ID) {
+					dag.Nodes[j].Dependents = removeNode(dag.Nodes[j].Dependents, nodeId)
+				}
+			}
 			dag.Nodes[i].Dependencies = nil
 
 			return true
@@ -1,6 +1,10 @@
 package dag
 
 import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
@@ -121,6 +125,23 @@ func TestOptimizeSimpleDag(t *testing.T) {
 	}
 }
 
+func TestOptimizeLogsPasses(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	optimizer := NewDagOptimizer().WithLogger(logger)
+
+	if _, err := optimizer.Optimize(createTestDag()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"component=optimizer", "pass=cse", "pass=dce", "optimization complete"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected log output to contain %q, got: %s", want, out)
+		}
+	}
+}
+
 func TestBuildExpressionSignaturePrimitive(t *testing.T) {
 	optimizer := NewDagOptimizer()
 	dag := NewCompiledDag()
@@ -430,6 +451,19 @@ func TestFoldNodeToConstant(t *testing.T) {
 	}
 }
 
+func TestFoldNodeToConstantRemovesReverseEdges(t *testing.T) {
+	optimizer := NewDagOptimizer()
+	dag := createTestDag()
+
+	optimizer.foldNodeToConstant(dag, 2, false)
+	if err := checkEdges(dag); err != nil {
+		t.Errorf("Expected symmetric edges after folding: %v", err)
+	}
+	if len(dag.GetNode(0).Dependents) != 0 || len(dag.GetNode(1).Dependents) != 0 {
+		t.Error("Expected folded node to be removed from its operands' dependents")
+	}
+}
+
 func TestFoldNodeToConstantNonexistent(t *testing.T) {
 	optimizer := NewDagOptimizer()
 	dag := NewCompiledDag()
@@ -478,6 +512,53 @@ func TestMarkReachableAlreadyProcessed(t *testing.T) {
 	}
 }
 
+func TestDeadCodeEliminationPrunesDependents(t *testing.T) {
+	optimizer := NewDagOptimizer()
+	dag := createTestDag()
+
+	// An unused NOT over primitive 0
+	unused := NewDagNode(4, NewLogicalNodeType(LogicalNot))
+	unused.Dependencies = []NodeId{0}
+	dag.Nodes = append(dag.Nodes, *unused)
+	dag.Nodes[0].Dependents = append(dag.Nodes[0].Dependents, 4)
+
+	optimized, _, err := optimizer.deadCodeElimination(dag)
+	if err != nil {
+		t.Fatalf("Dead code elimination failed: %v", err)
+	}
+	if optimized.GetNode(4) != nil {
+		t.Error("Expected unused node to be removed")
+	}
+	if err := checkEdges(optimized); err != nil {
+		t.Errorf("Expected symmetric edges after dead code elimination: %v", err)
+	}
+}
+
+func TestCheckEdges(t *testing.T) {
+	if err := checkEdges(createTestDag()); err != nil {
+		t.Fatalf("Expected test DAG to be consistent: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		corrupt  func(dag *CompiledDag)
+		expected string
+	}{
+		{"missing dependent", func(dag *CompiledDag) { dag.Nodes[0].Dependents = nil }, "Missing dependent: 0 -> 2"},
+		{"stale dependent", func(dag *CompiledDag) { dag.Nodes[3].Dependents = []NodeId{0} }, "Stale dependent: 3 -> 0"},
+		{"invalid dependency", func(dag *CompiledDag) { dag.Nodes[2].Dependencies = []NodeId{0, 1, 7} }, "Invalid dependency: 2 -> 7"},
+		{"duplicate ID", func(dag *CompiledDag) { dag.Nodes[1].ID = 0 }, "Duplicate node ID: 0"},
+		{"invalid result", func(dag *CompiledDag) { dag.RuleResults[2] = 9 }, "Invalid result node: 2 -> 9"},
+	}
+	for _, test := range tests {
+		dag := createTestDag()
+		test.corrupt(dag)
+		if err := checkEdges(dag); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: expected %q, got %v", test.name, test.expected, err)
+		}
+	}
+}
+
 func TestTopologicalSortSimple(t *testing.T) {
 	optimizer := NewDagOptimizer()
 	dag := createTestDag()
@@ -547,6 +628,175 @@ func TestEstimateNodeSelectivity(t *testing.T) {
 	}
 }
 
+func TestOptimizeRenumbersMergedNodes(t *testing.T) {
+	// Rule 1: NOT(P0); rule 2: NOT(P0) OR P0, where the second NOT and
+	// the duplicate P0 node (mapped onto node 0) are merged away
+	dag := NewCompiledDag()
+	nodes := []struct {
+		nodeType     NodeType
+		dependencies []NodeId
+	}{
+		{NewPrimitiveNodeType(0), nil},
+		{NewLogicalNodeType(LogicalNot), []NodeId{0}},
+		{NewResultNodeType(1), []NodeId{1}},
+		{NewPrimitiveNodeType(0), nil},
+		{NewLogicalNodeType(LogicalNot), []NodeId{3}},
+		{NewLogicalNodeType(LogicalOr), []NodeId{4, 3}},
+		{NewResultNodeType(2), []NodeId{5}},
+	}
+	for i, spec := range nodes {
+		node := NewDagNode(NodeId(i), spec.nodeType)
+		node.Dependencies = spec.dependencies
+		dag.Nodes = append(dag.Nodes, *node)
+		for _, depId := range spec.dependencies {
+			dag.Nodes[depId].Dependents = append(dag.Nodes[depId].Dependents, NodeId(i))
+		}
+		dag.ExecutionOrder = append(dag.ExecutionOrder, NodeId(i))
+	}
+	dag.PrimitiveMap[0] = 0
+	dag.RuleResults[1] = 2
+	dag.RuleResults[2] = 6
+
+	optimized, err := NewDagOptimizer().Optimize(dag)
+	if err != nil {
+		t.Fatalf("Optimization failed: %v", err)
+	}
+	if len(optimized.Nodes) != 5 {
+		t.Errorf("Expected 5 nodes after CSE, got %d", len(optimized.Nodes))
+	}
+	for i, node := range optimized.Nodes {
+		if node.ID != NodeId(i) {
+			t.Errorf("Expected node at position %d to have ID %d, got %d", i, i, node.ID)
+		}
+		for _, depId := range append(node.Dependencies, node.Dependents...) {
+			if optimized.GetNode(depId) == nil {
+				t.Errorf("Node %d references missing node %d", node.ID, depId)
+			}
+		}
+	}
+	for ruleId, resultId := range optimized.RuleResults {
+		node := optimized.GetNode(resultId)
+		if node == nil || node.NodeType.RuleId == nil || *node.NodeType.RuleId != ruleId {
+			t.Errorf("Rule %d result points at %+v", ruleId, node)
+		}
+	}
+	if err := optimized.Validate(); err != nil {
+		t.Errorf("Optimized DAG is invalid: %v", err)
+	}
+}
+
+// createImpossibleRuleset creates rules over P0 (EventID without values,
+// which can never match) and P1 (ProcessName contains powershell):
+// rule 1 = AND(P0, P1), rule 2 = OR(P0, P1), rule 3 = AND(P1, NOT(P0)),
+// rule 4 = NOT(P0)
+func createImpossibleRuleset() *CompiledRuleset {
+	ruleset := createTestRuleset()
+	ruleset.Primitives[0].Values = nil
+
+	dag := NewCompiledDag()
+	addNode := func(nodeType NodeType, dependencies ...NodeId) NodeId {
+		nodeId := NodeId(len(dag.Nodes))
+		node := NewDagNode(nodeId, nodeType)
+		node.Dependencies = dependencies
+		dag.Nodes = append(dag.Nodes, *node)
+		for _, depId := range dependencies {
+			dag.Nodes[depId].Dependents = append(dag.Nodes[depId].Dependents, nodeId)
+		}
+		dag.ExecutionOrder = append(dag.ExecutionOrder, nodeId)
+		return nodeId
+	}
+	p0 := addNode(NewPrimitiveNodeType(0))
+	p1 := addNode(NewPrimitiveNodeType(1))
+	dag.PrimitiveMap[0], dag.PrimitiveMap[1] = p0, p1
+	conditions := []NodeId{
+		addNode(NewLogicalNodeType(LogicalAnd), p0, p1),
+		addNode(NewLogicalNodeType(LogicalOr), p0, p1),
+		addNode(NewLogicalNodeType(LogicalAnd), p1, addNode(NewLogicalNodeType(LogicalNot), p0)),
+		addNode(NewLogicalNodeType(LogicalNot), p0),
+	}
+	for i, condition := range conditions {
+		ruleId := ir.RuleID(i + 1)
+		dag.RuleResults[ruleId] = addNode(NewResultNodeType(ruleId), condition)
+	}
+	dag.ResultBufferSize = len(dag.Nodes)
+	ruleset.Dag = dag
+	return ruleset
+}
+
+func TestConstantFoldingPropagatesImpossiblePrimitives(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	optimizer := NewDagOptimizer().WithLogger(logger).WithImpossiblePrimitives(0)
+
+	optimized, err := optimizer.Optimize(createImpossibleRuleset().Dag)
+	if err != nil {
+		t.Fatalf("Optimization failed: %v", err)
+	}
+	if err := optimized.Validate(); err != nil {
+		t.Fatalf("Optimized DAG is invalid: %v", err)
+	}
+	if _, exists := optimized.PrimitiveMap[0]; exists {
+		t.Error("Expected the impossible primitive to be eliminated")
+	}
+
+	// Rules 2 and 3 reduce to P1; rules 1 and 4 are constant
+	p1 := optimized.PrimitiveMap[1]
+	for _, ruleId := range []ir.RuleID{2, 3} {
+		condition := optimized.GetNode(optimized.GetNode(optimized.RuleResults[ruleId]).Dependencies[0])
+		if len(condition.Dependencies) != 1 || condition.Dependencies[0] != p1 {
+			t.Errorf("Rule %d: expected a condition on P1 only, got %+v", ruleId, condition)
+		}
+	}
+
+	out := buf.String()
+	for _, want := range []string{"msg=\"rule can never match\" component=optimizer rule_id=1", "msg=\"rule matches every event\" component=optimizer rule_id=4"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected log output to contain %q, got: %s", want, out)
+		}
+	}
+}
+
+func TestEngineFoldsImpossiblePrimitives(t *testing.T) {
+	events := []map[string]interface{}{
+		{"EventID": "4624", "ProcessName": "powershell.exe"},
+		{"EventID": "", "ProcessName": "cmd.exe"},
+		{},
+	}
+	expected := [][]ir.RuleID{{2, 3, 4}, {4}, {4}}
+
+	for _, optimize := range []bool{false, true} {
+		for _, backend := range []Backend{BackendDAG, BackendVM} {
+			engine, err := NewDagEngineBuilder().
+				WithOptimization(optimize).
+				WithPrefilter(false).
+				WithBackend(backend).
+				BuildFromRuleset(createImpossibleRuleset())
+			if err != nil {
+				t.Fatalf("Failed to create engine: %v", err)
+			}
+			for i, event := range events {
+				result, err := engine.Evaluate(event)
+				if err != nil {
+					t.Fatalf("Evaluation failed: %v", err)
+				}
+				if matched := sortedRules(result.MatchedRules); !reflect.DeepEqual(matched, expected[i]) {
+					t.Errorf("%s (optimize=%t), event %d: expected %v, got %v", backend, optimize, i, expected[i], matched)
+				}
+			}
+
+			batch, err := engine.EvaluateBatch([]interface{}{events[0], events[1], events[2]})
+			if err != nil {
+				t.Fatalf("Batch evaluation failed: %v", err)
+			}
+			for i, result := range batch {
+				if matched := sortedRules(result.MatchedRules); !reflect.DeepEqual(matched, expected[i]) {
+					t.Errorf("%s (optimize=%t), batch event %d: expected %v, got %v", backend, optimize, i, expected[i], matched)
+				}
+			}
+		}
+	}
+}
+
 // Helper functions
 func contains(s, substr string) bool {
 	for i := 0; i <= len(s)-len(substr); i++ {
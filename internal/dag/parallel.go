@@ -0,0 +1,249 @@
+package dag
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+)
+
+// chunksPerWorker splits a batch into more chunks than workers so a worker
+// that finishes early can pick up remaining work
+const chunksPerWorker = 4
+
+// workerCount returns the number of workers to use, auto-detecting the
+// available CPUs when NumThreads is 0
+func (c ParallelConfig) workerCount() int {
+	if c.NumThreads > 0 {
+		return c.NumThreads
+	}
+	return availableCPUs()
+}
+
+// cgroupRoot is where the cgroup file systems are mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupQuota is the CPU quota of the process's cgroup, read once
+var cgroupQuota = sync.OnceValue(func() float64 {
+	return cgroupCPUQuota(cgroupRoot, "/proc/self/cgroup")
+})
+
+// availableCPUs returns the number of CPUs the process can use: GOMAXPROCS,
+// lowered to the container's CPU quota (rounded up) when a cgroup limits it
+func availableCPUs() int {
+	return cpusWithin(runtime.GOMAXPROCS(0), cgroupQuota())
+}
+
+// cpusWithin caps a CPU count to a CPU quota (0 = no quota)
+func cpusWithin(cpus int, quota float64) int {
+	if quota > 0 {
+		cpus = min(cpus, int(math.Ceil(quota)))
+	}
+	return max(cpus, 1)
+}
+
+// cgroupCPUQuota returns the CPU quota, in CPUs, of the process's cgroup
+// (cgroup v2 cpu.max, or cgroup v1 cpu.cfs_quota_us over
+// cpu.cfs_period_us), or 0 when the CPU time is not limited. The process's
+// own group, read from its cgroup membership file, is checked before the
+// root group.
+func cgroupCPUQuota(root, membership string) float64 {
+	var v2Group, v1Group string
+	if file, err := os.Open(membership); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			// hierarchy-ID:controllers:path
+			parts := strings.SplitN(scanner.Text(), ":", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			if parts[0] == "0" && parts[1] == "" {
+				v2Group = parts[2]
+			}
+			for _, controller := range strings.Split(parts[1], ",") {
+				if controller == "cpu" {
+					v1Group = parts[2]
+				}
+			}
+		}
+		file.Close()
+	}
+
+	for _, dir := range []string{filepath.Join(root, v2Group), root} {
+		if quota, ok := cgroupV2Quota(filepath.Join(dir, "cpu.max")); ok {
+			return quota
+		}
+	}
+	for _, mount := range []string{"cpu", "cpu,cpuacct"} {
+		for _, dir := range []string{filepath.Join(root, mount, v1Group), filepath.Join(root, mount)} {
+			if quota, ok := cgroupV1Quota(dir); ok {
+				return quota
+			}
+		}
+	}
+	return 0
+}
+
+// cgroupV2Quota reads a cgroup v2 cpu.max file: "max period" or
+// "quota period"
+func cgroupV2Quota(path string) (float64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return 0, false
+	}
+	if fields[0] == "max" {
+		return 0, true
+	}
+	return quotaRatio(fields[0], fields[1])
+}
+
+// cgroupV1Quota reads the cgroup v1 CFS quota and period of a group, where
+// a quota of -1 means no limit
+func cgroupV1Quota(dir string) (float64, bool) {
+	quota, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0, false
+	}
+	period, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, false
+	}
+	if strings.TrimSpace(string(quota)) == "-1" {
+		return 0, true
+	}
+	return quotaRatio(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// quotaRatio divides a CPU time quota by its period
+func quotaRatio(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return q / p, true
+}
+
+// batchChunk is a contiguous range of a batch handled by one worker
+type batchChunk struct {
+	start, end int
+}
+
+// EvaluateBatch evaluates multiple events using parallel batch processing.
+//
+// Batches of at least MinBatchSizeForParallelism events are split into
+// contiguous chunks evaluated by a pool of workers, each with its own batch
+// evaluator. Results are written into a pre-sized slice, so they come back
+// in input order regardless of scheduling. Smaller batches, or configs with
+// event parallelism disabled, are evaluated on the calling goroutine.
+//
+// With ParallelConfig.AutoTune, the worker count and chunk size come from
+// the evaluator's tuner, which is fed the throughput of every batch.
+func (p *ParallelDagEvaluator) EvaluateBatch(events []interface{}) ([]*DagEvaluationResult, error) {
+	for i, event := range events {
+		if !matcher.IsSupportedEvent(event) {
+			return nil, fmt.Errorf("event at index %d: %w", i, matcher.ErrUnsupportedEvent)
+		}
+	}
+
+	if !p.config.EnableEventParallelism || len(events) < p.config.MinBatchSizeForParallelism {
+		return p.evaluateSequential(events)
+	}
+
+	setting := p.setting()
+	startTime := p.clock.Now()
+	results, err := p.evaluateWorkers(events, setting)
+	if err == nil && p.tuner != nil {
+		p.tuner.record(setting, len(events), clock.Since(p.clock, startTime))
+	}
+	return results, err
+}
+
+// setting returns the worker pool setting for the next batch
+func (p *ParallelDagEvaluator) setting() parallelSetting {
+	if p.tuner != nil {
+		return p.tuner.next()
+	}
+	return parallelSetting{workers: p.config.workerCount(), chunksPerWorker: chunksPerWorker}
+}
+
+// evaluateSequential evaluates the whole batch on the calling goroutine
+func (p *ParallelDagEvaluator) evaluateSequential(events []interface{}) ([]*DagEvaluationResult, error) {
+	evaluator := NewBatchDagEvaluator(p.dag, p.primitives)
+	results, err := p.evaluateChunk(evaluator, events)
+	p.totalNodesEvaluated += evaluator.totalNodesEvaluated
+	p.totalPrimitiveEvaluations += evaluator.totalPrimitiveEvaluations
+	return results, err
+}
+
+// evaluateWorkers evaluates the batch in chunks on a pool of workers
+func (p *ParallelDagEvaluator) evaluateWorkers(events []interface{}, setting parallelSetting) ([]*DagEvaluationResult, error) {
+	workers := min(setting.workers, len(events))
+	if workers <= 1 {
+		return p.evaluateSequential(events)
+	}
+
+	chunkCount := workers * setting.chunksPerWorker
+	chunkSize := max(1, (len(events)+chunkCount-1)/chunkCount)
+	chunks := make(chan batchChunk, (len(events)+chunkSize-1)/chunkSize)
+	for start := 0; start < len(events); start += chunkSize {
+		chunks <- batchChunk{start: start, end: min(start+chunkSize, len(events))}
+	}
+	close(chunks)
+
+	results := make([]*DagEvaluationResult, len(events))
+	chunkErrors := make([]error, len(events))
+	evaluators := make([]*BatchDagEvaluator, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		evaluators[w] = NewBatchDagEvaluator(p.dag, p.primitives)
+		wg.Add(1)
+		go func(evaluator *BatchDagEvaluator) {
+			defer wg.Done()
+			for chunk := range chunks {
+				chunkResults, err := p.evaluateChunk(evaluator, events[chunk.start:chunk.end])
+				if err != nil {
+					chunkErrors[chunk.start] = err
+					continue
+				}
+				copy(results[chunk.start:chunk.end], chunkResults)
+			}
+		}(evaluators[w])
+	}
+	wg.Wait()
+
+	// Report the error of the earliest failing chunk for deterministic errors
+	for _, err := range chunkErrors {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, evaluator := range evaluators {
+		p.totalNodesEvaluated += evaluator.totalNodesEvaluated
+		p.totalPrimitiveEvaluations += evaluator.totalPrimitiveEvaluations
+	}
+	return results, nil
+}
+
+// evaluateChunk evaluates a contiguous slice of events with a batch evaluator
+func (p *ParallelDagEvaluator) evaluateChunk(evaluator *BatchDagEvaluator, events []interface{}) ([]*DagEvaluationResult, error) {
+	evaluator.options = p.options
+	return evaluator.evaluateEvents(events)
+}
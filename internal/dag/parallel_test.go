@@ -0,0 +1,128 @@
+package dag
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParallelConfigWorkerCount(t *testing.T) {
+	if count := (ParallelConfig{NumThreads: 3}).workerCount(); count != 3 {
+		t.Errorf("Expected 3 workers, got %d", count)
+	}
+	if count := (ParallelConfig{}).workerCount(); count != availableCPUs() || count > runtime.GOMAXPROCS(0) {
+		t.Errorf("Expected auto-detected %d workers, got %d", availableCPUs(), count)
+	}
+}
+
+func TestCgroupCPUQuota(t *testing.T) {
+	write := func(path, content string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// cgroup v2, with the process in a nested group
+	root := t.TempDir()
+	membership := filepath.Join(root, "self-cgroup")
+	write(membership, "0::/kubepods/pod1\n")
+	write(filepath.Join(root, "cpu.max"), "max 100000\n")
+	write(filepath.Join(root, "kubepods", "pod1", "cpu.max"), "150000 100000\n")
+	if quota := cgroupCPUQuota(root, membership); quota != 1.5 {
+		t.Errorf("Expected a quota of 1.5 CPUs, got %v", quota)
+	}
+
+	// cgroup v2 without a limit
+	root = t.TempDir()
+	write(filepath.Join(root, "cpu.max"), "max 100000\n")
+	if quota := cgroupCPUQuota(root, filepath.Join(root, "missing")); quota != 0 {
+		t.Errorf("Expected no quota, got %v", quota)
+	}
+
+	// cgroup v1
+	root = t.TempDir()
+	membership = filepath.Join(root, "self-cgroup")
+	write(membership, "4:cpu,cpuacct:/docker/abc\n3:memory:/docker/abc\n")
+	write(filepath.Join(root, "cpu,cpuacct", "docker", "abc", "cpu.cfs_quota_us"), "200000\n")
+	write(filepath.Join(root, "cpu,cpuacct", "docker", "abc", "cpu.cfs_period_us"), "100000\n")
+	if quota := cgroupCPUQuota(root, membership); quota != 2 {
+		t.Errorf("Expected a quota of 2 CPUs, got %v", quota)
+	}
+	write(filepath.Join(root, "cpu,cpuacct", "docker", "abc", "cpu.cfs_quota_us"), "-1\n")
+	if quota := cgroupCPUQuota(root, membership); quota != 0 {
+		t.Errorf("Expected no quota, got %v", quota)
+	}
+
+	for _, tc := range []struct {
+		cpus     int
+		quota    float64
+		expected int
+	}{
+		{8, 0, 8},
+		{8, 1.5, 2},
+		{8, 0.2, 1},
+		{2, 16, 2},
+	} {
+		if cpus := cpusWithin(tc.cpus, tc.quota); cpus != tc.expected {
+			t.Errorf("cpusWithin(%d, %v) = %d, expected %d", tc.cpus, tc.quota, cpus, tc.expected)
+		}
+	}
+}
+
+func TestEvaluateBatchParallelPreservesOrder(t *testing.T) {
+	config := DefaultDagEngineConfig()
+	config.EnableOptimization = false
+	config.EnablePrefilter = false
+	config.EnableParallelProcessing = true
+	config.ParallelConfig = ParallelConfig{
+		NumThreads:                 4,
+		EnableEventParallelism:     true,
+		MinBatchSizeForParallelism: 10,
+	}
+
+	engine, err := NewDagEngineFromRulesetWithConfig(createBatchTestRuleset(), config)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	events := make([]interface{}, 1000)
+	for i := range events {
+		event := map[string]interface{}{"EventID": "4624"}
+		if i%7 == 0 {
+			event["ProcessName"] = "powershell.exe"
+		}
+		events[i] = event
+	}
+
+	parallel, err := engine.EvaluateBatchParallel(events)
+	if err != nil {
+		t.Fatalf("Parallel batch evaluation failed: %v", err)
+	}
+	sequential, err := engine.EvaluateBatch(events)
+	if err != nil {
+		t.Fatalf("Batch evaluation failed: %v", err)
+	}
+
+	for i := range events {
+		if !reflect.DeepEqual(parallel[i].MatchedRules, sequential[i].MatchedRules) {
+			t.Fatalf("event %d: parallel matched %v, sequential matched %v", i, parallel[i].MatchedRules, sequential[i].MatchedRules)
+		}
+	}
+	if engine.parallelEvaluator.totalNodesEvaluated != len(events)*len(engine.dag.ExecutionOrder) {
+		t.Errorf("Unexpected node evaluation total: %d", engine.parallelEvaluator.totalNodesEvaluated)
+	}
+}
+
+func TestEvaluateBatchParallelInvalidEvent(t *testing.T) {
+	evaluator := NewParallelDagEvaluator(createTestDag(), nil, ParallelConfig{NumThreads: 2, EnableEventParallelism: true})
+	_, err := evaluator.EvaluateBatch([]interface{}{map[string]interface{}{}, "not an event"})
+	if err == nil || !strings.Contains(err.Error(), "index 1") {
+		t.Errorf("Expected error for event at index 1, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package dag
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+)
+
+// EventError is the error of one event of a batch
+type EventError struct {
+	// Position of the event in the batch
+	Index int
+	Err   error
+}
+
+func (e *EventError) Error() string {
+	return fmt.Sprintf("event at index %d: %v", e.Index, e.Err)
+}
+
+func (e *EventError) Unwrap() error {
+	return e.Err
+}
+
+// EvaluateBatchPartial evaluates a batch like EvaluateBatch, but a failing
+// event does not fail the batch. It returns a result and an error per
+// event, index-aligned with events: events that failed have a nil result
+// and an *EventError, the others a result and a nil error. Ingestion
+// pipelines can then retry or dead-letter only the failed events; failed
+// events also go to the configured DeadLetterSink.
+//
+// The batch is evaluated together first. Only when that fails are its
+// events evaluated one at a time to tell the failing events apart.
+// Actions run for the matches of the events that succeeded.
+func (e *DagEngine) EvaluateBatchPartial(events []interface{}) ([]*DagEvaluationResult, []error) {
+	results := make([]*DagEvaluationResult, len(events))
+	errs := make([]error, len(events))
+	if len(events) == 0 {
+		return results, errs
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	defer func() {
+		for i, err := range errs {
+			if err != nil {
+				e.deadLetter(events[i], DeadLetterEvaluation, err, 0)
+			}
+		}
+	}()
+
+	// Events skipped by sampling get empty results
+	batch, positions := e.sampleBatch(events)
+	if positions != nil {
+		for i := range results {
+			results[i] = NewDagEvaluationResult()
+		}
+	}
+
+	// Reject unsupported events and prepare the others one at a time, so
+	// an enricher failing on one event only fails that event
+	var valid, prepared []interface{}
+	var indexes []int
+	for i, event := range batch {
+		index := i
+		if positions != nil {
+			index = positions[i]
+		}
+		if !matcher.IsSupportedEvent(event) {
+			errs[index] = &EventError{Index: index, Err: matcher.ErrUnsupportedEvent}
+			results[index] = nil
+			continue
+		}
+		preparedEvent, err := e.prepareEvent(event)
+		if err != nil {
+			errs[index] = &EventError{Index: index, Err: err}
+			results[index] = nil
+			continue
+		}
+		valid = append(valid, event)
+		prepared = append(prepared, preparedEvent)
+		indexes = append(indexes, index)
+	}
+	if len(prepared) == 0 {
+		return results, errs
+	}
+
+	batchResults, err := e.evaluatePrepared(prepared)
+	if err != nil {
+		e.log().Debug("batch evaluation failed, evaluating its events one at a time",
+			slog.Int("events", len(prepared)), slog.Any("error", err))
+		batchResults = make([]*DagEvaluationResult, len(prepared))
+		for i, event := range prepared {
+			eventResults, err := e.evaluatePrepared([]interface{}{event})
+			if err != nil {
+				errs[indexes[i]] = &EventError{Index: indexes[i], Err: err}
+				results[indexes[i]] = nil
+				continue
+			}
+			batchResults[i] = eventResults[0]
+		}
+	}
+
+	// Observe and dispatch the events that succeeded
+	succeeded := valid[:0:0]
+	var succeededResults []*DagEvaluationResult
+	for i, result := range batchResults {
+		if result == nil {
+			continue
+		}
+		results[indexes[i]] = result
+		succeeded = append(succeeded, valid[i])
+		succeededResults = append(succeededResults, result)
+	}
+	e.observePrefilter(succeeded, succeededResults)
+	e.attachRuleUUIDs(succeededResults...)
+	e.dispatchActions(succeeded, succeededResults)
+	e.deadLetterUnreliable(succeeded, succeededResults)
+	return results, errs
+}
@@ -0,0 +1,53 @@
+package dag
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+func TestEvaluateBatchPartial(t *testing.T) {
+	good := map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"}
+	bad := map[string]interface{}{"EventID": "4624", "ProcessName": "bad.exe"}
+
+	for _, backend := range []Backend{BackendDAG, BackendVM} {
+		ruleset, strict := createFailingRuleset()
+		engine, err := NewDagEngineBuilder().
+			WithPrefilter(false).
+			WithBackend(backend).
+			WithCustomModifier("strict", strict).
+			BuildFromRuleset(ruleset)
+		if err != nil {
+			t.Fatalf("%s: failed to create engine: %v", backend, err)
+		}
+
+		results, errs := engine.EvaluateBatchPartial([]interface{}{good, bad, "EventID=4624", good})
+		if len(results) != 4 || len(errs) != 4 {
+			t.Fatalf("%s: expected 4 results and errors, got %d and %d", backend, len(results), len(errs))
+		}
+		for _, i := range []int{0, 3} {
+			if errs[i] != nil || results[i] == nil || len(results[i].MatchedRules) != 1 || results[i].MatchedRules[0] != 1 {
+				t.Errorf("%s: expected event %d to match rule 1, got %v, %v", backend, i, results[i], errs[i])
+			}
+		}
+
+		var eventErr *EventError
+		if results[1] != nil || !errors.As(errs[1], &eventErr) || eventErr.Index != 1 ||
+			!sigmaerrors.IsType(errs[1], sigmaerrors.ErrorTypeExecution) {
+			t.Errorf("%s: expected an execution error for event 1, got %v, %v", backend, results[1], errs[1])
+		}
+		if results[2] != nil || !errors.Is(errs[2], matcher.ErrUnsupportedEvent) {
+			t.Errorf("%s: expected an unsupported event error for event 2, got %v", backend, errs[2])
+		}
+
+		// Without failures the batch is evaluated together
+		results, errs = engine.EvaluateBatchPartial([]interface{}{good, good})
+		for i := range results {
+			if errs[i] != nil || len(results[i].MatchedRules) != 1 {
+				t.Errorf("%s: unexpected result for event %d: %v, %v", backend, i, results[i], errs[i])
+			}
+		}
+	}
+}
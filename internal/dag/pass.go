@@ -0,0 +1,49 @@
+package dag
+
+import "time"
+
+// Pass is a DAG optimization pass. Run may rewrite the DAG it is given in
+// place, which the optimizer owns, and reports whether it changed it.
+// Passes run before nodes are renumbered, so node IDs need not match
+// positions; the dependencies and dependents of every node must be kept
+// symmetric.
+type Pass interface {
+	Name() string
+	Run(dag *CompiledDag) (*CompiledDag, bool, error)
+}
+
+// passFunc is a pass implemented by a function
+type passFunc struct {
+	name string
+	run  func(dag *CompiledDag) (*CompiledDag, bool, error)
+}
+
+// NewPass returns a pass running the given function
+func NewPass(name string, run func(dag *CompiledDag) (*CompiledDag, bool, error)) Pass {
+	return &passFunc{name: name, run: run}
+}
+
+func (p *passFunc) Name() string {
+	return p.name
+}
+
+func (p *passFunc) Run(dag *CompiledDag) (*CompiledDag, bool, error) {
+	return p.run(dag)
+}
+
+// Names of the built-in optimization passes
+const (
+	PassConstantFolding = "constant_folding"
+	PassCSE             = "cse"
+	PassFactoring       = "factoring"
+	PassDCE             = "dce"
+)
+
+// PassStatistics describes a single run of an optimization pass
+type PassStatistics struct {
+	Name        string
+	Duration    time.Duration
+	NodesBefore int
+	NodesAfter  int
+	Changed     bool
+}
@@ -0,0 +1,134 @@
+package dag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+)
+
+// firstOperandPass points the result node of createTestDag at the first
+// operand of its AND, leaving the AND and the second primitive unused
+func firstOperandPass() Pass {
+	return NewPass("first_operand", func(dag *CompiledDag) (*CompiledDag, bool, error) {
+		result := dag.GetNode(3)
+		if len(result.Dependencies) == 1 && result.Dependencies[0] == 0 {
+			return dag, false, nil
+		}
+		and := dag.GetNode(2)
+		and.Dependents = removeNode(and.Dependents, 3)
+		result.Dependencies = []NodeId{0}
+		primitive := dag.GetNode(0)
+		primitive.Dependents = append(primitive.Dependents, 3)
+		return dag, true, nil
+	})
+}
+
+func passNames(passes []Pass) []string {
+	var names []string
+	for _, pass := range passes {
+		names = append(names, pass.Name())
+	}
+	return names
+}
+
+func TestOptimizerCustomPass(t *testing.T) {
+	optimizer := NewDagOptimizer().
+		WithFactoring(true).
+		WithPass(firstOperandPass()).
+		WithClock(clock.NewStepping(time.Time{}, time.Millisecond))
+
+	expected := []string{PassConstantFolding, PassCSE, PassFactoring, "first_operand", PassDCE}
+	if names := passNames(optimizer.Passes()); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected passes %v, got %v", expected, names)
+	}
+
+	optimized, err := optimizer.Optimize(createTestDag())
+	if err != nil {
+		t.Fatalf("Optimization failed: %v", err)
+	}
+	// Dead code elimination removes the AND and the second primitive
+	if len(optimized.Nodes) != 2 {
+		t.Errorf("Expected 2 nodes, got %d", len(optimized.Nodes))
+	}
+
+	stats := optimizer.PassStatistics()
+	if len(stats) != len(expected) {
+		t.Fatalf("Expected statistics for %d passes, got %+v", len(expected), stats)
+	}
+	for i, pass := range stats {
+		if pass.Name != expected[i] || pass.Duration != time.Millisecond {
+			t.Errorf("Unexpected statistics for pass %d: %+v", i, pass)
+		}
+	}
+	if custom := stats[3]; !custom.Changed || custom.NodesBefore != 4 || custom.NodesAfter != 4 {
+		t.Errorf("Unexpected custom pass statistics: %+v", custom)
+	}
+	if dce := stats[4]; !dce.Changed || dce.NodesBefore != 4 || dce.NodesAfter != 2 {
+		t.Errorf("Unexpected dead code elimination statistics: %+v", dce)
+	}
+	if stats[0].Changed || stats[1].Changed {
+		t.Errorf("Expected folding and CSE to leave the DAG unchanged: %+v", stats[:2])
+	}
+}
+
+func TestOptimizerReplacedPipeline(t *testing.T) {
+	optimizer := NewDagOptimizer().WithPasses(firstOperandPass())
+	optimized, err := optimizer.Optimize(createTestDag())
+	if err != nil {
+		t.Fatalf("Optimization failed: %v", err)
+	}
+	// Without dead code elimination the unused nodes remain
+	if len(optimized.Nodes) != 4 {
+		t.Errorf("Expected 4 nodes, got %d", len(optimized.Nodes))
+	}
+	if names := passNames(optimizer.Passes()); !reflect.DeepEqual(names, []string{"first_operand"}) {
+		t.Errorf("Expected only the custom pass, got %v", names)
+	}
+	if stats := optimizer.PassStatistics(); len(stats) != 1 || !stats[0].Changed {
+		t.Errorf("Unexpected statistics: %+v", stats)
+	}
+}
+
+func TestOptimizerRejectsInconsistentPass(t *testing.T) {
+	broken := NewPass("broken", func(dag *CompiledDag) (*CompiledDag, bool, error) {
+		dag.GetNode(3).Dependencies = []NodeId{0}
+		return dag, true, nil
+	})
+	_, err := NewDagOptimizer().WithPass(broken).Optimize(createTestDag())
+	if err == nil || !strings.Contains(err.Error(), "Stale dependent: 2 -> 3") {
+		t.Errorf("Expected the pass to be rejected, got %v", err)
+	}
+}
+
+func TestEngineOptimizerPasses(t *testing.T) {
+	ruleset := createTestRuleset()
+	ruleset.Dag = createTestDag()
+
+	config := DefaultDagEngineConfig()
+	config.EnablePrefilter = false
+	config.OptimizerPasses = []Pass{firstOperandPass()}
+	engine, err := NewDagEngineFromRulesetWithConfig(ruleset, config)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// Rule 1 now only requires the event ID
+	result, err := engine.Evaluate(map[string]interface{}{"EventID": "4624"})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 || result.MatchedRules[0] != 1 {
+		t.Errorf("Expected rule 1 to match, got %v", result.MatchedRules)
+	}
+
+	var names []string
+	for _, pass := range engine.OptimizerStatistics() {
+		names = append(names, pass.Name)
+	}
+	if expected := []string{PassConstantFolding, PassCSE, "first_operand", PassDCE}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected statistics of passes %v, got %v", expected, names)
+	}
+}
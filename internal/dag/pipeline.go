@@ -0,0 +1,373 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// Names of the built-in pipeline stages, in pipeline order
+const (
+	// Selects the candidate rules of the event (the anchor groups the
+	// event hits when the engine groups rules, otherwise every rule)
+	StagePrefilter = "prefilter"
+	// Matches the primitives of the candidate rules against the event
+	StagePrimitives = "primitives"
+	// Evaluates the rule logic over the primitive results
+	StageLogic = "logic"
+)
+
+// PipelineStage is a step of an evaluation pipeline. Stages run in order
+// for every event and communicate through the PipelineContext.
+type PipelineStage interface {
+	Name() string
+	Process(ctx *PipelineContext) error
+}
+
+// NewPipelineStage creates a stage from a function
+func NewPipelineStage(name string, process func(ctx *PipelineContext) error) PipelineStage {
+	return &funcStage{name: name, process: process}
+}
+
+type funcStage struct {
+	name    string
+	process func(ctx *PipelineContext) error
+}
+
+func (s *funcStage) Name() string {
+	return s.name
+}
+
+func (s *funcStage) Process(ctx *PipelineContext) error {
+	return s.process(ctx)
+}
+
+// PipelineContext is the state of one event passing through a pipeline
+type PipelineContext struct {
+	// Event being evaluated. Stages before the primitives stage may modify
+	// or replace it, e.g. to enrich it.
+	Event interface{}
+
+	// Result of the logic stage (nil before it runs). Stages after it may
+	// filter the matches with FilterMatches.
+	Result *DagEvaluationResult
+
+	engine     *DagEngine
+	eval       *DagEvaluator
+	candidates []ruleResultNode
+	restricted bool
+	stopped    bool
+}
+
+// Stop ends the pipeline for the event after the current stage. The event
+// yields the result so far, no matches when the logic stage has not run.
+func (ctx *PipelineContext) Stop() {
+	ctx.stopped = true
+}
+
+// CandidateRules returns the rules the event is evaluated against, or nil
+// when it is evaluated against every rule
+func (ctx *PipelineContext) CandidateRules() []ir.RuleID {
+	if !ctx.restricted {
+		return nil
+	}
+	ruleIds := make([]ir.RuleID, len(ctx.candidates))
+	for i, rule := range ctx.candidates {
+		ruleIds[i] = rule.ruleId
+	}
+	return ruleIds
+}
+
+// RestrictRules keeps the candidate rules for which keep reports true, e.g.
+// the rules of the event's tenant. Stages after the logic stage filter
+// matches with FilterMatches instead.
+func (ctx *PipelineContext) RestrictRules(keep func(ruleId ir.RuleID) bool) {
+	if !ctx.restricted {
+		ctx.candidates = make([]ruleResultNode, 0, len(ctx.engine.dag.RuleResults))
+		for _, ruleId := range sortedRuleIDs(ctx.engine.dag.RuleResults) {
+			ctx.candidates = append(ctx.candidates, ruleResultNode{ruleId: ruleId, nodeId: ctx.engine.dag.RuleResults[ruleId]})
+		}
+		ctx.restricted = true
+	}
+	kept := ctx.candidates[:0]
+	for _, rule := range ctx.candidates {
+		if keep(rule.ruleId) {
+			kept = append(kept, rule)
+		}
+	}
+	ctx.candidates = kept
+}
+
+// PrimitiveResult returns whether a primitive matched the event, and
+// whether it has been matched yet
+func (ctx *PipelineContext) PrimitiveResult(primitiveId uint32) (matched, evaluated bool) {
+	if !ctx.eval.primitiveEvaluated.Test(primitiveId) {
+		return false, false
+	}
+	return ctx.eval.primitiveResults.Test(primitiveId), true
+}
+
+// FilterMatches keeps the matched rules for which keep reports true,
+// together with their match details
+func (ctx *PipelineContext) FilterMatches(keep func(ruleId ir.RuleID) bool) {
+	if ctx.Result == nil {
+		return
+	}
+	matched := ctx.Result.MatchedRules[:0]
+	for _, ruleId := range ctx.Result.MatchedRules {
+		if keep(ruleId) {
+			matched = append(matched, ruleId)
+		}
+	}
+	ctx.Result.MatchedRules = matched
+
+	details := ctx.Result.RuleMatches[:0]
+	for _, detail := range ctx.Result.RuleMatches {
+		if keep(detail.RuleID) {
+			details = append(details, detail)
+		}
+	}
+	ctx.Result.RuleMatches = details
+}
+
+// Pipeline evaluates events through a sequence of stages: the built-in
+// prefilter, primitives and logic stages, and any custom stages inserted
+// between or after them (e.g. enrichment before the primitives stage, or
+// tenant-specific post-filters after the logic stage).
+//
+// Stages run under the engine lock, so they must not evaluate events with
+// the engine themselves. Splitting primitive matching from the logic
+// matches every primitive of the candidate rules, where Evaluate skips the
+// primitives that cannot change a rule's result.
+type Pipeline struct {
+	engine *DagEngine
+	stages []PipelineStage
+}
+
+// NewPipeline creates a pipeline with the built-in stages. Only the DAG
+// backend supports pipelines.
+func (e *DagEngine) NewPipeline() (*Pipeline, error) {
+	if e.backend != nil {
+		return nil, fmt.Errorf("evaluation pipelines are not supported by the %s backend", e.backend.Backend())
+	}
+	return &Pipeline{
+		engine: e,
+		stages: []PipelineStage{
+			NewPipelineStage(StagePrefilter, prefilterStage),
+			NewPipelineStage(StagePrimitives, primitivesStage),
+			NewPipelineStage(StageLogic, logicStage),
+		},
+	}, nil
+}
+
+// Stages returns the names of the stages in order
+func (p *Pipeline) Stages() []string {
+	names := make([]string, len(p.stages))
+	for i, stage := range p.stages {
+		names[i] = stage.Name()
+	}
+	return names
+}
+
+// InsertBefore inserts a stage before the named stage
+func (p *Pipeline) InsertBefore(name string, stage PipelineStage) error {
+	position, err := p.position(name)
+	if err != nil {
+		return err
+	}
+	p.stages = append(p.stages[:position], append([]PipelineStage{stage}, p.stages[position:]...)...)
+	return nil
+}
+
+// InsertAfter inserts a stage after the named stage
+func (p *Pipeline) InsertAfter(name string, stage PipelineStage) error {
+	position, err := p.position(name)
+	if err != nil {
+		return err
+	}
+	p.stages = append(p.stages[:position+1], append([]PipelineStage{stage}, p.stages[position+1:]...)...)
+	return nil
+}
+
+// Append adds a stage at the end of the pipeline
+func (p *Pipeline) Append(stage PipelineStage) {
+	p.stages = append(p.stages, stage)
+}
+
+// Remove removes the named stage
+func (p *Pipeline) Remove(name string) error {
+	position, err := p.position(name)
+	if err != nil {
+		return err
+	}
+	p.stages = append(p.stages[:position], p.stages[position+1:]...)
+	return nil
+}
+
+// position returns the position of the first stage with the given name
+func (p *Pipeline) position(name string) (int, error) {
+	for i, stage := range p.stages {
+		if stage.Name() == name {
+			return i, nil
+		}
+	}
+	return 0, errors.NewConfigError(fmt.Sprintf("unknown pipeline stage %q", name))
+}
+
+// Evaluate passes an event through the stages and returns its matches
+func (p *Pipeline) Evaluate(event interface{}) (*DagEvaluationResult, error) {
+	e := p.engine
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !matcher.IsSupportedEvent(event) {
+		return nil, matcher.ErrUnsupportedEvent
+	}
+	if !e.admitEvent(event) {
+		return NewDagEvaluationResult(), nil
+	}
+	if e.evaluator == nil {
+		e.evaluator = e.newEvaluator()
+	}
+	e.evaluator.reset()
+	defer func() { e.evaluator.eventCtx = nil }()
+
+	prepared, err := e.prepareEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	ctx := &PipelineContext{Event: prepared, engine: e, eval: e.evaluator}
+	for _, stage := range p.stages {
+		if err := stage.Process(ctx); err != nil {
+			return nil, fmt.Errorf("pipeline stage %s: %w", stage.Name(), err)
+		}
+		if ctx.stopped {
+			break
+		}
+	}
+
+	result := ctx.Result
+	if result == nil {
+		result = NewDagEvaluationResult()
+	}
+	e.attachRuleUUIDs(result)
+	e.dispatchActions([]interface{}{event}, []*DagEvaluationResult{result})
+	e.deadLetterUnreliable([]interface{}{event}, []*DagEvaluationResult{result})
+	return result, nil
+}
+
+// prefilterStage narrows the candidate rules to the anchor groups the
+// event hits, and ends the pipeline when no candidate is left
+func prefilterStage(ctx *PipelineContext) error {
+	if ctx.engine.ruleGroups == nil {
+		return nil
+	}
+	candidates, ok := ctx.engine.ruleGroups.candidatesFor(ctx.Event)
+	if !ok {
+		return nil
+	}
+	if !ctx.restricted {
+		ctx.candidates = append([]ruleResultNode(nil), candidates...)
+		ctx.restricted = true
+	} else {
+		// Both lists are in rule ID order
+		kept := ctx.candidates[:0]
+		j := 0
+		for _, rule := range ctx.candidates {
+			for j < len(candidates) && candidates[j].ruleId < rule.ruleId {
+				j++
+			}
+			if j < len(candidates) && candidates[j].ruleId == rule.ruleId {
+				kept = append(kept, rule)
+			}
+		}
+		ctx.candidates = kept
+	}
+	if len(ctx.candidates) == 0 {
+		ctx.Stop()
+	}
+	return nil
+}
+
+// primitivesStage matches the primitives of the candidate rules, caching
+// their results for the logic stage
+func primitivesStage(ctx *PipelineContext) error {
+	eval := ctx.eval
+	eval.eventCtx = newEventContext(ctx.Event, eval.eventTimeout, eval.clock, eval.foldFieldCase)
+	for _, primitiveId := range ctx.primitiveIDs() {
+		if _, err := eval.evaluatePrimitiveCached(primitiveId, ctx.Event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// primitiveIDs returns the primitives the candidate rules depend on, in
+// order
+func (ctx *PipelineContext) primitiveIDs() []ir.PrimitiveID {
+	dag := ctx.engine.dag
+	seen := make(map[ir.PrimitiveID]bool)
+	var ids []ir.PrimitiveID
+	add := func(node *DagNode) {
+		if node.NodeType.Type == "Primitive" && node.NodeType.PrimitiveId != nil && !seen[*node.NodeType.PrimitiveId] {
+			seen[*node.NodeType.PrimitiveId] = true
+			ids = append(ids, *node.NodeType.PrimitiveId)
+		}
+	}
+
+	if !ctx.restricted {
+		for i := range dag.Nodes {
+			add(&dag.Nodes[i])
+		}
+	} else {
+		visited := make(map[NodeId]bool)
+		stack := make([]NodeId, 0, len(ctx.candidates))
+		for _, rule := range ctx.candidates {
+			stack = append(stack, rule.nodeId)
+		}
+		for len(stack) > 0 {
+			nodeId := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			node := dag.GetNode(nodeId)
+			if visited[nodeId] || node == nil {
+				continue
+			}
+			visited[nodeId] = true
+			add(node)
+			stack = append(stack, dag.DependenciesOf(nodeId)...)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// logicStage evaluates the candidate rules over the primitive results
+func logicStage(ctx *PipelineContext) error {
+	eval := ctx.eval
+	if eval.eventCtx == nil {
+		eval.eventCtx = newEventContext(ctx.Event, eval.eventTimeout, eval.clock, eval.foldFieldCase)
+	}
+
+	result := NewDagEvaluationResult()
+	if ctx.restricted {
+		// Candidates are evaluated completely when matches are collected, so
+		// details match those of unrestricted evaluation
+		err := eval.evaluateRulesOnDemand(ctx.Event, ctx.candidates, func([]ir.RuleID) bool { return false }, eval.collectsMatches(), result)
+		if err != nil {
+			return err
+		}
+	} else {
+		if err := eval.evaluateAllNodes(ctx.Event, result); err != nil {
+			return err
+		}
+		result.PrimitiveErrors = eval.eventCtx.RecoveredErrors()
+	}
+	if eval.collectsMatches() && len(result.MatchedRules) > 0 {
+		result.RuleMatches = eval.collectRuleMatches(result.MatchedRules)
+	}
+	ctx.Result = result
+	return nil
+}
@@ -0,0 +1,160 @@
+package dag
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+func newPipelineTestEngine(t *testing.T, builder *DagEngineBuilder) *DagEngine {
+	engine, err := builder.WithOptimization(false).BuildFromRuleset(createRawPrefilterRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return engine
+}
+
+func sortedMatches(result *DagEvaluationResult) []ir.RuleID {
+	matched := append([]ir.RuleID{}, result.MatchedRules...)
+	sort.Slice(matched, func(i, j int) bool { return matched[i] < matched[j] })
+	return matched
+}
+
+func TestPipelineDefaultStages(t *testing.T) {
+	events := []map[string]interface{}{
+		{"EventID": 4624, "CommandLine": "powershell -enc SQBFAFgA", "Image": "lsass.exe"},
+		{"Image": `C:\Tools\mimikatz.exe`},
+		{"EventID": 4624, "CommandLine": "notepad.exe"},
+	}
+	for _, grouping := range []bool{false, true} {
+		engine := newPipelineTestEngine(t, NewDagEngineBuilder().WithRuleGrouping(grouping))
+		pipeline, err := engine.NewPipeline()
+		if err != nil {
+			t.Fatalf("Failed to create pipeline: %v", err)
+		}
+		if stages := pipeline.Stages(); !reflect.DeepEqual(stages, []string{StagePrefilter, StagePrimitives, StageLogic}) {
+			t.Errorf("Unexpected stages %v", stages)
+		}
+
+		for _, event := range events {
+			expected, err := engine.Evaluate(event)
+			if err != nil {
+				t.Fatalf("Evaluation failed: %v", err)
+			}
+			result, err := pipeline.Evaluate(event)
+			if err != nil {
+				t.Fatalf("Pipeline evaluation failed: %v", err)
+			}
+			if !reflect.DeepEqual(sortedMatches(result), sortedMatches(expected)) {
+				t.Errorf("grouping=%v, event %v: expected %v, pipeline matched %v",
+					grouping, event, expected.MatchedRules, result.MatchedRules)
+			}
+		}
+	}
+}
+
+func TestPipelineCustomStages(t *testing.T) {
+	engine := newPipelineTestEngine(t, NewDagEngineBuilder())
+	pipeline, err := engine.NewPipeline()
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+
+	// Enrichment between the prefilter and the primitives
+	err = pipeline.InsertBefore(StagePrimitives, NewPipelineStage("enrich", func(ctx *PipelineContext) error {
+		event := ctx.Event.(map[string]interface{})
+		if event["Process"] == "credential-dump" {
+			event["Image"] = "lsass.exe"
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("Failed to insert stage: %v", err)
+	}
+	// Tenant b only runs rule 1
+	err = pipeline.InsertAfter(StagePrefilter, NewPipelineStage("tenant", func(ctx *PipelineContext) error {
+		if ctx.Event.(map[string]interface{})["Tenant"] == "b" {
+			ctx.RestrictRules(func(ruleId ir.RuleID) bool { return ruleId == 1 })
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("Failed to insert stage: %v", err)
+	}
+	var primitiveMatched bool
+	pipeline.Append(NewPipelineStage("suppress", func(ctx *PipelineContext) error {
+		primitiveMatched, _ = ctx.PrimitiveResult(3)
+		if ctx.Event.(map[string]interface{})["Suppress"] == true {
+			ctx.FilterMatches(func(ruleId ir.RuleID) bool { return ruleId != 2 })
+		}
+		return nil
+	}))
+	expectedStages := []string{StagePrefilter, "tenant", "enrich", StagePrimitives, StageLogic, "suppress"}
+	if stages := pipeline.Stages(); !reflect.DeepEqual(stages, expectedStages) {
+		t.Errorf("Expected stages %v, got %v", expectedStages, stages)
+	}
+
+	testCases := []struct {
+		event    map[string]interface{}
+		expected []ir.RuleID
+	}{
+		{map[string]interface{}{"Process": "credential-dump"}, []ir.RuleID{2}},
+		{map[string]interface{}{"Process": "credential-dump", "Tenant": "b"}, []ir.RuleID{}},
+		{map[string]interface{}{"Image": "lsass.exe", "Suppress": true}, []ir.RuleID{}},
+	}
+	for _, tc := range testCases {
+		result, err := pipeline.Evaluate(tc.event)
+		if err != nil {
+			t.Fatalf("Pipeline evaluation failed: %v", err)
+		}
+		if !reflect.DeepEqual(sortedMatches(result), tc.expected) {
+			t.Errorf("Event %v: expected %v, got %v", tc.event, tc.expected, result.MatchedRules)
+		}
+	}
+	if !primitiveMatched {
+		t.Error("Expected post-filters to see the primitive results")
+	}
+
+	if err := pipeline.InsertBefore("missing", NewPipelineStage("x", nil)); err == nil {
+		t.Error("Expected an error inserting before an unknown stage")
+	} else {
+		var sigmaErr *sigmaerrors.SigmaError
+		if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeConfig {
+			t.Errorf("Expected a configuration error, got %v", err)
+		}
+	}
+}
+
+func TestPipelineStop(t *testing.T) {
+	engine := newPipelineTestEngine(t, NewDagEngineBuilder())
+	pipeline, err := engine.NewPipeline()
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	if err := pipeline.InsertBefore(StagePrimitives, NewPipelineStage("drop", func(ctx *PipelineContext) error {
+		ctx.Stop()
+		return nil
+	})); err != nil {
+		t.Fatalf("Failed to insert stage: %v", err)
+	}
+
+	result, err := pipeline.Evaluate(map[string]interface{}{"Image": "lsass.exe"})
+	if err != nil {
+		t.Fatalf("Pipeline evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 0 || result.PrimitiveEvaluations != 0 {
+		t.Errorf("Expected a stopped event to match nothing, got %+v", result)
+	}
+
+	if err := pipeline.Remove("drop"); err != nil {
+		t.Fatalf("Failed to remove stage: %v", err)
+	}
+	result, err = pipeline.Evaluate(map[string]interface{}{"Image": "lsass.exe"})
+	if err != nil || len(result.MatchedRules) != 1 {
+		t.Errorf("Expected rule 2 to match once the stage is removed, got %v (%v)", result, err)
+	}
+}
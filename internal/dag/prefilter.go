@@ -0,0 +1,299 @@
+package dag
+
+import "unsafe"
+
+// PrefilterStrategy selects how the literal prefilter looks up event values
+// among the literal patterns of the ruleset
+type PrefilterStrategy int
+
+const (
+	// PrefilterAuto picks a strategy by pattern count: a hash set for small
+	// sets, Aho-Corasick above autoAhoCorasickPatterns patterns and a Bloom
+	// filter above autoBloomPatterns
+	PrefilterAuto PrefilterStrategy = iota
+	// PrefilterNone builds no prefilter, like disabling EnablePrefilter
+	PrefilterNone
+	// PrefilterHashSet passes events with a field value equal to a pattern
+	PrefilterHashSet
+	// PrefilterAhoCorasick passes events with a field value containing a
+	// pattern, ignoring ASCII case, in a single pass over each value
+	PrefilterAhoCorasick
+	// PrefilterBloom passes events with a field value that may equal a
+	// pattern. It holds very large pattern sets in a fraction of the memory
+	// of a hash set, at the cost of passing some events by false positive.
+	PrefilterBloom
+)
+
+var prefilterStrategyNames = map[PrefilterStrategy]string{
+	PrefilterAuto:        "auto",
+	PrefilterNone:        "none",
+	PrefilterHashSet:     "hash_set",
+	PrefilterAhoCorasick: "aho_corasick",
+	PrefilterBloom:       "bloom",
+}
+
+func (strategy PrefilterStrategy) String() string {
+	if name, exists := prefilterStrategyNames[strategy]; exists {
+		return name
+	}
+	return "auto"
+}
+
+const (
+	// autoAhoCorasickPatterns is the largest pattern set PrefilterAuto
+	// looks up in a hash set
+	autoAhoCorasickPatterns = 100
+	// autoBloomPatterns is the largest pattern set PrefilterAuto searches
+	// with Aho-Corasick
+	autoBloomPatterns = 100000
+)
+
+// resolvePrefilterStrategy returns the strategy PrefilterAuto stands for
+// with the given number of patterns, and any other strategy as is
+func resolvePrefilterStrategy(strategy PrefilterStrategy, patternCount int) PrefilterStrategy {
+	if strategy != PrefilterAuto {
+		return strategy
+	}
+	switch {
+	case patternCount > autoBloomPatterns:
+		return PrefilterBloom
+	case patternCount > autoAhoCorasickPatterns:
+		return PrefilterAhoCorasick
+	default:
+		return PrefilterHashSet
+	}
+}
+
+// prefilterIndex looks up event values among the prefilter patterns
+type prefilterIndex interface {
+	// matches reports whether an event value passes the prefilter
+	matches(value string) bool
+
+	// strategy is the implementation of the index
+	strategy() PrefilterStrategy
+
+	// memoryBytes estimates the memory of the index beyond the pattern set
+	memoryBytes() int
+}
+
+// newPrefilterIndex builds the index of a resolved strategy
+func newPrefilterIndex(strategy PrefilterStrategy, patterns map[string]bool) prefilterIndex {
+	switch strategy {
+	case PrefilterAhoCorasick:
+		return newAhoCorasick(patterns)
+	case PrefilterBloom:
+		filter := newBloomFilter(len(patterns))
+		for pattern := range patterns {
+			filter.add(pattern)
+		}
+		return filter
+	default:
+		return hashSetIndex(patterns)
+	}
+}
+
+// hashSetIndex looks up exact values in the pattern set itself
+type hashSetIndex map[string]bool
+
+func (index hashSetIndex) matches(value string) bool {
+	return index[value]
+}
+
+func (index hashSetIndex) strategy() PrefilterStrategy {
+	return PrefilterHashSet
+}
+
+func (index hashSetIndex) memoryBytes() int {
+	return 0
+}
+
+// ahoCorasick is an Aho-Corasick automaton over ASCII-lowercased patterns.
+// Transitions are sparse, so the automaton stays proportional to the total
+// pattern length.
+type ahoCorasick struct {
+	states []acState
+}
+
+type acState struct {
+	next map[byte]int32
+	fail int32
+	// A pattern ends here or at a state on the failure chain
+	output bool
+}
+
+func newAhoCorasick(patterns map[string]bool) *ahoCorasick {
+	ac := &ahoCorasick{states: []acState{{}}}
+	for pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		state := int32(0)
+		for i := 0; i < len(pattern); i++ {
+			c := lowerASCII(pattern[i])
+			next, exists := ac.states[state].next[c]
+			if !exists {
+				next = int32(len(ac.states))
+				ac.states = append(ac.states, acState{})
+				if ac.states[state].next == nil {
+					ac.states[state].next = make(map[byte]int32)
+				}
+				ac.states[state].next[c] = next
+			}
+			state = next
+		}
+		ac.states[state].output = true
+	}
+
+	// Failure links, breadth first so shorter suffixes are linked first
+	queue := make([]int32, 0, len(ac.states))
+	for _, child := range ac.states[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for c, child := range ac.states[state].next {
+			fail := ac.states[state].fail
+			for {
+				if next, exists := ac.states[fail].next[c]; exists && next != child {
+					ac.states[child].fail = next
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = ac.states[fail].fail
+			}
+			ac.states[child].output = ac.states[child].output || ac.states[ac.states[child].fail].output
+			queue = append(queue, child)
+		}
+	}
+	return ac
+}
+
+func (ac *ahoCorasick) matches(value string) bool {
+	state := int32(0)
+	for i := 0; i < len(value); i++ {
+		c := lowerASCII(value[i])
+		for {
+			if next, exists := ac.states[state].next[c]; exists {
+				state = next
+				break
+			}
+			if state == 0 {
+				break
+			}
+			state = ac.states[state].fail
+		}
+		if ac.states[state].output {
+			return true
+		}
+	}
+	return false
+}
+
+func (ac *ahoCorasick) strategy() PrefilterStrategy {
+	return PrefilterAhoCorasick
+}
+
+func (ac *ahoCorasick) memoryBytes() int {
+	size := len(ac.states) * int(unsafe.Sizeof(acState{}))
+	for i := range ac.states {
+		size += len(ac.states[i].next) * (int(unsafe.Sizeof(int32(0))) + 1 + mapEntryOverhead)
+	}
+	return size
+}
+
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
+
+const (
+	// bloomBitsPerPattern and bloomHashes give a false positive rate of
+	// about 1%
+	bloomBitsPerPattern = 10
+	bloomHashes         = 7
+)
+
+// bloomFilter is a Bloom filter over strings, using double hashing of a
+// 64-bit FNV-1a hash
+type bloomFilter struct {
+	bits  []uint64
+	size  uint64
+	count int
+}
+
+// newBloomFilter sizes a filter for the expected number of strings
+func newBloomFilter(expected int) *bloomFilter {
+	size := uint64(max(expected, 1) * bloomBitsPerPattern)
+	return &bloomFilter{bits: make([]uint64, (size+63)/64), size: size}
+}
+
+func (f *bloomFilter) add(s string) {
+	f.addHash(fnv64a(s))
+}
+
+// addHash adds a string by its FNV-1a hash
+func (f *bloomFilter) addHash(h uint64) {
+	h2 := bloomSecondHash(h)
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h + i*h2) % f.size
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+	f.count++
+}
+
+// mayContain reports whether s may have been added; false is definite
+func (f *bloomFilter) mayContain(s string) bool {
+	return f.mayContainHash(fnv64a(s))
+}
+
+// mayContainHash reports whether a string with the given FNV-1a hash may
+// have been added
+func (f *bloomFilter) mayContainHash(h uint64) bool {
+	h2 := bloomSecondHash(h)
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h + i*h2) % f.size
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) matches(value string) bool {
+	return f.mayContain(value)
+}
+
+func (f *bloomFilter) strategy() PrefilterStrategy {
+	return PrefilterBloom
+}
+
+func (f *bloomFilter) memoryBytes() int {
+	return int(unsafe.Sizeof(*f)) + len(f.bits)*8
+}
+
+// FNV-1a parameters, also used to hash event tokens incrementally
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+func fnv64a(s string) uint64 {
+	h := uint64(fnvOffset64)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+// bloomSecondHash derives the second hash of double hashing from the
+// first. It is odd so the probes of a string never collapse onto a single
+// bit of an even-sized filter.
+func bloomSecondHash(h uint64) uint64 {
+	return (h>>33 | h<<31) | 1
+}
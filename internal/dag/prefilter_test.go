@@ -0,0 +1,119 @@
+package dag
+
+import (
+	"fmt"
+	"testing"
+
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+func prefilterTestPrimitives() []Primitive {
+	return []Primitive{
+		{ID: 0, Field: "EventID", MatchType: "equals", Values: []string{"4624"}},
+		{ID: 1, Field: "CommandLine", MatchType: "contains", Values: []string{"Invoke-Mimikatz", "sekurlsa"}},
+		{ID: 2, Field: "Command", MatchType: "regex", Values: []string{"test.*"}},
+	}
+}
+
+func TestPrefilterStrategies(t *testing.T) {
+	testCases := []struct {
+		strategy PrefilterStrategy
+		event    map[string]interface{}
+		expected bool
+	}{
+		{PrefilterHashSet, map[string]interface{}{"EventID": 4624}, true},
+		{PrefilterHashSet, map[string]interface{}{"CommandLine": "powershell Invoke-Mimikatz"}, false},
+		{PrefilterAhoCorasick, map[string]interface{}{"CommandLine": "powershell invoke-MIMIKATZ -dump"}, true},
+		{PrefilterAhoCorasick, map[string]interface{}{"CommandLine": "mimikatz::sekurls"}, false},
+		{PrefilterAhoCorasick, map[string]interface{}{"Id": "44624"}, true},
+		{PrefilterBloom, map[string]interface{}{"CommandLine": "sekurlsa"}, true},
+		{PrefilterBloom, map[string]interface{}{"CommandLine": "notepad.exe"}, false},
+	}
+
+	for _, tc := range testCases {
+		prefilter, err := NewLiteralPrefilterWithStrategy(prefilterTestPrimitives(), tc.strategy)
+		if err != nil {
+			t.Fatalf("Failed to create %s prefilter: %v", tc.strategy, err)
+		}
+		if name := prefilter.Stats().StrategyName; name != tc.strategy.String() {
+			t.Errorf("Expected strategy name %s, got %s", tc.strategy, name)
+		}
+		if matched, _ := prefilter.Matches(tc.event); matched != tc.expected {
+			t.Errorf("Expected %s prefilter to return %v for %v", tc.strategy, tc.expected, tc.event)
+		}
+	}
+
+	if prefilter, err := NewLiteralPrefilterWithStrategy(prefilterTestPrimitives(), PrefilterNone); prefilter != nil || err != nil {
+		t.Errorf("Expected no prefilter for PrefilterNone, got %v, %v", prefilter, err)
+	}
+	if _, err := NewLiteralPrefilterWithStrategy(nil, PrefilterStrategy(42)); !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeConfig) {
+		t.Errorf("Expected a config error for an unknown strategy, got %v", err)
+	}
+}
+
+func TestAhoCorasickOverlappingPatterns(t *testing.T) {
+	ac := newAhoCorasick(map[string]bool{"he": true, "she": true, "hers": true, "abcd": true, "bc": true})
+	for value, expected := range map[string]bool{
+		"ushers": true,
+		"xhex":   true,
+		"abx":    false,
+		"xabcx":  true, // "bc" is found on the failure link of "abc"
+		"shx":    false,
+		"":       false,
+	} {
+		if ac.matches(value) != expected {
+			t.Errorf("Expected matches(%q) to be %v", value, expected)
+		}
+	}
+}
+
+func TestBloomFilterFalsePositiveRate(t *testing.T) {
+	filter := newBloomFilter(10000)
+	for i := 0; i < 10000; i++ {
+		filter.add(fmt.Sprintf("pattern-%d", i))
+	}
+	for i := 0; i < 10000; i++ {
+		if !filter.mayContain(fmt.Sprintf("pattern-%d", i)) {
+			t.Fatalf("Expected pattern-%d to be found", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.mayContain(fmt.Sprintf("other-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("Expected about 1%% false positives, got %d in 10000", falsePositives)
+	}
+}
+
+func TestEnginePrefilterStrategy(t *testing.T) {
+	for _, strategy := range []PrefilterStrategy{PrefilterHashSet, PrefilterAhoCorasick, PrefilterBloom} {
+		ruleset := createTestRuleset()
+		ruleset.Dag = createTestDag()
+		engine, err := NewDagEngineBuilder().WithPrefilterStrategy(strategy).BuildFromRuleset(ruleset)
+		if err != nil {
+			t.Fatalf("Failed to create engine: %v", err)
+		}
+		if name := engine.PrefilterStats().StrategyName; name != strategy.String() {
+			t.Errorf("Expected strategy %s, got %s", strategy, name)
+		}
+		if report := engine.Verify(); !report.OK() {
+			t.Errorf("Expected the %s prefilter to verify, got %v", strategy, report.Issues)
+		}
+		if engine.MemoryUsage().PrefilterBytes <= 0 {
+			t.Errorf("Expected the %s prefilter memory to be estimated", strategy)
+		}
+	}
+
+	ruleset := createTestRuleset()
+	ruleset.Dag = createTestDag()
+	engine, err := NewDagEngineBuilder().WithPrefilterStrategy(PrefilterNone).BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if stats := engine.PrefilterStats(); stats != nil {
+		t.Errorf("Expected no prefilter, got %+v", stats)
+	}
+}
@@ -0,0 +1,132 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+)
+
+// PriorityLimits bounds EvaluatePriority. Without limits every rule is
+// evaluated, in priority order.
+type PriorityLimits struct {
+	// Stop after this many matched rules (0 = no limit)
+	MaxMatches int
+
+	// Stop once the summed severity of the matched rules reaches the budget
+	// (0 = no budget), see RuleLevel.Severity
+	SeverityBudget int
+}
+
+// Severity weighs a rule level for severity budgets: 1 for informational
+// up to 5 for critical, and 0 for rules without a level
+func (level RuleLevel) Severity() int {
+	if _, exists := ruleLevelNames[level]; !exists {
+		return 0
+	}
+	return int(level)
+}
+
+// priorityOrder orders the DAG's rules from the most to the least severe
+// level, rules without a level last and ties by rule ID
+func priorityOrder(dag *CompiledDag, rules map[ir.RuleID]RuleMeta) []ruleResultNode {
+	order := make([]ruleResultNode, 0, len(dag.RuleResults))
+	for ruleId, nodeId := range dag.RuleResults {
+		order = append(order, ruleResultNode{ruleId: ruleId, nodeId: nodeId})
+	}
+	sort.Slice(order, func(i, j int) bool {
+		left, right := rules[order[i].ruleId].Level.Severity(), rules[order[j].ruleId].Level.Severity()
+		if left != right {
+			return left > right
+		}
+		return order[i].ruleId < order[j].ruleId
+	})
+	return order
+}
+
+// EvaluatePriority evaluates an event's rules from the most to the least
+// severe level and stops as soon as the limits are met, trading complete
+// attribution for response time (e.g. for inline blocking decisions).
+// Critical rules are evaluated first and rules without a level last.
+// Matched rules are reported in evaluation order. Only the DAG backend
+// supports priority evaluation.
+func (e *DagEngine) EvaluatePriority(event interface{}, limits PriorityLimits) (*DagEvaluationResult, error) {
+	if e.backend != nil {
+		return nil, fmt.Errorf("priority evaluation is not supported by the %s backend", e.backend.Backend())
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !matcher.IsSupportedEvent(event) {
+		return nil, matcher.ErrUnsupportedEvent
+	}
+	if !e.admitEvent(event) {
+		return NewDagEvaluationResult(), nil
+	}
+	if e.priorityOrder == nil {
+		e.priorityOrder = priorityOrder(e.dag, e.rules)
+	}
+	if e.evaluator == nil {
+		e.evaluator = e.newEvaluator()
+	}
+
+	severity := 0
+	prepared, err := e.prepareEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	result, err := e.evaluator.evaluateInOrder(prepared, e.priorityOrder, func(matched []ir.RuleID) bool {
+		severity += e.rules[matched[len(matched)-1]].Level.Severity()
+		return (limits.MaxMatches > 0 && len(matched) >= limits.MaxMatches) ||
+			(limits.SeverityBudget > 0 && severity >= limits.SeverityBudget)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	e.attachRuleUUIDs(result)
+	e.dispatchActions([]interface{}{event}, []*DagEvaluationResult{result})
+	e.deadLetterUnreliable([]interface{}{event}, []*DagEvaluationResult{result})
+	return result, nil
+}
+
+// evaluateInOrder evaluates rules in the given order until stop reports
+// true, attaching match details for the matched rules when enabled. Rules
+// skipped after stop are not evaluated; the evaluated rules are evaluated
+// completely when details are enabled, so their details are those of full
+// evaluation.
+func (eval *DagEvaluator) evaluateInOrder(
+	event interface{},
+	rules []ruleResultNode,
+	stop func(matched []ir.RuleID) bool,
+) (*DagEvaluationResult, error) {
+	result := NewDagEvaluationResult()
+	if err := eval.evaluateInOrderInto(event, rules, stop, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// evaluateInOrderInto is evaluateInOrder into a caller-provided result,
+// reusing the capacity of its slices like EvaluateInto
+func (eval *DagEvaluator) evaluateInOrderInto(
+	event interface{},
+	rules []ruleResultNode,
+	stop func(matched []ir.RuleID) bool,
+	result *DagEvaluationResult,
+) error {
+	eval.eventCtx = newEventContext(event, eval.eventTimeout, eval.clock, eval.foldFieldCase)
+	defer func() { eval.eventCtx = nil }()
+
+	eval.reset()
+	result.reset()
+	if err := eval.evaluateRulesOnDemand(event, rules, stop, eval.collectsMatches(), result); err != nil {
+		return err
+	}
+	if eval.collectsMatches() && len(result.MatchedRules) > 0 {
+		result.RuleMatches = eval.collectRuleMatches(result.MatchedRules)
+	}
+	return nil
+}
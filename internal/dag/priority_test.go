@@ -0,0 +1,82 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+// leveledRuleset creates one rule per level, all matching EventID 4624
+func leveledRuleset(levels ...RuleLevel) *CompiledRuleset {
+	ruleset := createTestRuleset()
+	dag := NewCompiledDag()
+	dag.Nodes = append(dag.Nodes, *NewDagNode(0, NewPrimitiveNodeType(0)))
+	dag.PrimitiveMap[0] = 0
+	dag.ExecutionOrder = []NodeId{0}
+
+	for i, level := range levels {
+		ruleId := ir.RuleID(i + 1)
+		nodeId := NodeId(len(dag.Nodes))
+		result := NewDagNode(nodeId, NewResultNodeType(ruleId))
+		result.Dependencies = []NodeId{0}
+		dag.Nodes[0].Dependents = append(dag.Nodes[0].Dependents, nodeId)
+		dag.Nodes = append(dag.Nodes, *result)
+		dag.RuleResults[ruleId] = nodeId
+		dag.ExecutionOrder = append(dag.ExecutionOrder, nodeId)
+		ruleset.Rules = append(ruleset.Rules, RuleMeta{ID: ruleId, Level: level})
+	}
+	dag.ResultBufferSize = len(dag.Nodes)
+	ruleset.Dag = dag
+	return ruleset
+}
+
+func TestEvaluatePriority(t *testing.T) {
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithPrefilter(false).
+		BuildFromRuleset(leveledRuleset(LevelLow, LevelCritical, LevelUnknown, LevelHigh))
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	event := map[string]interface{}{"EventID": "4624"}
+
+	tests := []struct {
+		name     string
+		limits   PriorityLimits
+		expected []ir.RuleID
+	}{
+		{"unlimited", PriorityLimits{}, []ir.RuleID{2, 4, 1, 3}},
+		{"max matches", PriorityLimits{MaxMatches: 2}, []ir.RuleID{2, 4}},
+		{"severity budget", PriorityLimits{SeverityBudget: 9}, []ir.RuleID{2, 4}},
+		{"budget above total", PriorityLimits{SeverityBudget: 100}, []ir.RuleID{2, 4, 1, 3}},
+		{"first limit wins", PriorityLimits{MaxMatches: 1, SeverityBudget: 100}, []ir.RuleID{2}},
+	}
+	for _, tt := range tests {
+		result, err := engine.EvaluatePriority(event, tt.limits)
+		if err != nil {
+			t.Fatalf("%s: priority evaluation failed: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(result.MatchedRules, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, result.MatchedRules)
+		}
+	}
+
+	if result, err := engine.EvaluatePriority(map[string]interface{}{"EventID": "1"}, PriorityLimits{}); err != nil || len(result.MatchedRules) != 0 {
+		t.Errorf("Expected no matches, got %v, %v", result, err)
+	}
+
+	vmEngine, err := NewDagEngineBuilder().WithBackend(BackendVM).BuildFromRuleset(leveledRuleset(LevelLow))
+	if err != nil {
+		t.Fatalf("Failed to create VM engine: %v", err)
+	}
+	if _, err := vmEngine.EvaluatePriority(event, PriorityLimits{}); err == nil {
+		t.Error("Expected the VM backend to reject priority evaluation")
+	}
+}
+
+func TestRuleLevelSeverity(t *testing.T) {
+	if LevelCritical.Severity() != 5 || LevelInformational.Severity() != 1 || LevelUnknown.Severity() != 0 || RuleLevel(42).Severity() != 0 {
+		t.Error("Unexpected level severities")
+	}
+}
@@ -0,0 +1,320 @@
+package dag
+
+import (
+	"strconv"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+// RawPrefilter rejects raw JSON events that no rule can match before they
+// are parsed. Every rule must require a literal word: one of the words of
+// its anchor set appears in any event the rule matches. The prefilter
+// splits the raw event into lowercase ASCII words and passes it when a word
+// is in a Bloom filter of every rule's anchors.
+//
+// Anchors are only taken from literal values matched without modifiers,
+// where the words of the value show up as whole words of the raw event.
+// Values that match differently typed event values (numbers, null), values
+// with non-ASCII characters and regular expressions anchor nothing, and a
+// rule that requires no literal disables the prefilter. Events with
+// non-ASCII characters are passed, since case folding could map them onto
+// ASCII words.
+type RawPrefilter struct {
+	anchors     *bloomFilter
+	anchorCount int
+
+	checked  atomic.Uint64
+	rejected atomic.Uint64
+}
+
+// RawPrefilterStats contains raw prefilter statistics
+type RawPrefilterStats struct {
+	// Distinct anchor words of the ruleset
+	AnchorCount int
+
+	// Events checked and rejected since the engine was built
+	EventsChecked  uint64
+	EventsRejected uint64
+}
+
+// wordAnchors is the anchor set a node requires: one of the words appears
+// in every event the node is true for. unconstrained marks nodes that can
+// be true for any event.
+type wordAnchors struct {
+	words         []string
+	unconstrained bool
+}
+
+// newRawPrefilter builds the raw prefilter of a DAG. When a rule requires
+// no literal word it returns nil and the rule.
+func newRawPrefilter(dag *CompiledDag, primitives []Primitive) (*RawPrefilter, ir.RuleID, bool) {
+	builder := newAnchorBuilder(dag, primitives)
+	words := make(map[string]bool)
+	for _, ruleId := range sortedRuleIDs(dag.RuleResults) {
+		anchors := builder.nodeAnchors(dag.RuleResults[ruleId])
+		if anchors.unconstrained {
+			return nil, ruleId, false
+		}
+		for _, word := range anchors.words {
+			words[word] = true
+		}
+	}
+
+	filter := newBloomFilter(len(words))
+	for word := range words {
+		filter.add(word)
+	}
+	return &RawPrefilter{anchors: filter, anchorCount: len(words)}, 0, true
+}
+
+// anchorBuilder computes the anchor sets of DAG nodes
+type anchorBuilder struct {
+	dag        *CompiledDag
+	primitives map[ir.PrimitiveID]*Primitive
+	anchors    map[NodeId]wordAnchors
+}
+
+func newAnchorBuilder(dag *CompiledDag, primitives []Primitive) *anchorBuilder {
+	byID := make(map[ir.PrimitiveID]*Primitive, len(primitives))
+	for i := range primitives {
+		byID[ir.PrimitiveID(primitives[i].ID)] = &primitives[i]
+	}
+	return &anchorBuilder{dag: dag, primitives: byID, anchors: make(map[NodeId]wordAnchors)}
+}
+
+// nodeAnchors returns the anchor set of a node: an AND requires the
+// smallest set of its operands, an OR the union of all of them
+func (b *anchorBuilder) nodeAnchors(nodeId NodeId) wordAnchors {
+	if anchors, exists := b.anchors[nodeId]; exists {
+		return anchors
+	}
+	// Guards against cycles in malformed DAGs
+	b.anchors[nodeId] = wordAnchors{unconstrained: true}
+
+	anchors := wordAnchors{unconstrained: true}
+	node := b.dag.GetNode(nodeId)
+	switch {
+	case node == nil:
+	case node.CachedResult != nil:
+		// A node folded to false is true for no event
+		anchors.unconstrained = *node.CachedResult
+	case node.NodeType.Type == "Primitive" && node.NodeType.PrimitiveId != nil:
+		if primitive, exists := b.primitives[*node.NodeType.PrimitiveId]; exists {
+			anchors = primitiveAnchors(primitive)
+		}
+	case node.NodeType.Type == "Result" || (node.NodeType.Type == "Logical" && *node.NodeType.Operation == LogicalAnd):
+		for _, depId := range b.dag.DependenciesOf(nodeId) {
+			operand := b.nodeAnchors(depId)
+			if !operand.unconstrained && (anchors.unconstrained || len(operand.words) < len(anchors.words)) {
+				anchors = operand
+			}
+		}
+	case node.NodeType.Type == "Logical" && *node.NodeType.Operation == LogicalOr:
+		anchors.unconstrained = false
+		for _, depId := range b.dag.DependenciesOf(nodeId) {
+			operand := b.nodeAnchors(depId)
+			if operand.unconstrained {
+				anchors = operand
+				break
+			}
+			anchors.words = append(anchors.words, operand.words...)
+		}
+	}
+
+	b.anchors[nodeId] = anchors
+	return anchors
+}
+
+// primitiveAnchors returns the longest whole word of each value of a
+// literal primitive
+func primitiveAnchors(primitive *Primitive) wordAnchors {
+	if len(primitive.Modifiers) > 0 || !isLiteralMatchType(primitive.MatchType) {
+		return wordAnchors{unconstrained: true}
+	}
+	anchoredStart := primitive.MatchType == "equals" || primitive.MatchType == "startswith"
+	anchoredEnd := primitive.MatchType == "equals" || primitive.MatchType == "endswith"
+
+	anchors := wordAnchors{words: make([]string, 0, len(primitive.Values))}
+	for i, value := range primitive.Values {
+		kind := ir.ValueKindString
+		if i < len(primitive.ValueKinds) {
+			kind = primitive.ValueKinds[i]
+		}
+		if kind == ir.ValueKindNull || isNumericLiteral(value) {
+			return wordAnchors{unconstrained: true}
+		}
+		word := longestWholeWord(value, anchoredStart, anchoredEnd)
+		if word == "" {
+			return wordAnchors{unconstrained: true}
+		}
+		anchors.words = append(anchors.words, word)
+	}
+	return anchors
+}
+
+// isNumericLiteral reports whether a value also matches numbers, which
+// are written differently in the raw event (4624 matches 4.624e3)
+func isNumericLiteral(value string) bool {
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
+
+// longestWholeWord returns the longest lowercase word of a value that is
+// a whole word of any event value the value matches. Words touching an end
+// of the value that is not anchored, or a wildcard, may be part of a longer
+// word of the event. Returns "" when there is none or the value has
+// non-ASCII characters.
+func longestWholeWord(value string, anchoredStart, anchoredEnd bool) string {
+	longest := ""
+	start := -1
+	for i := 0; i <= len(value); i++ {
+		var c byte
+		if i < len(value) {
+			c = value[i]
+			if c >= 0x80 {
+				return ""
+			}
+			if isWordByte(c) {
+				if start < 0 {
+					start = i
+				}
+				continue
+			}
+		}
+		if start >= 0 {
+			wholeStart := start > 0 || anchoredStart
+			wholeEnd := i < len(value) || anchoredEnd
+			if wholeStart && start > 0 && isWildcardByte(value[start-1]) {
+				wholeStart = false
+			}
+			if wholeEnd && i < len(value) && isWildcardByte(c) {
+				wholeEnd = false
+			}
+			if wholeStart && wholeEnd && i-start > len(longest) {
+				longest = value[start:i]
+			}
+			start = -1
+		}
+	}
+	return lowerASCIIString(longest)
+}
+
+func isWordByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
+func isWildcardByte(c byte) bool {
+	return c == '*' || c == '?'
+}
+
+func lowerASCIIString(s string) string {
+	for i := 0; i < len(s); i++ {
+		if 'A' <= s[i] && s[i] <= 'Z' {
+			lower := []byte(s)
+			for j := i; j < len(lower); j++ {
+				lower[j] = lowerASCII(lower[j])
+			}
+			return string(lower)
+		}
+	}
+	return s
+}
+
+// Matches reports whether a raw JSON event may match a rule
+func (p *RawPrefilter) Matches(raw []byte) bool {
+	return p.check(rawEventHasAnchor(raw, p.anchors))
+}
+
+// MatchesString is Matches for an event held in a string
+func (p *RawPrefilter) MatchesString(raw string) bool {
+	return p.check(rawEventHasAnchor(raw, p.anchors))
+}
+
+func (p *RawPrefilter) check(passed bool) bool {
+	p.checked.Add(1)
+	if !passed {
+		p.rejected.Add(1)
+	}
+	return passed
+}
+
+// Stats returns the raw prefilter statistics
+func (p *RawPrefilter) Stats() *RawPrefilterStats {
+	return &RawPrefilterStats{
+		AnchorCount:    p.anchorCount,
+		EventsChecked:  p.checked.Load(),
+		EventsRejected: p.rejected.Load(),
+	}
+}
+
+func (p *RawPrefilter) memoryBytes() int {
+	return int(unsafe.Sizeof(*p)) + p.anchors.memoryBytes()
+}
+
+// rawEventHasAnchor scans the words of a raw JSON event, hashing each one
+// as it is read, and reports whether one may be an anchor. JSON escapes
+// separate words, except \u escapes of ASCII characters, which are decoded.
+// Events with non-ASCII characters pass.
+func rawEventHasAnchor[T string | []byte](raw T, anchors *bloomFilter) bool {
+	h := uint64(fnvOffset64)
+	inWord := false
+	for i := 0; i <= len(raw); i++ {
+		c := byte(' ')
+		if i < len(raw) {
+			c = raw[i]
+		}
+		if c >= 0x80 {
+			return true
+		}
+		if c == '\\' && i+1 < len(raw) {
+			if raw[i+1] == 'u' && i+5 < len(raw) {
+				code, ok := hexValue(raw[i+2 : i+6])
+				if !ok || code >= 0x80 {
+					return true
+				}
+				c = byte(code)
+				i += 5
+			} else {
+				c = ' '
+				i++
+			}
+		}
+
+		if isWordByte(c) {
+			if !inWord {
+				h, inWord = fnvOffset64, true
+			}
+			h ^= uint64(lowerASCII(c))
+			h *= fnvPrime64
+			continue
+		}
+		if inWord {
+			if anchors.mayContainHash(h) {
+				return true
+			}
+			inWord = false
+		}
+	}
+	return false
+}
+
+// hexValue parses four hexadecimal digits
+func hexValue[T string | []byte](digits T) (int, bool) {
+	value := 0
+	for i := 0; i < len(digits); i++ {
+		c := digits[i]
+		switch {
+		case '0' <= c && c <= '9':
+			value = value*16 + int(c-'0')
+		case 'a' <= c && c <= 'f':
+			value = value*16 + int(c-'a'+10)
+		case 'A' <= c && c <= 'F':
+			value = value*16 + int(c-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+	return value, true
+}
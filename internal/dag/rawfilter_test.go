@@ -0,0 +1,215 @@
+package dag
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+// createRawPrefilterRuleset creates rule 1 as AND(EventID = 4624,
+// CommandLine contains " -enc ") and rule 2 as OR(Image endswith
+// "\mimikatz.exe", Image = "lsass.exe")
+func createRawPrefilterRuleset() *CompiledRuleset {
+	dag := NewCompiledDag()
+	addNode := func(nodeType NodeType, dependencies ...NodeId) NodeId {
+		nodeId := NodeId(len(dag.Nodes))
+		node := NewDagNode(nodeId, nodeType)
+		node.Dependencies = dependencies
+		dag.Nodes = append(dag.Nodes, *node)
+		for _, depId := range dependencies {
+			dag.Nodes[depId].Dependents = append(dag.Nodes[depId].Dependents, nodeId)
+		}
+		dag.ExecutionOrder = append(dag.ExecutionOrder, nodeId)
+		return nodeId
+	}
+
+	for primitiveId := ir.PrimitiveID(0); primitiveId < 4; primitiveId++ {
+		dag.PrimitiveMap[primitiveId] = addNode(NewPrimitiveNodeType(primitiveId))
+	}
+	and := addNode(NewLogicalNodeType(LogicalAnd), 0, 1)
+	dag.RuleResults[1] = addNode(NewResultNodeType(1), and)
+	or := addNode(NewLogicalNodeType(LogicalOr), 2, 3)
+	dag.RuleResults[2] = addNode(NewResultNodeType(2), or)
+	dag.ResultBufferSize = len(dag.Nodes)
+
+	return &CompiledRuleset{
+		Primitives: []Primitive{
+			{ID: 0, Field: "EventID", MatchType: "equals", Values: []string{"4624"}},
+			{ID: 1, Field: "CommandLine", MatchType: "contains", Values: []string{" -enc "}},
+			{ID: 2, Field: "Image", MatchType: "endswith", Values: []string{`\mimikatz.exe`}},
+			{ID: 3, Field: "Image", MatchType: "equals", Values: []string{"lsass.exe"}},
+		},
+		PrimitiveMap: map[uint32]*CompiledPrimitive{},
+		Dag:          dag,
+	}
+}
+
+func TestLongestWholeWord(t *testing.T) {
+	testCases := []struct {
+		value         string
+		anchoredStart bool
+		anchoredEnd   bool
+		expected      string
+	}{
+		{"lsass.exe", true, true, "lsass"},
+		{"powershell", false, false, ""},
+		{"powershell", true, false, ""},
+		{"powershell", true, true, "powershell"},
+		{" -enc ", false, false, "enc"},
+		{`\Mimikatz.exe`, false, true, "mimikatz"},
+		{"sekurlsa::logonpasswords", false, false, ""},
+		{"a*longerword*b", true, true, ""},
+		{"net user /add", false, false, "user"},
+		{"cafébabe", true, true, ""},
+	}
+
+	for _, tc := range testCases {
+		if word := longestWholeWord(tc.value, tc.anchoredStart, tc.anchoredEnd); word != tc.expected {
+			t.Errorf("longestWholeWord(%q, %v, %v) = %q, expected %q",
+				tc.value, tc.anchoredStart, tc.anchoredEnd, word, tc.expected)
+		}
+	}
+}
+
+func TestRawPrefilterAnchors(t *testing.T) {
+	ruleset := createRawPrefilterRuleset()
+	filter, _, ok := newRawPrefilter(ruleset.Dag, ruleset.Primitives)
+	if !ok {
+		t.Fatal("Expected every rule to require a literal word")
+	}
+	// The numeric EventID anchors nothing, so rule 1 requires "enc"
+	if stats := filter.Stats(); stats.AnchorCount != 3 {
+		t.Errorf("Expected 3 anchors (enc, mimikatz, lsass), got %d", stats.AnchorCount)
+	}
+
+	testCases := []struct {
+		raw      string
+		expected bool
+	}{
+		{`{"CommandLine":"powershell -ENC SQBFAFgA"}`, true},
+		{`{"Image":"C:\\Tools\\mimikatz.exe"}`, true},
+		{`{"Image":"\u006Csass.exe"}`, true},
+		{`{"Image":"C:\\Windows\\notepad.exe"}`, false},
+		{`{"CommandLine":"powershell -encodedcommand"}`, false},
+		{`{"Image":"lsass\u00e9.exe"}`, true},
+		{`{"User":"Jürgen"}`, true},
+		{``, false},
+	}
+
+	for _, tc := range testCases {
+		if passed := filter.MatchesString(tc.raw); passed != tc.expected {
+			t.Errorf("MatchesString(%s) = %v, expected %v", tc.raw, passed, tc.expected)
+		}
+		if passed := filter.Matches([]byte(tc.raw)); passed != tc.expected {
+			t.Errorf("Matches(%s) = %v, expected %v", tc.raw, passed, tc.expected)
+		}
+	}
+}
+
+func TestRawPrefilterUnconstrainedRule(t *testing.T) {
+	ruleset := createRawPrefilterRuleset()
+	// Rule 2 also matches on a regular expression, which anchors nothing
+	ruleset.Primitives[3].MatchType = "regex"
+
+	_, ruleId, ok := newRawPrefilter(ruleset.Dag, ruleset.Primitives)
+	if ok || ruleId != 2 {
+		t.Errorf("Expected rule 2 to disable the raw prefilter, got ok=%v rule=%d", ok, ruleId)
+	}
+
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithRawPrefilter(true).
+		BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if stats := engine.RawPrefilterStats(); stats != nil {
+		t.Errorf("Expected no raw prefilter, got %+v", stats)
+	}
+}
+
+func TestDagEngineRawPrefilter(t *testing.T) {
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithPrefilter(false).
+		WithRawPrefilter(true).
+		BuildFromRuleset(createRawPrefilterRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if engine.MemoryUsage().PrefilterBytes == 0 {
+		t.Error("Expected the raw prefilter to be counted in the engine memory")
+	}
+
+	testCases := []struct {
+		raw     string
+		matched []ir.RuleID
+	}{
+		{`{"EventID":4624,"CommandLine":"powershell -enc SQBFAFgA"}`, []ir.RuleID{1}},
+		{`{"Image":"C:\\Tools\\mimikatz.exe"}`, []ir.RuleID{2}},
+		{`{"Image":"lsass\u002eexe"}`, []ir.RuleID{2}},
+		{`{"EventID":4624,"CommandLine":"notepad.exe"}`, nil},
+		// Rejected events are not parsed
+		{`not json`, nil},
+	}
+
+	for _, tc := range testCases {
+		result, err := engine.EvaluateRaw(tc.raw)
+		if err != nil {
+			t.Fatalf("Evaluation of %s failed: %v", tc.raw, err)
+		}
+		if len(result.MatchedRules) != len(tc.matched) || (len(tc.matched) > 0 && result.MatchedRules[0] != tc.matched[0]) {
+			t.Errorf("Expected %s to match %v, got %v", tc.raw, tc.matched, result.MatchedRules)
+		}
+	}
+
+	stats := engine.RawPrefilterStats()
+	if stats == nil {
+		t.Fatal("Expected raw prefilter statistics")
+	}
+	if stats.EventsChecked != 5 || stats.EventsRejected != 2 {
+		t.Errorf("Expected 2 of 5 events rejected, got %d of %d", stats.EventsRejected, stats.EventsChecked)
+	}
+
+	// Without the option the engine parses every event
+	engine, err = NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(createRawPrefilterRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if engine.RawPrefilterStats() != nil {
+		t.Error("Expected no raw prefilter by default")
+	}
+	if _, err := engine.EvaluateRaw(`not json`); err == nil {
+		t.Error("Expected invalid JSON to fail without the raw prefilter")
+	}
+}
+
+func TestDagEngineRawPrefilterWithEnrichers(t *testing.T) {
+	// The enricher adds the field rule 2 matches on, which the raw event
+	// does not contain
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithRawPrefilter(true).
+		WithEnricher(EnricherFunc(func(ctx context.Context, event map[string]interface{}) error {
+			if event["HostName"] == "dc1" {
+				event["Image"] = "lsass.exe"
+			}
+			return nil
+		})).
+		BuildFromRuleset(createRawPrefilterRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if engine.RawPrefilterStats() != nil {
+		t.Error("Expected the raw prefilter to be disabled with enrichers")
+	}
+
+	result, err := engine.EvaluateRaw(`{"HostName":"dc1"}`)
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 || result.MatchedRules[0] != 2 {
+		t.Errorf("Expected the enriched event to match rule 2, got %v", result.MatchedRules)
+	}
+}
@@ -0,0 +1,210 @@
+package dag
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+)
+
+// SamplingStats reports the events skipped by sampling and rate limiting,
+// so the detection coverage traded for throughput is known
+type SamplingStats struct {
+	EventsSeen        uint64
+	EventsEvaluated   uint64
+	EventsSampledOut  uint64
+	EventsRateLimited uint64
+
+	// Sampled out events per value of the sampling field with a configured
+	// rate; events of other values are only counted in EventsSampledOut
+	SampledOutByValue map[string]uint64
+}
+
+// Coverage returns the fraction of seen events that were evaluated
+func (s SamplingStats) Coverage() float64 {
+	if s.EventsSeen == 0 {
+		return 1
+	}
+	return float64(s.EventsEvaluated) / float64(s.EventsSeen)
+}
+
+// WithSampling evaluates one of every rate events
+func (b *DagEngineBuilder) WithSampling(rate int) *DagEngineBuilder {
+	b.config.SampleRate = rate
+	return b
+}
+
+// WithFieldSampling samples events per value of a field (e.g. the log
+// channel), each value at its rate in rates and other values at the
+// SampleRate
+func (b *DagEngineBuilder) WithFieldSampling(field string, rates map[string]int) *DagEngineBuilder {
+	b.config.SampleField = field
+	b.config.SampleRates = rates
+	return b
+}
+
+// WithRateLimit limits evaluation to perSecond events per second with
+// bursts of up to burst events
+func (b *DagEngineBuilder) WithRateLimit(perSecond float64, burst int) *DagEngineBuilder {
+	b.config.RateLimit = perSecond
+	b.config.RateBurst = burst
+	return b
+}
+
+// eventSampler admits events by deterministic 1-in-N sampling, then by a
+// token bucket. It is only used under the engine lock.
+type eventSampler struct {
+	rate  int
+	field string
+	rates map[string]int
+
+	// Events seen per value with a configured rate, and of every other
+	// value together, so the counters stay bounded by the configuration
+	counts       map[string]uint64
+	defaultCount uint64
+
+	// Token bucket (limit = 0 when rate limiting is off)
+	limit  float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  clock.Clock
+
+	stats SamplingStats
+}
+
+// newEventSampler returns the sampler of a configuration, or nil when
+// every event is evaluated
+func newEventSampler(config DagEngineConfig, c clock.Clock) (*eventSampler, error) {
+	if config.SampleRate < 0 || config.RateLimit < 0 || config.RateBurst < 0 {
+		return nil, fmt.Errorf("sampling rate, rate limit and burst must not be negative")
+	}
+	for value, rate := range config.SampleRates {
+		if rate < 0 {
+			return nil, fmt.Errorf("sampling rate of %q must not be negative", value)
+		}
+	}
+	sampled := config.SampleRate > 1 || (config.SampleField != "" && len(config.SampleRates) > 0)
+	if !sampled && config.RateLimit == 0 {
+		return nil, nil
+	}
+
+	s := &eventSampler{
+		rate:   config.SampleRate,
+		field:  config.SampleField,
+		rates:  config.SampleRates,
+		counts: make(map[string]uint64),
+		limit:  config.RateLimit,
+		burst:  float64(config.RateBurst),
+		clock:  c,
+	}
+	s.stats.SampledOutByValue = make(map[string]uint64)
+	if s.limit > 0 {
+		if s.burst == 0 {
+			s.burst = max(1, s.limit)
+		}
+		s.tokens = s.burst
+		s.last = c.Now()
+	}
+	return s, nil
+}
+
+// admit reports whether an event is evaluated
+func (s *eventSampler) admit(event interface{}) bool {
+	s.stats.EventsSeen++
+
+	rate, key, configured := s.rate, "", false
+	if s.field != "" && len(s.rates) > 0 {
+		if value, err := matcher.DefaultFieldExtractor(event, s.field); err == nil && value != nil {
+			key = fmt.Sprint(value)
+			if fieldRate, exists := s.rates[key]; exists {
+				rate, configured = fieldRate, true
+			}
+		}
+	}
+	if rate > 1 {
+		count := s.defaultCount
+		if configured {
+			count = s.counts[key]
+			s.counts[key] = count + 1
+		} else {
+			s.defaultCount++
+		}
+		if count%uint64(rate) != 0 {
+			s.stats.EventsSampledOut++
+			if configured {
+				s.stats.SampledOutByValue[key]++
+			}
+			return false
+		}
+	}
+
+	if s.limit > 0 {
+		now := s.clock.Now()
+		s.tokens = min(s.burst, s.tokens+now.Sub(s.last).Seconds()*s.limit)
+		s.last = now
+		if s.tokens < 1 {
+			s.stats.EventsRateLimited++
+			return false
+		}
+		s.tokens--
+	}
+	s.stats.EventsEvaluated++
+	return true
+}
+
+// snapshot returns a copy of the statistics
+func (s *eventSampler) snapshot() *SamplingStats {
+	stats := s.stats
+	stats.SampledOutByValue = make(map[string]uint64, len(s.stats.SampledOutByValue))
+	for value, count := range s.stats.SampledOutByValue {
+		stats.SampledOutByValue[value] = count
+	}
+	return &stats
+}
+
+// admitEvent reports whether sampling and rate limiting let an event
+// through. Callers hold the engine lock.
+func (e *DagEngine) admitEvent(event interface{}) bool {
+	return e.sampler == nil || e.sampler.admit(event)
+}
+
+// sampleBatch returns the events of a batch that sampling and rate
+// limiting let through, with their positions in the batch (nil when every
+// event is let through). Callers hold the engine lock.
+func (e *DagEngine) sampleBatch(events []interface{}) ([]interface{}, []int) {
+	if e.sampler == nil {
+		return events, nil
+	}
+	admitted := make([]interface{}, 0, len(events))
+	positions := make([]int, 0, len(events))
+	for i, event := range events {
+		if e.sampler.admit(event) {
+			admitted = append(admitted, event)
+			positions = append(positions, i)
+		}
+	}
+	if len(admitted) == len(events) {
+		return events, nil
+	}
+	return admitted, positions
+}
+
+// expandSampled spreads the results of a sampled batch over the positions
+// of their events, with empty results for the skipped events
+func expandSampled(results []*DagEvaluationResult, positions []int, total int) []*DagEvaluationResult {
+	if positions == nil {
+		return results
+	}
+	expanded := make([]*DagEvaluationResult, total)
+	for i, position := range positions {
+		expanded[position] = results[i]
+	}
+	for i, result := range expanded {
+		if result == nil {
+			expanded[i] = NewDagEvaluationResult()
+		}
+	}
+	return expanded
+}
@@ -0,0 +1,133 @@
+package dag
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+func TestDagEngineSampling(t *testing.T) {
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithSampling(3).
+		BuildFromRuleset(createRawPrefilterRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	event := map[string]interface{}{"Image": "lsass.exe"}
+	matched := 0
+	for i := 0; i < 6; i++ {
+		result, err := engine.Evaluate(event)
+		if err != nil {
+			t.Fatalf("Evaluation failed: %v", err)
+		}
+		matched += len(result.MatchedRules)
+	}
+	if matched != 2 {
+		t.Errorf("Expected one of every three events to be evaluated, got %d matches", matched)
+	}
+
+	// Batches are sampled event by event, keeping the results in place
+	results, err := engine.EvaluateBatch([]interface{}{event, event, event})
+	if err != nil {
+		t.Fatalf("Batch evaluation failed: %v", err)
+	}
+	if len(results) != 3 || len(results[0].MatchedRules) != 1 || len(results[1].MatchedRules) != 0 || len(results[2].MatchedRules) != 0 {
+		t.Errorf("Unexpected sampled batch results: %v", results)
+	}
+
+	stats := engine.Stats().Sampling
+	if stats == nil || stats.EventsSeen != 9 || stats.EventsEvaluated != 3 || stats.EventsSampledOut != 6 {
+		t.Fatalf("Unexpected sampling statistics: %+v", stats)
+	}
+	if coverage := stats.Coverage(); coverage < 0.33 || coverage > 0.34 {
+		t.Errorf("Expected a third of the events covered, got %f", coverage)
+	}
+}
+
+func TestDagEngineFieldSampling(t *testing.T) {
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithFieldSampling("Channel", map[string]int{"Security": 2}).
+		BuildFromRuleset(createRawPrefilterRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		for _, channel := range []string{"Security", "Sysmon"} {
+			if _, err := engine.Evaluate(map[string]interface{}{"Channel": channel, "Image": "lsass.exe"}); err != nil {
+				t.Fatalf("Evaluation failed: %v", err)
+			}
+		}
+	}
+	stats := engine.Stats().Sampling
+	if stats.EventsEvaluated != 6 || stats.SampledOutByValue["Security"] != 2 || stats.SampledOutByValue["Sysmon"] != 0 {
+		t.Errorf("Expected only half of the Security events to be sampled out, got %+v", stats)
+	}
+
+	// Values without a rate share the default counter and keep no stats
+	engine, err = NewDagEngineBuilder().
+		WithOptimization(false).
+		WithSampling(2).
+		WithFieldSampling("Host", map[string]int{"dc01": 1}).
+		BuildFromRuleset(createRawPrefilterRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := engine.Evaluate(map[string]interface{}{"Host": fmt.Sprintf("host%d", i)}); err != nil {
+			t.Fatalf("Evaluation failed: %v", err)
+		}
+	}
+	stats = engine.Stats().Sampling
+	if stats.EventsSampledOut != 50 || len(stats.SampledOutByValue) != 0 {
+		t.Errorf("Expected half of the distinct hosts to be sampled out without per-value stats, got %+v", stats)
+	}
+	if counts := len(engine.sampler.counts); counts != 0 {
+		t.Errorf("Expected no per-value counters for unconfigured values, got %d", counts)
+	}
+}
+
+func TestDagEngineRateLimit(t *testing.T) {
+	c := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	engine, err := NewDagEngineBuilder().
+		WithOptimization(false).
+		WithClock(c).
+		WithRateLimit(2, 2).
+		BuildFromRuleset(createRawPrefilterRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	event := map[string]interface{}{"Image": "lsass.exe"}
+	evaluate := func() bool {
+		result, err := engine.Evaluate(event)
+		if err != nil {
+			t.Fatalf("Evaluation failed: %v", err)
+		}
+		return len(result.MatchedRules) > 0
+	}
+
+	if !evaluate() || !evaluate() || evaluate() {
+		t.Error("Expected a burst of two events, then the limit")
+	}
+	c.Advance(500 * time.Millisecond)
+	if !evaluate() || evaluate() {
+		t.Error("Expected one token after half a second")
+	}
+	if stats := engine.Stats().Sampling; stats.EventsRateLimited != 2 || stats.EventsEvaluated != 3 {
+		t.Errorf("Unexpected rate limit statistics: %+v", stats)
+	}
+
+	if _, err := NewDagEngineBuilder().WithSampling(-1).BuildFromRuleset(createRawPrefilterRuleset()); !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeConfig) {
+		t.Errorf("Expected a negative sampling rate to fail, got %v", err)
+	}
+	if engine, _ := NewDagEngineBuilder().BuildFromRuleset(createRawPrefilterRuleset()); engine.Stats().Sampling != nil {
+		t.Error("Expected no sampling statistics by default")
+	}
+}
@@ -0,0 +1,219 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+)
+
+// ShadowEvaluator evaluates events with an active engine and, in the
+// shadow of it, a candidate engine built from an updated ruleset. Callers
+// get the active engine's results while the differences between both
+// engines are recorded, so a rule pack update can be checked on live
+// traffic before it replaces the active one.
+//
+// Rules are compared across the engines by SIGMA UUID, falling back to the
+// title for rules without one, since rule IDs differ between compilations.
+// The candidate engine should be built without post-match actions.
+type ShadowEvaluator struct {
+	active    *DagEngine
+	candidate *DagEngine
+	onDiff    func(event interface{}, diff *ShadowDiff)
+
+	mu              sync.Mutex
+	eventsCompared  uint64
+	eventsDiffering uint64
+	candidateErrors uint64
+	newlyMatching   map[string]uint64
+	stoppedMatching map[string]uint64
+}
+
+// ShadowDiff is the difference between the engines' matches for one event
+type ShadowDiff struct {
+	// Rules only the candidate engine matched
+	NewMatches []string
+
+	// Rules only the active engine matched
+	LostMatches []string
+}
+
+// ShadowReport summarizes the differences recorded by a ShadowEvaluator
+type ShadowReport struct {
+	EventsCompared  uint64
+	EventsDiffering uint64
+
+	// Events the candidate engine failed to evaluate (not compared)
+	CandidateErrors uint64
+
+	// Events per rule that only the candidate engine matched, and that
+	// only the active engine matched
+	NewlyMatching   map[string]uint64
+	StoppedMatching map[string]uint64
+
+	// Rules only in the candidate ruleset, and only in the active one
+	RulesAdded   []string
+	RulesRemoved []string
+}
+
+// NewShadowEvaluator creates a shadow evaluator comparing candidate to
+// active
+func NewShadowEvaluator(active, candidate *DagEngine) *ShadowEvaluator {
+	return &ShadowEvaluator{
+		active:          active,
+		candidate:       candidate,
+		newlyMatching:   make(map[string]uint64),
+		stoppedMatching: make(map[string]uint64),
+	}
+}
+
+// OnDifference sets a function called with every event the engines
+// disagree on, e.g. to log it for review. It runs on the evaluating
+// goroutine.
+func (s *ShadowEvaluator) OnDifference(fn func(event interface{}, diff *ShadowDiff)) *ShadowEvaluator {
+	s.onDiff = fn
+	return s
+}
+
+// Evaluate evaluates an event with both engines and returns the active
+// engine's result. Errors of the candidate engine are counted, never
+// returned.
+func (s *ShadowEvaluator) Evaluate(event interface{}) (*DagEvaluationResult, error) {
+	result, err := s.active.Evaluate(event)
+	if err != nil {
+		return nil, err
+	}
+	candidate, err := s.candidate.Evaluate(event)
+	s.record([]interface{}{event}, []*DagEvaluationResult{result}, []*DagEvaluationResult{candidate}, err)
+	return result, nil
+}
+
+// EvaluateBatch evaluates events with both engines like Evaluate
+func (s *ShadowEvaluator) EvaluateBatch(events []interface{}) ([]*DagEvaluationResult, error) {
+	results, err := s.active.EvaluateBatch(events)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := s.candidate.EvaluateBatch(events)
+	s.record(events, results, candidates, err)
+	return results, nil
+}
+
+// record compares the engines' results
+func (s *ShadowEvaluator) record(events []interface{}, active, candidate []*DagEvaluationResult, err error) {
+	if err != nil {
+		s.mu.Lock()
+		s.candidateErrors += uint64(len(events))
+		s.mu.Unlock()
+		return
+	}
+
+	for i, event := range events {
+		diff := s.compare(active[i], candidate[i])
+		differs := len(diff.NewMatches) > 0 || len(diff.LostMatches) > 0
+
+		s.mu.Lock()
+		s.eventsCompared++
+		if differs {
+			s.eventsDiffering++
+			for _, rule := range diff.NewMatches {
+				s.newlyMatching[rule]++
+			}
+			for _, rule := range diff.LostMatches {
+				s.stoppedMatching[rule]++
+			}
+		}
+		s.mu.Unlock()
+
+		if differs && s.onDiff != nil {
+			s.onDiff(event, diff)
+		}
+	}
+}
+
+// compare returns the rules matched by only one of the engines
+func (s *ShadowEvaluator) compare(active, candidate *DagEvaluationResult) *ShadowDiff {
+	activeRules := make(map[string]bool, len(active.MatchedRules))
+	for _, ruleId := range active.MatchedRules {
+		activeRules[shadowRuleKey(s.active, ruleId)] = true
+	}
+	candidateRules := make(map[string]bool, len(candidate.MatchedRules))
+	for _, ruleId := range candidate.MatchedRules {
+		candidateRules[shadowRuleKey(s.candidate, ruleId)] = true
+	}
+
+	diff := &ShadowDiff{}
+	for rule := range candidateRules {
+		if !activeRules[rule] {
+			diff.NewMatches = append(diff.NewMatches, rule)
+		}
+	}
+	for rule := range activeRules {
+		if !candidateRules[rule] {
+			diff.LostMatches = append(diff.LostMatches, rule)
+		}
+	}
+	sort.Strings(diff.NewMatches)
+	sort.Strings(diff.LostMatches)
+	return diff
+}
+
+// shadowRuleKey identifies a rule across engines: its SIGMA UUID, its
+// title, or its rule ID for rules with neither
+func shadowRuleKey(engine *DagEngine, ruleId ir.RuleID) string {
+	meta := engine.rules[ruleId]
+	switch {
+	case meta.SigmaID != "":
+		return meta.SigmaID
+	case meta.Title != "":
+		return meta.Title
+	default:
+		return fmt.Sprintf("rule %d", ruleId)
+	}
+}
+
+// Report returns the differences recorded so far
+func (s *ShadowEvaluator) Report() ShadowReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := ShadowReport{
+		EventsCompared:  s.eventsCompared,
+		EventsDiffering: s.eventsDiffering,
+		CandidateErrors: s.candidateErrors,
+		NewlyMatching:   make(map[string]uint64, len(s.newlyMatching)),
+		StoppedMatching: make(map[string]uint64, len(s.stoppedMatching)),
+	}
+	for rule, count := range s.newlyMatching {
+		report.NewlyMatching[rule] = count
+	}
+	for rule, count := range s.stoppedMatching {
+		report.StoppedMatching[rule] = count
+	}
+
+	activeRules := shadowRuleKeys(s.active)
+	candidateRules := shadowRuleKeys(s.candidate)
+	for rule := range candidateRules {
+		if !activeRules[rule] {
+			report.RulesAdded = append(report.RulesAdded, rule)
+		}
+	}
+	for rule := range activeRules {
+		if !candidateRules[rule] {
+			report.RulesRemoved = append(report.RulesRemoved, rule)
+		}
+	}
+	sort.Strings(report.RulesAdded)
+	sort.Strings(report.RulesRemoved)
+	return report
+}
+
+// shadowRuleKeys returns the keys of an engine's rules
+func shadowRuleKeys(engine *DagEngine) map[string]bool {
+	keys := make(map[string]bool, len(engine.dag.RuleResults))
+	for ruleId := range engine.dag.RuleResults {
+		keys[shadowRuleKey(engine, ruleId)] = true
+	}
+	return keys
+}
@@ -0,0 +1,65 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShadowEvaluator(t *testing.T) {
+	activeRuleset := createRawPrefilterRuleset()
+	activeRuleset.Rules = []RuleMeta{{ID: 1, SigmaID: "rule-one"}, {ID: 2, SigmaID: "rule-two"}}
+	active, err := NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(activeRuleset)
+	if err != nil {
+		t.Fatalf("Failed to create active engine: %v", err)
+	}
+
+	// The update retargets rule two and replaces rule one
+	candidateRuleset := createRawPrefilterRuleset()
+	candidateRuleset.Primitives[3].Values = []string{"svchost.exe"}
+	candidateRuleset.Rules = []RuleMeta{{ID: 1, SigmaID: "rule-one-v2"}, {ID: 2, SigmaID: "rule-two"}}
+	candidate, err := NewDagEngineBuilder().WithOptimization(false).BuildFromRuleset(candidateRuleset)
+	if err != nil {
+		t.Fatalf("Failed to create candidate engine: %v", err)
+	}
+
+	var diffs []*ShadowDiff
+	shadow := NewShadowEvaluator(active, candidate).OnDifference(func(event interface{}, diff *ShadowDiff) {
+		diffs = append(diffs, diff)
+	})
+
+	result, err := shadow.Evaluate(map[string]interface{}{"Image": "lsass.exe"})
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(result.MatchedRules) != 1 || result.MatchedRules[0] != 2 {
+		t.Errorf("Expected the active engine's result, got %v", result.MatchedRules)
+	}
+	if _, err := shadow.EvaluateBatch([]interface{}{
+		map[string]interface{}{"Image": "svchost.exe"},
+		map[string]interface{}{"EventID": 4624, "CommandLine": "powershell -enc SQBFAFgA"},
+		map[string]interface{}{"Image": "notepad.exe"},
+	}); err != nil {
+		t.Fatalf("Batch evaluation failed: %v", err)
+	}
+
+	expectedDiffs := []*ShadowDiff{
+		{LostMatches: []string{"rule-two"}},
+		{NewMatches: []string{"rule-two"}},
+		{NewMatches: []string{"rule-one-v2"}, LostMatches: []string{"rule-one"}},
+	}
+	if !reflect.DeepEqual(diffs, expectedDiffs) {
+		t.Errorf("Expected differences %+v, got %+v", expectedDiffs, diffs)
+	}
+
+	report := shadow.Report()
+	if report.EventsCompared != 4 || report.EventsDiffering != 3 || report.CandidateErrors != 0 {
+		t.Errorf("Unexpected event counts: %+v", report)
+	}
+	if !reflect.DeepEqual(report.NewlyMatching, map[string]uint64{"rule-two": 1, "rule-one-v2": 1}) ||
+		!reflect.DeepEqual(report.StoppedMatching, map[string]uint64{"rule-two": 1, "rule-one": 1}) {
+		t.Errorf("Unexpected per-rule differences: %+v", report)
+	}
+	if !reflect.DeepEqual(report.RulesAdded, []string{"rule-one-v2"}) || !reflect.DeepEqual(report.RulesRemoved, []string{"rule-one"}) {
+		t.Errorf("Expected rule one to be replaced, got added %v and removed %v", report.RulesAdded, report.RulesRemoved)
+	}
+}
@@ -0,0 +1,176 @@
+package dag
+
+import (
+	"time"
+)
+
+// Tuning candidates for the number of chunks each worker receives
+var tuningChunksPerWorker = []int{1, chunksPerWorker, 16}
+
+// tuningTrialsPerSetting is the number of batches measured per candidate
+const tuningTrialsPerSetting = 3
+
+// parallelSetting is one worker pool configuration for batch evaluation
+type parallelSetting struct {
+	workers         int
+	chunksPerWorker int
+}
+
+// tuningMeasurement accumulates throughput samples of a candidate setting
+type tuningMeasurement struct {
+	batches int
+	events  int
+	elapsed time.Duration
+}
+
+// eventsPerSecond returns the measured throughput
+func (m tuningMeasurement) eventsPerSecond() float64 {
+	if m.elapsed <= 0 {
+		return 0
+	}
+	return float64(m.events) / m.elapsed.Seconds()
+}
+
+// ParallelTuner measures batch throughput at different worker counts and
+// chunk sizes and converges on the fastest setting.
+//
+// Every candidate is used for a few batches in turn; once all candidates
+// have been measured, the one with the highest events/sec is kept for all
+// later batches.
+type ParallelTuner struct {
+	candidates   []parallelSetting
+	measurements []tuningMeasurement
+	current      int
+	best         parallelSetting
+	bestRate     float64
+	converged    bool
+}
+
+// NewParallelTuner creates a tuner trying worker counts in powers of two up
+// to maxWorkers
+func NewParallelTuner(maxWorkers int) *ParallelTuner {
+	maxWorkers = max(1, maxWorkers)
+
+	var workerCounts []int
+	for workers := 1; workers < maxWorkers; workers *= 2 {
+		workerCounts = append(workerCounts, workers)
+	}
+	workerCounts = append(workerCounts, maxWorkers)
+
+	var candidates []parallelSetting
+	for _, workers := range workerCounts {
+		if workers == 1 {
+			// Chunking is irrelevant for a single worker
+			candidates = append(candidates, parallelSetting{workers: 1, chunksPerWorker: 1})
+			continue
+		}
+		for _, chunks := range tuningChunksPerWorker {
+			candidates = append(candidates, parallelSetting{workers: workers, chunksPerWorker: chunks})
+		}
+	}
+
+	return &ParallelTuner{
+		candidates:   candidates,
+		measurements: make([]tuningMeasurement, len(candidates)),
+		best:         candidates[len(candidates)-1],
+	}
+}
+
+// next returns the setting to use for the next batch
+func (t *ParallelTuner) next() parallelSetting {
+	if t.converged {
+		return t.best
+	}
+	return t.candidates[t.current]
+}
+
+// record adds a batch measurement for the given setting, advancing to the
+// next candidate or converging once every candidate has been measured
+func (t *ParallelTuner) record(setting parallelSetting, events int, elapsed time.Duration) {
+	if t.converged || setting != t.candidates[t.current] {
+		return
+	}
+
+	measurement := &t.measurements[t.current]
+	measurement.batches++
+	measurement.events += events
+	measurement.elapsed += elapsed
+	if measurement.batches < tuningTrialsPerSetting {
+		return
+	}
+
+	t.current++
+	if t.current < len(t.candidates) {
+		return
+	}
+
+	for i, m := range t.measurements {
+		if rate := m.eventsPerSecond(); rate > t.bestRate {
+			t.best = t.candidates[i]
+			t.bestRate = rate
+		}
+	}
+	t.converged = true
+}
+
+// Converged reports whether tuning has settled on a setting
+func (t *ParallelTuner) Converged() bool {
+	return t.converged
+}
+
+// Reset discards all measurements and restarts tuning
+func (t *ParallelTuner) Reset() {
+	clear(t.measurements)
+	t.current = 0
+	t.best = t.candidates[len(t.candidates)-1]
+	t.bestRate = 0
+	t.converged = false
+}
+
+// tuningBatches returns the number of batches measured so far
+func (t *ParallelTuner) tuningBatches() int {
+	batches := 0
+	for _, m := range t.measurements {
+		batches += m.batches
+	}
+	return batches
+}
+
+// EngineStats reports runtime decisions made by the engine
+type EngineStats struct {
+	// Worker pool setting used for parallel batches (from the tuner when
+	// auto-tuning is enabled, otherwise from ParallelConfig)
+	ParallelWorkers int
+	ChunksPerWorker int
+
+	// Thread count resolved from ParallelConfig.NumThreads, and the cgroup
+	// CPU quota in CPUs that auto-detection honoured (0 = not limited)
+	NumThreads int
+	CPUQuota   float64
+
+	// Whether auto-tuning is enabled and has converged
+	AutoTuning bool
+	AutoTuned  bool
+
+	// Batches measured while tuning and the best measured throughput
+	TuningBatches       int
+	BestEventsPerSecond float64
+
+	// Whether events are flattened at ingest, and the ruleset field depth
+	// statistics the decision was based on
+	EventFlattening bool
+	FieldDepth      FieldDepthStats
+
+	// Backend evaluating rule conditions
+	Backend Backend
+
+	// Events skipped by sampling and rate limiting (nil when every event
+	// is evaluated)
+	Sampling *SamplingStats
+
+	// Primitive errors treated as no match (nil under ErrorFailFast)
+	PrimitiveErrors *PrimitiveErrorStats
+
+	// Events routed to the dead-letter sink
+	DeadLetters uint64
+}
@@ -0,0 +1,132 @@
+package dag
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+)
+
+func TestParallelTunerCandidates(t *testing.T) {
+	tuner := NewParallelTuner(6)
+
+	workers := map[int]bool{}
+	for _, candidate := range tuner.candidates {
+		workers[candidate.workers] = true
+	}
+	for _, expected := range []int{1, 2, 4, 6} {
+		if !workers[expected] {
+			t.Errorf("Expected candidate with %d workers, got %v", expected, tuner.candidates)
+		}
+	}
+	if len(tuner.candidates) != 1+3*len(tuningChunksPerWorker) {
+		t.Errorf("Unexpected candidate count %d", len(tuner.candidates))
+	}
+}
+
+func TestParallelTunerConverges(t *testing.T) {
+	tuner := NewParallelTuner(2)
+	fastest := parallelSetting{workers: 2, chunksPerWorker: 16}
+
+	for !tuner.Converged() {
+		setting := tuner.next()
+		elapsed := 10 * time.Millisecond
+		if setting == fastest {
+			elapsed = time.Millisecond
+		}
+		tuner.record(setting, 1000, elapsed)
+	}
+
+	if tuner.next() != fastest {
+		t.Errorf("Expected tuner to converge on %+v, got %+v", fastest, tuner.next())
+	}
+	if tuner.tuningBatches() != len(tuner.candidates)*tuningTrialsPerSetting {
+		t.Errorf("Unexpected tuning batch count %d", tuner.tuningBatches())
+	}
+
+	tuner.Reset()
+	if tuner.Converged() || tuner.tuningBatches() != 0 {
+		t.Error("Expected reset tuner to restart measurements")
+	}
+}
+
+func TestEngineAutoTuningStats(t *testing.T) {
+	config := DefaultDagEngineConfig()
+	config.EnableOptimization = false
+	config.EnablePrefilter = false
+	config.EnableParallelProcessing = true
+	config.ParallelConfig = ParallelConfig{
+		NumThreads:                 2,
+		EnableEventParallelism:     true,
+		MinBatchSizeForParallelism: 10,
+		AutoTune:                   true,
+	}
+
+	engine, err := NewDagEngineFromRulesetWithConfig(createBatchTestRuleset(), config)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if stats := engine.Stats(); !stats.AutoTuning || stats.AutoTuned {
+		t.Fatalf("Expected tuning to be pending, got %+v", stats)
+	}
+
+	events := make([]interface{}, 64)
+	for i := range events {
+		events[i] = map[string]interface{}{"EventID": "4624", "ProcessName": "powershell"}
+	}
+	for i := 0; i < 4*tuningTrialsPerSetting; i++ {
+		results, err := engine.EvaluateBatchParallel(events)
+		if err != nil {
+			t.Fatalf("Parallel batch evaluation failed: %v", err)
+		}
+		if len(results[len(results)-1].MatchedRules) != 1 {
+			t.Fatalf("Expected rule 1 to match, got %v", results[len(results)-1].MatchedRules)
+		}
+	}
+
+	stats := engine.Stats()
+	if !stats.AutoTuned || stats.TuningBatches != 4*tuningTrialsPerSetting {
+		t.Errorf("Expected tuning to converge, got %+v", stats)
+	}
+	if stats.ParallelWorkers < 1 || stats.ParallelWorkers > 2 || stats.BestEventsPerSecond <= 0 {
+		t.Errorf("Unexpected tuned setting: %+v", stats)
+	}
+	if stats.NumThreads != 2 {
+		t.Errorf("Expected the configured 2 threads, got %d", stats.NumThreads)
+	}
+}
+
+func TestEngineAutoTuningWithClock(t *testing.T) {
+	config := DefaultDagEngineConfig()
+	config.EnableOptimization = false
+	config.EnablePrefilter = false
+	config.EnableParallelProcessing = true
+	config.ParallelConfig = ParallelConfig{
+		NumThreads:                 2,
+		EnableEventParallelism:     true,
+		MinBatchSizeForParallelism: 10,
+		AutoTune:                   true,
+	}
+	config.Clock = clock.NewStepping(time.Time{}, time.Millisecond)
+
+	engine, err := NewDagEngineFromRulesetWithConfig(createBatchTestRuleset(), config)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	events := make([]interface{}, 64)
+	for i := range events {
+		events[i] = map[string]interface{}{"EventID": "4624"}
+	}
+	for i := 0; i < 4*tuningTrialsPerSetting; i++ {
+		if _, err := engine.EvaluateBatchParallel(events); err != nil {
+			t.Fatalf("Parallel batch evaluation failed: %v", err)
+		}
+	}
+
+	// Every batch takes exactly one step, so the first candidate wins ties
+	stats := engine.Stats()
+	if !stats.AutoTuned || stats.ParallelWorkers != 1 || stats.BestEventsPerSecond != 64000 {
+		t.Errorf("Expected the single-worker setting at 64000 events/s, got %+v", stats)
+	}
+}
@@ -2,6 +2,8 @@ package dag
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
 	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
@@ -125,6 +127,84 @@ type CompiledDag struct {
 	PrimitiveMap     map[ir.PrimitiveID]NodeId
 	RuleResults      map[ir.RuleID]NodeId
 	ResultBufferSize int
+
+	// Selection name -> primitive IDs for each rule (used for match details)
+	RuleSelections map[ir.RuleID]map[string][]ir.PrimitiveID
+
+	// Fields declared by each rule's `fields:` list (used for field capture)
+	RuleFields map[ir.RuleID][]RuleField
+
+	// Flat edge storage populated by Compact: the edges of node i are
+	// Index[Offsets[i]:Offsets[i+1]]
+	DependencyOffsets []uint32
+	DependencyIndex   []NodeId
+	DependentOffsets  []uint32
+	DependentIndex    []NodeId
+}
+
+// RuleLevel is the SIGMA severity level of a rule
+type RuleLevel int
+
+const (
+	LevelUnknown RuleLevel = iota
+	LevelInformational
+	LevelLow
+	LevelMedium
+	LevelHigh
+	LevelCritical
+)
+
+var ruleLevelNames = map[RuleLevel]string{
+	LevelUnknown:       "unknown",
+	LevelInformational: "informational",
+	LevelLow:           "low",
+	LevelMedium:        "medium",
+	LevelHigh:          "high",
+	LevelCritical:      "critical",
+}
+
+func (level RuleLevel) String() string {
+	if name, exists := ruleLevelNames[level]; exists {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseRuleLevel parses a SIGMA level name (case-insensitive); unrecognized
+// names map to LevelUnknown
+func ParseRuleLevel(name string) RuleLevel {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for level, levelName := range ruleLevelNames {
+		if levelName == name {
+			return level
+		}
+	}
+	return LevelUnknown
+}
+
+// RuleMeta carries the source metadata of a compiled rule
+type RuleMeta struct {
+	ID        ir.RuleID
+	SigmaID   string
+	Title     string
+	Level     RuleLevel
+	Status    string
+	Tags      []string
+	LogSource LogSource
+}
+
+// LogSource identifies the log source a rule applies to
+type LogSource struct {
+	Category string
+	Product  string
+	Service  string
+}
+
+// RuleField is an entry of a rule's `fields:` list together with the event
+// field it resolves to after field mapping
+type RuleField struct {
+	Name       string
+	EventField string
 }
 
 func NewCompiledDag() *CompiledDag {
@@ -134,6 +214,8 @@ func NewCompiledDag() *CompiledDag {
 		PrimitiveMap:     make(map[ir.PrimitiveID]NodeId),
 		RuleResults:      make(map[ir.RuleID]NodeId),
 		ResultBufferSize: 0,
+		RuleSelections:   make(map[ir.RuleID]map[string][]ir.PrimitiveID),
+		RuleFields:       make(map[ir.RuleID][]RuleField),
 	}
 }
 
@@ -204,11 +286,29 @@ type DagStatistics struct {
 	AvgFanout            float64
 	SharedPrimitives     int
 	EstimatedMemoryBytes int
+
+	// Most dependents of a single node
+	MaxFanout int
+
+	// Longest chain of nodes from a primitive to a rule result, and the
+	// rule it ends in
+	CriticalPath     int
+	CriticalPathRule ir.RuleID
+
+	// Nodes each rule's result depends on, shared nodes included
+	NodesPerRule map[ir.RuleID]int
+
+	// Primitives per event field (nil when the statistics come from the
+	// DAG alone, which does not know primitive fields)
+	PrimitivesPerField map[string]int
+
+	// Rule groups by anchor word (nil when rule grouping is disabled)
+	RuleGroups *RuleGroupStats
 }
 
 func NewDagStatisticsFromDag(dag *CompiledDag) *DagStatistics {
 	var primitiveNodes, logicalNodes, resultNodes int
-	var totalDependencies int
+	var totalDependencies, maxFanout int
 
 	for _, node := range dag.Nodes {
 		switch node.NodeType.Type {
@@ -222,6 +322,9 @@ func NewDagStatisticsFromDag(dag *CompiledDag) *DagStatistics {
 			primitiveNodes++
 		}
 		totalDependencies += len(node.Dependencies)
+		if len(node.Dependents) > maxFanout {
+			maxFanout = len(node.Dependents)
+		}
 	}
 
 	var avgFanout float64
@@ -229,14 +332,17 @@ func NewDagStatisticsFromDag(dag *CompiledDag) *DagStatistics {
 		avgFanout = float64(totalDependencies) / float64(len(dag.Nodes))
 	}
 
-	maxDepth := calculateMaxDepth(dag)
+	depths := calculateDepths(dag)
+	maxDepth := 0
+	for _, depth := range depths {
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+	}
 	sharedPrimitives := calculateSharedPrimitives(dag)
-	estimatedMemoryBytes := len(dag.Nodes)*120 +
-		len(dag.ExecutionOrder)*4 +
-		len(dag.PrimitiveMap)*12 +
-		len(dag.RuleResults)*12
+	estimatedMemoryBytes := dag.MemoryUsage().TotalBytes
 
-	return &DagStatistics{
+	stats := &DagStatistics{
 		TotalNodes:           len(dag.Nodes),
 		PrimitiveNodes:       primitiveNodes,
 		LogicalNodes:         logicalNodes,
@@ -245,16 +351,70 @@ func NewDagStatisticsFromDag(dag *CompiledDag) *DagStatistics {
 		AvgFanout:            avgFanout,
 		SharedPrimitives:     sharedPrimitives,
 		EstimatedMemoryBytes: estimatedMemoryBytes,
+		MaxFanout:            maxFanout,
+		NodesPerRule:         calculateNodesPerRule(dag),
+	}
+	for ruleId, resultId := range dag.RuleResults {
+		depth := depths[resultId]
+		if depth > stats.CriticalPath || (depth == stats.CriticalPath && depth > 0 && ruleId < stats.CriticalPathRule) {
+			stats.CriticalPath, stats.CriticalPathRule = depth, ruleId
+		}
 	}
+	return stats
 }
 
-func calculateMaxDepth(dag *CompiledDag) int {
-	if len(dag.Nodes) == 0 {
-		return 0
+// String renders the statistics as a multi-line report
+func (s *DagStatistics) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "nodes: %d (%d primitive, %d logical, %d result)\n",
+		s.TotalNodes, s.PrimitiveNodes, s.LogicalNodes, s.ResultNodes)
+	fmt.Fprintf(&b, "depth: %d, critical path: %d", s.MaxDepth, s.CriticalPath)
+	if s.CriticalPath > 0 {
+		fmt.Fprintf(&b, " (rule %d)", s.CriticalPathRule)
 	}
+	fmt.Fprintf(&b, "\nfanout: %.2f average, %d max\n", s.AvgFanout, s.MaxFanout)
+	fmt.Fprintf(&b, "shared primitives: %d\n", s.SharedPrimitives)
 
+	if len(s.NodesPerRule) > 0 {
+		counts := make([]int, 0, len(s.NodesPerRule))
+		for _, count := range s.NodesPerRule {
+			counts = append(counts, count)
+		}
+		sort.Ints(counts)
+		fmt.Fprintf(&b, "nodes per rule: %d min, %d median, %d max over %d rules\n",
+			counts[0], counts[len(counts)/2], counts[len(counts)-1], len(counts))
+	}
+
+	if len(s.PrimitivesPerField) > 0 {
+		fields := make([]string, 0, len(s.PrimitivesPerField))
+		for field := range s.PrimitivesPerField {
+			fields = append(fields, field)
+		}
+		sort.Slice(fields, func(i, j int) bool {
+			a, b := s.PrimitivesPerField[fields[i]], s.PrimitivesPerField[fields[j]]
+			if a != b {
+				return a > b
+			}
+			return fields[i] < fields[j]
+		})
+		b.WriteString("primitives per field:")
+		for i, field := range fields {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, " %s %d", field, s.PrimitivesPerField[field])
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "estimated memory: %d bytes", s.EstimatedMemoryBytes)
+	return b.String()
+}
+
+// calculateDepths returns the depth of every node in the execution order,
+// counting nodes from a dependency-free node (depth 1)
+func calculateDepths(dag *CompiledDag) map[NodeId]int {
 	depths := make(map[NodeId]int)
-	maxDepth := 0
 
 	for _, nodeId := range dag.ExecutionOrder {
 		node := dag.GetNode(nodeId)
@@ -276,12 +436,32 @@ func calculateMaxDepth(dag *CompiledDag) int {
 		}
 
 		depths[nodeId] = nodeDepth
-		if nodeDepth > maxDepth {
-			maxDepth = nodeDepth
-		}
 	}
 
-	return maxDepth
+	return depths
+}
+
+// calculateNodesPerRule counts the nodes each rule's result node depends
+// on, directly or indirectly, itself included
+func calculateNodesPerRule(dag *CompiledDag) map[ir.RuleID]int {
+	index := nodeIndex(dag)
+	counts := make(map[ir.RuleID]int, len(dag.RuleResults))
+	for ruleId, resultId := range dag.RuleResults {
+		visited := make(map[NodeId]bool)
+		stack := []NodeId{resultId}
+		for len(stack) > 0 {
+			nodeId := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			position, exists := index[nodeId]
+			if visited[nodeId] || !exists {
+				continue
+			}
+			visited[nodeId] = true
+			stack = append(stack, dag.Nodes[position].Dependencies...)
+		}
+		counts[ruleId] = len(visited)
+	}
+	return counts
 }
 
 func calculateSharedPrimitives(dag *CompiledDag) int {
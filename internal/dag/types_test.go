@@ -1,7 +1,10 @@
 package dag
 
 import (
+	"reflect"
 	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
 )
 
 func createTestDagForTypes() *CompiledDag {
@@ -483,6 +486,46 @@ func TestDagStatisticsComplexDag(t *testing.T) {
 	}
 }
 
+func TestDagStatisticsRulePaths(t *testing.T) {
+	dag := createTestDagForTypes()
+
+	// Rule 2 negates primitive 1, sharing it with rule 1
+	not := NewDagNode(4, NewLogicalNodeType(LogicalNot))
+	not.AddDependency(1)
+	not.AddDependent(5)
+	result := NewDagNode(5, NewResultNodeType(2))
+	result.AddDependency(4)
+	dag.AddNode(*not)
+	dag.AddNode(*result)
+	dag.Nodes[1].AddDependent(4)
+	dag.RuleResults[2] = 5
+	dag.ExecutionOrder = append(dag.ExecutionOrder, 4, 5)
+
+	stats := NewDagStatisticsFromDag(dag)
+	if stats.MaxFanout != 2 {
+		t.Errorf("Expected MaxFanout = 2, got %v", stats.MaxFanout)
+	}
+	if stats.CriticalPath != 3 || stats.CriticalPathRule != 1 {
+		t.Errorf("Expected a critical path of 3 nodes in rule 1, got %v in rule %v", stats.CriticalPath, stats.CriticalPathRule)
+	}
+	if !reflect.DeepEqual(stats.NodesPerRule, map[ir.RuleID]int{1: 4, 2: 3}) {
+		t.Errorf("Unexpected nodes per rule: %v", stats.NodesPerRule)
+	}
+
+	stats.PrimitivesPerField = map[string]int{"EventID": 1, "Image": 2}
+	stats.EstimatedMemoryBytes = 1024
+	expected := `nodes: 6 (2 primitive, 2 logical, 2 result)
+depth: 3, critical path: 3 (rule 1)
+fanout: 0.83 average, 2 max
+shared primitives: 0
+nodes per rule: 3 min, 4 median, 4 max over 2 rules
+primitives per field: Image 2, EventID 1
+estimated memory: 1024 bytes`
+	if stats.String() != expected {
+		t.Errorf("Unexpected statistics report:\n%s\nexpected:\n%s", stats, expected)
+	}
+}
+
 func TestDagStatisticsSharedPrimitives(t *testing.T) {
 	dag := NewCompiledDag()
 
@@ -522,3 +565,15 @@ func indexOf(s, substr string) int {
 	}
 	return -1
 }
+
+func TestParseRuleLevel(t *testing.T) {
+	if ParseRuleLevel(" High ") != LevelHigh {
+		t.Error("Expected level names to be parsed case-insensitively")
+	}
+	if ParseRuleLevel("severe") != LevelUnknown {
+		t.Error("Expected unknown level name to map to LevelUnknown")
+	}
+	if LevelCritical.String() != "critical" {
+		t.Errorf("Unexpected level name: %s", LevelCritical.String())
+	}
+}
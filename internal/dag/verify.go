@@ -0,0 +1,284 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// Checks run by DagEngine.Verify
+const (
+	// The DAG structure: node IDs, dependencies and execution order
+	CheckDag = "dag"
+	// Every rule has a result node that depends on a primitive or constant
+	CheckRuleReachable = "rule_reachable"
+	// Primitive nodes and compiled primitives refer to each other
+	CheckPrimitiveReferenced = "primitive_referenced"
+	// Every primitive has a matcher registered for its match type
+	CheckMatcherRegistered = "matcher_registered"
+	// The prefilter patterns are the values of the literal primitives
+	CheckPrefilter = "prefilter"
+)
+
+// VerifyIssue is an invariant of the engine's compiled state that does not
+// hold.
+type VerifyIssue struct {
+	// Check that found the issue, one of the Check* constants
+	Check   string
+	Message string
+}
+
+func (issue VerifyIssue) String() string {
+	return fmt.Sprintf("%s: %s", issue.Check, issue.Message)
+}
+
+// VerifyReport is the outcome of DagEngine.Verify.
+type VerifyReport struct {
+	// Compiled state checked
+	Nodes      int
+	Rules      int
+	Primitives int
+
+	// Violated invariants, grouped by check
+	Issues []VerifyIssue
+}
+
+// OK reports whether every invariant holds
+func (r *VerifyReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// Err returns the issues as a single compilation error, or nil when every
+// invariant holds
+func (r *VerifyReport) Err() error {
+	if r.OK() {
+		return nil
+	}
+	messages := make([]string, len(r.Issues))
+	for i, issue := range r.Issues {
+		messages[i] = issue.String()
+	}
+	return errors.NewCompilationError(fmt.Sprintf("engine verification failed: %s", strings.Join(messages, "; ")))
+}
+
+func (r *VerifyReport) addIssue(check, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, VerifyIssue{Check: check, Message: fmt.Sprintf(format, args...)})
+}
+
+// Verify checks the invariants of the engine's compiled state: a valid DAG,
+// a reachable result node for every rule, primitive nodes and compiled
+// primitives that refer to each other, a registered matcher for every
+// match type and prefilter patterns consistent with the primitives. A
+// malformed engine otherwise evaluates to no matches without an error.
+func (e *DagEngine) Verify() *VerifyReport {
+	report := &VerifyReport{
+		Nodes:      len(e.dag.Nodes),
+		Rules:      len(e.dag.RuleResults),
+		Primitives: len(e.primitives),
+	}
+	e.verifyDag(report)
+	e.verifyRules(report)
+	e.verifyPrimitives(report)
+	e.verifyMatchers(report)
+	e.verifyPrefilter(report)
+	return report
+}
+
+// verifyDag checks the DAG structure. Evaluators address nodes by ID, so
+// every node must sit at the position of its ID and follow its
+// dependencies in the execution order.
+func (e *DagEngine) verifyDag(report *VerifyReport) {
+	if err := e.dag.Validate(); err != nil {
+		report.addIssue(CheckDag, "%v", err)
+	}
+	for i := range e.dag.Nodes {
+		if id := e.dag.Nodes[i].ID; int(id) != i {
+			report.addIssue(CheckDag, "node %d is stored at position %d", id, i)
+		}
+	}
+
+	position := make(map[NodeId]int, len(e.dag.ExecutionOrder))
+	for i, nodeId := range e.dag.ExecutionOrder {
+		if _, exists := position[nodeId]; exists {
+			report.addIssue(CheckDag, "node %d appears twice in the execution order", nodeId)
+		}
+		position[nodeId] = i
+	}
+	for i := range e.dag.Nodes {
+		nodeId := e.dag.Nodes[i].ID
+		nodePosition, ordered := position[nodeId]
+		if !ordered {
+			report.addIssue(CheckDag, "node %d is missing from the execution order", nodeId)
+			continue
+		}
+		for _, depId := range e.dag.DependenciesOf(nodeId) {
+			if depPosition, exists := position[depId]; exists && depPosition > nodePosition {
+				report.addIssue(CheckDag, "node %d is evaluated before its dependency %d", nodeId, depId)
+			}
+		}
+	}
+}
+
+// verifyRules checks that every rule has a result node for it which
+// depends, directly or not, on a primitive or a folded constant
+func (e *DagEngine) verifyRules(report *VerifyReport) {
+	for _, ruleId := range sortedRuleIDs(e.dag.RuleResults) {
+		resultId := e.dag.RuleResults[ruleId]
+		node := e.dag.GetNode(resultId)
+		if node == nil {
+			report.addIssue(CheckRuleReachable, "rule %d has no result node %d", ruleId, resultId)
+			continue
+		}
+		if node.NodeType.Type != "Result" || node.NodeType.RuleId == nil || *node.NodeType.RuleId != ruleId {
+			report.addIssue(CheckRuleReachable, "node %d is not the result node of rule %d", resultId, ruleId)
+			continue
+		}
+		if !e.reachesLeaf(resultId) {
+			report.addIssue(CheckRuleReachable, "rule %d does not depend on any primitive", ruleId)
+		}
+	}
+
+	ruleIds := make([]ir.RuleID, 0, len(e.rules))
+	for ruleId := range e.rules {
+		ruleIds = append(ruleIds, ruleId)
+	}
+	sort.Slice(ruleIds, func(i, j int) bool { return ruleIds[i] < ruleIds[j] })
+	for _, ruleId := range ruleIds {
+		if _, exists := e.dag.RuleResults[ruleId]; !exists {
+			report.addIssue(CheckRuleReachable, "rule %d (%s) has no result node", ruleId, e.rules[ruleId].Title)
+		}
+	}
+}
+
+// reachesLeaf reports whether a node depends on a primitive node or a node
+// with a folded result
+func (e *DagEngine) reachesLeaf(nodeId NodeId) bool {
+	visited := make(map[NodeId]bool)
+	stack := []NodeId{nodeId}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		node := e.dag.GetNode(current)
+		if visited[current] || node == nil {
+			continue
+		}
+		visited[current] = true
+		if node.NodeType.Type == "Primitive" || node.CachedResult != nil {
+			return true
+		}
+		stack = append(stack, e.dag.DependenciesOf(current)...)
+	}
+	return false
+}
+
+// verifyPrimitives checks that every primitive node has a compiled
+// primitive and every compiled primitive a node
+func (e *DagEngine) verifyPrimitives(report *VerifyReport) {
+	referenced := make(map[uint32]bool, len(e.primitives))
+	for i := range e.dag.Nodes {
+		node := &e.dag.Nodes[i]
+		if node.NodeType.Type != "Primitive" {
+			continue
+		}
+		if node.NodeType.PrimitiveId == nil {
+			report.addIssue(CheckPrimitiveReferenced, "primitive node %d has no primitive", node.ID)
+			continue
+		}
+		primitiveId := uint32(*node.NodeType.PrimitiveId)
+		referenced[primitiveId] = true
+		if _, exists := e.primitives[primitiveId]; !exists && node.CachedResult == nil {
+			report.addIssue(CheckPrimitiveReferenced, "node %d references unknown primitive %d", node.ID, primitiveId)
+		}
+	}
+
+	for _, primitiveId := range e.sortedPrimitiveIDs() {
+		if !referenced[primitiveId] {
+			report.addIssue(CheckPrimitiveReferenced, "primitive %d (%s) is not referenced by any node",
+				primitiveId, e.primitives[primitiveId].Field)
+		}
+	}
+}
+
+// verifyMatchers checks that every primitive is matched by a registered
+// matcher rather than the equality fallback for unknown match types
+func (e *DagEngine) verifyMatchers(report *VerifyReport) {
+	for _, primitiveId := range e.sortedPrimitiveIDs() {
+		primitive := e.primitives[primitiveId]
+		if primitive.Matcher == nil {
+			report.addIssue(CheckMatcherRegistered, "primitive %d (%s) has no matcher registered for match type %q",
+				primitiveId, primitive.Field, primitive.MatchType)
+		}
+	}
+}
+
+// verifyPrefilter checks that the prefilter holds exactly the values of the
+// literal primitives and that its statistics describe it
+func (e *DagEngine) verifyPrefilter(report *VerifyReport) {
+	if e.prefilter == nil {
+		return
+	}
+
+	expected := make(map[string]bool)
+	for _, primitive := range e.primitives {
+		if isLiteralMatchType(primitive.MatchType) {
+			for _, value := range primitive.Values {
+				expected[value] = true
+			}
+		}
+	}
+
+	var missing, extra []string
+	for value := range expected {
+		if !e.prefilter.index.matches(value) {
+			missing = append(missing, value)
+		}
+	}
+	// The Bloom filter keeps no patterns to compare
+	for value := range e.prefilter.patterns {
+		if !expected[value] {
+			extra = append(extra, value)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	if len(missing) > 0 {
+		report.addIssue(CheckPrefilter, "patterns missing for primitive values %q", missing)
+	}
+	if len(extra) > 0 {
+		report.addIssue(CheckPrefilter, "patterns %q match no primitive", extra)
+	}
+
+	held := len(e.prefilter.patterns)
+	if filter, ok := e.prefilter.index.(*bloomFilter); ok {
+		held = filter.count
+	}
+	if count := e.prefilter.stats.PatternCount; count != held {
+		report.addIssue(CheckPrefilter, "statistics count %d patterns, the prefilter holds %d", count, held)
+	}
+	if name := e.prefilter.index.strategy().String(); e.prefilter.stats.StrategyName != name {
+		report.addIssue(CheckPrefilter, "statistics name strategy %s, the prefilter uses %s", e.prefilter.stats.StrategyName, name)
+	}
+}
+
+// sortedPrimitiveIDs returns the IDs of the compiled primitives in order
+func (e *DagEngine) sortedPrimitiveIDs() []uint32 {
+	ids := make([]uint32, 0, len(e.primitives))
+	for id := range e.primitives {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// sortedRuleIDs returns the rules of a rule result map in order
+func sortedRuleIDs(results map[ir.RuleID]NodeId) []ir.RuleID {
+	ids := make([]ir.RuleID, 0, len(results))
+	for id := range results {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,92 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+func newVerifyTestEngine(t *testing.T, optimize bool) *DagEngine {
+	t.Helper()
+	ruleset := createTestRuleset()
+	ruleset.Dag = createTestDag()
+	ruleset.Rules = []RuleMeta{{ID: 1, Title: "Logon"}}
+
+	config := DefaultDagEngineConfig()
+	config.EnableOptimization = optimize
+	engine, err := NewDagEngineFromRulesetWithConfig(ruleset, config)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return engine
+}
+
+func TestVerifyHealthyEngine(t *testing.T) {
+	for _, optimize := range []bool{false, true} {
+		report := newVerifyTestEngine(t, optimize).Verify()
+		if !report.OK() || report.Err() != nil {
+			t.Errorf("Expected a healthy engine (optimized: %v), got %v", optimize, report.Issues)
+		}
+		if report.Nodes != 4 || report.Rules != 1 || report.Primitives != 2 {
+			t.Errorf("Unexpected verified counts: %+v", report)
+		}
+	}
+}
+
+func TestVerifyReportsBrokenInvariants(t *testing.T) {
+	engine := newVerifyTestEngine(t, false)
+
+	// A rule without a result node, a primitive no node references with an
+	// unregistered match type, and a stray prefilter pattern that the
+	// prefilter statistics do not count
+	engine.rules[2] = RuleMeta{ID: 2, Title: "Orphan"}
+	engine.primitives[7] = &CompiledPrimitive{ID: 7, Field: "User", MatchType: "soundex", Values: []string{"alice"}}
+	engine.prefilter.patterns["stray"] = true
+	// A rule whose result node depends on nothing
+	engine.dag.Nodes[3].Dependencies = nil
+	engine.dag.Compact()
+
+	report := engine.Verify()
+	checks := make(map[string]int)
+	for _, issue := range report.Issues {
+		checks[issue.Check]++
+	}
+	expected := map[string]int{
+		CheckRuleReachable:       2,
+		CheckPrimitiveReferenced: 1,
+		CheckMatcherRegistered:   1,
+		CheckPrefilter:           2,
+	}
+	for check, count := range expected {
+		if checks[check] != count {
+			t.Errorf("Expected %d %s issues, got %v", count, check, report.Issues)
+		}
+	}
+	if checks[CheckDag] != 0 {
+		t.Errorf("Expected the DAG structure to verify, got %v", report.Issues)
+	}
+
+	err := report.Err()
+	if !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeCompilation) || !strings.Contains(err.Error(), "Orphan") {
+		t.Errorf("Expected a compilation error naming the orphan rule, got %v", err)
+	}
+}
+
+func TestVerifyReportsMalformedDag(t *testing.T) {
+	engine := newVerifyTestEngine(t, false)
+	engine.dag.ExecutionOrder = []NodeId{3, 0, 1, 2}
+	engine.dag.Nodes[1].ID = 5
+
+	report := engine.Verify()
+	var messages []string
+	for _, issue := range report.Issues {
+		if issue.Check == CheckDag {
+			messages = append(messages, issue.Message)
+		}
+	}
+	joined := strings.Join(messages, "\n")
+	if !strings.Contains(joined, "node 5 is stored at position 1") || !strings.Contains(joined, "node 3 is evaluated before its dependency 2") {
+		t.Errorf("Expected misplaced and misordered nodes to be reported, got %v", report.Issues)
+	}
+}
@@ -0,0 +1,456 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// Opcode is a bytecode operation
+type Opcode uint8
+
+const (
+	// OpPrimitive pushes the result of primitive Arg
+	OpPrimitive Opcode = iota
+	// OpConst pushes Arg != 0
+	OpConst
+	// OpAnd pops Arg values and pushes true when all are true (false for none)
+	OpAnd
+	// OpOr pops Arg values and pushes true when any is true
+	OpOr
+	// OpNot replaces the top of the stack with its negation
+	OpNot
+)
+
+var opcodeNames = map[Opcode]string{
+	OpPrimitive: "PRIMITIVE",
+	OpConst:     "CONST",
+	OpAnd:       "AND",
+	OpOr:        "OR",
+	OpNot:       "NOT",
+}
+
+func (op Opcode) String() string {
+	if name, exists := opcodeNames[op]; exists {
+		return name
+	}
+	return fmt.Sprintf("Unknown Opcode: %d", op)
+}
+
+// Instructions are packed into 32 bits: an 8-bit opcode and a 24-bit argument
+const (
+	instructionArgBits = 24
+	maxInstructionArg  = 1<<instructionArgBits - 1
+
+	// Bounds on compiled programs, checked when they are compiled or loaded
+	maxProgramLength = 1 << 20
+	maxVMStackDepth  = 1024
+)
+
+// Instruction is a packed bytecode instruction
+type Instruction uint32
+
+// NewInstruction packs an opcode and its argument
+func NewInstruction(op Opcode, arg uint32) (Instruction, error) {
+	if arg > maxInstructionArg {
+		return 0, errors.NewInvalidBytecode(fmt.Sprintf("%s argument %d exceeds %d", op, arg, maxInstructionArg))
+	}
+	return Instruction(uint32(op)<<instructionArgBits | arg), nil
+}
+
+// Op returns the instruction opcode
+func (inst Instruction) Op() Opcode {
+	return Opcode(inst >> instructionArgBits)
+}
+
+// Arg returns the instruction argument
+func (inst Instruction) Arg() uint32 {
+	return uint32(inst) & maxInstructionArg
+}
+
+func (inst Instruction) String() string {
+	if inst.Op() == OpNot {
+		return inst.Op().String()
+	}
+	return fmt.Sprintf("%s %d", inst.Op(), inst.Arg())
+}
+
+// RuleProgram is the bytecode of a single rule's condition
+type RuleProgram struct {
+	RuleID ir.RuleID
+	Code   []Instruction
+
+	// Maximum stack depth reached while running the program
+	MaxStack int
+}
+
+// Validate checks that the program is well formed: known opcodes, a stack
+// that never underflows or exceeds the VM limit, and exactly one result
+func (program *RuleProgram) Validate() error {
+	if len(program.Code) > maxProgramLength {
+		return errors.NewInvalidBytecode(fmt.Sprintf("rule %d: program has %d instructions (max %d)", program.RuleID, len(program.Code), maxProgramLength))
+	}
+
+	depth, maxDepth := 0, 0
+	for pc, inst := range program.Code {
+		pops, pushes := 0, 1
+		switch inst.Op() {
+		case OpPrimitive, OpConst:
+		case OpAnd, OpOr:
+			pops = int(inst.Arg())
+		case OpNot:
+			pops = 1
+		default:
+			return errors.NewInvalidBytecode(fmt.Sprintf("rule %d: unknown opcode %d at %d", program.RuleID, inst.Op(), pc))
+		}
+		if depth < pops {
+			return errors.NewStackUnderflow()
+		}
+		depth += pushes - pops
+		if depth > maxVMStackDepth {
+			return errors.NewStackOverflow()
+		}
+		maxDepth = max(maxDepth, depth)
+	}
+	if depth != 1 {
+		return errors.NewInvalidBytecode(fmt.Sprintf("rule %d: program leaves %d values on the stack", program.RuleID, depth))
+	}
+	program.MaxStack = maxDepth
+	return nil
+}
+
+// CompileBytecode compiles every rule of a DAG into a bytecode program,
+// sorted by rule ID. Each program is the postfix form of the rule's
+// condition; logical nodes shared between rules or branches are repeated
+// in every program that uses them, while primitive results are still
+// matched once per event.
+func CompileBytecode(dag *CompiledDag) ([]RuleProgram, error) {
+	ruleIds := make([]ir.RuleID, 0, len(dag.RuleResults))
+	for ruleId := range dag.RuleResults {
+		ruleIds = append(ruleIds, ruleId)
+	}
+	sort.Slice(ruleIds, func(i, j int) bool { return ruleIds[i] < ruleIds[j] })
+
+	programs := make([]RuleProgram, 0, len(ruleIds))
+	for _, ruleId := range ruleIds {
+		program := RuleProgram{RuleID: ruleId}
+		if err := emitNode(dag, dag.RuleResults[ruleId], &program, make(map[NodeId]bool)); err != nil {
+			return nil, err
+		}
+		if err := program.Validate(); err != nil {
+			return nil, err
+		}
+		programs = append(programs, program)
+	}
+	return programs, nil
+}
+
+// emitNode appends the postfix code of a node, mirroring the DAG
+// evaluator's semantics for each node type
+func emitNode(dag *CompiledDag, nodeId NodeId, program *RuleProgram, visiting map[NodeId]bool) error {
+	node := dag.GetNode(nodeId)
+	if node == nil {
+		return errors.NewInvalidBytecode(fmt.Sprintf("rule %d: node not found: %d", program.RuleID, nodeId))
+	}
+	if visiting[nodeId] {
+		return errors.NewInvalidBytecode(fmt.Sprintf("rule %d: cycle at node %d", program.RuleID, nodeId))
+	}
+	if len(program.Code) >= maxProgramLength {
+		return errors.NewInvalidBytecode(fmt.Sprintf("rule %d: program exceeds %d instructions", program.RuleID, maxProgramLength))
+	}
+	visiting[nodeId] = true
+	defer delete(visiting, nodeId)
+
+	emit := func(op Opcode, arg uint32) error {
+		inst, err := NewInstruction(op, arg)
+		if err != nil {
+			return err
+		}
+		program.Code = append(program.Code, inst)
+		return nil
+	}
+
+	dependencies := dag.DependenciesOf(nodeId)
+	switch node.NodeType.Type {
+	case "Primitive":
+		if node.NodeType.PrimitiveId == nil {
+			return emit(OpConst, 0)
+		}
+		return emit(OpPrimitive, uint32(*node.NodeType.PrimitiveId))
+
+	case "Logical":
+		if node.NodeType.Operation == nil {
+			return emit(OpConst, 0)
+		}
+		switch *node.NodeType.Operation {
+		case LogicalAnd, LogicalOr:
+			if len(dependencies) == 0 {
+				return emit(OpConst, 0)
+			}
+			op := OpAnd
+			if *node.NodeType.Operation == LogicalOr {
+				op = OpOr
+			}
+			// Fold operands pairwise so the stack depth follows the
+			// condition's nesting rather than its width
+			for i, depId := range dependencies {
+				if err := emitNode(dag, depId, program, visiting); err != nil {
+					return err
+				}
+				if i > 0 {
+					if err := emit(op, 2); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		case LogicalNot:
+			if len(dependencies) != 1 {
+				return emit(OpConst, 0)
+			}
+			if err := emitNode(dag, dependencies[0], program, visiting); err != nil {
+				return err
+			}
+			return emit(OpNot, 0)
+		default:
+			return emit(OpConst, 0)
+		}
+
+	case "Result":
+		if len(dependencies) != 1 {
+			return emit(OpConst, 0)
+		}
+		return emitNode(dag, dependencies[0], program, visiting)
+
+	case "Prefilter":
+		return emit(OpConst, 1)
+
+	default:
+		return emit(OpConst, 0)
+	}
+}
+
+// BytecodeVM evaluates events by running each rule's bytecode program over
+// the same compiled primitives as the DAG evaluator
+type BytecodeVM struct {
+	programs   []RuleProgram
+	primitives map[uint32]*CompiledPrimitive
+	inactive   map[ir.RuleID]bool
+
+	// Reused per-event state
+	stack              []bool
+	primitiveResults   Bitset
+	primitiveEvaluated Bitset
+	eventCtx           *matcher.EventContext
+	captureFields      bool
+	ruleFields         map[ir.RuleID][]RuleField
+	eventTimeout       time.Duration
+	clock              clock.Clock
+	foldFieldCase      bool
+	primitiveErrors    *primitiveErrors
+}
+
+// NewBytecodeVM compiles the DAG's rules and creates a VM over the primitives
+func NewBytecodeVM(dag *CompiledDag, primitives map[uint32]*CompiledPrimitive) (*BytecodeVM, error) {
+	programs, err := CompileBytecode(dag)
+	if err != nil {
+		return nil, err
+	}
+	return NewBytecodeVMFromPrograms(programs, primitives)
+}
+
+// NewBytecodeVMFromPrograms creates a VM from already compiled programs,
+// validating each of them
+func NewBytecodeVMFromPrograms(programs []RuleProgram, primitives map[uint32]*CompiledPrimitive) (*BytecodeVM, error) {
+	maxStack, primitiveCapacity := 0, 0
+	for i := range programs {
+		if err := programs[i].Validate(); err != nil {
+			return nil, err
+		}
+		maxStack = max(maxStack, programs[i].MaxStack)
+		for _, inst := range programs[i].Code {
+			if inst.Op() == OpPrimitive {
+				primitiveCapacity = max(primitiveCapacity, int(inst.Arg())+1)
+			}
+		}
+	}
+
+	return &BytecodeVM{
+		programs:           programs,
+		primitives:         primitives,
+		stack:              make([]bool, 0, maxStack),
+		primitiveResults:   NewBitset(primitiveCapacity),
+		primitiveEvaluated: NewBitset(primitiveCapacity),
+	}, nil
+}
+
+// withOptions applies engine evaluation settings to the VM. Match details
+// are not supported; rule field capture is.
+func (vm *BytecodeVM) withOptions(options evaluatorOptions, ruleFields map[ir.RuleID][]RuleField) *BytecodeVM {
+	vm.inactive = inactiveRuleSet(options.inactiveRules)
+	vm.captureFields = options.captureFields
+	vm.ruleFields = ruleFields
+	vm.eventTimeout = options.eventTimeout
+	vm.clock = options.clock
+	vm.foldFieldCase = options.foldFieldCase
+	vm.primitiveErrors = options.primitiveErrors
+	return vm
+}
+
+// Backend identifies the VM as the bytecode backend
+func (vm *BytecodeVM) Backend() Backend {
+	return BackendVM
+}
+
+// Programs returns the compiled rule programs
+func (vm *BytecodeVM) Programs() []RuleProgram {
+	return vm.programs
+}
+
+// Evaluate runs every active rule program against an event. Matched rules
+// are reported in rule ID order.
+func (vm *BytecodeVM) Evaluate(event interface{}) (*DagEvaluationResult, error) {
+	if !matcher.IsSupportedEvent(event) {
+		return nil, matcher.ErrUnsupportedEvent
+	}
+
+	vm.eventCtx = newEventContext(event, vm.eventTimeout, vm.clock, vm.foldFieldCase)
+	defer func() { vm.eventCtx = nil }()
+	vm.primitiveResults.Reset()
+	vm.primitiveEvaluated.Reset()
+
+	result := NewDagEvaluationResult()
+	for i := range vm.programs {
+		program := &vm.programs[i]
+		if vm.inactive[program.RuleID] {
+			continue
+		}
+		matched, err := vm.run(program, event, result)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			result.MatchedRules = append(result.MatchedRules, program.RuleID)
+		}
+	}
+
+	if vm.captureFields && len(result.MatchedRules) > 0 {
+		result.RuleMatches = make([]RuleMatch, 0, len(result.MatchedRules))
+		for _, ruleId := range result.MatchedRules {
+			result.RuleMatches = append(result.RuleMatches, RuleMatch{RuleID: ruleId, Fields: extractRuleFields(vm.eventCtx, vm.ruleFields[ruleId])})
+		}
+	}
+	result.PrimitiveErrors = vm.eventCtx.RecoveredErrors()
+	return result, nil
+}
+
+// EvaluateAnyMatch reports whether any active rule matches an event,
+// stopping at the first rule program that matches
+func (vm *BytecodeVM) EvaluateAnyMatch(event interface{}) (bool, error) {
+	if !matcher.IsSupportedEvent(event) {
+		return false, matcher.ErrUnsupportedEvent
+	}
+
+	vm.eventCtx = newEventContext(event, vm.eventTimeout, vm.clock, vm.foldFieldCase)
+	defer func() { vm.eventCtx = nil }()
+	vm.primitiveResults.Reset()
+	vm.primitiveEvaluated.Reset()
+
+	result := NewDagEvaluationResult()
+	for i := range vm.programs {
+		program := &vm.programs[i]
+		if vm.inactive[program.RuleID] {
+			continue
+		}
+		matched, err := vm.run(program, event, result)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+// run executes a single program
+func (vm *BytecodeVM) run(program *RuleProgram, event interface{}, result *DagEvaluationResult) (bool, error) {
+	stack := vm.stack[:0]
+	for _, inst := range program.Code {
+		result.NodesEvaluated++
+
+		switch inst.Op() {
+		case OpPrimitive:
+			if len(stack) >= maxVMStackDepth {
+				return false, errors.NewStackOverflow()
+			}
+			matched, err := vm.primitive(ir.PrimitiveID(inst.Arg()), event, result)
+			if err != nil {
+				return false, err
+			}
+			stack = append(stack, matched)
+
+		case OpConst:
+			if len(stack) >= maxVMStackDepth {
+				return false, errors.NewStackOverflow()
+			}
+			stack = append(stack, inst.Arg() != 0)
+
+		case OpAnd, OpOr:
+			count := int(inst.Arg())
+			if len(stack) < count {
+				return false, errors.NewStackUnderflow()
+			}
+			operands := stack[len(stack)-count:]
+			value := inst.Op() == OpAnd && count > 0
+			for _, operand := range operands {
+				if inst.Op() == OpAnd && !operand {
+					value = false
+					break
+				}
+				if inst.Op() == OpOr && operand {
+					value = true
+					break
+				}
+			}
+			stack = append(stack[:len(stack)-count], value)
+
+		case OpNot:
+			if len(stack) == 0 {
+				return false, errors.NewStackUnderflow()
+			}
+			stack[len(stack)-1] = !stack[len(stack)-1]
+
+		default:
+			return false, errors.NewInvalidBytecode(fmt.Sprintf("rule %d: unknown opcode %d", program.RuleID, inst.Op()))
+		}
+	}
+	vm.stack = stack
+
+	if len(stack) != 1 {
+		return false, errors.NewInvalidBytecode(fmt.Sprintf("rule %d: program leaves %d values on the stack", program.RuleID, len(stack)))
+	}
+	return stack[0], nil
+}
+
+// primitive matches a primitive at most once per event
+func (vm *BytecodeVM) primitive(primitiveId ir.PrimitiveID, event interface{}, result *DagEvaluationResult) (bool, error) {
+	if vm.primitiveEvaluated.Test(uint32(primitiveId)) {
+		return vm.primitiveResults.Test(uint32(primitiveId)), nil
+	}
+
+	matched := false
+	if primitive, exists := vm.primitives[uint32(primitiveId)]; exists && primitive != nil {
+		result.PrimitiveEvaluations++
+		var err error
+		matched, err = matchPrimitive(primitiveId, primitive, vm.eventCtx, event, vm.primitiveErrors)
+		if err != nil {
+			return false, err
+		}
+	}
+	vm.primitiveEvaluated.SetTo(uint32(primitiveId), true)
+	vm.primitiveResults.SetTo(uint32(primitiveId), matched)
+	return matched, nil
+}
@@ -0,0 +1,146 @@
+package dag
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// program assembles a rule program, failing the test on invalid instructions
+func program(t *testing.T, code ...[2]uint32) RuleProgram {
+	t.Helper()
+	p := RuleProgram{}
+	for _, pair := range code {
+		inst, err := NewInstruction(Opcode(pair[0]), pair[1])
+		if err != nil {
+			t.Fatalf("Failed to create instruction: %v", err)
+		}
+		p.Code = append(p.Code, inst)
+	}
+	return p
+}
+
+func TestInstructionPacking(t *testing.T) {
+	inst, err := NewInstruction(OpPrimitive, 123456)
+	if err != nil {
+		t.Fatalf("Failed to create instruction: %v", err)
+	}
+	if inst.Op() != OpPrimitive || inst.Arg() != 123456 || inst.String() != "PRIMITIVE 123456" {
+		t.Errorf("Unexpected instruction %s", inst)
+	}
+	if _, err := NewInstruction(OpPrimitive, maxInstructionArg+1); err == nil {
+		t.Error("Expected an error for an argument wider than 24 bits")
+	}
+}
+
+func TestRuleProgramValidate(t *testing.T) {
+	manyConsts := make([][2]uint32, maxVMStackDepth+1)
+	for i := range manyConsts {
+		manyConsts[i] = [2]uint32{uint32(OpConst), 1}
+	}
+
+	tests := []struct {
+		name     string
+		program  RuleProgram
+		expected sigmaerrors.ErrorType
+	}{
+		{"underflow", program(t, [2]uint32{uint32(OpConst), 1}, [2]uint32{uint32(OpAnd), 2}), sigmaerrors.ErrorTypeStackUnderflow},
+		{"overflow", program(t, manyConsts...), sigmaerrors.ErrorTypeStackOverflow},
+		{"opcode", program(t, [2]uint32{99, 0}), sigmaerrors.ErrorTypeInvalidBytecode},
+		{"leftover", program(t, [2]uint32{uint32(OpConst), 1}, [2]uint32{uint32(OpConst), 0}), sigmaerrors.ErrorTypeInvalidBytecode},
+	}
+	for _, tt := range tests {
+		err := tt.program.Validate()
+		var sigmaErr *sigmaerrors.SigmaError
+		if !errors.As(err, &sigmaErr) || sigmaErr.Type != tt.expected {
+			t.Errorf("%s: expected error type %v, got %v", tt.name, tt.expected, err)
+		}
+		if _, err := NewBytecodeVMFromPrograms([]RuleProgram{tt.program}, nil); err == nil {
+			t.Errorf("%s: expected the VM to reject the program", tt.name)
+		}
+	}
+
+	valid := program(t,
+		[2]uint32{uint32(OpPrimitive), 0},
+		[2]uint32{uint32(OpConst), 1},
+		[2]uint32{uint32(OpOr), 2},
+		[2]uint32{uint32(OpNot), 0})
+	if err := valid.Validate(); err != nil || valid.MaxStack != 2 {
+		t.Errorf("Expected a valid program with max stack 2, got %v (max stack %d)", err, valid.MaxStack)
+	}
+}
+
+func TestCompileBytecode(t *testing.T) {
+	programs, err := CompileBytecode(createBatchTestRuleset().Dag)
+	if err != nil {
+		t.Fatalf("Failed to compile bytecode: %v", err)
+	}
+	if len(programs) != 2 || programs[0].RuleID != 1 || programs[1].RuleID != 2 {
+		t.Fatalf("Expected programs for rules 1 and 2, got %+v", programs)
+	}
+	if fmt.Sprint(programs[0].Code) != "[PRIMITIVE 0 PRIMITIVE 1 AND 2]" {
+		t.Errorf("Unexpected code for rule 1: %v", programs[0].Code)
+	}
+	if fmt.Sprint(programs[1].Code) != "[PRIMITIVE 1 NOT]" {
+		t.Errorf("Unexpected code for rule 2: %v", programs[1].Code)
+	}
+}
+
+func TestDagEngineVMBackendMatchesDag(t *testing.T) {
+	events := make([]interface{}, 20)
+	for i := range events {
+		event := map[string]interface{}{"EventID": fmt.Sprint(4620 + i%5)}
+		if i%3 == 0 {
+			event["ProcessName"] = "powershell.exe"
+		}
+		events[i] = event
+	}
+
+	dagEngine, err := NewDagEngineBuilder().WithPrefilter(false).BuildFromRuleset(createBatchTestRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create DAG engine: %v", err)
+	}
+	vmEngine, err := NewDagEngineBuilder().WithPrefilter(false).WithBackend(BackendVM).BuildFromRuleset(createBatchTestRuleset())
+	if err != nil {
+		t.Fatalf("Failed to create VM engine: %v", err)
+	}
+	if vmEngine.Stats().Backend != BackendVM {
+		t.Errorf("Expected VM backend in stats, got %s", vmEngine.Stats().Backend)
+	}
+
+	batch, err := vmEngine.EvaluateBatch(events)
+	if err != nil {
+		t.Fatalf("VM batch evaluation failed: %v", err)
+	}
+	for i, event := range events {
+		expected, err := dagEngine.Evaluate(event)
+		if err != nil {
+			t.Fatalf("DAG evaluation failed: %v", err)
+		}
+		actual, err := vmEngine.Evaluate(event)
+		if err != nil {
+			t.Fatalf("VM evaluation failed: %v", err)
+		}
+
+		expectedRules := append([]ir.RuleID(nil), expected.MatchedRules...)
+		sort.Slice(expectedRules, func(a, b int) bool { return expectedRules[a] < expectedRules[b] })
+		if len(expectedRules) == 0 {
+			expectedRules = []ir.RuleID{}
+		}
+		if !reflect.DeepEqual(actual.MatchedRules, expectedRules) {
+			t.Errorf("event %d: VM matched %v, DAG matched %v", i, actual.MatchedRules, expectedRules)
+		}
+		if !reflect.DeepEqual(batch[i].MatchedRules, actual.MatchedRules) {
+			t.Errorf("event %d: VM batch matched %v, single matched %v", i, batch[i].MatchedRules, actual.MatchedRules)
+		}
+	}
+
+	if _, err := NewDagEngineBuilder().WithBackend(BackendVM).WithMatchDetails(true).BuildFromRuleset(createBatchTestRuleset()); err == nil {
+		t.Error("Expected the VM backend to reject match details")
+	}
+}
@@ -0,0 +1,50 @@
+package dag
+
+import (
+	"testing"
+
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+func TestWarmup(t *testing.T) {
+	ruleset := createBatchTestRuleset()
+	ruleset.Primitives[1].MatchType = "regex"
+	ruleset.Primitives[1].Values = []string{"^powershell", `pwsh\.exe$`}
+	engine, err := NewDagEngineBuilder().
+		WithPrefilter(true).
+		BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	stats, err := engine.Warmup(WarmupOptions{BatchSize: 1000, SyntheticEvents: true})
+	if err != nil {
+		t.Fatalf("Warm-up failed: %v", err)
+	}
+	if stats.Regexes != 2 || stats.SyntheticEvents != 2 {
+		t.Errorf("Unexpected warm-up stats: %+v", stats)
+	}
+	if engine.evaluator == nil || engine.batchEvaluator == nil || cap(engine.batchEvaluator.memoryPool.backing) < len(engine.dag.Nodes)*bitsetWords(1000) {
+		t.Error("Expected the evaluators to be created and sized for the batch size")
+	}
+	// Synthetic events leave no trace in the prefilter statistics
+	if observed := engine.PrefilterStats().EventsObserved; observed != 0 {
+		t.Errorf("Expected no observed events, got %d", observed)
+	}
+
+	result, err := engine.Evaluate(map[string]interface{}{"EventID": "4624", "ProcessName": "powershell.exe"})
+	if err != nil || len(result.MatchedRules) != 1 || result.MatchedRules[0] != 1 {
+		t.Errorf("Expected rule 1 to match after warm-up, got %v, %v", result, err)
+	}
+
+	ruleset = createBatchTestRuleset()
+	ruleset.Primitives[1].MatchType = "regex"
+	ruleset.Primitives[1].Values = []string{"^powershell", "(unclosed"}
+	engine, err = NewDagEngineBuilder().WithPrefilter(false).BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if _, err := engine.Warmup(WarmupOptions{}); !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeInvalidRegex) {
+		t.Errorf("Expected an invalid regex error, got %v", err)
+	}
+}
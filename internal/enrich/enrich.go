@@ -0,0 +1,199 @@
+// Package enrich provides built-in event enrichers for the DAG engine:
+// GeoIP lookups from MaxMind databases and tags for CIDR ranges. Enrichers
+// add fields before events are evaluated, so rules can match on them like
+// on any event field, e.g. src_country or asset_tag.
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/matcher"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+var (
+	_ dag.Enricher = (*GeoIPEnricher)(nil)
+	_ dag.Enricher = (*CIDRTagEnricher)(nil)
+)
+
+// eventAddr returns the IP address in an event field (dotted paths walk
+// nested fields). Missing fields and values that are not IP addresses, such
+// as "-" placeholders, yield false.
+func eventAddr(event map[string]interface{}, field string) (netip.Addr, bool) {
+	value, err := matcher.DefaultFieldExtractor(event, field)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	text, ok := value.(string)
+	if !ok {
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(text))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// GeoIPEnricher adds the location of an event's IP address from a MaxMind
+// database. With prefix "src" it sets src_country (ISO code), src_city,
+// src_asn and src_as_org, each when the database has it: country and city
+// databases carry locations, ASN databases the autonomous system.
+type GeoIPEnricher struct {
+	db          *GeoIPDatabase
+	sourceField string
+	prefix      string
+}
+
+// NewGeoIPEnricher creates an enricher looking up the address in
+// sourceField and naming the fields it adds with prefix
+func NewGeoIPEnricher(db *GeoIPDatabase, sourceField, prefix string) *GeoIPEnricher {
+	return &GeoIPEnricher{db: db, sourceField: sourceField, prefix: prefix}
+}
+
+// Enrich implements dag.Enricher
+func (e *GeoIPEnricher) Enrich(ctx context.Context, event map[string]interface{}) error {
+	addr, ok := eventAddr(event, e.sourceField)
+	if !ok {
+		return nil
+	}
+	record, err := e.db.Lookup(addr)
+	if err != nil || record == nil {
+		return err
+	}
+
+	if country, ok := recordPath(record, "country", "iso_code").(string); ok {
+		event[e.prefix+"_country"] = country
+	}
+	if city, ok := recordPath(record, "city", "names", "en").(string); ok {
+		event[e.prefix+"_city"] = city
+	}
+	if asn, ok := recordPath(record, "autonomous_system_number").(uint64); ok {
+		event[e.prefix+"_asn"] = asn
+	}
+	if org, ok := recordPath(record, "autonomous_system_organization").(string); ok {
+		event[e.prefix+"_as_org"] = org
+	}
+	return nil
+}
+
+// recordPath returns the value under nested keys of a record, nil when
+// missing
+func recordPath(record map[string]interface{}, keys ...string) interface{} {
+	var value interface{} = record
+	for _, key := range keys {
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = nested[key]
+	}
+	return value
+}
+
+// CIDRTagEnricher tags events whose IP address falls in a known range, e.g.
+// with the asset class or owner of a subnet. The most specific range wins.
+type CIDRTagEnricher struct {
+	sourceField string
+	targetField string
+
+	// Tags by masked prefix, and the prefix lengths in use from the most
+	// specific, per address family
+	tags     map[netip.Prefix]string
+	ipv4Bits []int
+	ipv6Bits []int
+}
+
+// NewCIDRTagEnricher creates an enricher setting targetField to the tag of
+// the range containing the address in sourceField. Tags are keyed by CIDR
+// range; a bare address is a range of one address.
+func NewCIDRTagEnricher(sourceField, targetField string, tags map[string]string) (*CIDRTagEnricher, error) {
+	e := &CIDRTagEnricher{
+		sourceField: sourceField,
+		targetField: targetField,
+		tags:        make(map[netip.Prefix]string, len(tags)),
+	}
+	ipv4Bits := make(map[int]bool)
+	ipv6Bits := make(map[int]bool)
+	for cidr, tag := range tags {
+		prefix, err := parsePrefix(cidr)
+		if err != nil {
+			return nil, errors.NewConfigError(fmt.Sprintf("invalid CIDR range %q: %v", cidr, err))
+		}
+		e.tags[prefix] = tag
+		if prefix.Addr().Is4() {
+			ipv4Bits[prefix.Bits()] = true
+		} else {
+			ipv6Bits[prefix.Bits()] = true
+		}
+	}
+	e.ipv4Bits = descendingKeys(ipv4Bits)
+	e.ipv6Bits = descendingKeys(ipv6Bits)
+	return e, nil
+}
+
+// parsePrefix parses a CIDR range or a bare address, masking host bits
+func parsePrefix(cidr string) (netip.Prefix, error) {
+	cidr = strings.TrimSpace(cidr)
+	if !strings.Contains(cidr, "/") {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	return prefix.Masked(), nil
+}
+
+func descendingKeys(set map[int]bool) []int {
+	keys := make([]int, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(keys)))
+	return keys
+}
+
+// Enrich implements dag.Enricher
+func (e *CIDRTagEnricher) Enrich(ctx context.Context, event map[string]interface{}) error {
+	addr, ok := eventAddr(event, e.sourceField)
+	if !ok {
+		return nil
+	}
+	if tag, ok := e.Tag(addr); ok {
+		event[e.targetField] = tag
+	}
+	return nil
+}
+
+// Tag returns the tag of the most specific range containing an address
+func (e *CIDRTagEnricher) Tag(addr netip.Addr) (string, bool) {
+	addr = addr.Unmap()
+	bits := e.ipv6Bits
+	if addr.Is4() {
+		bits = e.ipv4Bits
+	}
+	for _, length := range bits {
+		prefix, err := addr.Prefix(length)
+		if err != nil {
+			continue
+		}
+		if tag, exists := e.tags[prefix]; exists {
+			return tag, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,320 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/dag"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// testRecord is a MaxMind DB test network with its data, or a pointer to
+// the data of an earlier network
+type testRecord struct {
+	prefix    string
+	data      map[string]interface{}
+	pointerTo int
+}
+
+// encodeMMDBValue appends a value in the MaxMind DB data format. Only the
+// types the tests use are supported: maps, short strings and unsigned
+// integers.
+func encodeMMDBValue(t *testing.T, buffer []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return append(append(buffer, byte(mmdbString<<5|len(v))), v...)
+	case uint64:
+		var digits []byte
+		for n := v; n > 0; n >>= 8 {
+			digits = append([]byte{byte(n)}, digits...)
+		}
+		// uint64 is an extended type
+		return append(append(buffer, byte(len(digits)), mmdbUint64-7), digits...)
+	case map[string]interface{}:
+		buffer = append(buffer, byte(mmdbMap<<5|len(v)))
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			buffer = encodeMMDBValue(t, buffer, key)
+			buffer = encodeMMDBValue(t, buffer, v[key])
+		}
+		return buffer
+	default:
+		t.Fatalf("Unsupported test value %T", value)
+		return nil
+	}
+}
+
+// buildTestMMDB builds a MaxMind DB with the given networks
+func buildTestMMDB(t *testing.T, ipVersion, recordSize int, records ...testRecord) []byte {
+	// Records are a node, data at an offset, or empty
+	type record struct {
+		node, data int
+		isNode     bool
+		isData     bool
+	}
+	nodes := [][2]record{{}}
+
+	var data []byte
+	offsets := make([]int, len(records))
+	for i, network := range records {
+		offsets[i] = len(data)
+		if network.data != nil {
+			data = encodeMMDBValue(t, data, network.data)
+		} else {
+			target := offsets[network.pointerTo]
+			data = append(data, byte(mmdbPointer<<5|target>>8), byte(target))
+		}
+
+		prefix := netip.MustParsePrefix(network.prefix)
+		ip := prefix.Addr().AsSlice()
+		bits := make([]byte, 0, 128)
+		if ipVersion == 6 && prefix.Addr().Is4() {
+			bits = append(bits, make([]byte, 96)...)
+		}
+		for bit := 0; bit < prefix.Bits(); bit++ {
+			bits = append(bits, (ip[bit/8]>>(7-uint(bit%8)))&1)
+		}
+
+		node := 0
+		for depth, bit := range bits {
+			if depth == len(bits)-1 {
+				nodes[node][bit] = record{data: offsets[i], isData: true}
+				break
+			}
+			if !nodes[node][bit].isNode {
+				nodes = append(nodes, [2]record{})
+				nodes[node][bit] = record{node: len(nodes) - 1, isNode: true}
+			}
+			node = nodes[node][bit].node
+		}
+	}
+
+	nodeCount := len(nodes)
+	value := func(r record) uint32 {
+		switch {
+		case r.isNode:
+			return uint32(r.node)
+		case r.isData:
+			return uint32(nodeCount + dataSectionSeparator + r.data)
+		default:
+			return uint32(nodeCount)
+		}
+	}
+	var buffer []byte
+	for _, node := range nodes {
+		left, right := value(node[0]), value(node[1])
+		switch recordSize {
+		case 24:
+			buffer = append(buffer, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			buffer = append(buffer, byte(left>>16), byte(left>>8), byte(left),
+				byte(left>>24)<<4|byte(right>>24), byte(right>>16), byte(right>>8), byte(right))
+		default:
+			t.Fatalf("Unsupported test record size %d", recordSize)
+		}
+	}
+	buffer = append(buffer, make([]byte, dataSectionSeparator)...)
+	buffer = append(buffer, data...)
+	buffer = append(buffer, metadataMarker...)
+	return encodeMMDBValue(t, buffer, map[string]interface{}{
+		"node_count":    uint64(nodeCount),
+		"record_size":   uint64(recordSize),
+		"ip_version":    uint64(ipVersion),
+		"database_type": "Test-City",
+	})
+}
+
+func testGeoIPRecords() []testRecord {
+	return []testRecord{
+		{prefix: "81.2.69.0/24", data: map[string]interface{}{
+			"country":                  map[string]interface{}{"iso_code": "GB"},
+			"city":                     map[string]interface{}{"names": map[string]interface{}{"en": "London"}},
+			"autonomous_system_number": uint64(20712),
+		}},
+		{prefix: "2001:db8::/32", data: map[string]interface{}{
+			"country": map[string]interface{}{"iso_code": "US"},
+		}},
+		// Shares the record of the first network through a pointer
+		{prefix: "81.2.70.0/24", pointerTo: 0},
+	}
+}
+
+func TestGeoIPDatabaseLookup(t *testing.T) {
+	for _, recordSize := range []int{24, 28} {
+		db, err := NewGeoIPDatabase(buildTestMMDB(t, 6, recordSize, testGeoIPRecords()...))
+		if err != nil {
+			t.Fatalf("record size %d: failed to read database: %v", recordSize, err)
+		}
+		if db.DatabaseType != "Test-City" {
+			t.Errorf("Expected database type Test-City, got %q", db.DatabaseType)
+		}
+
+		testCases := []struct {
+			addr    string
+			country string
+		}{
+			{"81.2.69.160", "GB"},
+			{"::ffff:81.2.69.1", "GB"},
+			{"81.2.70.7", "GB"},
+			{"2001:db8::1", "US"},
+			{"81.2.71.1", ""},
+			{"10.0.0.1", ""},
+		}
+		for _, tc := range testCases {
+			record, err := db.Lookup(netip.MustParseAddr(tc.addr))
+			if err != nil {
+				t.Fatalf("Lookup of %s failed: %v", tc.addr, err)
+			}
+			if country, _ := recordPath(record, "country", "iso_code").(string); country != tc.country {
+				t.Errorf("record size %d: expected %s in %q, got %q (%v)", recordSize, tc.addr, tc.country, country, record)
+			}
+		}
+	}
+
+	// IPv4 databases hold no IPv6 addresses
+	db, err := NewGeoIPDatabase(buildTestMMDB(t, 4, 24, testGeoIPRecords()[0]))
+	if err != nil {
+		t.Fatalf("Failed to read IPv4 database: %v", err)
+	}
+	if record, err := db.Lookup(netip.MustParseAddr("81.2.69.1")); err != nil || record == nil {
+		t.Errorf("Expected a record for 81.2.69.1, got %v (%v)", record, err)
+	}
+	if record, err := db.Lookup(netip.MustParseAddr("2001:db8::1")); err != nil || record != nil {
+		t.Errorf("Expected no record for an IPv6 address, got %v (%v)", record, err)
+	}
+
+	_, err = NewGeoIPDatabase([]byte("not a database"))
+	var sigmaErr *sigmaerrors.SigmaError
+	if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeConfig {
+		t.Errorf("Expected a configuration error for an invalid database, got %v", err)
+	}
+}
+
+func TestGeoIPEnricher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, buildTestMMDB(t, 6, 24, testGeoIPRecords()...), 0o600); err != nil {
+		t.Fatalf("Failed to write database: %v", err)
+	}
+	db, err := OpenGeoIP(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	enricher := NewGeoIPEnricher(db, "source.ip", "src")
+
+	event := map[string]interface{}{"source": map[string]interface{}{"ip": "81.2.69.160"}}
+	if err := enricher.Enrich(context.Background(), event); err != nil {
+		t.Fatalf("Enrichment failed: %v", err)
+	}
+	if event["src_country"] != "GB" || event["src_city"] != "London" || event["src_asn"] != uint64(20712) {
+		t.Errorf("Unexpected enrichment: %v", event)
+	}
+	if _, exists := event["src_as_org"]; exists {
+		t.Error("Expected fields missing from the record to be left out")
+	}
+
+	event = map[string]interface{}{"source": map[string]interface{}{"ip": "-"}}
+	if err := enricher.Enrich(context.Background(), event); err != nil || len(event) != 1 {
+		t.Errorf("Expected values that are not addresses to be skipped, got %v (%v)", event, err)
+	}
+}
+
+func TestCIDRTagEnricher(t *testing.T) {
+	enricher, err := NewCIDRTagEnricher("DestinationIp", "asset_tag", map[string]string{
+		"10.0.0.0/8":   "internal",
+		"10.1.2.0/24":  "domain-controllers",
+		"10.1.2.10":    "pdc",
+		"fd00::/8":     "internal",
+		"192.168.1.77": "printer",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create enricher: %v", err)
+	}
+
+	testCases := []struct {
+		addr string
+		tag  string
+	}{
+		{"10.9.9.9", "internal"},
+		{"10.1.2.3", "domain-controllers"},
+		{"10.1.2.10", "pdc"},
+		{"::ffff:10.1.2.3", "domain-controllers"},
+		{"fd12::1", "internal"},
+		{"192.168.1.78", ""},
+		{"not an address", ""},
+	}
+	for _, tc := range testCases {
+		event := map[string]interface{}{"DestinationIp": tc.addr}
+		if err := enricher.Enrich(context.Background(), event); err != nil {
+			t.Fatalf("Enrichment failed: %v", err)
+		}
+		if tag, _ := event["asset_tag"].(string); tag != tc.tag {
+			t.Errorf("Expected %s to be tagged %q, got %q", tc.addr, tc.tag, tag)
+		}
+	}
+
+	if _, err := NewCIDRTagEnricher("ip", "tag", map[string]string{"10.0.0.0/33": "x"}); err == nil {
+		t.Error("Expected an invalid range to fail")
+	}
+}
+
+func TestEngineEnrichment(t *testing.T) {
+	tagger, err := NewCIDRTagEnricher("DestinationIp", "asset_tag", map[string]string{"10.1.2.0/24": "domain-controllers"})
+	if err != nil {
+		t.Fatalf("Failed to create enricher: %v", err)
+	}
+
+	compiled := dag.NewCompiledDag()
+	primitive := dag.NewDagNode(0, dag.NewPrimitiveNodeType(0))
+	primitive.Dependents = []dag.NodeId{1}
+	result := dag.NewDagNode(1, dag.NewResultNodeType(1))
+	result.Dependencies = []dag.NodeId{0}
+	compiled.Nodes = []dag.DagNode{*primitive, *result}
+	compiled.ExecutionOrder = []dag.NodeId{0, 1}
+	compiled.PrimitiveMap[0] = 0
+	compiled.RuleResults[1] = 1
+	compiled.ResultBufferSize = 2
+	ruleset := &dag.CompiledRuleset{
+		Primitives: []dag.Primitive{{ID: 0, Field: "asset_tag", MatchType: "equals", Values: []string{"domain-controllers"}}},
+		Dag:        compiled,
+	}
+
+	engine, err := dag.NewDagEngineBuilder().
+		WithOptimization(false).
+		WithEnricher(tagger).
+		BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	event := map[string]interface{}{"DestinationIp": "10.1.2.3"}
+	matches, err := engine.Evaluate(event)
+	if err != nil {
+		t.Fatalf("Evaluation failed: %v", err)
+	}
+	if len(matches.MatchedRules) != 1 || matches.MatchedRules[0] != 1 {
+		t.Errorf("Expected the enriched field to match rule 1, got %v", matches.MatchedRules)
+	}
+	if _, exists := event["asset_tag"]; exists {
+		t.Error("Expected the caller's event to be left unchanged")
+	}
+
+	failing := dag.EnricherFunc(func(ctx context.Context, event map[string]interface{}) error {
+		return errors.New("lookup service unavailable")
+	})
+	engine, err = dag.NewDagEngineBuilder().WithOptimization(false).WithEnricher(failing).BuildFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if _, err := engine.Evaluate(event); err == nil {
+		t.Error("Expected enrichment errors to fail the evaluation")
+	}
+}
@@ -0,0 +1,376 @@
+package enrich
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"os"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// metadataMarker precedes the metadata map at the end of a MaxMind DB file
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// metadataMaxSize bounds the search for the metadata marker
+const metadataMaxSize = 128 * 1024
+
+// dataSectionSeparator is the gap of zero bytes between the search tree
+// and the data section
+const dataSectionSeparator = 16
+
+// GeoIPDatabase is a MaxMind DB (GeoLite2, GeoIP2 or compatible) loaded in
+// memory. It is safe for concurrent use.
+type GeoIPDatabase struct {
+	buffer []byte
+
+	// Search tree layout from the metadata
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+
+	// Node to start IPv4 lookups from in an IPv6 tree
+	ipv4Start uint
+
+	// Data section, addressed by the offsets in the tree and in pointers
+	data []byte
+
+	// Type of the database, e.g. "GeoLite2-Country"
+	DatabaseType string
+}
+
+// OpenGeoIP loads a MaxMind DB file
+func OpenGeoIP(path string) (*GeoIPDatabase, error) {
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WrapIOError(err)
+	}
+	db, err := NewGeoIPDatabase(buffer)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// NewGeoIPDatabase reads a MaxMind DB from its bytes
+func NewGeoIPDatabase(buffer []byte) (*GeoIPDatabase, error) {
+	start := max(0, len(buffer)-metadataMaxSize)
+	marker := bytes.LastIndex(buffer[start:], metadataMarker)
+	if marker < 0 {
+		return nil, errors.NewConfigError("invalid MaxMind DB: metadata not found")
+	}
+	metadataStart := start + marker + len(metadataMarker)
+	metadata, _, err := (&mmdbDecoder{buffer: buffer[metadataStart:]}).decode(0)
+	if err != nil {
+		return nil, errors.NewConfigError(fmt.Sprintf("invalid MaxMind DB metadata: %v", err))
+	}
+	fields, ok := metadata.(map[string]interface{})
+	if !ok {
+		return nil, errors.NewConfigError("invalid MaxMind DB metadata: not a map")
+	}
+
+	db := &GeoIPDatabase{buffer: buffer}
+	db.nodeCount = metadataUint(fields, "node_count")
+	db.recordSize = metadataUint(fields, "record_size")
+	db.ipVersion = metadataUint(fields, "ip_version")
+	db.DatabaseType, _ = fields["database_type"].(string)
+	if db.recordSize != 24 && db.recordSize != 28 && db.recordSize != 32 {
+		return nil, errors.NewConfigError(fmt.Sprintf("invalid MaxMind DB: unsupported record size %d", db.recordSize))
+	}
+	if db.ipVersion != 4 && db.ipVersion != 6 {
+		return nil, errors.NewConfigError(fmt.Sprintf("invalid MaxMind DB: unsupported IP version %d", db.ipVersion))
+	}
+
+	treeSize := db.nodeCount * db.recordSize / 4
+	dataStart := treeSize + dataSectionSeparator
+	if dataStart > uint(start+marker) {
+		return nil, errors.NewConfigError("invalid MaxMind DB: search tree overlaps metadata")
+	}
+	db.data = buffer[dataStart : start+marker]
+
+	// IPv4 addresses live under ::/96 in IPv6 trees
+	if db.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < db.nodeCount; i++ {
+			node = db.readRecord(node, 0)
+		}
+		db.ipv4Start = node
+	}
+	return db, nil
+}
+
+// metadataUint returns an unsigned metadata field, 0 when missing
+func metadataUint(fields map[string]interface{}, key string) uint {
+	value, _ := fields[key].(uint64)
+	return uint(value)
+}
+
+// Lookup returns the record of the network containing an address, or nil
+// when the database has none
+func (db *GeoIPDatabase) Lookup(addr netip.Addr) (map[string]interface{}, error) {
+	addr = addr.Unmap()
+	node := uint(0)
+	bits := 128
+	if addr.Is4() {
+		bits = 32
+		if db.ipVersion == 6 {
+			node = db.ipv4Start
+		}
+	} else if db.ipVersion == 4 {
+		return nil, nil
+	}
+
+	ip := addr.AsSlice()
+	for i := 0; i < bits && node < db.nodeCount; i++ {
+		bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+		node = db.readRecord(node, uint(bit))
+	}
+	if node <= db.nodeCount {
+		// Not found (node == nodeCount) or a tree deeper than the address
+		return nil, nil
+	}
+
+	offset := node - db.nodeCount - dataSectionSeparator
+	if offset >= uint(len(db.data)) {
+		return nil, errors.NewExecutionError("corrupt MaxMind DB: record points outside the data section")
+	}
+	value, _, err := (&mmdbDecoder{buffer: db.data}).decode(offset)
+	if err != nil {
+		return nil, errors.NewExecutionError(fmt.Sprintf("corrupt MaxMind DB record: %v", err))
+	}
+	record, _ := value.(map[string]interface{})
+	return record, nil
+}
+
+// readRecord returns the left (0) or right (1) record of a node
+func (db *GeoIPDatabase) readRecord(node, side uint) uint {
+	switch db.recordSize {
+	case 24:
+		offset := node*6 + side*3
+		b := db.buffer[offset : offset+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := db.buffer[node*7 : node*7+7]
+		if side == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		offset := node*8 + side*4
+		return uint(binary.BigEndian.Uint32(db.buffer[offset : offset+4]))
+	}
+}
+
+// MaxMind DB data types
+const (
+	mmdbExtended  = 0
+	mmdbPointer   = 1
+	mmdbString    = 2
+	mmdbDouble    = 3
+	mmdbBytes     = 4
+	mmdbUint16    = 5
+	mmdbUint32    = 6
+	mmdbMap       = 7
+	mmdbInt32     = 8
+	mmdbUint64    = 9
+	mmdbUint128   = 10
+	mmdbArray     = 11
+	mmdbContainer = 12
+	mmdbEndMarker = 13
+	mmdbBool      = 14
+	mmdbFloat     = 15
+)
+
+// maxDecodeDepth bounds nesting, so corrupt databases cannot recurse
+// without end
+const maxDecodeDepth = 64
+
+// mmdbDecoder decodes values of a MaxMind DB data section. Values decode to
+// map[string]interface{}, []interface{}, string, []byte, float64, uint64,
+// int64, *big.Int (uint128) and bool.
+type mmdbDecoder struct {
+	buffer []byte
+	depth  int
+}
+
+// decode decodes the value at offset and returns the offset after it
+func (d *mmdbDecoder) decode(offset uint) (interface{}, uint, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDecodeDepth {
+		return nil, 0, fmt.Errorf("values nested deeper than %d", maxDecodeDepth)
+	}
+
+	kind, size, offset, err := d.decodeControl(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if kind == mmdbPointer {
+		// A pointer's value is decoded where it points; decoding continues
+		// after the pointer itself
+		value, _, err := d.decode(size)
+		return value, offset, err
+	}
+
+	switch kind {
+	case mmdbMap:
+		record := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var key, value interface{}
+			if key, offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key of type %T", key)
+			}
+			if value, offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+			record[name] = value
+		}
+		return record, offset, nil
+	case mmdbArray:
+		values := make([]interface{}, 0, min(size, 1024))
+		for i := uint(0); i < size; i++ {
+			var value interface{}
+			if value, offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+			values = append(values, value)
+		}
+		return values, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(d.buffer)) || end < offset {
+		return nil, 0, fmt.Errorf("value of %d bytes at offset %d runs past the data", size, offset)
+	}
+	b := d.buffer[offset:end]
+	switch kind {
+	case mmdbString:
+		return string(b), end, nil
+	case mmdbBytes:
+		return append([]byte(nil), b...), end, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("double of %d bytes", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("float of %d bytes", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), end, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("unsigned integer of %d bytes", size)
+		}
+		value := uint64(0)
+		for _, c := range b {
+			value = value<<8 | uint64(c)
+		}
+		return value, end, nil
+	case mmdbInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("int32 of %d bytes", size)
+		}
+		value := uint32(0)
+		for _, c := range b {
+			value = value<<8 | uint32(c)
+		}
+		if size == 4 {
+			return int64(int32(value)), end, nil
+		}
+		return int64(value), end, nil
+	case mmdbUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("uint128 of %d bytes", size)
+		}
+		return new(big.Int).SetBytes(b), end, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", kind)
+	}
+}
+
+// decodeControl decodes the control byte of the value at offset and
+// returns its type, its size (the target offset for pointers) and the
+// offset of its payload
+func (d *mmdbDecoder) decodeControl(offset uint) (kind, size, next uint, err error) {
+	next = offset
+	readByte := func() (uint, error) {
+		if next >= uint(len(d.buffer)) {
+			return 0, fmt.Errorf("unexpected end of data at offset %d", next)
+		}
+		next++
+		return uint(d.buffer[next-1]), nil
+	}
+
+	control, err := readByte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	kind = control >> 5
+	if kind == mmdbExtended {
+		extended, err := readByte()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		kind = 7 + extended
+		if kind < mmdbInt32 {
+			return 0, 0, 0, fmt.Errorf("invalid extended type %d", kind)
+		}
+	}
+
+	if kind == mmdbPointer {
+		pointerSize := (control >> 3) & 0x3
+		pointer := uint(0)
+		if pointerSize < 3 {
+			pointer = control & 0x7
+		}
+		for i := uint(0); i <= pointerSize; i++ {
+			c, err := readByte()
+			if err != nil {
+				return 0, 0, 0, err
+			}
+			pointer = pointer<<8 | c
+		}
+		switch pointerSize {
+		case 1:
+			pointer += 2048
+		case 2:
+			pointer += 526336
+		}
+		return kind, pointer, next, nil
+	}
+
+	size = control & 0x1f
+	if size >= 29 {
+		extraBytes := size - 28
+		extra := uint(0)
+		for i := uint(0); i < extraBytes; i++ {
+			c, err := readByte()
+			if err != nil {
+				return 0, 0, 0, err
+			}
+			extra = extra<<8 | c
+		}
+		switch size {
+		case 29:
+			size = 29 + extra
+		case 30:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+	if kind == mmdbContainer || kind == mmdbEndMarker {
+		return 0, 0, 0, fmt.Errorf("unexpected data type %d", kind)
+	}
+	return kind, size, next, nil
+}
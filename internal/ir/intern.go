@@ -0,0 +1,49 @@
+package ir
+
+// StringInterner: bảng intern chuỗi, giữ một bản duy nhất cho mỗi giá trị
+// chuỗi (tên field, match type, giá trị literal, modifier).
+// Các chuỗi bằng nhau đã intern dùng chung vùng nhớ, nên ruleset lớn lặp lại
+// cùng một field hàng nghìn lần chỉ lưu nó một lần, và so sánh hai chuỗi
+// đã intern dừng ngay ở bước so sánh con trỏ mà không cần đọc từng byte.
+// Không an toàn khi dùng đồng thời.
+type StringInterner struct {
+	strings    map[string]string
+	savedBytes int
+}
+
+// NewStringInterner: tạo bảng intern rỗng
+func NewStringInterner() *StringInterner {
+	return &StringInterner{strings: make(map[string]string)}
+}
+
+// Intern: trả về bản chuỗi đã intern bằng với s, thêm s vào bảng nếu chưa có
+func (si *StringInterner) Intern(s string) string {
+	if interned, exists := si.strings[s]; exists {
+		si.savedBytes += len(s)
+		return interned
+	}
+	si.strings[s] = s
+	return s
+}
+
+// InternAll: trả về slice mới chứa các chuỗi đã intern (nil nếu values là nil)
+func (si *StringInterner) InternAll(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	interned := make([]string, len(values))
+	for i, value := range values {
+		interned[i] = si.Intern(value)
+	}
+	return interned
+}
+
+// Len: số chuỗi phân biệt trong bảng
+func (si *StringInterner) Len() int {
+	return len(si.strings)
+}
+
+// SavedBytes: tổng số byte của các chuỗi trùng lặp đã được thay bằng bản intern
+func (si *StringInterner) SavedBytes() int {
+	return si.savedBytes
+}
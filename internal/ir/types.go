@@ -16,6 +16,15 @@ type Primitive struct {
 	MatchType string   `json:"match_type"`
 	Values    []string `json:"values"`
 	Modifiers []string `json:"modifiers"`
+
+	// ValueKinds: kind của từng giá trị trong Values (nil = toàn chuỗi)
+	ValueKinds []ValueKind `json:"value_kinds,omitempty"`
+
+	// FieldAliases: các field thay thế, thử lần lượt khi event không có Field
+	FieldAliases []string `json:"field_aliases,omitempty"`
+
+	// IgnoreCase: so sánh chuỗi không phân biệt hoa thường (mặc định của SIGMA, tắt bằng modifier cased)
+	IgnoreCase bool `json:"ignore_case,omitempty"`
 }
 
 // NewPrimitive: tạo một Primitive mới, có copy dữ liệu để tránh bị thay đổi ngoài ý muốn
@@ -54,7 +63,7 @@ func (p *Primitive) String() string {
 		p.Field, p.MatchType, p.Values, p.Modifiers)
 }
 
-// Equal: so sánh 2 Primitive xem có giống hệt nhau không (so sánh cả field, matchType, values, modifiers)
+// Equal: so sánh 2 Primitive xem có giống hệt nhau không (so sánh cả field, matchType, values, modifiers, kinds)
 func (p *Primitive) Equal(other *Primitive) bool {
     if other == nil {
         return false
@@ -62,7 +71,10 @@ func (p *Primitive) Equal(other *Primitive) bool {
     return p.Field == other.Field &&
            p.MatchType == other.MatchType &&
            stringSlicesEqual(p.Values, other.Values) &&
-           stringSlicesEqual(p.Modifiers, other.Modifiers)
+           stringSlicesEqual(p.Modifiers, other.Modifiers) &&
+           valueKindsEqual(p.ValueKinds, other.ValueKinds) &&
+           stringSlicesEqual(p.FieldAliases, other.FieldAliases) &&
+           p.IgnoreCase == other.IgnoreCase
 }
 
 // stringSlicesEqual: so sánh 2 slice string theo thứ tự phần tử
@@ -80,7 +92,10 @@ func stringSlicesEqual(a, b []string) bool {
 
 // Clone: tạo một bản sao mới của Primitive (deep copy)
 func (p *Primitive) Clone() *Primitive {
-    return NewPrimitive(p.Field, p.MatchType, p.Values, p.Modifiers)
+    clone := NewTypedPrimitive(p.Field, p.MatchType, p.Values, p.ValueKinds, p.Modifiers)
+    clone.FieldAliases = copyStrings(p.FieldAliases)
+    clone.IgnoreCase = p.IgnoreCase
+    return clone
 }
 
 // Hash: tạo ra giá trị băm (hash) duy nhất cho Primitive
@@ -92,6 +107,11 @@ func (p *Primitive) Hash() uint64 {
     h.Write([]byte(p.MatchType))
     h.Write([]byte(strings.Join(p.Values, "|")))    
     h.Write([]byte(strings.Join(p.Modifiers, "|")))
+    h.Write([]byte(valueKindsKey(p.ValueKinds)))
+    h.Write([]byte(strings.Join(p.FieldAliases, "|")))
+    if p.IgnoreCase {
+        h.Write([]byte("ignorecase"))
+    }
 
     return h.Sum64()
 }
@@ -102,6 +122,7 @@ type CompiledRuleset struct {
     PrimitiveMap  map[string]PrimitiveID `json:"primitive_map"` // ánh xạ primitive key sang ID
     Primitives    []Primitive            `json:"primitives"`    // danh sách primitive
     primitiveKeys map[string]string      // lưu lại key đã sinh
+    interner      *StringInterner        // bảng intern chuỗi của primitive (nil = không intern)
 }
 
 // NewCompiledRuleset: tạo ruleset rỗng
@@ -113,6 +134,18 @@ func NewCompiledRuleset() *CompiledRuleset {
     }
 }
 
+// WithInterner: intern chuỗi (field, match type, values, modifiers) của các
+// primitive được thêm sau đó vào bảng interner (nil = tắt intern)
+func (cr *CompiledRuleset) WithInterner(interner *StringInterner) *CompiledRuleset {
+    cr.interner = interner
+    return cr
+}
+
+// Interner: trả về bảng intern chuỗi của ruleset (nil nếu không intern)
+func (cr *CompiledRuleset) Interner() *StringInterner {
+    return cr.interner
+}
+
 // PrimitiveCount: trả về số lượng primitive trong ruleset
 func (cr *CompiledRuleset) PrimitiveCount() int {
     return len(cr.Primitives)
@@ -135,6 +168,14 @@ func (cr *CompiledRuleset) AddPrimitive(primitive Primitive) PrimitiveID {
         return id
     }
     
+    if cr.interner != nil {
+        primitive.Field = cr.interner.Intern(primitive.Field)
+        primitive.MatchType = cr.interner.Intern(primitive.MatchType)
+        primitive.Values = cr.interner.InternAll(primitive.Values)
+        primitive.Modifiers = cr.interner.InternAll(primitive.Modifiers)
+        primitive.FieldAliases = cr.interner.InternAll(primitive.FieldAliases)
+    }
+
     id := PrimitiveID(len(cr.Primitives))
     cr.Primitives = append(cr.Primitives, primitive)
     cr.PrimitiveMap[key] = id
@@ -143,19 +184,43 @@ func (cr *CompiledRuleset) AddPrimitive(primitive Primitive) PrimitiveID {
     return id
 }
 
+// Truncate: xóa các primitive có ID >= count, dùng để hoàn tác các primitive
+// được thêm bởi một rule biên dịch thất bại
+func (cr *CompiledRuleset) Truncate(count int) {
+    if count < 0 || count >= len(cr.Primitives) {
+        return
+    }
+    for i := count; i < len(cr.Primitives); i++ {
+        key := cr.primitiveToKey(&cr.Primitives[i])
+        delete(cr.PrimitiveMap, key)
+        delete(cr.primitiveKeys, key)
+    }
+    cr.Primitives = cr.Primitives[:count]
+}
+
 // primitiveToKey: sinh ra khóa duy nhất cho một primitive dựa trên field, matchType, values, modifiers
+// (và kind của giá trị nếu có giá trị không phải chuỗi, field alias nếu có)
 func (cr *CompiledRuleset) primitiveToKey(p *Primitive) string {
     var parts []string
     parts = append(parts, p.Field)
     parts = append(parts, p.MatchType)
     parts = append(parts, strings.Join(p.Values, "|"))
     parts = append(parts, strings.Join(p.Modifiers, "|"))
+    if kinds := valueKindsKey(p.ValueKinds); kinds != "" {
+        parts = append(parts, kinds)
+    }
+    if len(p.FieldAliases) > 0 {
+        parts = append(parts, "aliases="+strings.Join(p.FieldAliases, "|"))
+    }
+    if p.IgnoreCase {
+        parts = append(parts, "ignorecase")
+    }
     return strings.Join(parts, "::")
 }
 
 // Clone: tạo bản sao của ruleset (deep copy toàn bộ primitive)
 func (cr *CompiledRuleset) Clone() *CompiledRuleset {
-    newRuleset := NewCompiledRuleset()
+    newRuleset := NewCompiledRuleset().WithInterner(cr.interner)
     
     for _, primitive := range cr.Primitives {
         newRuleset.AddPrimitive(*primitive.Clone())
@@ -0,0 +1,84 @@
+package ir
+
+// ValueKind: kiểu gốc của một giá trị trong selection YAML. Values của
+// Primitive luôn là chuỗi; kind cho matcher biết cách so sánh giá trị với
+// event theo SIGMA (ví dụ `Elevated: true` khớp bool true và chuỗi "true"
+// không phân biệt hoa thường, `ParentImage: null` khớp khi field vắng mặt)
+type ValueKind uint8
+
+const (
+	ValueKindString ValueKind = iota // chuỗi (mặc định)
+	ValueKindInt                     // số nguyên
+	ValueKindFloat                   // số thực
+	ValueKindBool                    // true/false
+	ValueKindNull                    // null: field không có hoặc là null, giá trị chuỗi là ""
+)
+
+// String: tên của kind để debug/log
+func (k ValueKind) String() string {
+	switch k {
+	case ValueKindInt:
+		return "int"
+	case ValueKindFloat:
+		return "float"
+	case ValueKindBool:
+		return "bool"
+	case ValueKindNull:
+		return "null"
+	}
+	return "string"
+}
+
+// NewTypedPrimitive: tạo Primitive có kind cho từng giá trị (kinds song song
+// với values). Nếu mọi giá trị đều là chuỗi thì ValueKinds là nil, để
+// primitive trùng với primitive tạo bằng NewPrimitive
+func NewTypedPrimitive(field, matchType string, values []string, kinds []ValueKind, modifiers []string) *Primitive {
+	primitive := NewPrimitive(field, matchType, values, modifiers)
+	for _, kind := range kinds {
+		if kind != ValueKindString {
+			primitive.ValueKinds = append([]ValueKind(nil), kinds...)
+			break
+		}
+	}
+	return primitive
+}
+
+// ValueKind: trả về kind của giá trị thứ i (ValueKindString nếu không có kind)
+func (p *Primitive) ValueKind(i int) ValueKind {
+	if i < 0 || i >= len(p.ValueKinds) {
+		return ValueKindString
+	}
+	return p.ValueKinds[i]
+}
+
+// valueKindsEqual: so sánh 2 slice kind, nil tương đương với toàn chuỗi
+func valueKindsEqual(a, b []ValueKind) bool {
+	n := max(len(a), len(b))
+	for i := 0; i < n; i++ {
+		var ka, kb ValueKind
+		if i < len(a) {
+			ka = a[i]
+		}
+		if i < len(b) {
+			kb = b[i]
+		}
+		if ka != kb {
+			return false
+		}
+	}
+	return true
+}
+
+// valueKindsKey: chuỗi mô tả các kind dùng trong key/hash ("" nếu toàn chuỗi)
+func valueKindsKey(kinds []ValueKind) string {
+	typed := false
+	key := make([]byte, len(kinds))
+	for i, kind := range kinds {
+		key[i] = '0' + byte(kind)
+		typed = typed || kind != ValueKindString
+	}
+	if !typed {
+		return ""
+	}
+	return string(key)
+}
@@ -0,0 +1,145 @@
+// Package logging provides component-scoped structured logging for the engine.
+//
+// All engine components log through log/slog. Hosts inject their own
+// *slog.Logger (or nothing, in which case logs are discarded) and may tune
+// verbosity globally or per component.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+// Component names used to scope engine loggers.
+const (
+	ComponentCompiler  = "compiler"
+	ComponentOptimizer = "optimizer"
+	ComponentEngine    = "engine"
+	ComponentMatcher   = "matcher"
+)
+
+// ComponentKey is the attribute key carrying the component name.
+const ComponentKey = "component"
+
+// Options controls logger verbosity.
+type Options struct {
+	// Minimum level for components without an explicit override (default: Info)
+	Level slog.Leveler
+
+	// Per-component minimum levels, keyed by component name
+	ComponentLevels map[string]slog.Level
+}
+
+// Discard returns a logger that drops every record.
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// New wraps a handler with component-aware level filtering.
+func New(handler slog.Handler, opts Options) *slog.Logger {
+	levels := make(map[string]slog.Level, len(opts.ComponentLevels))
+	for component, level := range opts.ComponentLevels {
+		levels[component] = level
+	}
+	return slog.New(&componentHandler{
+		inner:  handler,
+		level:  opts.Level,
+		levels: levels,
+	})
+}
+
+// NewText creates a text logger writing to w at the given minimum level.
+func NewText(w io.Writer, level slog.Level) *slog.Logger {
+	return New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug}), Options{Level: level})
+}
+
+// For returns a logger scoped to the given component.
+// A nil logger yields a discarding logger so callers never need nil checks.
+func For(logger *slog.Logger, component string) *slog.Logger {
+	if logger == nil {
+		return Discard()
+	}
+	return logger.With(slog.String(ComponentKey, component))
+}
+
+// WithMinLevel returns a logger that drops records below level before they
+// reach the underlying handler.
+func WithMinLevel(logger *slog.Logger, level slog.Level) *slog.Logger {
+	if logger == nil {
+		return Discard()
+	}
+	return slog.New(&minLevelHandler{inner: logger.Handler(), level: level})
+}
+
+// componentHandler filters records by the level configured for their component
+type componentHandler struct {
+	inner     slog.Handler
+	level     slog.Leveler
+	levels    map[string]slog.Level
+	component string
+}
+
+func (h *componentHandler) minLevel() slog.Level {
+	if level, ok := h.levels[h.component]; ok && h.component != "" {
+		return level
+	}
+	if h.level != nil {
+		return h.level.Level()
+	}
+	return slog.LevelInfo
+}
+
+func (h *componentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.minLevel() && h.inner.Enabled(ctx, level)
+}
+
+func (h *componentHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	component := h.component
+	for _, attr := range attrs {
+		if attr.Key == ComponentKey {
+			component = attr.Value.String()
+		}
+	}
+	return &componentHandler{
+		inner:     h.inner.WithAttrs(attrs),
+		level:     h.level,
+		levels:    h.levels,
+		component: component,
+	}
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return &componentHandler{
+		inner:     h.inner.WithGroup(name),
+		level:     h.level,
+		levels:    h.levels,
+		component: h.component,
+	}
+}
+
+// minLevelHandler drops records below a fixed level
+type minLevelHandler struct {
+	inner slog.Handler
+	level slog.Level
+}
+
+func (h *minLevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level && h.inner.Enabled(ctx, level)
+}
+
+func (h *minLevelHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *minLevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &minLevelHandler{inner: h.inner.WithAttrs(attrs), level: h.level}
+}
+
+func (h *minLevelHandler) WithGroup(name string) slog.Handler {
+	return &minLevelHandler{inner: h.inner.WithGroup(name), level: h.level}
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestForNilLoggerDiscards(t *testing.T) {
+	logger := For(nil, ComponentEngine)
+	if logger == nil {
+		t.Fatal("Expected non-nil logger")
+	}
+	if logger.Enabled(t.Context(), slog.LevelError) {
+		t.Error("Expected discard logger to be disabled")
+	}
+}
+
+func TestNewTextLevelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	logger := For(NewText(&buf, slog.LevelInfo), ComponentCompiler)
+
+	logger.Debug("hidden")
+	logger.Info("visible")
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Errorf("Expected debug record to be filtered, got: %s", out)
+	}
+	if !strings.Contains(out, "visible") {
+		t.Errorf("Expected info record, got: %s", out)
+	}
+	if !strings.Contains(out, "component=compiler") {
+		t.Errorf("Expected component attribute, got: %s", out)
+	}
+}
+
+func TestComponentLevelOverride(t *testing.T) {
+	var buf bytes.Buffer
+	base := New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}), Options{
+		Level:           slog.LevelWarn,
+		ComponentLevels: map[string]slog.Level{ComponentOptimizer: slog.LevelDebug},
+	})
+
+	For(base, ComponentOptimizer).Debug("optimizer detail")
+	For(base, ComponentEngine).Info("engine detail")
+
+	out := buf.String()
+	if !strings.Contains(out, "optimizer detail") {
+		t.Errorf("Expected optimizer debug record, got: %s", out)
+	}
+	if strings.Contains(out, "engine detail") {
+		t.Errorf("Expected engine info record to be filtered, got: %s", out)
+	}
+}
+
+func TestWithMinLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := WithMinLevel(NewText(&buf, slog.LevelDebug), slog.LevelInfo)
+
+	logger.Debug("hidden")
+	logger.Warn("shown")
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") || !strings.Contains(out, "shown") {
+		t.Errorf("Unexpected output: %s", out)
+	}
+}
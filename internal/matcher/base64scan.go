@@ -0,0 +1,202 @@
+package matcher
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// Base64 scanning matcher for encoded payloads.
+//
+// base64_scan finds base64-looking runs in the field value, decodes them and
+// applies an inner matcher to each decoded string, so `base64_scan:
+// DownloadString` matches "powershell -enc <UTF-16LE base64>" whatever the
+// payload offset. UTF-16LE payloads (as produced for -EncodedCommand) are
+// decoded to text. Parameters:
+//
+//	inner:<matcher>   matcher applied to decoded content (default contains)
+//	minlen:<n>        shortest run considered, in characters (default 16)
+//	maxsize:<n>       decoded bytes kept per run, longer runs are truncated
+//	                  (default 65536)
+//	maxruns:<n>       runs decoded per value (default 16)
+//	ignorecase        decoded content is lowercased before matching; set at
+//	                  compile time for case-insensitive primitives, whose
+//	                  values are lowercased then
+
+// Default base64_scan limits
+const (
+	DefaultBase64ScanMinLength = 16
+	DefaultBase64ScanMaxSize   = 64 * 1024
+	DefaultBase64ScanMaxRuns   = 16
+)
+
+// base64ScanIgnoreCase is the modifier making base64_scan lowercase decoded
+// content
+const base64ScanIgnoreCase = "ignorecase"
+
+// base64ScanOptions holds the configuration read from parameter modifiers
+type base64ScanOptions struct {
+	inner      string
+	minLength  int
+	maxSize    int
+	maxRuns    int
+	ignoreCase bool
+}
+
+// CreateBase64ScanMatch creates the base64_scan matcher. Inner matchers are
+// looked up in registry.
+func CreateBase64ScanMatch(registry *MatcherRegistry) MatchFn {
+	return func(fieldValue string, values []string, modifiers []string) (bool, error) {
+		options, err := parseBase64ScanOptions(modifiers)
+		if err != nil {
+			return false, err
+		}
+		inner, exists := registry.GetMatcher(options.inner)
+		if !exists {
+			return false, sigmaerrors.Wrap(sigmaerrors.ErrorTypeUnsupportedMatchType, options.inner, ErrUnsupportedMatchType)
+		}
+
+		for _, decoded := range DecodeBase64Runs(fieldValue, options.minLength, options.maxSize, options.maxRuns) {
+			if options.ignoreCase {
+				decoded = strings.ToLower(decoded)
+			}
+			matched, err := inner(decoded, values, modifiers)
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// parseBase64ScanOptions reads the base64_scan parameters from the modifiers
+func parseBase64ScanOptions(modifiers []string) (base64ScanOptions, error) {
+	options := base64ScanOptions{
+		inner:     "contains",
+		minLength: DefaultBase64ScanMinLength,
+		maxSize:   DefaultBase64ScanMaxSize,
+		maxRuns:   DefaultBase64ScanMaxRuns,
+	}
+	for _, modifier := range modifiers {
+		name, value, found := strings.Cut(modifier, ":")
+		if !found {
+			if modifier == base64ScanIgnoreCase {
+				options.ignoreCase = true
+			}
+			continue
+		}
+		var limit *int
+		switch name {
+		case "inner":
+			options.inner = value
+			continue
+		case "minlen":
+			limit = &options.minLength
+		case "maxsize":
+			limit = &options.maxSize
+		case "maxruns":
+			limit = &options.maxRuns
+		default:
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return options, fmt.Errorf("invalid base64_scan %s %q", name, value)
+		}
+		*limit = n
+	}
+	return options, nil
+}
+
+// isBase64Char reports whether c belongs to the standard or URL-safe alphabet
+func isBase64Char(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+		c == '+' || c == '/' || c == '-' || c == '_'
+}
+
+// DecodeBase64Runs decodes the base64 runs of at least minLength characters
+// in s, keeping at most maxSize decoded bytes per run and maxRuns runs. Runs
+// that do not decode are skipped.
+func DecodeBase64Runs(s string, minLength, maxSize, maxRuns int) []string {
+	var decoded []string
+	for i := 0; i < len(s) && len(decoded) < maxRuns; {
+		if !isBase64Char(s[i]) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(s) && isBase64Char(s[i]) {
+			i++
+		}
+		run := s[start:i]
+		for i < len(s) && s[i] == '=' {
+			i++
+		}
+		if len(run) < minLength {
+			continue
+		}
+		if text, ok := decodeBase64Run(run, maxSize); ok {
+			decoded = append(decoded, text)
+		}
+	}
+	return decoded
+}
+
+// decodeBase64Run decodes an unpadded run, truncated to maxSize decoded bytes
+func decodeBase64Run(run string, maxSize int) (string, bool) {
+	if maxEncoded := (maxSize + 2) / 3 * 4; len(run) > maxEncoded {
+		run = run[:maxEncoded]
+	}
+	if len(run)%4 == 1 {
+		run = run[:len(run)-1]
+	}
+
+	encoding := base64.RawStdEncoding
+	if strings.ContainsAny(run, "-_") {
+		encoding = base64.RawURLEncoding
+	}
+	data, err := encoding.DecodeString(run)
+	if err != nil {
+		return "", false
+	}
+	if len(data) > maxSize {
+		data = data[:maxSize]
+	}
+	return decodeUTF16LE(data), true
+}
+
+// decodeUTF16LE converts UTF-16LE text to UTF-8. Data that does not look like
+// UTF-16LE (a NUL high byte on most ASCII-range characters) is returned as is.
+func decodeUTF16LE(data []byte) string {
+	if len(data) < 2 {
+		return string(data)
+	}
+	zeros := 0
+	for i := 1; i < len(data); i += 2 {
+		if data[i] == 0 {
+			zeros++
+		}
+	}
+	if zeros*4 < len(data)/2*3 {
+		return string(data)
+	}
+
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+	}
+	return string(utf16.Decode(units))
+}
+
+// RegisterBase64ScanMatchers registers base64_scan with inner matchers
+// resolved from the same registry
+func RegisterBase64ScanMatchers(registry *MatcherRegistry) {
+	registry.RegisterMatcher("base64_scan", CreateBase64ScanMatch(registry))
+}
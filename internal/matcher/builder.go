@@ -4,13 +4,15 @@ import (
 	"fmt"
 
 	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
 )
 
 // MatcherBuilder provides a builder pattern for creating compiled primitives
 // with custom match functions, modifiers, and compilation hooks
 type MatcherBuilder struct {
-	registry *MatcherRegistry
-	compiled []*CompiledPrimitive
+	registry     *MatcherRegistry
+	compiled     []*CompiledPrimitive
+	modifierMode UnknownModifierMode
 }
 
 // NewMatcherBuilder creates a new matcher builder with default registry
@@ -32,6 +34,28 @@ func NewMatcherBuilderWithRegistry(registry *MatcherRegistry) *MatcherBuilder {
 	}
 }
 
+// NewComprehensiveMatcherBuilder creates a builder with every built-in
+// matcher and modifier registered
+func NewComprehensiveMatcherBuilder() *MatcherBuilder {
+	builder := NewMatcherBuilder().WithComprehensiveDefaults()
+	RegisterAdvancedMatchers(builder.GetRegistry())
+	RegisterTimeMatchers(builder.GetRegistry())
+	RegisterIPMatchers(builder.GetRegistry())
+	RegisterDomainMatchers(builder.GetRegistry())
+	RegisterUnicodeModifiers(builder.GetRegistry())
+	RegisterWindowsPathMatchers(builder.GetRegistry())
+	RegisterCommandLineMatchers(builder.GetRegistry())
+	RegisterBase64ScanMatchers(builder.GetRegistry())
+	RegisterComprehensiveModifiers(builder.GetRegistry())
+	return builder
+}
+
+// WithUnknownModifierMode sets how unknown modifiers are handled (strict by default)
+func (b *MatcherBuilder) WithUnknownModifierMode(mode UnknownModifierMode) *MatcherBuilder {
+	b.modifierMode = mode
+	return b
+}
+
 // WithDefaults registers default matchers and modifiers
 func (b *MatcherBuilder) WithDefaults() *MatcherBuilder {
 	// Register defaults using the builder's registry
@@ -79,18 +103,13 @@ func (b *MatcherBuilder) CompilePrimitive(primitive ir.Primitive) (*CompiledPrim
 	// Get match function
 	matchFn, exists := b.registry.GetMatcher(primitive.MatchType)
 	if !exists {
-		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMatchType, primitive.MatchType)
+		return nil, unsupportedMatchType(primitive)
 	}
 
 	// Build modifier chain
-	var modifierChain []ModifierFn
-	for _, modifierName := range primitive.Modifiers {
-		modifier, modExists := b.registry.GetModifier(modifierName)
-		if !modExists {
-			// Skip unknown modifiers (could be configurable)
-			continue
-		}
-		modifierChain = append(modifierChain, modifier)
+	modifierChain, err := buildModifierChain(primitive, b.registry, b.modifierMode)
+	if err != nil {
+		return nil, err
 	}
 
 	// Parse field path
@@ -103,9 +122,9 @@ func (b *MatcherBuilder) CompilePrimitive(primitive ir.Primitive) (*CompiledPrim
 		modifierChain,
 		primitive.Values,
 		primitive.Modifiers,
-	)
+	).withTypedValues(primitive).withIgnoreCase(primitive).WithFieldAliases(primitive.FieldAliases)
 
-	return compiled, nil
+	return compiled.withMatchFactory(primitive, b.registry)
 }
 
 // GetCompiledPrimitives returns the currently compiled primitives
@@ -133,16 +152,16 @@ func (b *MatcherBuilder) Stats() *CompiledPrimitiveStats {
 func (b *MatcherBuilder) Validate() error {
 	for i, primitive := range b.compiled {
 		if primitive == nil {
-			return fmt.Errorf("primitive %d is nil", i)
+			return sigmaerrors.Errorf(sigmaerrors.ErrorTypeCompilation, "primitive %d is nil", i)
 		}
 		if len(primitive.FieldPath) == 0 {
-			return fmt.Errorf("primitive %d has empty field path", i)
+			return sigmaerrors.Errorf(sigmaerrors.ErrorTypeCompilation, "primitive %d has empty field path", i)
 		}
 		if primitive.MatchFn == nil {
-			return fmt.Errorf("primitive %d has nil match function", i)
+			return sigmaerrors.Errorf(sigmaerrors.ErrorTypeCompilation, "primitive %d has nil match function", i)
 		}
 		if len(primitive.Values) == 0 {
-			return fmt.Errorf("primitive %d has no values", i)
+			return sigmaerrors.Errorf(sigmaerrors.ErrorTypeCompilation, "primitive %d has no values", i)
 		}
 	}
 	return nil
@@ -230,15 +249,44 @@ func (b *MatcherBuilder) registerAdvancedToRegistry() {
 // MatcherEvaluator provides evaluation capabilities for compiled primitives
 type MatcherEvaluator struct {
 	primitives []*CompiledPrimitive
+	ids        []ir.PrimitiveID // ids[i] identifies primitives[i]
 }
 
-// NewMatcherEvaluator creates a new evaluator with compiled primitives
+// NewMatcherEvaluator creates a new evaluator with compiled primitives,
+// identified by their position
 func NewMatcherEvaluator(primitives []*CompiledPrimitive) *MatcherEvaluator {
+	ids := make([]ir.PrimitiveID, len(primitives))
+	for i := range ids {
+		ids[i] = ir.PrimitiveID(i)
+	}
 	return &MatcherEvaluator{
 		primitives: primitives,
+		ids:        ids,
 	}
 }
 
+// NewMatcherEvaluatorWithIDs creates a new evaluator whose primitives are
+// identified by the given primitive IDs
+func NewMatcherEvaluatorWithIDs(primitives []*CompiledPrimitive, ids []ir.PrimitiveID) (*MatcherEvaluator, error) {
+	if len(primitives) != len(ids) {
+		return nil, sigmaerrors.Errorf(sigmaerrors.ErrorTypeCompilation, "got %d primitive IDs for %d primitives", len(ids), len(primitives))
+	}
+	seen := make(map[ir.PrimitiveID]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return nil, sigmaerrors.Errorf(sigmaerrors.ErrorTypeCompilation, "duplicate primitive ID %d", id)
+		}
+		seen[id] = true
+	}
+
+	idsCopy := make([]ir.PrimitiveID, len(ids))
+	copy(idsCopy, ids)
+	return &MatcherEvaluator{
+		primitives: primitives,
+		ids:        idsCopy,
+	}, nil
+}
+
 // Evaluate evaluates all primitives against an event
 func (e *MatcherEvaluator) Evaluate(event interface{}) ([]bool, error) {
 	ctx := NewEventContext(event)
@@ -247,7 +295,7 @@ func (e *MatcherEvaluator) Evaluate(event interface{}) ([]bool, error) {
 	for i, primitive := range e.primitives {
 		matched, err := primitive.Matches(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("primitive %d evaluation failed: %w", i, err)
+			return nil, fmt.Errorf("primitive %d evaluation failed: %w", e.ids[i], err)
 		}
 		results[i] = matched
 	}
@@ -255,6 +303,36 @@ func (e *MatcherEvaluator) Evaluate(event interface{}) ([]bool, error) {
 	return results, nil
 }
 
+// EvaluateAll evaluates all primitives against an event and returns the
+// results keyed by primitive ID
+func (e *MatcherEvaluator) EvaluateAll(event interface{}) (map[ir.PrimitiveID]bool, error) {
+	ctx := NewEventContext(event)
+	results := make(map[ir.PrimitiveID]bool, len(e.primitives))
+
+	for i, primitive := range e.primitives {
+		matched, err := primitive.Matches(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("primitive %d evaluation failed: %w", e.ids[i], err)
+		}
+		results[e.ids[i]] = matched
+	}
+
+	return results, nil
+}
+
+// EvaluateAllWithResults evaluates all primitives and returns detailed
+// results keyed by primitive ID
+func (e *MatcherEvaluator) EvaluateAllWithResults(event interface{}) (map[ir.PrimitiveID]*MatchResult, error) {
+	ctx := NewEventContext(event)
+	results := make(map[ir.PrimitiveID]*MatchResult, len(e.primitives))
+
+	for i, primitive := range e.primitives {
+		results[e.ids[i]] = primitive.MatchesWithResult(ctx)
+	}
+
+	return results, nil
+}
+
 // EvaluateWithResults evaluates all primitives and returns detailed results
 func (e *MatcherEvaluator) EvaluateWithResults(event interface{}) ([]*MatchResult, error) {
 	ctx := NewEventContext(event)
@@ -275,7 +353,7 @@ func (e *MatcherEvaluator) EvaluateWithContext(ctx *EventContext) ([]bool, error
 	for i, primitive := range e.primitives {
 		matched, err := primitive.Matches(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("primitive %d evaluation failed: %w", i, err)
+			return nil, fmt.Errorf("primitive %d evaluation failed: %w", e.ids[i], err)
 		}
 		results[i] = matched
 	}
@@ -293,6 +371,14 @@ func (e *MatcherEvaluator) PrimitiveCount() int {
 	return len(e.primitives)
 }
 
+// PrimitiveIDs returns the primitive IDs in evaluation order, so ids[i]
+// identifies the i-th result of Evaluate
+func (e *MatcherEvaluator) PrimitiveIDs() []ir.PrimitiveID {
+	ids := make([]ir.PrimitiveID, len(e.ids))
+	copy(ids, e.ids)
+	return ids
+}
+
 // BuildEvaluator builds both compiler and evaluator in one step
 func (b *MatcherBuilder) BuildEvaluator(primitives []ir.Primitive) (*MatcherEvaluator, error) {
 	compiled, err := b.Compile(primitives)
@@ -303,6 +389,21 @@ func (b *MatcherBuilder) BuildEvaluator(primitives []ir.Primitive) (*MatcherEval
 	return NewMatcherEvaluator(compiled), nil
 }
 
+// BuildEvaluatorFromRuleset compiles the primitives of a ruleset into an
+// evaluator keyed by their ruleset primitive IDs
+func (b *MatcherBuilder) BuildEvaluatorFromRuleset(ruleset *ir.CompiledRuleset) (*MatcherEvaluator, error) {
+	compiled, err := b.Compile(ruleset.Primitives)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]ir.PrimitiveID, len(compiled))
+	for i := range ids {
+		ids[i] = ir.PrimitiveID(i)
+	}
+	return NewMatcherEvaluatorWithIDs(compiled, ids)
+}
+
 // QuickBuild provides a convenient way to build an evaluator with defaults
 func QuickBuild(primitives []ir.Primitive) (*MatcherEvaluator, error) {
 	builder := NewMatcherBuilder().WithDefaults()
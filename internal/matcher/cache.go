@@ -4,6 +4,8 @@ import (
 	"regexp"
 	"sync"
 	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
 )
 
 // GlobalRegexCache provides thread-safe caching of compiled regex patterns
@@ -30,6 +32,7 @@ type CacheConfig struct {
 	TTL             time.Duration // Time to live for cached patterns
 	HotThreshold    int64         // Access count threshold for hot patterns
 	CleanupInterval time.Duration // Interval for cleanup of expired patterns
+	Clock           clock.Clock   // Time source for access times and expiry (nil = system clock)
 }
 
 // CacheStats contains statistics about cache performance
@@ -68,6 +71,7 @@ func DefaultCacheConfig() CacheConfig {
 
 // NewGlobalRegexCache creates a new regex cache with the given configuration
 func NewGlobalRegexCache(config CacheConfig) *GlobalRegexCache {
+	config.Clock = clock.Or(config.Clock)
 	return &GlobalRegexCache{
 		cache:  make(map[string]*CachedRegex),
 		config: config,
@@ -82,7 +86,7 @@ func (c *GlobalRegexCache) GetOrCompile(pattern string) (*regexp.Regexp, error)
 	if exists {
 		// Update access statistics
 		cached.AccessCount++
-		cached.LastAccess = time.Now()
+		cached.LastAccess = c.config.Clock.Now()
 		if cached.AccessCount >= c.config.HotThreshold {
 			cached.IsHot = true
 		}
@@ -99,7 +103,7 @@ func (c *GlobalRegexCache) GetOrCompile(pattern string) (*regexp.Regexp, error)
 	// Double-check locking pattern
 	if cached, exists := c.cache[pattern]; exists {
 		cached.AccessCount++
-		cached.LastAccess = time.Now()
+		cached.LastAccess = c.config.Clock.Now()
 		c.stats.Hits++
 		return cached.Regex, nil
 	}
@@ -116,7 +120,7 @@ func (c *GlobalRegexCache) GetOrCompile(pattern string) (*regexp.Regexp, error)
 	}
 
 	// Add to cache
-	now := time.Now()
+	now := c.config.Clock.Now()
 	c.cache[pattern] = &CachedRegex{
 		Regex:       compiled,
 		Pattern:     pattern,
@@ -184,7 +188,7 @@ func (c *GlobalRegexCache) cleanup() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	now := time.Now()
+	now := c.config.Clock.Now()
 	var toDelete []string
 
 	for key, cached := range c.cache {
@@ -0,0 +1,198 @@
+package matcher
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Argument-aware command line matchers.
+//
+// The field value is split into arguments and each argument is compared on
+// its own, so `arg_equals: -enc` matches "powershell -enc AAA" but not
+// "tool.exe --encoding=utf8 -encrypt". The quoting parameter selects the
+// tokenizer:
+//
+//	quoting:windows   CommandLineToArgvW rules, case-insensitive (default)
+//	quoting:posix     shell quoting without expansion, case-sensitive
+
+// CommandLineQuoting selects the rules used to split a command line
+type CommandLineQuoting int
+
+const (
+	// WindowsQuoting follows CommandLineToArgvW
+	WindowsQuoting CommandLineQuoting = iota
+	// POSIXQuoting follows POSIX shell quoting without expansion
+	POSIXQuoting
+)
+
+// TokenizeCommandLine splits a command line into arguments
+func TokenizeCommandLine(commandLine string, quoting CommandLineQuoting) []string {
+	if quoting == POSIXQuoting {
+		return tokenizePOSIX(commandLine)
+	}
+	return tokenizeWindows(commandLine)
+}
+
+// tokenizeWindows applies the CommandLineToArgvW rules: 2n backslashes before
+// a quote yield n backslashes and toggle quoting, 2n+1 yield n backslashes
+// and a literal quote, and "" inside quotes is a literal quote
+func tokenizeWindows(commandLine string) []string {
+	var args []string
+	var current strings.Builder
+	inArg, inQuotes := false, false
+
+	for i := 0; i < len(commandLine); i++ {
+		c := commandLine[i]
+		switch {
+		case c == '\\':
+			backslashes := 0
+			for i < len(commandLine) && commandLine[i] == '\\' {
+				backslashes++
+				i++
+			}
+			if i < len(commandLine) && commandLine[i] == '"' {
+				current.WriteString(strings.Repeat(`\`, backslashes/2))
+				if backslashes%2 == 1 {
+					current.WriteByte('"')
+				} else {
+					inQuotes = !inQuotes
+				}
+			} else {
+				current.WriteString(strings.Repeat(`\`, backslashes))
+				i--
+			}
+			inArg = true
+		case c == '"':
+			if inQuotes && i+1 < len(commandLine) && commandLine[i+1] == '"' {
+				current.WriteByte('"')
+				i++
+			} else {
+				inQuotes = !inQuotes
+			}
+			inArg = true
+		case (c == ' ' || c == '\t' || c == '\n' || c == '\r') && !inQuotes:
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// tokenizePOSIX applies shell quoting: single quotes are literal, double
+// quotes allow \" \\ \$ and \` escapes, and a backslash outside quotes
+// escapes the next character
+func tokenizePOSIX(commandLine string) []string {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote byte
+
+	for i := 0; i < len(commandLine); i++ {
+		c := commandLine[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				current.WriteByte(c)
+			}
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else if c == '\\' && i+1 < len(commandLine) && strings.IndexByte("\"\\$`", commandLine[i+1]) >= 0 {
+				i++
+				current.WriteByte(commandLine[i])
+			} else {
+				current.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == '\\' && i+1 < len(commandLine):
+			i++
+			current.WriteByte(commandLine[i])
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// parseCommandLineQuoting reads the quoting parameter from the modifiers
+func parseCommandLineQuoting(modifiers []string) (CommandLineQuoting, error) {
+	for _, modifier := range modifiers {
+		if name, ok := strings.CutPrefix(modifier, "quoting:"); ok {
+			switch strings.ToLower(name) {
+			case "windows":
+				return WindowsQuoting, nil
+			case "posix":
+				return POSIXQuoting, nil
+			default:
+				return WindowsQuoting, fmt.Errorf("unknown command line quoting %q", name)
+			}
+		}
+	}
+	return WindowsQuoting, nil
+}
+
+// createArgumentMatch creates a matcher comparing each argument to the patterns
+func createArgumentMatch(compare func(arg, pattern string) bool) MatchFn {
+	return func(fieldValue string, values []string, modifiers []string) (bool, error) {
+		quoting, err := parseCommandLineQuoting(modifiers)
+		if err != nil {
+			return false, err
+		}
+		for _, arg := range TokenizeCommandLine(fieldValue, quoting) {
+			if quoting == WindowsQuoting {
+				arg = strings.ToLower(arg)
+			}
+			for _, value := range values {
+				if quoting == WindowsQuoting {
+					value = strings.ToLower(value)
+				}
+				if compare(arg, value) {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+}
+
+// CreateArgEqualsMatch creates a matcher for command lines with an argument
+// equal to any pattern
+func CreateArgEqualsMatch() MatchFn {
+	return createArgumentMatch(func(arg, pattern string) bool { return arg == pattern })
+}
+
+// CreateArgContainsMatch creates a matcher for command lines with an argument
+// containing any pattern
+func CreateArgContainsMatch() MatchFn {
+	return createArgumentMatch(strings.Contains)
+}
+
+// RegisterCommandLineMatchers registers the argument-aware matchers
+func RegisterCommandLineMatchers(registry *MatcherRegistry) {
+	registry.RegisterMatcher("arg_equals", CreateArgEqualsMatch())
+	registry.RegisterMatcher("arg_contains", CreateArgContainsMatch())
+}
@@ -0,0 +1,115 @@
+package matcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ValueString converts an event value to the string matchers compare rule
+// values against. The result is the same as formatting with fmt's %v, but
+// strings, booleans, integers, floats and json.Number take fast paths that
+// skip fmt's reflection and allocate at most the resulting string.
+func ValueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case json.Number:
+		return string(v)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// ValueStrings converts an event value to strings: one per item of a list
+// value, otherwise a single string (see ValueString)
+func ValueStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		result := make([]string, len(v))
+		for i, item := range v {
+			result[i] = ValueString(item)
+		}
+		return result
+	}
+	return []string{ValueString(value)}
+}
+
+// ValueNumber returns the numeric value of an integer, float or json.Number
+// event value. Strings, booleans and other values are not numbers, so
+// "4624" keeps string semantics.
+func ValueNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// parseNumericValue parses a rule value written as a finite decimal number
+// ("4624", "-1.5", "1e6"). Hexadecimal, infinite and NaN values are left to
+// string matching.
+func parseNumericValue(value string) (float64, bool) {
+	if value == "" || strings.ContainsAny(value, "xXnN") {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
@@ -2,10 +2,13 @@ package matcher
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
 )
 
 // CompiledPrimitive represents a pre-compiled primitive with optimized match functions
@@ -29,9 +32,33 @@ type CompiledPrimitive struct {
 	// Field path as a dot-separated string (cached for performance)
 	fieldPathString string
 
+	// Paths read in order when the event lacks the field (see WithFieldAliases)
+	aliasPaths []string
+
+	// Identifies the modifier chain in the event context's transformed value cache
+	modifierKey string
+
+	// Chain and cache key of the field value compared with Values: the
+	// modifier chain, followed by lowercasing when case is ignored (see
+	// withIgnoreCase)
+	matchChain []ModifierFn
+	matchKey   string
+
 	// Whether all values are literal (no wildcards)
 	isLiteralOnly bool
 
+	// Values compared by type rather than as strings (equality primitives
+	// without modifiers only, see withTypedValues): values written as
+	// numbers, booleans, and the index of a null value (-1 = none)
+	numericValues []numericValue
+	boolValues    []boolValue
+	nullIndex     int
+
+	// Index of an empty string value when there is a null value (-1 =
+	// none): null is held as an empty value that empty strings must not
+	// match
+	emptyIndex int
+
 	// Estimated memory usage
 	memoryUsage int
 }
@@ -68,9 +95,139 @@ func NewCompiledPrimitive(
 		Values:          valuesCopy,
 		RawModifiers:    modifiersCopy,
 		fieldPathString: fieldPathString,
+		modifierKey:     strings.Join(modifiersCopy, "|"),
+		matchChain:      modifierChainCopy,
+		matchKey:        strings.Join(modifiersCopy, "|"),
 		isLiteralOnly:   isLiteralOnly,
 		memoryUsage:     memoryUsage,
+		nullIndex:       -1,
+		emptyIndex:      -1,
+	}
+}
+
+// numericValue is a rule value parsed as a number
+type numericValue struct {
+	index int
+	value float64
+}
+
+// boolValue is a boolean rule value
+type boolValue struct {
+	index int
+	value bool
+}
+
+// withTypedValues enables typed comparison for equality primitives without
+// modifiers, following the SIGMA value types:
+//   - values written as numbers equal numeric event values (int, float64 or
+//     json.Number) of the same value, so 4624 equals "4624" and "4624.0"
+//     without formatting the event value as a string
+//   - boolean values equal boolean event values and strings spelling the
+//     boolean in any case ("True")
+//   - null values match when the field is missing or null, not when it is
+//     an empty string
+//
+// Other values, and values of other event types, are matched as strings.
+func (cp *CompiledPrimitive) withTypedValues(primitive ir.Primitive) *CompiledPrimitive {
+	if (primitive.MatchType != "equals" && primitive.MatchType != "exact") || len(cp.ModifierChain) > 0 {
+		return cp
+	}
+	for i, value := range cp.Values {
+		switch primitive.ValueKind(i) {
+		case ir.ValueKindNull:
+			if cp.nullIndex < 0 {
+				cp.nullIndex = i
+			}
+		case ir.ValueKindBool:
+			if b, err := strconv.ParseBool(value); err == nil {
+				cp.boolValues = append(cp.boolValues, boolValue{index: i, value: b})
+			}
+		default:
+			if value == "" && cp.emptyIndex < 0 {
+				cp.emptyIndex = i
+			}
+			if number, ok := parseNumericValue(value); ok {
+				cp.numericValues = append(cp.numericValues, numericValue{index: i, value: number})
+			}
+		}
+	}
+	return cp
+}
+
+// withMatchFactory builds the match function with the registry's factory
+// for the primitive's match type, if it has one, from the compiled values
+func (cp *CompiledPrimitive) withMatchFactory(primitive ir.Primitive, registry *MatcherRegistry) (*CompiledPrimitive, error) {
+	factory, exists := registry.GetMatchFactory(primitive.MatchType)
+	if !exists {
+		return cp, nil
+	}
+	matchFn, err := factory(cp.Values, cp.RawModifiers)
+	if err != nil {
+		return nil, sigmaerrors.WithField(err, sigmaerrors.ErrorTypeCompilation, primitive.Field)
+	}
+	cp.MatchFn = matchFn
+	return cp, nil
+}
+
+// ignoreCaseKey suffixes the modifier key of primitives ignoring case, so
+// their lowercased field values are cached apart from the plain values
+const ignoreCaseKey = "\x00ignorecase"
+
+// withIgnoreCase makes string matching case-insensitive, the SIGMA default,
+// for primitives compiled with IgnoreCase: values and (transformed) field
+// values are lowercased before matching. Regular expressions and custom
+// match types are left as is, they handle case themselves.
+func (cp *CompiledPrimitive) withIgnoreCase(primitive ir.Primitive) *CompiledPrimitive {
+	if primitive.IgnoreCase && primitive.MatchType == "base64_scan" {
+		return cp.withBase64ScanIgnoreCase()
+	}
+	if !primitive.IgnoreCase || !caseFoldingMatchTypes[primitive.MatchType] {
+		return cp
 	}
+	for i, value := range cp.Values {
+		cp.Values[i] = strings.ToLower(value)
+	}
+	cp.matchChain = append(append([]ModifierFn(nil), cp.ModifierChain...), CreateLowercaseModifier())
+	cp.matchKey = cp.modifierKey + ignoreCaseKey
+	return cp
+}
+
+// withBase64ScanIgnoreCase lowercases the values of a base64_scan primitive
+// and has the matcher lowercase decoded content instead of the field value,
+// which is decoded as is. Inner matchers that do not fold case are left
+// as is.
+func (cp *CompiledPrimitive) withBase64ScanIgnoreCase() *CompiledPrimitive {
+	options, err := parseBase64ScanOptions(cp.RawModifiers)
+	if err != nil || options.ignoreCase || !caseFoldingMatchTypes[options.inner] {
+		return cp
+	}
+	for i, value := range cp.Values {
+		cp.Values[i] = strings.ToLower(value)
+	}
+	cp.RawModifiers = append(cp.RawModifiers, base64ScanIgnoreCase)
+	return cp
+}
+
+// caseFoldingMatchTypes are the match types compared ignoring case when a
+// primitive has IgnoreCase
+var caseFoldingMatchTypes = map[string]bool{
+	"equals":     true,
+	"exact":      true,
+	"contains":   true,
+	"startswith": true,
+	"endswith":   true,
+	"wildcard":   true,
+	"glob":       true,
+}
+
+// IgnoresCase reports whether the primitive matches strings ignoring case
+func (cp *CompiledPrimitive) IgnoresCase() bool {
+	return cp.matchKey != cp.modifierKey
+}
+
+// hasTypedValues reports whether any value is compared by type
+func (cp *CompiledPrimitive) hasTypedValues() bool {
+	return len(cp.numericValues) > 0 || len(cp.boolValues) > 0 || cp.nullIndex >= 0
 }
 
 // FieldPathString returns the field path as a dot-separated string
@@ -78,6 +235,33 @@ func (cp *CompiledPrimitive) FieldPathString() string {
 	return cp.fieldPathString
 }
 
+// WithFieldAliases sets the field paths read, in order, from events that
+// lack the primitive's field, e.g. winlog.event_data.CommandLine for
+// process.command_line
+func (cp *CompiledPrimitive) WithFieldAliases(aliases []string) *CompiledPrimitive {
+	cp.aliasPaths = append([]string(nil), aliases...)
+	return cp
+}
+
+// FieldAliases returns the alias paths of the primitive's field
+func (cp *CompiledPrimitive) FieldAliases() []string {
+	return cp.aliasPaths
+}
+
+// fieldPathIn returns the path the primitive reads in an event: its field,
+// or the first alias present in the event when the field is not
+func (cp *CompiledPrimitive) fieldPathIn(ctx *EventContext) string {
+	if len(cp.aliasPaths) == 0 || ctx.HasField(cp.fieldPathString) {
+		return cp.fieldPathString
+	}
+	for _, alias := range cp.aliasPaths {
+		if ctx.HasField(alias) {
+			return alias
+		}
+	}
+	return cp.fieldPathString
+}
+
 // HasModifiers returns true if the primitive has any modifiers
 func (cp *CompiledPrimitive) HasModifiers() bool {
 	return len(cp.ModifierChain) > 0
@@ -100,41 +284,134 @@ func (cp *CompiledPrimitive) MemoryUsage() int {
 
 // Matches evaluates this primitive against an event context
 func (cp *CompiledPrimitive) Matches(ctx *EventContext) (bool, error) {
-	// Extract field value from event
-	fieldValue, exists, err := ctx.GetFieldAsString(cp.fieldPathString)
+	if index, handled, err := cp.matchTyped(ctx); handled || err != nil {
+		return index >= 0, err
+	}
+
+	// Extract and transform the field value (cached per event)
+	transformedValue, exists, err := ctx.GetTransformedField(cp.fieldPathIn(ctx), cp.matchKey, cp.matchChain)
 	if err != nil {
-		return false, fmt.Errorf("field extraction failed: %w", err)
+		return false, err
 	}
 	if !exists {
 		return false, nil // Field not found = no match
 	}
 
-	// Apply modifier chain to transform the field value
-	transformedValue := fieldValue
-	for _, modifier := range cp.ModifierChain {
-		transformedValue, err = modifier(transformedValue)
-		if err != nil {
-			return false, fmt.Errorf("modifier failed: %w", err)
+	// Apply match function
+	return cp.matchValues(ctx, transformedValue)
+}
+
+// matchTyped compares the event value with the values compared by type
+// (see withTypedValues), returning the index of the first matching value or
+// -1. handled is false when the result is left to string matching: the
+// primitive has no typed values, or the event value matches none of the
+// typed values and may still equal another value as a string. Rule values
+// that are not numbers never equal a finite number's string form, so
+// numeric event values are always handled when there are numeric values.
+func (cp *CompiledPrimitive) matchTyped(ctx *EventContext) (int, bool, error) {
+	if !cp.hasTypedValues() {
+		return -1, false, nil
+	}
+	path := cp.fieldPathIn(ctx)
+	value, exists, err := ctx.GetField(path)
+	if err != nil {
+		return -1, false, sigmaerrors.WithField(err, sigmaerrors.ErrorTypeFieldExtraction, path)
+	}
+	if !exists {
+		// Missing and null fields only match null
+		return cp.nullIndex, true, nil
+	}
+
+	switch v := value.(type) {
+	case bool:
+		for _, b := range cp.boolValues {
+			if b.value == v {
+				return b.index, true, nil
+			}
+		}
+		return -1, false, nil
+	case string:
+		if v == "" && cp.nullIndex >= 0 {
+			return cp.emptyIndex, true, nil
+		}
+		for _, b := range cp.boolValues {
+			if strings.EqualFold(v, strconv.FormatBool(b.value)) {
+				return b.index, true, nil
+			}
 		}
+		return -1, false, nil
 	}
 
-	// Apply match function
-	matched, err := cp.MatchFn(transformedValue, cp.Values, cp.RawModifiers)
-	if err != nil {
-		return false, fmt.Errorf("match function failed: %w", err)
+	number, ok := ValueNumber(value)
+	if !ok || len(cp.numericValues) == 0 || math.IsInf(number, 0) || math.IsNaN(number) {
+		return -1, false, nil
+	}
+	if err := ctx.CheckDeadline(); err != nil {
+		return -1, true, err
+	}
+	for _, numeric := range cp.numericValues {
+		if numeric.value == number {
+			return numeric.index, true, nil
+		}
+	}
+	return -1, true, nil
+}
+
+// deadlineCheckInterval is how many values a primitive matches between
+// deadline checks when the event has a deadline
+const deadlineCheckInterval = 16
+
+// matchValues applies the match function to a transformed field value. When
+// the event has a deadline the values are matched in groups, checking the
+// deadline before each group; match functions match any of their values, so
+// grouping does not change the result. Deadline errors are returned as is.
+func (cp *CompiledPrimitive) matchValues(ctx *EventContext, transformedValue string) (bool, error) {
+	values := cp.Values
+	groupSize := len(values)
+	if _, hasDeadline := ctx.Deadline(); hasDeadline {
+		groupSize = deadlineCheckInterval
 	}
 
-	return matched, nil
+	for {
+		if err := ctx.CheckDeadline(); err != nil {
+			return false, err
+		}
+		group := values[:min(groupSize, len(values))]
+		matched, err := cp.MatchFn(transformedValue, group, cp.RawModifiers)
+		if err != nil {
+			return false, sigmaerrors.WithField(err, sigmaerrors.ErrorTypeExecution, cp.fieldPathString)
+		}
+		values = values[len(group):]
+		if matched || len(values) == 0 {
+			return matched, nil
+		}
+	}
 }
 
 // MatchesWithResult evaluates this primitive and returns detailed match result
 func (cp *CompiledPrimitive) MatchesWithResult(ctx *EventContext) *MatchResult {
-	result := NewMatchResult(false, cp.fieldPathString)
+	path := cp.fieldPathIn(ctx)
+	result := NewMatchResult(false, path)
 
 	// Extract field value from event
-	fieldValue, exists, err := ctx.GetFieldAsString(cp.fieldPathString)
+	fieldValue, exists, err := ctx.GetFieldAsString(path)
+	if err != nil {
+		return result.WithError(sigmaerrors.WithField(err, sigmaerrors.ErrorTypeFieldExtraction, path))
+	}
+
+	index, handled, err := cp.matchTyped(ctx)
 	if err != nil {
-		return result.WithError(fmt.Errorf("field extraction failed: %w", err))
+		return result.WithError(err)
+	}
+	if handled {
+		// Typed values are only compared without modifiers
+		result.MatchedValue = fieldValue
+		result.TransformedValue = fieldValue
+		if index >= 0 {
+			result.Matched = true
+			result.WithMatchedPattern(index, cp.Values[index])
+		}
+		return result
 	}
 	if !exists {
 		return result // Field not found = no match
@@ -142,37 +419,60 @@ func (cp *CompiledPrimitive) MatchesWithResult(ctx *EventContext) *MatchResult {
 
 	result.MatchedValue = fieldValue
 
-	// Apply modifier chain to transform the field value
-	transformedValue := fieldValue
-	for _, modifier := range cp.ModifierChain {
-		var modErr error
-		transformedValue, modErr = modifier(transformedValue)
-		if modErr != nil {
-			return result.WithError(fmt.Errorf("modifier failed: %w", modErr))
-		}
+	// Apply modifier chain to transform the field value (cached per event)
+	transformedValue, exists, err := ctx.GetTransformedField(path, cp.matchKey, cp.matchChain)
+	if err != nil {
+		return result.WithError(err)
+	}
+	if !exists {
+		return result // No value left by the modifiers = no match
 	}
 
 	result.TransformedValue = transformedValue
 
 	// Apply match function
-	matched, err := cp.MatchFn(transformedValue, cp.Values, cp.RawModifiers)
+	matched, err := cp.matchValues(ctx, transformedValue)
 	if err != nil {
-		return result.WithError(fmt.Errorf("match function failed: %w", err))
+		return result.WithError(err)
 	}
 
 	result.Matched = matched
+	if matched {
+		if index := cp.matchingValueIndex(transformedValue); index >= 0 {
+			result.WithMatchedPattern(index, cp.Values[index])
+		}
+	}
 	return result
 }
 
+// matchingValueIndex returns the index of the first value that matches the
+// transformed field value on its own, or -1 when no single value matches
+func (cp *CompiledPrimitive) matchingValueIndex(transformedValue string) int {
+	for i := range cp.Values {
+		matched, err := cp.MatchFn(transformedValue, cp.Values[i:i+1], cp.RawModifiers)
+		if err == nil && matched {
+			return i
+		}
+	}
+	return -1
+}
+
 // Clone creates a deep copy of the compiled primitive
 func (cp *CompiledPrimitive) Clone() *CompiledPrimitive {
-	return NewCompiledPrimitive(
+	clone := NewCompiledPrimitive(
 		cp.FieldPath,
 		cp.MatchFn,
 		cp.ModifierChain,
 		cp.Values,
 		cp.RawModifiers,
 	)
+	clone.numericValues = append([]numericValue(nil), cp.numericValues...)
+	clone.boolValues = append([]boolValue(nil), cp.boolValues...)
+	clone.nullIndex = cp.nullIndex
+	clone.emptyIndex = cp.emptyIndex
+	clone.matchChain = append([]ModifierFn(nil), cp.matchChain...)
+	clone.matchKey = cp.matchKey
+	return clone
 }
 
 // String returns a string representation for debugging
@@ -181,26 +481,28 @@ func (cp *CompiledPrimitive) String() string {
 		cp.fieldPathString, cp.Values, cp.RawModifiers)
 }
 
-// FromPrimitive creates a CompiledPrimitive from an IR Primitive
+// FromPrimitive creates a CompiledPrimitive from an IR Primitive, rejecting
+// unknown modifiers
 func FromPrimitive(primitive ir.Primitive) (*CompiledPrimitive, error) {
+	return FromPrimitiveWithMode(primitive, UnknownModifierError)
+}
+
+// FromPrimitiveWithMode creates a CompiledPrimitive from an IR Primitive
+// using the default registry and the given unknown-modifier handling
+func FromPrimitiveWithMode(primitive ir.Primitive, mode UnknownModifierMode) (*CompiledPrimitive, error) {
 	// Parse field path (split on dots for nested access)
 	fieldPath := strings.Split(primitive.Field, ".")
 
 	// Get match function from default registry
 	matchFn, exists := GetDefaultMatcher(primitive.MatchType)
 	if !exists {
-		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMatchType, primitive.MatchType)
+		return nil, unsupportedMatchType(primitive)
 	}
 
 	// Build modifier chain
-	var modifierChain []ModifierFn
-	for _, modifierName := range primitive.Modifiers {
-		modifier, modExists := GetDefaultModifier(modifierName)
-		if !modExists {
-			// For now, skip unknown modifiers (could be configurable)
-			continue
-		}
-		modifierChain = append(modifierChain, modifier)
+	modifierChain, err := buildModifierChain(primitive, defaultRegistry, mode)
+	if err != nil {
+		return nil, err
 	}
 
 	return NewCompiledPrimitive(
@@ -209,7 +511,15 @@ func FromPrimitive(primitive ir.Primitive) (*CompiledPrimitive, error) {
 		modifierChain,
 		primitive.Values,
 		primitive.Modifiers,
-	), nil
+	).withTypedValues(primitive).withIgnoreCase(primitive).withMatchFactory(primitive, defaultRegistry)
+}
+
+// unsupportedMatchType returns the error for a primitive whose match type is
+// not registered, which wraps ErrUnsupportedMatchType
+func unsupportedMatchType(primitive ir.Primitive) error {
+	err := sigmaerrors.Wrap(sigmaerrors.ErrorTypeUnsupportedMatchType, primitive.MatchType, ErrUnsupportedMatchType)
+	err.Field = primitive.Field
+	return err
 }
 
 // calculateIsLiteralOnly checks if all values are literal (no wildcards or regex)
@@ -283,6 +593,10 @@ func (p *CompiledPrimitivePool) Put(primitive *CompiledPrimitive) {
 	primitive.Values = nil
 	primitive.RawModifiers = nil
 	primitive.fieldPathString = ""
+	primitive.aliasPaths = nil
+	primitive.modifierKey = ""
+	primitive.matchChain = nil
+	primitive.matchKey = ""
 	primitive.isLiteralOnly = false
 	primitive.memoryUsage = 0
 
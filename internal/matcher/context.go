@@ -1,10 +1,15 @@
 package matcher
 
 import (
-	"fmt"
+	"errors"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+	"google.golang.org/protobuf/proto"
 )
 
 // EventContext provides efficient field value extraction and caching for events
@@ -14,6 +19,43 @@ type EventContext struct {
 	cache     map[string]interface{}
 	cacheMux  sync.RWMutex
 	extractor FieldExtractorFn
+
+	// String values, string list values and modifier-transformed values,
+	// shared by every primitive reading the same field (and modifier chain)
+	// in this event
+	stringCache      map[string]cachedString
+	stringListCache  map[string][]string
+	transformedCache map[transformKey]string
+
+	// Evaluation budget of the event (zero deadline = unlimited), read
+	// from deadlineClock
+	deadline      time.Time
+	deadlineClock clock.Clock
+
+	// Errors evaluation recovered from while matching the event
+	recoveredErrors int
+
+	// Resolve field paths missing from the event case-insensitively,
+	// through foldedFields: the event's map key paths by their lowercase
+	// form, indexed on the first miss
+	caseInsensitive bool
+	foldedFields    map[string]string
+}
+
+// maxFoldedFieldDepth bounds the nesting of event maps indexed for
+// case-insensitive field lookup
+const maxFoldedFieldDepth = 16
+
+// cachedString is a field value converted to string
+type cachedString struct {
+	value  string
+	exists bool
+}
+
+// transformKey identifies a field value after a modifier chain
+type transformKey struct {
+	fieldPath   string
+	modifierKey string
 }
 
 // NewEventContext creates a new event context with the given event
@@ -47,6 +89,15 @@ func (ctx *EventContext) GetField(fieldPath string) (interface{}, bool, error) {
 
 	// Extract field value
 	value, err := ctx.extractor(ctx.event, fieldPath)
+	if errors.Is(err, ErrFieldNotFound) && ctx.caseInsensitive {
+		if path, exists := ctx.foldedField(fieldPath); exists && path != fieldPath {
+			value, err = ctx.extractor(ctx.event, path)
+		}
+	}
+	if errors.Is(err, ErrFieldNotFound) {
+		// A missing field is not an error, it just doesn't match
+		value, err = nil, nil
+	}
 	if err != nil {
 		return nil, false, err
 	}
@@ -59,22 +110,129 @@ func (ctx *EventContext) GetField(fieldPath string) (interface{}, bool, error) {
 	return value, value != nil, nil
 }
 
+// SetCaseInsensitiveFields makes field paths missing from the event resolve
+// to a path differing only in case, e.g. "CommandLine" to "commandline".
+// Map events are indexed by lowercase key path on the first missing field;
+// when several paths fold to the same lowercase form, the lexically
+// smallest wins. Struct fields are always matched case-insensitively.
+func (ctx *EventContext) SetCaseInsensitiveFields(enabled bool) {
+	ctx.caseInsensitive = enabled
+}
+
+// foldedField returns the event's path that differs from fieldPath only in
+// case, indexing the event on first use
+func (ctx *EventContext) foldedField(fieldPath string) (string, bool) {
+	ctx.cacheMux.Lock()
+	defer ctx.cacheMux.Unlock()
+
+	if ctx.foldedFields == nil {
+		ctx.foldedFields = make(map[string]string)
+		if event, ok := ctx.event.(map[string]interface{}); ok {
+			indexFoldedFields(ctx.foldedFields, event, "", 0)
+		}
+	}
+	path, exists := ctx.foldedFields[strings.ToLower(fieldPath)]
+	return path, exists
+}
+
+// indexFoldedFields adds the key paths of an event map to index by their
+// lowercase form
+func indexFoldedFields(index map[string]string, event map[string]interface{}, prefix string, depth int) {
+	for key, value := range event {
+		path := prefix + key
+		folded := strings.ToLower(path)
+		if existing, exists := index[folded]; !exists || path < existing {
+			index[folded] = path
+		}
+		if nested, ok := value.(map[string]interface{}); ok && depth < maxFoldedFieldDepth {
+			indexFoldedFields(index, nested, path+".", depth+1)
+		}
+	}
+}
+
 // GetFieldAsString extracts a field value and converts it to string
 func (ctx *EventContext) GetFieldAsString(fieldPath string) (string, bool, error) {
+	ctx.cacheMux.RLock()
+	if cached, exists := ctx.stringCache[fieldPath]; exists {
+		ctx.cacheMux.RUnlock()
+		return cached.value, cached.exists, nil
+	}
+	ctx.cacheMux.RUnlock()
+
 	value, exists, err := ctx.GetField(fieldPath)
-	if err != nil || !exists {
-		return "", exists, err
+	if err != nil {
+		return "", false, err
 	}
 
-	if value == nil {
-		return "", false, nil
+	var cached cachedString
+	if exists && value != nil {
+		cached = cachedString{value: ValueString(value), exists: true}
 	}
 
-	return fmt.Sprintf("%v", value), true, nil
+	ctx.cacheMux.Lock()
+	if ctx.stringCache == nil {
+		ctx.stringCache = make(map[string]cachedString)
+	}
+	ctx.stringCache[fieldPath] = cached
+	ctx.cacheMux.Unlock()
+
+	return cached.value, cached.exists, nil
 }
 
-// GetFieldAsStringSlice extracts a field value and converts it to string slice
+// GetTransformedField extracts a field value as a string and applies a
+// modifier chain to it. The result is cached per (field, modifierKey), so
+// primitives sharing a field and modifier chain transform the value once per
+// event. modifierKey must uniquely identify the chain (e.g. the joined
+// modifier names). A modifier returning ErrNoValue leaves the field without
+// a value, as if it were missing.
+func (ctx *EventContext) GetTransformedField(fieldPath, modifierKey string, chain []ModifierFn) (string, bool, error) {
+	value, exists, err := ctx.GetFieldAsString(fieldPath)
+	if err != nil {
+		return "", false, sigmaerrors.WithField(err, sigmaerrors.ErrorTypeFieldExtraction, fieldPath)
+	}
+	if !exists || len(chain) == 0 {
+		return value, exists, nil
+	}
+
+	key := transformKey{fieldPath: fieldPath, modifierKey: modifierKey}
+	ctx.cacheMux.RLock()
+	if transformed, cached := ctx.transformedCache[key]; cached {
+		ctx.cacheMux.RUnlock()
+		return transformed, true, nil
+	}
+	ctx.cacheMux.RUnlock()
+
+	for _, modifier := range chain {
+		value, err = modifier(value)
+		if errors.Is(err, ErrNoValue) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, sigmaerrors.WithField(err, sigmaerrors.ErrorTypeModifier, fieldPath)
+		}
+	}
+
+	ctx.cacheMux.Lock()
+	if ctx.transformedCache == nil {
+		ctx.transformedCache = make(map[transformKey]string)
+	}
+	ctx.transformedCache[key] = value
+	ctx.cacheMux.Unlock()
+
+	return value, true, nil
+}
+
+// GetFieldAsStringSlice extracts a field value and converts it to string
+// slice, one string per item of a list value. The conversion is cached like
+// GetFieldAsString's.
 func (ctx *EventContext) GetFieldAsStringSlice(fieldPath string) ([]string, bool, error) {
+	ctx.cacheMux.RLock()
+	if cached, exists := ctx.stringListCache[fieldPath]; exists {
+		ctx.cacheMux.RUnlock()
+		return cached, true, nil
+	}
+	ctx.cacheMux.RUnlock()
+
 	value, exists, err := ctx.GetField(fieldPath)
 	if err != nil || !exists {
 		return nil, exists, err
@@ -84,21 +242,15 @@ func (ctx *EventContext) GetFieldAsStringSlice(fieldPath string) ([]string, bool
 		return nil, false, nil
 	}
 
-	// Handle different types
-	switch v := value.(type) {
-	case []string:
-		return v, true, nil
-	case []interface{}:
-		result := make([]string, len(v))
-		for i, item := range v {
-			result[i] = fmt.Sprintf("%v", item)
-		}
-		return result, true, nil
-	case string:
-		return []string{v}, true, nil
-	default:
-		return []string{fmt.Sprintf("%v", v)}, true, nil
+	values := ValueStrings(value)
+	ctx.cacheMux.Lock()
+	if ctx.stringListCache == nil {
+		ctx.stringListCache = make(map[string][]string)
 	}
+	ctx.stringListCache[fieldPath] = values
+	ctx.cacheMux.Unlock()
+
+	return values, true, nil
 }
 
 // HasField checks if a field exists in the event
@@ -112,6 +264,10 @@ func (ctx *EventContext) ClearCache() {
 	ctx.cacheMux.Lock()
 	defer ctx.cacheMux.Unlock()
 	ctx.cache = make(map[string]interface{})
+	ctx.stringCache = nil
+	ctx.stringListCache = nil
+	ctx.transformedCache = nil
+	ctx.foldedFields = nil
 }
 
 // CacheSize returns the number of cached field values
@@ -126,18 +282,72 @@ func (ctx *EventContext) GetEvent() interface{} {
 	return ctx.event
 }
 
+// SetDeadline limits how long matching may run for this event. Primitives
+// check the deadline between groups of values and fail with an
+// ErrorTypeExecutionTimeout error once it has passed. A nil source reads
+// the wall clock; a zero deadline removes the limit.
+func (ctx *EventContext) SetDeadline(deadline time.Time, source clock.Clock) {
+	ctx.deadline = deadline
+	ctx.deadlineClock = clock.Or(source)
+}
+
+// Deadline returns the event's deadline and whether one is set
+func (ctx *EventContext) Deadline() (time.Time, bool) {
+	return ctx.deadline, !ctx.deadline.IsZero()
+}
+
+// CheckDeadline returns an ErrorTypeExecutionTimeout error when the event's
+// deadline has passed
+func (ctx *EventContext) CheckDeadline() error {
+	if ctx.deadline.IsZero() || ctx.deadlineClock.Now().Before(ctx.deadline) {
+		return nil
+	}
+	return sigmaerrors.NewExecutionTimeout()
+}
+
+// RecordError counts an error evaluation recovered from while matching the
+// event, such as a failing matcher treated as not matching
+func (ctx *EventContext) RecordError() {
+	ctx.recoveredErrors++
+}
+
+// RecoveredErrors returns the number of errors recorded with RecordError
+func (ctx *EventContext) RecoveredErrors() int {
+	if ctx == nil {
+		return 0
+	}
+	return ctx.recoveredErrors
+}
+
 // SetExtractor sets a custom field extractor
 func (ctx *EventContext) SetExtractor(extractor FieldExtractorFn) {
 	ctx.extractor = extractor
 }
 
 // DefaultFieldExtractor is the default implementation for extracting field values
-// Supports map[string]interface{} and struct field access with dot notation
+// Supports map[string]interface{}, struct field access with dot notation,
+// FieldAccessor events and protobuf messages
 func DefaultFieldExtractor(event interface{}, fieldPath string) (interface{}, error) {
 	if event == nil {
 		return nil, ErrFieldNotFound
 	}
 
+	// Events that resolve paths themselves, including protobuf messages
+	var accessor FieldAccessor
+	switch e := event.(type) {
+	case FieldAccessor:
+		accessor = e
+	case proto.Message:
+		accessor = NewProtoEvent(e)
+	}
+	if accessor != nil {
+		value, exists := accessor.LookupField(fieldPath)
+		if !exists {
+			return nil, ErrFieldNotFound
+		}
+		return value, nil
+	}
+
 	// Split field path on dots
 	parts := strings.Split(fieldPath, ".")
 	if len(parts) == 0 {
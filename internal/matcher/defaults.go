@@ -14,6 +14,15 @@ func RegisterDefaultMatchers() {
 	// Register comprehensive modifiers from modifiers.go
 	RegisterComprehensiveModifiers(registry)
 
+	// Modifier aliases registered by MatcherBuilder.WithDefaults, so both
+	// registries accept the same names
+	registry.RegisterModifier("lowercase", CreateLowercaseModifier())
+	registry.RegisterModifier("uppercase", CreateUppercaseModifier())
+	registry.RegisterModifier("base64", CreateBase64DecodeModifier())
+	registry.RegisterModifier("base64decode", CreateBase64DecodeModifier())
+	registry.RegisterModifier("trim", CreateTrimModifier())
+	registry.RegisterModifier("trimspace", CreateTrimModifier())
+
 	// Exact match functions
 	registry.RegisterMatcher("equals", CreateExactMatch())
 	registry.RegisterMatcher("exact", CreateExactMatch())
@@ -32,6 +41,30 @@ func RegisterDefaultMatchers() {
 	registry.RegisterMatcher("range", CreateNumericRangeMatch())
 	registry.RegisterMatcher("fuzzy", CreateFuzzyMatch())
 	registry.RegisterMatcher("length", CreateLengthMatch())
+	registry.RegisterMatcher("length_gt", CreateLengthGreaterThanMatch())
+	registry.RegisterMatcher("length_lt", CreateLengthLessThanMatch())
+	registry.RegisterMatcher("entropy_gt", CreateEntropyGreaterThanMatch())
+
+	// Timestamp matching functions from time.go
+	RegisterTimeMatchers(registry)
+
+	// IP classification and range matching functions from ip.go
+	RegisterIPMatchers(registry)
+
+	// Domain and URL matching functions from domain.go
+	RegisterDomainMatchers(registry)
+
+	// Unicode normalization modifiers from unicode.go
+	RegisterUnicodeModifiers(registry)
+
+	// Windows path matching functions from winpath.go
+	RegisterWindowsPathMatchers(registry)
+
+	// Argument-aware command line matching functions from cmdline.go
+	RegisterCommandLineMatchers(registry)
+
+	// Base64 scanning matcher from base64scan.go
+	RegisterBase64ScanMatchers(registry)
 
 	// Wildcard matching functions
 	registry.RegisterMatcher("glob", CreateGlobMatch())
@@ -152,7 +185,8 @@ func CreateGlobMatch() MatchFn {
 }
 
 // globMatch implements simple glob pattern matching
-// Supports * (any characters) and ? (single character)
+// Supports * (any characters) and ? (single character); as in SIGMA, \*,
+// \? and \\ stand for a literal *, ? and \, other backslashes are literal
 func globMatch(pattern, text string) (bool, error) {
 	// Convert glob pattern to regex
 	regexPattern := globToRegex(pattern)
@@ -168,19 +202,27 @@ func globToRegex(glob string) string {
 	var result strings.Builder
 	result.WriteString("^")
 
-	for i, char := range glob {
-		switch char {
-		case '*':
+	escaped := false
+	for _, char := range glob {
+		switch {
+		case escaped:
+			escaped = false
+			if char != '*' && char != '?' && char != '\\' {
+				result.WriteString(`\\`)
+			}
+			result.WriteString(regexp.QuoteMeta(string(char)))
+		case char == '\\':
+			escaped = true
+		case char == '*':
 			result.WriteString(".*")
-		case '?':
+		case char == '?':
 			result.WriteString(".")
-		case '.', '+', '(', ')', '[', ']', '{', '}', '^', '$', '|', '\\':
-			result.WriteString("\\")
-			result.WriteRune(char)
 		default:
-			result.WriteRune(char)
+			result.WriteString(regexp.QuoteMeta(string(char)))
 		}
-		_ = i // Suppress unused variable warning
+	}
+	if escaped {
+		result.WriteString(`\\`)
 	}
 
 	result.WriteString("$")
@@ -269,11 +311,14 @@ func CreateNumericMatch() MatchFn {
 
 // CreateJsonExtractModifier creates a JSON field extraction modifier
 func CreateJsonExtractModifier(fieldPath string) ModifierFn {
-	return func(input string) (string, error) {
-		// Simple JSON field extraction - could be enhanced with proper JSON parsing
-		// For now, return the input as-is
-		return input, nil
+	modifier, err := CreateJSONExtractModifierFactory()([]string{fieldPath})
+	if err != nil {
+		// Return a modifier that always returns an error
+		return func(input string) (string, error) {
+			return "", err
+		}
 	}
+	return modifier
 }
 
 // CreateRegexExtractModifier creates a regex group extraction modifier
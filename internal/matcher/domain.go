@@ -0,0 +1,177 @@
+package matcher
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Domain and URL matchers and modifiers for DNS and proxy logs.
+//
+// Domains are compared case-insensitively and without trailing dots.
+// domain_endswith only matches on label boundaries, so "evil.com" matches
+// "evil.com" and "a.evil.com" but not "notevil.com". etld1_equals compares
+// registrable domains (eTLD+1) using the public suffix list, so
+// "a.b.example.co.uk" equals "example.co.uk".
+
+// NormalizeDomain lowercases a domain and strips the trailing root dot
+func NormalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// IsSubdomainOf reports whether domain equals parent or is one of its
+// subdomains. Both must be normalized.
+func IsSubdomainOf(domain, parent string) bool {
+	if domain == parent {
+		return true
+	}
+	return len(domain) > len(parent) &&
+		strings.HasSuffix(domain, parent) &&
+		domain[len(domain)-len(parent)-1] == '.'
+}
+
+// RegisteredDomain returns the eTLD+1 of a domain, e.g. "example.co.uk" for
+// "www.example.co.uk"
+func RegisteredDomain(domain string) (string, error) {
+	return publicsuffix.EffectiveTLDPlusOne(NormalizeDomain(domain))
+}
+
+// CreateDomainEndsWithMatch creates a matcher for domains equal to or under
+// any pattern domain. A leading "." or "*." in patterns is ignored.
+func CreateDomainEndsWithMatch() MatchFn {
+	return func(fieldValue string, values []string, modifiers []string) (bool, error) {
+		domain := NormalizeDomain(fieldValue)
+		for _, value := range values {
+			parent := NormalizeDomain(value)
+			parent = strings.TrimPrefix(strings.TrimPrefix(parent, "*"), ".")
+			if parent != "" && IsSubdomainOf(domain, parent) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// CreateETLDPlusOneMatch creates a matcher for domains sharing their
+// registrable domain with any pattern
+func CreateETLDPlusOneMatch() MatchFn {
+	return func(fieldValue string, values []string, modifiers []string) (bool, error) {
+		registered, err := RegisteredDomain(fieldValue)
+		if err != nil {
+			// Public suffixes and empty values have no registrable domain
+			return false, nil
+		}
+		for _, value := range values {
+			expected, err := RegisteredDomain(value)
+			if err != nil {
+				expected = NormalizeDomain(value)
+			}
+			if registered == expected {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// CreateURLParamMatch creates a matcher for URL query parameters. Patterns
+// are "name=value" (parameter has the value) or "name" (parameter present).
+func CreateURLParamMatch() MatchFn {
+	return func(fieldValue string, values []string, modifiers []string) (bool, error) {
+		u, err := parseURL(fieldValue)
+		if err != nil {
+			return false, err
+		}
+		query := u.Query()
+		for _, value := range values {
+			name, expected, hasValue := strings.Cut(value, "=")
+			actual, present := query[name]
+			if !present {
+				continue
+			}
+			if !hasValue {
+				return true, nil
+			}
+			for _, v := range actual {
+				if v == expected {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+}
+
+// parseURL parses a URL, accepting values without a scheme such as
+// "example.com/path"
+func parseURL(value string) (*url.URL, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	return u, nil
+}
+
+// CreateURLHostModifier creates a modifier extracting the host (without port)
+// from a URL or "host:port" value
+func CreateURLHostModifier() ModifierFn {
+	return func(input string) (string, error) {
+		u, err := parseURL(input)
+		if err != nil {
+			return input, err
+		}
+		return NormalizeDomain(u.Hostname()), nil
+	}
+}
+
+// CreateURLPathModifier creates a modifier extracting the decoded URL path
+func CreateURLPathModifier() ModifierFn {
+	return func(input string) (string, error) {
+		u, err := parseURL(input)
+		if err != nil {
+			return input, err
+		}
+		return u.Path, nil
+	}
+}
+
+// CreateURLQueryModifier creates a modifier extracting the raw URL query
+func CreateURLQueryModifier() ModifierFn {
+	return func(input string) (string, error) {
+		u, err := parseURL(input)
+		if err != nil {
+			return input, err
+		}
+		return u.RawQuery, nil
+	}
+}
+
+// CreateETLDPlusOneModifier creates a modifier reducing a domain to its
+// registrable domain
+func CreateETLDPlusOneModifier() ModifierFn {
+	return func(input string) (string, error) {
+		registered, err := RegisteredDomain(input)
+		if err != nil {
+			return NormalizeDomain(input), nil
+		}
+		return registered, nil
+	}
+}
+
+// RegisterDomainMatchers registers the domain and URL matchers and modifiers
+func RegisterDomainMatchers(registry *MatcherRegistry) {
+	registry.RegisterMatcher("domain_endswith", CreateDomainEndsWithMatch())
+	registry.RegisterMatcher("etld1_equals", CreateETLDPlusOneMatch())
+	registry.RegisterMatcher("url_param", CreateURLParamMatch())
+
+	registry.RegisterModifier("url_host", CreateURLHostModifier())
+	registry.RegisterModifier("url_path", CreateURLPathModifier())
+	registry.RegisterModifier("url_query", CreateURLQueryModifier())
+	registry.RegisterModifier("etld1", CreateETLDPlusOneModifier())
+}
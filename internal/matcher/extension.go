@@ -0,0 +1,150 @@
+package matcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ExtensionMatchFn is the match function ABI exposed by extensions: it
+// receives the (modified) field value and the rule patterns and reports
+// whether the value matched
+type ExtensionMatchFn func(value string, patterns []string) bool
+
+// Extension is a set of matchers and modifiers built outside the engine
+// binary, e.g. in a Go plugin or a WASM module
+type Extension struct {
+	// Name identifies the extension in errors (usually its file path)
+	Name string
+
+	// Match types, usable as field modifiers in rules
+	Matchers map[string]ExtensionMatchFn
+
+	// Value modifiers
+	Modifiers map[string]ModifierFn
+}
+
+// ExtensionLoader loads an extension from a file
+type ExtensionLoader func(path string) (*Extension, error)
+
+var (
+	extensionLoadersMu sync.RWMutex
+
+	// extensionLoaders maps file extensions to loaders. Go plugins are
+	// supported out of the box; hosts embedding a WASM runtime register a
+	// loader for ".wasm".
+	extensionLoaders = map[string]ExtensionLoader{
+		".so": LoadPluginExtension,
+	}
+)
+
+// RegisterExtensionLoader registers the loader used for files with the given
+// extension (e.g. ".wasm"), replacing any existing loader
+func RegisterExtensionLoader(fileExt string, loader ExtensionLoader) {
+	extensionLoadersMu.Lock()
+	defer extensionLoadersMu.Unlock()
+	extensionLoaders[strings.ToLower(fileExt)] = loader
+}
+
+// LoadExtension loads an extension with the loader registered for its file
+// extension
+func LoadExtension(path string) (*Extension, error) {
+	fileExt := strings.ToLower(filepath.Ext(path))
+
+	extensionLoadersMu.RLock()
+	loader, exists := extensionLoaders[fileExt]
+	extensionLoadersMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("no extension loader registered for %q files: %s", fileExt, path)
+	}
+	extension, err := loader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load extension %s: %w", path, err)
+	}
+	if extension.Name == "" {
+		extension.Name = path
+	}
+	return extension, nil
+}
+
+// LoadExtensions loads each extension file and registers its matchers and
+// modifiers. It stops at the first file that fails to load or register.
+func (r *MatcherRegistry) LoadExtensions(paths ...string) error {
+	for _, path := range paths {
+		extension, err := LoadExtension(path)
+		if err != nil {
+			return err
+		}
+		if err := r.RegisterExtension(extension); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterExtension registers an extension's matchers and modifiers.
+// Extensions cannot replace registered names, since that would silently
+// change the meaning of existing rules; nothing is registered on conflict.
+func (r *MatcherRegistry) RegisterExtension(extension *Extension) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, name := range sortedKeys(extension.Matchers) {
+		if _, exists := r.matchers[name]; exists {
+			return fmt.Errorf("extension %s: matcher %q is already registered", extension.Name, name)
+		}
+	}
+	for _, name := range sortedKeys(extension.Modifiers) {
+		if _, exists := r.modifiers[name]; exists {
+			return fmt.Errorf("extension %s: modifier %q is already registered", extension.Name, name)
+		}
+	}
+
+	for name, fn := range extension.Matchers {
+		r.matchers[name] = extensionMatcher(extension.Name, name, fn)
+	}
+	for name, fn := range extension.Modifiers {
+		r.modifiers[name] = extensionModifier(extension.Name, name, fn)
+	}
+	return nil
+}
+
+// extensionMatcher adapts an extension match function to MatchFn, turning
+// panics in extension code into match errors
+func extensionMatcher(extensionName, name string, fn ExtensionMatchFn) MatchFn {
+	return func(fieldValue string, values []string, modifiers []string) (matched bool, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				matched = false
+				err = fmt.Errorf("%w: extension %s matcher %s panicked: %v", ErrMatchFunctionFailed, extensionName, name, r)
+			}
+		}()
+		return fn(fieldValue, values), nil
+	}
+}
+
+// extensionModifier guards an extension modifier against panics
+func extensionModifier(extensionName, name string, fn ModifierFn) ModifierFn {
+	return func(input string) (output string, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				output = ""
+				err = fmt.Errorf("%w: extension %s modifier %s panicked: %v", ErrModifierFailed, extensionName, name, r)
+			}
+		}()
+		return fn(input)
+	}
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
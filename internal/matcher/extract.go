@@ -0,0 +1,359 @@
+package matcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Structured payload extraction modifiers.
+//
+//	json_extract:$.path   JSONPath subset: $, .key, ['key'], [index]
+//	xml_extract:/a/b      XPath subset: /step, //step (descendants), *,
+//	                      [n] (1-based position), [@attr='value'],
+//	                      trailing @attr or text()
+//
+// Strings are extracted verbatim; other JSON values are re-encoded as JSON.
+// XML elements yield their trimmed text content. A path that selects nothing,
+// or a payload that does not decode, yields no value, so the primitive does
+// not match, as when its field is missing.
+
+// ErrNoValue is returned, wrapped, by modifiers whose input holds no value
+// to match. Primitives treat it as a missing field rather than an error.
+var ErrNoValue = errors.New("no value")
+
+// ErrPathNotFound is returned when an extraction path selects nothing
+var ErrPathNotFound = fmt.Errorf("%w: path not found", ErrNoValue)
+
+// jsonPathStep is one step of a parsed JSON path
+type jsonPathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// CreateJSONExtractModifierFactory creates the json_extract factory
+func CreateJSONExtractModifierFactory() ModifierFactory {
+	return func(args []string) (ModifierFn, error) {
+		if len(args) != 1 || args[0] == "" {
+			return nil, fmt.Errorf("json_extract requires a path argument, e.g. json_extract:$.user.name")
+		}
+		steps, err := parseJSONPath(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(input string) (string, error) {
+			return extractJSON(input, steps)
+		}, nil
+	}
+}
+
+// CreateXMLExtractModifierFactory creates the xml_extract factory
+func CreateXMLExtractModifierFactory() ModifierFactory {
+	return func(args []string) (ModifierFn, error) {
+		if len(args) != 1 || args[0] == "" {
+			return nil, fmt.Errorf("xml_extract requires a path argument, e.g. xml_extract:/Event/System/EventID")
+		}
+		path, err := parseXPath(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(input string) (string, error) {
+			return extractXML(input, path)
+		}, nil
+	}
+}
+
+// parseJSONPath parses the supported JSONPath subset
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	rest := strings.TrimPrefix(path, "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		// Allow bare paths such as "user.name"
+		rest = "." + rest
+	}
+
+	var steps []jsonPathStep
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: empty key", path)
+			}
+			steps = append(steps, jsonPathStep{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: unclosed bracket", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, jsonPathStep{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: bad index %q", path, inner)
+			}
+			steps = append(steps, jsonPathStep{index: index, isIndex: true})
+		default:
+			return nil, fmt.Errorf("invalid JSON path %q", path)
+		}
+	}
+	return steps, nil
+}
+
+// extractJSON decodes a JSON document and returns the value at the path
+func extractJSON(input string, steps []jsonPathStep) (string, error) {
+	decoder := json.NewDecoder(strings.NewReader(input))
+	decoder.UseNumber()
+	var current interface{}
+	if err := decoder.Decode(&current); err != nil {
+		return "", fmt.Errorf("%w: invalid JSON: %v", ErrNoValue, err)
+	}
+
+	for _, step := range steps {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, exists := node[step.key]
+			if step.isIndex || !exists {
+				return "", ErrPathNotFound
+			}
+			current = value
+		case []interface{}:
+			if !step.isIndex || step.index >= len(node) {
+				return "", ErrPathNotFound
+			}
+			current = node[step.index]
+		default:
+			return "", ErrPathNotFound
+		}
+	}
+
+	switch value := current.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return string(encoded), nil
+	}
+}
+
+// xpathStep is one location step of a parsed XPath
+type xpathStep struct {
+	name       string // element local name or "*"
+	descendant bool   // preceded by "//"
+	position   int    // 1-based position predicate (0 = none)
+	attrName   string // [@attrName='attrValue'] predicate ("" = none)
+	attrValue  string
+}
+
+// xpath is a parsed XPath with its optional final attribute or text() selector
+type xpath struct {
+	steps     []xpathStep
+	attribute string
+}
+
+// parseXPath parses the supported XPath subset
+func parseXPath(path string) (*xpath, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid XML path %q: must be absolute", path)
+	}
+
+	result := &xpath{}
+	rest := path
+	for rest != "" {
+		descendant := strings.HasPrefix(rest, "//")
+		rest = strings.TrimLeft(rest, "/")
+		end := strings.IndexByte(rest, '/')
+		if end < 0 {
+			end = len(rest)
+		}
+		token := rest[:end]
+		rest = rest[end:]
+		if token == "" {
+			return nil, fmt.Errorf("invalid XML path %q: empty step", path)
+		}
+
+		if rest == "" && (strings.HasPrefix(token, "@") || token == "text()") {
+			result.attribute = strings.TrimPrefix(token, "@")
+			if token == "text()" {
+				result.attribute = ""
+			}
+			break
+		}
+
+		step := xpathStep{name: token, descendant: descendant}
+		if open := strings.IndexByte(token, '['); open >= 0 {
+			if !strings.HasSuffix(token, "]") {
+				return nil, fmt.Errorf("invalid XML path %q: unclosed predicate", path)
+			}
+			predicate := token[open+1 : len(token)-1]
+			step.name = token[:open]
+			if name, value, found := strings.Cut(strings.TrimPrefix(predicate, "@"), "="); found && strings.HasPrefix(predicate, "@") {
+				value = strings.TrimSpace(value)
+				if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] {
+					return nil, fmt.Errorf("invalid XML path %q: unquoted predicate value %q", path, value)
+				}
+				step.attrName = strings.TrimSpace(name)
+				step.attrValue = value[1 : len(value)-1]
+			} else {
+				position, err := strconv.Atoi(predicate)
+				if err != nil || position < 1 {
+					return nil, fmt.Errorf("invalid XML path %q: unsupported predicate %q", path, token[open:])
+				}
+				step.position = position
+			}
+		}
+		result.steps = append(result.steps, step)
+	}
+
+	if len(result.steps) == 0 {
+		return nil, fmt.Errorf("invalid XML path %q: no element steps", path)
+	}
+	return result, nil
+}
+
+// xmlNode is a parsed XML element
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+
+	// Text content, including the text of descendants, which is the text
+	// of the document between start and end
+	text       string
+	start, end int
+}
+
+// parseXMLTree parses a document into an element tree under a virtual root
+func parseXMLTree(input string) (*xmlNode, error) {
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	var nodes []*xmlNode
+	var text bytes.Buffer
+	decoder := xml.NewDecoder(strings.NewReader(input))
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid XML: %v", ErrNoValue, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr)), start: text.Len(), end: -1}
+			for _, attr := range t.Attr {
+				node.attrs[attr.Name.Local] = attr.Value
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+			nodes = append(nodes, node)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack[len(stack)-1].end = text.Len()
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			text.Write(t)
+		}
+	}
+
+	// Elements share the document's text, so deep documents do not copy
+	// it once per ancestor
+	document := text.String()
+	for _, node := range nodes {
+		if node.end < 0 {
+			node.end = len(document)
+		}
+		node.text = document[node.start:node.end]
+	}
+
+	if len(root.children) == 0 {
+		return nil, fmt.Errorf("%w: invalid XML: no root element", ErrNoValue)
+	}
+	return root, nil
+}
+
+// extractXML parses a document and returns the first node selected by the path
+func extractXML(input string, path *xpath) (string, error) {
+	root, err := parseXMLTree(input)
+	if err != nil {
+		return "", err
+	}
+
+	context := []*xmlNode{root}
+	for _, step := range path.steps {
+		var next []*xmlNode
+		for _, node := range context {
+			var candidates []*xmlNode
+			if step.descendant {
+				candidates = descendants(node, nil)
+			} else {
+				candidates = node.children
+			}
+
+			matched := 0
+			for _, candidate := range candidates {
+				if step.name != "*" && candidate.name != step.name {
+					continue
+				}
+				if step.attrName != "" && candidate.attrs[step.attrName] != step.attrValue {
+					continue
+				}
+				matched++
+				if step.position == 0 || matched == step.position {
+					next = append(next, candidate)
+				}
+			}
+		}
+		if len(next) == 0 {
+			return "", ErrPathNotFound
+		}
+		context = next
+	}
+
+	selected := context[0]
+	if path.attribute != "" {
+		value, exists := selected.attrs[path.attribute]
+		if !exists {
+			return "", ErrPathNotFound
+		}
+		return value, nil
+	}
+	return strings.TrimSpace(selected.text), nil
+}
+
+// descendants appends every element below node in document order
+func descendants(node *xmlNode, result []*xmlNode) []*xmlNode {
+	for _, child := range node.children {
+		result = append(result, child)
+		result = descendants(child, result)
+	}
+	return result
+}
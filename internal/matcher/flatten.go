@@ -0,0 +1,74 @@
+package matcher
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FlatEvent is an event flattened to dotted keys, so "a.b.0.c" is a single
+// map lookup instead of a walk through nested maps
+type FlatEvent map[string]interface{}
+
+// LookupField implements FieldAccessor
+func (e FlatEvent) LookupField(fieldPath string) (interface{}, bool) {
+	value, exists := e[fieldPath]
+	return value, exists
+}
+
+// flatEntry is a value waiting to be flattened under a path
+type flatEntry struct {
+	path  string
+	value interface{}
+}
+
+// FlattenEvent maps nested maps and arrays to dotted keys, e.g.
+// {"a": {"b": [{"c": 1}]}} to "a", "a.b", "a.b.0" and "a.b.0.c". Container
+// values are kept under their own path, so every path the nested extractor
+// resolves has the same value. Keys that contain dots are kept as is; when
+// one collides with a nested path, the nested value wins.
+func FlattenEvent(event map[string]interface{}) FlatEvent {
+	flat := make(FlatEvent, len(event)*2)
+	var dotted []flatEntry
+
+	var add func(path string, value interface{}, keepExisting bool)
+	add = func(path string, value interface{}, keepExisting bool) {
+		if keepExisting {
+			if _, exists := flat[path]; exists {
+				return
+			}
+		}
+		flat[path] = value
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				childPath := path + "." + key
+				if !keepExisting && strings.Contains(key, ".") {
+					dotted = append(dotted, flatEntry{path: childPath, value: child})
+					continue
+				}
+				add(childPath, child, keepExisting)
+			}
+		case []interface{}:
+			for i, child := range v {
+				add(path+"."+strconv.Itoa(i), child, keepExisting)
+			}
+		}
+	}
+
+	for key, value := range event {
+		if strings.Contains(key, ".") {
+			dotted = append(dotted, flatEntry{path: key, value: value})
+			continue
+		}
+		add(key, value, false)
+	}
+
+	// Literal dotted keys fill the remaining paths in a stable order
+	sort.Slice(dotted, func(i, j int) bool { return dotted[i].path < dotted[j].path })
+	for _, entry := range dotted {
+		add(entry.path, entry.value, true)
+	}
+	return flat
+}
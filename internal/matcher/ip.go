@@ -0,0 +1,261 @@
+package matcher
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// IP address classification and range matchers.
+//
+// The classification matchers (ip_private, ip_public, ip_loopback) take a
+// boolean pattern: `SourceIp|ip_private: true` matches private addresses,
+// `false` matches every other valid address. ip_in_ranges takes a list of
+// CIDRs, single addresses or "first-last" ranges, merged into sorted
+// disjoint intervals when the primitive is compiled and searched in
+// O(log n). Field values that are not addresses do not match.
+
+// nonPublicPrefixes are special-purpose ranges that are neither private nor
+// loopback but are not publicly routable either
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// ipInterval is an inclusive address range
+type ipInterval struct {
+	first netip.Addr
+	last  netip.Addr
+}
+
+// IPRangeSet is a set of address ranges stored as sorted, disjoint intervals
+type IPRangeSet struct {
+	intervals []ipInterval
+}
+
+// NewIPRangeSet compiles CIDRs, single addresses and "first-last" ranges
+// into a range set
+func NewIPRangeSet(ranges []string) (*IPRangeSet, error) {
+	intervals := make([]ipInterval, 0, len(ranges))
+	for _, r := range ranges {
+		interval, err := parseIPInterval(strings.TrimSpace(r))
+		if err != nil {
+			return nil, err
+		}
+		intervals = append(intervals, interval)
+	}
+
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].first.Less(intervals[j].first)
+	})
+
+	// Merge overlapping and adjacent intervals of the same address family
+	merged := intervals[:0]
+	for _, interval := range intervals {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if last.first.Is4() == interval.first.Is4() &&
+				(interval.first.Compare(last.last) <= 0 || interval.first == last.last.Next()) {
+				if interval.last.Compare(last.last) > 0 {
+					last.last = interval.last
+				}
+				continue
+			}
+		}
+		merged = append(merged, interval)
+	}
+
+	return &IPRangeSet{intervals: merged}, nil
+}
+
+// Contains reports whether the address falls in any range of the set
+func (s *IPRangeSet) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	// First interval starting after addr; the candidate is the one before it
+	i := sort.Search(len(s.intervals), func(i int) bool {
+		return addr.Less(s.intervals[i].first)
+	})
+	return i > 0 && s.intervals[i-1].contains(addr)
+}
+
+// contains reports whether an unmapped address falls in the interval
+func (interval ipInterval) contains(addr netip.Addr) bool {
+	return interval.first.Is4() == addr.Is4() &&
+		addr.Compare(interval.first) >= 0 && addr.Compare(interval.last) <= 0
+}
+
+// Len returns the number of disjoint intervals in the set
+func (s *IPRangeSet) Len() int {
+	return len(s.intervals)
+}
+
+// parseIPInterval parses a CIDR, single address or "first-last" range
+func parseIPInterval(r string) (ipInterval, error) {
+	if strings.Contains(r, "/") {
+		prefix, err := netip.ParsePrefix(r)
+		if err != nil {
+			return ipInterval{}, sigmaerrors.NewInvalidCIDR(r)
+		}
+		prefix = prefix.Masked()
+		return ipInterval{first: prefix.Addr().Unmap(), last: lastAddr(prefix).Unmap()}, nil
+	}
+
+	if firstStr, lastStr, found := strings.Cut(r, "-"); found {
+		first, err1 := netip.ParseAddr(strings.TrimSpace(firstStr))
+		last, err2 := netip.ParseAddr(strings.TrimSpace(lastStr))
+		if err1 != nil || err2 != nil {
+			return ipInterval{}, sigmaerrors.NewInvalidRange(r)
+		}
+		first, last = first.Unmap(), last.Unmap()
+		if first.Is4() != last.Is4() || last.Less(first) {
+			return ipInterval{}, sigmaerrors.NewInvalidRange(r)
+		}
+		return ipInterval{first: first, last: last}, nil
+	}
+
+	addr, err := netip.ParseAddr(r)
+	if err != nil {
+		return ipInterval{}, sigmaerrors.NewInvalidIPAddress(r)
+	}
+	addr = addr.Unmap()
+	return ipInterval{first: addr, last: addr}, nil
+}
+
+// lastAddr returns the highest address of a masked prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(bytes)*8; bit++ {
+		bytes[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
+
+// parseFieldAddr parses a field value as an IP address, ignoring IPv6 zones
+func parseFieldAddr(fieldValue string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(fieldValue))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.WithZone("").Unmap(), true
+}
+
+// IsPrivateIP reports whether the address is in a private range (RFC 1918,
+// RFC 4193) or link-local
+func IsPrivateIP(addr netip.Addr) bool {
+	return addr.IsPrivate() || addr.IsLinkLocalUnicast()
+}
+
+// IsPublicIP reports whether the address is a publicly routable unicast address
+func IsPublicIP(addr netip.Addr) bool {
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// createIPClassMatch matches addresses whose classification equals the
+// boolean pattern
+func createIPClassMatch(classify func(netip.Addr) bool) MatchFn {
+	return func(fieldValue string, values []string, modifiers []string) (bool, error) {
+		addr, ok := parseFieldAddr(fieldValue)
+		if !ok {
+			return false, nil
+		}
+		class := classify(addr)
+		for _, value := range values {
+			expected, err := strconv.ParseBool(value)
+			if err != nil {
+				return false, fmt.Errorf("invalid boolean pattern: %s", value)
+			}
+			if class == expected {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// CreatePrivateIPMatch creates a matcher for private addresses
+func CreatePrivateIPMatch() MatchFn {
+	return createIPClassMatch(IsPrivateIP)
+}
+
+// CreatePublicIPMatch creates a matcher for publicly routable addresses
+func CreatePublicIPMatch() MatchFn {
+	return createIPClassMatch(IsPublicIP)
+}
+
+// CreateLoopbackIPMatch creates a matcher for loopback addresses
+func CreateLoopbackIPMatch() MatchFn {
+	return createIPClassMatch(netip.Addr.IsLoopback)
+}
+
+// CreateIPInRangesMatchFactory creates the ip_in_ranges factory, which
+// compiles a primitive's ranges into a range set once
+func CreateIPInRangesMatchFactory() MatchFactory {
+	return func(values []string, modifiers []string) (MatchFn, error) {
+		set, err := NewIPRangeSet(values)
+		if err != nil {
+			return nil, err
+		}
+		intervals := make(map[string]ipInterval, len(values))
+		for _, value := range values {
+			intervals[value], _ = parseIPInterval(strings.TrimSpace(value))
+		}
+
+		return func(fieldValue string, group []string, modifiers []string) (bool, error) {
+			addr, ok := parseFieldAddr(fieldValue)
+			if !ok {
+				return false, nil
+			}
+			if len(group) == len(values) {
+				return set.Contains(addr), nil
+			}
+			// Values matched in groups under a deadline
+			for _, value := range group {
+				interval, exists := intervals[value]
+				if !exists {
+					if interval, err = parseIPInterval(strings.TrimSpace(value)); err != nil {
+						return false, err
+					}
+				}
+				if interval.contains(addr) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}, nil
+	}
+}
+
+// CreateIPInRangesMatch creates a matcher for addresses in any of the
+// pattern ranges, compiling the ranges on every call. Compiled primitives
+// use CreateIPInRangesMatchFactory instead.
+func CreateIPInRangesMatch() MatchFn {
+	return matchFnFromFactory(CreateIPInRangesMatchFactory())
+}
+
+// RegisterIPMatchers registers the IP classification and range matchers
+func RegisterIPMatchers(registry *MatcherRegistry) {
+	registry.RegisterMatcher("ip_private", CreatePrivateIPMatch())
+	registry.RegisterMatcher("ip_public", CreatePublicIPMatch())
+	registry.RegisterMatcher("ip_loopback", CreateLoopbackIPMatch())
+	registry.RegisterMatchFactory("ip_in_ranges", CreateIPInRangesMatchFactory())
+}
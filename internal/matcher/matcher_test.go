@@ -1,9 +1,19 @@
 package matcher
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/clock"
 	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func TestMatcherRegistry(t *testing.T) {
@@ -130,6 +140,99 @@ func TestEventContext(t *testing.T) {
 	}
 }
 
+func TestStructEventExtraction(t *testing.T) {
+	event, err := structpb.NewStruct(map[string]interface{}{
+		"EventID": 4624,
+		"process": map[string]interface{}{
+			"name":     "cmd.exe",
+			"args":     []interface{}{"/c", "whoami"},
+			"elevated": true,
+		},
+		"parent": nil,
+	})
+	if err != nil {
+		t.Fatalf("Failed to build event: %v", err)
+	}
+
+	for _, ctx := range []*EventContext{NewEventContext(event), NewEventContext(NewStructEvent(event))} {
+		tests := map[string]string{
+			"EventID":          "4624",
+			"process.name":     "cmd.exe",
+			"process.args.1":   "whoami",
+			"process.elevated": "true",
+		}
+		for path, expected := range tests {
+			value, exists, err := ctx.GetFieldAsString(path)
+			if err != nil || !exists || value != expected {
+				t.Errorf("%s: expected %q, got %q (exists=%v, err=%v)", path, expected, value, exists, err)
+			}
+		}
+		for _, path := range []string{"missing", "process.args.2", "process.name.x", "parent"} {
+			if ctx.HasField(path) {
+				t.Errorf("%s: expected field to be missing", path)
+			}
+		}
+	}
+}
+
+func TestProtoEventExtraction(t *testing.T) {
+	event := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("events.proto"),
+		Package: proto.String("sigma"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("ProcessCreation")},
+		},
+		Options: &descriptorpb.FileOptions{
+			GoPackage:   proto.String("example.com/events"),
+			OptimizeFor: descriptorpb.FileOptions_LITE_RUNTIME.Enum(),
+		},
+	}
+
+	ctx := NewEventContext(event)
+	tests := map[string]string{
+		"name":                   "events.proto",
+		"message_type.0.name":    "ProcessCreation",
+		"messageType.0.name":     "ProcessCreation",
+		"options.go_package":     "example.com/events",
+		"options.optimize_for":   "LITE_RUNTIME",
+		"options.goPackage":      "example.com/events",
+		"message_type.0.field":   "[]",
+		"options.java_package.x": "",
+	}
+	for path, expected := range tests {
+		value, exists, err := ctx.GetFieldAsString(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+		if expected == "" {
+			if exists {
+				t.Errorf("%s: expected field to be missing, got %q", path, value)
+			}
+			continue
+		}
+		if !exists || value != expected {
+			t.Errorf("%s: expected %q, got %q (exists=%v)", path, expected, value, exists)
+		}
+	}
+
+	// Unset optional fields are not found
+	if ctx.HasField("syntax") {
+		t.Error("Expected unset optional field to be missing")
+	}
+
+	primitive, err := NewMatcherBuilder().WithDefaults().CompilePrimitive(ir.Primitive{
+		Field:     "options.go_package",
+		MatchType: "endswith",
+		Values:    []string{"/events"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to compile primitive: %v", err)
+	}
+	if matched, err := primitive.Matches(NewEventContext(NewProtoEvent(event))); err != nil || !matched {
+		t.Errorf("Expected protobuf event to match, got %v (err=%v)", matched, err)
+	}
+}
+
 func TestCompiledPrimitive(t *testing.T) {
 	// Create test match function
 	matchFn := func(fieldValue string, values []string, modifiers []string) (bool, error) {
@@ -286,6 +389,77 @@ func TestDefaultModifiers(t *testing.T) {
 	}
 }
 
+func TestHashModifiers(t *testing.T) {
+	tests := []struct {
+		name     string
+		modifier ModifierFn
+		expected string
+	}{
+		{"md5", CreateMD5HashModifier(), "900150983cd24fb0d6963f7d28e17f72"},
+		{"sha1", CreateSHA1HashModifier(), "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"sha256", CreateSHA256HashModifier(), "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+
+	for _, tt := range tests {
+		result, err := tt.modifier("abc")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if result != tt.expected {
+			t.Errorf("%s: expected '%s', got '%s'", tt.name, tt.expected, result)
+		}
+	}
+}
+
+func TestUnicodeModifiers(t *testing.T) {
+	tests := []struct {
+		name     string
+		modifier ModifierFn
+		input    string
+		expected string
+	}{
+		{"nfkc fullwidth", CreateNFKCModifier(), "ｃｍｄ.ｅｘｅ", "cmd.exe"},
+		{"nfkc ligature", CreateNFKCModifier(), "ﬁle", "file"},
+		{"nfkc ascii", CreateNFKCModifier(), "cmd.exe", "cmd.exe"},
+		{"strip diacritics", CreateStripDiacriticsModifier(), "pówërshéll.exe", "powershell.exe"},
+		{"strip combining mark", CreateStripDiacriticsModifier(), "e\u0301vil", "evil"},
+		{"homoglyph cyrillic", CreateHomoglyphModifier(), "рowershеll.exe", "powershell.exe"},
+		{"homoglyph greek", CreateHomoglyphModifier(), "mιcrοsοft.com", "microsoft.com"},
+		{"homoglyph uppercase", CreateHomoglyphModifier(), "СМD.ЕХЕ", "CMD.EXE"},
+		{"homoglyph fullwidth", CreateHomoglyphModifier(), "ｒｕｎｄｌｌ３２", "rundll32"},
+	}
+
+	for _, tt := range tests {
+		result, err := tt.modifier(tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if result != tt.expected {
+			t.Errorf("%s: expected '%s', got '%s'", tt.name, tt.expected, result)
+		}
+	}
+
+	builder := NewComprehensiveMatcherBuilder()
+	primitive := ir.Primitive{
+		Field:     "Image",
+		MatchType: "endswith",
+		Values:    []string{"\\powershell.exe"},
+		Modifiers: []string{"homoglyph", "strip_diacritics"},
+	}
+	compiled, err := builder.CompilePrimitive(primitive)
+	if err != nil {
+		t.Fatalf("Failed to compile primitive: %v", err)
+	}
+	ctx := NewEventContext(map[string]interface{}{"Image": "C:\\Windows\\рówershеll.exe"})
+	matched, err := compiled.Matches(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !matched {
+		t.Error("Expected obfuscated image name to match after normalization")
+	}
+}
+
 func TestMatcherBuilder(t *testing.T) {
 	builder := NewMatcherBuilder().WithDefaults()
 
@@ -402,6 +576,55 @@ func TestMatcherEvaluator(t *testing.T) {
 	}
 }
 
+func TestMatcherEvaluatorPrimitiveIDs(t *testing.T) {
+	ruleset := ir.NewCompiledRuleset()
+	eventID := ruleset.AddPrimitive(ir.Primitive{Field: "EventID", MatchType: "equals", Values: []string{"4624"}})
+	process := ruleset.AddPrimitive(ir.Primitive{Field: "ProcessName", MatchType: "contains", Values: []string{"explorer"}})
+
+	evaluator, err := NewMatcherBuilder().WithDefaults().BuildEvaluatorFromRuleset(ruleset)
+	if err != nil {
+		t.Fatalf("Failed to build evaluator: %v", err)
+	}
+
+	event := map[string]interface{}{"EventID": "4624", "ProcessName": "notepad.exe"}
+	results, err := evaluator.EvaluateAll(event)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(results) != 2 || !results[eventID] || results[process] {
+		t.Errorf("Expected only primitive %d to match, got %v", eventID, results)
+	}
+
+	detailed, err := evaluator.EvaluateAllWithResults(event)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result := detailed[eventID]; result == nil || !result.Matched || result.MatchedPattern != "4624" {
+		t.Errorf("Expected detailed match for primitive %d, got %+v", eventID, result)
+	}
+
+	// Explicit IDs follow the primitives, not their positions
+	compiled := evaluator.GetPrimitives()
+	reordered, err := NewMatcherEvaluatorWithIDs([]*CompiledPrimitive{compiled[1], compiled[0]}, []ir.PrimitiveID{process, eventID})
+	if err != nil {
+		t.Fatalf("Failed to build evaluator: %v", err)
+	}
+	if ids := reordered.PrimitiveIDs(); ids[0] != process || ids[1] != eventID {
+		t.Errorf("Expected IDs in evaluation order, got %v", ids)
+	}
+	results, _ = reordered.EvaluateAll(event)
+	if !results[eventID] || results[process] {
+		t.Errorf("Expected results keyed by primitive ID, got %v", results)
+	}
+
+	if _, err := NewMatcherEvaluatorWithIDs(compiled, []ir.PrimitiveID{0}); err == nil {
+		t.Error("Expected an error for mismatched ID count")
+	}
+	if _, err := NewMatcherEvaluatorWithIDs(compiled, []ir.PrimitiveID{1, 1}); err == nil {
+		t.Error("Expected an error for duplicate IDs")
+	}
+}
+
 func TestFromPrimitive(t *testing.T) {
 	// Register defaults first
 	RegisterDefaults()
@@ -460,6 +683,29 @@ func TestGlobMatch(t *testing.T) {
 	if matched {
 		t.Error("Expected glob match to fail")
 	}
+
+	// Test escaped wildcards and backslashes
+	for _, tt := range []struct {
+		value    string
+		pattern  string
+		expected bool
+	}{
+		{`C:\x\cmd.exe`, `*\\cmd.exe`, true},
+		{`C:\x\cmd.exe`, `C:\x\\*`, true},
+		{`C:\x\cmd.exe`, `C:\x\*`, false},
+		{"report*.txt", `report\*.txt`, true},
+		{"report-1.txt", `report\*.txt`, false},
+		{"what?", `what\?`, true},
+		{"whats", `what\?`, false},
+	} {
+		matched, err := globMatcher(tt.value, []string{tt.pattern}, nil)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if matched != tt.expected {
+			t.Errorf("Expected %s to match %s: %v, got %v", tt.pattern, tt.value, tt.expected, matched)
+		}
+	}
 }
 
 func TestRegexMatch(t *testing.T) {
@@ -526,3 +772,538 @@ func TestCompiledPrimitiveStats(t *testing.T) {
 		t.Errorf("Expected 2 unique field paths, got %d", stats.UniqueFieldPaths)
 	}
 }
+
+func TestMatchesWithResultReportsPattern(t *testing.T) {
+	primitive := NewCompiledPrimitive(
+		[]string{"Image"},
+		CreateEndsWithMatch(),
+		nil,
+		[]string{"\\cmd.exe", "\\powershell.exe"},
+		nil,
+	)
+
+	ctx := NewEventContext(map[string]interface{}{
+		"Image": "C:\\Windows\\System32\\WindowsPowerShell\\v1.0\\powershell.exe",
+	})
+
+	result := primitive.MatchesWithResult(ctx)
+	if !result.Matched {
+		t.Fatal("Expected primitive to match")
+	}
+	if result.PatternIndex != 1 {
+		t.Errorf("Expected pattern index 1, got %d", result.PatternIndex)
+	}
+	if result.MatchedPattern != "\\powershell.exe" {
+		t.Errorf("Expected matched pattern '\\powershell.exe', got '%s'", result.MatchedPattern)
+	}
+
+	miss := primitive.MatchesWithResult(NewEventContext(map[string]interface{}{"Image": "notepad.exe"}))
+	if miss.Matched || miss.PatternIndex != -1 || miss.MatchedPattern != "" {
+		t.Errorf("Expected no pattern for non-matching event, got %+v", miss)
+	}
+}
+
+func TestEventContextCaseInsensitiveFields(t *testing.T) {
+	event := map[string]interface{}{
+		"commandline": "whoami",
+		"Process":     map[string]interface{}{"parent": map[string]interface{}{"NAME": "cmd.exe"}},
+		"user":        "alice",
+		"USER":        "bob",
+	}
+
+	ctx := NewEventContext(event)
+	if ctx.HasField("CommandLine") {
+		t.Fatal("Expected fields to be case-sensitive by default")
+	}
+
+	ctx = NewEventContext(event)
+	ctx.SetCaseInsensitiveFields(true)
+	tests := map[string]string{
+		"CommandLine":         "whoami",
+		"process.Parent.name": "cmd.exe",
+		// Exact names win; ambiguous folds take the lexically smallest
+		"USER": "bob",
+		"User": "bob",
+	}
+	for field, expected := range tests {
+		if value, exists, err := ctx.GetFieldAsString(field); err != nil || !exists || value != expected {
+			t.Errorf("Expected %s to resolve to %q, got %q, %v, %v", field, expected, value, exists, err)
+		}
+	}
+	if ctx.HasField("Image") {
+		t.Error("Expected absent fields to stay absent")
+	}
+}
+
+func TestEventContextCachesTransformedValues(t *testing.T) {
+	calls := 0
+	lower := func(value string) (string, error) {
+		calls++
+		return strings.ToLower(value), nil
+	}
+
+	contains := NewCompiledPrimitive([]string{"CommandLine"}, CreateContainsMatch(), []ModifierFn{lower}, []string{"iex"}, []string{"lower"})
+	endsWith := NewCompiledPrimitive([]string{"CommandLine"}, CreateEndsWithMatch(), []ModifierFn{lower}, []string{"x"}, []string{"lower"})
+
+	ctx := NewEventContext(map[string]interface{}{"CommandLine": "powershell IEX"})
+	for _, primitive := range []*CompiledPrimitive{contains, endsWith} {
+		matched, err := primitive.Matches(ctx)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !matched {
+			t.Errorf("Expected %s to match", primitive)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected modifier chain to run once per event, ran %d times", calls)
+	}
+
+	// A different modifier chain on the same field is transformed separately
+	value, exists, err := ctx.GetTransformedField("CommandLine", "upper", []ModifierFn{func(v string) (string, error) {
+		return strings.ToUpper(v), nil
+	}})
+	if err != nil || !exists || value != "POWERSHELL IEX" {
+		t.Errorf("Unexpected transformed value %q (exists=%v, err=%v)", value, exists, err)
+	}
+
+	// Missing fields are cached as absent
+	if _, exists, _ := ctx.GetTransformedField("Missing", "lower", []ModifierFn{lower}); exists {
+		t.Error("Expected missing field not to exist")
+	}
+	if calls != 1 {
+		t.Errorf("Expected modifier not to run for missing field, ran %d times", calls)
+	}
+
+	ctx.ClearCache()
+	if _, err := contains.Matches(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected modifier to re-run after ClearCache, ran %d times", calls)
+	}
+}
+
+func TestValueStringMatchesFmt(t *testing.T) {
+	values := []interface{}{
+		"powershell", true, 4624, int8(-8), int16(16), int32(-32), int64(1) << 40,
+		uint(7), uint8(8), uint16(16), uint32(32), uint64(1) << 63,
+		0.0, 4624.0, 0.1, 1e21, 1.5e-7, float32(3.14), json.Number("4624"),
+		nil, []interface{}{"a", 1}, map[string]interface{}{"a": 1},
+	}
+	for _, value := range values {
+		if got, expected := ValueString(value), fmt.Sprintf("%v", value); got != expected {
+			t.Errorf("ValueString(%#v) = %q, expected %q", value, got, expected)
+		}
+	}
+
+	event := map[string]interface{}{"EventID": 4624, "Ports": []interface{}{80, "443"}}
+	allocs := testing.AllocsPerRun(100, func() {
+		ValueString(event["EventID"])
+	})
+	if allocs > 1 {
+		t.Errorf("Expected at most one allocation converting an int, got %v", allocs)
+	}
+
+	ctx := NewEventContext(event)
+	ports, exists, err := ctx.GetFieldAsStringSlice("Ports")
+	if err != nil || !exists || len(ports) != 2 || ports[0] != "80" || ports[1] != "443" {
+		t.Fatalf("Unexpected list value %v (exists=%v, err=%v)", ports, exists, err)
+	}
+	if again, _, _ := ctx.GetFieldAsStringSlice("Ports"); &again[0] != &ports[0] {
+		t.Error("Expected the converted list to be cached")
+	}
+}
+
+func TestCompiledPrimitiveNumericEquality(t *testing.T) {
+	RegisterDefaults()
+
+	primitive, err := FromPrimitive(ir.Primitive{
+		Field:     "EventID",
+		MatchType: "equals",
+		Values:    []string{"admin", "4624.0", "1000000"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to compile primitive: %v", err)
+	}
+
+	tests := []struct {
+		value    interface{}
+		expected bool
+	}{
+		{4624, true},
+		{int64(4624), true},
+		{uint16(4624), true},
+		{4624.0, true},
+		{json.Number("4624"), true},
+		{1e6, true}, // formatted as "1e+06"
+		{4625, false},
+		{"4624", false}, // strings keep string semantics
+		{"admin", true},
+		{json.Number("4625"), false},
+	}
+	for _, test := range tests {
+		ctx := NewEventContext(map[string]interface{}{"EventID": test.value})
+		matched, err := primitive.Matches(ctx)
+		if err != nil {
+			t.Fatalf("Match failed for %#v: %v", test.value, err)
+		}
+		if matched != test.expected {
+			t.Errorf("Expected %#v to match %v, got %v", test.value, test.expected, matched)
+		}
+	}
+
+	result := primitive.MatchesWithResult(NewEventContext(map[string]interface{}{"EventID": 1e6}))
+	if !result.Matched || result.PatternIndex != 2 {
+		t.Errorf("Expected the numeric value to match pattern 2, got %+v", result)
+	}
+	if clone := primitive.Clone(); len(clone.numericValues) != 2 {
+		t.Errorf("Expected the clone to keep 2 numeric values, got %d", len(clone.numericValues))
+	}
+
+	// Modifiers transform the string form, so they keep string matching
+	lowered, err := FromPrimitive(ir.Primitive{
+		Field:     "EventID",
+		MatchType: "equals",
+		Values:    []string{"4624.0"},
+		Modifiers: []string{"lowercase"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to compile primitive: %v", err)
+	}
+	if matched, _ := lowered.Matches(NewEventContext(map[string]interface{}{"EventID": 4624})); matched {
+		t.Error("Expected a primitive with modifiers to compare strings")
+	}
+
+	event := map[string]interface{}{"EventID": 4624.0}
+	allocs := testing.AllocsPerRun(100, func() {
+		ctx := NewEventContext(event)
+		primitive.Matches(ctx)
+	})
+	plain := testing.AllocsPerRun(100, func() {
+		NewEventContext(event).GetField("EventID")
+	})
+	if allocs > plain {
+		t.Errorf("Expected numeric matching not to allocate beyond field extraction, got %v > %v", allocs, plain)
+	}
+}
+
+func TestMatchErrorsAreTyped(t *testing.T) {
+	RegisterDefaults()
+
+	primitive, err := FromPrimitive(ir.Primitive{Field: "SourceIp", MatchType: "cidr", Values: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatalf("Failed to compile primitive: %v", err)
+	}
+	_, err = primitive.Matches(NewEventContext(map[string]interface{}{"SourceIp": "not-an-ip"}))
+	var sigmaErr *sigmaerrors.SigmaError
+	if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeInvalidIPAddress || sigmaErr.Field != "SourceIp" {
+		t.Errorf("Expected an invalid IP address error on SourceIp, got %#v", sigmaErr)
+	}
+
+	_, err = FromPrimitive(ir.Primitive{Field: "SourceIp", MatchType: "nope", Values: []string{"x"}})
+	if !errors.Is(err, ErrUnsupportedMatchType) || !sigmaerrors.IsType(err, sigmaerrors.ErrorTypeUnsupportedMatchType) {
+		t.Errorf("Expected an unsupported match type error, got %v", err)
+	}
+}
+
+func TestUnknownModifierModes(t *testing.T) {
+	RegisterDefaults()
+
+	primitive := ir.Primitive{
+		Field:     "CommandLine",
+		MatchType: "contains",
+		Values:    []string{"iex"},
+		Modifiers: []string{"lowercase", "windash"},
+	}
+
+	_, err := FromPrimitive(primitive)
+	var sigmaErr *sigmaerrors.SigmaError
+	if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeModifier {
+		t.Fatalf("Expected modifier error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "windash") || !strings.Contains(err.Error(), "CommandLine") {
+		t.Errorf("Expected error to name the modifier and field, got %v", err)
+	}
+
+	compiled, err := FromPrimitiveWithMode(primitive, UnknownModifierSkip)
+	if err != nil {
+		t.Fatalf("Expected lenient mode to skip unknown modifier, got %v", err)
+	}
+	if len(compiled.ModifierChain) != 1 {
+		t.Errorf("Expected only the known modifier in the chain, got %d", len(compiled.ModifierChain))
+	}
+
+	builder := NewComprehensiveMatcherBuilder()
+	if _, err := builder.CompilePrimitive(primitive); err == nil {
+		t.Error("Expected strict builder to reject unknown modifier")
+	}
+	if _, err := builder.WithUnknownModifierMode(UnknownModifierSkip).CompilePrimitive(primitive); err != nil {
+		t.Errorf("Expected lenient builder to compile, got %v", err)
+	}
+
+	// Match function parameters are not registry modifiers
+	fuzzy := ir.Primitive{Field: "Image", MatchType: "fuzzy", Values: []string{"cmd.exe"}, Modifiers: []string{"fuzzy:0.9"}}
+	if _, err := FromPrimitive(fuzzy); err != nil {
+		t.Errorf("Expected parameter modifier to be accepted, got %v", err)
+	}
+}
+
+func TestRegisterExtension(t *testing.T) {
+	registry := NewComprehensiveMatcherBuilder().GetRegistry()
+	extension := &Extension{
+		Name: "test",
+		Matchers: map[string]ExtensionMatchFn{
+			"palindrome": func(value string, patterns []string) bool {
+				for i := 0; i < len(value)/2; i++ {
+					if value[i] != value[len(value)-1-i] {
+						return false
+					}
+				}
+				return true
+			},
+			"boom": func(value string, patterns []string) bool { panic("boom") },
+		},
+	}
+	if err := registry.RegisterExtension(extension); err != nil {
+		t.Fatalf("Failed to register extension: %v", err)
+	}
+
+	palindrome, _ := registry.GetMatcher("palindrome")
+	if matched, err := palindrome("level", nil, nil); err != nil || !matched {
+		t.Errorf("Expected palindrome match, got %v, %v", matched, err)
+	}
+	boom, _ := registry.GetMatcher("boom")
+	if _, err := boom("x", nil, nil); !errors.Is(err, ErrMatchFunctionFailed) {
+		t.Errorf("Expected panic to surface as match error, got %v", err)
+	}
+
+	conflict := &Extension{Name: "conflict", Matchers: map[string]ExtensionMatchFn{
+		"contains": func(value string, patterns []string) bool { return true },
+	}}
+	if err := registry.RegisterExtension(conflict); err == nil {
+		t.Error("Expected extension overriding a built-in matcher to be rejected")
+	}
+}
+
+func TestLoadExtensionWithRegisteredLoader(t *testing.T) {
+	RegisterExtensionLoader(".testext", func(path string) (*Extension, error) {
+		return &Extension{Modifiers: map[string]ModifierFn{
+			"reverse": func(input string) (string, error) {
+				runes := []rune(input)
+				for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+					runes[i], runes[j] = runes[j], runes[i]
+				}
+				return string(runes), nil
+			},
+		}}, nil
+	})
+
+	registry := NewMatcherRegistry()
+	if err := registry.LoadExtensions("custom.testext"); err != nil {
+		t.Fatalf("Failed to load extension: %v", err)
+	}
+	reverse, exists := registry.GetModifier("reverse")
+	if !exists {
+		t.Fatal("Expected extension modifier to be registered")
+	}
+	if output, _ := reverse("abc"); output != "cba" {
+		t.Errorf("Expected 'cba', got %q", output)
+	}
+
+	if err := registry.LoadExtensions("custom.unknown"); err == nil {
+		t.Error("Expected error for a file type without a loader")
+	}
+}
+
+func TestJSONExtractModifier(t *testing.T) {
+	registry := NewComprehensiveMatcherBuilder().GetRegistry()
+	payload := `{"user": {"name": "alice", "roles": ["admin", "dev"], "id": 42, "active": true}, "odd key": "x"}`
+
+	tests := map[string]string{
+		"json_extract:$.user.name":     "alice",
+		"json_extract:$.user.roles[1]": "dev",
+		"json_extract:$.user.id":       "42",
+		"json_extract:$.user.active":   "true",
+		"json_extract:$['odd key']":    "x",
+		"json_extract:user.roles":      `["admin","dev"]`,
+	}
+	for spec, expected := range tests {
+		modifier, exists, err := registry.ResolveModifier(spec)
+		if !exists || err != nil {
+			t.Fatalf("%s: failed to resolve modifier: %v", spec, err)
+		}
+		result, err := modifier(payload)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", spec, err)
+		}
+		if result != expected {
+			t.Errorf("%s: expected '%s', got '%s'", spec, expected, result)
+		}
+	}
+
+	modifier, _, _ := registry.ResolveModifier("json_extract:$.user.email")
+	if _, err := modifier(payload); !errors.Is(err, ErrPathNotFound) {
+		t.Errorf("Expected path not found error, got %v", err)
+	}
+	if _, _, err := registry.ResolveModifier("json_extract"); err == nil {
+		t.Error("Expected error for json_extract without a path")
+	}
+	if _, _, err := registry.ResolveModifier("json_extract:$.a[x]"); err == nil {
+		t.Error("Expected error for invalid JSON path")
+	}
+}
+
+func TestXMLExtractModifier(t *testing.T) {
+	registry := NewComprehensiveMatcherBuilder().GetRegistry()
+	payload := `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">
+  <System><EventID>4624</EventID><Computer>dc01</Computer></System>
+  <EventData>
+    <Data Name="TargetUserName">alice</Data>
+    <Data Name="LogonType">3</Data>
+  </EventData>
+</Event>`
+
+	tests := map[string]string{
+		"xml_extract:/Event/System/EventID":                 "4624",
+		"xml_extract://Computer":                            "dc01",
+		"xml_extract:/Event/EventData/Data[2]":              "3",
+		"xml_extract:/Event/EventData/Data[2]/@Name":        "LogonType",
+		"xml_extract://Data[@Name='TargetUserName']/text()": "alice",
+		"xml_extract:/Event/*/EventID":                      "4624",
+		"xml_extract:/Event/System":                         "4624dc01",
+	}
+	for spec, expected := range tests {
+		modifier, exists, err := registry.ResolveModifier(spec)
+		if !exists || err != nil {
+			t.Fatalf("%s: failed to resolve modifier: %v", spec, err)
+		}
+		result, err := modifier(payload)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", spec, err)
+		}
+		if result != expected {
+			t.Errorf("%s: expected '%s', got '%s'", spec, expected, result)
+		}
+	}
+
+	modifier, _, _ := registry.ResolveModifier("xml_extract:/Event/System/EventID")
+	if _, err := modifier("<Event"); !errors.Is(err, ErrNoValue) {
+		t.Errorf("Expected no value for invalid XML, got %v", err)
+	}
+	if _, _, err := registry.ResolveModifier("xml_extract:Event/System"); err == nil {
+		t.Error("Expected error for relative XML path")
+	}
+}
+
+func TestParameterizedModifiers(t *testing.T) {
+	registry := NewComprehensiveMatcherBuilder().GetRegistry()
+
+	tests := []struct {
+		spec     string
+		input    string
+		expected string
+	}{
+		{"substring:0:5", "powershell.exe", "power"},
+		{"substring:-4", "powershell.exe", ".exe"},
+		{"substring:5:100", "powershell", "shell"},
+		{"replace:^:", "p^o^w^e^r", "power"},
+		{`replace:\::_`, "C:\\Windows", "C_\\Windows"},
+		{"split:,:2", "a,b,c,d", "c"},
+		{"split:\\\\:-1", `C:\Windows\System32\cmd.exe`, "cmd.exe"},
+		{"split:,:9", "a,b", ""},
+	}
+	for _, tt := range tests {
+		modifier, exists, err := registry.ResolveModifier(tt.spec)
+		if !exists || err != nil {
+			t.Fatalf("%s: failed to resolve modifier: %v", tt.spec, err)
+		}
+		result, err := modifier(tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.spec, err)
+		}
+		if result != tt.expected {
+			t.Errorf("%s: expected '%s', got '%s'", tt.spec, tt.expected, result)
+		}
+	}
+
+	for _, spec := range []string{"substring", "substring:a", "replace:onlyone", "split:,:x", "split::1"} {
+		if _, _, err := registry.ResolveModifier(spec); err == nil {
+			t.Errorf("%s: expected argument error", spec)
+		}
+	}
+}
+
+func TestRegexCacheExpiryUsesClock(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	config := DefaultCacheConfig()
+	config.Clock = now
+	cache := NewGlobalRegexCache(config)
+
+	if _, err := cache.GetOrCompile("^cmd"); err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	now.Advance(config.TTL)
+	cache.cleanup()
+	if cache.GetStats().CurrentSize != 1 {
+		t.Fatal("Expected pattern to survive until its TTL has passed")
+	}
+
+	now.Advance(time.Second)
+	cache.cleanup()
+	if stats := cache.GetStats(); stats.CurrentSize != 0 || stats.Evictions != 1 {
+		t.Errorf("Expected expired pattern to be evicted, got %+v", stats)
+	}
+}
+
+func TestCompiledPrimitiveDeadline(t *testing.T) {
+	now := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	calls := 0
+	// Every call to the match function takes a millisecond
+	slowMatch := func(fieldValue string, values []string, modifiers []string) (bool, error) {
+		calls++
+		now.Advance(time.Millisecond)
+		for _, value := range values {
+			if value == fieldValue {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	values := make([]string, 100)
+	for i := range values {
+		values[i] = fmt.Sprintf("value%d", i)
+	}
+	primitive := NewCompiledPrimitive([]string{"Field"}, slowMatch, nil, values, nil)
+
+	// Without a deadline all values are matched in one call
+	ctx := NewEventContext(map[string]interface{}{"Field": "value99"})
+	if matched, err := primitive.Matches(ctx); err != nil || !matched || calls != 1 {
+		t.Fatalf("Expected a match in one call, got %v, %v after %d calls", matched, err, calls)
+	}
+
+	// A generous deadline still matches the last value
+	calls = 0
+	ctx = NewEventContext(map[string]interface{}{"Field": "value99"})
+	ctx.SetDeadline(now.Now().Add(time.Second), now)
+	if matched, err := primitive.Matches(ctx); err != nil || !matched {
+		t.Fatalf("Expected a match before the deadline, got %v, %v", matched, err)
+	}
+
+	// The deadline is checked between groups of values
+	calls = 0
+	ctx = NewEventContext(map[string]interface{}{"Field": "value99"})
+	ctx.SetDeadline(now.Now().Add(2*time.Millisecond), now)
+	_, err := primitive.Matches(ctx)
+	var sigmaErr *sigmaerrors.SigmaError
+	if !errors.As(err, &sigmaErr) || sigmaErr.Type != sigmaerrors.ErrorTypeExecutionTimeout {
+		t.Fatalf("Expected an execution timeout, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected matching to stop after 2 groups, got %d calls", calls)
+	}
+	if result := primitive.MatchesWithResult(ctx); result.Error != sigmaerrors.NewExecutionTimeout().Error() {
+		t.Errorf("Expected the detailed result to carry the timeout, got %q", result.Error)
+	}
+}
@@ -0,0 +1,76 @@
+package matcher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PhucNguyen204/sigma-engine-golang/internal/ir"
+	"github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// UnknownModifierMode controls how modifiers missing from the registry are
+// handled when a primitive is compiled
+type UnknownModifierMode int
+
+const (
+	// UnknownModifierError rejects primitives with unknown modifiers (default)
+	UnknownModifierError UnknownModifierMode = iota
+
+	// UnknownModifierSkip drops unknown modifiers from the chain
+	UnknownModifierSkip
+)
+
+// String returns the mode name
+func (m UnknownModifierMode) String() string {
+	switch m {
+	case UnknownModifierError:
+		return "error"
+	case UnknownModifierSkip:
+		return "skip"
+	default:
+		return fmt.Sprintf("UnknownModifierMode(%d)", int(m))
+	}
+}
+
+// IsModifierParameter reports whether a modifier is a parameter read by the
+// match function (e.g. "fuzzy:0.9", "threshold=0.8") rather than a
+// registered transformation
+func IsModifierParameter(name string) bool {
+	return strings.ContainsAny(name, ":=")
+}
+
+// NewUnknownModifierError returns the error reported for an unknown modifier
+func NewUnknownModifierError(modifier, field string) *errors.SigmaError {
+	err := errors.NewModifierError(fmt.Sprintf("unknown modifier '%s' on field '%s'", modifier, field))
+	err.Field = field
+	return err
+}
+
+// NewInvalidModifierError returns the error reported for a parameterized
+// modifier whose arguments are rejected
+func NewInvalidModifierError(modifier, field string, cause error) *errors.SigmaError {
+	err := errors.Wrap(errors.ErrorTypeModifier,
+		fmt.Sprintf("invalid modifier '%s' on field '%s': %v", modifier, field, cause), cause)
+	err.Field = field
+	return err
+}
+
+// buildModifierChain resolves a primitive's modifiers into transformation
+// functions. Parameter modifiers are left to the match function.
+func buildModifierChain(primitive ir.Primitive, registry *MatcherRegistry, mode UnknownModifierMode) ([]ModifierFn, error) {
+	var modifierChain []ModifierFn
+	for _, modifierName := range primitive.Modifiers {
+		modifier, exists, err := registry.ResolveModifier(modifierName)
+		if err != nil {
+			return nil, NewInvalidModifierError(modifierName, primitive.Field, err)
+		}
+		if !exists {
+			if mode == UnknownModifierError && !IsModifierParameter(modifierName) {
+				return nil, NewUnknownModifierError(modifierName, primitive.Field)
+			}
+			continue
+		}
+		modifierChain = append(modifierChain, modifier)
+	}
+	return modifierChain, nil
+}
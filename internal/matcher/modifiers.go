@@ -1,7 +1,11 @@
 package matcher
 
 import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"net/url"
 	"strconv"
@@ -45,8 +49,8 @@ func registerStringModifiers(registry *MatcherRegistry) {
 
 // registerFormatModifiers registers data format modifiers
 func registerFormatModifiers(registry *MatcherRegistry) {
-	registry.RegisterModifier("json_extract", CreateJSONExtractModifier())
-	registry.RegisterModifier("xml_extract", CreateXMLExtractModifier())
+	registry.RegisterModifierFactory("json_extract", CreateJSONExtractModifierFactory())
+	registry.RegisterModifierFactory("xml_extract", CreateXMLExtractModifierFactory())
 	registry.RegisterModifier("csv_extract", CreateCSVExtractModifier())
 	registry.RegisterModifier("split_first", CreateSplitFirstModifier())
 }
@@ -61,10 +65,13 @@ func registerNumericModifiers(registry *MatcherRegistry) {
 
 // registerAdvancedModifiers registers advanced transformation modifiers
 func registerAdvancedModifiers(registry *MatcherRegistry) {
-	registry.RegisterModifier("substring", CreateSubstringModifier())
+	registry.RegisterModifierFactory("substring", CreateSubstringModifierFactory())
+	registry.RegisterModifierFactory("replace", CreateReplaceModifierFactory())
+	registry.RegisterModifierFactory("split", CreateSplitModifierFactory())
 	registry.RegisterModifier("replace_basic", CreateReplaceBasicModifier())
 	registry.RegisterModifier("regex_extract_simple", CreateRegexExtractSimpleModifier())
 	registry.RegisterModifier("hash_md5", CreateMD5HashModifier())
+	registry.RegisterModifier("hash_sha1", CreateSHA1HashModifier())
 	registry.RegisterModifier("hash_sha256", CreateSHA256HashModifier())
 }
 
@@ -274,14 +281,92 @@ func CreateRoundModifier() ModifierFn {
 
 // Advanced Modifiers (simplified implementations)
 
-// CreateSubstringModifier creates a substring extraction modifier
-func CreateSubstringModifier() ModifierFn {
-	return func(input string) (string, error) {
-		// Simplified: extract from index 1 to end (configurable in real implementation)
-		if len(input) > 1 {
-			return input[1:], nil
+// CreateSubstringModifierFactory creates the substring factory:
+// "substring:start" or "substring:start:end", in characters. Negative
+// positions count from the end; out-of-range positions are clamped.
+func CreateSubstringModifierFactory() ModifierFactory {
+	return func(args []string) (ModifierFn, error) {
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("substring takes start and optional end, e.g. substring:0:10")
 		}
-		return input, nil
+		start, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid substring start %q", args[0])
+		}
+		end, hasEnd := 0, len(args) == 2
+		if hasEnd {
+			if end, err = strconv.Atoi(args[1]); err != nil {
+				return nil, fmt.Errorf("invalid substring end %q", args[1])
+			}
+		}
+
+		return func(input string) (string, error) {
+			runes := []rune(input)
+			from := clampIndex(start, len(runes))
+			to := len(runes)
+			if hasEnd {
+				to = clampIndex(end, len(runes))
+			}
+			if from >= to {
+				return "", nil
+			}
+			return string(runes[from:to]), nil
+		}, nil
+	}
+}
+
+// clampIndex resolves a possibly negative position against a length
+func clampIndex(index, length int) int {
+	if index < 0 {
+		index += length
+	}
+	if index < 0 {
+		return 0
+	}
+	if index > length {
+		return length
+	}
+	return index
+}
+
+// CreateReplaceModifierFactory creates the replace factory:
+// "replace:old:new" replaces every occurrence of old (new may be empty)
+func CreateReplaceModifierFactory() ModifierFactory {
+	return func(args []string) (ModifierFn, error) {
+		if len(args) != 2 || args[0] == "" {
+			return nil, fmt.Errorf("replace takes a non-empty search string and a replacement, e.g. replace:foo:bar")
+		}
+		replacer := strings.NewReplacer(args[0], args[1])
+		return func(input string) (string, error) {
+			return replacer.Replace(input), nil
+		}, nil
+	}
+}
+
+// CreateSplitModifierFactory creates the split factory: "split:sep:index"
+// returns the index-th part (negative counts from the end) or an empty
+// string when there are fewer parts
+func CreateSplitModifierFactory() ModifierFactory {
+	return func(args []string) (ModifierFn, error) {
+		if len(args) != 2 || args[0] == "" {
+			return nil, fmt.Errorf("split takes a non-empty separator and an index, e.g. split:,:2")
+		}
+		separator := args[0]
+		index, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid split index %q", args[1])
+		}
+		return func(input string) (string, error) {
+			parts := strings.Split(input, separator)
+			i := index
+			if i < 0 {
+				i += len(parts)
+			}
+			if i < 0 || i >= len(parts) {
+				return "", nil
+			}
+			return parts[i], nil
+		}, nil
 	}
 }
 
@@ -312,35 +397,6 @@ func CreateRegexExtractSimpleModifier() ModifierFn {
 	}
 }
 
-// CreateJSONExtractModifier creates a JSON field extraction modifier (simplified)
-func CreateJSONExtractModifier() ModifierFn {
-	return func(input string) (string, error) {
-		// Simplified implementation - would need proper JSON parsing
-		if strings.Contains(input, ":") {
-			parts := strings.Split(input, ":")
-			if len(parts) > 1 {
-				extracted := strings.Trim(parts[1], " \"")
-				return extracted, nil
-			}
-		}
-		return input, nil
-	}
-}
-
-// CreateXMLExtractModifier creates an XML content extraction modifier (simplified)
-func CreateXMLExtractModifier() ModifierFn {
-	return func(input string) (string, error) {
-		// Simplified implementation - would need proper XML parsing
-		start := strings.Index(input, ">")
-		end := strings.LastIndex(input, "<")
-		if start >= 0 && end > start {
-			extracted := input[start+1 : end]
-			return strings.TrimSpace(extracted), nil
-		}
-		return input, nil
-	}
-}
-
 // CreateCSVExtractModifier creates a CSV field extraction modifier
 func CreateCSVExtractModifier() ModifierFn {
 	return func(input string) (string, error) {
@@ -365,20 +421,26 @@ func CreateSplitFirstModifier() ModifierFn {
 	}
 }
 
-// CreateMD5HashModifier creates an MD5 hash modifier (placeholder)
+// CreateMD5HashModifier creates a modifier returning the lowercase hex MD5 digest
 func CreateMD5HashModifier() ModifierFn {
 	return func(input string) (string, error) {
-		// Placeholder - would need crypto/md5
-		hash := fmt.Sprintf("md5_%x", len(input))
-		return hash, nil
+		sum := md5.Sum([]byte(input))
+		return hex.EncodeToString(sum[:]), nil
+	}
+}
+
+// CreateSHA1HashModifier creates a modifier returning the lowercase hex SHA1 digest
+func CreateSHA1HashModifier() ModifierFn {
+	return func(input string) (string, error) {
+		sum := sha1.Sum([]byte(input))
+		return hex.EncodeToString(sum[:]), nil
 	}
 }
 
-// CreateSHA256HashModifier creates a SHA256 hash modifier (placeholder)
+// CreateSHA256HashModifier creates a modifier returning the lowercase hex SHA256 digest
 func CreateSHA256HashModifier() ModifierFn {
 	return func(input string) (string, error) {
-		// Placeholder - would need crypto/sha256
-		hash := fmt.Sprintf("sha256_%x", len(input)*256)
-		return hash, nil
+		sum := sha256.Sum256([]byte(input))
+		return hex.EncodeToString(sum[:]), nil
 	}
 }
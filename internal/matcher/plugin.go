@@ -0,0 +1,61 @@
+package matcher
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// Symbols looked up in Go plugin extensions. Both are optional, but a plugin
+// must export at least one of them:
+//
+//	var SigmaMatchers = map[string]func(value string, patterns []string) bool{...}
+//	var SigmaModifiers = map[string]func(input string) (string, error){...}
+const (
+	PluginMatchersSymbol  = "SigmaMatchers"
+	PluginModifiersSymbol = "SigmaModifiers"
+)
+
+// LoadPluginExtension loads an extension from a Go plugin (.so) built with
+// `go build -buildmode=plugin`. The plugin must be built with the same Go
+// toolchain as the engine.
+func LoadPluginExtension(path string) (*Extension, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	extension := &Extension{
+		Name:      path,
+		Matchers:  make(map[string]ExtensionMatchFn),
+		Modifiers: make(map[string]ModifierFn),
+	}
+
+	matchersFound := false
+	if symbol, err := p.Lookup(PluginMatchersSymbol); err == nil {
+		matchers, ok := symbol.(*map[string]func(string, []string) bool)
+		if !ok {
+			return nil, fmt.Errorf("%s has type %T, want map[string]func(string, []string) bool", PluginMatchersSymbol, symbol)
+		}
+		for name, fn := range *matchers {
+			extension.Matchers[name] = fn
+		}
+		matchersFound = true
+	}
+
+	modifiersFound := false
+	if symbol, err := p.Lookup(PluginModifiersSymbol); err == nil {
+		modifiers, ok := symbol.(*map[string]func(string) (string, error))
+		if !ok {
+			return nil, fmt.Errorf("%s has type %T, want map[string]func(string) (string, error)", PluginModifiersSymbol, symbol)
+		}
+		for name, fn := range *modifiers {
+			extension.Modifiers[name] = fn
+		}
+		modifiersFound = true
+	}
+
+	if !matchersFound && !modifiersFound {
+		return nil, fmt.Errorf("plugin exports neither %s nor %s", PluginMatchersSymbol, PluginModifiersSymbol)
+	}
+	return extension, nil
+}
@@ -0,0 +1,236 @@
+package matcher
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Protobuf event adapters.
+//
+// Events carried as google.protobuf.Struct or typed protobuf messages are
+// read in place, without a JSON round trip. Path segments select struct
+// keys, message fields (by proto or JSON name), map keys and list indexes,
+// e.g. "process.args.0". Leaves are returned as Go values: numbers, bools
+// and strings as is, enums by name, bytes as strings, timestamps as RFC3339
+// and other messages as JSON. Unset fields with presence are not found.
+//
+// DefaultFieldExtractor adapts proto.Message events automatically; the
+// adapters below can be used to wrap events explicitly.
+
+// StructEvent adapts a google.protobuf.Struct or Value event
+type StructEvent struct {
+	value *structpb.Value
+}
+
+// NewStructEvent wraps a google.protobuf.Struct event
+func NewStructEvent(event *structpb.Struct) *StructEvent {
+	return &StructEvent{value: structpb.NewStructValue(event)}
+}
+
+// NewStructValueEvent wraps a google.protobuf.Value event
+func NewStructValueEvent(event *structpb.Value) *StructEvent {
+	return &StructEvent{value: event}
+}
+
+// LookupField implements FieldAccessor
+func (e *StructEvent) LookupField(fieldPath string) (interface{}, bool) {
+	return lookupStructValue(e.value, strings.Split(fieldPath, "."))
+}
+
+// ProtoEvent adapts a typed protobuf message event using protobuf reflection
+type ProtoEvent struct {
+	message protoreflect.Message
+}
+
+// NewProtoEvent wraps a protobuf message event
+func NewProtoEvent(event proto.Message) *ProtoEvent {
+	return &ProtoEvent{message: event.ProtoReflect()}
+}
+
+// LookupField implements FieldAccessor
+func (e *ProtoEvent) LookupField(fieldPath string) (interface{}, bool) {
+	return lookupProtoMessage(e.message, strings.Split(fieldPath, "."))
+}
+
+// IsSupportedEvent reports whether the engines can evaluate an event: a
+// map[string]interface{}, a FieldAccessor or a protobuf message
+func IsSupportedEvent(event interface{}) bool {
+	switch event.(type) {
+	case map[string]interface{}, FieldAccessor, proto.Message:
+		return true
+	}
+	return false
+}
+
+// structValueOf returns the google.protobuf.Value form of the Struct
+// well-known types
+func structValueOf(message proto.Message) (*structpb.Value, bool) {
+	switch m := message.(type) {
+	case *structpb.Struct:
+		return structpb.NewStructValue(m), true
+	case *structpb.ListValue:
+		return structpb.NewListValue(m), true
+	case *structpb.Value:
+		return m, true
+	}
+	return nil, false
+}
+
+// lookupStructValue follows path segments through struct keys and list indexes
+func lookupStructValue(value *structpb.Value, parts []string) (interface{}, bool) {
+	for _, part := range parts {
+		switch kind := value.GetKind().(type) {
+		case *structpb.Value_StructValue:
+			next, exists := kind.StructValue.GetFields()[part]
+			if !exists {
+				return nil, false
+			}
+			value = next
+		case *structpb.Value_ListValue:
+			values := kind.ListValue.GetValues()
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(values) {
+				return nil, false
+			}
+			value = values[index]
+		default:
+			return nil, false
+		}
+	}
+	if value.GetKind() == nil {
+		return nil, false
+	}
+	return structValueInterface(value), true
+}
+
+// structValueInterface converts a google.protobuf.Value to a Go value
+func structValueInterface(value *structpb.Value) interface{} {
+	switch kind := value.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return kind.StringValue
+	case *structpb.Value_NumberValue:
+		return kind.NumberValue
+	case *structpb.Value_BoolValue:
+		return kind.BoolValue
+	case *structpb.Value_NullValue:
+		return nil
+	default:
+		return value.AsInterface()
+	}
+}
+
+// lookupProtoMessage follows path segments through message fields
+func lookupProtoMessage(message protoreflect.Message, parts []string) (interface{}, bool) {
+	if value, ok := structValueOf(message.Interface()); ok {
+		return lookupStructValue(value, parts)
+	}
+
+	fields := message.Descriptor().Fields()
+	field := fields.ByName(protoreflect.Name(parts[0]))
+	if field == nil {
+		field = fields.ByJSONName(parts[0])
+	}
+	if field == nil || (field.HasPresence() && !message.Has(field)) {
+		return nil, false
+	}
+	return lookupProtoValue(message.Get(field), field, parts[1:])
+}
+
+// lookupProtoValue follows path segments through a field value, indexing
+// lists and maps
+func lookupProtoValue(value protoreflect.Value, field protoreflect.FieldDescriptor, parts []string) (interface{}, bool) {
+	switch {
+	case field.IsList():
+		list := value.List()
+		if len(parts) == 0 {
+			elements := make([]interface{}, list.Len())
+			for i := range elements {
+				elements[i] = protoValueInterface(list.Get(i), field)
+			}
+			return elements, true
+		}
+		index, err := strconv.Atoi(parts[0])
+		if err != nil || index < 0 || index >= list.Len() {
+			return nil, false
+		}
+		return lookupProtoElement(list.Get(index), field, parts[1:])
+
+	case field.IsMap():
+		entries := value.Map()
+		if len(parts) == 0 {
+			result := make(map[string]interface{}, entries.Len())
+			entries.Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+				result[key.String()] = protoValueInterface(value, field.MapValue())
+				return true
+			})
+			return result, true
+		}
+		var found protoreflect.Value
+		entries.Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+			if key.String() == parts[0] {
+				found = value
+				return false
+			}
+			return true
+		})
+		if !found.IsValid() {
+			return nil, false
+		}
+		return lookupProtoElement(found, field.MapValue(), parts[1:])
+
+	default:
+		return lookupProtoElement(value, field, parts)
+	}
+}
+
+// lookupProtoElement returns a single (non-list, non-map) value, descending
+// into messages for the remaining path segments
+func lookupProtoElement(value protoreflect.Value, field protoreflect.FieldDescriptor, parts []string) (interface{}, bool) {
+	if len(parts) == 0 {
+		return protoValueInterface(value, field), true
+	}
+	if field.Message() == nil {
+		return nil, false
+	}
+	return lookupProtoMessage(value.Message(), parts)
+}
+
+// protoValueInterface converts a single field value to a Go value
+func protoValueInterface(value protoreflect.Value, field protoreflect.FieldDescriptor) interface{} {
+	switch field.Kind() {
+	case protoreflect.EnumKind:
+		if enumValue := field.Enum().Values().ByNumber(value.Enum()); enumValue != nil {
+			return string(enumValue.Name())
+		}
+		return int32(value.Enum())
+	case protoreflect.BytesKind:
+		return string(value.Bytes())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return protoMessageInterface(value.Message().Interface())
+	default:
+		return value.Interface()
+	}
+}
+
+// protoMessageInterface converts a message leaf to a Go value
+func protoMessageInterface(message proto.Message) interface{} {
+	if value, ok := structValueOf(message); ok {
+		return structValueInterface(value)
+	}
+	if timestamp, ok := message.(*timestamppb.Timestamp); ok {
+		return timestamp.AsTime().Format(time.RFC3339Nano)
+	}
+	encoded, err := protojson.Marshal(message)
+	if err != nil {
+		return fmt.Sprintf("%v", message)
+	}
+	return string(encoded)
+}
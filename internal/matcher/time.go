@@ -0,0 +1,323 @@
+package matcher
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sigmaerrors "github.com/PhucNguyen204/sigma-engine-golang/pkg/errors"
+)
+
+// Timestamp matchers for time-window and off-hours rules.
+//
+// Field values are parsed as RFC3339 by default, falling back to
+// "2006-01-02 15:04:05" and numeric epoch (seconds or milliseconds, chosen by
+// magnitude). Parameter modifiers configure parsing:
+//
+//	format:epoch      epoch seconds (fractions allowed)
+//	format:epoch_ms   epoch milliseconds
+//	format:<layout>   Go time layout, e.g. format:02/01/2006 15:04
+//	tz:<location>     location for clock-time patterns and layouts without zone (default UTC)
+//
+// Patterns are timestamps in the field format or any auto-detected format,
+// or clock times ("18:00", "06:30:00") that compare the field's time of day.
+// Between takes "start..end" bounds (inclusive); clock ranges may wrap
+// midnight, so "18:00..06:00" matches off-hours. Patterns are parsed when
+// the primitive is compiled; field values that are not timestamps do not
+// match.
+
+// clockLayouts are the accepted time-of-day pattern layouts
+var clockLayouts = []string{"15:04:05", "15:04"}
+
+// fallbackTimeLayouts are tried after RFC3339 when no format is configured
+var fallbackTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// timeBound is a parsed pattern: an instant or a time of day
+type timeBound struct {
+	instant time.Time
+	clock   time.Duration
+	isClock bool
+}
+
+// timeOptions holds the parsing configuration read from parameter modifiers
+type timeOptions struct {
+	format   string
+	location *time.Location
+}
+
+// timeWindow is a parsed "start..end" pattern
+type timeWindow struct {
+	start timeBound
+	end   timeBound
+}
+
+// locations caches loaded time zones by name
+var locations sync.Map
+
+// CreateBeforeMatchFactory creates the before factory, matching timestamps
+// strictly before any pattern
+func CreateBeforeMatchFactory() MatchFactory {
+	return createTimeComparisonFactory(func(cmp int) bool { return cmp < 0 })
+}
+
+// CreateAfterMatchFactory creates the after factory, matching timestamps
+// strictly after any pattern
+func CreateAfterMatchFactory() MatchFactory {
+	return createTimeComparisonFactory(func(cmp int) bool { return cmp > 0 })
+}
+
+// CreateBeforeMatch creates a matcher for timestamps strictly before any
+// pattern, parsing the patterns on every call. Compiled primitives use
+// CreateBeforeMatchFactory instead.
+func CreateBeforeMatch() MatchFn {
+	return matchFnFromFactory(CreateBeforeMatchFactory())
+}
+
+// CreateAfterMatch creates a matcher for timestamps strictly after any
+// pattern, parsing the patterns on every call. Compiled primitives use
+// CreateAfterMatchFactory instead.
+func CreateAfterMatch() MatchFn {
+	return matchFnFromFactory(CreateAfterMatchFactory())
+}
+
+// CreateBetweenMatchFactory creates the between factory, matching
+// timestamps within any "start..end" window
+func CreateBetweenMatchFactory() MatchFactory {
+	return func(values []string, modifiers []string) (MatchFn, error) {
+		options, err := parseTimeOptions(modifiers)
+		if err != nil {
+			return nil, err
+		}
+		windows := make(map[string]timeWindow, len(values))
+		for _, value := range values {
+			if windows[value], err = parseTimeWindow(value, options); err != nil {
+				return nil, err
+			}
+		}
+
+		return func(fieldValue string, group []string, modifiers []string) (bool, error) {
+			timestamp, err := parseTimestamp(fieldValue, options)
+			if err != nil {
+				return false, nil
+			}
+			for _, value := range group {
+				window, exists := windows[value]
+				if !exists {
+					if window, err = parseTimeWindow(value, options); err != nil {
+						return false, err
+					}
+				}
+
+				afterStart := compareTime(timestamp, window.start, options) >= 0
+				beforeEnd := compareTime(timestamp, window.end, options) <= 0
+				if window.start.isClock && window.start.clock > window.end.clock {
+					// Window wraps midnight
+					if afterStart || beforeEnd {
+						return true, nil
+					}
+				} else if afterStart && beforeEnd {
+					return true, nil
+				}
+			}
+			return false, nil
+		}, nil
+	}
+}
+
+// CreateBetweenMatch creates a matcher for timestamps within any
+// "start..end" window, parsing the windows on every call. Compiled
+// primitives use CreateBetweenMatchFactory instead.
+func CreateBetweenMatch() MatchFn {
+	return matchFnFromFactory(CreateBetweenMatchFactory())
+}
+
+// createTimeComparisonFactory matches when accept holds for the comparison
+// of the field timestamp with any pattern
+func createTimeComparisonFactory(accept func(cmp int) bool) MatchFactory {
+	return func(values []string, modifiers []string) (MatchFn, error) {
+		options, err := parseTimeOptions(modifiers)
+		if err != nil {
+			return nil, err
+		}
+		bounds := make(map[string]timeBound, len(values))
+		for _, value := range values {
+			if bounds[value], err = parseTimeBound(value, options); err != nil {
+				return nil, err
+			}
+		}
+
+		return func(fieldValue string, group []string, modifiers []string) (bool, error) {
+			timestamp, err := parseTimestamp(fieldValue, options)
+			if err != nil {
+				return false, nil
+			}
+			for _, value := range group {
+				bound, exists := bounds[value]
+				if !exists {
+					if bound, err = parseTimeBound(value, options); err != nil {
+						return false, err
+					}
+				}
+				if accept(compareTime(timestamp, bound, options)) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}, nil
+	}
+}
+
+// compareTime compares a timestamp with a bound, by time of day for clock bounds
+func compareTime(timestamp time.Time, bound timeBound, options timeOptions) int {
+	if !bound.isClock {
+		return timestamp.Compare(bound.instant)
+	}
+	local := timestamp.In(options.location)
+	clock := time.Duration(local.Hour())*time.Hour +
+		time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second +
+		time.Duration(local.Nanosecond())
+	switch {
+	case clock < bound.clock:
+		return -1
+	case clock > bound.clock:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// parseTimeOptions reads format and tz parameters from the modifiers
+func parseTimeOptions(modifiers []string) (timeOptions, error) {
+	options := timeOptions{location: time.UTC}
+	for _, modifier := range modifiers {
+		if format, ok := strings.CutPrefix(modifier, "format:"); ok {
+			options.format = format
+		} else if name, ok := strings.CutPrefix(modifier, "tz:"); ok {
+			location, err := loadLocation(name)
+			if err != nil {
+				return options, err
+			}
+			options.location = location
+		}
+	}
+	return options, nil
+}
+
+// loadLocation loads a time zone, caching it by name
+func loadLocation(name string) (*time.Location, error) {
+	if cached, ok := locations.Load(name); ok {
+		return cached.(*time.Location), nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", name, err)
+	}
+	locations.Store(name, location)
+	return location, nil
+}
+
+// parseTimeWindow parses a "start..end" pattern
+func parseTimeWindow(window string, options timeOptions) (timeWindow, error) {
+	startStr, endStr, found := strings.Cut(window, "..")
+	if !found {
+		return timeWindow{}, sigmaerrors.Errorf(sigmaerrors.ErrorTypeInvalidRange, "time window %q: expected start..end", window)
+	}
+	start, err := parseTimeBound(strings.TrimSpace(startStr), options)
+	if err != nil {
+		return timeWindow{}, err
+	}
+	end, err := parseTimeBound(strings.TrimSpace(endStr), options)
+	if err != nil {
+		return timeWindow{}, err
+	}
+	if start.isClock != end.isClock {
+		return timeWindow{}, sigmaerrors.Errorf(sigmaerrors.ErrorTypeInvalidRange, "time window %q: cannot mix clock times and timestamps", window)
+	}
+	return timeWindow{start: start, end: end}, nil
+}
+
+// parseTimeBound parses a pattern as a clock time or a timestamp
+func parseTimeBound(pattern string, options timeOptions) (timeBound, error) {
+	for _, layout := range clockLayouts {
+		if clock, err := time.Parse(layout, pattern); err == nil {
+			return timeBound{
+				clock:   clock.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)),
+				isClock: true,
+			}, nil
+		}
+	}
+
+	// Patterns may use the field format or any auto-detected format
+	instant, err := parseTimestamp(pattern, options)
+	if err != nil && options.format != "" {
+		instant, err = parseTimestamp(pattern, timeOptions{location: options.location})
+	}
+	if err != nil {
+		return timeBound{}, fmt.Errorf("invalid time pattern: %w", err)
+	}
+	return timeBound{instant: instant}, nil
+}
+
+// ParseTimestamp parses an event timestamp like the timestamp matchers do
+// without a format modifier: RFC3339, common layouts in UTC, or epoch
+// seconds or milliseconds
+func ParseTimestamp(value string) (time.Time, error) {
+	return parseTimestamp(value, timeOptions{location: time.UTC})
+}
+
+// parseTimestamp parses a timestamp using the configured format, or by
+// auto-detection when none is set
+func parseTimestamp(value string, options timeOptions) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(options.format) {
+	case "":
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t, nil
+		}
+		for _, layout := range fallbackTimeLayouts {
+			if t, err := time.ParseInLocation(layout, value, options.location); err == nil {
+				return t, nil
+			}
+		}
+		if epoch, err := strconv.ParseFloat(value, 64); err == nil {
+			// Values beyond year ~5138 in seconds are taken as milliseconds
+			if math.Abs(epoch) >= 1e11 {
+				return epochTime(epoch, time.Millisecond), nil
+			}
+			return epochTime(epoch, time.Second), nil
+		}
+		return time.Time{}, fmt.Errorf("unrecognized timestamp: %s", value)
+	case "rfc3339":
+		return time.Parse(time.RFC3339Nano, value)
+	case "epoch":
+		epoch, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid epoch timestamp: %s", value)
+		}
+		return epochTime(epoch, time.Second), nil
+	case "epoch_ms":
+		epoch, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid epoch timestamp: %s", value)
+		}
+		return epochTime(epoch, time.Millisecond), nil
+	default:
+		return time.ParseInLocation(options.format, value, options.location)
+	}
+}
+
+// epochTime converts an epoch value in the given unit to a UTC time
+func epochTime(epoch float64, unit time.Duration) time.Time {
+	return time.Unix(0, int64(epoch*float64(unit))).UTC()
+}
+
+// RegisterTimeMatchers registers the timestamp comparison matchers
+func RegisterTimeMatchers(registry *MatcherRegistry) {
+	registry.RegisterMatchFactory("before", CreateBeforeMatchFactory())
+	registry.RegisterMatchFactory("after", CreateAfterMatchFactory())
+	registry.RegisterMatchFactory("between", CreateBetweenMatchFactory())
+}
@@ -2,7 +2,12 @@ package matcher
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+
+	"github.com/cespare/xxhash/v2"
 )
 
 // MatchFn represents a function that matches field values against primitive values
@@ -16,29 +21,57 @@ type MatchFn func(fieldValue string, values []string, modifiers []string) (bool,
 // returns: transformed value or error
 type ModifierFn func(input string) (string, error)
 
+// ModifierFactory builds a modifier from the arguments of a parameterized
+// modifier, e.g. ["$.a.b"] for "json_extract:$.a.b". Arguments are parsed
+// when the primitive is compiled, so errors surface before evaluation.
+type ModifierFactory func(args []string) (ModifierFn, error)
+
+// MatchFactory builds the match function of a primitive from its values and
+// modifiers when the primitive is compiled, so patterns are parsed once and
+// errors surface before evaluation. The built function is called with the
+// primitive's values or a subset of them.
+type MatchFactory func(values []string, modifiers []string) (MatchFn, error)
+
 // FieldExtractorFn represents a function that extracts field values from events
 // event: the event data
 // fieldPath: the field path to extract (e.g., "nested.field")
 // returns: extracted value or error
 type FieldExtractorFn func(event interface{}, fieldPath string) (interface{}, error)
 
+// FieldAccessor is implemented by events that resolve dotted field paths
+// themselves (e.g. StructEvent, ProtoEvent); DefaultFieldExtractor uses it
+// instead of map or reflection access
+type FieldAccessor interface {
+	LookupField(fieldPath string) (interface{}, bool)
+}
+
 // MatchResult represents the result of matching a primitive against an event
 type MatchResult struct {
 	Matched          bool   `json:"matched"`
 	FieldPath        string `json:"field_path"`
 	MatchedValue     string `json:"matched_value,omitempty"`
 	TransformedValue string `json:"transformed_value,omitempty"`
+	MatchedPattern   string `json:"matched_pattern,omitempty"` // Primitive value that matched
+	PatternIndex     int    `json:"pattern_index"`             // Index of MatchedPattern in the primitive values (-1 = none)
 	Error            string `json:"error,omitempty"`
 }
 
 // NewMatchResult creates a new match result
 func NewMatchResult(matched bool, fieldPath string) *MatchResult {
 	return &MatchResult{
-		Matched:   matched,
-		FieldPath: fieldPath,
+		Matched:      matched,
+		FieldPath:    fieldPath,
+		PatternIndex: -1,
 	}
 }
 
+// WithMatchedPattern records which primitive value matched
+func (mr *MatchResult) WithMatchedPattern(index int, pattern string) *MatchResult {
+	mr.PatternIndex = index
+	mr.MatchedPattern = pattern
+	return mr
+}
+
 // WithMatchedValue sets the matched value
 func (mr *MatchResult) WithMatchedValue(value string) *MatchResult {
 	mr.MatchedValue = value
@@ -62,24 +95,52 @@ func (mr *MatchResult) WithError(err error) *MatchResult {
 
 // MatcherRegistry manages the registration and lookup of match functions
 type MatcherRegistry struct {
-	matchers  map[string]MatchFn
-	modifiers map[string]ModifierFn
-	mutex     sync.RWMutex
+	matchers       map[string]MatchFn
+	matchFactories map[string]MatchFactory
+	modifiers      map[string]ModifierFn
+	factories      map[string]ModifierFactory
+	mutex          sync.RWMutex
 }
 
 // NewMatcherRegistry creates a new matcher registry
 func NewMatcherRegistry() *MatcherRegistry {
 	return &MatcherRegistry{
-		matchers:  make(map[string]MatchFn),
-		modifiers: make(map[string]ModifierFn),
+		matchers:       make(map[string]MatchFn),
+		matchFactories: make(map[string]MatchFactory),
+		modifiers:      make(map[string]ModifierFn),
+		factories:      make(map[string]ModifierFactory),
 	}
 }
 
-// RegisterMatcher registers a match function
+// RegisterMatcher registers a match function, replacing any match factory
+// of the name
 func (r *MatcherRegistry) RegisterMatcher(name string, matcher MatchFn) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	r.matchers[name] = matcher
+	delete(r.matchFactories, name)
+}
+
+// RegisterMatchFactory registers a match type whose primitives build their
+// match function when compiled. GetMatcher returns a function building one
+// on every call, for callers without a compiled primitive.
+func (r *MatcherRegistry) RegisterMatchFactory(name string, factory MatchFactory) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.matchers[name] = matchFnFromFactory(factory)
+	r.matchFactories[name] = factory
+}
+
+// matchFnFromFactory returns a match function that builds one from the
+// factory for the values and modifiers of each call
+func matchFnFromFactory(factory MatchFactory) MatchFn {
+	return func(fieldValue string, values []string, modifiers []string) (bool, error) {
+		matchFn, err := factory(values, modifiers)
+		if err != nil {
+			return false, err
+		}
+		return matchFn(fieldValue, values, modifiers)
+	}
 }
 
 // RegisterModifier registers a modifier function
@@ -89,6 +150,14 @@ func (r *MatcherRegistry) RegisterModifier(name string, modifier ModifierFn) {
 	r.modifiers[name] = modifier
 }
 
+// RegisterModifierFactory registers a parameterized modifier, used as
+// "name:arg1:arg2" in rules
+func (r *MatcherRegistry) RegisterModifierFactory(name string, factory ModifierFactory) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.factories[name] = factory
+}
+
 // GetMatcher retrieves a match function by name
 func (r *MatcherRegistry) GetMatcher(name string) (MatchFn, bool) {
 	r.mutex.RLock()
@@ -97,6 +166,14 @@ func (r *MatcherRegistry) GetMatcher(name string) (MatchFn, bool) {
 	return matcher, exists
 }
 
+// GetMatchFactory retrieves a match factory by name
+func (r *MatcherRegistry) GetMatchFactory(name string) (MatchFactory, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	factory, exists := r.matchFactories[name]
+	return factory, exists
+}
+
 // GetModifier retrieves a modifier function by name
 func (r *MatcherRegistry) GetModifier(name string) (ModifierFn, bool) {
 	r.mutex.RLock()
@@ -105,6 +182,66 @@ func (r *MatcherRegistry) GetModifier(name string) (ModifierFn, bool) {
 	return modifier, exists
 }
 
+// GetModifierFactory retrieves a parameterized modifier factory by name
+func (r *MatcherRegistry) GetModifierFactory(name string) (ModifierFactory, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	factory, exists := r.factories[name]
+	return factory, exists
+}
+
+// ResolveModifier resolves a modifier as written in a rule: a registered
+// modifier name, or a factory name followed by ":"-separated arguments
+// (see ParseModifierArgs). It reports false when neither is registered, and
+// an error when the factory rejects the arguments.
+func (r *MatcherRegistry) ResolveModifier(spec string) (ModifierFn, bool, error) {
+	if modifier, exists := r.GetModifier(spec); exists {
+		return modifier, true, nil
+	}
+
+	name, rawArgs, hasArgs := strings.Cut(spec, ":")
+	factory, exists := r.GetModifierFactory(name)
+	if !exists {
+		return nil, false, nil
+	}
+	var args []string
+	if hasArgs {
+		args = ParseModifierArgs(rawArgs)
+	}
+	modifier, err := factory(args)
+	if err != nil {
+		return nil, true, err
+	}
+	return modifier, true, nil
+}
+
+// ParseModifierArgs splits modifier arguments on ":". A backslash escapes
+// the next character, so the arguments of `replace:\::-` are ":" and "-".
+// Empty arguments are kept ("replace:foo:" replaces with nothing).
+func ParseModifierArgs(raw string) []string {
+	var args []string
+	var current strings.Builder
+	escaped := false
+	for _, r := range raw {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ':':
+			args = append(args, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if escaped {
+		current.WriteRune('\\')
+	}
+	return append(args, current.String())
+}
+
 // ListMatchers returns all registered matcher names
 func (r *MatcherRegistry) ListMatchers() []string {
 	r.mutex.RLock()
@@ -129,6 +266,28 @@ func (r *MatcherRegistry) ListModifiers() []string {
 	return names
 }
 
+// Fingerprint identifies the registered matcher, modifier and modifier
+// factory names, so artifacts compiled against one registry can tell
+// whether another registry resolves the same names. It does not cover the
+// behavior of the functions.
+func (r *MatcherRegistry) Fingerprint() string {
+	r.mutex.RLock()
+	names := make([]string, 0, len(r.matchers)+len(r.modifiers)+len(r.factories))
+	for name := range r.matchers {
+		names = append(names, "matcher:"+name)
+	}
+	for name := range r.modifiers {
+		names = append(names, "modifier:"+name)
+	}
+	for name := range r.factories {
+		names = append(names, "factory:"+name)
+	}
+	r.mutex.RUnlock()
+
+	sort.Strings(names)
+	return fmt.Sprintf("%016x", xxhash.Sum64String(strings.Join(names, "\n")))
+}
+
 // MatcherCount returns the number of registered matchers
 func (r *MatcherRegistry) MatcherCount() int {
 	r.mutex.RLock()
@@ -148,7 +307,9 @@ func (r *MatcherRegistry) Clear() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	r.matchers = make(map[string]MatchFn)
+	r.matchFactories = make(map[string]MatchFactory)
 	r.modifiers = make(map[string]ModifierFn)
+	r.factories = make(map[string]ModifierFactory)
 }
 
 // Common errors
@@ -160,6 +321,7 @@ var (
 	ErrInvalidFieldValue     = errors.New("invalid field value")
 	ErrMatchFunctionFailed   = errors.New("match function failed")
 	ErrModifierFailed        = errors.New("modifier function failed")
+	ErrUnsupportedEvent      = errors.New("event must be a map[string]interface{}, FieldAccessor or protobuf message")
 )
 
 // Default registry instance (can be used globally)
@@ -0,0 +1,108 @@
+package matcher
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Unicode normalization modifiers for obfuscated process names and domains.
+//
+//	nfkc              compatibility composition, e.g. fullwidth "ｃｍｄ" -> "cmd"
+//	strip_diacritics  removes combining marks, e.g. "pówershell" -> "powershell"
+//	homoglyph         NFKC, then maps Cyrillic/Greek lookalikes to ASCII,
+//	                  e.g. "рowershеll" (Cyrillic р, е) -> "powershell"
+//
+// The modifiers only transform the field value, so rule values should be
+// written in plain ASCII.
+
+// homoglyphs maps Cyrillic and Greek letters to the ASCII letters they are
+// visually confusable with
+var homoglyphs = map[rune]rune{
+	// Cyrillic lowercase
+	'а': 'a', 'в': 'b', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j', 'к': 'k',
+	'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y',
+	'х': 'x', 'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'ё': 'e', 'ї': 'i',
+	// Cyrillic uppercase
+	'А': 'A', 'В': 'B', 'Е': 'E', 'Һ': 'H', 'І': 'I', 'Ј': 'J', 'К': 'K',
+	'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'У': 'Y',
+	'Х': 'X', 'Ѕ': 'S', 'Ԁ': 'D', 'Ԛ': 'Q', 'Ԝ': 'W', 'Ё': 'E', 'Ї': 'I',
+	// Greek lowercase
+	'α': 'a', 'β': 'b', 'γ': 'y', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v',
+	'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	// Greek uppercase
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K',
+	'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+}
+
+// isASCII reports whether s contains only ASCII bytes, which no modifier in
+// this file changes
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// StripDiacritics decomposes s and removes combining marks
+func StripDiacritics(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	stripped := strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, norm.NFD.String(s))
+	return norm.NFC.String(stripped)
+}
+
+// FoldHomoglyphs applies NFKC and replaces Cyrillic and Greek lookalikes with
+// their ASCII counterparts
+func FoldHomoglyphs(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if ascii, exists := homoglyphs[r]; exists {
+			return ascii
+		}
+		return r
+	}, norm.NFKC.String(s))
+}
+
+// CreateNFKCModifier creates a modifier applying Unicode NFKC normalization
+func CreateNFKCModifier() ModifierFn {
+	return func(input string) (string, error) {
+		if isASCII(input) {
+			return input, nil
+		}
+		return norm.NFKC.String(input), nil
+	}
+}
+
+// CreateStripDiacriticsModifier creates a modifier removing diacritics
+func CreateStripDiacriticsModifier() ModifierFn {
+	return func(input string) (string, error) {
+		return StripDiacritics(input), nil
+	}
+}
+
+// CreateHomoglyphModifier creates a modifier folding homoglyphs to ASCII
+func CreateHomoglyphModifier() ModifierFn {
+	return func(input string) (string, error) {
+		return FoldHomoglyphs(input), nil
+	}
+}
+
+// RegisterUnicodeModifiers registers the Unicode normalization modifiers
+func RegisterUnicodeModifiers(registry *MatcherRegistry) {
+	registry.RegisterModifier("nfkc", CreateNFKCModifier())
+	registry.RegisterModifier("strip_diacritics", CreateStripDiacriticsModifier())
+	registry.RegisterModifier("homoglyph", CreateHomoglyphModifier())
+}
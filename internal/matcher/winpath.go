@@ -0,0 +1,185 @@
+package matcher
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Windows path normalization for path-based matches.
+//
+// NormalizeWindowsPath lowercases a path, converts "/" to "\", strips the
+// "\\?\", "\\.\" and "\??\" prefixes ("\\?\UNC\host" becomes "\\host"),
+// collapses repeated separators and resolves a leading environment variable
+// such as %SystemRoot% or the kernel form \SystemRoot\ to its default
+// location. Short (8.3) names can only be expanded for well-known
+// directories such as PROGRA~1, since resolving others needs the filesystem.
+//
+//	winpath               modifier normalizing the field value
+//	winpath:shortnames    same, also expanding well-known 8.3 names
+//	winpath_equals        matchers normalizing the field and patterns
+//	winpath_startswith    (with short-name expansion), so
+//	winpath_endswith      "%SystemRoot%/system32/" matches
+//	winpath_contains      "\\?\C:\Windows\System32\cmd.exe"
+
+// windowsEnvPrefixes maps environment variables to their default expansion
+var windowsEnvPrefixes = map[string]string{
+	"systemroot":              `c:\windows`,
+	"windir":                  `c:\windows`,
+	"systemdrive":             `c:`,
+	"programfiles":            `c:\program files`,
+	"programfiles(x86)":       `c:\program files (x86)`,
+	"programw6432":            `c:\program files`,
+	"programdata":             `c:\programdata`,
+	"allusersprofile":         `c:\programdata`,
+	"commonprogramfiles":      `c:\program files\common files`,
+	"public":                  `c:\users\public`,
+	"commonprogramw6432":      `c:\program files\common files`,
+	"commonprogramfiles(x86)": `c:\program files (x86)\common files`,
+}
+
+// windowsShortNames maps well-known 8.3 directory names to their long names
+var windowsShortNames = map[string]string{
+	"progra~1": "program files",
+	"progra~2": "program files (x86)",
+	"progra~3": "programdata",
+	"common~1": "common files",
+	"docume~1": "documents and settings",
+	"micros~1": "microsoft",
+	"window~1": "windowspowershell",
+}
+
+// windowsDevicePrefixes are stripped from the start of normalized paths
+var windowsDevicePrefixes = []string{`\\?\`, `\\.\`, `\??\`}
+
+// normalizedWindowsPatterns caches normalized matcher patterns
+var normalizedWindowsPatterns sync.Map
+
+// NormalizeWindowsPath returns the canonical lowercase form of a Windows path
+func NormalizeWindowsPath(path string, expandShortNames bool) string {
+	path = strings.TrimSpace(path)
+	if len(path) >= 2 && path[0] == '"' && path[len(path)-1] == '"' {
+		path = path[1 : len(path)-1]
+	}
+	path = strings.ToLower(strings.ReplaceAll(path, "/", `\`))
+
+	// Device and UNC prefixes
+	if strings.HasPrefix(path, `\\?\unc\`) {
+		path = `\\` + path[len(`\\?\unc\`):]
+	} else {
+		for _, prefix := range windowsDevicePrefixes {
+			if strings.HasPrefix(path, prefix) {
+				path = path[len(prefix):]
+				break
+			}
+		}
+	}
+
+	// Environment variable and \SystemRoot\ prefixes
+	if strings.HasPrefix(path, "%") {
+		if end := strings.IndexByte(path[1:], '%'); end >= 0 {
+			if expansion, exists := windowsEnvPrefixes[path[1:end+1]]; exists {
+				path = expansion + path[end+2:]
+			}
+		}
+	} else if path == `\systemroot` || strings.HasPrefix(path, `\systemroot\`) {
+		path = windowsEnvPrefixes["systemroot"] + path[len(`\systemroot`):]
+	}
+
+	// Collapse repeated separators, keeping a leading UNC "\\"
+	var builder strings.Builder
+	builder.Grow(len(path))
+	start := 0
+	if strings.HasPrefix(path, `\\`) {
+		builder.WriteString(`\\`)
+		start = 2
+	}
+	for i := start; i < len(path); i++ {
+		if path[i] == '\\' && i > start && path[i-1] == '\\' {
+			continue
+		}
+		builder.WriteByte(path[i])
+	}
+	path = builder.String()
+
+	if expandShortNames && strings.Contains(path, "~") {
+		components := strings.Split(path, `\`)
+		for i, component := range components {
+			if long, exists := windowsShortNames[component]; exists {
+				components[i] = long
+			}
+		}
+		path = strings.Join(components, `\`)
+	}
+	return path
+}
+
+// CreateWindowsPathModifierFactory creates the winpath factory
+func CreateWindowsPathModifierFactory() ModifierFactory {
+	return func(args []string) (ModifierFn, error) {
+		expandShortNames := false
+		switch {
+		case len(args) == 0:
+		case len(args) == 1 && args[0] == "shortnames":
+			expandShortNames = true
+		default:
+			return nil, fmt.Errorf("winpath accepts only the optional argument \"shortnames\", got %q", args)
+		}
+		return func(input string) (string, error) {
+			return NormalizeWindowsPath(input, expandShortNames), nil
+		}, nil
+	}
+}
+
+// createWindowsPathMatch creates a matcher comparing normalized paths
+func createWindowsPathMatch(compare func(path, pattern string) bool) MatchFn {
+	return func(fieldValue string, values []string, modifiers []string) (bool, error) {
+		path := NormalizeWindowsPath(fieldValue, true)
+		for _, value := range values {
+			if compare(path, normalizedWindowsPattern(value)) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// normalizedWindowsPattern normalizes a pattern once and caches the result
+func normalizedWindowsPattern(pattern string) string {
+	if cached, exists := normalizedWindowsPatterns.Load(pattern); exists {
+		return cached.(string)
+	}
+	normalized := NormalizeWindowsPath(pattern, true)
+	normalizedWindowsPatterns.Store(pattern, normalized)
+	return normalized
+}
+
+// CreateWindowsPathEqualsMatch creates a matcher for equal normalized paths
+func CreateWindowsPathEqualsMatch() MatchFn {
+	return createWindowsPathMatch(func(path, pattern string) bool { return path == pattern })
+}
+
+// CreateWindowsPathStartsWithMatch creates a matcher for normalized path prefixes
+func CreateWindowsPathStartsWithMatch() MatchFn {
+	return createWindowsPathMatch(strings.HasPrefix)
+}
+
+// CreateWindowsPathEndsWithMatch creates a matcher for normalized path suffixes
+func CreateWindowsPathEndsWithMatch() MatchFn {
+	return createWindowsPathMatch(strings.HasSuffix)
+}
+
+// CreateWindowsPathContainsMatch creates a matcher for normalized path substrings
+func CreateWindowsPathContainsMatch() MatchFn {
+	return createWindowsPathMatch(strings.Contains)
+}
+
+// RegisterWindowsPathMatchers registers the Windows path matchers and modifier
+func RegisterWindowsPathMatchers(registry *MatcherRegistry) {
+	registry.RegisterMatcher("winpath_equals", CreateWindowsPathEqualsMatch())
+	registry.RegisterMatcher("winpath_startswith", CreateWindowsPathStartsWithMatch())
+	registry.RegisterMatcher("winpath_endswith", CreateWindowsPathEndsWithMatch())
+	registry.RegisterMatcher("winpath_contains", CreateWindowsPathContainsMatch())
+
+	registry.RegisterModifierFactory("winpath", CreateWindowsPathModifierFactory())
+}
@@ -1,6 +1,7 @@
 package errors
 
 import (
+	stderrors "errors"
 	"fmt"
 )
 
@@ -95,6 +96,12 @@ const (
 	ErrorTypeInvalidNumericValue
 	ErrorTypeInvalidFieldPath
 	ErrorTypeDangerousRegexPattern
+
+	// Resource limit errors
+	ErrorTypeMemoryBudgetExceeded
+
+	// Configuration errors
+	ErrorTypeConfig
 )
 
 func (et ErrorType) String() string {
@@ -151,6 +158,10 @@ func (et ErrorType) String() string {
 		return "INVALID_FIELD_PATH"
 	case ErrorTypeDangerousRegexPattern:
 		return "DANGEROUS_REGEX_PATTERN"
+	case ErrorTypeMemoryBudgetExceeded:
+		return "MEMORY_BUDGET_EXCEEDED"
+	case ErrorTypeConfig:
+		return "CONFIG"
 	default:
 		return "UNKNOWN"
 	}
@@ -161,6 +172,8 @@ type SigmaError struct {
 	Message      string    `json:"message"`                 // String data (for most variants)
 	Details      string    `json:"details,omitempty"`       // Additional context
 	NumericValue *uint64   `json:"numeric_value,omitempty"` // For u32/u64 data in Rust variants
+	Rule         string    `json:"rule,omitempty"`          // Title of the rule the error occurred in
+	Field        string    `json:"field,omitempty"`         // Event field the error occurred on
 	Cause        error     `json:"-"`                       // Wrapped error (equivalent to error chaining)
 }
 
@@ -233,6 +246,10 @@ func (e *SigmaError) Error() string {
 		return fmt.Sprintf("Invalid field path: %s", e.Message)
 	case ErrorTypeDangerousRegexPattern:
 		return fmt.Sprintf("Dangerous regex pattern detected: %s", e.Message)
+	case ErrorTypeMemoryBudgetExceeded:
+		return fmt.Sprintf("Memory budget exceeded: %s", e.Message)
+	case ErrorTypeConfig:
+		return fmt.Sprintf("Configuration error: %s", e.Message)
 	default:
 		return fmt.Sprintf("Unknown error: %s", e.Message)
 	}
@@ -277,6 +294,65 @@ func Wrap(errType ErrorType, message string, cause error) *SigmaError {
 	}
 }
 
+// Errorf creates a SigmaError of the given type with a formatted message.
+// An error operand of a %w verb becomes the cause.
+func Errorf(errType ErrorType, format string, args ...interface{}) *SigmaError {
+	formatted := fmt.Errorf(format, args...)
+	return Wrap(errType, formatted.Error(), stderrors.Unwrap(formatted))
+}
+
+// WithRule records the rule on the first SigmaError err wraps, unless it
+// already names one. An err wrapping no SigmaError is wrapped in one of
+// errType, so callers can always errors.As the result into a SigmaError.
+func WithRule(err error, errType ErrorType, rule string) error {
+	return withContext(err, errType, func(sigmaErr *SigmaError) {
+		if sigmaErr.Rule == "" {
+			sigmaErr.Rule = rule
+		}
+	})
+}
+
+// WithField records the event field on the first SigmaError err wraps,
+// unless it already names one. An err wrapping no SigmaError is wrapped in
+// one of errType.
+func WithField(err error, errType ErrorType, field string) error {
+	return withContext(err, errType, func(sigmaErr *SigmaError) {
+		if sigmaErr.Field == "" {
+			sigmaErr.Field = field
+		}
+	})
+}
+
+func withContext(err error, errType ErrorType, set func(*SigmaError)) error {
+	if err == nil {
+		return nil
+	}
+	var sigmaErr *SigmaError
+	if stderrors.As(err, &sigmaErr) {
+		set(sigmaErr)
+		return err
+	}
+	sigmaErr = Wrap(errType, err.Error(), err)
+	set(sigmaErr)
+	return sigmaErr
+}
+
+// IsType reports whether err or any error it wraps is a SigmaError of the
+// given type
+func IsType(err error, errType ErrorType) bool {
+	for err != nil {
+		var sigmaErr *SigmaError
+		if !stderrors.As(err, &sigmaErr) {
+			return false
+		}
+		if sigmaErr.Type == errType {
+			return true
+		}
+		err = sigmaErr.Cause
+	}
+	return false
+}
+
 func NewCompilationError(message string) *SigmaError {
 	return New(ErrorTypeCompilation, message)
 }
@@ -381,6 +457,15 @@ func NewDangerousRegexPattern(pattern string) *SigmaError {
 	return New(ErrorTypeDangerousRegexPattern, pattern)
 }
 
+func NewMemoryBudgetExceeded(required, budget uint64) *SigmaError {
+	return NewWithNumeric(ErrorTypeMemoryBudgetExceeded,
+		fmt.Sprintf("compiled ruleset needs %d bytes, budget is %d bytes", required, budget), required)
+}
+
+func NewConfigError(message string) *SigmaError {
+	return New(ErrorTypeConfig, message)
+}
+
 func WrapIOError(err error) *SigmaError {
 	if err == nil {
 		return nil